@@ -0,0 +1,265 @@
+// Package admin is an HTTP API for inspecting and operating a running DHCP server.
+//
+// The API lists leases and cached backend reads, dry-runs a lookup for a MAC address, toggles maintenance mode,
+// dumps the effective handler configuration, and reports backend health. All responses are JSON:
+//
+//	GET /leases               the leases of Leases
+//	GET /cache                the stats and unexpired reads of Cache
+//	GET /lookup?mac=<mac>     the DHCPOFFER Handler would send to mac, see reservation.Handler.DryRun
+//	GET /maintenance          whether maintenance mode of Handler is enabled
+//	PUT /maintenance          enable or disable maintenance mode with a {"enabled":true} body
+//	GET /config               the effective configuration of Handler
+//	GET /health               the Health handler, or the health of Handler when Health is not set
+//
+// The API has no authentication of its own. Serve it on a loopback or management address only.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/tinkerbell/dhcp/backend/cache"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler/reservation"
+	"github.com/tinkerbell/dhcp/lease"
+)
+
+// lookupTimeout bounds a lookup made through the API.
+const lookupTimeout = 10 * time.Second
+
+// Server is an http.Handler serving the admin API. Endpoints for a nil field respond with a 501 status code.
+//
+// For example:
+//
+//	a := &admin.Server{Handler: h, Cache: c, Health: srv.HealthHandler()}
+//	go http.ListenAndServe("127.0.0.1:8081", a)
+type Server struct {
+	// Handler is the handler that lookups, maintenance mode, and the configuration are served for.
+	Handler *reservation.Handler
+
+	// Leases is the lease store that leases are listed from.
+	Leases lease.Store
+
+	// Cache is the caching backend that cached reads are listed from. It is commonly the Backend of Handler.
+	Cache *cache.Backend
+
+	// Health reports the health of the server, for example dhcp.Server.HealthHandler.
+	// When it is not set, the health of Handler, see reservation.Handler.Health, is reported instead.
+	Health http.Handler
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/leases":
+		s.serve(w, r, s.Leases != nil, s.leases, http.MethodGet)
+	case "/cache":
+		s.serve(w, r, s.Cache != nil, s.cache, http.MethodGet)
+	case "/lookup":
+		s.serve(w, r, s.Handler != nil, s.lookup, http.MethodGet)
+	case "/maintenance":
+		s.serve(w, r, s.Handler != nil, s.maintenance, http.MethodGet, http.MethodPut)
+	case "/config":
+		s.serve(w, r, s.Handler != nil, s.config, http.MethodGet)
+	case "/health":
+		if s.Health != nil {
+			s.serve(w, r, true, s.Health.ServeHTTP, http.MethodGet)
+			return
+		}
+		s.serve(w, r, s.Handler != nil, s.health, http.MethodGet)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown endpoint %v", r.URL.Path))
+	}
+}
+
+// serve calls fn when the endpoint is configured and the request method is one of methods.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request, configured bool, fn http.HandlerFunc, methods ...string) {
+	if !configured {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("endpoint %v is not configured", r.URL.Path))
+		return
+	}
+	for _, m := range methods {
+		if r.Method == m {
+			fn(w, r)
+			return
+		}
+	}
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %v is not allowed for endpoint %v", r.Method, r.URL.Path))
+}
+
+type leaseResponse struct {
+	MAC     string     `json:"mac"`
+	IP      netip.Addr `json:"ip"`
+	Expires time.Time  `json:"expires"`
+}
+
+func (s *Server) leases(w http.ResponseWriter, r *http.Request) {
+	leases, err := s.Leases.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to list leases: %w", err))
+		return
+	}
+	resp := make([]leaseResponse, 0, len(leases))
+	for _, l := range leases {
+		resp = append(resp, leaseResponse{MAC: l.MAC.String(), IP: l.IP, Expires: l.Expires})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type cacheEntryResponse struct {
+	Key       string     `json:"key"`
+	Expires   time.Time  `json:"expires"`
+	Error     string     `json:"error,omitempty"`
+	MAC       string     `json:"mac,omitempty"`
+	IPAddress netip.Addr `json:"ipAddress"`
+	Hostname  string     `json:"hostname,omitempty"`
+	Netboot   bool       `json:"allowNetboot,omitempty"`
+}
+
+func (s *Server) cache(w http.ResponseWriter, _ *http.Request) {
+	stats := s.Cache.Stats()
+	resp := struct {
+		Hits         uint64               `json:"hits"`
+		NegativeHits uint64               `json:"negativeHits"`
+		Misses       uint64               `json:"misses"`
+		Evictions    uint64               `json:"evictions"`
+		Entries      []cacheEntryResponse `json:"entries"`
+	}{Hits: stats.Hits, NegativeHits: stats.NegativeHits, Misses: stats.Misses, Evictions: stats.Evictions, Entries: []cacheEntryResponse{}}
+	for _, e := range s.Cache.Entries() {
+		c := cacheEntryResponse{Key: e.Key, Expires: e.Expires}
+		if e.Err != nil {
+			c.Error = e.Err.Error()
+		}
+		if e.DHCP != nil {
+			if len(e.DHCP.MACAddress) > 0 {
+				c.MAC = e.DHCP.MACAddress.String()
+			}
+			c.IPAddress = e.DHCP.IPAddress
+			c.Hostname = e.DHCP.Hostname
+		}
+		if e.Netboot != nil {
+			c.Netboot = e.Netboot.AllowNetboot
+		}
+		resp.Entries = append(resp.Entries, c)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) {
+	mac, err := net.ParseMAC(r.URL.Query().Get("mac"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid mac query parameter: %w", err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), lookupTimeout)
+	defer cancel()
+	reply, err := s.Handler.DryRun(ctx, mac)
+	if err != nil {
+		writeError(w, lookupStatus(err), err)
+		return
+	}
+
+	resp := struct {
+		MAC              string `json:"mac"`
+		Type             string `json:"type"`
+		IPAddress        string `json:"ipAddress"`
+		ServerIdentifier string `json:"serverIdentifier,omitempty"`
+		NextServer       string `json:"nextServer,omitempty"`
+		BootFileName     string `json:"bootFileName,omitempty"`
+		Summary          string `json:"summary"`
+	}{
+		MAC:          mac.String(),
+		Type:         reply.MessageType().String(),
+		IPAddress:    reply.YourIPAddr.String(),
+		BootFileName: reply.BootFileName,
+		Summary:      reply.Summary(),
+	}
+	if id := reply.ServerIdentifier(); id != nil {
+		resp.ServerIdentifier = id.String()
+	}
+	if ns := reply.ServerIPAddr; ns != nil && !ns.IsUnspecified() {
+		resp.NextServer = ns.String()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// lookupStatus returns the status code for the lookup error err.
+func lookupStatus(err error) int {
+	switch {
+	case data.IsNotFound(err):
+		return http.StatusNotFound
+	case data.IsInvalidRecord(err):
+		return http.StatusUnprocessableEntity
+	case data.IsUnauthorized(err), data.IsUnavailable(err), errors.Is(err, reservation.ErrReadTimeout):
+		return http.StatusBadGateway
+	}
+
+	return http.StatusInternalServerError
+}
+
+type maintenanceBody struct {
+	Enabled bool `json:"enabled"`
+}
+
+func (s *Server) maintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		var b maintenanceBody
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+			return
+		}
+		s.Handler.SetMaintenance(b.Enabled)
+	}
+	writeJSON(w, http.StatusOK, maintenanceBody{Enabled: s.Handler.Maintenance()})
+}
+
+func (s *Server) config(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, newConfigResponse(s.Handler.Config()))
+}
+
+type healthResponse struct {
+	Healthy  bool       `json:"healthy"`
+	Backend  string     `json:"backend"`
+	LastRead *time.Time `json:"lastRead,omitempty"`
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), lookupTimeout)
+	defer cancel()
+	st := s.Handler.Health(ctx)
+	resp := healthResponse{Healthy: st.Backend == nil, Backend: "ok"}
+	if st.Backend != nil {
+		resp.Backend = st.Backend.Error()
+	}
+	if !st.LastRead.IsZero() {
+		t := st.LastRead.UTC()
+		resp.LastRead = &t
+	}
+	code := http.StatusOK
+	if !resp.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, resp)
+}
+
+// writeJSON writes v as the JSON body of a response with the status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err as the JSON body of a response with the status code. For example:
+//
+//	{"error":"not found"}
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, struct {
+		Error string `json:"error"`
+	}{Error: err.Error()})
+}
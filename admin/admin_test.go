@@ -0,0 +1,234 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/dhcp/backend/cache"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler/reservation"
+	"github.com/tinkerbell/dhcp/lease"
+)
+
+// mockBackend serves a record for every MAC address, except for those in errs.
+type mockBackend struct {
+	errs map[string]error
+}
+
+func (m *mockBackend) GetByMac(_ context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	if err := m.errs[mac.String()]; err != nil {
+		return nil, nil, err
+	}
+	return &data.DHCP{MACAddress: mac, IPAddress: netip.MustParseAddr("192.168.2.10"), Hostname: "node1"}, &data.Netboot{}, nil
+}
+
+func (m *mockBackend) GetByIP(context.Context, net.IP) (*data.DHCP, *data.Netboot, error) {
+	return nil, nil, data.ErrNotFound
+}
+
+// do sends a request to s and returns the status code and the body of the response.
+func do(t *testing.T, s http.Handler, method, target, body string) (int, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	b, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, strings.TrimSpace(string(b))
+}
+
+func TestServeHTTP(t *testing.T) {
+	tests := map[string]struct {
+		method   string
+		target   string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		"leases": {
+			target:   "/leases",
+			wantCode: http.StatusOK,
+			wantBody: `[{"mac":"00:00:00:00:00:01","ip":"192.168.2.10","expires":"2030-01-01T00:00:00Z"}]`,
+		},
+		"lookup": {
+			target:   "/lookup?mac=00:00:00:00:00:01",
+			wantCode: http.StatusOK,
+			wantBody: `"ipAddress":"192.168.2.10","serverIdentifier":"192.168.2.1","nextServer":"192.168.2.1"`,
+		},
+		"lookup not found": {
+			target:   "/lookup?mac=00:00:00:00:00:02",
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"not found"}`,
+		},
+		"lookup unavailable": {
+			target:   "/lookup?mac=00:00:00:00:00:03",
+			wantCode: http.StatusBadGateway,
+			wantBody: `{"error":"backend unavailable: connection refused"}`,
+		},
+		"lookup invalid mac": {
+			target:   "/lookup?mac=nope",
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"invalid mac query parameter: address nope: invalid MAC address"}`,
+		},
+		"maintenance": {
+			target:   "/maintenance",
+			wantCode: http.StatusOK,
+			wantBody: `{"enabled":false}`,
+		},
+		"enable maintenance": {
+			method:   http.MethodPut,
+			target:   "/maintenance",
+			body:     `{"enabled":true}`,
+			wantCode: http.StatusOK,
+			wantBody: `{"enabled":true}`,
+		},
+		"invalid maintenance body": {
+			method:   http.MethodPut,
+			target:   "/maintenance",
+			body:     `on`,
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"invalid body: invalid character 'o' looking for beginning of value"}`,
+		},
+		"config": {
+			target:   "/config",
+			wantCode: http.StatusOK,
+			wantBody: `{"ipAddr":"192.168.2.1","netboot":{"enabled":false,"ipxeBinServerTFTP":"","ipxeBinServerHTTP":"http://192.168.2.1:8080","ipxeScriptURL":false,"userClass":""},` +
+				`"otelEnabled":false,"traceparent":{"mode":"suboption","code":0},"syslogAddr":"","readTimeout":"0s","validation":{"subnets":["192.168.2.0/24"],"minLeaseTime":0,"maxLeaseTime":0,"urlSchemes":["http","https","tftp"]},` +
+				`"errorPolicy":{"notFound":"default","unauthorized":"default","unavailable":"drop","invalidRecord":"default","other":"default","authoritative":false}}`,
+		},
+		"health": {
+			target:   "/health",
+			wantCode: http.StatusOK,
+			wantBody: `{"healthy":true}`,
+		},
+		"method not allowed": {
+			method:   http.MethodPost,
+			target:   "/leases",
+			wantCode: http.StatusMethodNotAllowed,
+			wantBody: `{"error":"method POST is not allowed for endpoint /leases"}`,
+		},
+		"unknown endpoint": {
+			target:   "/nope",
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"unknown endpoint /nope"}`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			leases := lease.NewMemoryStore()
+			if err := leases.Put(context.Background(), lease.Lease{
+				MAC:     net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+				IP:      netip.MustParseAddr("192.168.2.10"),
+				Expires: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+			}); err != nil {
+				t.Fatal(err)
+			}
+			s := &Server{
+				Handler: &reservation.Handler{
+					Backend: &mockBackend{errs: map[string]error{
+						"00:00:00:00:00:02": data.ErrNotFound,
+						"00:00:00:00:00:03": fmt.Errorf("%w: connection refused", data.ErrUnavailable),
+					}},
+					IPAddr:      netip.MustParseAddr("192.168.2.1"),
+					Netboot:     reservation.Netboot{IPXEBinServerHTTP: &url.URL{Scheme: "http", Host: "192.168.2.1:8080"}},
+					Validation:  reservation.Validation{Subnets: []netip.Prefix{netip.MustParsePrefix("192.168.2.0/24")}},
+					ErrorPolicy: reservation.ErrorPolicy{Unavailable: reservation.ActionDrop},
+				},
+				Leases: leases,
+				Health: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { _, _ = io.WriteString(w, `{"healthy":true}`) }),
+			}
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			code, body := do(t, s, method, tt.target, tt.body)
+			if code != tt.wantCode {
+				t.Fatalf("got status code %d, want %d: %v", code, tt.wantCode, body)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Fatalf("got body %v, want it to contain %v", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := &cache.Backend{
+		Backend:     &mockBackend{errs: map[string]error{"00:00:00:00:00:02": data.ErrNotFound}},
+		TTL:         time.Minute,
+		NegativeTTL: time.Minute,
+	}
+	for _, mac := range []net.HardwareAddr{{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, {0x00, 0x00, 0x00, 0x00, 0x00, 0x02}} {
+		_, _, _ = c.GetByMac(context.Background(), mac)
+	}
+	_, _, _ = c.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01})
+
+	code, body := do(t, &Server{Cache: c}, http.MethodGet, "/cache", "")
+	if code != http.StatusOK {
+		t.Fatalf("got status code %d, want %d: %v", code, http.StatusOK, body)
+	}
+	// the expiry times are not known, so they are cut from the body.
+	for _, k := range c.Entries() {
+		body = strings.Replace(body, `"expires":"`+k.Expires.Format(time.RFC3339Nano)+`",`, "", 1)
+	}
+	want := `{"hits":1,"negativeHits":0,"misses":2,"evictions":0,"entries":[` +
+		`{"key":"mac:00:00:00:00:00:01","mac":"00:00:00:00:00:01","ipAddress":"192.168.2.10","hostname":"node1"},` +
+		`{"key":"mac:00:00:00:00:00:02","error":"not found","ipAddress":""}]}`
+	if diff := cmp.Diff(want, body); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	h := &reservation.Handler{}
+	s := &Server{Handler: h}
+	for _, enabled := range []bool{true, false} {
+		if code, body := do(t, s, http.MethodPut, "/maintenance", fmt.Sprintf(`{"enabled":%v}`, enabled)); code != http.StatusOK {
+			t.Fatalf("got status code %d, want %d: %v", code, http.StatusOK, body)
+		}
+		if got := h.Maintenance(); got != enabled {
+			t.Fatalf("Maintenance() = %v, want %v", got, enabled)
+		}
+	}
+}
+
+func TestNotConfigured(t *testing.T) {
+	for _, target := range []string{"/leases", "/cache", "/lookup", "/maintenance", "/config", "/health"} {
+		code, body := do(t, &Server{}, http.MethodGet, target, "")
+		if code != http.StatusNotImplemented {
+			t.Fatalf("%v: got status code %d, want %d", target, code, http.StatusNotImplemented)
+		}
+		if want := `{"error":"endpoint ` + target + ` is not configured"}`; body != want {
+			t.Fatalf("%v: got body %v, want %v", target, body, want)
+		}
+	}
+}
+
+func TestHandlerHealth(t *testing.T) {
+	h := &reservation.Handler{Backend: &mockBackend{}, IPAddr: netip.MustParseAddr("192.168.2.1")}
+	code, body := do(t, &Server{Handler: h}, http.MethodGet, "/health", "")
+	if code != http.StatusOK {
+		t.Fatalf("got status code %d, want %d: %v", code, http.StatusOK, body)
+	}
+	if want := `{"healthy":true,"backend":"ok"}`; !strings.Contains(body, want) {
+		t.Fatalf("got body %v, want it to contain %v", body, want)
+	}
+
+	if _, err := h.DryRun(context.Background(), net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := do(t, &Server{Handler: h}, http.MethodGet, "/health", ""); !strings.Contains(body, `"lastRead":"`) {
+		t.Fatalf("got body %v, want it to contain the time of the last read", body)
+	}
+}
@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"net/netip"
+
+	"github.com/tinkerbell/dhcp/handler/reservation"
+)
+
+// configResponse is the JSON form of a reservation.Config.
+type configResponse struct {
+	IPAddr      netip.Addr          `json:"ipAddr"`
+	Netboot     netbootResponse     `json:"netboot"`
+	OTELEnabled bool                `json:"otelEnabled"`
+	Traceparent traceparentResponse `json:"traceparent"`
+	SyslogAddr  netip.Addr          `json:"syslogAddr"`
+	ReadTimeout string              `json:"readTimeout"`
+	Validation  validationResponse  `json:"validation"`
+	ErrorPolicy errorPolicyResponse `json:"errorPolicy"`
+}
+
+type netbootResponse struct {
+	Enabled           bool   `json:"enabled"`
+	IPXEBinServerTFTP string `json:"ipxeBinServerTFTP"`
+	IPXEBinServerHTTP string `json:"ipxeBinServerHTTP"`
+	// IPXEScriptURL is a function of the DHCP message, so only whether it is set is reported.
+	IPXEScriptURL bool   `json:"ipxeScriptURL"`
+	UserClass     string `json:"userClass"`
+}
+
+type traceparentResponse struct {
+	Mode string `json:"mode"`
+	Code uint8  `json:"code"`
+}
+
+type validationResponse struct {
+	Subnets      []netip.Prefix `json:"subnets"`
+	MinLeaseTime uint32         `json:"minLeaseTime"`
+	MaxLeaseTime uint32         `json:"maxLeaseTime"`
+	URLSchemes   []string       `json:"urlSchemes"`
+}
+
+type errorPolicyResponse struct {
+	NotFound      string `json:"notFound"`
+	Unauthorized  string `json:"unauthorized"`
+	Unavailable   string `json:"unavailable"`
+	InvalidRecord string `json:"invalidRecord"`
+	Other         string `json:"other"`
+	Authoritative bool   `json:"authoritative"`
+}
+
+// newConfigResponse returns the JSON form of c.
+func newConfigResponse(c reservation.Config) configResponse {
+	r := configResponse{
+		IPAddr:      c.IPAddr,
+		OTELEnabled: c.OTELEnabled,
+		Traceparent: traceparentResponse{Mode: c.Traceparent.Mode.String(), Code: c.Traceparent.Code},
+		SyslogAddr:  c.SyslogAddr,
+		ReadTimeout: c.ReadTimeout.String(),
+		Netboot: netbootResponse{
+			Enabled:       c.Netboot.Enabled,
+			IPXEScriptURL: c.Netboot.IPXEScriptURL != nil,
+			UserClass:     string(c.Netboot.UserClass),
+		},
+		Validation: validationResponse{
+			Subnets:      c.Validation.Subnets,
+			MinLeaseTime: c.Validation.MinLeaseTime,
+			MaxLeaseTime: c.Validation.MaxLeaseTime,
+			URLSchemes:   c.Validation.URLSchemes,
+		},
+		ErrorPolicy: errorPolicyResponse{
+			NotFound:      c.ErrorPolicy.NotFound.String(),
+			Unauthorized:  c.ErrorPolicy.Unauthorized.String(),
+			Unavailable:   c.ErrorPolicy.Unavailable.String(),
+			InvalidRecord: c.ErrorPolicy.InvalidRecord.String(),
+			Other:         c.ErrorPolicy.Other.String(),
+			Authoritative: c.ErrorPolicy.Authoritative,
+		},
+	}
+	if c.Netboot.IPXEBinServerTFTP.IsValid() {
+		r.Netboot.IPXEBinServerTFTP = c.Netboot.IPXEBinServerTFTP.String()
+	}
+	if c.Netboot.IPXEBinServerHTTP != nil {
+		r.Netboot.IPXEBinServerHTTP = c.Netboot.IPXEBinServerHTTP.String()
+	}
+	if r.Validation.Subnets == nil {
+		r.Validation.Subnets = []netip.Prefix{}
+	}
+	if r.Validation.URLSchemes == nil {
+		r.Validation.URLSchemes = reservation.DefaultURLSchemes
+	}
+
+	return r
+}
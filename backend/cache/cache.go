@@ -0,0 +1,246 @@
+// Package cache is a backend that memoizes the results of another backend.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+const (
+	// DefaultTTL is the default amount of time a successful read is cached.
+	DefaultTTL = 30 * time.Second
+	// DefaultMaxEntries is the default maximum number of cached reads.
+	DefaultMaxEntries = 10000
+)
+
+// Backend wraps a handler.BackendReader and caches its results.
+//
+// Successful reads are cached for TTL. Reads that fail with a not found error
+// (see data.IsNotFound) are cached for NegativeTTL.
+// All other errors are never cached. When the cache holds MaxEntries reads,
+// the least recently used read is evicted.
+//
+// The returned *data.DHCP and *data.Netboot values are shared between callers and must not be modified.
+type Backend struct {
+	// Backend is the backend whose reads are cached.
+	Backend handler.BackendReader
+
+	// TTL is how long a successful read is cached.
+	TTL time.Duration
+
+	// NegativeTTL is how long a not found read is cached. A zero value disables negative caching.
+	NegativeTTL time.Duration
+
+	// MaxEntries is the maximum number of cached reads. A zero value means no limit.
+	MaxEntries int
+
+	mu      sync.Mutex // protects entries and lru
+	entries map[string]*list.Element
+	lru     *list.List
+
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	negativeHits atomic.Uint64
+	evictions    atomic.Uint64
+}
+
+// Stats holds the cache hit and miss counters.
+type Stats struct {
+	// Hits is the number of reads served from a cached successful read.
+	Hits uint64
+	// NegativeHits is the number of reads served from a cached not found read.
+	NegativeHits uint64
+	// Misses is the number of reads that were passed to the wrapped backend.
+	Misses uint64
+	// Evictions is the number of cached reads removed to stay under MaxEntries.
+	Evictions uint64
+	// Entries is the number of cached reads, including expired reads that have not been removed yet.
+	Entries int
+}
+
+// Entry is a cached read, see Backend.Entries.
+type Entry struct {
+	// Key identifies the read, "mac:" followed by the MAC address or "ip:" followed by the IP address that was read.
+	Key string
+	// DHCP and Netboot are the result of a successful read.
+	DHCP    *data.DHCP
+	Netboot *data.Netboot
+	// Err is the not found error of a not found read.
+	Err error
+	// Expires is when the read is removed from the cache.
+	Expires time.Time
+}
+
+type entry struct {
+	key     string
+	dhcp    *data.DHCP
+	netboot *data.Netboot
+	err     error
+	expires time.Time
+}
+
+// NewBackend returns a caching backend wrapping b with the default TTL and max entries.
+func NewBackend(b handler.BackendReader) *Backend {
+	return &Backend{
+		Backend:    b,
+		TTL:        DefaultTTL,
+		MaxEntries: DefaultMaxEntries,
+	}
+}
+
+// GetByMac implements the handler.BackendReader interface.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.cache.GetByMac")
+	defer span.End()
+
+	return b.read(ctx, "mac:"+mac.String(), span, func(ctx context.Context) (*data.DHCP, *data.Netboot, error) {
+		return b.Backend.GetByMac(ctx, mac)
+	})
+}
+
+// GetByIP implements the handler.BackendReader interface.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.cache.GetByIP")
+	defer span.End()
+
+	return b.read(ctx, "ip:"+ip.String(), span, func(ctx context.Context) (*data.DHCP, *data.Netboot, error) {
+		return b.Backend.GetByIP(ctx, ip)
+	})
+}
+
+// Stats returns the current cache counters.
+func (b *Backend) Stats() Stats {
+	b.mu.Lock()
+	n := len(b.entries)
+	b.mu.Unlock()
+
+	return Stats{
+		Hits:         b.hits.Load(),
+		NegativeHits: b.negativeHits.Load(),
+		Misses:       b.misses.Load(),
+		Evictions:    b.evictions.Load(),
+		Entries:      n,
+	}
+}
+
+// Entries returns the unexpired cached reads, most recently used first.
+// The DHCP and Netboot values are shared with the cache and must not be modified.
+func (b *Backend) Entries() []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.lru == nil {
+		return nil
+	}
+	now := time.Now()
+	entries := make([]Entry, 0, b.lru.Len())
+	for elem := b.lru.Front(); elem != nil; elem = elem.Next() {
+		e := elem.Value.(*entry)
+		if now.After(e.expires) {
+			continue
+		}
+		entries = append(entries, Entry{Key: e.key, DHCP: e.dhcp, Netboot: e.netboot, Err: e.err, Expires: e.expires})
+	}
+
+	return entries
+}
+
+// Purge removes all cached reads.
+func (b *Backend) Purge() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = nil
+	b.lru = nil
+}
+
+// read returns the cached read for key or calls fn and caches the result.
+func (b *Backend) read(ctx context.Context, key string, s trace.Span, fn func(context.Context) (*data.DHCP, *data.Netboot, error)) (*data.DHCP, *data.Netboot, error) {
+	if e, ok := b.get(key); ok {
+		if e.err != nil {
+			b.negativeHits.Add(1)
+			s.SetAttributes(attribute.String("cache", "negative-hit"))
+			s.SetStatus(codes.Error, redact.FromContext(ctx).Error(e.err))
+
+			return nil, nil, e.err
+		}
+		b.hits.Add(1)
+		s.SetAttributes(attribute.String("cache", "hit"))
+		s.SetStatus(codes.Ok, "")
+
+		return e.dhcp, e.netboot, nil
+	}
+
+	b.misses.Add(1)
+	s.SetAttributes(attribute.String("cache", "miss"))
+	d, n, err := fn(ctx)
+	if err != nil {
+		if data.IsNotFound(err) && b.NegativeTTL > 0 {
+			b.set(&entry{key: key, err: err, expires: time.Now().Add(b.NegativeTTL)})
+		}
+		s.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	if b.TTL > 0 {
+		b.set(&entry{key: key, dhcp: d, netboot: n, expires: time.Now().Add(b.TTL)})
+	}
+	s.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// get returns the unexpired entry for key and marks it as recently used.
+func (b *Backend) get(key string) (*entry, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	elem, ok := b.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if time.Now().After(e.expires) {
+		b.lru.Remove(elem)
+		delete(b.entries, key)
+		return nil, false
+	}
+	b.lru.MoveToFront(elem)
+
+	return e, true
+}
+
+// set adds or replaces the entry for e.key, evicting the least recently used entries if needed.
+func (b *Backend) set(e *entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.entries == nil {
+		b.entries = make(map[string]*list.Element)
+		b.lru = list.New()
+	}
+	if elem, ok := b.entries[e.key]; ok {
+		elem.Value = e
+		b.lru.MoveToFront(elem)
+		return
+	}
+	b.entries[e.key] = b.lru.PushFront(e)
+	for b.MaxEntries > 0 && b.lru.Len() > b.MaxEntries {
+		oldest := b.lru.Back()
+		b.lru.Remove(oldest)
+		delete(b.entries, oldest.Value.(*entry).key)
+		b.evictions.Add(1)
+	}
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+type notFoundError struct{}
+
+func (notFoundError) NotFound() bool { return true }
+
+func (notFoundError) Error() string { return "not found" }
+
+var errBackend = errors.New("backend error")
+
+type mockBackend struct {
+	calls int
+	err   error
+}
+
+func (m *mockBackend) GetByMac(_ context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, nil, m.err
+	}
+	return &data.DHCP{MACAddress: mac, IPAddress: netip.MustParseAddr("192.168.2.10")}, &data.Netboot{AllowNetboot: true}, nil
+}
+
+func (m *mockBackend) GetByIP(_ context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, nil, m.err
+	}
+	a, _ := netip.AddrFromSlice(ip.To4())
+	return &data.DHCP{IPAddress: a}, &data.Netboot{}, nil
+}
+
+func TestGetByMac(t *testing.T) {
+	tests := map[string]struct {
+		backend     *mockBackend
+		negativeTTL time.Duration
+		wantCalls   int
+		wantErr     error
+		wantStats   Stats
+	}{
+		"cached": {
+			backend:   &mockBackend{},
+			wantCalls: 1,
+			wantStats: Stats{Hits: 2, Misses: 1, Entries: 1},
+		},
+		"not found without negative caching": {
+			backend:   &mockBackend{err: notFoundError{}},
+			wantCalls: 3,
+			wantErr:   notFoundError{},
+			wantStats: Stats{Misses: 3},
+		},
+		"not found with negative caching": {
+			backend:     &mockBackend{err: notFoundError{}},
+			negativeTTL: time.Minute,
+			wantCalls:   1,
+			wantErr:     notFoundError{},
+			wantStats:   Stats{NegativeHits: 2, Misses: 1, Entries: 1},
+		},
+		"other errors are not cached": {
+			backend:     &mockBackend{err: errBackend},
+			negativeTTL: time.Minute,
+			wantCalls:   3,
+			wantErr:     errBackend,
+			wantStats:   Stats{Misses: 3},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := NewBackend(tt.backend)
+			b.NegativeTTL = tt.negativeTTL
+			for i := 0; i < 3; i++ {
+				if _, _, err := b.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			}
+			if tt.backend.calls != tt.wantCalls {
+				t.Errorf("got %d backend calls, want %d", tt.backend.calls, tt.wantCalls)
+			}
+			if diff := cmp.Diff(b.Stats(), tt.wantStats); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestGetByIP(t *testing.T) {
+	m := &mockBackend{}
+	b := NewBackend(m)
+	for i := 0; i < 2; i++ {
+		d, _, err := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 10))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.IPAddress != netip.MustParseAddr("192.168.2.10") {
+			t.Fatalf("got %v, want 192.168.2.10", d.IPAddress)
+		}
+	}
+	if m.calls != 1 {
+		t.Fatalf("got %d backend calls, want 1", m.calls)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	m := &mockBackend{}
+	b := &Backend{Backend: m, TTL: time.Millisecond}
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	if _, _, err := b.GetByMac(context.Background(), mac); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, _, err := b.GetByMac(context.Background(), mac); err != nil {
+		t.Fatal(err)
+	}
+	if m.calls != 2 {
+		t.Fatalf("got %d backend calls, want 2", m.calls)
+	}
+}
+
+func TestMaxEntries(t *testing.T) {
+	m := &mockBackend{}
+	b := &Backend{Backend: m, TTL: time.Minute, MaxEntries: 2}
+	macs := []net.HardwareAddr{
+		{0x00, 0x01, 0x02, 0x03, 0x04, 0x01},
+		{0x00, 0x01, 0x02, 0x03, 0x04, 0x02},
+		{0x00, 0x01, 0x02, 0x03, 0x04, 0x03},
+	}
+	for _, mac := range macs {
+		if _, _, err := b.GetByMac(context.Background(), mac); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first mac was evicted, the last mac is still cached.
+	for _, mac := range []net.HardwareAddr{macs[2], macs[0]} {
+		if _, _, err := b.GetByMac(context.Background(), mac); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := Stats{Hits: 1, Misses: 4, Evictions: 2, Entries: 2}
+	if diff := cmp.Diff(b.Stats(), want); diff != "" {
+		t.Error(diff)
+	}
+
+	b.Purge()
+	if got := b.Stats().Entries; got != 0 {
+		t.Fatalf("got %d entries after Purge, want 0", got)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	b := &Backend{Backend: &mockBackend{}, TTL: time.Minute, NegativeTTL: time.Millisecond}
+	if got := b.Entries(); got != nil {
+		t.Fatalf("got %v entries, want none", got)
+	}
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	if _, _, err := b.GetByMac(context.Background(), mac); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.GetByIP(context.Background(), net.IP{192, 168, 2, 20}); err != nil {
+		t.Fatal(err)
+	}
+	b.Backend = &mockBackend{err: notFoundError{}}
+	if _, _, err := b.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x06}); err == nil {
+		t.Fatal("GetByMac() error = nil, want a not found error")
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// the expired not found read is not listed.
+	var keys []string
+	for _, e := range b.Entries() {
+		keys = append(keys, e.Key)
+	}
+	if diff := cmp.Diff([]string{"ip:192.168.2.20", "mac:00:01:02:03:04:05"}, keys); diff != "" {
+		t.Fatal(diff)
+	}
+	if got := b.Entries()[1].DHCP.IPAddress; got != netip.MustParseAddr("192.168.2.10") {
+		t.Fatalf("got IP address %v, want 192.168.2.10", got)
+	}
+}
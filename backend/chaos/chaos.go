@@ -0,0 +1,232 @@
+// Package chaos is a backend that injects faults into the reads of another backend: latency, errors, and stale data.
+//
+// It is meant for staging and game days, to check that the handler, the backends that wrap it, like the resilient
+// backend, and the alerts on the backend metrics behave as expected when the real backend, for example a Tink server,
+// is slow, failing, or serving old data.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+// ErrInjected is the default error of the reads that are failed by the backend. It wraps data.ErrUnavailable, so it is
+// classified, retried, and counted like the error of an unreachable backend.
+var ErrInjected = fmt.Errorf("%w: injected fault", data.ErrUnavailable)
+
+// Faults of a read, as recorded in the chaos.fault attribute of its span.
+const (
+	faultNone  = "none"
+	faultError = "error"
+	faultStale = "stale"
+)
+
+// Backend wraps a handler.BackendReader and injects faults into its reads.
+//
+// Every read is delayed by Latency, plus a random part of Jitter. A read whose context is done first returns the error
+// of the context. Then a fraction ErrorRate of the reads fail with Err, and a fraction StaleRate of the remaining reads
+// are answered with the data of an earlier read of the same MAC or IP address, without reading the wrapped backend.
+//
+// The zero values inject no faults. The fields must not be changed while the backend is in use.
+type Backend struct {
+	// Backend is the wrapped backend.
+	Backend handler.BackendReader
+
+	// Latency is added to every read.
+	Latency time.Duration
+
+	// Jitter is the maximum of a random latency that is added to every read, on top of Latency.
+	Jitter time.Duration
+
+	// ErrorRate is the fraction of reads, from 0 to 1, that fail with Err.
+	ErrorRate float64
+
+	// Err is the error of the failed reads. Defaults to ErrInjected.
+	Err error
+
+	// StaleRate is the fraction of reads, from 0 to 1, that are answered with stale data. A MAC or IP address that was
+	// not read successfully before has no stale data, and its reads are passed to the wrapped backend.
+	StaleRate float64
+
+	// StaleAge is how long the data of a read is kept as the stale data of its MAC or IP address. Once it is older, the
+	// next successful read replaces it. A zero value keeps the data of the first successful read for good.
+	StaleAge time.Duration
+
+	// Rand returns a random number in [0, 1). Defaults to rand.Float64. Tests set it to inject faults deterministically.
+	Rand func() float64
+
+	mu    sync.Mutex // protects stale
+	stale map[string]snapshot
+}
+
+// snapshot is the data of a successful read.
+type snapshot struct {
+	d  *data.DHCP
+	n  *data.Netboot
+	at time.Time
+}
+
+// clone returns a snapshot with shallow copies of the data of s.
+func (s snapshot) clone() snapshot {
+	if s.d != nil {
+		d := *s.d
+		s.d = &d
+	}
+	if s.n != nil {
+		n := *s.n
+		s.n = &n
+	}
+
+	return s
+}
+
+// NewBackend returns a Backend wrapping b that injects no faults until they are configured.
+func NewBackend(b handler.BackendReader) *Backend {
+	return &Backend{Backend: b}
+}
+
+// GetByMac implements the handler.BackendReader interface.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.chaos.GetByMac")
+	defer span.End()
+
+	return b.read(ctx, span, "mac:"+mac.String(), func(ctx context.Context) (*data.DHCP, *data.Netboot, error) {
+		return b.Backend.GetByMac(ctx, mac)
+	})
+}
+
+// GetByIP implements the handler.BackendReader interface.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.chaos.GetByIP")
+	defer span.End()
+
+	return b.read(ctx, span, "ip:"+ip.String(), func(ctx context.Context) (*data.DHCP, *data.Netboot, error) {
+		return b.Backend.GetByIP(ctx, ip)
+	})
+}
+
+// Healthy implements the handler.HealthChecker interface. It returns the health of the wrapped backend, if the wrapped
+// backend implements handler.HealthChecker. Faults are not injected into health checks.
+func (b *Backend) Healthy(ctx context.Context) error {
+	if hc, ok := b.Backend.(handler.HealthChecker); ok {
+		return hc.Healthy(ctx)
+	}
+
+	return nil
+}
+
+// read injects the faults into a read of key, and calls get if the read is not failed or answered with stale data.
+func (b *Backend) read(ctx context.Context, span trace.Span, key string, get func(context.Context) (*data.DHCP, *data.Netboot, error)) (*data.DHCP, *data.Netboot, error) {
+	latency := b.latency()
+	span.SetAttributes(attribute.String("chaos.latency", latency.String()))
+	d, n, fault, err := b.inject(ctx, latency, key, get)
+	span.SetAttributes(attribute.String("chaos.fault", fault))
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// inject waits for latency, and then fails the read, answers it with stale data, or calls get. It returns the fault
+// that was injected.
+func (b *Backend) inject(ctx context.Context, latency time.Duration, key string, get func(context.Context) (*data.DHCP, *data.Netboot, error)) (*data.DHCP, *data.Netboot, string, error) {
+	if latency > 0 {
+		t := time.NewTimer(latency)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, nil, faultNone, ctx.Err()
+		case <-t.C:
+		}
+	}
+	if b.chance(b.ErrorRate) {
+		if b.Err != nil {
+			return nil, nil, faultError, b.Err
+		}
+
+		return nil, nil, faultError, ErrInjected
+	}
+	if b.chance(b.StaleRate) {
+		if s, ok := b.snapshot(key); ok {
+			return s.d, s.n, faultStale, nil
+		}
+	}
+
+	d, n, err := get(ctx)
+	if err == nil {
+		b.keep(key, d, n)
+	}
+
+	return d, n, faultNone, err
+}
+
+// latency returns the latency of a read, Latency and a random part of Jitter.
+func (b *Backend) latency() time.Duration {
+	if b.Jitter <= 0 {
+		return b.Latency
+	}
+
+	return b.Latency + time.Duration(b.random()*float64(b.Jitter))
+}
+
+// chance reports whether a fault with rate happens to a read.
+func (b *Backend) chance(rate float64) bool {
+	return rate > 0 && b.random() < rate
+}
+
+// random returns a random number in [0, 1).
+func (b *Backend) random() float64 {
+	if b.Rand != nil {
+		return b.Rand()
+	}
+
+	return rand.Float64()
+}
+
+// snapshot returns a copy of the stale data of key, so that the stale data is not changed by the callers.
+func (b *Backend) snapshot(key string) (snapshot, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.stale[key]
+
+	return s.clone(), ok
+}
+
+// keep keeps d and n as the stale data of key, unless key has stale data that is younger than StaleAge.
+func (b *Backend) keep(key string, d *data.DHCP, n *data.Netboot) {
+	if b.StaleRate <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s, ok := b.stale[key]; ok && (b.StaleAge <= 0 || time.Since(s.at) < b.StaleAge) {
+		return
+	}
+	if b.stale == nil {
+		b.stale = make(map[string]snapshot)
+	}
+	b.stale[key] = snapshot{d: d, n: n, at: time.Now()}.clone()
+}
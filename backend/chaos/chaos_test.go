@@ -0,0 +1,150 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tinkerbell/dhcp/backend/mock"
+	"github.com/tinkerbell/dhcp/backend/resilient"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler"
+)
+
+var (
+	_ handler.BackendReader = &Backend{}
+	_ handler.HealthChecker = &Backend{}
+)
+
+var (
+	mac        = net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	errBackend = errors.New("backend error")
+)
+
+// sequence returns a Rand func that returns rs in order, and then the last one.
+func sequence(rs ...float64) func() float64 {
+	i := 0
+	return func() float64 {
+		r := rs[min(i, len(rs)-1)]
+		i++
+		return r
+	}
+}
+
+func TestErrorRate(t *testing.T) {
+	tests := map[string]struct {
+		rate      float64
+		err       error
+		rand      float64
+		wantErr   error
+		wantCalls int
+	}{
+		"no faults":      {rand: 0, wantCalls: 1},
+		"below the rate": {rate: 0.5, rand: 0.25, wantErr: ErrInjected},
+		"above the rate": {rate: 0.5, rand: 0.75, wantCalls: 1},
+		"every read":     {rate: 1, rand: 0.99, wantErr: data.ErrUnavailable},
+		"custom error":   {rate: 1, err: errBackend, wantErr: errBackend},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := mock.New(mock.WithDefault(mock.Response{DHCP: &data.DHCP{Hostname: "server-01"}, Netboot: &data.Netboot{}}))
+			b := NewBackend(m)
+			b.ErrorRate = tt.rate
+			b.Err = tt.err
+			b.Rand = sequence(tt.rand)
+
+			_, _, err := b.GetByMac(context.Background(), mac)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := len(m.Calls()); got != tt.wantCalls {
+				t.Fatalf("got %d reads of the wrapped backend, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestLatency(t *testing.T) {
+	m := mock.New(mock.WithDefault(mock.Response{DHCP: &data.DHCP{}, Netboot: &data.Netboot{}}))
+	b := NewBackend(m)
+	b.Latency = 20 * time.Millisecond
+	b.Jitter = 20 * time.Millisecond
+	b.Rand = sequence(0.5)
+
+	start := time.Now()
+	if _, _, err := b.GetByIP(context.Background(), net.IP{192, 168, 2, 150}); err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Since(start); got < 30*time.Millisecond {
+		t.Fatalf("GetByIP() returned after %v, want at least 30ms", got)
+	}
+
+	b.Latency = time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := b.GetByIP(ctx, net.IP{192, 168, 2, 150}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetByIP() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := len(m.Calls()); got != 1 {
+		t.Fatalf("got %d reads of the wrapped backend, want 1", got)
+	}
+}
+
+func TestStale(t *testing.T) {
+	m := mock.New(mock.WithMAC(mac,
+		mock.Response{DHCP: &data.DHCP{Hostname: "old"}, Netboot: &data.Netboot{}},
+		mock.Response{DHCP: &data.DHCP{Hostname: "new"}, Netboot: &data.Netboot{}},
+	))
+	b := NewBackend(m)
+	b.StaleRate = 0.5
+	// the first read has no stale data, the second is stale, and the third is not.
+	b.Rand = sequence(0, 0, 0.75)
+
+	for i, want := range []string{"old", "old", "new"} {
+		d, _, err := b.GetByMac(context.Background(), mac)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Hostname != want {
+			t.Fatalf("read %d: got hostname %q, want %q", i, d.Hostname, want)
+		}
+		// the stale data is a copy, changing it does not change the next stale read.
+		d.Hostname = "changed"
+	}
+	if got := len(m.Calls()); got != 2 {
+		t.Fatalf("got %d reads of the wrapped backend, want 2", got)
+	}
+
+	b.Rand = sequence(0)
+	if d, _, _ := b.GetByMac(context.Background(), mac); d.Hostname != "old" {
+		t.Fatalf("got hostname %q, want the data of the first read kept for good", d.Hostname)
+	}
+
+	b.StaleAge = time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	b.Rand = sequence(0.75, 0)
+	for _, want := range []string{"new", "new"} {
+		if d, _, _ := b.GetByMac(context.Background(), mac); d.Hostname != want {
+			t.Fatalf("got hostname %q, want %q once the stale data is older than StaleAge", d.Hostname, want)
+		}
+	}
+}
+
+// TestResilient checks that the injected errors are retried by the resilient backend.
+func TestResilient(t *testing.T) {
+	m := mock.New(mock.WithDefault(mock.Response{DHCP: &data.DHCP{}, Netboot: &data.Netboot{}}))
+	b := NewBackend(m)
+	b.ErrorRate = 0.5
+	b.Rand = sequence(0, 0, 0.75)
+	r := resilient.NewBackend(b)
+	r.InitialBackoff = time.Millisecond
+
+	if _, _, err := r.GetByMac(context.Background(), mac); err != nil {
+		t.Fatalf("GetByMac() error = %v, want the injected errors to be retried", err)
+	}
+	if got := len(m.Calls()); got != 1 {
+		t.Fatalf("got %d reads of the wrapped backend, want 1", got)
+	}
+}
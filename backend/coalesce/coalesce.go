@@ -0,0 +1,113 @@
+// Package coalesce is a backend that coalesces concurrent identical reads of another backend.
+//
+// During a PXE storm a single machine can send many DHCP requests, each of which reads the backend.
+// Concurrent reads for the same MAC address, or IP address, share a single read of the wrapped backend.
+package coalesce
+
+import (
+	"context"
+	"net"
+
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/sync/singleflight"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+// Backend wraps a handler.BackendReader and coalesces concurrent reads for the same key.
+//
+// The shared read is not canceled when the caller that started it is canceled, so that the other
+// callers still get a result. Each caller stops waiting when its own context is done.
+// Wrap the backend with a backend that has timeouts, like the resilient backend, to bound the shared read.
+//
+// The returned *data.DHCP and *data.Netboot values are shared between callers and must not be modified.
+type Backend struct {
+	// Backend is the backend whose reads are coalesced.
+	Backend handler.BackendReader
+
+	group singleflight.Group
+}
+
+// NewBackend returns a Backend that coalesces reads of b.
+func NewBackend(b handler.BackendReader) *Backend {
+	return &Backend{Backend: b}
+}
+
+// result is the shared result of a read.
+type result struct {
+	d *data.DHCP
+	n *data.Netboot
+}
+
+// GetByMac implements the handler.BackendReader interface.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.coalesce.GetByMac")
+	defer span.End()
+
+	d, n, shared, err := b.do(ctx, "mac:"+mac.String(), func(ctx context.Context) (*data.DHCP, *data.Netboot, error) {
+		return b.Backend.GetByMac(ctx, mac)
+	})
+	span.SetAttributes(attribute.Bool("coalesce.shared", shared))
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.coalesce.GetByIP")
+	defer span.End()
+
+	d, n, shared, err := b.do(ctx, "ip:"+ip.String(), func(ctx context.Context) (*data.DHCP, *data.Netboot, error) {
+		return b.Backend.GetByIP(ctx, ip)
+	})
+	span.SetAttributes(attribute.Bool("coalesce.shared", shared))
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// do calls get once for all concurrent calls with the same key. shared reports whether the result was given to more than one caller.
+func (b *Backend) do(ctx context.Context, key string, get func(context.Context) (*data.DHCP, *data.Netboot, error)) (*data.DHCP, *data.Netboot, bool, error) {
+	// the read must outlive a canceled caller, the other callers are waiting for it.
+	rctx := context.WithoutCancel(ctx)
+	ch := b.group.DoChan(key, func() (interface{}, error) {
+		d, n, err := get(rctx)
+		return result{d: d, n: n}, err
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, nil, false, ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, nil, r.Shared, r.Err
+		}
+		res := r.Val.(result)
+
+		return res.d, res.n, r.Shared, nil
+	}
+}
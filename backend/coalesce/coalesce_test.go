@@ -0,0 +1,142 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tinkerbell/dhcp/data"
+)
+
+var errBackend = errors.New("backend error")
+
+// mockBackend blocks every read until release is closed.
+type mockBackend struct {
+	calls   atomic.Int32
+	release chan struct{}
+	err     error
+}
+
+func (m *mockBackend) read(ctx context.Context) (*data.DHCP, *data.Netboot, error) {
+	m.calls.Add(1)
+	select {
+	case <-m.release:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	if m.err != nil {
+		return nil, nil, m.err
+	}
+
+	return &data.DHCP{Hostname: "server-01"}, &data.Netboot{}, nil
+}
+
+func (m *mockBackend) GetByMac(ctx context.Context, _ net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	return m.read(ctx)
+}
+
+func (m *mockBackend) GetByIP(ctx context.Context, _ net.IP) (*data.DHCP, *data.Netboot, error) {
+	return m.read(ctx)
+}
+
+func TestCoalesce(t *testing.T) {
+	tests := map[string]struct {
+		err error
+	}{
+		"success": {},
+		"error":   {err: errBackend},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := &mockBackend{release: make(chan struct{}), err: tt.err}
+			b := NewBackend(m)
+			mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+
+			const callers = 10
+			var wg sync.WaitGroup
+			errs := make(chan error, callers)
+			for i := 0; i < callers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					d, _, err := b.GetByMac(context.Background(), mac)
+					if err == nil && d.Hostname != "server-01" {
+						err = errors.New("unexpected hostname " + d.Hostname)
+					}
+					errs <- err
+				}()
+			}
+			// wait for the first read to start, then give the other callers time to join it.
+			for m.calls.Load() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			time.Sleep(20 * time.Millisecond)
+			close(m.release)
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("GetByMac() error = %v, want %v", err, tt.err)
+				}
+			}
+			if got := m.calls.Load(); got != 1 {
+				t.Fatalf("got %d backend calls, want 1", got)
+			}
+		})
+	}
+}
+
+func TestDifferentKeys(t *testing.T) {
+	m := &mockBackend{release: make(chan struct{})}
+	close(m.release)
+	b := NewBackend(m)
+	if _, _, err := b.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.calls.Load(); got != 2 {
+		t.Fatalf("got %d backend calls, want 2", got)
+	}
+}
+
+func TestCanceledCaller(t *testing.T) {
+	m := &mockBackend{release: make(chan struct{})}
+	b := NewBackend(m)
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, _, err := b.GetByMac(ctx, mac)
+		first <- err
+	}()
+	for m.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	second := make(chan error, 1)
+	go func() {
+		_, _, err := b.GetByMac(context.Background(), mac)
+		second <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// canceling the caller that started the read must not fail the other caller.
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("first GetByMac() error = %v, want %v", err, context.Canceled)
+	}
+	close(m.release)
+	if err := <-second; err != nil {
+		t.Fatalf("second GetByMac() error = %v, want nil", err)
+	}
+	if got := m.calls.Load(); got != 1 {
+		t.Fatalf("got %d backend calls, want 1", got)
+	}
+}
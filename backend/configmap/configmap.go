@@ -0,0 +1,222 @@
+// Package configmap is a backend that serves the reservations in a Kubernetes ConfigMap. It is meant for
+// lightweight clusters that do not have the Tinkerbell CRDs installed but still want to manage reservations centrally.
+//
+// The ConfigMap holds a document in the data model of the file backend, see backend/file/testdata/example.yaml.
+// Only the one ConfigMap is watched, so the backend needs get, list, and watch permissions on ConfigMaps in its namespace only.
+package configmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/dhcp/backend/file"
+	"github.com/tinkerbell/dhcp/data"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Errors used by the ConfigMap backend.
+var (
+	errNotLoaded = fmt.Errorf("%w: configmap has not been loaded", data.ErrUnavailable)
+	errDeleted   = errors.New("configmap was deleted, serving last good data")
+	errKey       = errors.New("configmap does not have the data key")
+	errKeys      = errors.New("configmap must have exactly one data key when Key is not set")
+	errNotSynced = errors.New("configmap cache not synced")
+)
+
+// Backend serves the reservations in the ConfigMap Name in Namespace.
+//
+// The ConfigMap is watched, and its data is validated before it replaces the data being served. When the ConfigMap
+// is updated with invalid data, or deleted, the last good data continues to be served and Healthy returns an error.
+type Backend struct {
+	// Namespace is the namespace of the ConfigMap.
+	Namespace string
+
+	// Name is the name of the ConfigMap.
+	Name string
+
+	// Key is the data key of the document in the ConfigMap, for example hardware.yaml. The key is also used to detect
+	// the format of the document. When it is empty, the ConfigMap must have exactly one data key.
+	Key string
+
+	// Log is the logger to be used in the ConfigMap backend.
+	Log logr.Logger
+
+	client kubernetes.Interface
+
+	mu              sync.Mutex // protects the fields below
+	files           *file.Watcher
+	resourceVersion string
+	loadErr         error
+	synced          bool
+}
+
+// NewBackend returns a Backend for the ConfigMap name in namespace.
+//
+// Callers must start watching the ConfigMap by calling Start() before use.
+func NewBackend(l logr.Logger, conf *rest.Config, namespace, name string) (*Backend, error) {
+	c, err := kubernetes.NewForConfig(conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	return &Backend{Namespace: namespace, Name: name, Log: l, client: c}, nil
+}
+
+// Start watches the ConfigMap until ctx is done.
+// Start is a blocking method. Use a context cancellation to exit.
+func (b *Backend) Start(ctx context.Context) error {
+	f := informers.NewSharedInformerFactoryWithOptions(b.client, 0,
+		informers.WithNamespace(b.Namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.FieldSelector = fields.OneTermEqualSelector("metadata.name", b.Name).String()
+		}),
+	)
+	i := f.Core().V1().ConfigMaps().Informer()
+	if _, err := i.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    b.update,
+		UpdateFunc: func(_, obj interface{}) { b.update(obj) },
+		DeleteFunc: b.delete,
+	}); err != nil {
+		return err
+	}
+	f.Start(ctx.Done())
+	defer f.Shutdown()
+	if !cache.WaitForCacheSync(ctx.Done(), i.HasSynced) {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return errNotSynced
+	}
+	b.mu.Lock()
+	b.synced = true
+	b.mu.Unlock()
+	<-ctx.Done()
+	b.Log.Info("stopping configmap backend")
+
+	return nil
+}
+
+// update serves the data of the ConfigMap obj.
+func (b *Backend) update(obj interface{}) {
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok || cm.Namespace != b.Namespace || cm.Name != b.Name {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cm.ResourceVersion != "" && cm.ResourceVersion == b.resourceVersion {
+		return
+	}
+	if err := b.load(cm); err != nil {
+		b.Log.Error(err, "rejected configmap update, serving last good data", "namespace", cm.Namespace, "name", cm.Name)
+		b.loadErr = err
+		return
+	}
+	b.resourceVersion = cm.ResourceVersion
+	b.loadErr = nil
+	b.Log.Info("loaded configmap", "namespace", cm.Namespace, "name", cm.Name, "resourceVersion", cm.ResourceVersion)
+}
+
+// load validates the document in cm and serves it. b.mu must be held.
+func (b *Backend) load(cm *corev1.ConfigMap) error {
+	key := b.Key
+	if key == "" {
+		if len(cm.Data) != 1 {
+			keys := make([]string, 0, len(cm.Data))
+			for k := range cm.Data {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return fmt.Errorf("%w: %v", errKeys, keys)
+		}
+		for k := range cm.Data {
+			key = k
+		}
+	}
+	d, ok := cm.Data[key]
+	if !ok {
+		return fmt.Errorf("%w: %v", errKey, key)
+	}
+	if b.files == nil {
+		b.files = &file.Watcher{FilePath: b.Namespace + "/" + b.Name, Log: b.Log}
+	}
+
+	return b.files.Update(key, []byte(d))
+}
+
+// delete keeps serving the last good data when the ConfigMap is deleted, so an accidental deletion does not stop DHCP.
+func (b *Backend) delete(obj interface{}) {
+	if t, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = t.Obj
+	}
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok || cm.Namespace != b.Namespace || cm.Name != b.Name {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.Log.Info("configmap deleted, serving last good data", "namespace", cm.Namespace, "name", cm.Name)
+	b.resourceVersion = ""
+	b.loadErr = errDeleted
+}
+
+// watcher returns the file watcher that serves the last good data, or nil if the ConfigMap was not loaded.
+func (b *Backend) watcher() *file.Watcher {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.files
+}
+
+// GetByMac implements the handler.BackendReader interface. It reads the last valid document of the ConfigMap, which is
+// still served after the ConfigMap is deleted. A MAC address that is not in the document is a data.ErrNotFound error,
+// and every read before the ConfigMap is first loaded is a data.ErrUnavailable error.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	w := b.watcher()
+	if w == nil {
+		return nil, nil, errNotLoaded
+	}
+
+	return w.GetByMac(ctx, mac)
+}
+
+// GetByIP implements the handler.BackendReader interface. It reads the last valid document of the ConfigMap like
+// GetByMac.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	w := b.watcher()
+	if w == nil {
+		return nil, nil, errNotLoaded
+	}
+
+	return w.GetByIP(ctx, ip)
+}
+
+// Healthy implements the handler.HealthChecker interface.
+// It returns an error until the ConfigMap is loaded, and when the last update of the ConfigMap was invalid or it was deleted.
+func (b *Backend) Healthy(ctx context.Context) error {
+	b.mu.Lock()
+	synced, err, w := b.synced, b.loadErr, b.files
+	b.mu.Unlock()
+	if !synced {
+		return errNotSynced
+	}
+	if err != nil {
+		return err
+	}
+	if w == nil {
+		return errNotLoaded
+	}
+
+	return w.Healthy(ctx)
+}
@@ -0,0 +1,156 @@
+package configmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+var mac = net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+func document(ip string) string {
+	return fmt.Sprintf("00:00:00:00:00:01:\n  ipAddress: %v\n  subnetMask: 255.255.255.0\n", ip)
+}
+
+func configMap(rv string, d map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tink-system", Name: "dhcp", ResourceVersion: rv},
+		Data:       d,
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := map[string]struct {
+		key     string
+		cm      *corev1.ConfigMap
+		wantErr error
+		wantIP  string
+	}{
+		"single key": {
+			cm:     configMap("1", map[string]string{"hardware.yaml": document("192.168.2.1")}),
+			wantIP: "192.168.2.1",
+		},
+		"key": {
+			key:    "site-b.yaml",
+			cm:     configMap("1", map[string]string{"site-a.yaml": document("192.168.2.1"), "site-b.yaml": document("192.168.2.2")}),
+			wantIP: "192.168.2.2",
+		},
+		"missing key": {
+			key:     "site-c.yaml",
+			cm:      configMap("1", map[string]string{"site-a.yaml": document("192.168.2.1")}),
+			wantErr: errKey,
+		},
+		"more than one key": {
+			cm:      configMap("1", map[string]string{"site-a.yaml": document("192.168.2.1"), "site-b.yaml": document("192.168.2.2")}),
+			wantErr: errKeys,
+		},
+		"other configmap": {
+			cm:      &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "tink-system", Name: "other"}, Data: map[string]string{"hardware.yaml": document("192.168.2.1")}},
+			wantErr: errNotLoaded,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &Backend{Namespace: "tink-system", Name: "dhcp", Key: tt.key, Log: logr.Discard(), synced: true}
+			b.update(tt.cm)
+			if err := b.Healthy(context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Healthy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			d, _, err := b.GetByMac(context.Background(), mac)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.wantIP, d.IPAddress.String()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestInvalidUpdateAndDelete(t *testing.T) {
+	ctx := context.Background()
+	b := &Backend{Namespace: "tink-system", Name: "dhcp", Log: logr.Discard(), synced: true}
+	b.update(configMap("1", map[string]string{"hardware.yaml": document("192.168.2.1")}))
+	if err := b.Healthy(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// an invalid update is rejected and the last good data is served.
+	b.update(configMap("2", map[string]string{"hardware.yaml": "not a mac: {}\n"}))
+	if err := b.Healthy(ctx); err == nil {
+		t.Fatal("Healthy() error = nil, want an error after an invalid update")
+	}
+	if _, _, err := b.GetByIP(ctx, net.ParseIP("192.168.2.1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a deleted configmap keeps the last good data.
+	b.delete(cache.DeletedFinalStateUnknown{Obj: configMap("2", nil)})
+	if err := b.Healthy(ctx); !errors.Is(err, errDeleted) {
+		t.Fatalf("Healthy() error = %v, wantErr %v", err, errDeleted)
+	}
+	if _, _, err := b.GetByIP(ctx, net.ParseIP("192.168.2.1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// and is served again when it is recreated.
+	b.update(configMap("3", map[string]string{"hardware.yaml": document("192.168.2.3")}))
+	if err := b.Healthy(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.GetByIP(ctx, net.ParseIP("192.168.2.3")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := fake.NewSimpleClientset(configMap("", map[string]string{"hardware.yaml": document("192.168.2.1")}))
+	b := &Backend{Namespace: "tink-system", Name: "dhcp", Log: logr.Discard(), client: c}
+	if err := b.Healthy(ctx); !errors.Is(err, errNotSynced) {
+		t.Fatalf("Healthy() error = %v, wantErr %v", err, errNotSynced)
+	}
+
+	done := make(chan error)
+	go func() { done <- b.Start(ctx) }()
+	eventually(t, func() error { return b.Healthy(ctx) })
+
+	if _, err := c.CoreV1().ConfigMaps("tink-system").Update(ctx, configMap("", map[string]string{"hardware.yaml": document("192.168.2.2")}), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() error {
+		_, _, err := b.GetByIP(ctx, net.ParseIP("192.168.2.2"))
+		return err
+	})
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// eventually fails t if fn does not return a nil error within a few seconds.
+func eventually(t *testing.T, fn func() error) {
+	t.Helper()
+	var err error
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if err = fn(); err == nil {
+			return
+		}
+	}
+	t.Fatal(err)
+}
@@ -0,0 +1,487 @@
+// Package csv watches a CSV file of reservations for changes and serves the DHCP data in it.
+//
+// The first row of the file is a header that names the columns, so columns can be in any order.
+// Columns with other names are ignored, which allows inventory exports with extra columns to be used as is.
+// Empty lines and lines starting with "#" are skipped. The mac and ip columns are required, the others are optional:
+//
+//	mac            MAC address, e.g. 08:00:27:29:4e:67
+//	ip             IP address, CIDR notation is allowed, e.g. 192.168.2.150/24
+//	mask           subnet mask, optional when ip is in CIDR notation
+//	gateway        default gateway
+//	hostname       hostname
+//	domain_name    domain name
+//	name_servers   name servers, separated by ";" or spaces
+//	ntp_servers    NTP servers, separated by ";" or spaces
+//	domain_search  domain search list, separated by ";" or spaces
+//	broadcast      broadcast address, derived from ip and the subnet mask when empty
+//	lease_time     lease time in seconds, data.DefaultLeaseTime when empty
+//	vlan_id        VLAN ID
+//	arch           architecture
+//	allow_pxe      true/false or yes/no, allows the client to netboot
+//	ipxe_url       iPXE script URL
+//	ipxe_script    iPXE script
+//	console        console
+//	facility       facility
+//	ipxe_binary    iPXE binary, overrides the binary chosen based on the client architecture
+//
+// For example:
+//
+//	mac,ip,mask,gateway,hostname,allow_pxe,ipxe_url
+//	08:00:27:29:4e:67,192.168.2.150,255.255.255.0,192.168.2.1,node-01,true,http://boot.example.com/auto.ipxe
+package csv
+
+import (
+	"bytes"
+	"context"
+	stdcsv "encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+// Errors used by the CSV watcher.
+var (
+	errEmptyFile     = errors.New("file is empty")
+	errMissingColumn = errors.New("missing required column")
+	errDuplicateMAC  = errors.New("duplicate MAC address")
+	errDuplicateIP   = errors.New("duplicate IP address")
+	errParseIP       = errors.New("failed to parse IP address")
+	errParseSubnet   = errors.New("failed to parse subnet mask")
+	errParseBool     = errors.New("failed to parse boolean")
+)
+
+// utf8BOM is the byte order mark that spreadsheet programs often write at the start of a CSV export.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// cellError is returned when a cell of the file is not valid. It names the line and column of the cell.
+type cellError struct {
+	line   int
+	column string
+	err    error
+}
+
+func (c *cellError) Error() string {
+	return fmt.Sprintf("line %d, column %q: %v", c.line, c.column, c.err)
+}
+
+func (c *cellError) Unwrap() error {
+	return c.err
+}
+
+// record is the data of one row.
+type record struct {
+	dhcp    data.DHCP
+	netboot data.Netboot
+}
+
+// table is the parsed contents of a file.
+type table struct {
+	byMAC map[string]record
+	byIP  map[netip.Addr]record
+}
+
+// Watcher watches a CSV file for changes and serves the reservations in it.
+//
+// When the file changes, the new contents are parsed and validated before they replace the in memory data.
+// Invalid contents are rejected and the last good data continues to be served.
+type Watcher struct {
+	// FilePath is the path to the CSV file.
+	FilePath string
+
+	// Log is the logger to be used in the CSV backend.
+	Log logr.Logger
+
+	dataMu   sync.RWMutex // protects data and loadErr
+	data     table
+	loadErr  error         // error from the last update of data, nil if it succeeded
+	rejected atomic.Uint64 // number of file updates that failed validation
+	watcher  *fsnotify.Watcher
+}
+
+// NewWatcher reads the CSV file f and returns a Watcher for it. An error is returned if the file is not valid.
+// Call Start to reload the file when it changes.
+func NewWatcher(l logr.Logger, f string) (*Watcher, error) {
+	t, err := load(f)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// The file is watched through its parent directory. Editors, `mv` based deploys,
+	// and Kubernetes ConfigMap mounts replace a file instead of writing to it, after which
+	// a watch on the file itself no longer receives events.
+	if err := watcher.Add(filepath.Dir(f)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	return &Watcher{FilePath: f, Log: l, data: t, watcher: watcher}, nil
+}
+
+// GetByMac implements the handler.BackendReader interface. It returns a copy of the row for mac from the last valid
+// version of the file; a change that does not parse is not loaded. A MAC address without a row is a data.ErrNotFound
+// error.
+func (w *Watcher) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	_, span := tracer.Start(ctx, "backend.csv.GetByMac")
+	defer span.End()
+
+	w.dataMu.RLock()
+	r, ok := w.data.byMAC[mac.String()]
+	w.dataMu.RUnlock()
+	if !ok {
+		err := data.HardwareNotFoundError{}
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	d, n := r.dhcp, r.netboot
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return &d, &n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface. It returns a copy of the row whose ip column is ip, read
+// like GetByMac.
+func (w *Watcher) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	_, span := tracer.Start(ctx, "backend.csv.GetByIP")
+	defer span.End()
+
+	addr, ok := netip.AddrFromSlice(ip)
+	var r record
+	if ok {
+		w.dataMu.RLock()
+		r, ok = w.data.byIP[addr.Unmap()]
+		w.dataMu.RUnlock()
+	}
+	if !ok {
+		err := data.HardwareNotFoundError{}
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	d, n := r.dhcp, r.netboot
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return &d, &n, nil
+}
+
+// Start watches the file for changes and reloads it on changes.
+// Start is a blocking method. Use a context cancellation to exit.
+func (w *Watcher) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			w.Log.Info("stopping watcher")
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				continue
+			}
+			w.handleEvent(event)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				continue
+			}
+			w.Log.Info("error watching file", "err", err)
+		}
+	}
+}
+
+// handleEvent reloads the file when an event in its parent directory affects it.
+// Events for the file itself and for a Kubernetes ConfigMap "..data" symlink swap cause a reload.
+func (w *Watcher) handleEvent(event fsnotify.Event) {
+	if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename) == 0 {
+		return
+	}
+	name := filepath.Clean(w.FilePath)
+	isSymlinkSwap := filepath.Base(event.Name) == "..data" && filepath.Dir(filepath.Clean(event.Name)) == filepath.Dir(name)
+	if filepath.Clean(event.Name) != name && !isSymlinkSwap {
+		return
+	}
+
+	w.Log.Info("file changed, updating cache", "file", name)
+	t, err := load(name)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			w.rejected.Add(1)
+		}
+		w.Log.Error(err, "rejected file update, serving last good data", "file", name)
+		w.dataMu.Lock()
+		w.loadErr = err
+		w.dataMu.Unlock()
+		return
+	}
+	w.dataMu.Lock()
+	w.data = t
+	w.loadErr = nil
+	w.dataMu.Unlock()
+}
+
+// Healthy implements the handler.HealthChecker interface.
+// It returns an error if the last update of the file could not be read or was rejected,
+// even though the last good data is still served.
+func (w *Watcher) Healthy(context.Context) error {
+	w.dataMu.RLock()
+	defer w.dataMu.RUnlock()
+	if w.loadErr != nil {
+		return fmt.Errorf("last file update failed, serving last good data: %w", w.loadErr)
+	}
+
+	return nil
+}
+
+// Rejected returns the number of file updates that were rejected because the new contents were invalid.
+func (w *Watcher) Rejected() uint64 {
+	return w.rejected.Load()
+}
+
+// load reads and parses the file at name.
+func load(name string) (table, error) {
+	b, err := os.ReadFile(filepath.Clean(name))
+	if err != nil {
+		return table{}, err
+	}
+
+	return parse(b)
+}
+
+// parse parses the contents of a CSV file. Every row must be valid and no two rows may have the same MAC or IP address.
+func parse(b []byte) (table, error) {
+	b = bytes.TrimPrefix(b, utf8BOM)
+	if len(bytes.TrimSpace(b)) == 0 {
+		return table{}, errEmptyFile
+	}
+	r := stdcsv.NewReader(bytes.NewReader(b))
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err != nil {
+		return table{}, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, c := range []string{"mac", "ip"} {
+		if _, ok := columns[c]; !ok {
+			return table{}, fmt.Errorf("%w: %q", errMissingColumn, c)
+		}
+	}
+
+	t := table{byMAC: make(map[string]record), byIP: make(map[netip.Addr]record)}
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return table{}, err
+		}
+		line, _ := r.FieldPos(0)
+		cell := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+		rec, err := translate(cell)
+		var ce *cellError
+		if errors.As(err, &ce) {
+			ce.line = line
+		} else if err != nil {
+			err = fmt.Errorf("line %d: %w", line, err)
+		}
+		if err != nil {
+			return table{}, err
+		}
+		mac := rec.dhcp.MACAddress.String()
+		if _, ok := t.byMAC[mac]; ok {
+			return table{}, &cellError{line: line, column: "mac", err: fmt.Errorf("%w: %v", errDuplicateMAC, mac)}
+		}
+		if _, ok := t.byIP[rec.dhcp.IPAddress]; ok {
+			return table{}, &cellError{line: line, column: "ip", err: fmt.Errorf("%w: %v", errDuplicateIP, rec.dhcp.IPAddress)}
+		}
+		t.byMAC[mac] = rec
+		t.byIP[rec.dhcp.IPAddress] = rec
+	}
+
+	return t, nil
+}
+
+// translate converts the cells of a row into a record. cell returns the value of the named column.
+func translate(cell func(string) string) (record, error) {
+	mac, err := net.ParseMAC(cell("mac"))
+	if err != nil {
+		return record{}, &cellError{column: "mac", err: err}
+	}
+
+	// ip address, required. CIDR notation is allowed.
+	var prefix netip.Prefix
+	var ip netip.Addr
+	if s := cell("ip"); strings.Contains(s, "/") {
+		if prefix, err = netip.ParsePrefix(s); err != nil {
+			return record{}, &cellError{column: "ip", err: fmt.Errorf("%w: %w", err, errParseIP)}
+		}
+		ip = prefix.Addr().Unmap()
+	} else if ip, err = netip.ParseAddr(s); err != nil {
+		return record{}, &cellError{column: "ip", err: fmt.Errorf("%w: %w", err, errParseIP)}
+	}
+
+	// subnet mask, required unless the ip address is in CIDR notation.
+	var mask net.IPMask
+	switch s := cell("mask"); {
+	case s != "":
+		sm := net.ParseIP(s).To4()
+		if sm == nil {
+			return record{}, &cellError{column: "mask", err: errParseSubnet}
+		}
+		mask = net.IPMask(sm)
+	case prefix.IsValid() && ip.Is4():
+		mask = net.CIDRMask(prefix.Bits(), 32)
+	default:
+		return record{}, &cellError{column: "mask", err: errParseSubnet}
+	}
+
+	var opts []data.DHCPOption
+	gw, err := parseAddr(cell, "gateway")
+	if err != nil {
+		return record{}, err
+	}
+	if gw.IsValid() {
+		opts = append(opts, data.WithDefaultGateways(gw))
+	}
+	// broadcast address, derived from the ip address and subnet mask when empty.
+	bcast, err := parseAddr(cell, "broadcast")
+	if err != nil {
+		return record{}, err
+	}
+	if bcast.IsValid() {
+		opts = append(opts, data.WithBroadcastAddress(bcast))
+	}
+	ns, err := parseIPs(cell, "name_servers")
+	if err != nil {
+		return record{}, err
+	}
+	ntp, err := parseIPs(cell, "ntp_servers")
+	if err != nil {
+		return record{}, err
+	}
+	opts = append(opts, data.WithNameServers(ns...), data.WithNTPServers(ntp...))
+	if s := cell("lease_time"); s != "" {
+		lt, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return record{}, &cellError{column: "lease_time", err: err}
+		}
+		opts = append(opts, data.WithLeaseTime(uint32(lt)))
+	}
+	opts = append(opts,
+		data.WithHostname(cell("hostname")),
+		data.WithDomainName(cell("domain_name")),
+		data.WithDomainSearch(list(cell("domain_search"))...),
+		data.WithVLANID(cell("vlan_id")),
+		data.WithArch(cell("arch")),
+	)
+
+	var allow bool
+	switch s := strings.ToLower(cell("allow_pxe")); s {
+	case "", "false", "no", "0":
+	case "true", "yes", "1":
+		allow = true
+	default:
+		return record{}, &cellError{column: "allow_pxe", err: fmt.Errorf("%w: %q", errParseBool, s)}
+	}
+	nopts := []data.NetbootOption{
+		data.WithIPXEScript(cell("ipxe_script")),
+		data.WithConsole(cell("console")),
+		data.WithFacility(cell("facility")),
+		data.WithIPXEBinary(cell("ipxe_binary")),
+	}
+	if s := cell("ipxe_url"); s != "" {
+		u, err := url.ParseRequestURI(s)
+		if err != nil {
+			return record{}, &cellError{column: "ipxe_url", err: err}
+		}
+		nopts = append(nopts, data.WithIPXEScriptURL(u))
+	}
+
+	d, err := data.NewDHCP(mac, ip, mask, opts...)
+	if err != nil {
+		return record{}, err
+	}
+	n, err := data.NewNetboot(allow, nopts...)
+	if err != nil {
+		return record{}, &cellError{column: "ipxe_url", err: err}
+	}
+
+	return record{dhcp: *d, netboot: *n}, nil
+}
+
+// parseAddr parses the optional IP address in the named column.
+func parseAddr(cell func(string) string, column string) (netip.Addr, error) {
+	s := cell(column)
+	if s == "" {
+		return netip.Addr{}, nil
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, &cellError{column: column, err: fmt.Errorf("%w: %w", err, errParseIP)}
+	}
+
+	return a, nil
+}
+
+// parseIPs parses the list of IP addresses in the named column.
+func parseIPs(cell func(string) string, column string) ([]net.IP, error) {
+	var r []net.IP
+	for _, s := range list(cell(column)) {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, &cellError{column: column, err: fmt.Errorf("%w: %q", errParseIP, s)}
+		}
+		r = append(r, ip)
+	}
+
+	return r, nil
+}
+
+// list splits a cell with multiple values separated by ";" or spaces.
+func list(s string) []string {
+	f := strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ' ' || r == '\t' })
+	if len(f) == 0 {
+		return nil
+	}
+
+	return f
+}
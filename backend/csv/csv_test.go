@@ -0,0 +1,216 @@
+package csv
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+const example = "\xef\xbb\xbfMAC, IP, mask, gateway, hostname, allow_pxe, ipxe_url, name_servers, lease_time, rack, ipxe_binary\n" +
+	"# a comment\n" +
+	"08:00:27:29:4e:67,192.168.2.150,255.255.255.0,192.168.2.1,node-01,TRUE,http://boot.example.com/auto.ipxe,1.1.1.1;8.8.8.8,86400,r1,snp-debug.efi\n" +
+	"\n" +
+	"08:00:27:29:4e:68,192.168.2.151/24,,192.168.2.1,node-02,no,,,,r1,\n"
+
+var mac1 = net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67}
+
+func TestParse(t *testing.T) {
+	got, err := parse([]byte(example))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]record{
+		"08:00:27:29:4e:67": {
+			dhcp: data.DHCP{
+				MACAddress:       mac1,
+				IPAddress:        netip.MustParseAddr("192.168.2.150"),
+				SubnetMask:       net.IPv4Mask(255, 255, 255, 0),
+				DefaultGateways:  []netip.Addr{netip.MustParseAddr("192.168.2.1")},
+				NameServers:      []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("8.8.8.8")},
+				Hostname:         "node-01",
+				BroadcastAddress: netip.MustParseAddr("192.168.2.255"),
+				LeaseTime:        86400,
+			},
+			netboot: data.Netboot{
+				AllowNetboot:  true,
+				IPXEScriptURL: &url.URL{Scheme: "http", Host: "boot.example.com", Path: "/auto.ipxe"},
+				IPXEBinary:    "snp-debug.efi",
+			},
+		},
+		"08:00:27:29:4e:68": {
+			dhcp: data.DHCP{
+				MACAddress:       net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x68},
+				IPAddress:        netip.MustParseAddr("192.168.2.151"),
+				SubnetMask:       net.IPv4Mask(255, 255, 255, 0),
+				DefaultGateways:  []netip.Addr{netip.MustParseAddr("192.168.2.1")},
+				BroadcastAddress: netip.MustParseAddr("192.168.2.255"),
+				Hostname:         "node-02",
+				LeaseTime:        data.DefaultLeaseTime,
+			},
+		},
+	}
+	if diff := cmp.Diff(got.byMAC, want, cmp.AllowUnexported(record{}), cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+		t.Fatal(diff)
+	}
+	for mac, r := range got.byMAC {
+		if r.dhcp.BroadcastAddress != netip.MustParseAddr("192.168.2.255") {
+			t.Fatalf("got broadcast address %v for %v, want 192.168.2.255", r.dhcp.BroadcastAddress, mac)
+		}
+	}
+	if _, ok := got.byIP[netip.MustParseAddr("192.168.2.151")]; !ok {
+		t.Fatal("record not indexed by IP address")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]struct {
+		contents string
+		wantErr  error
+	}{
+		"empty":           {contents: "\n", wantErr: errEmptyFile},
+		"no ip column":    {contents: "mac,mask\n08:00:27:29:4e:67,255.255.255.0\n", wantErr: errMissingColumn},
+		"bad ip":          {contents: "mac,ip,mask\n08:00:27:29:4e:67,192.168.2,255.255.255.0\n", wantErr: errParseIP},
+		"no mask":         {contents: "mac,ip\n08:00:27:29:4e:67,192.168.2.150\n", wantErr: errParseSubnet},
+		"bad allow_pxe":   {contents: "mac,ip,allow_pxe\n08:00:27:29:4e:67,192.168.2.150/24,maybe\n", wantErr: errParseBool},
+		"bad gateway":     {contents: "mac,ip,gateway\n08:00:27:29:4e:67,192.168.2.150/24,gw\n", wantErr: errParseIP},
+		"bad name server": {contents: "mac,ip,name_servers\n08:00:27:29:4e:67,192.168.2.150/24,1.1.1.1;dns\n", wantErr: errParseIP},
+		"IPv6 ip":         {contents: "mac,ip,mask\n08:00:27:29:4e:67,2001:db8::1,255.255.255.0\n", wantErr: data.ErrInvalidRecord},
+		"duplicate mac": {
+			contents: "mac,ip\n08:00:27:29:4e:67,192.168.2.150/24\n08:00:27:29:4E:67,192.168.2.151/24\n",
+			wantErr:  errDuplicateMAC,
+		},
+		"duplicate ip": {
+			contents: "mac,ip\n08:00:27:29:4e:67,192.168.2.150/24\n08:00:27:29:4e:68,192.168.2.150/24\n",
+			wantErr:  errDuplicateIP,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parse([]byte(tt.contents)); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseErrorLine(t *testing.T) {
+	_, err := parse([]byte("mac,ip\n# comment\n08:00:27:29:4e:67,192.168.2.150/24\nbad,192.168.2.151/24\n"))
+	var ce *cellError
+	if !errors.As(err, &ce) {
+		t.Fatalf("parse() error = %v, want a cell error", err)
+	}
+	if ce.line != 4 || ce.column != "mac" {
+		t.Fatalf("got line %d, column %q, want line 4, column \"mac\"", ce.line, ce.column)
+	}
+}
+
+func newWatcher(t *testing.T, contents string) (*Watcher, string) {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "hardware.csv")
+	if err := os.WriteFile(name, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWatcher(logr.Discard(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w, name
+}
+
+func TestGet(t *testing.T) {
+	w, _ := newWatcher(t, example)
+	d, n, err := w.GetByMac(context.Background(), mac1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Hostname != "node-01" || !n.AllowNetboot {
+		t.Fatalf("GetByMac() = %v, %v", d, n)
+	}
+	d, _, err = w.GetByIP(context.Background(), net.IPv4(192, 168, 2, 151))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Hostname != "node-02" {
+		t.Fatalf("GetByIP() hostname = %q, want node-02", d.Hostname)
+	}
+	if _, _, err := w.GetByMac(context.Background(), net.HardwareAddr{0, 0, 0, 0, 0, 1}); !errors.Is(err, data.HardwareNotFoundError{}) {
+		t.Fatalf("GetByMac() error = %v, want %v", err, data.HardwareNotFoundError{})
+	}
+	if _, _, err := w.GetByIP(context.Background(), net.IPv4(192, 168, 2, 1)); !errors.Is(err, data.HardwareNotFoundError{}) {
+		t.Fatalf("GetByIP() error = %v, want %v", err, data.HardwareNotFoundError{})
+	}
+}
+
+func TestNewWatcherInvalidFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "hardware.csv")
+	if err := os.WriteFile(name, []byte("mac\n08:00:27:29:4e:67\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWatcher(logr.Discard(), name); !errors.Is(err, errMissingColumn) {
+		t.Fatalf("NewWatcher() error = %v, wantErr %v", err, errMissingColumn)
+	}
+}
+
+func TestHotReload(t *testing.T) {
+	w, name := newWatcher(t, "mac,ip\n08:00:27:29:4e:67,192.168.2.150/24\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Start(ctx)
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %v", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	hostname := func() string {
+		d, _, err := w.GetByMac(context.Background(), mac1)
+		if err != nil {
+			return ""
+		}
+		return d.Hostname
+	}
+
+	// replace the file, as an export job or an editor would.
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, []byte("mac,ip,hostname\n08:00:27:29:4e:67,192.168.2.150/24,updated\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the update", func() bool { return hostname() == "updated" })
+
+	// an invalid update is rejected and the last good data is served.
+	if err := os.WriteFile(name, []byte("mac,ip\nnot-a-mac,192.168.2.150/24\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the rejection", func() bool { return w.Rejected() > 0 })
+	if got := hostname(); got != "updated" {
+		t.Fatalf("got hostname %q, want the last good data", got)
+	}
+	if err := w.Healthy(ctx); err == nil {
+		t.Fatal("Healthy() expected error after a rejected update")
+	}
+
+	if err := os.WriteFile(name, []byte("mac,ip,hostname\n08:00:27:29:4e:67,192.168.2.150/24,fixed\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the fix", func() bool { return hostname() == "fixed" && w.Healthy(ctx) == nil })
+}
@@ -0,0 +1,549 @@
+// Package dynamodb is a backend implementation that reads DHCP reservations from an Amazon DynamoDB table.
+//
+// The table has one item per reservation with the MAC address as the partition key. GetByIP uses a
+// global secondary index on the IP address. The DynamoDB JSON API is used directly and requests are signed with
+// AWS Signature Version 4, so no AWS SDK is needed.
+//
+// Item attributes, all optional except mac, ipAddress, and subnetMask:
+//
+//	mac              S     partition key, lower case, colon separated, e.g. "00:01:02:03:04:05"
+//	ipAddress        S     key of the IP address index
+//	subnetMask       S
+//	defaultGateway   S
+//	nameServers      SS/L  list of IP addresses
+//	ntpServers       SS/L  list of IP addresses
+//	broadcastAddress S
+//	hostname         S
+//	domainName       S
+//	domainSearch     SS/L
+//	vlanID           S
+//	leaseTime        N     seconds
+//	arch             S
+//	allowNetboot     BOOL
+//	ipxeScriptUrl    S
+//	ipxeScript       S
+//	console          S
+//	facility         S
+//	ipxeBinary       S     overrides the iPXE binary chosen based on the client architecture
+package dynamodb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/internal/sigv4"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+// Default values used by NewBackend.
+const (
+	DefaultIPIndex        = "ipAddress-index"
+	DefaultMaxRetries     = 3
+	DefaultInitialBackoff = 50 * time.Millisecond
+	DefaultMaxBackoff     = 5 * time.Second
+)
+
+// Errors used by the DynamoDB backend.
+var (
+	errMultipleRecords = fmt.Errorf("%w: more than one item found", data.ErrInvalidRecord)
+	errDAX             = errors.New("dynamodb: DynamoDB Accelerator (DAX) endpoints are not supported, use the DynamoDB endpoint of the table")
+)
+
+// apiError is an error response from the DynamoDB API.
+type apiError struct {
+	status  int
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	t := e.Type
+	if _, after, ok := strings.Cut(t, "#"); ok {
+		t = after
+	}
+
+	return fmt.Sprintf("dynamodb: %d %s: %s", e.status, t, e.Message)
+}
+
+// throttled reports whether the request was rejected because of the request rate.
+func (e *apiError) throttled() bool {
+	for _, t := range []string{"ThrottlingException", "ProvisionedThroughputExceededException", "RequestLimitExceeded"} {
+		if strings.HasSuffix(e.Type, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Is classifies e: throttling and server errors match data.ErrUnavailable, and
+// rejected credentials match data.ErrUnauthorized.
+func (e *apiError) Is(target error) bool {
+	switch target {
+	case data.ErrUnavailable:
+		return e.throttled() || e.status >= http.StatusInternalServerError
+	case data.ErrUnauthorized:
+		if e.status == http.StatusForbidden {
+			return true
+		}
+		for _, t := range []string{"UnrecognizedClientException", "AccessDeniedException", "InvalidSignatureException", "MissingAuthenticationTokenException", "ExpiredTokenException"} {
+			if strings.HasSuffix(e.Type, t) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Backend is a backend implementation that reads DHCP reservations from a DynamoDB table.
+//
+// Requests that are throttled or fail with a server error are retried up to MaxRetries times with an
+// exponential backoff with jitter. Retries are adaptive: throttling raises a delay that is shared by all requests,
+// and every successful request lowers it again, so the backend slows down as a whole while the table is throttled.
+type Backend struct {
+	// Endpoint is the DynamoDB endpoint. It defaults to the regional endpoint, https://dynamodb.<region>.amazonaws.com.
+	// It can be set to a VPC endpoint or to DynamoDB Local for testing.
+	// DynamoDB Accelerator (DAX) uses its own protocol and is not supported, NewBackend rejects a DAX cluster endpoint.
+	Endpoint *url.URL
+
+	// Region is the AWS region of the table.
+	Region string
+
+	// Table is the name of the table.
+	Table string
+
+	// IPIndex is the name of the global secondary index on ipAddress, used by GetByIP.
+	IPIndex string
+
+	// Credentials sign the requests.
+	Credentials Credentials
+
+	// Client is the HTTP client used to talk to the DynamoDB API.
+	Client *http.Client
+
+	// MaxRetries is the number of times a throttled or failed request is retried.
+	MaxRetries int
+
+	// InitialBackoff is the wait before the first retry. It doubles for each following retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between retries and the shared throttling delay.
+	MaxBackoff time.Duration
+
+	// Log is the logger to be used in the DynamoDB backend.
+	Log logr.Logger
+
+	mu       sync.Mutex // protects throttle
+	throttle time.Duration
+}
+
+// NewBackend returns a DynamoDB backend for table in region, at endpoint or, when it is nil, at the regional endpoint.
+// It returns an error when endpoint is the endpoint of a DAX cluster.
+func NewBackend(l logr.Logger, endpoint *url.URL, region, table string, c Credentials) (*Backend, error) {
+	if endpoint == nil {
+		endpoint = &url.URL{Scheme: "https", Host: fmt.Sprintf("dynamodb.%s.amazonaws.com", region)}
+	}
+	if isDAX(endpoint) {
+		return nil, errDAX
+	}
+
+	return &Backend{
+		Endpoint:       endpoint,
+		Region:         region,
+		Table:          table,
+		IPIndex:        DefaultIPIndex,
+		Credentials:    c,
+		Client:         http.DefaultClient,
+		MaxRetries:     DefaultMaxRetries,
+		InitialBackoff: DefaultInitialBackoff,
+		MaxBackoff:     DefaultMaxBackoff,
+		Log:            l,
+	}, nil
+}
+
+// Credentials are AWS credentials used to sign requests.
+type Credentials = sigv4.Credentials
+
+// CredentialsFromEnv returns the credentials in the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables.
+func CredentialsFromEnv() (Credentials, error) {
+	return sigv4.CredentialsFromEnv()
+}
+
+// attributeValue is a DynamoDB attribute value. Only the types used by reservations are decoded.
+type attributeValue struct {
+	S    *string          `json:"S,omitempty"`
+	N    *string          `json:"N,omitempty"`
+	BOOL *bool            `json:"BOOL,omitempty"`
+	SS   []string         `json:"SS,omitempty"`
+	L    []attributeValue `json:"L,omitempty"`
+}
+
+// stringValue returns a string attribute value.
+func stringValue(s string) attributeValue {
+	return attributeValue{S: &s}
+}
+
+// item is a DynamoDB item.
+type item map[string]attributeValue
+
+// GetByMac implements the handler.BackendReader interface. It gets the item whose partition key mac is the MAC address
+// in its lower case, colon separated form. Nothing is cached, every read is a GetItem request. No item is a
+// data.ErrNotFound error.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.dynamodb.GetByMac")
+	defer span.End()
+
+	req := map[string]any{
+		"TableName": b.Table,
+		"Key":       item{"mac": stringValue(mac.String())},
+	}
+	var resp struct {
+		Item item `json:"Item"`
+	}
+	if err := b.call(ctx, "GetItem", req, &resp); err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, fmt.Errorf("failed getting item for (%v): %w", mac, err)
+	}
+	if resp.Item == nil {
+		err := data.HardwareNotFoundError{}
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	d, n, err := translate(resp.Item)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", data.ErrInvalidRecord, err)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface. It queries the global secondary index IPIndex on the
+// ipAddress attribute, so an item that was just written may not be found yet. No item is a data.ErrNotFound error and
+// more than one is a data.ErrInvalidRecord error.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.dynamodb.GetByIP")
+	defer span.End()
+
+	req := map[string]any{
+		"TableName":                 b.Table,
+		"IndexName":                 b.IPIndex,
+		"KeyConditionExpression":    "#ip = :ip",
+		"ExpressionAttributeNames":  map[string]string{"#ip": "ipAddress"},
+		"ExpressionAttributeValues": item{":ip": stringValue(ip.String())},
+	}
+	var resp struct {
+		Items []item `json:"Items"`
+	}
+	if err := b.call(ctx, "Query", req, &resp); err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, fmt.Errorf("failed querying items for (%v): %w", ip, err)
+	}
+	if len(resp.Items) == 0 {
+		err := data.HardwareNotFoundError{}
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	if len(resp.Items) > 1 {
+		err := fmt.Errorf("%w: got %d items for ip %s", errMultipleRecords, len(resp.Items), ip)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	d, n, err := translate(resp.Items[0])
+	if err != nil {
+		err = fmt.Errorf("%w: %w", data.ErrInvalidRecord, err)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// call calls the DynamoDB operation op with the JSON encoding of in and decodes the response into out.
+// Throttled requests and server errors are retried.
+func (b *Backend) call(ctx context.Context, op string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	backoff := b.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		if err := sleep(ctx, b.throttleDelay()); err != nil {
+			return err
+		}
+		err := b.do(ctx, op, body, out)
+		var ae *apiError
+		switch {
+		case err == nil:
+			b.adjustThrottle(false)
+			return nil
+		case errors.As(err, &ae) && ae.throttled():
+			b.adjustThrottle(true)
+		case errors.As(err, &ae) && ae.status >= http.StatusInternalServerError:
+		default:
+			return err
+		}
+		if attempt >= b.MaxRetries {
+			return err
+		}
+		b.Log.V(1).Info("retrying DynamoDB request", "operation", op, "attempt", attempt+1, "err", err)
+		// full jitter, https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+		if backoff > 0 {
+			if err := sleep(ctx, time.Duration(rand.Int63n(int64(backoff)))); err != nil {
+				return err
+			}
+		}
+		backoff *= 2
+		if b.MaxBackoff > 0 && backoff > b.MaxBackoff {
+			backoff = b.MaxBackoff
+		}
+	}
+}
+
+// do makes a single signed request for the DynamoDB operation op.
+func (b *Backend) do(ctx context.Context, op string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.Endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "DynamoDB_20120810."+op)
+	sigv4.Sign(req, body, b.Credentials, b.Region, "dynamodb", time.Now())
+
+	c := b.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %w", data.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		ae := &apiError{status: resp.StatusCode}
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		_ = json.Unmarshal(b, ae)
+
+		return ae
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// isDAX reports whether u is the endpoint of a DAX cluster, like dax://my-cluster.abc123.dax-clusters.us-east-1.amazonaws.com.
+func isDAX(u *url.URL) bool {
+	return u.Scheme == "dax" || u.Scheme == "daxs" || strings.Contains(u.Hostname(), ".dax-clusters.")
+}
+
+// throttleDelay returns the delay shared by all requests while the table is throttled.
+func (b *Backend) throttleDelay() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.throttle
+}
+
+// adjustThrottle doubles the shared delay after a throttled request and halves it after a successful one.
+func (b *Backend) adjustThrottle(throttled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !throttled {
+		b.throttle /= 2
+		if b.throttle < time.Millisecond {
+			b.throttle = 0
+		}
+		return
+	}
+	if b.throttle == 0 {
+		b.throttle = b.InitialBackoff
+	} else {
+		b.throttle *= 2
+	}
+	if b.MaxBackoff > 0 && b.throttle > b.MaxBackoff {
+		b.throttle = b.MaxBackoff
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// translate converts a DynamoDB item into data.DHCP and data.Netboot structs.
+func translate(i item) (*data.DHCP, *data.Netboot, error) {
+	d := new(data.DHCP)
+	n := new(data.Netboot)
+
+	var err error
+	// mac address, required
+	if d.MACAddress, err = net.ParseMAC(i.str("mac")); err != nil {
+		return nil, nil, fmt.Errorf("mac: %w", err)
+	}
+
+	// ip address, required
+	if d.IPAddress, err = netip.ParseAddr(i.str("ipAddress")); err != nil {
+		return nil, nil, fmt.Errorf("ipAddress: %w", err)
+	}
+
+	// subnet mask, required
+	sm := net.ParseIP(i.str("subnetMask"))
+	if sm == nil || sm.To4() == nil {
+		return nil, nil, fmt.Errorf("subnetMask: invalid subnet mask %q", i.str("subnetMask"))
+	}
+	d.SubnetMask = net.IPMask(sm.To4())
+
+	// default gateway, optional
+	if s := i.str("defaultGateway"); s != "" {
+		gw, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("defaultGateway: %w", err)
+		}
+		d.SetDefaultGateway(gw)
+	}
+
+	// broadcast address, optional
+	if s := i.str("broadcastAddress"); s != "" {
+		if d.BroadcastAddress, err = netip.ParseAddr(s); err != nil {
+			return nil, nil, fmt.Errorf("broadcastAddress: %w", err)
+		}
+	}
+
+	// name servers and ntp servers, optional
+	for _, s := range i.strs("nameServers") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("nameServers: invalid IP address %q", s)
+		}
+		d.NameServers = append(d.NameServers, ip)
+	}
+	for _, s := range i.strs("ntpServers") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("ntpServers: invalid IP address %q", s)
+		}
+		d.NTPServers = append(d.NTPServers, ip)
+	}
+
+	// lease time, optional
+	if s := i.num("leaseTime"); s != "" {
+		lt, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, nil, fmt.Errorf("leaseTime: %w", err)
+		}
+		d.LeaseTime = uint32(lt)
+	}
+
+	d.Hostname = i.str("hostname")
+	d.DomainName = i.str("domainName")
+	d.DomainSearch = i.strs("domainSearch")
+	d.VLANID = i.str("vlanID")
+	d.Arch = i.str("arch")
+
+	// netboot
+	if v, ok := i["allowNetboot"]; ok && v.BOOL != nil {
+		n.AllowNetboot = *v.BOOL
+	}
+	if s := i.str("ipxeScriptUrl"); s != "" {
+		if n.IPXEScriptURL, err = url.ParseRequestURI(s); err != nil {
+			return nil, nil, fmt.Errorf("ipxeScriptUrl: %w", err)
+		}
+	}
+	n.IPXEScript = i.str("ipxeScript")
+	n.Console = i.str("console")
+	n.Facility = i.str("facility")
+	n.IPXEBinary = i.str("ipxeBinary")
+
+	return d, n, nil
+}
+
+// str returns the string attribute name, or an empty string.
+func (i item) str(name string) string {
+	if v, ok := i[name]; ok && v.S != nil {
+		return *v.S
+	}
+
+	return ""
+}
+
+// num returns the number attribute name, or an empty string.
+func (i item) num(name string) string {
+	if v, ok := i[name]; ok && v.N != nil {
+		return *v.N
+	}
+
+	return ""
+}
+
+// strs returns the string set, or list of strings, attribute name.
+func (i item) strs(name string) []string {
+	v, ok := i[name]
+	if !ok {
+		return nil
+	}
+	if len(v.SS) > 0 {
+		return v.SS
+	}
+	var r []string
+	for _, e := range v.L {
+		if e.S != nil {
+			r = append(r, *e.S)
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,268 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+const reservation = `{
+	"mac": {"S": "00:01:02:03:04:05"},
+	"ipAddress": {"S": "192.168.2.150"},
+	"subnetMask": {"S": "255.255.255.0"},
+	"defaultGateway": {"S": "192.168.2.1"},
+	"nameServers": {"SS": ["1.1.1.1", "8.8.8.8"]},
+	"ntpServers": {"L": [{"S": "132.163.96.2"}]},
+	"hostname": {"S": "pxe-virtualbox"},
+	"domainName": {"S": "example.com"},
+	"domainSearch": {"L": [{"S": "example.com"}]},
+	"broadcastAddress": {"S": "192.168.2.255"},
+	"leaseTime": {"N": "86400"},
+	"arch": {"S": "x86_64"},
+	"allowNetboot": {"BOOL": true},
+	"ipxeScriptUrl": {"S": "http://boot.netboot.xyz"},
+	"facility": {"S": "onprem"}
+}`
+
+var (
+	wantDHCP = &data.DHCP{
+		MACAddress:       net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+		IPAddress:        netip.MustParseAddr("192.168.2.150"),
+		SubnetMask:       net.IPv4Mask(255, 255, 255, 0),
+		DefaultGateways:  []netip.Addr{netip.MustParseAddr("192.168.2.1")},
+		NameServers:      []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("8.8.8.8")},
+		NTPServers:       []net.IP{net.ParseIP("132.163.96.2")},
+		Hostname:         "pxe-virtualbox",
+		DomainName:       "example.com",
+		DomainSearch:     []string{"example.com"},
+		BroadcastAddress: netip.MustParseAddr("192.168.2.255"),
+		LeaseTime:        86400,
+		Arch:             "x86_64",
+	}
+	wantNetboot = &data.Netboot{
+		AllowNetboot:  true,
+		IPXEScriptURL: &url.URL{Scheme: "http", Host: "boot.netboot.xyz"},
+		Facility:      "onprem",
+	}
+)
+
+// response is a response of the fake DynamoDB API.
+type response struct {
+	status int
+	body   string
+}
+
+// newBackend returns a Backend talking to a fake DynamoDB API that answers with responses in order,
+// repeating the last one. The operation of each request is recorded in ops.
+func newBackend(t *testing.T, ops *[]string, responses ...response) *Backend {
+	t.Helper()
+	var mu sync.Mutex
+	n := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if ops != nil {
+			*ops = append(*ops, r.Header.Get("X-Amz-Target"))
+		}
+		i := n
+		n++
+		if i >= len(responses) {
+			i = len(responses) - 1
+		}
+		w.WriteHeader(responses[i].status)
+		fmt.Fprint(w, responses[i].body)
+	}))
+	t.Cleanup(s.Close)
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewBackend(logr.Discard(), u, "us-east-1", "reservations", Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.InitialBackoff = time.Millisecond
+	b.MaxBackoff = 2 * time.Millisecond
+
+	return b
+}
+
+func TestGetByMac(t *testing.T) {
+	tests := map[string]struct {
+		responses  []response
+		wantErr    error
+		wantAPIErr bool
+		wantOps    int
+	}{
+		"success":   {responses: []response{{http.StatusOK, `{"Item": ` + reservation + `}`}}, wantOps: 1},
+		"not found": {responses: []response{{http.StatusOK, `{}`}}, wantErr: data.HardwareNotFoundError{}, wantOps: 1},
+		"throttled then success": {
+			responses: []response{
+				{http.StatusBadRequest, `{"__type": "com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException", "message": "slow down"}`},
+				{http.StatusInternalServerError, `{}`},
+				{http.StatusOK, `{"Item": ` + reservation + `}`},
+			},
+			wantOps: 3,
+		},
+		"retries exhausted": {
+			responses:  []response{{http.StatusServiceUnavailable, `{}`}},
+			wantAPIErr: true,
+			wantOps:    DefaultMaxRetries + 1,
+		},
+		"not retried": {
+			responses:  []response{{http.StatusBadRequest, `{"__type": "com.amazonaws.dynamodb.v20120810#ResourceNotFoundException"}`}},
+			wantAPIErr: true,
+			wantOps:    1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var ops []string
+			b := newBackend(t, &ops, tt.responses...)
+			d, n, err := b.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05})
+			var ae *apiError
+			if tt.wantAPIErr {
+				if !errors.As(err, &ae) {
+					t.Fatalf("GetByMac() error = %v, want an API error", err)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(ops) != tt.wantOps {
+				t.Fatalf("got %d requests, want %d", len(ops), tt.wantOps)
+			}
+			if ops[0] != "DynamoDB_20120810.GetItem" {
+				t.Fatalf("got operation %q, want GetItem", ops[0])
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(d, wantDHCP, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+			if d.IPAddress != wantDHCP.IPAddress || d.DefaultGateway() != wantDHCP.DefaultGateway() || d.BroadcastAddress != wantDHCP.BroadcastAddress {
+				t.Errorf("got addresses %v, %v, %v", d.IPAddress, d.DefaultGateway(), d.BroadcastAddress)
+			}
+			if diff := cmp.Diff(n, wantNetboot); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestGetByIP(t *testing.T) {
+	tests := map[string]struct {
+		body    string
+		wantErr error
+	}{
+		"success":        {body: `{"Items": [` + reservation + `]}`},
+		"not found":      {body: `{"Items": []}`, wantErr: data.HardwareNotFoundError{}},
+		"multiple items": {body: `{"Items": [` + reservation + `,` + reservation + `]}`, wantErr: errMultipleRecords},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var ops []string
+			b := newBackend(t, &ops, response{http.StatusOK, tt.body})
+			d, _, err := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 150))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ops[0] != "DynamoDB_20120810.Query" {
+				t.Fatalf("got operation %q, want Query", ops[0])
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if diff := cmp.Diff(d, wantDHCP, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestNewBackend(t *testing.T) {
+	tests := map[string]struct {
+		endpoint string
+		want     string
+		wantErr  error
+	}{
+		"regional endpoint": {want: "https://dynamodb.us-east-1.amazonaws.com"},
+		"dynamodb local":    {endpoint: "http://localhost:8000", want: "http://localhost:8000"},
+		"dax scheme":        {endpoint: "dax://my-cluster.abc123.dax-clusters.us-east-1.amazonaws.com", wantErr: errDAX},
+		"daxs scheme":       {endpoint: "daxs://my-cluster.abc123.dax-clusters.us-east-1.amazonaws.com", wantErr: errDAX},
+		"dax host":          {endpoint: "https://my-cluster.abc123.dax-clusters.us-east-1.amazonaws.com:9111", wantErr: errDAX},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var u *url.URL
+			if tt.endpoint != "" {
+				var err error
+				if u, err = url.Parse(tt.endpoint); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b, err := NewBackend(logr.Discard(), u, "us-east-1", "reservations", Credentials{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewBackend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := b.Endpoint.String(); got != tt.want {
+				t.Fatalf("got endpoint %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdjustThrottle(t *testing.T) {
+	b := &Backend{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 30 * time.Millisecond}
+	for _, want := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond} {
+		b.adjustThrottle(true)
+		if got := b.throttleDelay(); got != want {
+			t.Fatalf("throttleDelay() = %v, want %v", got, want)
+		}
+	}
+	for _, want := range []time.Duration{15 * time.Millisecond, 7500 * time.Microsecond} {
+		b.adjustThrottle(false)
+		if got := b.throttleDelay(); got != want {
+			t.Fatalf("throttleDelay() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	tests := map[string]string{
+		"bad mac":         `{"mac": {"S": "bad"}}`,
+		"bad ip":          `{"mac": {"S": "00:01:02:03:04:05"}, "ipAddress": {"S": "bad"}}`,
+		"no subnet mask":  `{"mac": {"S": "00:01:02:03:04:05"}, "ipAddress": {"S": "192.168.2.150"}}`,
+		"bad name server": `{"mac": {"S": "00:01:02:03:04:05"}, "ipAddress": {"S": "192.168.2.150"}, "subnetMask": {"S": "255.255.255.0"}, "nameServers": {"SS": ["bad"]}}`,
+		"bad lease time":  `{"mac": {"S": "00:01:02:03:04:05"}, "ipAddress": {"S": "192.168.2.150"}, "subnetMask": {"S": "255.255.255.0"}, "leaseTime": {"N": "-1"}}`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := newBackend(t, nil, response{http.StatusOK, `{"Item": ` + body + `}`})
+			if _, _, err := b.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}); err == nil {
+				t.Fatal("GetByMac() expected error")
+			}
+		})
+	}
+}
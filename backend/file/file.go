@@ -1,35 +1,78 @@
 // Package file watches a file for changes and updates the in memory DHCP data.
+// YAML and JSON files are supported.
 package file
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/netip"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/ghodss/yaml"
 	"github.com/go-logr/logr"
 	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/redact"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
 )
 
 const tracerName = "github.com/tinkerbell/dhcp"
 
+// defaultRecord is the key of the record that is used when no record matches a MAC address.
+// This allows any machine to be booted, for example into a discovery environment, with a single entry.
+const defaultRecord = "default"
+
 // Errors used by the file watcher.
 var (
 	// errFileFormat is returned when the file is not in the correct format, e.g. not valid YAML.
 	errFileFormat     = fmt.Errorf("invalid file format")
-	errRecordNotFound = fmt.Errorf("record not found")
+	errRecordNotFound = fmt.Errorf("record %w", data.ErrNotFound)
 	errParseIP        = fmt.Errorf("failed to parse IP from File")
 	errParseSubnet    = fmt.Errorf("failed to parse subnet mask from File")
 	errParseURL       = fmt.Errorf("failed to parse URL")
+	errEmptyFile      = fmt.Errorf("file is empty")
+	errInvalidMTU     = fmt.Errorf("MTU must be between 68 and 65535")
+	errParseRoute     = fmt.Errorf("failed to parse static route")
+	errParseOption    = fmt.Errorf("failed to parse option")
+)
+
+// fieldError is returned when a field of a record is not valid. It names the offending field.
+type fieldError struct {
+	field string
+	err   error
+}
+
+func (f *fieldError) Error() string {
+	return fmt.Sprintf("invalid field %q: %v", f.field, f.err)
+}
+
+func (f *fieldError) Unwrap() error {
+	return f.err
+}
+
+// Format is the format of the data in a watched file.
+type Format string
+
+// Supported file formats.
+const (
+	// FormatAuto detects the format from the file extension and, if the extension is not known, the file contents.
+	FormatAuto Format = ""
+	// FormatYAML is YAML.
+	FormatYAML Format = "yaml"
+	// FormatJSON is JSON.
+	FormatJSON Format = "json"
 )
 
 // netboot is the structure for the data expected in a file.
@@ -39,47 +82,113 @@ type netboot struct {
 	IPXEScript    string `yaml:"ipxeScript"`    // Overrides a default value that is passed into DHCP on startup.
 	Console       string `yaml:"console"`
 	Facility      string `yaml:"facility"`
+	KernelParams  string `yaml:"kernelParams"` // Extra kernel command line parameters.
+	IPXEBinary    string `yaml:"ipxeBinary"`   // Overrides the iPXE binary that is chosen based on the client architecture.
+	HTTPBootURI   string `yaml:"httpBootUri"`  // Booted directly by UEFI HTTP Boot clients instead of iPXE.
+	OSIE          osie   `yaml:"osie"`
+}
+
+// osie is the structure for the Operating System Installation Environment expected in a file.
+type osie struct {
+	BaseURL string `yaml:"baseUrl"` // The URL that kernel and initrd are relative to.
+	Kernel  string `yaml:"kernel"`  // The kernel file name.
+	Initrd  string `yaml:"initrd"`  // The initrd file name.
+}
+
+// route is the structure for a classless static route expected in a file.
+type route struct {
+	Destination string `yaml:"destination"` // The destination network in CIDR notation.
+	Router      string `yaml:"router"`      // The router for the destination network.
 }
 
 // dhcp is the structure for the data expected in a file.
 type dhcp struct {
-	MACAddress       net.HardwareAddr // The MAC address of the client.
-	IPAddress        string           `yaml:"ipAddress"`        // yiaddr DHCP header.
-	SubnetMask       string           `yaml:"subnetMask"`       // DHCP option 1.
-	DefaultGateway   string           `yaml:"defaultGateway"`   // DHCP option 3.
-	NameServers      []string         `yaml:"nameServers"`      // DHCP option 6.
-	Hostname         string           `yaml:"hostname"`         // DHCP option 12.
-	DomainName       string           `yaml:"domainName"`       // DHCP option 15.
-	BroadcastAddress string           `yaml:"broadcastAddress"` // DHCP option 28.
-	NTPServers       []string         `yaml:"ntpServers"`       // DHCP option 42.
-	VLANID           string           `yaml:"vlanID"`           // DHCP option 43.116.
-	LeaseTime        int              `yaml:"leaseTime"`        // DHCP option 51.
-	Arch             string           `yaml:"arch"`             // DHCP option 93.
-	DomainSearch     []string         `yaml:"domainSearch"`     // DHCP option 119.
-	Netboot          netboot          `yaml:"netboot"`
+	MACAddress         net.HardwareAddr            // The MAC address of the client.
+	MACAddresses       []string                    `yaml:"macAddresses"`       // Additional MAC addresses, e.g. bonded NICs, that use this record.
+	IPAddress          string                      `yaml:"ipAddress"`          // yiaddr DHCP header. CIDR notation is allowed.
+	SubnetMask         string                      `yaml:"subnetMask"`         // DHCP option 1. Optional when ipAddress is in CIDR notation.
+	TimeOffset         int32                       `yaml:"timeOffset"`         // DHCP option 2, seconds east of UTC.
+	DefaultGateway     string                      `yaml:"defaultGateway"`     // DHCP option 3.
+	DefaultGateways    []string                    `yaml:"defaultGateways"`    // DHCP option 3. Additional default gateways, after defaultGateway.
+	NameServers        []string                    `yaml:"nameServers"`        // DHCP option 6.
+	Hostname           string                      `yaml:"hostname"`           // DHCP option 12.
+	DomainName         string                      `yaml:"domainName"`         // DHCP option 15.
+	MTU                int                         `yaml:"mtu"`                // DHCP option 26.
+	BroadcastAddress   string                      `yaml:"broadcastAddress"`   // DHCP option 28.
+	NTPServers         []string                    `yaml:"ntpServers"`         // DHCP option 42.
+	VLANID             string                      `yaml:"vlanID"`             // DHCP option 43.116.
+	VendorOptions      map[uint8]string            `yaml:"vendorOptions"`      // DHCP option 43 sub-options by code, hex encoded like otherOptions.
+	LeaseTime          int                         `yaml:"leaseTime"`          // DHCP option 51.
+	ClientIdentifier   string                      `yaml:"clientIdentifier"`   // DHCP option 61, hex encoded like otherOptions.
+	TFTPServerName     string                      `yaml:"tftpServerName"`     // DHCP option 66.
+	BootFileName       string                      `yaml:"bootFileName"`       // DHCP option 67.
+	Arch               string                      `yaml:"arch"`               // DHCP option 93.
+	ClientGUID         string                      `yaml:"clientGuid"`         // DHCP option 97, hex encoded. Bytes can be separated by colons or dashes, like a UUID.
+	PosixTimezone      string                      `yaml:"posixTimezone"`      // DHCP option 100, a POSIX TZ string.
+	Timezone           string                      `yaml:"timezone"`           // DHCP option 101, a tz database name.
+	CaptivePortalURL   string                      `yaml:"captivePortalUrl"`   // DHCP option 114.
+	DomainSearch       []string                    `yaml:"domainSearch"`       // DHCP option 119.
+	SIPServers         []string                    `yaml:"sipServers"`         // DHCP option 120. Either all IPv4 addresses or all domain names.
+	StaticRoutes       []route                     `yaml:"staticRoutes"`       // DHCP option 121.
+	VIVendorOptions    map[uint32]map[uint8]string `yaml:"viVendorOptions"`    // DHCP option 125 sub-options by enterprise number and code, hex encoded like otherOptions.
+	VLANTag            uint16                      `yaml:"vlanTag"`            // DHCP option 132, the 802.1Q VLAN ID.
+	VLANPriority       uint8                       `yaml:"vlanPriority"`       // DHCP option 133, the 802.1p priority.
+	ProxyAutoConfigURL string                      `yaml:"proxyAutoConfigUrl"` // DHCP option 252.
+	OtherOptions       map[uint8]string            `yaml:"otherOptions"`       // DHCP options by code, hex encoded. Bytes can be separated by colons.
+	Netboot            netboot                     `yaml:"netboot"`
 }
 
 // Watcher represents the backend for watching a file for changes and updating the in memory DHCP data.
+// The path being watched can also be a directory, in which case every YAML and JSON file
+// in the directory is loaded and the records from all files are merged.
+//
+// When a watched file changes, the new contents are validated before they replace the in memory data.
+// Invalid contents are rejected and the last good data continues to be served.
 type Watcher struct {
 	fileMu sync.RWMutex // protects FilePath for reads
 
-	// FilePath is the path to the file to watch.
+	// FilePath is the path to the file, or directory of files, to watch.
 	FilePath string
 
+	// Format is the format of the file. Defaults to FormatAuto.
+	// When watching a directory, the format applies to every file in the directory.
+	Format Format
+
+	// ExpandEnv enables ${VAR} and ${VAR:-default} expansion of environment variables in the file contents.
+	// References to variables that are not set and have no default are left as is,
+	// so iPXE variables like ${net0/mac} in an ipxeScript are not changed.
+	ExpandEnv bool
+
 	// Log is the logger to be used in the File backend.
-	Log     logr.Logger
-	dataMu  sync.RWMutex // protects data
-	data    []byte       // data from file
-	watcher *fsnotify.Watcher
+	Log      logr.Logger
+	dataMu   sync.RWMutex      // protects data and loadErr
+	data     map[string][]byte // data from file(s), keyed by file path
+	loadErr  error             // error from the last update of data, nil if it succeeded
+	dir      bool              // FilePath is a directory
+	rejected atomic.Uint64     // number of file updates that failed validation
+	watcher  *fsnotify.Watcher
 }
 
 // NewWatcher creates a new file watcher.
+// f can be a single file or a directory of YAML and JSON files.
 func NewWatcher(l logr.Logger, f string) (*Watcher, error) {
+	fi, err := os.Stat(f)
+	if err != nil {
+		return nil, err
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
-	if err := watcher.Add(f); err != nil {
+	// A single file is watched through its parent directory. Editors, `mv` based deploys,
+	// and Kubernetes ConfigMap mounts replace a file instead of writing to it, after which
+	// a watch on the file itself no longer receives events.
+	watchPath := filepath.Dir(f)
+	if fi.IsDir() {
+		watchPath = f
+	}
+	if err := watcher.Add(watchPath); err != nil {
 		return nil, err
 	}
 
@@ -87,11 +196,14 @@ func NewWatcher(l logr.Logger, f string) (*Watcher, error) {
 		FilePath: f,
 		watcher:  watcher,
 		Log:      l,
+		dir:      fi.IsDir(),
 	}
 
-	w.fileMu.RLock()
-	w.data, err = os.ReadFile(filepath.Clean(f))
-	w.fileMu.RUnlock()
+	if w.dir {
+		w.data, err = w.readDir()
+	} else {
+		w.data, err = w.readFile()
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -100,87 +212,111 @@ func NewWatcher(l logr.Logger, f string) (*Watcher, error) {
 }
 
 // GetByMac is the implementation of the Backend interface.
-// It reads a given file from the in memory data (w.data).
+// It reads the in memory data (w.data) of the watched file(s).
 func (w *Watcher) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
 	tracer := otel.Tracer(tracerName)
 	_, span := tracer.Start(ctx, "backend.file.GetByMac")
 	defer span.End()
 
 	// get data from file, translate it, then pass it into setDHCPOpts and setNetworkBootOpts
-	w.dataMu.RLock()
-	d := w.data
-	w.dataMu.RUnlock()
-	r := make(map[string]dhcp)
-	if err := yaml.Unmarshal(d, &r); err != nil {
-		err := fmt.Errorf("%w: %w", err, errFileFormat)
+	r, err := w.records()
+	if err != nil {
 		w.Log.Error(err, "failed to unmarshal file data")
-		span.SetStatus(codes.Error, err.Error())
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	v, found := findByMac(r, mac)
+	if !found {
+		err = fmt.Errorf("%w: %s", errRecordNotFound, mac.String())
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	// found a record for this mac address
+	v.MACAddress = mac
+	d, n, err := w.translate(v)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", data.ErrInvalidRecord, err)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 		return nil, nil, err
 	}
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// findByMac returns the record for mac. A record keyed by mac takes precedence over a record that lists mac in its macAddresses.
+// If there is no record for mac, the default record is returned, if it exists.
+func findByMac(r map[string]dhcp, mac net.HardwareAddr) (dhcp, bool) {
 	for k, v := range r {
 		if strings.EqualFold(k, mac.String()) {
-			// found a record for this mac address
-			v.MACAddress = mac
-			d, n, err := w.translate(v)
-			if err != nil {
-				span.SetStatus(codes.Error, err.Error())
-
-				return nil, nil, err
+			return v, true
+		}
+	}
+	keys := make([]string, 0, len(r))
+	for k := range r {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, m := range r[k].MACAddresses {
+			if hw, err := net.ParseMAC(m); err == nil && bytes.Equal(hw, mac) {
+				return r[k], true
 			}
-			span.SetAttributes(d.EncodeToAttributes()...)
-			span.SetAttributes(n.EncodeToAttributes()...)
-			span.SetStatus(codes.Ok, "")
-
-			return d, n, nil
+		}
+	}
+	for k, v := range r {
+		if strings.EqualFold(k, defaultRecord) {
+			return v, true
 		}
 	}
 
-	err := fmt.Errorf("%w: %s", errRecordNotFound, mac.String())
-	span.SetStatus(codes.Error, err.Error())
-
-	return nil, nil, err
+	return dhcp{}, false
 }
 
 // GetByIP is the implementation of the Backend interface.
-// It reads a given file from the in memory data (w.data).
+// It reads the in memory data (w.data) of the watched file(s).
 func (w *Watcher) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
 	tracer := otel.Tracer(tracerName)
 	_, span := tracer.Start(ctx, "backend.file.GetByIP")
 	defer span.End()
 
 	// get data from file, translate it, then pass it into setDHCPOpts and setNetworkBootOpts
-	w.dataMu.RLock()
-	d := w.data
-	w.dataMu.RUnlock()
-	r := make(map[string]dhcp)
-	if err := yaml.Unmarshal(d, &r); err != nil {
-		err := fmt.Errorf("%w: %w", err, errFileFormat)
+	r, err := w.records()
+	if err != nil {
 		w.Log.Error(err, "failed to unmarshal file data")
-		span.SetStatus(codes.Error, err.Error())
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 		return nil, nil, err
 	}
 	for k, v := range r {
-		if v.IPAddress == ip.String() {
+		if strings.EqualFold(k, defaultRecord) {
+			// the default record is only used for lookups by mac address.
+			continue
+		}
+		if a, _, _ := strings.Cut(v.IPAddress, "/"); a == ip.String() {
 			// found a record for this ip address
-			v.IPAddress = ip.String()
 			mac, err := net.ParseMAC(k)
 			if err != nil {
-				err := fmt.Errorf("%w: %w", err, errFileFormat)
+				err := fmt.Errorf("%w: %w: %w", data.ErrInvalidRecord, err, errFileFormat)
 				w.Log.Error(err, "failed to parse mac address")
-				span.SetStatus(codes.Error, err.Error())
+				span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 				return nil, nil, err
 			}
 			v.MACAddress = mac
 			d, n, err := w.translate(v)
 			if err != nil {
-				span.SetStatus(codes.Error, err.Error())
+				err = fmt.Errorf("%w: %w", data.ErrInvalidRecord, err)
+				span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 				return nil, nil, err
 			}
-			span.SetAttributes(d.EncodeToAttributes()...)
+			span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
 			span.SetAttributes(n.EncodeToAttributes()...)
 			span.SetStatus(codes.Ok, "")
 
@@ -188,8 +324,8 @@ func (w *Watcher) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Net
 		}
 	}
 
-	err := fmt.Errorf("%w: %s", errRecordNotFound, ip.String())
-	span.SetStatus(codes.Error, err.Error())
+	err = fmt.Errorf("%w: %s", errRecordNotFound, ip.String())
+	span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 	return nil, nil, err
 }
@@ -206,19 +342,11 @@ func (w *Watcher) Start(ctx context.Context) {
 			if !ok {
 				continue
 			}
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				w.Log.Info("file changed, updating cache")
-				w.fileMu.RLock()
-				d, err := os.ReadFile(w.FilePath)
-				w.fileMu.RUnlock()
-				if err != nil {
-					w.Log.Error(err, "failed to read file", "file", w.FilePath)
-					break
-				}
-				w.dataMu.Lock()
-				w.data = d
-				w.dataMu.Unlock()
+			if w.dir {
+				w.handleDirEvent(event)
+				break
 			}
+			w.handleFileEvent(event)
 		case err, ok := <-w.watcher.Errors:
 			if !ok {
 				continue
@@ -228,31 +356,392 @@ func (w *Watcher) Start(ctx context.Context) {
 	}
 }
 
+// handleFileEvent reloads the single watched file when an event in its parent directory affects it.
+// Events for the file itself and for a Kubernetes ConfigMap "..data" symlink swap cause a reload.
+// When the file is removed or renamed away the last good data continues to be served
+// until the file is created again.
+func (w *Watcher) handleFileEvent(event fsnotify.Event) {
+	if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) == 0 {
+		return
+	}
+	w.fileMu.RLock()
+	name := w.FilePath
+	w.fileMu.RUnlock()
+	if filepath.Clean(event.Name) != filepath.Clean(name) && !isSymlinkSwap(event.Name, name) {
+		return
+	}
+
+	w.Log.Info("file changed, updating cache")
+	d, err := w.readFile()
+	if err != nil {
+		w.Log.Error(err, "failed to read file", "file", name)
+		w.setLoadErr(err)
+		return
+	}
+	if bad, err := w.validateAll(d); err != nil {
+		w.reject(err, bad)
+		return
+	}
+	w.dataMu.Lock()
+	w.data = d
+	w.loadErr = nil
+	w.dataMu.Unlock()
+}
+
+// handleDirEvent updates the in memory data for a single file event in a watched directory.
+// Files are added on create and write events and removed on remove and rename events.
+// A file that is renamed into the directory generates a create event.
+// A Kubernetes ConfigMap "..data" symlink swap reloads the whole directory.
+func (w *Watcher) handleDirEvent(event fsnotify.Event) {
+	w.fileMu.RLock()
+	dir := w.FilePath
+	w.fileMu.RUnlock()
+	if isSymlinkSwap(event.Name, filepath.Join(dir, "..data")) {
+		if event.Op&fsnotify.Create == 0 {
+			return
+		}
+		w.Log.Info("directory changed, updating cache", "dir", dir)
+		d, err := w.readDir()
+		if err != nil {
+			w.Log.Error(err, "failed to read directory", "dir", dir)
+			w.setLoadErr(err)
+			return
+		}
+		if name, err := w.validateAll(d); err != nil {
+			w.reject(err, name)
+			return
+		}
+		w.dataMu.Lock()
+		w.data = d
+		w.loadErr = nil
+		w.dataMu.Unlock()
+		return
+	}
+	if !supportedFile(event.Name) {
+		return
+	}
+	switch {
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		w.Log.Info("file removed, updating cache", "file", event.Name)
+		w.dataMu.Lock()
+		delete(w.data, event.Name)
+		w.dataMu.Unlock()
+	case event.Op&(fsnotify.Create|fsnotify.Write) != 0:
+		w.Log.Info("file changed, updating cache", "file", event.Name)
+		d, err := os.ReadFile(filepath.Clean(event.Name))
+		if err != nil {
+			w.Log.Error(err, "failed to read file", "file", event.Name)
+			w.setLoadErr(err)
+			return
+		}
+		if err := w.validate(event.Name, d); err != nil {
+			w.reject(err, event.Name)
+			return
+		}
+		w.dataMu.Lock()
+		if w.data == nil {
+			w.data = make(map[string][]byte)
+		}
+		w.data[event.Name] = d
+		w.loadErr = nil
+		w.dataMu.Unlock()
+	}
+}
+
+// validate checks that the data from the file at name is not empty, can be parsed, and that every record
+// is keyed by a valid MAC address or is the default record and only lists valid MAC addresses in macAddresses. Empty data is rejected as it is most likely a file that is in the middle of being written.
+// Errors translating individual records are not checked here, they are returned when the record is read.
+func (w *Watcher) validate(name string, b []byte) error {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return errEmptyFile
+	}
+	r, err := w.parse(name, b)
+	if err != nil {
+		return err
+	}
+	for k, v := range r {
+		for i, m := range v.MACAddresses {
+			if _, err := net.ParseMAC(m); err != nil {
+				return &fieldError{field: fmt.Sprintf("%v.macAddresses[%d]", k, i), err: fmt.Errorf("%w: %w", err, errFileFormat)}
+			}
+		}
+		if strings.EqualFold(k, defaultRecord) {
+			continue
+		}
+		if _, err := net.ParseMAC(k); err != nil {
+			return fmt.Errorf("%w: %w", err, errFileFormat)
+		}
+	}
+
+	return nil
+}
+
+// validateAll validates the data of every file in d, returning the name of the first invalid file.
+func (w *Watcher) validateAll(d map[string][]byte) (string, error) {
+	for name, b := range d {
+		if err := w.validate(name, b); err != nil {
+			return name, err
+		}
+	}
+
+	return "", nil
+}
+
+// reject logs and counts an update that failed validation.
+// The in memory data is not changed, so the last good data continues to be served.
+func (w *Watcher) reject(err error, name string) {
+	w.rejected.Add(1)
+	w.Log.Error(err, "rejected invalid file update, serving last good data", "file", name)
+	w.setLoadErr(fmt.Errorf("%v: %w", name, err))
+}
+
+// setLoadErr records err as the result of the last update of the in memory data.
+func (w *Watcher) setLoadErr(err error) {
+	w.dataMu.Lock()
+	w.loadErr = err
+	w.dataMu.Unlock()
+}
+
+// Healthy implements the handler.HealthChecker interface.
+// It returns an error if the last update of the file(s) could not be read or was rejected, even though
+// the last good data is still served, or if the data being served can not be parsed.
+func (w *Watcher) Healthy(context.Context) error {
+	w.dataMu.RLock()
+	err := w.loadErr
+	w.dataMu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("last file update failed, serving last good data: %w", err)
+	}
+	if _, err := w.records(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Update validates b, the new contents of the file named name, and replaces the in memory data with it.
+// It allows data that is not read from a local file, for example data fetched over HTTP, to be served.
+// The name is used to detect the format of b when Format is FormatAuto.
+// Invalid data is rejected and the last good data continues to be served.
+//
+// A Watcher that is only updated with Update can be created as a struct literal. Its Start method must not be called.
+func (w *Watcher) Update(name string, b []byte) error {
+	if err := w.validate(name, b); err != nil {
+		w.reject(err, name)
+		return err
+	}
+	w.dataMu.Lock()
+	w.data = map[string][]byte{name: b}
+	w.loadErr = nil
+	w.dataMu.Unlock()
+
+	return nil
+}
+
+// Rejected returns the number of file updates that were rejected because the new contents were invalid.
+func (w *Watcher) Rejected() uint64 {
+	return w.rejected.Load()
+}
+
+// isSymlinkSwap returns true if name is the "..data" symlink in the same directory as file.
+// Kubernetes ConfigMap and Secret volumes update all files at once by atomically
+// replacing the "..data" symlink that the files in the volume point through.
+func isSymlinkSwap(name, file string) bool {
+	return filepath.Base(name) == "..data" && filepath.Dir(filepath.Clean(name)) == filepath.Dir(filepath.Clean(file))
+}
+
+// readFile reads the single watched file.
+func (w *Watcher) readFile() (map[string][]byte, error) {
+	w.fileMu.RLock()
+	defer w.fileMu.RUnlock()
+	d, err := os.ReadFile(filepath.Clean(w.FilePath))
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{w.FilePath: d}, nil
+}
+
+// readDir reads every YAML and JSON file in the watched directory.
+// Subdirectories are not read.
+func (w *Watcher) readDir() (map[string][]byte, error) {
+	w.fileMu.RLock()
+	defer w.fileMu.RUnlock()
+	entries, err := os.ReadDir(w.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	r := make(map[string][]byte)
+	for _, e := range entries {
+		name := filepath.Join(w.FilePath, e.Name())
+		if e.IsDir() || !supportedFile(name) {
+			continue
+		}
+		d, err := os.ReadFile(filepath.Clean(name))
+		if err != nil {
+			return nil, err
+		}
+		r[name] = d
+	}
+
+	return r, nil
+}
+
+// records parses and merges the in memory data of all watched files.
+// Files are merged in lexical order of their path. When the same MAC address
+// is in more than one file, the record from the last file is used.
+func (w *Watcher) records() (map[string]dhcp, error) {
+	w.dataMu.RLock()
+	defer w.dataMu.RUnlock()
+	names := make([]string, 0, len(w.data))
+	for name := range w.data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r := make(map[string]dhcp)
+	for _, name := range names {
+		recs, err := w.parse(name, w.data[name])
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+		for k, v := range recs {
+			if _, ok := r[k]; ok {
+				w.Log.V(1).Info("duplicate record found, using the last one", "mac", k, "file", name)
+			}
+			r[k] = v
+		}
+	}
+
+	return r, nil
+}
+
+// parse unmarshals the data from the file at name into records keyed by MAC address.
+// YAML and JSON share the same data model and translate path.
+func (w *Watcher) parse(name string, b []byte) (map[string]dhcp, error) {
+	r := make(map[string]dhcp)
+	var err error
+	if w.ExpandEnv {
+		b = expandEnv(b)
+	}
+	switch detectFormat(w.Format, name, b) {
+	case FormatJSON:
+		err = json.Unmarshal(b, &r)
+	default:
+		err = yaml.Unmarshal(b, &r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", err, errFileFormat)
+	}
+
+	return r, nil
+}
+
+// envVar matches ${VAR} and ${VAR:-default}.
+var envVar = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces ${VAR} and ${VAR:-default} in b with the value of the environment variable VAR.
+// The default is used when VAR is not set or is empty. References without a default to variables that are not set are not replaced.
+func expandEnv(b []byte) []byte {
+	return envVar.ReplaceAllFunc(b, func(m []byte) []byte {
+		sub := envVar.FindSubmatch(m)
+		v, ok := os.LookupEnv(string(sub[1]))
+		switch {
+		case v != "":
+			return []byte(v)
+		case sub[2] != nil:
+			return sub[3]
+		case ok:
+			return nil
+		}
+
+		return m
+	})
+}
+
+// supportedFile returns true if name has a YAML or JSON file extension.
+func supportedFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+
+	return false
+}
+
+// detectFormat returns f if it is not FormatAuto. Otherwise the format is detected by
+// the extension of name and, if the extension is not known, by the contents of b.
+// Content that starts with a "{" is JSON, everything else is YAML.
+func detectFormat(f Format, name string, b []byte) Format {
+	if f != FormatAuto {
+		return f
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		return FormatJSON
+	}
+
+	return FormatYAML
+}
+
 // translate converts the data from the file into a data.DHCP and data.Netboot structs.
 func (w *Watcher) translate(r dhcp) (*data.DHCP, *data.Netboot, error) {
 	d := new(data.DHCP)
 	n := new(data.Netboot)
 
 	d.MACAddress = r.MACAddress
-	// ip address, required
-	ip, err := netip.ParseAddr(r.IPAddress)
-	if err != nil {
-		return nil, nil, fmt.Errorf("%w: %w", err, errParseIP)
+	// ip address, required. CIDR notation (192.168.2.150/24) is allowed.
+	var prefix netip.Prefix
+	if strings.Contains(r.IPAddress, "/") {
+		p, err := netip.ParsePrefix(r.IPAddress)
+		if err != nil {
+			return nil, nil, &fieldError{field: "ipAddress", err: fmt.Errorf("%w: %w", err, errParseIP)}
+		}
+		prefix = p
+		d.IPAddress = p.Addr()
+	} else {
+		ip, err := netip.ParseAddr(r.IPAddress)
+		if err != nil {
+			return nil, nil, &fieldError{field: "ipAddress", err: fmt.Errorf("%w: %w", err, errParseIP)}
+		}
+		d.IPAddress = ip
 	}
-	d.IPAddress = ip
 
-	// subnet mask, required
-	sm := net.ParseIP(r.SubnetMask)
-	if sm == nil {
-		return nil, nil, errParseSubnet
+	// subnet mask, required unless the ip address is in CIDR notation.
+	// An explicit subnet mask takes precedence over the CIDR prefix length.
+	switch {
+	case r.SubnetMask != "":
+		sm := net.ParseIP(r.SubnetMask)
+		if sm == nil {
+			return nil, nil, &fieldError{field: "subnetMask", err: errParseSubnet}
+		}
+		d.SubnetMask = net.IPMask(sm.To4())
+	case prefix.IsValid() && d.IPAddress.Is4():
+		d.SubnetMask = net.CIDRMask(prefix.Bits(), 32)
+	default:
+		return nil, nil, &fieldError{field: "subnetMask", err: errParseSubnet}
 	}
-	d.SubnetMask = net.IPMask(sm.To4())
 
 	// default gateway, optional
 	if dg, err := netip.ParseAddr(r.DefaultGateway); err != nil {
 		w.Log.Info("failed to parse default gateway", "defaultGateway", r.DefaultGateway, "err", err)
 	} else {
-		d.DefaultGateway = dg
+		d.DefaultGateways = append(d.DefaultGateways, dg)
+	}
+
+	// additional default gateways, optional
+	for _, s := range r.DefaultGateways {
+		dg, err := netip.ParseAddr(s)
+		if err != nil {
+			w.Log.Info("failed to parse default gateway", "defaultGateway", s, "err", err)
+			break
+		}
+		d.DefaultGateways = append(d.DefaultGateways, dg)
 	}
 
 	// name servers, optional
@@ -271,11 +760,21 @@ func (w *Watcher) translate(r dhcp) (*data.DHCP, *data.Netboot, error) {
 	// domain name, optional
 	d.DomainName = r.DomainName
 
-	// broadcast address, optional
-	if ba, err := netip.ParseAddr(r.BroadcastAddress); err != nil {
-		w.Log.Info("failed to parse broadcast address", "broadcastAddress", r.BroadcastAddress, "err", err)
-	} else {
+	// mtu, optional
+	if r.MTU != 0 {
+		if r.MTU < 68 || r.MTU > 65535 {
+			return nil, nil, &fieldError{field: "mtu", err: errInvalidMTU}
+		}
+		d.MTU = uint16(r.MTU)
+	}
+
+	// broadcast address, optional. Derived from the ip address when it is in CIDR notation.
+	if ba, err := netip.ParseAddr(r.BroadcastAddress); err == nil {
 		d.BroadcastAddress = ba
+	} else if r.BroadcastAddress == "" && prefix.IsValid() && len(d.SubnetMask) == net.IPv4len {
+		d.BroadcastAddress = data.Broadcast(d.IPAddress, d.SubnetMask)
+	} else {
+		w.Log.Info("failed to parse broadcast address", "broadcastAddress", r.BroadcastAddress, "err", err)
 	}
 
 	// ntp servers, optional
@@ -291,15 +790,103 @@ func (w *Watcher) translate(r dhcp) (*data.DHCP, *data.Netboot, error) {
 	// vlanid
 	d.VLANID = r.VLANID
 
+	// vlan tag and priority, optional
+	d.VLANTag = r.VLANTag
+	d.VLANPriority = r.VLANPriority
+
 	// lease time
 	d.LeaseTime = uint32(r.LeaseTime)
 
+	// tftp server name and bootfile name, optional
+	d.TFTPServerName = r.TFTPServerName
+	d.BootFileName = r.BootFileName
+
 	// arch
 	d.Arch = r.Arch
 
+	// client identifier and guid, optional
+	if r.ClientIdentifier != "" {
+		b, err := decodeHex(r.ClientIdentifier)
+		if err != nil {
+			return nil, nil, &fieldError{field: "clientIdentifier", err: fmt.Errorf("%w: %w", err, errParseOption)}
+		}
+		d.ClientIdentifier = b
+	}
+	if r.ClientGUID != "" {
+		b, err := decodeHex(r.ClientGUID)
+		if err != nil {
+			return nil, nil, &fieldError{field: "clientGuid", err: fmt.Errorf("%w: %w", err, errParseOption)}
+		}
+		d.ClientGUID = b
+	}
+
+	// time offset and timezone, optional
+	d.TimeOffset = r.TimeOffset
+	d.TZPOSIX = r.PosixTimezone
+	d.TZDatabase = r.Timezone
+
 	// domain search
 	d.DomainSearch = r.DomainSearch
 
+	// sip servers, optional. IPv4 addresses are sent as addresses, anything else as domain names.
+	for _, s := range r.SIPServers {
+		if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+			d.SIPServerAddresses = append(d.SIPServerAddresses, ip.To4())
+			continue
+		}
+		d.SIPServerNames = append(d.SIPServerNames, s)
+	}
+
+	// static routes, optional
+	for i, rt := range r.StaticRoutes {
+		dst, err := netip.ParsePrefix(rt.Destination)
+		if err != nil {
+			return nil, nil, &fieldError{field: fmt.Sprintf("staticRoutes[%d].destination", i), err: fmt.Errorf("%w: %w", err, errParseRoute)}
+		}
+		gw, err := netip.ParseAddr(rt.Router)
+		if err != nil {
+			return nil, nil, &fieldError{field: fmt.Sprintf("staticRoutes[%d].router", i), err: fmt.Errorf("%w: %w", err, errParseRoute)}
+		}
+		d.ClasslessStaticRoutes = append(d.ClasslessStaticRoutes, data.Route{Destination: dst, Router: gw})
+	}
+
+	// captive portal url, optional. It must be a valid url.
+	if r.CaptivePortalURL != "" {
+		u, err := url.Parse(r.CaptivePortalURL)
+		if err != nil {
+			return nil, nil, &fieldError{field: "captivePortalUrl", err: fmt.Errorf("%w: %w", err, errParseURL)}
+		}
+		d.CaptivePortalURL = u
+	}
+
+	// proxy auto-config url, optional. It must be a valid url.
+	if r.ProxyAutoConfigURL != "" {
+		u, err := url.Parse(r.ProxyAutoConfigURL)
+		if err != nil {
+			return nil, nil, &fieldError{field: "proxyAutoConfigUrl", err: fmt.Errorf("%w: %w", err, errParseURL)}
+		}
+		d.ProxyAutoConfigURL = u
+	}
+
+	// vendor, other, and vendor-identifying options, optional
+	var err error
+	if d.VendorOptions, err = parseOptions("vendorOptions", r.VendorOptions); err != nil {
+		return nil, nil, err
+	}
+	if d.OtherOptions, err = parseOptions("otherOptions", r.OtherOptions); err != nil {
+		return nil, nil, err
+	}
+	for e, opts := range r.VIVendorOptions {
+		o, err := parseOptions(fmt.Sprintf("viVendorOptions[%d]", e), opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		if d.VIVendorOptions == nil {
+			d.VIVendorOptions = make(data.VIVendorOptions, len(r.VIVendorOptions))
+		}
+		d.VIVendorOptions[e] = o
+	}
+
 	// allow machine to netboot
 	n.AllowNetboot = r.Netboot.AllowPXE
 
@@ -307,7 +894,7 @@ func (w *Watcher) translate(r dhcp) (*data.DHCP, *data.Netboot, error) {
 	if r.Netboot.IPXEScriptURL != "" {
 		u, err := url.Parse(r.Netboot.IPXEScriptURL)
 		if err != nil {
-			return nil, nil, fmt.Errorf("%w: %w", err, errParseURL)
+			return nil, nil, &fieldError{field: "netboot.ipxeScriptUrl", err: fmt.Errorf("%w: %w", err, errParseURL)}
 		}
 		n.IPXEScriptURL = u
 	}
@@ -327,5 +914,53 @@ func (w *Watcher) translate(r dhcp) (*data.DHCP, *data.Netboot, error) {
 		n.Facility = r.Netboot.Facility
 	}
 
+	// kernel params
+	n.KernelParams = r.Netboot.KernelParams
+
+	// ipxe binary
+	n.IPXEBinary = r.Netboot.IPXEBinary
+
+	// http boot uri is optional but if provided, it must be a valid url
+	if r.Netboot.HTTPBootURI != "" {
+		u, err := url.Parse(r.Netboot.HTTPBootURI)
+		if err != nil {
+			return nil, nil, &fieldError{field: "netboot.httpBootUri", err: fmt.Errorf("%w: %w", err, errParseURL)}
+		}
+		n.HTTPBootURI = u
+	}
+
+	// osie, optional but if the base url is provided, it must be a valid url
+	if r.Netboot.OSIE.BaseURL != "" {
+		u, err := url.Parse(r.Netboot.OSIE.BaseURL)
+		if err != nil {
+			return nil, nil, &fieldError{field: "netboot.osie.baseUrl", err: fmt.Errorf("%w: %w", err, errParseURL)}
+		}
+		n.OSIE.BaseURL = u
+	}
+	n.OSIE.Kernel = r.Netboot.OSIE.Kernel
+	n.OSIE.Initrd = r.Netboot.OSIE.Initrd
+
 	return d, n, nil
 }
+
+// parseOptions decodes the hex encoded values of opts, the field of a record.
+func parseOptions(field string, opts map[uint8]string) (map[uint8][]byte, error) {
+	if len(opts) == 0 {
+		return nil, nil
+	}
+	r := make(map[uint8][]byte, len(opts))
+	for c, v := range opts {
+		b, err := decodeHex(v)
+		if err != nil {
+			return nil, &fieldError{field: fmt.Sprintf("%v[%d]", field, c), err: fmt.Errorf("%w: %w", err, errParseOption)}
+		}
+		r[c] = b
+	}
+
+	return r, nil
+}
+
+// decodeHex decodes the hex encoded s. Bytes in s can be separated by colons or dashes.
+func decodeHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.NewReplacer(":", "", "-", "").Replace(s))
+}
@@ -11,6 +11,7 @@ import (
 	"net/netip"
 	"net/url"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -51,7 +52,7 @@ func TestNewWatcher(t *testing.T) {
 			if tt.wantErr != nil {
 				got = ""
 			} else {
-				got = string(w.data)
+				got = string(w.data[name])
 			}
 			if diff := cmp.Diff(got, tt.want); diff != "" {
 				t.Fatal(diff)
@@ -106,7 +107,7 @@ func TestStartFileUpdateError(t *testing.T) {
 	go func() {
 		<-time.After(time.Millisecond)
 		got.FilePath = "not-found.txt"
-		got.watcher.Events <- fsnotify.Event{Op: fsnotify.Write}
+		got.watcher.Events <- fsnotify.Event{Name: "not-found.txt", Op: fsnotify.Write}
 		cancel()
 	}()
 	got.Start(ctx)
@@ -117,7 +118,11 @@ func TestStartFileUpdateError(t *testing.T) {
 }
 
 func TestStartFileUpdate(t *testing.T) {
-	tt := &testData{initial: "once upon a time", after: "\nhello world", expectedOut: "once upon a time\nhello world"}
+	tt := &testData{
+		initial:     "00:00:00:00:00:01:\n  ipAddress: '192.168.2.1'\n",
+		after:       "00:00:00:00:00:02:\n  ipAddress: '192.168.2.2'\n",
+		expectedOut: "00:00:00:00:00:01:\n  ipAddress: '192.168.2.1'\n00:00:00:00:00:02:\n  ipAddress: '192.168.2.2'\n",
+	}
 	got, name := tt.helper(t, logr.Discard())
 	defer os.Remove(name)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -136,7 +141,7 @@ func TestStartFileUpdate(t *testing.T) {
 	}()
 	got.Start(ctx)
 	got.dataMu.RLock()
-	d := got.data
+	d := got.data[name]
 	got.dataMu.RUnlock()
 	if diff := cmp.Diff(string(d), tt.expectedOut); diff != "" {
 		t.Log(string(d))
@@ -144,6 +149,56 @@ func TestStartFileUpdate(t *testing.T) {
 	}
 }
 
+func TestStartFileUpdateRejected(t *testing.T) {
+	tests := map[string]struct {
+		after string
+	}{
+		"invalid yaml":        {after: "once upon a time"},
+		"invalid mac address": {after: "not-a-mac:\n  ipAddress: '192.168.2.2'\n"},
+		"empty file":          {after: "\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt := &testData{initial: "00:00:00:00:00:01:\n  ipAddress: '192.168.2.1'\n  subnetMask: '255.255.255.0'\n"}
+			got, name := tt.helper(t, logr.Discard())
+			defer os.Remove(name)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if err := got.Healthy(ctx); err != nil {
+				t.Fatalf("Healthy() error = %v, want nil", err)
+			}
+			go got.Start(ctx)
+			if err := os.WriteFile(name, []byte(tc.after), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for got.Rejected() == 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("update was not rejected")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if _, _, err := got.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}); err != nil {
+				t.Fatalf("last good data not served: %v", err)
+			}
+			if err := got.Healthy(ctx); err == nil {
+				t.Fatal("Healthy() expected error after a rejected update")
+			}
+
+			// a good update makes the watcher healthy again.
+			if err := os.WriteFile(name, []byte(tt.initial), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			for got.Healthy(ctx) != nil {
+				if time.Now().After(deadline) {
+					t.Fatal("watcher did not become healthy after a good update")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
 func TestStartFileUpdateClosedChan(t *testing.T) {
 	out := &bytes.Buffer{}
 	l := stdr.New(log.New(out, "", 0))
@@ -212,7 +267,7 @@ func (tt *testData) helper(t *testing.T, l logr.Logger) (*Watcher, string) {
 		t.Fatal(err)
 	}
 	w.dataMu.RLock()
-	before := string(w.data)
+	before := string(w.data[name])
 	w.dataMu.RUnlock()
 	if diff := cmp.Diff(before, tt.initial); diff != "" {
 		t.Fatal("before", diff)
@@ -223,41 +278,82 @@ func (tt *testData) helper(t *testing.T, l logr.Logger) (*Watcher, string) {
 
 func TestTranslate(t *testing.T) {
 	input := dhcp{
-		MACAddress:       []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
-		IPAddress:        "192.168.2.150",
-		SubnetMask:       "255.255.255.0",
-		DefaultGateway:   "192.168.2.1",
-		NameServers:      []string{"1.1.1.1", "8.8.8.8"},
-		Hostname:         "test-server",
-		DomainName:       "example.com",
-		BroadcastAddress: "192.168.2.255",
-		NTPServers:       []string{"132.163.96.2"},
-		VLANID:           "100",
-		LeaseTime:        86400,
-		Arch:             "x86_64",
-		DomainSearch:     []string{"example.com"},
+		MACAddress:         []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+		IPAddress:          "192.168.2.150",
+		SubnetMask:         "255.255.255.0",
+		DefaultGateway:     "192.168.2.1",
+		DefaultGateways:    []string{"192.168.2.2"},
+		NameServers:        []string{"1.1.1.1", "8.8.8.8"},
+		Hostname:           "test-server",
+		DomainName:         "example.com",
+		MTU:                9000,
+		BroadcastAddress:   "192.168.2.255",
+		NTPServers:         []string{"132.163.96.2"},
+		VLANID:             "100",
+		LeaseTime:          86400,
+		TFTPServerName:     "192.168.2.5",
+		BootFileName:       "pxelinux.0",
+		Arch:               "x86_64",
+		ClientIdentifier:   "01:00:01:02:03:04:05",
+		ClientGUID:         "4c4c4544-0051-1080-804a-b4c04f4e3832",
+		DomainSearch:       []string{"example.com"},
+		StaticRoutes:       []route{{Destination: "10.0.0.0/8", Router: "192.168.2.254"}},
+		VendorOptions:      map[uint8]string{1: "aa"},
+		TimeOffset:         3600,
+		PosixTimezone:      "CET-1CEST,M3.5.0,M10.5.0/3",
+		Timezone:           "Europe/Amsterdam",
+		SIPServers:         []string{"192.168.2.5"},
+		VIVendorOptions:    map[uint32]map[uint8]string{3561: {1: "61:62:63"}},
+		CaptivePortalURL:   "https://portal.example.com/api",
+		VLANTag:            200,
+		VLANPriority:       5,
+		ProxyAutoConfigURL: "http://192.168.2.1/wpad.dat",
+		OtherOptions:       map[uint8]string{224: "01:02:ff"},
 		Netboot: netboot{
 			AllowPXE:      true,
 			IPXEScriptURL: "http://boot.netboot.xyz",
 			IPXEScript:    "#!ipxe\nchain http://boot.netboot.xyz",
 			Console:       "ttyS0",
 			Facility:      "onprem",
+			KernelParams:  "quiet",
+			IPXEBinary:    "snp-debug.efi",
+			HTTPBootURI:   "https://192.168.2.5/uki.efi",
+			OSIE:          osie{BaseURL: "http://192.168.2.5:8080/osie", Kernel: "vmlinuz-x86_64", Initrd: "initramfs-x86_64"},
 		},
 	}
 	wantDHCP := &data.DHCP{
 		MACAddress:       []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
 		IPAddress:        netip.MustParseAddr("192.168.2.150"),
 		SubnetMask:       net.IPv4Mask(255, 255, 255, 0),
-		DefaultGateway:   netip.MustParseAddr("192.168.2.1"),
+		DefaultGateways:  []netip.Addr{netip.MustParseAddr("192.168.2.1"), netip.MustParseAddr("192.168.2.2")},
 		NameServers:      []net.IP{{1, 1, 1, 1}, {8, 8, 8, 8}},
 		Hostname:         "test-server",
 		DomainName:       "example.com",
+		MTU:              9000,
 		BroadcastAddress: netip.MustParseAddr("192.168.2.255"),
 		NTPServers:       []net.IP{{132, 163, 96, 2}},
 		VLANID:           "100",
 		LeaseTime:        86400,
+		TFTPServerName:   "192.168.2.5",
+		BootFileName:     "pxelinux.0",
 		Arch:             "x86_64",
+		ClientIdentifier: []byte{0x01, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+		ClientGUID:       []byte{0x4c, 0x4c, 0x45, 0x44, 0x00, 0x51, 0x10, 0x80, 0x80, 0x4a, 0xb4, 0xc0, 0x4f, 0x4e, 0x38, 0x32},
 		DomainSearch:     []string{"example.com"},
+		ClasslessStaticRoutes: []data.Route{
+			{Destination: netip.MustParsePrefix("10.0.0.0/8"), Router: netip.MustParseAddr("192.168.2.254")},
+		},
+		VendorOptions:      map[uint8][]byte{1: {0xaa}},
+		TimeOffset:         3600,
+		TZPOSIX:            "CET-1CEST,M3.5.0,M10.5.0/3",
+		TZDatabase:         "Europe/Amsterdam",
+		SIPServerAddresses: []net.IP{{192, 168, 2, 5}},
+		VIVendorOptions:    data.VIVendorOptions{3561: {1: []byte("abc")}},
+		CaptivePortalURL:   &url.URL{Scheme: "https", Host: "portal.example.com", Path: "/api"},
+		VLANTag:            200,
+		VLANPriority:       5,
+		ProxyAutoConfigURL: &url.URL{Scheme: "http", Host: "192.168.2.1", Path: "/wpad.dat"},
+		OtherOptions:       map[uint8][]byte{224: {0x01, 0x02, 0xff}},
 	}
 	wantNetboot := &data.Netboot{
 		AllowNetboot:  true,
@@ -265,13 +361,21 @@ func TestTranslate(t *testing.T) {
 		IPXEScript:    "#!ipxe\nchain http://boot.netboot.xyz",
 		Console:       "ttyS0",
 		Facility:      "onprem",
+		KernelParams:  "quiet",
+		IPXEBinary:    "snp-debug.efi",
+		HTTPBootURI:   &url.URL{Scheme: "https", Host: "192.168.2.5", Path: "/uki.efi"},
+		OSIE: data.OSIE{
+			BaseURL: &url.URL{Scheme: "http", Host: "192.168.2.5:8080", Path: "/osie"},
+			Kernel:  "vmlinuz-x86_64",
+			Initrd:  "initramfs-x86_64",
+		},
 	}
 	w := &Watcher{Log: logr.Discard()}
 	gotDHCP, gotNetboot, err := w.translate(input)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if diff := cmp.Diff(gotDHCP, wantDHCP, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+	if diff := cmp.Diff(gotDHCP, wantDHCP, cmpopts.IgnoreUnexported(netip.Addr{}, netip.Prefix{})); diff != "" {
 		t.Error(diff)
 	}
 	if diff := cmp.Diff(gotNetboot, wantNetboot); diff != "" {
@@ -291,6 +395,12 @@ func TestTranslateErrors(t *testing.T) {
 		"invalid NameServers":       {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "192.168.1.255", NameServers: []string{"no good"}}, wantErr: nil},
 		"invalid ntpservers":        {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "192.168.1.255", NTPServers: []string{"no good"}}, wantErr: nil},
 		"invalid ipxe script url":   {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "255.255.255.0", Netboot: netboot{IPXEScriptURL: ":not a url"}}, wantErr: errParseURL},
+		"invalid mtu":               {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "255.255.255.0", MTU: 70000}, wantErr: errInvalidMTU},
+		"invalid route destination": {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "255.255.255.0", StaticRoutes: []route{{Destination: "10.0.0.0", Router: "1.1.1.254"}}}, wantErr: errParseRoute},
+		"invalid route router":      {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "255.255.255.0", StaticRoutes: []route{{Destination: "10.0.0.0/8"}}}, wantErr: errParseRoute},
+		"invalid proxy config url":  {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "255.255.255.0", ProxyAutoConfigURL: ":not a url"}, wantErr: errParseURL},
+		"invalid other option":      {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "255.255.255.0", OtherOptions: map[uint8]string{224: "not hex"}}, wantErr: errParseOption},
+		"invalid client GUID":       {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "255.255.255.0", ClientGUID: "not hex"}, wantErr: errParseOption},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -302,6 +412,33 @@ func TestTranslateErrors(t *testing.T) {
 	}
 }
 
+func TestTranslateFieldError(t *testing.T) {
+	tests := map[string]struct {
+		input     dhcp
+		wantField string
+	}{
+		"ip address":      {input: dhcp{IPAddress: "not an IP"}, wantField: "ipAddress"},
+		"subnet mask":     {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "not a mask"}, wantField: "subnetMask"},
+		"mtu":             {input: dhcp{IPAddress: "1.1.1.1/24", MTU: 10}, wantField: "mtu"},
+		"route router":    {input: dhcp{IPAddress: "1.1.1.1/24", StaticRoutes: []route{{Destination: "10.0.0.0/8", Router: "1.1.1.254"}, {Destination: "10.1.0.0/16"}}}, wantField: "staticRoutes[1].router"},
+		"ipxe script url": {input: dhcp{IPAddress: "1.1.1.1/24", Netboot: netboot{IPXEScriptURL: ":not a url"}}, wantField: "netboot.ipxeScriptUrl"},
+		"osie base url":   {input: dhcp{IPAddress: "1.1.1.1/24", Netboot: netboot{OSIE: osie{BaseURL: ":not a url"}}}, wantField: "netboot.osie.baseUrl"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := &Watcher{Log: logr.Discard()}
+			_, _, err := w.translate(tt.input)
+			var fe *fieldError
+			if !errors.As(err, &fe) {
+				t.Fatalf("translate() error = %v, want a field error", err)
+			}
+			if fe.field != tt.wantField {
+				t.Errorf("got field %q, want %q", fe.field, tt.wantField)
+			}
+		})
+	}
+}
+
 func TestGetByMac(t *testing.T) {
 	tests := map[string]struct {
 		mac     net.HardwareAddr
@@ -370,3 +507,434 @@ func TestGetByIP(t *testing.T) {
 		})
 	}
 }
+
+func TestGetByMacJSON(t *testing.T) {
+	tests := map[string]struct {
+		mac     net.HardwareAddr
+		format  Format
+		wantErr error
+	}{
+		"record found":           {mac: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67}},
+		"record found as yaml":   {mac: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67}, format: FormatYAML},
+		"no record found":        {mac: net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}, wantErr: errRecordNotFound},
+		"fail error translating": {mac: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x68}, wantErr: errParseIP},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w, err := NewWatcher(logr.Discard(), "testdata/example.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Format = tt.format
+			_, _, err = w.GetByMac(context.Background(), tt.mac)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]struct {
+		format  Format
+		name    string
+		content string
+		want    Format
+	}{
+		"explicit format":   {format: FormatYAML, name: "data.json", content: "{}", want: FormatYAML},
+		"json extension":    {name: "data.json", content: "---", want: FormatJSON},
+		"yaml extension":    {name: "data.yaml", content: "{}", want: FormatYAML},
+		"yml extension":     {name: "data.YML", want: FormatYAML},
+		"json content":      {name: "data", content: "\n  {\"00:01:02:03:04:05\": {}}", want: FormatJSON},
+		"yaml content":      {name: "data", content: "00:01:02:03:04:05:\n  ipAddress: 1.1.1.1", want: FormatYAML},
+		"unknown extension": {name: "data.txt", content: "{}", want: FormatJSON},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := detectFormat(tt.format, tt.name, []byte(tt.content)); got != tt.want {
+				t.Fatalf("detectFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	dir := t.TempDir()
+	rack1 := "00:00:00:00:00:01:\n  ipAddress: '192.168.2.1'\n  subnetMask: '255.255.255.0'\n"
+	rack2 := `{"00:00:00:00:00:02": {"ipAddress": "192.168.2.2", "subnetMask": "255.255.255.0"}}`
+	rack3 := "00:00:00:00:00:03:\n  ipAddress: '192.168.2.3'\n  subnetMask: '255.255.255.0'\n"
+	for name, content := range map[string]string{"rack1.yaml": rack1, "rack2.json": rack2, "ignored.txt": "not data"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := NewWatcher(logr.Discard(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ip := range []net.IP{{192, 168, 2, 1}, {192, 168, 2, 2}} {
+		if _, _, err := w.GetByIP(context.Background(), ip); err != nil {
+			t.Fatalf("GetByIP(%v) error = %v", ip, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Start(ctx)
+
+	if err := os.WriteFile(filepath.Join(dir, "rack3.yml"), []byte(rack3), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "rack1.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	mac1 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	mac3 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x03}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, _, err1 := w.GetByMac(context.Background(), mac1)
+		_, _, err3 := w.GetByMac(context.Background(), mac3)
+		if errors.Is(err1, errRecordNotFound) && err3 == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("directory changes not picked up: removed file error = %v, added file error = %v", err1, err3)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStartAtomicReplace(t *testing.T) {
+	record := func(ip string) []byte {
+		return []byte("00:00:00:00:00:01:\n  ipAddress: '" + ip + "'\n  subnetMask: '255.255.255.0'\n")
+	}
+	waitForIP := func(t *testing.T, w *Watcher, ip string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			d, _, err := w.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01})
+			if err == nil && d.IPAddress.String() == ip {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("update to %v not picked up, got %v, err %v", ip, d, err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	t.Run("rename over the file", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "hardware.yaml")
+		if err := os.WriteFile(name, record("192.168.2.1"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		w, err := NewWatcher(logr.Discard(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go w.Start(ctx)
+
+		// replace the file twice, a watch on the file itself would miss the second update.
+		for _, ip := range []string{"192.168.2.2", "192.168.2.3"} {
+			tmp := filepath.Join(dir, ".hardware.yaml.tmp")
+			if err := os.WriteFile(tmp, record(ip), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Rename(tmp, name); err != nil {
+				t.Fatal(err)
+			}
+			waitForIP(t, w, ip)
+		}
+	})
+
+	t.Run("kubernetes configmap symlink swap", func(t *testing.T) {
+		dir := t.TempDir()
+		// layout of a ConfigMap volume: hardware.yaml -> ..data/hardware.yaml, ..data -> ..v1
+		if err := os.Mkdir(filepath.Join(dir, "..v1"), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "..v1", "hardware.yaml"), record("192.168.2.1"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Join(dir, "hardware.yaml")
+		if err := os.Symlink(filepath.Join("..data", "hardware.yaml"), name); err != nil {
+			t.Fatal(err)
+		}
+		w, err := NewWatcher(logr.Discard(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go w.Start(ctx)
+
+		if err := os.Mkdir(filepath.Join(dir, "..v2"), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "..v2", "hardware.yaml"), record("192.168.2.2"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+		waitForIP(t, w, "192.168.2.2")
+	})
+}
+
+func TestTranslateCIDR(t *testing.T) {
+	tests := map[string]struct {
+		input         dhcp
+		wantIP        netip.Addr
+		wantMask      net.IPMask
+		wantBroadcast netip.Addr
+		wantErr       error
+	}{
+		"cidr": {
+			input:         dhcp{IPAddress: "192.168.2.150/24"},
+			wantIP:        netip.MustParseAddr("192.168.2.150"),
+			wantMask:      net.IPv4Mask(255, 255, 255, 0),
+			wantBroadcast: netip.MustParseAddr("192.168.2.255"),
+		},
+		"cidr with explicit subnet mask and broadcast": {
+			input:         dhcp{IPAddress: "10.0.10.5/16", SubnetMask: "255.255.255.0", BroadcastAddress: "10.0.10.127"},
+			wantIP:        netip.MustParseAddr("10.0.10.5"),
+			wantMask:      net.IPv4Mask(255, 255, 255, 0),
+			wantBroadcast: netip.MustParseAddr("10.0.10.127"),
+		},
+		"cidr with explicit subnet mask": {
+			input:         dhcp{IPAddress: "10.0.10.5/16", SubnetMask: "255.255.255.128"},
+			wantIP:        netip.MustParseAddr("10.0.10.5"),
+			wantMask:      net.IPv4Mask(255, 255, 255, 128),
+			wantBroadcast: netip.MustParseAddr("10.0.10.127"),
+		},
+		"invalid cidr":             {input: dhcp{IPAddress: "192.168.2.150/33"}, wantErr: errParseIP},
+		"no cidr and no mask":      {input: dhcp{IPAddress: "192.168.2.150"}, wantErr: errParseSubnet},
+		"ipv6 cidr and no ipv4 sm": {input: dhcp{IPAddress: "fd00::1/64"}, wantErr: errParseSubnet},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := &Watcher{Log: logr.Discard()}
+			d, _, err := w.translate(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("translate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if d.IPAddress != tt.wantIP {
+				t.Errorf("got ip %v, want %v", d.IPAddress, tt.wantIP)
+			}
+			if diff := cmp.Diff(d.SubnetMask, tt.wantMask); diff != "" {
+				t.Error(diff)
+			}
+			if d.BroadcastAddress != tt.wantBroadcast {
+				t.Errorf("got broadcast %v, want %v", d.BroadcastAddress, tt.wantBroadcast)
+			}
+		})
+	}
+}
+
+func TestGetByIPCIDR(t *testing.T) {
+	name, err := createFile([]byte("00:00:00:00:00:01:\n  ipAddress: '192.168.2.150/24'\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(name)
+	w, err := NewWatcher(logr.Discard(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _, err := w.GetByIP(context.Background(), net.IPv4(192, 168, 2, 150))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(d.SubnetMask, net.IPv4Mask(255, 255, 255, 0)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGetByMacDefaultRecord(t *testing.T) {
+	content := `00:00:00:00:00:01:
+  ipAddress: '192.168.2.1'
+  subnetMask: '255.255.255.0'
+default:
+  ipAddress: '192.168.2.250'
+  subnetMask: '255.255.255.0'
+  netboot:
+    allowPxe: true
+`
+	name, err := createFile([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(name)
+	w, err := NewWatcher(logr.Discard(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		mac    net.HardwareAddr
+		wantIP string
+	}{
+		"exact match":    {mac: net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, wantIP: "192.168.2.1"},
+		"default record": {mac: net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}, wantIP: "192.168.2.250"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, _, err := w.GetByMac(context.Background(), tt.mac)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.IPAddress.String() != tt.wantIP {
+				t.Errorf("got ip %v, want %v", d.IPAddress, tt.wantIP)
+			}
+			if d.MACAddress.String() != tt.mac.String() {
+				t.Errorf("got mac %v, want %v", d.MACAddress, tt.mac)
+			}
+		})
+	}
+	if _, _, err := w.GetByIP(context.Background(), net.IPv4(192, 168, 2, 250)); !errors.Is(err, errRecordNotFound) {
+		t.Errorf("GetByIP() for the default record error = %v, want %v", err, errRecordNotFound)
+	}
+	if err := w.validate(name, []byte(content)); err != nil {
+		t.Errorf("validate() error = %v", err)
+	}
+}
+
+func TestGetByMacMultipleMACs(t *testing.T) {
+	content := `00:00:00:00:00:01:
+  ipAddress: '192.168.2.1/24'
+  macAddresses:
+  - '00:00:00:00:00:02'
+  - '00-00-00-00-00-03'
+00:00:00:00:00:03:
+  ipAddress: '192.168.2.3/24'
+`
+	name, err := createFile([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(name)
+	w, err := NewWatcher(logr.Discard(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		mac     net.HardwareAddr
+		wantIP  string
+		wantErr error
+	}{
+		"record key":         {mac: net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, wantIP: "192.168.2.1"},
+		"additional mac":     {mac: net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}, wantIP: "192.168.2.1"},
+		"record key is used": {mac: net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x03}, wantIP: "192.168.2.3"},
+		"not found":          {mac: net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x04}, wantErr: errRecordNotFound},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, _, err := w.GetByMac(context.Background(), tt.mac)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if d.IPAddress.String() != tt.wantIP {
+				t.Errorf("got ip %v, want %v", d.IPAddress, tt.wantIP)
+			}
+			if d.MACAddress.String() != tt.mac.String() {
+				t.Errorf("got mac %v, want %v", d.MACAddress, tt.mac)
+			}
+		})
+	}
+	if err := w.validate(name, []byte("00:00:00:00:00:01:\n  macAddresses:\n  - 'not a mac'\n")); !errors.Is(err, errFileFormat) {
+		t.Errorf("validate() error = %v, want %v", err, errFileFormat)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("DHCP_TEST_HOST", "boot.example.com")
+	t.Setenv("DHCP_TEST_EMPTY", "")
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"set":                       {input: "http://${DHCP_TEST_HOST}/auto.ipxe", want: "http://boot.example.com/auto.ipxe"},
+		"set with default":          {input: "${DHCP_TEST_HOST:-other.example.com}", want: "boot.example.com"},
+		"unset with default":        {input: "${DHCP_TEST_UNSET:-1.1.1.1}", want: "1.1.1.1"},
+		"empty with default":        {input: "${DHCP_TEST_EMPTY:-1.1.1.1}", want: "1.1.1.1"},
+		"empty without default":     {input: "a${DHCP_TEST_EMPTY}b", want: "ab"},
+		"unset without default":     {input: "${DHCP_TEST_UNSET}", want: "${DHCP_TEST_UNSET}"},
+		"ipxe variable is not used": {input: "chain http://x/${net0/mac}", want: "chain http://x/${net0/mac}"},
+		"no variables":              {input: "ipAddress: '192.168.2.1'", want: "ipAddress: '192.168.2.1'"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := string(expandEnv([]byte(tt.input))); got != tt.want {
+				t.Errorf("expandEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetByMacExpandEnv(t *testing.T) {
+	t.Setenv("DHCP_TEST_DNS", "9.9.9.9")
+	content := `00:00:00:00:00:01:
+  ipAddress: '192.168.2.1/24'
+  nameServers:
+  - '${DHCP_TEST_DNS}'
+  netboot:
+    ipxeScriptUrl: 'http://${DHCP_TEST_SCRIPT_HOST:-192.168.2.5}/auto.ipxe'
+`
+	name, err := createFile([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(name)
+	w, err := NewWatcher(logr.Discard(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.ExpandEnv = true
+	d, n, err := w.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(d.NameServers, []net.IP{net.ParseIP("9.9.9.9")}); diff != "" {
+		t.Error(diff)
+	}
+	if got := n.IPXEScriptURL.String(); got != "http://192.168.2.5/auto.ipxe" {
+		t.Errorf("got ipxe script url %q, want %q", got, "http://192.168.2.5/auto.ipxe")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	w := &Watcher{Log: logr.Discard()}
+	mac := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	if err := w.Update("hardware.json", []byte(`{"00:00:00:00:00:01": {"ipAddress": "192.168.2.1", "subnetMask": "255.255.255.0"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := w.GetByMac(context.Background(), mac); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Update("hardware.json", []byte("once upon a time")); !errors.Is(err, errFileFormat) {
+		t.Fatalf("Update() error = %v, wantErr %v", err, errFileFormat)
+	}
+	if w.Rejected() != 1 {
+		t.Fatalf("Rejected() = %d, want 1", w.Rejected())
+	}
+	if _, _, err := w.GetByMac(context.Background(), mac); err != nil {
+		t.Fatalf("last good data not served: %v", err)
+	}
+	if err := w.Healthy(context.Background()); err == nil {
+		t.Fatal("Healthy() expected error after a rejected update")
+	}
+}
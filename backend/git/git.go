@@ -0,0 +1,273 @@
+// Package git is a backend that serves the reservations in a file of a git repository. The repository is cloned
+// and then pulled on an interval, so changes to DHCP records are reviewed and tracked like any other change, GitOps style.
+//
+// The file uses the data model of the file backend, see backend/file/testdata/example.yaml.
+// The commit that the served data comes from is recorded in the span of every read, as the git.commit attribute.
+//
+// The git command line client is used to clone and pull the repository, so it must be installed.
+// Authentication is configured as for any git client, for example with an SSH key or a credential helper.
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/dhcp/backend/file"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+// DefaultInterval is the default time between pulls of the repository.
+const DefaultInterval = time.Minute
+
+// Errors used by the git backend.
+var (
+	errNoSync = fmt.Errorf("%w: repository has not been synced", data.ErrUnavailable)
+	errGit    = errors.New("git command failed")
+)
+
+// Backend serves the reservations in the file at Path of the git repository at Repository.
+//
+// The repository is pulled every Interval. When a pull fails or the file is invalid at the new commit,
+// the data of the last good commit continues to be served and Healthy returns an error.
+type Backend struct {
+	// Repository is the URL of the git repository, in any form that git clone accepts.
+	Repository string
+
+	// Branch is the branch to serve. Defaults to the default branch of the repository.
+	Branch string
+
+	// Path is the path of the file of reservations, relative to the root of the repository.
+	Path string
+
+	// Dir is the directory the repository is cloned into. Defaults to a new temporary directory.
+	Dir string
+
+	// Format is the format of the file. Defaults to file.FormatAuto.
+	Format file.Format
+
+	// Interval is the time between pulls.
+	Interval time.Duration
+
+	// Git is the git executable. Defaults to "git" in the PATH.
+	Git string
+
+	// Log is the logger to be used in the git backend.
+	Log logr.Logger
+
+	mu      sync.RWMutex // protects the fields below
+	files   *file.Watcher
+	commit  string
+	syncErr error
+}
+
+// NewBackend returns a Backend for the file at path in the git repository at repo.
+// The repository is not cloned until Sync or Start is called.
+func NewBackend(l logr.Logger, repo, path string) *Backend {
+	return &Backend{
+		Repository: repo,
+		Path:       path,
+		Interval:   DefaultInterval,
+		Git:        "git",
+		Log:        l,
+	}
+}
+
+// Start pulls the repository every Interval until ctx is done.
+// Start is a blocking method. Use a context cancellation to exit.
+func (b *Backend) Start(ctx context.Context) {
+	interval := b.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := b.Sync(ctx); err != nil && ctx.Err() == nil {
+			b.Log.Error(err, "failed to sync repository, serving last good commit", "commit", b.Commit())
+		}
+		select {
+		case <-ctx.Done():
+			b.Log.Info("stopping git backend")
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Sync clones the repository, or pulls it when it was already cloned, and serves the file at the new commit.
+func (b *Backend) Sync(ctx context.Context) error {
+	err := b.sync(ctx)
+	b.mu.Lock()
+	b.syncErr = err
+	b.mu.Unlock()
+
+	return err
+}
+
+func (b *Backend) sync(ctx context.Context) error {
+	if b.Dir == "" {
+		d, err := os.MkdirTemp("", "dhcp-git-")
+		if err != nil {
+			return err
+		}
+		b.Dir = d
+	}
+	ref := b.Branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := os.Stat(filepath.Join(b.Dir, ".git")); errors.Is(err, os.ErrNotExist) {
+		args := []string{"clone", "--quiet", "--depth", "1", "--single-branch"}
+		if b.Branch != "" {
+			args = append(args, "--branch", b.Branch)
+		}
+		if _, err := b.git(ctx, append(args, "--", b.Repository, b.Dir)...); err != nil {
+			return err
+		}
+	} else {
+		if _, err := b.git(ctx, "-C", b.Dir, "fetch", "--quiet", "--depth", "1", "origin", ref); err != nil {
+			return err
+		}
+		if _, err := b.git(ctx, "-C", b.Dir, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+			return err
+		}
+	}
+	out, err := b.git(ctx, "-C", b.Dir, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	commit := strings.TrimSpace(out)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if commit == b.commit {
+		return nil
+	}
+	body, err := os.ReadFile(filepath.Join(b.Dir, filepath.FromSlash(b.Path)))
+	if err != nil {
+		return fmt.Errorf("commit %v: %w", commit, err)
+	}
+	if b.files == nil {
+		b.files = &file.Watcher{FilePath: b.Path, Format: b.Format, Log: b.Log}
+	}
+	if err := b.files.Update(b.Path, body); err != nil {
+		return fmt.Errorf("commit %v: %w", commit, err)
+	}
+	b.commit = commit
+	b.Log.Info("serving new commit", "commit", commit, "path", b.Path)
+
+	return nil
+}
+
+// git runs the git executable with args and returns its standard output.
+func (b *Backend) git(ctx context.Context, args ...string) (string, error) {
+	g := b.Git
+	if g == "" {
+		g = "git"
+	}
+	cmd := exec.CommandContext(ctx, g, args...)
+	// never wait for credentials on a terminal.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: git %v: %w: %s", errGit, args[0], err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	return stdout.String(), nil
+}
+
+// GetByMac implements the handler.BackendReader interface. It reads the records of the file at Path as of the last
+// synced commit, which is added to the span. A MAC address that is not in the file is a data.ErrNotFound error, and
+// every read before the first sync is a data.ErrUnavailable error.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.git.GetByMac")
+	defer span.End()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.files == nil {
+		span.SetStatus(codes.Error, errNoSync.Error())
+
+		return nil, nil, errNoSync
+	}
+	span.SetAttributes(attribute.String("git.commit", b.commit))
+	d, n, err := b.files.GetByMac(ctx, mac)
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface. It reads the records of the last synced commit like
+// GetByMac.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.git.GetByIP")
+	defer span.End()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.files == nil {
+		span.SetStatus(codes.Error, errNoSync.Error())
+
+		return nil, nil, errNoSync
+	}
+	span.SetAttributes(attribute.String("git.commit", b.commit))
+	d, n, err := b.files.GetByIP(ctx, ip)
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// Healthy implements the handler.HealthChecker interface.
+// It returns an error if the repository was not synced yet, or if the last sync failed or the file was invalid.
+func (b *Backend) Healthy(ctx context.Context) error {
+	b.mu.RLock()
+	err, w := b.syncErr, b.files
+	b.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("last sync failed, serving last good commit: %w", err)
+	}
+	if w == nil {
+		return errNoSync
+	}
+
+	return w.Healthy(ctx)
+}
+
+// Commit returns the hash of the commit that the served data comes from, or an empty string if the repository was not synced yet.
+func (b *Backend) Commit() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.commit
+}
@@ -0,0 +1,195 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+)
+
+var mac = net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+func document(ip string) string {
+	return fmt.Sprintf(`{"00:00:00:00:00:01": {"ipAddress": %q, "subnetMask": "255.255.255.0"}}`, ip)
+}
+
+// repo is a local git repository that a Backend clones.
+type repo struct {
+	t   *testing.T
+	dir string
+}
+
+func newRepo(t *testing.T) *repo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	r := &repo{t: t, dir: t.TempDir()}
+	r.git("init", "--quiet", "--initial-branch", "main")
+
+	return r
+}
+
+func (r *repo) git(args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", r.dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// commit commits contents to the file at path and returns the hash of the commit.
+func (r *repo) commit(path, contents string) string {
+	r.t.Helper()
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(r.dir, path)), 0o755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, path), []byte(contents), 0o600); err != nil {
+		r.t.Fatal(err)
+	}
+	r.git("add", "--all")
+	r.git("commit", "--quiet", "--message", "update "+path)
+
+	return r.git("rev-parse", "HEAD")
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	r := newRepo(t)
+	c1 := r.commit("dhcp/hardware.json", document("192.168.2.1"))
+
+	b := NewBackend(logr.Discard(), r.dir, "dhcp/hardware.json")
+	b.Dir = filepath.Join(t.TempDir(), "clone")
+	if _, _, err := b.GetByMac(ctx, mac); !errors.Is(err, errNoSync) {
+		t.Fatalf("GetByMac() error = %v, wantErr %v", err, errNoSync)
+	}
+	if err := b.Healthy(ctx); !errors.Is(err, errNoSync) {
+		t.Fatalf("Healthy() error = %v, wantErr %v", err, errNoSync)
+	}
+
+	// clone.
+	if err := b.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(c1, b.Commit()); diff != "" {
+		t.Fatal(diff)
+	}
+	d, _, err := b.GetByMac(ctx, mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("192.168.2.1", d.IPAddress.String()); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// pull a new commit.
+	c2 := r.commit("dhcp/hardware.json", document("192.168.2.2"))
+	if err := b.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(c2, b.Commit()); diff != "" {
+		t.Fatal(diff)
+	}
+	if _, _, err := b.GetByIP(ctx, net.ParseIP("192.168.2.2")); err != nil {
+		t.Fatal(err)
+	}
+
+	// an invalid file is rejected and the last good commit is served.
+	r.commit("dhcp/hardware.json", `{"not a mac": {}}`)
+	if err := b.Sync(ctx); err == nil {
+		t.Fatal("Sync() error = nil, want an error for an invalid file")
+	}
+	if diff := cmp.Diff(c2, b.Commit()); diff != "" {
+		t.Fatal(diff)
+	}
+	if _, _, err := b.GetByIP(ctx, net.ParseIP("192.168.2.2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Healthy(ctx); err == nil {
+		t.Fatal("Healthy() error = nil, want an error after an invalid commit")
+	}
+
+	// fixing the file recovers.
+	c4 := r.commit("dhcp/hardware.json", document("192.168.2.4"))
+	if err := b.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(c4, b.Commit()); diff != "" {
+		t.Fatal(diff)
+	}
+	if err := b.Healthy(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncBranch(t *testing.T) {
+	ctx := context.Background()
+	r := newRepo(t)
+	r.commit("hardware.json", document("192.168.2.1"))
+	r.git("checkout", "--quiet", "-b", "site-a")
+	c := r.commit("hardware.json", document("10.0.0.1"))
+	r.git("checkout", "--quiet", "main")
+
+	b := NewBackend(logr.Discard(), r.dir, "hardware.json")
+	b.Branch = "site-a"
+	b.Dir = filepath.Join(t.TempDir(), "clone")
+	if err := b.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(c, b.Commit()); diff != "" {
+		t.Fatal(diff)
+	}
+	if _, _, err := b.GetByIP(ctx, net.ParseIP("10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncError(t *testing.T) {
+	ctx := context.Background()
+	tests := map[string]struct {
+		repo    func(t *testing.T) string
+		path    string
+		wantErr error
+	}{
+		"no repository": {
+			repo:    func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing") },
+			path:    "hardware.json",
+			wantErr: errGit,
+		},
+		"no file": {
+			repo: func(t *testing.T) string {
+				r := newRepo(t)
+				r.commit("hardware.json", document("192.168.2.1"))
+				return r.dir
+			},
+			path:    "missing.json",
+			wantErr: os.ErrNotExist,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := exec.LookPath("git"); err != nil {
+				t.Skip("git is not installed")
+			}
+			b := NewBackend(logr.Discard(), tt.repo(t), tt.path)
+			b.Dir = filepath.Join(t.TempDir(), "clone")
+			if err := b.Sync(ctx); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Sync() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := b.Healthy(ctx); err == nil {
+				t.Fatal("Healthy() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+// Package hegel is a backend implementation that gets DHCP data from the Tinkerbell metadata service, Hegel.
+// It is meant for deployments where DHCP runs at the edge and only the metadata endpoint is reachable,
+// not the Tink API or the cluster.
+//
+// Hegel identifies the machine a request is for by the source IP address of the request, and when the
+// requester is one of its trusted proxies, by the X-Forwarded-For header. GetByIP requests the metadata with
+// X-Forwarded-For set to the IP address, so the host running this DHCP server must be a trusted proxy
+// (the TRUSTED_PROXIES setting of Hegel). Hegel has no lookup by MAC address, so GetByMac requires MACURL
+// to point at an endpoint that serves the same metadata document by MAC address, for example a proxy in front of Hegel.
+package hegel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+// Errors used by the Hegel backend.
+var (
+	errStatus         = errors.New("unexpected status code from Hegel")
+	errNoMACURL       = errors.New("lookup by MAC address requires MACURL to be set")
+	errMACPlaceholder = errors.New("MACURL must contain the {mac} placeholder")
+)
+
+// Backend is a backend implementation that gets DHCP data from the Hegel metadata service.
+type Backend struct {
+	// URL is the base URL of Hegel. For example, http://hegel.example.com:50061.
+	URL *url.URL
+
+	// MACURL is a URL template with a {mac} placeholder that serves the metadata document for a MAC address.
+	// For example, http://metadata-proxy.example.com/metadata/{mac}. When it is empty GetByMac returns an error.
+	MACURL string
+
+	// Client is the HTTP client used to talk to Hegel.
+	Client *http.Client
+
+	// Log is the logger to be used in the Hegel backend.
+	Log logr.Logger
+}
+
+// NewBackend returns a Hegel backend. macURL is optional, see Backend.MACURL.
+func NewBackend(l logr.Logger, u *url.URL, macURL string) (*Backend, error) {
+	if macURL != "" && !strings.Contains(macURL, "{mac}") {
+		return nil, errMACPlaceholder
+	}
+
+	return &Backend{URL: u, MACURL: macURL, Client: http.DefaultClient, Log: l}, nil
+}
+
+// metadata is the part of the Hegel metadata document that is used, the Tinkerbell hardware data.
+type metadata struct {
+	Metadata struct {
+		Facility struct {
+			FacilityCode string `json:"facility_code"`
+		} `json:"facility"`
+	} `json:"metadata"`
+	Network struct {
+		Interfaces []iface `json:"interfaces"`
+	} `json:"network"`
+}
+
+type iface struct {
+	DHCP struct {
+		MAC string `json:"mac"`
+		IP  struct {
+			Address string `json:"address"`
+			Netmask string `json:"netmask"`
+			Gateway string `json:"gateway"`
+		} `json:"ip"`
+		Hostname    string   `json:"hostname"`
+		LeaseTime   uint32   `json:"lease_time"`
+		NameServers []string `json:"name_servers"`
+		TimeServers []string `json:"time_servers"`
+		Arch        string   `json:"arch"`
+		VLANID      string   `json:"vlan_id"`
+	} `json:"dhcp"`
+	Netboot struct {
+		AllowPXE bool `json:"allow_pxe"`
+		IPXE     struct {
+			URL      string `json:"url"`
+			Contents string `json:"contents"`
+		} `json:"ipxe"`
+		OSIE struct {
+			BaseURL string `json:"base_url"`
+			Kernel  string `json:"kernel"`
+			Initrd  string `json:"initrd"`
+		} `json:"osie"`
+	} `json:"netboot"`
+}
+
+// GetByMac implements the handler.BackendReader interface. It gets the metadata document at MACURL, with {mac} replaced
+// by mac, and builds the record from the interface of the document with that MAC address. Nothing is cached. A 404 or
+// a document without the interface is a data.ErrNotFound error, and a read without MACURL fails.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.hegel.GetByMac")
+	defer span.End()
+
+	if b.MACURL == "" {
+		span.SetStatus(codes.Error, errNoMACURL.Error())
+
+		return nil, nil, errNoMACURL
+	}
+	u := strings.ReplaceAll(b.MACURL, "{mac}", url.PathEscape(mac.String()))
+	m, err := b.get(ctx, u, "")
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	d, n, err := translate(m, func(i iface) bool { return strings.EqualFold(i.DHCP.MAC, mac.String()) })
+	if err != nil {
+		if !data.IsNotFound(err) {
+			err = fmt.Errorf("%w: %w", data.ErrInvalidRecord, err)
+		}
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface. It gets the /metadata document of Hegel with ip as the
+// X-Forwarded-For address, the way Hegel identifies a machine, and builds the record from the interface of the document
+// with that IP address. A 404 or a document without the interface is a data.ErrNotFound error.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.hegel.GetByIP")
+	defer span.End()
+
+	m, err := b.get(ctx, b.URL.JoinPath("/metadata").String(), ip.String())
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	d, n, err := translate(m, func(i iface) bool { return i.DHCP.IP.Address == ip.String() })
+	if err != nil {
+		if !data.IsNotFound(err) {
+			err = fmt.Errorf("%w: %w", data.ErrInvalidRecord, err)
+		}
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// get gets the metadata document at u. forwardedFor, if not empty, is sent as the X-Forwarded-For header.
+// A 404 response is returned as a not found error.
+func (b *Backend) get(ctx context.Context, u, forwardedFor string) (metadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return metadata{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+
+	c := b.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return metadata{}, err
+		}
+		return metadata{}, fmt.Errorf("%w: %w", data.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound:
+		return metadata{}, data.HardwareNotFoundError{}
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return metadata{}, fmt.Errorf("%w: %w: %d", data.ErrUnauthorized, errStatus, resp.StatusCode)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return metadata{}, fmt.Errorf("%w: %w: %d", data.ErrUnavailable, errStatus, resp.StatusCode)
+	default:
+		return metadata{}, fmt.Errorf("%w: %d", errStatus, resp.StatusCode)
+	}
+	var m metadata
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return metadata{}, fmt.Errorf("%w: failed to decode metadata: %w", data.ErrInvalidRecord, err)
+	}
+
+	return m, nil
+}
+
+// translate converts the first interface of m that match returns true for into data.DHCP and data.Netboot structs.
+// A not found error is returned if no interface matches.
+func translate(m metadata, match func(iface) bool) (*data.DHCP, *data.Netboot, error) {
+	var i *iface
+	for idx := range m.Network.Interfaces {
+		if match(m.Network.Interfaces[idx]) {
+			i = &m.Network.Interfaces[idx]
+			break
+		}
+	}
+	if i == nil {
+		// the document is for another interface of the machine, or for another machine.
+		return nil, nil, data.HardwareNotFoundError{}
+	}
+
+	d := new(data.DHCP)
+	n := new(data.Netboot)
+
+	var err error
+	// mac address, required
+	if d.MACAddress, err = net.ParseMAC(i.DHCP.MAC); err != nil {
+		return nil, nil, err
+	}
+
+	// ip address and subnet mask, required
+	if d.IPAddress, err = netip.ParseAddr(i.DHCP.IP.Address); err != nil {
+		return nil, nil, err
+	}
+	sm := net.ParseIP(i.DHCP.IP.Netmask).To4()
+	if sm == nil {
+		return nil, nil, fmt.Errorf("invalid netmask %q", i.DHCP.IP.Netmask)
+	}
+	d.SubnetMask = net.IPMask(sm)
+
+	// default gateway, optional
+	if i.DHCP.IP.Gateway != "" {
+		gw, err := netip.ParseAddr(i.DHCP.IP.Gateway)
+		if err != nil {
+			return nil, nil, err
+		}
+		d.SetDefaultGateway(gw)
+	}
+
+	// name servers and time servers, optional
+	for _, s := range i.DHCP.NameServers {
+		if ip := net.ParseIP(s); ip != nil {
+			d.NameServers = append(d.NameServers, ip)
+		}
+	}
+	for _, s := range i.DHCP.TimeServers {
+		if ip := net.ParseIP(s); ip != nil {
+			d.NTPServers = append(d.NTPServers, ip)
+		}
+	}
+
+	d.Hostname = i.DHCP.Hostname
+	d.LeaseTime = i.DHCP.LeaseTime
+	d.Arch = i.DHCP.Arch
+	d.VLANID = i.DHCP.VLANID
+
+	// netboot
+	n.AllowNetboot = i.Netboot.AllowPXE
+	if i.Netboot.IPXE.URL != "" {
+		if n.IPXEScriptURL, err = url.ParseRequestURI(i.Netboot.IPXE.URL); err != nil {
+			return nil, nil, err
+		}
+	}
+	n.IPXEScript = i.Netboot.IPXE.Contents
+	if i.Netboot.OSIE.BaseURL != "" {
+		if n.OSIE.BaseURL, err = url.Parse(i.Netboot.OSIE.BaseURL); err != nil {
+			return nil, nil, err
+		}
+	}
+	n.OSIE.Kernel = i.Netboot.OSIE.Kernel
+	n.OSIE.Initrd = i.Netboot.OSIE.Initrd
+	n.Facility = m.Metadata.Facility.FacilityCode
+
+	return d, n, nil
+}
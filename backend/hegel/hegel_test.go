@@ -0,0 +1,156 @@
+package hegel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+const document = `{
+	"id": "0eba0bf8-3772-4b4a-ab9f-6ebe93b90a94",
+	"metadata": {"facility": {"facility_code": "onprem"}},
+	"network": {"interfaces": [{
+		"dhcp": {
+			"mac": "3c:ec:ef:4c:4f:54",
+			"ip": {"address": "192.168.2.150", "netmask": "255.255.255.0", "gateway": "192.168.2.1"},
+			"hostname": "sm01",
+			"lease_time": 86400,
+			"name_servers": ["1.1.1.1"],
+			"time_servers": ["132.163.96.2"],
+			"arch": "x86_64"
+		},
+		"netboot": {
+			"allow_pxe": true,
+			"ipxe": {"url": "http://boot.example.com/auto.ipxe"},
+			"osie": {"base_url": "http://osie.example.com", "kernel": "vmlinuz", "initrd": "initramfs"}
+		}
+	}]}
+}`
+
+var (
+	mac      = net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54}
+	wantDHCP = &data.DHCP{
+		MACAddress:      mac,
+		IPAddress:       netip.MustParseAddr("192.168.2.150"),
+		SubnetMask:      net.IPv4Mask(255, 255, 255, 0),
+		DefaultGateways: []netip.Addr{netip.MustParseAddr("192.168.2.1")},
+		NameServers:     []net.IP{net.ParseIP("1.1.1.1")},
+		NTPServers:      []net.IP{net.ParseIP("132.163.96.2")},
+		Hostname:        "sm01",
+		LeaseTime:       86400,
+		Arch:            "x86_64",
+	}
+	wantNetboot = &data.Netboot{
+		AllowNetboot:  true,
+		IPXEScriptURL: &url.URL{Scheme: "http", Host: "boot.example.com", Path: "/auto.ipxe"},
+		Facility:      "onprem",
+		OSIE:          data.OSIE{BaseURL: &url.URL{Scheme: "http", Host: "osie.example.com"}, Kernel: "vmlinuz", Initrd: "initramfs"},
+	}
+)
+
+// newBackend returns a Backend for a fake Hegel that serves the document for 192.168.2.150 at /metadata,
+// using X-Forwarded-For, and for 3c:ec:ef:4c:4f:54 at /by-mac/<mac>.
+func newBackend(t *testing.T, status int) *Backend {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/metadata" && r.Header.Get("X-Forwarded-For") == "192.168.2.150",
+			r.URL.Path == "/by-mac/3c:ec:ef:4c:4f:54":
+			w.WriteHeader(status)
+			fmt.Fprint(w, document)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewBackend(logr.Discard(), u, s.URL+"/by-mac/{mac}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestNewBackend(t *testing.T) {
+	if _, err := NewBackend(logr.Discard(), &url.URL{}, "http://proxy/metadata"); !errors.Is(err, errMACPlaceholder) {
+		t.Fatalf("NewBackend() error = %v, wantErr %v", err, errMACPlaceholder)
+	}
+}
+
+func TestGetByMac(t *testing.T) {
+	tests := map[string]struct {
+		mac     net.HardwareAddr
+		status  int
+		noURL   bool
+		wantErr error
+	}{
+		"success":    {mac: mac, status: http.StatusOK},
+		"not found":  {mac: net.HardwareAddr{0, 0, 0, 0, 0, 1}, status: http.StatusOK, wantErr: data.HardwareNotFoundError{}},
+		"bad status": {mac: mac, status: http.StatusInternalServerError, wantErr: errStatus},
+		"no mac url": {mac: mac, status: http.StatusOK, noURL: true, wantErr: errNoMACURL},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := newBackend(t, tt.status)
+			if tt.noURL {
+				b.MACURL = ""
+			}
+			d, n, err := b.GetByMac(context.Background(), tt.mac)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if diff := cmp.Diff(d, wantDHCP, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(n, wantNetboot); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestGetByIP(t *testing.T) {
+	tests := map[string]struct {
+		ip      net.IP
+		wantErr error
+	}{
+		"success":   {ip: net.IPv4(192, 168, 2, 150)},
+		"not found": {ip: net.IPv4(192, 168, 2, 151), wantErr: data.HardwareNotFoundError{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := newBackend(t, http.StatusOK)
+			d, _, err := b.GetByIP(context.Background(), tt.ip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if diff := cmp.Diff(d, wantDHCP, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+			if d.IPAddress != wantDHCP.IPAddress {
+				t.Errorf("got IP address %v, want %v", d.IPAddress, wantDHCP.IPAddress)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package isc
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/tinkerbell/dhcp/backend/static"
+)
+
+// decl is a dhcpd.conf declaration: the global scope, or a block like a subnet, group, or host declaration.
+type decl struct {
+	// kind is the first word of the declaration, e.g. "subnet", empty for the global scope.
+	kind string
+	// args are the words of the declaration after kind, e.g. the name of a host.
+	args []string
+	// params are the statements in the block that end with ";", in any order as they apply to the whole block.
+	params [][]string
+	// children are the declarations in the block.
+	children []*decl
+}
+
+// ParseDhcpd returns the fixed address host reservations in an ISC dhcpd.conf file.
+// Hosts without a hardware ethernet address or a fixed-address are skipped, and include statements are not followed.
+// Hosts declared outside of a subnet declaration get the options of the subnet their fixed-address is in.
+func ParseDhcpd(b []byte) ([]static.Record, error) {
+	tokens, err := tokenize(string(b))
+	if err != nil {
+		return nil, err
+	}
+	root := &decl{}
+	if rest, err := parseBlock(root, tokens); err != nil {
+		return nil, err
+	} else if rest != nil {
+		return nil, fmt.Errorf("%w: unexpected }", errSyntax)
+	}
+
+	// the scopes that hosts and subnets are declared in, from the global scope to the declaration itself.
+	var hosts, subnets [][]*decl
+	var walk func(d *decl, chain []*decl)
+	walk = func(d *decl, chain []*decl) {
+		chain = append(chain[:len(chain):len(chain)], d)
+		switch d.kind {
+		case "", "group", "shared-network":
+		case "subnet":
+			subnets = append(subnets, chain)
+		case "host":
+			hosts = append(hosts, chain)
+			return
+		default:
+			// classes, pools, conditionals, failover peers, and other blocks do not declare fixed address hosts.
+			return
+		}
+		for _, c := range d.children {
+			walk(c, chain)
+		}
+	}
+	walk(root, nil)
+
+	var r []host
+	for _, chain := range hosts {
+		h, ok, err := dhcpdHost(chain, subnets)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			r = append(r, h)
+		}
+	}
+
+	return records(r)
+}
+
+// dhcpdHost returns the host for the host declaration at the end of chain.
+// ok is false if the host does not have a hardware ethernet address or a fixed-address.
+func dhcpdHost(chain []*decl, subnets [][]*decl) (h host, ok bool, err error) {
+	d := chain[len(chain)-1]
+	if len(d.args) > 0 {
+		h.name = d.args[0]
+	}
+	for _, p := range d.params {
+		switch {
+		case len(p) == 3 && p[0] == "hardware" && p[1] == "ethernet":
+			if h.mac, err = net.ParseMAC(p[2]); err != nil {
+				return host{}, false, fmt.Errorf("host %v: %w", h.name, err)
+			}
+		case len(p) >= 2 && p[0] == "fixed-address":
+			// only the first address of a list is used.
+			if h.ip, err = netip.ParseAddr(p[1]); err != nil {
+				return host{}, false, fmt.Errorf("host %v: %w: fixed-address must be an IP address: %w", h.name, errUnsupported, err)
+			}
+		}
+	}
+	if h.mac == nil || !h.ip.IsValid() {
+		return host{}, false, nil
+	}
+
+	// a host outside of a subnet declaration is in the subnet its address is in.
+	inSubnet := false
+	for _, c := range chain {
+		inSubnet = inSubnet || c.kind == "subnet"
+	}
+	if !inSubnet {
+		for _, s := range subnets {
+			if p, err := subnetPrefix(s[len(s)-1]); err == nil && p.Contains(h.ip) {
+				chain = append(s[:len(s):len(s)], chain[1:]...)
+				break
+			}
+		}
+	}
+
+	h.options = options{}
+	useHostDeclNames := false
+	var next, file string
+	for _, c := range chain {
+		if c.kind == "subnet" {
+			p, err := subnetPrefix(c)
+			if err != nil {
+				return host{}, false, err
+			}
+			h.mask = net.CIDRMask(p.Bits(), 32)
+		}
+		for _, p := range c.params {
+			switch {
+			case len(p) >= 2 && p[0] == "option":
+				h.options[p[1]] = p[2:]
+			case len(p) == 2 && p[0] == "default-lease-time":
+				lt, err := strconv.ParseUint(p[1], 10, 32)
+				if err != nil {
+					return host{}, false, fmt.Errorf("%w: default-lease-time %q", errSyntax, p[1])
+				}
+				h.leaseTime = uint32(lt)
+			case len(p) == 2 && p[0] == "use-host-decl-names":
+				useHostDeclNames = p[1] == "on" || p[1] == "true"
+			case len(p) == 2 && p[0] == "next-server":
+				next = p[1]
+			case len(p) == 2 && p[0] == "filename":
+				file = p[1]
+			}
+		}
+	}
+	if _, ok := h.options["host-name"]; !ok && useHostDeclNames && h.name != "" {
+		h.options["host-name"] = []string{h.name}
+	}
+	if _, ok := h.options["tftp-server-name"]; !ok && next != "" {
+		h.options["tftp-server-name"] = []string{next}
+	}
+	if _, ok := h.options["bootfile-name"]; !ok && file != "" {
+		h.options["bootfile-name"] = []string{file}
+	}
+
+	return h, true, nil
+}
+
+// subnetPrefix returns the network of a "subnet <network> netmask <mask>" declaration.
+func subnetPrefix(d *decl) (netip.Prefix, error) {
+	if len(d.args) != 3 || d.args[1] != "netmask" {
+		return netip.Prefix{}, fmt.Errorf("%w: subnet %q", errSyntax, strings.Join(d.args, " "))
+	}
+	ip, err := netip.ParseAddr(d.args[0])
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%w: subnet %v: %w", errSyntax, d.args[0], err)
+	}
+	m := net.ParseIP(d.args[2]).To4()
+	if m == nil {
+		return netip.Prefix{}, fmt.Errorf("%w: subnet %v: invalid netmask %q", errSyntax, d.args[0], d.args[2])
+	}
+	ones, _ := net.IPMask(m).Size()
+
+	return netip.PrefixFrom(ip, ones), nil
+}
+
+// parseBlock adds the statements in tokens to d until the "}" that ends the block, and returns the tokens after it.
+func parseBlock(d *decl, tokens []string) ([]string, error) {
+	var stmt []string
+	for len(tokens) > 0 {
+		t := tokens[0]
+		tokens = tokens[1:]
+		switch t {
+		case ";":
+			if len(stmt) > 0 {
+				d.params = append(d.params, stmt)
+			}
+			stmt = nil
+		case "{":
+			if len(stmt) == 0 {
+				return nil, fmt.Errorf("%w: block without a declaration", errSyntax)
+			}
+			c := &decl{kind: stmt[0], args: stmt[1:]}
+			var err error
+			if tokens, err = parseBlock(c, tokens); err != nil {
+				return nil, err
+			}
+			if tokens == nil {
+				return nil, fmt.Errorf("%w: missing } after %v", errSyntax, strings.Join(stmt, " "))
+			}
+			d.children = append(d.children, c)
+			stmt = nil
+		case "}":
+			if len(stmt) > 0 {
+				return nil, fmt.Errorf("%w: missing ; after %v", errSyntax, strings.Join(stmt, " "))
+			}
+			return tokens, nil
+		case ",":
+		default:
+			stmt = append(stmt, t)
+		}
+	}
+	if len(stmt) > 0 {
+		return nil, fmt.Errorf("%w: missing ; after %v", errSyntax, strings.Join(stmt, " "))
+	}
+
+	return nil, nil
+}
+
+// tokenize splits a dhcpd.conf file into words, quoted strings without their quotes, and the
+// punctuation "{", "}", ";", and ",". Comments, from "#" to the end of the line, are removed.
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '{' || c == '}' || c == ';' || c == ',':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated string", errSyntax)
+			}
+			tokens = append(tokens, s[i+1:i+1+end])
+			i += end + 2
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\r\n#{};,\"", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+
+	return tokens, nil
+}
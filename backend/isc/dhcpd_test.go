@@ -0,0 +1,117 @@
+package isc
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tinkerbell/dhcp/backend/static"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+const dhcpdConf = `
+# global parameters
+option domain-name "example.com";
+option domain-name-servers 8.8.8.8, 1.1.1.1;
+default-lease-time 600;
+use-host-decl-names on;
+
+subnet 192.168.1.0 netmask 255.255.255.0 {
+	range 192.168.1.100 192.168.1.200;
+	host node-01 {
+		hardware ethernet 00:11:22:33:44:55;
+		fixed-address 192.168.1.10;
+		filename "undionly.kpxe"; # chainload iPXE
+		next-server 192.168.1.2;
+	}
+	group {
+		option domain-name "lab.example.com";
+		host node-02 {
+			hardware ethernet 00:11:22:33:44:56;
+			fixed-address 192.168.1.11, 192.168.1.12;
+			option host-name "second";
+		}
+	}
+	# parameters apply to the whole scope, also to hosts declared before them.
+	option routers 192.168.1.1;
+}
+
+class "pxe" {
+	match if substring (option vendor-class-identifier, 0, 9) = "PXEClient";
+}
+
+# a global host gets the options of the subnet its address is in.
+host node-03 {
+	hardware ethernet 00:11:22:33:44:57;
+	fixed-address 192.168.1.13;
+	default-lease-time 3600;
+}
+
+host dynamic {
+	hardware ethernet 00:11:22:33:44:58;
+}
+`
+
+func TestParseDhcpd(t *testing.T) {
+	got, err := ParseDhcpd([]byte(dhcpdConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := data.DHCP{
+		SubnetMask:      net.IPv4Mask(255, 255, 255, 0),
+		DefaultGateways: []netip.Addr{netip.MustParseAddr("192.168.1.1")},
+		NameServers:     []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("1.1.1.1")},
+		DomainName:      "example.com",
+		LeaseTime:       600,
+	}
+	node1, node2, node3 := base, base, base
+	node1.MACAddress = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	node1.IPAddress = netip.MustParseAddr("192.168.1.10")
+	node1.Hostname = "node-01"
+	node1.BootFileName = "undionly.kpxe"
+	node1.TFTPServerName = "192.168.1.2"
+	node2.MACAddress = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x56}
+	node2.IPAddress = netip.MustParseAddr("192.168.1.11")
+	node2.Hostname = "second"
+	node2.DomainName = "lab.example.com"
+	node3.MACAddress = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x57}
+	node3.IPAddress = netip.MustParseAddr("192.168.1.13")
+	node3.Hostname = "node-03"
+	node3.LeaseTime = 3600
+	want := []static.Record{{DHCP: node1}, {DHCP: node2}, {DHCP: node3}}
+
+	if diff := cmp.Diff(got, want, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+		t.Fatal(diff)
+	}
+	for i := range want {
+		if got[i].DHCP.IPAddress != want[i].DHCP.IPAddress || got[i].DHCP.DefaultGateway() != want[i].DHCP.DefaultGateway() {
+			t.Errorf("record %d: got %v, %v, want %v, %v", i, got[i].DHCP.IPAddress, got[i].DHCP.DefaultGateway(), want[i].DHCP.IPAddress, want[i].DHCP.DefaultGateway())
+		}
+	}
+}
+
+func TestParseDhcpdErrors(t *testing.T) {
+	tests := map[string]struct {
+		conf    string
+		wantErr error
+	}{
+		"missing semicolon":      {conf: "option domain-name \"example.com\"\n", wantErr: errSyntax},
+		"missing brace":          {conf: "subnet 192.168.1.0 netmask 255.255.255.0 {\n", wantErr: errSyntax},
+		"extra brace":            {conf: "}\n", wantErr: errSyntax},
+		"unterminated string":    {conf: "option domain-name \"example.com;\n", wantErr: errSyntax},
+		"hostname address":       {conf: "host a { hardware ethernet 00:11:22:33:44:55; fixed-address a.example.com; }", wantErr: errUnsupported},
+		"bad option":             {conf: "host a { hardware ethernet 00:11:22:33:44:55; fixed-address 192.168.1.10; option routers gw; }", wantErr: errOption},
+		"bad subnet declaration": {conf: "subnet 192.168.1.0 { host a { hardware ethernet 00:11:22:33:44:55; fixed-address 192.168.1.10; } }", wantErr: errSyntax},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseDhcpd([]byte(tt.conf))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseDhcpd() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+// Package isc imports host reservations from the configuration of a legacy ISC DHCP server,
+// either an ISC dhcpd.conf file or a Kea DHCPv4 JSON configuration file, to allow a drop-in migration.
+//
+// The reservations are served by a static backend. Only fixed address host reservations are imported:
+// dynamic pools, classes, and conditional statements are ignored. Options set in enclosing scopes
+// (global, shared-network, subnet, and group) are inherited by the hosts in them, as they are by dhcpd and Kea.
+//
+// The following options are imported: subnet-mask, routers, domain-name-servers, host-name, domain-name,
+// interface-mtu, broadcast-address, ntp-servers, tftp-server-name, bootfile-name, and domain-search.
+// The lease time, boot file name, and next server of a host are also imported.
+// Netboot is not enabled for imported hosts, their boot file name and next server are served as is.
+package isc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/dhcp/backend/static"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+// Errors used when importing a configuration.
+var (
+	errSyntax      = errors.New("syntax error")
+	errOption      = errors.New("invalid option value")
+	errUnsupported = errors.New("unsupported configuration")
+)
+
+// Format is the format of a configuration file.
+type Format string
+
+// Supported configuration formats.
+const (
+	// FormatAuto detects the format from the file extension, .json is Kea and anything else is dhcpd.
+	FormatAuto Format = ""
+	// FormatDhcpd is an ISC dhcpd.conf file.
+	FormatDhcpd Format = "dhcpd"
+	// FormatKea is a Kea DHCPv4 JSON configuration file.
+	FormatKea Format = "kea"
+)
+
+// NewBackend reads the host reservations in the configuration file at path and returns a static backend that serves them.
+func NewBackend(l logr.Logger, path string, f Format) (*static.Backend, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	if f == FormatAuto {
+		f = FormatDhcpd
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			f = FormatKea
+		}
+	}
+
+	var records []static.Record
+	switch f {
+	case FormatDhcpd:
+		records, err = ParseDhcpd(b)
+	case FormatKea:
+		records, err = ParseKea(b)
+	default:
+		return nil, fmt.Errorf("unknown format %q", f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	l.Info("imported host reservations", "file", path, "format", f, "hosts", len(records))
+
+	return static.New(static.WithRecords(records...))
+}
+
+// options are DHCP options by name, in the order of their values.
+type options map[string][]string
+
+// merge returns the options of o overridden by the options of other.
+func (o options) merge(other options) options {
+	r := make(options, len(o)+len(other))
+	for k, v := range o {
+		r[k] = v
+	}
+	for k, v := range other {
+		r[k] = v
+	}
+
+	return r
+}
+
+// host is a host reservation with all the options that apply to it.
+type host struct {
+	name      string
+	mac       net.HardwareAddr
+	ip        netip.Addr
+	leaseTime uint32
+	options   options
+	// mask is the subnet mask of the subnet the host is in, used when there is no subnet-mask option.
+	mask net.IPMask
+}
+
+// record converts h into a static.Record.
+func (h host) record() (static.Record, error) {
+	d := data.DHCP{MACAddress: h.mac, IPAddress: h.ip, SubnetMask: h.mask, LeaseTime: h.leaseTime}
+	for name, v := range h.options {
+		if err := setOption(&d, name, v); err != nil {
+			return static.Record{}, fmt.Errorf("host %v: option %v: %w", h.name, name, err)
+		}
+	}
+
+	return static.Record{DHCP: d}, nil
+}
+
+// setOption sets the option name to the values v in d. Options that are not supported are ignored.
+func setOption(d *data.DHCP, name string, v []string) error {
+	if len(v) == 0 {
+		// an option without a value is only an error if the option is supported.
+		v = []string{""}
+	}
+	var err error
+	switch name {
+	case "subnet-mask":
+		ip := net.ParseIP(v[0]).To4()
+		if ip == nil {
+			return fmt.Errorf("%w: %q", errOption, v[0])
+		}
+		d.SubnetMask = net.IPMask(ip)
+	case "routers":
+		d.DefaultGateways, err = parseAddrs(v)
+	case "broadcast-address":
+		d.BroadcastAddress, err = netip.ParseAddr(v[0])
+	case "domain-name-servers":
+		d.NameServers, err = parseIPs(v)
+	case "ntp-servers":
+		d.NTPServers, err = parseIPs(v)
+	case "host-name":
+		d.Hostname = v[0]
+	case "domain-name":
+		d.DomainName = v[0]
+	case "domain-search":
+		d.DomainSearch = v
+	case "interface-mtu":
+		var mtu uint64
+		mtu, err = strconv.ParseUint(v[0], 10, 16)
+		d.MTU = uint16(mtu)
+	case "tftp-server-name":
+		d.TFTPServerName = v[0]
+	case "bootfile-name":
+		d.BootFileName = v[0]
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", errOption, err)
+	}
+
+	return nil
+}
+
+// parseIPs parses a list of IP addresses.
+func parseIPs(v []string) ([]net.IP, error) {
+	r := make([]net.IP, 0, len(v))
+	for _, s := range v {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", s)
+		}
+		r = append(r, ip)
+	}
+
+	return r, nil
+}
+
+// parseAddrs parses a list of IP addresses.
+func parseAddrs(v []string) ([]netip.Addr, error) {
+	r := make([]netip.Addr, 0, len(v))
+	for _, s := range v {
+		a, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, a)
+	}
+
+	return r, nil
+}
+
+// records converts hosts into static.Records.
+func records(hosts []host) ([]static.Record, error) {
+	r := make([]static.Record, 0, len(hosts))
+	for _, h := range hosts {
+		rec, err := h.record()
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, rec)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,49 @@
+package isc
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestNewBackend(t *testing.T) {
+	tests := map[string]struct {
+		file     string
+		contents string
+		format   Format
+		wantErr  bool
+	}{
+		"dhcpd":             {file: "dhcpd.conf", contents: dhcpdConf},
+		"kea":               {file: "kea-dhcp4.json", contents: keaConf},
+		"explicit format":   {file: "kea.conf", contents: keaConf, format: FormatKea},
+		"wrong format":      {file: "kea.conf", contents: keaConf, wantErr: true},
+		"unknown format":    {file: "dhcpd.conf", contents: dhcpdConf, format: "xml", wantErr: true},
+		"duplicate address": {file: "dhcpd.conf", contents: "host a { hardware ethernet 00:11:22:33:44:55; fixed-address 192.168.1.10; }\nhost b { hardware ethernet 00:11:22:33:44:55; fixed-address 192.168.1.11; }", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.contents), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			b, err := NewBackend(logr.Discard(), path, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewBackend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			d, _, err := b.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Hostname != "node-01" {
+				t.Fatalf("GetByMac() hostname = %q, want node-01", d.Hostname)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package isc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/tinkerbell/dhcp/backend/static"
+)
+
+// keaOptionNames are the names of the options that can be imported, by option code.
+var keaOptionNames = map[int]string{
+	1:   "subnet-mask",
+	3:   "routers",
+	6:   "domain-name-servers",
+	12:  "host-name",
+	15:  "domain-name",
+	26:  "interface-mtu",
+	28:  "broadcast-address",
+	42:  "ntp-servers",
+	66:  "tftp-server-name",
+	67:  "bootfile-name",
+	119: "domain-search",
+}
+
+// keaScope holds the parameters that Kea inherits from the global scope to shared networks, subnets, and reservations.
+type keaScope struct {
+	OptionData    []keaOption `json:"option-data"`
+	ValidLifetime *uint32     `json:"valid-lifetime"`
+	NextServer    string      `json:"next-server"`
+	BootFileName  string      `json:"boot-file-name"`
+}
+
+type keaOption struct {
+	Name string `json:"name"`
+	Code int    `json:"code"`
+	Data string `json:"data"`
+}
+
+type keaReservation struct {
+	keaScope
+	HWAddress string `json:"hw-address"`
+	IPAddress string `json:"ip-address"`
+	Hostname  string `json:"hostname"`
+}
+
+type keaSubnet struct {
+	keaScope
+	Subnet       string           `json:"subnet"`
+	Reservations []keaReservation `json:"reservations"`
+}
+
+type keaConfig struct {
+	Dhcp4 struct {
+		keaScope
+		Reservations   []keaReservation `json:"reservations"`
+		Subnet4        []keaSubnet      `json:"subnet4"`
+		SharedNetworks []struct {
+			keaScope
+			Subnet4 []keaSubnet `json:"subnet4"`
+		} `json:"shared-networks"`
+	} `json:"Dhcp4"`
+}
+
+// resolved is a keaScope with the parameters inherited from its parent scopes.
+type resolved struct {
+	options   options
+	leaseTime uint32
+}
+
+// apply returns r with the parameters of s applied.
+func (r resolved) apply(s keaScope) resolved {
+	o := options{}
+	for _, od := range s.OptionData {
+		name := od.Name
+		if name == "" {
+			name = keaOptionNames[od.Code]
+		}
+		if name == "" {
+			continue
+		}
+		var v []string
+		for _, f := range strings.Split(od.Data, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				v = append(v, f)
+			}
+		}
+		o[name] = v
+	}
+	if s.NextServer != "" {
+		o["tftp-server-name"] = []string{s.NextServer}
+	}
+	if s.BootFileName != "" {
+		o["bootfile-name"] = []string{s.BootFileName}
+	}
+	c := resolved{options: r.options.merge(o), leaseTime: r.leaseTime}
+	if s.ValidLifetime != nil {
+		c.leaseTime = *s.ValidLifetime
+	}
+
+	return c
+}
+
+// ParseKea returns the host reservations in a Kea DHCPv4 JSON configuration file.
+// Comments, which Kea allows in its configuration files, are removed before the file is parsed.
+// Reservations that are not identified by hw-address or that do not have an ip-address are skipped.
+// Global reservations get the options of the subnet their ip-address is in.
+func ParseKea(b []byte) ([]static.Record, error) {
+	var c keaConfig
+	if err := json.Unmarshal(stripComments(b), &c); err != nil {
+		return nil, fmt.Errorf("%w: %w", errSyntax, err)
+	}
+	global := resolved{options: options{}}.apply(c.Dhcp4.keaScope)
+
+	type subnet struct {
+		prefix netip.Prefix
+		scope  resolved
+		res    []keaReservation
+	}
+	var subnets []subnet
+	add := func(parent resolved, ss []keaSubnet) error {
+		for _, s := range ss {
+			p, err := netip.ParsePrefix(s.Subnet)
+			if err != nil {
+				return fmt.Errorf("%w: subnet %q: %w", errSyntax, s.Subnet, err)
+			}
+			subnets = append(subnets, subnet{prefix: p, scope: parent.apply(s.keaScope), res: s.Reservations})
+		}
+		return nil
+	}
+	if err := add(global, c.Dhcp4.Subnet4); err != nil {
+		return nil, err
+	}
+	for _, sn := range c.Dhcp4.SharedNetworks {
+		if err := add(global.apply(sn.keaScope), sn.Subnet4); err != nil {
+			return nil, err
+		}
+	}
+
+	var hosts []host
+	addHost := func(s resolved, p netip.Prefix, r keaReservation) error {
+		if r.HWAddress == "" || r.IPAddress == "" {
+			return nil
+		}
+		mac, err := net.ParseMAC(r.HWAddress)
+		if err != nil {
+			return fmt.Errorf("reservation %v: %w", r.HWAddress, err)
+		}
+		ip, err := netip.ParseAddr(r.IPAddress)
+		if err != nil {
+			return fmt.Errorf("reservation %v: %w", r.HWAddress, err)
+		}
+		h := s.apply(r.keaScope)
+		if r.Hostname != "" {
+			h.options["host-name"] = []string{r.Hostname}
+		}
+		hs := host{name: r.HWAddress, mac: mac, ip: ip, leaseTime: h.leaseTime, options: h.options}
+		if p.IsValid() && ip.Is4() {
+			hs.mask = net.CIDRMask(p.Bits(), 32)
+		}
+		hosts = append(hosts, hs)
+
+		return nil
+	}
+	for _, s := range subnets {
+		for _, r := range s.res {
+			if err := addHost(s.scope, s.prefix, r); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, r := range c.Dhcp4.Reservations {
+		s, p := global, netip.Prefix{}
+		if ip, err := netip.ParseAddr(r.IPAddress); err == nil {
+			for _, sn := range subnets {
+				if sn.prefix.Contains(ip) {
+					s, p = sn.scope, sn.prefix
+					break
+				}
+			}
+		}
+		if err := addHost(s, p, r); err != nil {
+			return nil, err
+		}
+	}
+
+	return records(hosts)
+}
+
+// stripComments removes the "//", "/* */", and "#" comments that Kea allows in JSON configuration files.
+// Comment characters in strings are kept.
+func stripComments(b []byte) []byte {
+	r := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		switch {
+		case b[i] == '"':
+			// copy the string, including escaped quotes.
+			j := i + 1
+			for j < len(b) && b[j] != '"' {
+				if b[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			end := j + 1
+			if end > len(b) {
+				end = len(b)
+			}
+			r = append(r, b[i:end]...)
+			i = end - 1
+		case b[i] == '#' || b[i] == '/' && i+1 < len(b) && b[i+1] == '/':
+			for i < len(b) && b[i] != '\n' {
+				i++
+			}
+			if i < len(b) {
+				r = append(r, '\n')
+			}
+		case b[i] == '/' && i+1 < len(b) && b[i+1] == '*':
+			end := strings.Index(string(b[i+2:]), "*/")
+			if end < 0 {
+				return r
+			}
+			i += end + 3
+		default:
+			r = append(r, b[i])
+		}
+	}
+
+	return r
+}
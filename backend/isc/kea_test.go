@@ -0,0 +1,109 @@
+package isc
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tinkerbell/dhcp/backend/static"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+const keaConf = `{
+"Dhcp4": {
+	// global parameters
+	"valid-lifetime": 4000,
+	"option-data": [
+		{"name": "domain-name-servers", "data": "8.8.8.8, 1.1.1.1"},
+		{"code": 15, "data": "example.com"}
+	],
+	"subnet4": [{
+		"subnet": "192.168.1.0/24",
+		"option-data": [{"name": "routers", "data": "192.168.1.1"}],
+		"reservations": [
+			{"hw-address": "00:11:22:33:44:55", "ip-address": "192.168.1.10", "hostname": "node-01", "boot-file-name": "undionly.kpxe", "next-server": "192.168.1.2"},
+			/* reservations by client id are not imported */
+			{"client-id": "01:00:11:22:33:44:99", "ip-address": "192.168.1.99"}
+		]
+	}],
+	"shared-networks": [{
+		"name": "lab",
+		"valid-lifetime": 600,
+		"subnet4": [{"subnet": "10.0.0.0/16", "option-data": [{"name": "routers", "data": "10.0.0.1"}]}]
+	}],
+	# a global reservation gets the options of the subnet its address is in.
+	"reservations": [
+		{"hw-address": "00:11:22:33:44:56", "ip-address": "10.0.1.5", "option-data": [{"name": "domain-name", "data": "lab.example.com"}]}
+	]
+}
+}`
+
+func TestParseKea(t *testing.T) {
+	got, err := ParseKea([]byte(keaConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []static.Record{
+		{DHCP: data.DHCP{
+			MACAddress:      net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+			IPAddress:       netip.MustParseAddr("192.168.1.10"),
+			SubnetMask:      net.IPv4Mask(255, 255, 255, 0),
+			DefaultGateways: []netip.Addr{netip.MustParseAddr("192.168.1.1")},
+			NameServers:     []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("1.1.1.1")},
+			Hostname:        "node-01",
+			DomainName:      "example.com",
+			LeaseTime:       4000,
+			TFTPServerName:  "192.168.1.2",
+			BootFileName:    "undionly.kpxe",
+		}},
+		{DHCP: data.DHCP{
+			MACAddress:      net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x56},
+			IPAddress:       netip.MustParseAddr("10.0.1.5"),
+			SubnetMask:      net.IPv4Mask(255, 255, 0, 0),
+			DefaultGateways: []netip.Addr{netip.MustParseAddr("10.0.0.1")},
+			NameServers:     []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("1.1.1.1")},
+			DomainName:      "lab.example.com",
+			LeaseTime:       600,
+		}},
+	}
+	if diff := cmp.Diff(got, want, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+		t.Fatal(diff)
+	}
+	for i := range want {
+		if got[i].DHCP.IPAddress != want[i].DHCP.IPAddress || got[i].DHCP.DefaultGateway() != want[i].DHCP.DefaultGateway() {
+			t.Errorf("record %d: got %v, %v, want %v, %v", i, got[i].DHCP.IPAddress, got[i].DHCP.DefaultGateway(), want[i].DHCP.IPAddress, want[i].DHCP.DefaultGateway())
+		}
+	}
+}
+
+func TestParseKeaErrors(t *testing.T) {
+	tests := map[string]struct {
+		conf    string
+		wantErr error
+	}{
+		"not json":   {conf: "Dhcp4 {", wantErr: errSyntax},
+		"bad subnet": {conf: `{"Dhcp4": {"subnet4": [{"subnet": "192.168.1.0"}]}}`, wantErr: errSyntax},
+		"bad option": {
+			conf:    `{"Dhcp4": {"reservations": [{"hw-address": "00:11:22:33:44:55", "ip-address": "192.168.1.10", "option-data": [{"name": "routers", "data": "gw"}]}]}}`,
+			wantErr: errOption,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseKea([]byte(tt.conf)); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseKea() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	in := "{\n// comment\n\"a\": \"http://x/#y\", # comment\n/* multi\nline */\"b\": \"\\\"//\"\n}"
+	want := "{\n\n\"a\": \"http://x/#y\", \n\"b\": \"\\\"//\"\n}"
+	if got := string(stripComments([]byte(in))); got != want {
+		t.Fatalf("stripComments() = %q, want %q", got, want)
+	}
+}
@@ -1,4 +1,4 @@
-// Package kube is a backend implementation that uses the Tinkerbell CRDs to get DHCP data.
+// Package kube is a backend implementation that uses the Tinkerbell CRDs, or any custom resource described by a Mapping, to get DHCP data.
 package kube
 
 import (
@@ -8,23 +8,61 @@ import (
 	"net"
 	"net/netip"
 	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/redact"
 	"github.com/tinkerbell/tink/api/v1alpha1"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/cluster"
 )
 
 const tracerName = "github.com/tinkerbell/dhcp"
 
+// Default values for the live API fallback rate limit.
+const (
+	DefaultFallbackRate  = 1
+	DefaultFallbackBurst = 5
+)
+
+var (
+	// errFallbackRateLimited is returned when a live API fallback lookup is not done because of the rate limit.
+	errFallbackRateLimited = errors.New("live API lookup rate limited")
+	// errCacheNotSynced is returned by Healthy when the Hardware cache has not synced.
+	errCacheNotSynced = errors.New("hardware cache not synced")
+)
+
 // Backend is a backend implementation that uses the Tinkerbell CRDs to get DHCP data.
 type Backend struct {
+	// LiveFallback enables a direct, uncached, API lookup when the cache has no Hardware for a MAC or IP address.
+	// This covers the window after a Hardware object is created and before the cache receives it.
+	LiveFallback bool
+
+	// FallbackLimiter rate limits the live API fallback lookups. Each lookup lists all Hardware objects in scope
+	// from the API server, so lookups are limited to avoid a thundering herd, for example when many unknown machines are powered on.
+	// NewBackend sets it to DefaultFallbackRate per second with a burst of DefaultFallbackBurst. A nil FallbackLimiter does not rate limit.
+	FallbackLimiter *rate.Limiter
+
 	cluster cluster.Cluster
+	// apiReader reads directly from the API server.
+	apiReader client.Reader
+	// listOpts limit the live API fallback lookups to the namespace and labels of the cache.
+	listOpts []client.ListOption
+	// synced is set once the Hardware cache has synced.
+	synced atomic.Bool
 }
 
 // NewBackend returns a controller-runtime cluster.Cluster with the Tinkerbell runtime
@@ -32,6 +70,8 @@ type Backend struct {
 // * Hardware by MAC address
 // * Hardware by IP address
 //
+// WithNamespace and WithLabelSelector can be passed in opts to limit the Hardware objects that are cached.
+//
 // Callers must instantiate the client-side cache by calling Start() before use.
 func NewBackend(conf *rest.Config, opts ...cluster.Option) (*Backend, error) {
 	rs := runtime.NewScheme()
@@ -60,7 +100,71 @@ func NewBackend(conf *rest.Config, opts ...cluster.Option) (*Backend, error) {
 		return nil, fmt.Errorf("failed to setup indexer(.spec.interfaces.dhcp.ip.address): %w", err)
 	}
 
-	return &Backend{cluster: c}, nil
+	return &Backend{
+		FallbackLimiter: rate.NewLimiter(DefaultFallbackRate, DefaultFallbackBurst),
+		cluster:         c,
+		apiReader:       c.GetAPIReader(),
+		listOpts:        hardwareListOptions(opts),
+	}, nil
+}
+
+// hardwareListOptions returns the list options that limit a List of Hardware to the namespace and labels
+// set by WithNamespace and WithLabelSelector in opts.
+func hardwareListOptions(opts []cluster.Option) []client.ListOption {
+	o := &cluster.Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	var r []client.ListOption
+	for k, v := range o.Cache.ByObject {
+		if _, ok := k.(*v1alpha1.Hardware); !ok {
+			continue
+		}
+		for ns := range v.Namespaces {
+			r = append(r, client.InNamespace(ns))
+		}
+		if v.Label != nil {
+			r = append(r, client.MatchingLabelsSelector{Selector: v.Label})
+		}
+	}
+
+	return r
+}
+
+// WithNamespace restricts the Hardware cache and watch to namespace.
+// By default Hardware objects in all namespaces are cached.
+func WithNamespace(namespace string) cluster.Option {
+	return func(o *cluster.Options) {
+		hardwareByObject(o, func(bo *cache.ByObject) {
+			bo.Namespaces = map[string]cache.Config{namespace: {}}
+		})
+	}
+}
+
+// WithLabelSelector restricts the Hardware cache and watch to Hardware objects that match selector.
+func WithLabelSelector(selector labels.Selector) cluster.Option {
+	return func(o *cluster.Options) {
+		hardwareByObject(o, func(bo *cache.ByObject) {
+			bo.Label = selector
+		})
+	}
+}
+
+// hardwareByObject calls fn with the Hardware cache options in o, adding them if they do not exist.
+func hardwareByObject(o *cluster.Options, fn func(*cache.ByObject)) {
+	if o.Cache.ByObject == nil {
+		o.Cache.ByObject = make(map[client.Object]cache.ByObject)
+	}
+	for k, v := range o.Cache.ByObject {
+		if _, ok := k.(*v1alpha1.Hardware); ok {
+			fn(&v)
+			o.Cache.ByObject[k] = v
+			return
+		}
+	}
+	var bo cache.ByObject
+	fn(&bo)
+	o.Cache.ByObject[&v1alpha1.Hardware{}] = bo
 }
 
 // Start starts the client-side cache.
@@ -68,6 +172,34 @@ func (b *Backend) Start(ctx context.Context) error {
 	return b.cluster.Start(ctx)
 }
 
+// WaitForCacheSync blocks until the Hardware cache has synced or ctx is done.
+// It returns false if the cache could not be synced. Start must be running for the cache to sync.
+// Until the cache has synced, lookups return a not found error for Hardware that exists.
+func (b *Backend) WaitForCacheSync(ctx context.Context) bool {
+	if b.synced.Load() {
+		return true
+	}
+	if _, err := b.cluster.GetCache().GetInformer(ctx, &v1alpha1.Hardware{}); err != nil {
+		return false
+	}
+	if !b.cluster.GetCache().WaitForCacheSync(ctx) {
+		return false
+	}
+	b.synced.Store(true)
+
+	return true
+}
+
+// Healthy implements the handler.HealthChecker interface. The backend is healthy once the Hardware cache has synced.
+// Until then Healthy waits for the cache to sync for as long as ctx allows.
+func (b *Backend) Healthy(ctx context.Context) error {
+	if !b.WaitForCacheSync(ctx) {
+		return errCacheNotSynced
+	}
+
+	return nil
+}
+
 // GetByMac implements the handler.BackendReader interface and returns DHCP and netboot data based on a mac address.
 func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
 	tracer := otel.Tracer(tracerName)
@@ -76,21 +208,30 @@ func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHC
 	hardwareList := &v1alpha1.HardwareList{}
 
 	if err := b.cluster.GetClient().List(ctx, hardwareList, &client.MatchingFields{MACAddrIndex: mac.String()}); err != nil {
-		span.SetStatus(codes.Error, err.Error())
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 		return nil, nil, fmt.Errorf("failed listing hardware for (%v): %w", mac, err)
 	}
 
+	if len(hardwareList.Items) == 0 && b.LiveFallback {
+		items, err := b.liveLookup(ctx, MACAddrs, mac.String())
+		if err != nil {
+			span.AddEvent("live API fallback failed", trace.WithAttributes(attribute.String("error", redact.FromContext(ctx).Error(err))))
+		}
+		hardwareList.Items = items
+	}
+	hardwareList.Items = enabled(hardwareList.Items)
+
 	if len(hardwareList.Items) == 0 {
-		err := hardwareNotFoundError{}
-		span.SetStatus(codes.Error, err.Error())
+		err := data.HardwareNotFoundError{}
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 		return nil, nil, err
 	}
 
 	if len(hardwareList.Items) > 1 {
-		err := fmt.Errorf("got %d hardware objects for mac %s, expected only 1", len(hardwareList.Items), mac)
-		span.SetStatus(codes.Error, err.Error())
+		err := fmt.Errorf("%w: got %d hardware objects for mac %s, expected only 1", data.ErrInvalidRecord, len(hardwareList.Items), mac)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 		return nil, nil, err
 	}
@@ -105,20 +246,22 @@ func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHC
 
 	d, err := toDHCPData(i.DHCP)
 	if err != nil {
-		err = fmt.Errorf("failed to convert hardware to DHCP data: %w", err)
-		span.SetStatus(codes.Error, err.Error())
+		err = fmt.Errorf("%w: failed to convert hardware to DHCP data: %w", data.ErrInvalidRecord, err)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 		return nil, nil, err
 	}
 	n, err := toNetbootData(i.Netboot)
 	if err != nil {
-		err = fmt.Errorf("failed to convert hardware to netboot data: %w", err)
-		span.SetStatus(codes.Error, err.Error())
+		err = fmt.Errorf("%w: failed to convert hardware to netboot data: %w", data.ErrInvalidRecord, err)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 		return nil, nil, err
 	}
+	n.Facility = facility(&hardwareList.Items[0])
+	n.IPXEBinary = hardwareList.Items[0].GetAnnotations()[AnnotationIPXEBinary]
 
-	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
 	span.SetAttributes(n.EncodeToAttributes()...)
 	span.SetStatus(codes.Ok, "")
 
@@ -133,21 +276,30 @@ func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Net
 	hardwareList := &v1alpha1.HardwareList{}
 
 	if err := b.cluster.GetClient().List(ctx, hardwareList, &client.MatchingFields{IPAddrIndex: ip.String()}); err != nil {
-		span.SetStatus(codes.Error, err.Error())
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 		return nil, nil, fmt.Errorf("failed listing hardware for (%v): %w", ip, err)
 	}
 
+	if len(hardwareList.Items) == 0 && b.LiveFallback {
+		items, err := b.liveLookup(ctx, IPAddrs, ip.String())
+		if err != nil {
+			span.AddEvent("live API fallback failed", trace.WithAttributes(attribute.String("error", redact.FromContext(ctx).Error(err))))
+		}
+		hardwareList.Items = items
+	}
+	hardwareList.Items = enabled(hardwareList.Items)
+
 	if len(hardwareList.Items) == 0 {
-		err := hardwareNotFoundError{}
-		span.SetStatus(codes.Error, err.Error())
+		err := data.HardwareNotFoundError{}
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 		return nil, nil, err
 	}
 
 	if len(hardwareList.Items) > 1 {
-		err := fmt.Errorf("got %d hardware objects for ip: %s, expected only 1", len(hardwareList.Items), ip)
-		span.SetStatus(codes.Error, err.Error())
+		err := fmt.Errorf("%w: got %d hardware objects for ip: %s, expected only 1", data.ErrInvalidRecord, len(hardwareList.Items), ip)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 		return nil, nil, err
 	}
@@ -162,26 +314,193 @@ func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Net
 
 	d, err := toDHCPData(i.DHCP)
 	if err != nil {
-		err = fmt.Errorf("failed to convert hardware to DHCP data: %w", err)
-		span.SetStatus(codes.Error, err.Error())
+		err = fmt.Errorf("%w: failed to convert hardware to DHCP data: %w", data.ErrInvalidRecord, err)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 		return nil, nil, err
 	}
 	n, err := toNetbootData(i.Netboot)
 	if err != nil {
-		err = fmt.Errorf("failed to convert hardware to netboot data: %w", err)
-		span.SetStatus(codes.Error, err.Error())
+		err = fmt.Errorf("%w: failed to convert hardware to netboot data: %w", data.ErrInvalidRecord, err)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
 
 		return nil, nil, err
 	}
+	n.Facility = facility(&hardwareList.Items[0])
+	n.IPXEBinary = hardwareList.Items[0].GetAnnotations()[AnnotationIPXEBinary]
 
-	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
 	span.SetAttributes(n.EncodeToAttributes()...)
 	span.SetStatus(codes.Ok, "")
 
 	return d, n, nil
 }
 
+// AnnotationDisabled is the annotation that quarantines a Hardware object from DHCP and netboot.
+// When its value is "true", lookups return a not found error for the Hardware without the record having to be deleted.
+const AnnotationDisabled = "dhcp.tinkerbell.org/disabled"
+
+// AnnotationIPXEBinary is the annotation that pins a Hardware object to an iPXE binary, for example a debug build of snp.efi,
+// instead of the binary that is chosen based on the client architecture.
+const AnnotationIPXEBinary = "dhcp.tinkerbell.org/ipxe-binary"
+
+// disabled returns true if obj has the AnnotationDisabled annotation set to true.
+func disabled(obj metav1.Object) bool {
+	v, err := strconv.ParseBool(obj.GetAnnotations()[AnnotationDisabled])
+
+	return err == nil && v
+}
+
+// enabled returns the Hardware in hw that is not disabled.
+func enabled(hw []v1alpha1.Hardware) []v1alpha1.Hardware {
+	var r []v1alpha1.Hardware
+	for i := range hw {
+		if !disabled(&hw[i]) {
+			r = append(r, hw[i])
+		}
+	}
+
+	return r
+}
+
+// Annotations that RecordAck, RecordRelease, and RecordDecline write to the Hardware object of a client.
+const (
+	// AnnotationLastAck is the time, in RFC 3339 format, of the last DHCP ACK sent to the client.
+	AnnotationLastAck = "dhcp.tinkerbell.org/last-ack"
+	// AnnotationIPAddress is the IP address assigned in the last DHCP ACK.
+	AnnotationIPAddress = "dhcp.tinkerbell.org/ip-address"
+	// AnnotationBootFile is the bootfile served in the last DHCP ACK.
+	AnnotationBootFile = "dhcp.tinkerbell.org/bootfile"
+	// AnnotationLastRelease is the time, in RFC 3339 format, of the last DHCP RELEASE received from the client.
+	AnnotationLastRelease = "dhcp.tinkerbell.org/last-release"
+	// AnnotationLastDecline is the time, in RFC 3339 format, of the last DHCP DECLINE received from the client.
+	AnnotationLastDecline = "dhcp.tinkerbell.org/last-decline"
+	// AnnotationDeclinedIPAddress is the IP address the client declined in the last DHCP DECLINE.
+	AnnotationDeclinedIPAddress = "dhcp.tinkerbell.org/declined-ip-address"
+)
+
+// RecordAck patches the annotations of the Hardware object for mac with the time of the ACK,
+// the assigned IP address, and the bootfile served. This gives operators visibility into which
+// machines actually received a DHCP lease without scraping logs. An empty bootfile removes the bootfile annotation.
+//
+// RecordAck implements the handler.BackendWriter interface.
+func (b *Backend) RecordAck(ctx context.Context, mac net.HardwareAddr, ip netip.Addr, bootfile string) error {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.kube.RecordAck")
+	defer span.End()
+
+	err := b.patchAnnotations(ctx, mac, func(a map[string]string) {
+		a[AnnotationLastAck] = time.Now().UTC().Format(time.RFC3339)
+		a[AnnotationIPAddress] = ip.String()
+		if bootfile != "" {
+			a[AnnotationBootFile] = bootfile
+		} else {
+			delete(a, AnnotationBootFile)
+		}
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return err
+	}
+	span.SetStatus(codes.Ok, "")
+
+	return nil
+}
+
+// RecordRelease patches the annotations of the Hardware object for mac with the time of the RELEASE.
+//
+// RecordRelease implements the handler.BackendWriter interface.
+func (b *Backend) RecordRelease(ctx context.Context, mac net.HardwareAddr, _ netip.Addr) error {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.kube.RecordRelease")
+	defer span.End()
+
+	err := b.patchAnnotations(ctx, mac, func(a map[string]string) {
+		a[AnnotationLastRelease] = time.Now().UTC().Format(time.RFC3339)
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return err
+	}
+	span.SetStatus(codes.Ok, "")
+
+	return nil
+}
+
+// RecordDecline patches the annotations of the Hardware object for mac with the time of the DECLINE
+// and the declined IP address. A decline usually means that another host is using the IP address.
+//
+// RecordDecline implements the handler.BackendWriter interface.
+func (b *Backend) RecordDecline(ctx context.Context, mac net.HardwareAddr, ip netip.Addr) error {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.kube.RecordDecline")
+	defer span.End()
+
+	err := b.patchAnnotations(ctx, mac, func(a map[string]string) {
+		a[AnnotationLastDecline] = time.Now().UTC().Format(time.RFC3339)
+		a[AnnotationDeclinedIPAddress] = ip.String()
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return err
+	}
+	span.SetStatus(codes.Ok, "")
+
+	return nil
+}
+
+// patchAnnotations merge patches the annotations of the Hardware object for mac, as updated by fn.
+func (b *Backend) patchAnnotations(ctx context.Context, mac net.HardwareAddr, fn func(map[string]string)) error {
+	hardwareList := &v1alpha1.HardwareList{}
+	if err := b.cluster.GetClient().List(ctx, hardwareList, &client.MatchingFields{MACAddrIndex: mac.String()}); err != nil {
+		return fmt.Errorf("failed listing hardware for (%v): %w", mac, err)
+	}
+	if len(hardwareList.Items) == 0 {
+		return data.HardwareNotFoundError{}
+	}
+	if len(hardwareList.Items) > 1 {
+		return fmt.Errorf("got %d hardware objects for mac %s, expected only 1", len(hardwareList.Items), mac)
+	}
+
+	hw := hardwareList.Items[0].DeepCopy()
+	patch := client.MergeFrom(hardwareList.Items[0].DeepCopy())
+	if hw.Annotations == nil {
+		hw.Annotations = make(map[string]string)
+	}
+	fn(hw.Annotations)
+	if err := b.cluster.GetClient().Patch(ctx, hw, patch); err != nil {
+		return fmt.Errorf("failed patching hardware %s/%s: %w", hw.Namespace, hw.Name, err)
+	}
+
+	return nil
+}
+
+// liveLookup lists Hardware directly from the API server, bypassing the cache,
+// and returns the Hardware objects for which index returns value.
+func (b *Backend) liveLookup(ctx context.Context, index client.IndexerFunc, value string) ([]v1alpha1.Hardware, error) {
+	if b.FallbackLimiter != nil && !b.FallbackLimiter.Allow() {
+		return nil, errFallbackRateLimited
+	}
+	hardwareList := &v1alpha1.HardwareList{}
+	if err := b.apiReader.List(ctx, hardwareList, b.listOpts...); err != nil {
+		return nil, err
+	}
+	var r []v1alpha1.Hardware
+	for i := range hardwareList.Items {
+		for _, v := range index(&hardwareList.Items[i]) {
+			if v == value {
+				r = append(r, hardwareList.Items[i])
+				break
+			}
+		}
+	}
+
+	return r, nil
+}
+
 // toDHCPData converts a v1alpha1.DHCP to a data.DHCP data structure.
 // if required fields are missing, an error is returned.
 // Required fields: v1alpha1.Interface.DHCP.MAC, v1alpha1.Interface.DHCP.IP.Address, v1alpha1.Interface.DHCP.IP.Netmask.
@@ -189,58 +508,71 @@ func toDHCPData(h *v1alpha1.DHCP) (*data.DHCP, error) {
 	if h == nil {
 		return nil, errors.New("no DHCP data")
 	}
-	d := new(data.DHCP)
 
-	var err error
 	// MACAddress is required
-	if d.MACAddress, err = net.ParseMAC(h.MAC); err != nil {
+	mac, err := net.ParseMAC(h.MAC)
+	if err != nil {
 		return nil, err
 	}
 
-	if h.IP != nil {
-		// IPAddress is required
-		if d.IPAddress, err = netip.ParseAddr(h.IP.Address); err != nil {
-			return nil, err
-		}
-		// Netmask is required
-		sm := net.ParseIP(h.IP.Netmask)
-		if sm == nil {
-			return nil, errors.New("no netmask")
-		}
-		d.SubnetMask = net.IPMask(sm.To4())
-	} else {
+	if h.IP == nil {
 		return nil, errors.New("no IP data")
 	}
+	// IPAddress is required
+	ip, err := netip.ParseAddr(h.IP.Address)
+	if err != nil {
+		return nil, err
+	}
+	// Netmask is required. The broadcast address is derived from the IP address and netmask.
+	sm := net.ParseIP(h.IP.Netmask)
+	if sm == nil {
+		return nil, errors.New("no netmask")
+	}
 
+	var opts []data.DHCPOption
 	// Gateway is optional, but should be a valid IP address if present
 	if h.IP.Gateway != "" {
-		if d.DefaultGateway, err = netip.ParseAddr(h.IP.Gateway); err != nil {
+		gw, err := netip.ParseAddr(h.IP.Gateway)
+		if err != nil {
 			return nil, err
 		}
+		opts = append(opts, data.WithDefaultGateways(gw))
 	}
 
 	// name servers, optional
+	var ns []net.IP
 	for _, s := range h.NameServers {
 		ip := net.ParseIP(s)
 		if ip == nil {
 			break
 		}
-		d.NameServers = append(d.NameServers, ip)
+		ns = append(ns, ip)
 	}
 
-	// hostname, optional
-	d.Hostname = h.Hostname
-
-	// lease time required
-	d.LeaseTime = uint32(h.LeaseTime)
+	// time servers, optional
+	var ntp []net.IP
+	for _, s := range h.TimeServers {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			break
+		}
+		ntp = append(ntp, ip)
+	}
 
-	// arch
-	d.Arch = h.Arch
+	// lease time, data.DefaultLeaseTime when not set
+	if h.LeaseTime > 0 {
+		opts = append(opts, data.WithLeaseTime(uint32(h.LeaseTime)))
+	}
 
-	// vlanid
-	d.VLANID = h.VLANID
+	opts = append(opts,
+		data.WithNameServers(ns...),
+		data.WithNTPServers(ntp...),
+		data.WithHostname(h.Hostname),
+		data.WithArch(h.Arch),
+		data.WithVLANID(h.VLANID),
+	)
 
-	return d, nil
+	return data.NewDHCP(mac, ip, net.IPMask(sm.To4()), opts...)
 }
 
 // toNetbootData converts a hardware interface to a data.Netboot data structure.
@@ -248,12 +580,7 @@ func toNetbootData(i *v1alpha1.Netboot) (*data.Netboot, error) {
 	if i == nil {
 		return nil, errors.New("no netboot data")
 	}
-	n := new(data.Netboot)
-
-	// allow machine to netboot
-	if i.AllowPXE != nil {
-		n.AllowNetboot = *i.AllowPXE
-	}
+	var opts []data.NetbootOption
 
 	// ipxe script url is optional but if provided, it must be a valid url
 	if i.IPXE != nil {
@@ -262,20 +589,33 @@ func toNetbootData(i *v1alpha1.Netboot) (*data.Netboot, error) {
 			if err != nil {
 				return nil, err
 			}
-			n.IPXEScriptURL = u
+			opts = append(opts, data.WithIPXEScriptURL(u))
 		}
+		opts = append(opts, data.WithIPXEScript(i.IPXE.Contents))
 	}
 
-	// ipxescript
-	if i.IPXE != nil {
-		n.IPXEScript = i.IPXE.Contents
+	// osie, optional but if the base url is provided, it must be a valid url
+	if i.OSIE != nil {
+		o := data.OSIE{Kernel: i.OSIE.Kernel, Initrd: i.OSIE.Initrd}
+		if i.OSIE.BaseURL != "" {
+			u, err := url.ParseRequestURI(i.OSIE.BaseURL)
+			if err != nil {
+				return nil, err
+			}
+			o.BaseURL = u
+		}
+		opts = append(opts, data.WithOSIE(o))
 	}
 
-	// console
-	n.Console = ""
+	// allow machine to netboot
+	return data.NewNetboot(i.AllowPXE != nil && *i.AllowPXE, opts...)
+}
 
-	// facility
-	n.Facility = ""
+// facility returns the facility code from the metadata of h.
+func facility(h *v1alpha1.Hardware) string {
+	if h.Spec.Metadata == nil || h.Spec.Metadata.Facility == nil {
+		return ""
+	}
 
-	return n, nil
+	return h.Spec.Metadata.Facility.FacilityCode
 }
@@ -2,18 +2,22 @@ package kube
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"net/http"
 	"net/netip"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/tinkerbell/dhcp/data"
 	"github.com/tinkerbell/tink/api/v1alpha1"
+	"golang.org/x/time/rate"
 	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -58,6 +62,81 @@ func TestNewBackend(t *testing.T) {
 	}
 }
 
+func TestCacheOptions(t *testing.T) {
+	sel := labels.SelectorFromSet(labels.Set{"env": "lab"})
+	tests := map[string]struct {
+		opts          []cluster.Option
+		wantNamespace map[string]cache.Config
+		wantLabel     labels.Selector
+	}{
+		"namespace":            {opts: []cluster.Option{WithNamespace("tink-system")}, wantNamespace: map[string]cache.Config{"tink-system": {}}},
+		"label selector":       {opts: []cluster.Option{WithLabelSelector(sel)}, wantLabel: sel},
+		"namespace and labels": {opts: []cluster.Option{WithNamespace("tink-system"), WithLabelSelector(sel)}, wantNamespace: map[string]cache.Config{"tink-system": {}}, wantLabel: sel},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := &cluster.Options{}
+			for _, opt := range tt.opts {
+				opt(o)
+			}
+			if len(o.Cache.ByObject) != 1 {
+				t.Fatalf("got %d cache ByObject entries, want 1", len(o.Cache.ByObject))
+			}
+			for k, v := range o.Cache.ByObject {
+				if _, ok := k.(*v1alpha1.Hardware); !ok {
+					t.Fatalf("got cache options for %T, want *v1alpha1.Hardware", k)
+				}
+				if diff := cmp.Diff(v.Namespaces, tt.wantNamespace); diff != "" {
+					t.Error(diff)
+				}
+				if fmt.Sprint(v.Label) != fmt.Sprint(tt.wantLabel) {
+					t.Errorf("got label selector %v, want %v", v.Label, tt.wantLabel)
+				}
+			}
+		})
+	}
+}
+
+func TestWaitForCacheSync(t *testing.T) {
+	tests := map[string]struct {
+		synced bool
+	}{
+		"synced":     {synced: true},
+		"not synced": {synced: false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rs := runtime.NewScheme()
+			if err := v1alpha1.AddToScheme(rs); err != nil {
+				t.Fatal(err)
+			}
+			cl := fake.NewClientBuilder().WithScheme(rs).Build()
+			synced := tt.synced
+			fn := func(o *cluster.Options) {
+				o.NewClient = func(config *rest.Config, options client.Options) (client.Client, error) {
+					return cl, nil
+				}
+				o.MapperProvider = func(_ *rest.Config, _ *http.Client) (meta.RESTMapper, error) {
+					return cl.RESTMapper(), nil
+				}
+				o.NewCache = func(config *rest.Config, options cache.Options) (cache.Cache, error) {
+					return &informertest.FakeInformers{Scheme: rs, Synced: &synced}, nil
+				}
+			}
+			b, err := NewBackend(new(rest.Config), fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := b.WaitForCacheSync(context.Background()); got != tt.synced {
+				t.Errorf("WaitForCacheSync() = %v, want %v", got, tt.synced)
+			}
+			if err := b.Healthy(context.Background()); (err == nil) != tt.synced {
+				t.Errorf("Healthy() error = %v, want healthy = %v", err, tt.synced)
+			}
+		})
+	}
+}
+
 func TestToDHCPData(t *testing.T) {
 	tests := map[string]struct {
 		in        *v1alpha1.DHCP
@@ -103,11 +182,13 @@ func TestToDHCPData(t *testing.T) {
 				},
 			},
 			want: &data.DHCP{
-				SubnetMask:     net.IPv4Mask(255, 255, 0, 0),
-				DefaultGateway: netip.MustParseAddr("192.168.2.1"),
-				NameServers:    []net.IP{net.IPv4(1, 1, 1, 1)},
-				IPAddress:      netip.MustParseAddr("192.168.2.4"),
-				MACAddress:     net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x04},
+				SubnetMask:       net.IPv4Mask(255, 255, 0, 0),
+				DefaultGateways:  []netip.Addr{netip.MustParseAddr("192.168.2.1")},
+				NameServers:      []net.IP{net.IPv4(1, 1, 1, 1)},
+				IPAddress:        netip.MustParseAddr("192.168.2.4"),
+				BroadcastAddress: netip.MustParseAddr("192.168.255.255"),
+				LeaseTime:        data.DefaultLeaseTime,
+				MACAddress:       net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x04},
 			},
 		},
 		"full": {
@@ -116,6 +197,7 @@ func TestToDHCPData(t *testing.T) {
 				Hostname:    "test",
 				LeaseTime:   3600,
 				NameServers: []string{"1.1.1.1"},
+				TimeServers: []string{"132.163.96.2"},
 				IP: &v1alpha1.IP{
 					Address: "192.168.1.4",
 					Netmask: "255.255.255.0",
@@ -123,13 +205,15 @@ func TestToDHCPData(t *testing.T) {
 				},
 			},
 			want: &data.DHCP{
-				SubnetMask:     net.IPv4Mask(255, 255, 255, 0),
-				DefaultGateway: netip.MustParseAddr("192.168.1.1"),
-				NameServers:    []net.IP{net.IPv4(1, 1, 1, 1)},
-				Hostname:       "test",
-				LeaseTime:      3600,
-				IPAddress:      netip.MustParseAddr("192.168.1.4"),
-				MACAddress:     net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x04},
+				SubnetMask:       net.IPv4Mask(255, 255, 255, 0),
+				DefaultGateways:  []netip.Addr{netip.MustParseAddr("192.168.1.1")},
+				NameServers:      []net.IP{net.IPv4(1, 1, 1, 1)},
+				NTPServers:       []net.IP{net.IPv4(132, 163, 96, 2)},
+				Hostname:         "test",
+				LeaseTime:        3600,
+				IPAddress:        netip.MustParseAddr("192.168.1.4"),
+				BroadcastAddress: netip.MustParseAddr("192.168.1.255"),
+				MACAddress:       net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x04},
 			},
 		},
 	}
@@ -142,6 +226,9 @@ func TestToDHCPData(t *testing.T) {
 			if diff := cmp.Diff(got, tt.want, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
 				t.Fatal(diff)
 			}
+			if got != nil && got.BroadcastAddress != tt.want.BroadcastAddress {
+				t.Fatalf("got broadcast address %v, want %v", got.BroadcastAddress, tt.want.BroadcastAddress)
+			}
 		})
 	}
 }
@@ -155,6 +242,23 @@ func TestToNetbootData(t *testing.T) {
 		"nil input":    {in: nil, shouldErr: true},
 		"bad ipxe url": {in: &v1alpha1.Netboot{IPXE: &v1alpha1.IPXE{URL: "bad"}}, shouldErr: true},
 		"successful":   {in: &v1alpha1.Netboot{IPXE: &v1alpha1.IPXE{URL: "http://example.com/ipxe.ipxe"}}, want: &data.Netboot{IPXEScriptURL: &url.URL{Scheme: "http", Host: "example.com", Path: "/ipxe.ipxe"}}},
+		"bad osie url": {in: &v1alpha1.Netboot{OSIE: &v1alpha1.OSIE{BaseURL: "bad"}}, shouldErr: true},
+		"ipxe contents and osie": {
+			in: &v1alpha1.Netboot{
+				AllowPXE: &[]bool{true}[0],
+				IPXE:     &v1alpha1.IPXE{Contents: "#!ipxe\nautoboot"},
+				OSIE:     &v1alpha1.OSIE{BaseURL: "http://example.com/osie", Kernel: "vmlinuz-x86_64", Initrd: "initramfs-x86_64"},
+			},
+			want: &data.Netboot{
+				AllowNetboot: true,
+				IPXEScript:   "#!ipxe\nautoboot",
+				OSIE: data.OSIE{
+					BaseURL: &url.URL{Scheme: "http", Host: "example.com", Path: "/osie"},
+					Kernel:  "vmlinuz-x86_64",
+					Initrd:  "initramfs-x86_64",
+				},
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -169,6 +273,27 @@ func TestToNetbootData(t *testing.T) {
 	}
 }
 
+func TestFacility(t *testing.T) {
+	tests := map[string]struct {
+		in   *v1alpha1.Hardware
+		want string
+	}{
+		"no metadata": {in: &v1alpha1.Hardware{}},
+		"no facility": {in: &v1alpha1.Hardware{Spec: v1alpha1.HardwareSpec{Metadata: &v1alpha1.HardwareMetadata{}}}},
+		"facility code": {
+			in:   &v1alpha1.Hardware{Spec: v1alpha1.HardwareSpec{Metadata: &v1alpha1.HardwareMetadata{Facility: &v1alpha1.MetadataFacility{FacilityCode: "onprem"}}}},
+			want: "onprem",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := facility(tt.in); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGetByIP(t *testing.T) {
 	tests := map[string]struct {
 		hwObject    []v1alpha1.Hardware
@@ -183,10 +308,10 @@ func TestGetByIP(t *testing.T) {
 		"bad netboot data":       {shouldErr: true, hwObject: []v1alpha1.Hardware{badNetbootObject2}},
 		"fail to list hardware":  {shouldErr: true, failToList: true},
 		"good data": {hwObject: []v1alpha1.Hardware{hwObject1}, wantDHCP: &data.DHCP{
-			MACAddress:     net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54},
-			IPAddress:      netip.MustParseAddr("172.16.10.100"),
-			SubnetMask:     []byte{0xff, 0xff, 0xff, 0x00},
-			DefaultGateway: netip.MustParseAddr("255.255.255.0"),
+			MACAddress:      net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54},
+			IPAddress:       netip.MustParseAddr("172.16.10.100"),
+			SubnetMask:      []byte{0xff, 0xff, 0xff, 0x00},
+			DefaultGateways: []netip.Addr{netip.MustParseAddr("255.255.255.0")},
 			NameServers: []net.IP{
 				{0x1, 0x1, 0x1, 0x1},
 			},
@@ -280,10 +405,10 @@ func TestGetByMac(t *testing.T) {
 		"bad netboot data":       {shouldErr: true, hwObject: []v1alpha1.Hardware{badNetbootObject}},
 		"fail to list hardware":  {shouldErr: true, failToList: true},
 		"good data": {hwObject: []v1alpha1.Hardware{hwObject1}, wantDHCP: &data.DHCP{
-			MACAddress:     net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54},
-			IPAddress:      netip.MustParseAddr("172.16.10.100"),
-			SubnetMask:     []byte{0xff, 0xff, 0xff, 0x00},
-			DefaultGateway: netip.MustParseAddr("255.255.255.0"),
+			MACAddress:      net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54},
+			IPAddress:       netip.MustParseAddr("172.16.10.100"),
+			SubnetMask:      []byte{0xff, 0xff, 0xff, 0x00},
+			DefaultGateways: []netip.Addr{netip.MustParseAddr("255.255.255.0")},
 			NameServers: []net.IP{
 				{0x1, 0x1, 0x1, 0x1},
 			},
@@ -576,3 +701,221 @@ var badNetbootObject2 = v1alpha1.Hardware{
 		},
 	},
 }
+
+func TestLiveFallback(t *testing.T) {
+	tests := map[string]struct {
+		liveFallback bool
+		limiter      *rate.Limiter
+		apiObjects   []v1alpha1.Hardware
+		wantFound    bool
+	}{
+		"found in api":          {liveFallback: true, apiObjects: []v1alpha1.Hardware{hwObject1}, wantFound: true},
+		"fallback disabled":     {apiObjects: []v1alpha1.Hardware{hwObject1}},
+		"not found in api":      {liveFallback: true},
+		"rate limited":          {liveFallback: true, limiter: rate.NewLimiter(0, 0), apiObjects: []v1alpha1.Hardware{hwObject1}},
+		"other hardware in api": {liveFallback: true, apiObjects: []v1alpha1.Hardware{hwObject2}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rs := runtime.NewScheme()
+			if err := v1alpha1.AddToScheme(rs); err != nil {
+				t.Fatal(err)
+			}
+			cl := fake.NewClientBuilder().WithScheme(rs).
+				WithIndex(&v1alpha1.Hardware{}, MACAddrIndex, MACAddrs).
+				WithIndex(&v1alpha1.Hardware{}, IPAddrIndex, IPAddrs).
+				Build()
+			fn := func(o *cluster.Options) {
+				o.NewClient = func(config *rest.Config, options client.Options) (client.Client, error) {
+					return cl, nil
+				}
+				o.MapperProvider = func(_ *rest.Config, _ *http.Client) (meta.RESTMapper, error) {
+					return cl.RESTMapper(), nil
+				}
+				o.NewCache = func(config *rest.Config, options cache.Options) (cache.Cache, error) {
+					return &informertest.FakeInformers{Scheme: rs}, nil
+				}
+			}
+			b, err := NewBackend(new(rest.Config), fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b.LiveFallback = tt.liveFallback
+			if tt.limiter != nil {
+				b.FallbackLimiter = tt.limiter
+			}
+			b.apiReader = fake.NewClientBuilder().WithScheme(rs).WithLists(&v1alpha1.HardwareList{Items: tt.apiObjects}).Build()
+
+			_, _, err = b.GetByMac(context.Background(), net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54})
+			if got := err == nil; got != tt.wantFound {
+				t.Errorf("GetByMac() error = %v, want found %v", err, tt.wantFound)
+			}
+			_, _, err = b.GetByIP(context.Background(), net.IPv4(172, 16, 10, 100))
+			if got := err == nil; got != tt.wantFound {
+				t.Errorf("GetByIP() error = %v, want found %v", err, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestHardwareListOptions(t *testing.T) {
+	got := hardwareListOptions([]cluster.Option{WithNamespace("tink-system"), WithLabelSelector(labels.SelectorFromSet(labels.Set{"env": "lab"}))})
+	lo := &client.ListOptions{}
+	lo.ApplyOptions(got)
+	if lo.Namespace != "tink-system" {
+		t.Errorf("got namespace %q, want %q", lo.Namespace, "tink-system")
+	}
+	if lo.LabelSelector == nil || lo.LabelSelector.String() != "env=lab" {
+		t.Errorf("got label selector %v, want %v", lo.LabelSelector, "env=lab")
+	}
+}
+
+func TestRecordAck(t *testing.T) {
+	tests := map[string]struct {
+		hwObject     []v1alpha1.Hardware
+		bootfile     string
+		wantBootfile string
+		wantErr      bool
+	}{
+		"annotations written": {hwObject: []v1alpha1.Hardware{hwObject1}, bootfile: "ipxe.efi", wantBootfile: "ipxe.efi"},
+		"no bootfile":         {hwObject: []v1alpha1.Hardware{hwObject1}},
+		"no hardware":         {wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rs := runtime.NewScheme()
+			if err := v1alpha1.AddToScheme(rs); err != nil {
+				t.Fatal(err)
+			}
+			cl := fake.NewClientBuilder().WithScheme(rs).
+				WithIndex(&v1alpha1.Hardware{}, MACAddrIndex, MACAddrs).
+				WithLists(&v1alpha1.HardwareList{Items: tt.hwObject}).
+				Build()
+			fn := func(o *cluster.Options) {
+				o.NewClient = func(config *rest.Config, options client.Options) (client.Client, error) {
+					return cl, nil
+				}
+				o.MapperProvider = func(_ *rest.Config, _ *http.Client) (meta.RESTMapper, error) {
+					return cl.RESTMapper(), nil
+				}
+				o.NewCache = func(config *rest.Config, options cache.Options) (cache.Cache, error) {
+					return &informertest.FakeInformers{Scheme: rs}, nil
+				}
+			}
+			b, err := NewBackend(new(rest.Config), fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mac := net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54}
+			err = b.RecordAck(context.Background(), mac, netip.MustParseAddr("172.16.10.100"), tt.bootfile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RecordAck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			hw := &v1alpha1.Hardware{}
+			if err := cl.Get(context.Background(), client.ObjectKeyFromObject(&tt.hwObject[0]), hw); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := time.Parse(time.RFC3339, hw.Annotations[AnnotationLastAck]); err != nil {
+				t.Errorf("got last ack %q, want an RFC 3339 time: %v", hw.Annotations[AnnotationLastAck], err)
+			}
+			if got := hw.Annotations[AnnotationIPAddress]; got != "172.16.10.100" {
+				t.Errorf("got ip address %q, want %q", got, "172.16.10.100")
+			}
+			if got := hw.Annotations[AnnotationBootFile]; got != tt.wantBootfile {
+				t.Errorf("got bootfile %q, want %q", got, tt.wantBootfile)
+			}
+		})
+	}
+}
+
+func TestRecordReleaseDecline(t *testing.T) {
+	rs := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(rs); err != nil {
+		t.Fatal(err)
+	}
+	cl := fake.NewClientBuilder().WithScheme(rs).
+		WithIndex(&v1alpha1.Hardware{}, MACAddrIndex, MACAddrs).
+		WithLists(&v1alpha1.HardwareList{Items: []v1alpha1.Hardware{hwObject1}}).
+		Build()
+	fn := func(o *cluster.Options) {
+		o.NewClient = func(config *rest.Config, options client.Options) (client.Client, error) {
+			return cl, nil
+		}
+		o.MapperProvider = func(_ *rest.Config, _ *http.Client) (meta.RESTMapper, error) {
+			return cl.RESTMapper(), nil
+		}
+		o.NewCache = func(config *rest.Config, options cache.Options) (cache.Cache, error) {
+			return &informertest.FakeInformers{Scheme: rs}, nil
+		}
+	}
+	b, err := NewBackend(new(rest.Config), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54}
+	ip := netip.MustParseAddr("172.16.10.100")
+	if err := b.RecordRelease(context.Background(), mac, ip); err != nil {
+		t.Fatalf("RecordRelease() error = %v", err)
+	}
+	if err := b.RecordDecline(context.Background(), mac, ip); err != nil {
+		t.Fatalf("RecordDecline() error = %v", err)
+	}
+	if err := b.RecordDecline(context.Background(), net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, ip); !hardwareNotFound(err) {
+		t.Fatalf("RecordDecline() error = %v, want not found", err)
+	}
+
+	hw := &v1alpha1.Hardware{}
+	if err := cl.Get(context.Background(), client.ObjectKeyFromObject(&hwObject1), hw); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []string{AnnotationLastRelease, AnnotationLastDecline} {
+		if _, err := time.Parse(time.RFC3339, hw.Annotations[a]); err != nil {
+			t.Errorf("got %v %q, want an RFC 3339 time: %v", a, hw.Annotations[a], err)
+		}
+	}
+	if got := hw.Annotations[AnnotationDeclinedIPAddress]; got != ip.String() {
+		t.Errorf("got declined ip address %q, want %q", got, ip)
+	}
+}
+
+func TestDisabledAnnotation(t *testing.T) {
+	tests := map[string]struct {
+		annotation string
+		wantFound  bool
+	}{
+		"disabled":         {annotation: "true", wantFound: false},
+		"explicit enabled": {annotation: "false", wantFound: true},
+		"not a bool":       {annotation: "yes please", wantFound: true},
+		"no annotation":    {wantFound: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			hw := *hwObject1.DeepCopy()
+			if tt.annotation != "" {
+				hw.Annotations = map[string]string{AnnotationDisabled: tt.annotation}
+			}
+			b := newFakeBackend(t, hw)
+			_, _, err := b.GetByMac(context.Background(), net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54})
+			if got := err == nil; got != tt.wantFound {
+				t.Errorf("GetByMac() error = %v, want found %v", err, tt.wantFound)
+			}
+			if !tt.wantFound && !hardwareNotFound(err) {
+				t.Errorf("GetByMac() error = %v, want a not found error", err)
+			}
+			_, _, err = b.GetByIP(context.Background(), net.IPv4(172, 16, 10, 100))
+			if got := err == nil; got != tt.wantFound {
+				t.Errorf("GetByIP() error = %v, want found %v", err, tt.wantFound)
+			}
+		})
+	}
+}
+
+// hardwareNotFound returns true if err is a not found error, matching how the reservation handler checks for one.
+func hardwareNotFound(err error) bool {
+	nf, ok := err.(interface{ NotFound() bool })
+
+	return ok && nf.NotFound()
+}
@@ -0,0 +1,354 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+// Indexes used by a MappedBackend.
+const (
+	mappedMACAddrIndex = ".mapping.mac"
+	mappedIPAddrIndex  = ".mapping.ip"
+)
+
+// Mapping describes how to read DHCP data from a custom resource.
+// Fields are dot separated paths into the object, for example "spec.network.mac".
+// A field that is empty is not read.
+type Mapping struct {
+	// GVK is the group, version, and kind of the custom resource. The list kind is GVK.Kind + "List".
+	GVK schema.GroupVersionKind
+
+	// MACAddress is the path to the MAC address of the machine. Required.
+	// The field can be a string or a list of strings.
+	MACAddress string
+	// IPAddress is the path to the IP address of the machine. Required.
+	IPAddress string
+	// SubnetMask is the path to the subnet mask, e.g. 255.255.255.0. Required.
+	SubnetMask string
+
+	DefaultGateway string // Path to the default gateway.
+	NameServers    string // Path to a list of name servers.
+	Hostname       string // Path to the hostname.
+	DomainName     string // Path to the domain name.
+	LeaseTime      string // Path to the lease time in seconds, an integer.
+	Arch           string // Path to the architecture.
+
+	AllowNetboot  string // Path to a boolean that allows the machine to netboot.
+	IPXEScriptURL string // Path to the iPXE script URL.
+	IPXEScript    string // Path to the iPXE script contents.
+	Console       string // Path to the serial console.
+	Facility      string // Path to the facility code.
+	KernelParams  string // Path to the extra kernel command line parameters.
+	IPXEBinary    string // Path to the iPXE binary that overrides the one chosen based on the client architecture.
+	OSIEBaseURL   string // Path to the URL that the OSIE kernel and initrd are relative to.
+	OSIEKernel    string // Path to the OSIE kernel file name.
+	OSIEInitrd    string // Path to the OSIE initrd file name.
+}
+
+// MappedBackend is a backend implementation that gets DHCP data from any custom resource using a Mapping.
+// It allows clusters that do not have the Tinkerbell CRDs to use their existing machine CRDs.
+type MappedBackend struct {
+	cluster cluster.Cluster
+	mapping Mapping
+}
+
+// NewMappedBackend returns a MappedBackend for the custom resource described by m, with indexers for
+// the MAC address and IP address fields of m.
+//
+// Callers must instantiate the client-side cache by calling Start() before use.
+func NewMappedBackend(conf *rest.Config, m Mapping, opts ...cluster.Option) (*MappedBackend, error) {
+	if m.GVK.Empty() || m.MACAddress == "" || m.IPAddress == "" || m.SubnetMask == "" {
+		return nil, errors.New("mapping requires a GVK, MACAddress, IPAddress, and SubnetMask")
+	}
+	rs := runtime.NewScheme()
+	if err := scheme.AddToScheme(rs); err != nil {
+		return nil, err
+	}
+
+	o := []cluster.Option{func(o *cluster.Options) {
+		o.Scheme = rs
+		// read the unstructured custom resources from the cache instead of the API server.
+		o.Client.Cache = &client.CacheOptions{Unstructured: true}
+	}}
+	o = append(o, opts...)
+	c, err := cluster.New(conf, o...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new cluster config: %w", err)
+	}
+
+	if err := c.GetFieldIndexer().IndexField(context.Background(), m.object(), mappedMACAddrIndex, m.macAddrs); err != nil {
+		return nil, fmt.Errorf("failed to setup indexer(%v): %w", m.MACAddress, err)
+	}
+
+	if err := c.GetFieldIndexer().IndexField(context.Background(), m.object(), mappedIPAddrIndex, m.ipAddrs); err != nil {
+		return nil, fmt.Errorf("failed to setup indexer(%v): %w", m.IPAddress, err)
+	}
+
+	return &MappedBackend{cluster: c, mapping: m}, nil
+}
+
+// Start starts the client-side cache.
+func (b *MappedBackend) Start(ctx context.Context) error {
+	return b.cluster.Start(ctx)
+}
+
+// GetByMac implements the handler.BackendReader interface. It reads the object whose mapped MAC address field holds
+// mac from the client-side cache, and translates its fields with the Mapping. The MAC address of the record is mac, also
+// when the field holds more than one. No object is a data.ErrNotFound error, and more than one object or fields that
+// do not translate is a data.ErrInvalidRecord error.
+func (b *MappedBackend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.kube.mapped.GetByMac")
+	defer span.End()
+
+	obj, err := b.get(ctx, mappedMACAddrIndex, mac.String())
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	d, n, err := b.mapping.translate(obj)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to convert %v to DHCP data: %w", data.ErrInvalidRecord, b.mapping.GVK.Kind, err)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	// The object can have more than one MAC address, the one that was looked up is the one to use.
+	d.MACAddress = mac
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface. It reads the object whose mapped IP address field holds ip
+// from the client-side cache like GetByMac.
+func (b *MappedBackend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.kube.mapped.GetByIP")
+	defer span.End()
+
+	obj, err := b.get(ctx, mappedIPAddrIndex, ip.String())
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	d, n, err := b.mapping.translate(obj)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to convert %v to DHCP data: %w", data.ErrInvalidRecord, b.mapping.GVK.Kind, err)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// get returns the single object for which the index returns value. Objects disabled with AnnotationDisabled are ignored.
+func (b *MappedBackend) get(ctx context.Context, index, value string) (*unstructured.Unstructured, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(b.mapping.GVK.GroupVersion().WithKind(b.mapping.GVK.Kind + "List"))
+	if err := b.cluster.GetClient().List(ctx, list, &client.MatchingFields{index: value}); err != nil {
+		return nil, fmt.Errorf("failed listing %v for (%v): %w", b.mapping.GVK.Kind, value, err)
+	}
+	var items []unstructured.Unstructured
+	for i := range list.Items {
+		if !disabled(&list.Items[i]) {
+			items = append(items, list.Items[i])
+		}
+	}
+
+	switch len(items) {
+	case 0:
+		return nil, data.HardwareNotFoundError{}
+	case 1:
+		return &items[0], nil
+	}
+
+	return nil, fmt.Errorf("%w: got %d %v objects for %s, expected only 1", data.ErrInvalidRecord, len(items), b.mapping.GVK.Kind, value)
+}
+
+// object returns an empty object of the mapped kind.
+func (m Mapping) object() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(m.GVK)
+
+	return u
+}
+
+// macAddrs is the index function for the MAC address field.
+// MAC addresses are normalized so that they match the format of net.HardwareAddr.String().
+func (m Mapping) macAddrs(obj client.Object) []string {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil
+	}
+	var r []string
+	for _, s := range stringsAt(u, m.MACAddress) {
+		if mac, err := net.ParseMAC(s); err == nil {
+			r = append(r, mac.String())
+		}
+	}
+
+	return r
+}
+
+// ipAddrs is the index function for the IP address field.
+func (m Mapping) ipAddrs(obj client.Object) []string {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil
+	}
+
+	return stringsAt(u, m.IPAddress)
+}
+
+// translate converts a mapped object to data.DHCP and data.Netboot structs.
+// The first MAC address found is used, GetByMac overrides it with the MAC address that was looked up.
+func (m Mapping) translate(u *unstructured.Unstructured) (*data.DHCP, *data.Netboot, error) {
+	d := new(data.DHCP)
+	n := new(data.Netboot)
+
+	// MACAddress is required
+	macs := m.macAddrs(u)
+	if len(macs) == 0 {
+		return nil, nil, fmt.Errorf("no MAC address at %v", m.MACAddress)
+	}
+	mac, err := net.ParseMAC(macs[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	d.MACAddress = mac
+
+	// IPAddress is required
+	if d.IPAddress, err = netip.ParseAddr(stringAt(u, m.IPAddress)); err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", m.IPAddress, err)
+	}
+
+	// Netmask is required
+	sm := net.ParseIP(stringAt(u, m.SubnetMask))
+	if sm == nil {
+		return nil, nil, fmt.Errorf("no netmask at %v", m.SubnetMask)
+	}
+	d.SubnetMask = net.IPMask(sm.To4())
+
+	// Gateway is optional, but should be a valid IP address if present
+	if gw := stringAt(u, m.DefaultGateway); gw != "" {
+		a, err := netip.ParseAddr(gw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%v: %w", m.DefaultGateway, err)
+		}
+		d.SetDefaultGateway(a)
+	}
+
+	// name servers, optional
+	for _, s := range stringsAt(u, m.NameServers) {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			break
+		}
+		d.NameServers = append(d.NameServers, ip)
+	}
+
+	d.Hostname = stringAt(u, m.Hostname)
+	d.DomainName = stringAt(u, m.DomainName)
+	d.Arch = stringAt(u, m.Arch)
+	if m.LeaseTime != "" {
+		if lt, found, err := unstructured.NestedInt64(u.Object, path(m.LeaseTime)...); err == nil && found {
+			d.LeaseTime = uint32(lt)
+		}
+	}
+
+	if m.AllowNetboot != "" {
+		if allow, found, err := unstructured.NestedBool(u.Object, path(m.AllowNetboot)...); err == nil && found {
+			n.AllowNetboot = allow
+		}
+	}
+	// ipxe script url is optional but if provided, it must be a valid url
+	if s := stringAt(u, m.IPXEScriptURL); s != "" {
+		if n.IPXEScriptURL, err = url.ParseRequestURI(s); err != nil {
+			return nil, nil, fmt.Errorf("%v: %w", m.IPXEScriptURL, err)
+		}
+	}
+	n.IPXEScript = stringAt(u, m.IPXEScript)
+	n.Console = stringAt(u, m.Console)
+	n.Facility = stringAt(u, m.Facility)
+	n.KernelParams = stringAt(u, m.KernelParams)
+	n.IPXEBinary = stringAt(u, m.IPXEBinary)
+
+	// osie base url is optional but if provided, it must be a valid url
+	if s := stringAt(u, m.OSIEBaseURL); s != "" {
+		if n.OSIE.BaseURL, err = url.ParseRequestURI(s); err != nil {
+			return nil, nil, fmt.Errorf("%v: %w", m.OSIEBaseURL, err)
+		}
+	}
+	n.OSIE.Kernel = stringAt(u, m.OSIEKernel)
+	n.OSIE.Initrd = stringAt(u, m.OSIEInitrd)
+
+	return d, n, nil
+}
+
+// path splits a dot separated field path.
+func path(field string) []string {
+	return strings.Split(field, ".")
+}
+
+// stringAt returns the string at field in u. An empty string is returned if the field is not set or is not a string.
+func stringAt(u *unstructured.Unstructured, field string) string {
+	if field == "" {
+		return ""
+	}
+	s, _, _ := unstructured.NestedString(u.Object, path(field)...)
+
+	return s
+}
+
+// stringsAt returns the string or list of strings at field in u.
+func stringsAt(u *unstructured.Unstructured, field string) []string {
+	if field == "" {
+		return nil
+	}
+	v, found, err := unstructured.NestedFieldNoCopy(u.Object, path(field)...)
+	if err != nil || !found {
+		return nil
+	}
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var r []string
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				r = append(r, s)
+			}
+		}
+		return r
+	}
+
+	return nil
+}
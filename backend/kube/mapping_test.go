@@ -0,0 +1,170 @@
+package kube
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tinkerbell/dhcp/data"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+var testMapping = Mapping{
+	GVK:           schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Machine"},
+	MACAddress:    "spec.network.macs",
+	IPAddress:     "spec.network.ip",
+	SubnetMask:    "spec.network.netmask",
+	NameServers:   "spec.network.dns",
+	Hostname:      "metadata.name",
+	LeaseTime:     "spec.network.leaseSeconds",
+	AllowNetboot:  "spec.boot.pxe",
+	IPXEScriptURL: "spec.boot.script",
+	KernelParams:  "spec.boot.cmdline",
+	OSIEBaseURL:   "spec.boot.osie.url",
+	OSIEKernel:    "spec.boot.osie.kernel",
+}
+
+func machine(name string, macs []interface{}, ip string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"network": map[string]interface{}{
+				"macs":         macs,
+				"ip":           ip,
+				"netmask":      "255.255.255.0",
+				"dns":          []interface{}{"1.1.1.1"},
+				"leaseSeconds": int64(3600),
+			},
+			"boot": map[string]interface{}{
+				"pxe":     true,
+				"script":  "http://netboot.xyz",
+				"cmdline": "quiet",
+				"osie": map[string]interface{}{
+					"url":    "http://netboot.xyz/osie",
+					"kernel": "vmlinuz",
+				},
+			},
+		},
+	}}
+	u.SetGroupVersionKind(testMapping.GVK)
+	u.SetName(name)
+	u.SetNamespace("default")
+
+	return u
+}
+
+func TestNewMappedBackend(t *testing.T) {
+	if _, err := NewMappedBackend(new(rest.Config), Mapping{GVK: testMapping.GVK}); err == nil {
+		t.Fatal("expected error for a mapping without required fields")
+	}
+}
+
+func TestMappedBackend(t *testing.T) {
+	wantDHCP := &data.DHCP{
+		MACAddress:  net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x55},
+		IPAddress:   netip.MustParseAddr("172.16.10.100"),
+		SubnetMask:  []byte{0xff, 0xff, 0xff, 0x00},
+		NameServers: []net.IP{net.ParseIP("1.1.1.1")},
+		Hostname:    "machine1",
+		LeaseTime:   3600,
+	}
+	wantNetboot := &data.Netboot{
+		AllowNetboot:  true,
+		IPXEScriptURL: &url.URL{Scheme: "http", Host: "netboot.xyz"},
+		KernelParams:  "quiet",
+		OSIE:          data.OSIE{BaseURL: &url.URL{Scheme: "http", Host: "netboot.xyz", Path: "/osie"}, Kernel: "vmlinuz"},
+	}
+	tests := map[string]struct {
+		objects     []client.Object
+		wantDHCP    *data.DHCP
+		wantNetboot *data.Netboot
+		wantErr     bool
+	}{
+		"good data": {
+			objects:     []client.Object{machine("machine1", []interface{}{"3C:EC:EF:4C:4F:54", "3c:ec:ef:4c:4f:55"}, "172.16.10.100")},
+			wantDHCP:    wantDHCP,
+			wantNetboot: wantNetboot,
+		},
+		"not found": {
+			objects: []client.Object{machine("machine1", []interface{}{"00:00:00:00:00:01"}, "172.16.10.1")},
+			wantErr: true,
+		},
+		"more than one object": {
+			objects: []client.Object{
+				machine("machine1", []interface{}{"3c:ec:ef:4c:4f:55"}, "172.16.10.100"),
+				machine("machine2", []interface{}{"3c:ec:ef:4c:4f:55"}, "172.16.10.100"),
+			},
+			wantErr: true,
+		},
+		"disabled": {
+			objects: []client.Object{func() client.Object {
+				m := machine("machine1", []interface{}{"3c:ec:ef:4c:4f:55"}, "172.16.10.100")
+				m.SetAnnotations(map[string]string{AnnotationDisabled: "true"})
+				return m
+			}()},
+			wantErr: true,
+		},
+		"bad ip": {
+			objects: []client.Object{machine("machine1", []interface{}{"3c:ec:ef:4c:4f:55"}, "not an ip")},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rs := runtime.NewScheme()
+			cl := fake.NewClientBuilder().WithScheme(rs).
+				WithIndex(testMapping.object(), mappedMACAddrIndex, testMapping.macAddrs).
+				WithIndex(testMapping.object(), mappedIPAddrIndex, testMapping.ipAddrs).
+				WithObjects(tt.objects...).
+				Build()
+			fn := func(o *cluster.Options) {
+				o.NewClient = func(config *rest.Config, options client.Options) (client.Client, error) {
+					return cl, nil
+				}
+				o.MapperProvider = func(_ *rest.Config, _ *http.Client) (meta.RESTMapper, error) {
+					return cl.RESTMapper(), nil
+				}
+				o.NewCache = func(config *rest.Config, options cache.Options) (cache.Cache, error) {
+					return &informertest.FakeInformers{Scheme: rs}, nil
+				}
+			}
+			b, err := NewMappedBackend(new(rest.Config), testMapping, fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gotDHCP, gotNetboot, err := b.GetByMac(context.Background(), net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x55})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(gotDHCP, tt.wantDHCP, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(gotNetboot, tt.wantNetboot); diff != "" {
+				t.Error(diff)
+			}
+
+			// GetByIP uses the first MAC address of the object.
+			gotDHCP, _, err = b.GetByIP(context.Background(), net.IPv4(172, 16, 10, 100))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetByIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && gotDHCP.MACAddress.String() != "3c:ec:ef:4c:4f:54" {
+				t.Errorf("got mac %v, want %v", gotDHCP.MACAddress, "3c:ec:ef:4c:4f:54")
+			}
+		})
+	}
+}
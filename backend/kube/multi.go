@@ -0,0 +1,188 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+// Cluster is a named Backend for one Kubernetes cluster.
+type Cluster struct {
+	// Name identifies the cluster in errors and traces, for example the kubeconfig context name.
+	Name    string
+	Backend *Backend
+}
+
+// MultiBackend aggregates the Hardware caches of multiple clusters behind one handler.BackendReader.
+// This allows a single DHCP server to serve machines whose Hardware objects live in a management cluster per site.
+//
+// A lookup queries every cluster. Hardware found in more than one cluster is an error.
+// When the Hardware is found in one cluster, errors from the other clusters are ignored.
+type MultiBackend struct {
+	Clusters []Cluster
+}
+
+// NewMultiBackend returns a MultiBackend with a Backend for every rest config in confs, keyed by cluster name.
+// opts are passed to every Backend.
+//
+// Callers must instantiate the client-side caches by calling Start() before use.
+func NewMultiBackend(confs map[string]*rest.Config, opts ...cluster.Option) (*MultiBackend, error) {
+	names := make([]string, 0, len(confs))
+	for name := range confs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m := &MultiBackend{}
+	for _, name := range names {
+		b, err := NewBackend(confs[name], opts...)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %v: %w", name, err)
+		}
+		m.Clusters = append(m.Clusters, Cluster{Name: name, Backend: b})
+	}
+
+	return m, nil
+}
+
+// Start starts the client-side caches of all clusters. It blocks until ctx is done or a cache fails to start.
+func (m *MultiBackend) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(m.Clusters))
+	for _, c := range m.Clusters {
+		c := c
+		go func() {
+			if err := c.Backend.Start(ctx); err != nil {
+				errs <- fmt.Errorf("cluster %v: %w", c.Name, err)
+				return
+			}
+			errs <- nil
+		}()
+	}
+	var err error
+	for range m.Clusters {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+			cancel()
+		}
+	}
+
+	return err
+}
+
+// WaitForCacheSync blocks until the Hardware caches of all clusters have synced or ctx is done.
+// It returns false if any cache could not be synced.
+func (m *MultiBackend) WaitForCacheSync(ctx context.Context) bool {
+	for _, c := range m.Clusters {
+		if !c.Backend.WaitForCacheSync(ctx) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Healthy implements the handler.HealthChecker interface. It returns the errors of all unhealthy clusters.
+func (m *MultiBackend) Healthy(ctx context.Context) error {
+	var errs []error
+	for _, c := range m.Clusters {
+		if err := c.Backend.Healthy(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("cluster %v: %w", c.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// GetByMac implements the handler.BackendReader interface. It reads the Hardware for mac from the cache of every
+// cluster and adds the name of the cluster it was found in to the span. Hardware in more than one cluster is a
+// data.ErrInvalidRecord error. When no cluster has the Hardware, the errors of the clusters that failed are returned,
+// or a data.ErrNotFound error when none failed.
+func (m *MultiBackend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.kube.multi.GetByMac")
+	defer span.End()
+
+	name, d, n, err := m.lookup(ctx, func(ctx context.Context, b *Backend) (*data.DHCP, *data.Netboot, error) {
+		return b.GetByMac(ctx, mac)
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(attribute.String("kube.cluster", name))
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface. It reads the Hardware for ip from every cluster like
+// GetByMac.
+func (m *MultiBackend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.kube.multi.GetByIP")
+	defer span.End()
+
+	name, d, n, err := m.lookup(ctx, func(ctx context.Context, b *Backend) (*data.DHCP, *data.Netboot, error) {
+		return b.GetByIP(ctx, ip)
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(attribute.String("kube.cluster", name))
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// lookup calls get for every cluster and returns the name of the cluster the Hardware was found in and its data.
+// A not found error is returned if no cluster has the Hardware and no cluster returned another error.
+func (m *MultiBackend) lookup(ctx context.Context, get func(context.Context, *Backend) (*data.DHCP, *data.Netboot, error)) (string, *data.DHCP, *data.Netboot, error) {
+	var found []string
+	var d *data.DHCP
+	var n *data.Netboot
+	var errs []error
+	for _, c := range m.Clusters {
+		cd, cn, err := get(ctx, c.Backend)
+		if err != nil {
+			if !errors.Is(err, data.HardwareNotFoundError{}) {
+				errs = append(errs, fmt.Errorf("cluster %v: %w", c.Name, err))
+			}
+			continue
+		}
+		found = append(found, c.Name)
+		d, n = cd, cn
+	}
+
+	switch {
+	case len(found) > 1:
+		return "", nil, nil, fmt.Errorf("%w: hardware found in more than one cluster: %v", data.ErrInvalidRecord, strings.Join(found, ", "))
+	case len(found) == 1:
+		return found[0], d, n, nil
+	case len(errs) > 0:
+		return "", nil, nil, errors.Join(errs...)
+	}
+
+	return "", nil, nil, data.HardwareNotFoundError{}
+}
@@ -0,0 +1,96 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+// newFakeBackend returns a Backend whose cache holds hw.
+func newFakeBackend(t *testing.T, hw ...v1alpha1.Hardware) *Backend {
+	t.Helper()
+	rs := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(rs); err != nil {
+		t.Fatal(err)
+	}
+	cl := fake.NewClientBuilder().WithScheme(rs).
+		WithIndex(&v1alpha1.Hardware{}, MACAddrIndex, MACAddrs).
+		WithIndex(&v1alpha1.Hardware{}, IPAddrIndex, IPAddrs).
+		WithLists(&v1alpha1.HardwareList{Items: hw}).
+		Build()
+	fn := func(o *cluster.Options) {
+		o.NewClient = func(config *rest.Config, options client.Options) (client.Client, error) {
+			return cl, nil
+		}
+		o.MapperProvider = func(_ *rest.Config, _ *http.Client) (meta.RESTMapper, error) {
+			return cl.RESTMapper(), nil
+		}
+		o.NewCache = func(config *rest.Config, options cache.Options) (cache.Cache, error) {
+			return &informertest.FakeInformers{Scheme: rs}, nil
+		}
+	}
+	b, err := NewBackend(new(rest.Config), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestMultiBackend(t *testing.T) {
+	tests := map[string]struct {
+		clusters  map[string][]v1alpha1.Hardware
+		wantFound bool
+		wantErr   error
+	}{
+		"found in one cluster": {
+			clusters:  map[string][]v1alpha1.Hardware{"site-a": nil, "site-b": {hwObject1}},
+			wantFound: true,
+		},
+		"not found": {
+			clusters: map[string][]v1alpha1.Hardware{"site-a": nil, "site-b": {hwObject2}},
+			wantErr:  data.HardwareNotFoundError{},
+		},
+		"found in two clusters": {
+			clusters: map[string][]v1alpha1.Hardware{"site-a": {hwObject1}, "site-b": {hwObject1}},
+		},
+		"no clusters": {
+			wantErr: data.HardwareNotFoundError{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := &MultiBackend{}
+			for cname, hw := range tt.clusters {
+				m.Clusters = append(m.Clusters, Cluster{Name: cname, Backend: newFakeBackend(t, hw...)})
+			}
+			d, _, err := m.GetByMac(context.Background(), net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54})
+			if got := err == nil; got != tt.wantFound {
+				t.Fatalf("GetByMac() error = %v, want found %v", err, tt.wantFound)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantFound && d.Hostname != "sm01" {
+				t.Errorf("got hostname %q, want %q", d.Hostname, "sm01")
+			}
+			_, _, err = m.GetByIP(context.Background(), net.IPv4(172, 16, 10, 100))
+			if got := err == nil; got != tt.wantFound {
+				t.Fatalf("GetByIP() error = %v, want found %v", err, tt.wantFound)
+			}
+		})
+	}
+}
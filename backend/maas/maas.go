@@ -0,0 +1,347 @@
+// Package maas is a backend implementation that uses the MAAS region controller API to get DHCP data.
+package maas
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+// Errors used by the MAAS backend.
+var (
+	errAPIKey          = errors.New("API key must be in the format <consumer key>:<token key>:<token secret>")
+	errMultipleRecords = fmt.Errorf("%w: more than one machine found", data.ErrInvalidRecord)
+	errNoIP            = errors.New("no IPv4 address linked to the interface")
+	errSubnet          = fmt.Errorf("%w: subnet of the IPv4 address is not an IPv4 CIDR", data.ErrInvalidRecord)
+	errStatus          = errors.New("unexpected status code from MAAS")
+)
+
+// archMap maps MAAS architectures to the architecture names used in data.DHCP.Arch.
+var archMap = map[string]string{
+	"amd64":   "x86_64",
+	"i386":    "x86",
+	"arm64":   "aarch64",
+	"armhf":   "arm",
+	"ppc64el": "ppc64le",
+	"s390x":   "s390x",
+}
+
+// Backend is a backend implementation that uses the MAAS region controller API to get DHCP data.
+// It allows machines enlisted in MAAS to be netbooted by this DHCP server.
+type Backend struct {
+	// URL is the base URL of the MAAS region controller. For example, http://maas.example.com:5240/MAAS.
+	URL *url.URL
+
+	// Client is the HTTP client used to talk to the MAAS API.
+	Client *http.Client
+
+	// LeaseTime is the lease time, in seconds, for all records. MAAS does not track a per machine lease time.
+	// When zero, data.DefaultLeaseTime is used.
+	LeaseTime uint32
+
+	// Log is the logger to be used in the MAAS backend.
+	Log logr.Logger
+
+	consumerKey string
+	tokenKey    string
+	tokenSecret string
+}
+
+type machine struct {
+	SystemID     string `json:"system_id"`
+	Hostname     string `json:"hostname"`
+	Architecture string `json:"architecture"`
+	Netboot      bool   `json:"netboot"`
+	Domain       struct {
+		Name string `json:"name"`
+	} `json:"domain"`
+	Interfaces []iface `json:"interface_set"`
+}
+
+type iface struct {
+	MACAddress string `json:"mac_address"`
+	Links      []link `json:"links"`
+}
+
+type link struct {
+	IPAddress string `json:"ip_address"`
+	Subnet    subnet `json:"subnet"`
+}
+
+type subnet struct {
+	CIDR       string   `json:"cidr"`
+	GatewayIP  string   `json:"gateway_ip"`
+	DNSServers []string `json:"dns_servers"`
+	VLAN       struct {
+		VID int `json:"vid"`
+	} `json:"vlan"`
+}
+
+// NewBackend returns a MAAS backend.
+// apiKey is a MAAS API key in the format <consumer key>:<token key>:<token secret>.
+func NewBackend(l logr.Logger, u *url.URL, apiKey string) (*Backend, error) {
+	parts := strings.Split(apiKey, ":")
+	if len(parts) != 3 {
+		return nil, errAPIKey
+	}
+
+	return &Backend{
+		URL:         u,
+		Client:      http.DefaultClient,
+		LeaseTime:   86400,
+		Log:         l,
+		consumerKey: parts[0],
+		tokenKey:    parts[1],
+		tokenSecret: parts[2],
+	}, nil
+}
+
+// GetByMac implements the handler.BackendReader interface. It lists the machines with an interface with the MAC address
+// mac, and builds the record from the first IPv4 link of that interface. Nothing is cached, every read is an API request.
+// No machine is a data.ErrNotFound error, and more than one machine or an interface without an IPv4 link is a
+// data.ErrInvalidRecord error.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.maas.GetByMac")
+	defer span.End()
+
+	machines := []machine{}
+	if err := b.get(ctx, url.Values{"mac_address": {mac.String()}}, &machines); err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, fmt.Errorf("failed listing machines for (%v): %w", mac, err)
+	}
+
+	if len(machines) == 0 {
+		err := data.HardwareNotFoundError{}
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	if len(machines) > 1 {
+		err := fmt.Errorf("%w: got %d machines for mac %s", errMultipleRecords, len(machines), mac)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	m := machines[0]
+	var i iface
+	for _, elem := range m.Interfaces {
+		if strings.EqualFold(elem.MACAddress, mac.String()) {
+			i = elem
+			break
+		}
+	}
+
+	d, n, err := b.translate(m, i)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", data.ErrInvalidRecord, err)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface. The MAAS machines API cannot filter by IP address, so all
+// machines are listed and the first interface with a link to ip is used. No such interface is a data.ErrNotFound error.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.maas.GetByIP")
+	defer span.End()
+
+	machines := []machine{}
+	if err := b.get(ctx, nil, &machines); err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, fmt.Errorf("failed listing machines for (%v): %w", ip, err)
+	}
+
+	for _, m := range machines {
+		for _, i := range m.Interfaces {
+			for _, l := range i.Links {
+				if l.IPAddress != ip.String() {
+					continue
+				}
+				d, n, err := b.translate(m, i)
+				if err != nil {
+					err = fmt.Errorf("%w: %w", data.ErrInvalidRecord, err)
+					span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+					return nil, nil, err
+				}
+
+				span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+				span.SetAttributes(n.EncodeToAttributes()...)
+				span.SetStatus(codes.Ok, "")
+
+				return d, n, nil
+			}
+		}
+	}
+
+	err := data.HardwareNotFoundError{}
+	span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+	return nil, nil, err
+}
+
+// get lists machines from the MAAS API and decodes the JSON response into v.
+func (b *Backend) get(ctx context.Context, query url.Values, v any) error {
+	u := b.URL.JoinPath("/api/2.0/machines/")
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", b.authorization())
+
+	c := b.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %w", data.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %w: %d", data.ErrUnauthorized, errStatus, resp.StatusCode)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: %w: %d", data.ErrUnavailable, errStatus, resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: %d", errStatus, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// authorization returns an OAuth 1.0 PLAINTEXT Authorization header value, as required by the MAAS API.
+func (b *Backend) authorization() string {
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+
+	return fmt.Sprintf(
+		`OAuth oauth_version="1.0", oauth_signature_method="PLAINTEXT", oauth_consumer_key="%s", oauth_token="%s", oauth_signature="&%s", oauth_nonce="%s", oauth_timestamp="%d"`,
+		b.consumerKey, b.tokenKey, url.QueryEscape(b.tokenSecret), hex.EncodeToString(nonce), time.Now().Unix(),
+	)
+}
+
+// translate converts a MAAS machine and one of its interfaces into data.DHCP and data.Netboot structs.
+func (b *Backend) translate(m machine, i iface) (*data.DHCP, *data.Netboot, error) {
+	// mac address, required
+	mac, err := net.ParseMAC(i.MACAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// ip address and subnet mask, required. The first IPv4 link is used. The broadcast address is computed from them.
+	var l *link
+	var ip netip.Addr
+	for idx := range i.Links {
+		if a, err := netip.ParseAddr(i.Links[idx].IPAddress); err == nil && a.Is4() {
+			l = &i.Links[idx]
+			ip = a
+			break
+		}
+	}
+	if l == nil {
+		return nil, nil, fmt.Errorf("%w: %s", errNoIP, i.MACAddress)
+	}
+	p, err := netip.ParsePrefix(l.Subnet.CIDR)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !p.Addr().Is4() {
+		return nil, nil, fmt.Errorf("%w: %v", errSubnet, l.Subnet.CIDR)
+	}
+
+	var opts []data.DHCPOption
+
+	// default gateway, optional
+	if l.Subnet.GatewayIP != "" {
+		if dg, err := netip.ParseAddr(l.Subnet.GatewayIP); err != nil {
+			b.Log.Info("failed to parse default gateway", "defaultGateway", l.Subnet.GatewayIP, "err", err)
+		} else {
+			opts = append(opts, data.WithDefaultGateways(dg))
+		}
+	}
+
+	// name servers, optional
+	var ns []net.IP
+	for _, s := range l.Subnet.DNSServers {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			b.Log.Info("failed to parse name server", "nameServer", s)
+			break
+		}
+		ns = append(ns, ip)
+	}
+	opts = append(opts, data.WithNameServers(ns...))
+
+	// hostname and domain name
+	opts = append(opts, data.WithHostname(m.Hostname), data.WithDomainName(m.Domain.Name))
+	if m.Domain.Name != "" {
+		opts = append(opts, data.WithDomainSearch(m.Domain.Name))
+	}
+
+	// vlanid, 0 is the untagged VLAN in MAAS
+	if l.Subnet.VLAN.VID != 0 {
+		opts = append(opts, data.WithVLANID(fmt.Sprint(l.Subnet.VLAN.VID)))
+	}
+
+	// lease time, data.DefaultLeaseTime when not set
+	if b.LeaseTime > 0 {
+		opts = append(opts, data.WithLeaseTime(b.LeaseTime))
+	}
+
+	// arch, MAAS architectures are in the format <arch>/<subarch>
+	a, _, _ := strings.Cut(m.Architecture, "/")
+	if v, ok := archMap[a]; ok {
+		a = v
+	}
+	opts = append(opts, data.WithArch(a))
+
+	d, err := data.NewDHCP(mac, ip, net.CIDRMask(p.Bits(), 32), opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	// allow machine to netboot
+	n, err := data.NewNetboot(m.Netboot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d, n, nil
+}
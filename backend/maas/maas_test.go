@@ -0,0 +1,145 @@
+package maas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+const machines = `[{
+	"system_id": "abc123",
+	"hostname": "node-01",
+	"architecture": "amd64/generic",
+	"netboot": true,
+	"domain": {"name": "maas"},
+	"interface_set": [{
+		"mac_address": "00:01:02:03:04:05",
+		"links": [
+			{"ip_address": "fd00::5", "subnet": {"cidr": "fd00::/64"}},
+			{"ip_address": "10.0.0.5", "subnet": {"cidr": "10.0.0.0/24", "gateway_ip": "10.0.0.1", "dns_servers": ["10.0.0.2"], "vlan": {"vid": 0}}}
+		]
+	}]
+}]`
+
+func newBackend(t *testing.T, body string, status int) *Backend {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), `oauth_signature="&secret"`) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(s.Close)
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewBackend(logr.Discard(), u, "consumer:token:secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+var (
+	wantDHCP = &data.DHCP{
+		MACAddress:       net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+		IPAddress:        netip.MustParseAddr("10.0.0.5"),
+		SubnetMask:       net.IPv4Mask(255, 255, 255, 0),
+		DefaultGateways:  []netip.Addr{netip.MustParseAddr("10.0.0.1")},
+		NameServers:      []net.IP{net.ParseIP("10.0.0.2")},
+		Hostname:         "node-01",
+		DomainName:       "maas",
+		BroadcastAddress: netip.MustParseAddr("10.0.0.255"),
+		LeaseTime:        86400,
+		Arch:             "x86_64",
+		DomainSearch:     []string{"maas"},
+	}
+	wantNetboot = &data.Netboot{AllowNetboot: true}
+)
+
+func TestNewBackend(t *testing.T) {
+	if _, err := NewBackend(logr.Discard(), &url.URL{}, "not-a-key"); !errors.Is(err, errAPIKey) {
+		t.Fatalf("NewBackend() error = %v, wantErr %v", err, errAPIKey)
+	}
+}
+
+func TestGetByMac(t *testing.T) {
+	tests := map[string]struct {
+		body    string
+		status  int
+		wantErr error
+	}{
+		"success":           {body: machines, status: http.StatusOK},
+		"not found":         {body: `[]`, status: http.StatusOK, wantErr: data.HardwareNotFoundError{}},
+		"multiple machines": {body: `[{}, {}]`, status: http.StatusOK, wantErr: errMultipleRecords},
+		"bad status":        {status: http.StatusInternalServerError, wantErr: errStatus},
+		"no ipv4 link":      {body: `[{"interface_set": [{"mac_address": "00:01:02:03:04:05"}]}]`, status: http.StatusOK, wantErr: errNoIP},
+		"ipv6 subnet": {
+			body:    `[{"interface_set": [{"mac_address": "00:01:02:03:04:05", "links": [{"ip_address": "10.0.0.5", "subnet": {"cidr": "fd00::/64"}}]}]}]`,
+			status:  http.StatusOK,
+			wantErr: data.ErrInvalidRecord,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := newBackend(t, tt.body, tt.status)
+			d, n, err := b.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if diff := cmp.Diff(d, wantDHCP, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(n, wantNetboot); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestGetByIP(t *testing.T) {
+	tests := map[string]struct {
+		ip      net.IP
+		wantErr error
+	}{
+		"success":   {ip: net.IPv4(10, 0, 0, 5)},
+		"not found": {ip: net.IPv4(10, 0, 0, 6), wantErr: data.HardwareNotFoundError{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := newBackend(t, machines, http.StatusOK)
+			d, n, err := b.GetByIP(context.Background(), tt.ip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if diff := cmp.Diff(d, wantDHCP, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(n, wantNetboot); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
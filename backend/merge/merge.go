@@ -0,0 +1,252 @@
+// Package merge is a backend that overlays the data from two backends.
+package merge
+
+import (
+	"context"
+	"net"
+	"net/netip"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+// Backend merges the data from a Primary and a Defaults backend.
+//
+// Precedence rules:
+//   - The Primary backend is authoritative. If it returns an error, that error is returned.
+//   - The Defaults backend is always looked up by the MAC address of the Primary record.
+//     If it returns any error, the Primary record is returned unchanged.
+//   - A field from the Primary record is always used when it is set (non zero).
+//     A field from the Defaults record is only used when the Primary field is not set.
+//   - The MAC address, IP address, and AllowNetboot are never taken from the Defaults record.
+//
+// For example, a kube backend can provide the IP and MAC address per Hardware object
+// while a file backend provides the site wide name servers, NTP servers, and domain search list.
+type Backend struct {
+	// Primary is the authoritative backend.
+	Primary handler.BackendReader
+
+	// Defaults provides values for fields not set by the Primary backend.
+	Defaults handler.BackendReader
+
+	// Log is the logger to be used in the merge backend.
+	Log logr.Logger
+}
+
+// GetByMac implements the handler.BackendReader interface.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.merge.GetByMac")
+	defer span.End()
+
+	d, n, err := b.Primary.GetByMac(ctx, mac)
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	d, n = b.merge(ctx, d, n)
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.merge.GetByIP")
+	defer span.End()
+
+	d, n, err := b.Primary.GetByIP(ctx, ip)
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	d, n = b.merge(ctx, d, n)
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// merge looks up the Defaults record for d and fills in the fields d and n do not set.
+func (b *Backend) merge(ctx context.Context, d *data.DHCP, n *data.Netboot) (*data.DHCP, *data.Netboot) {
+	if b.Defaults == nil {
+		return d, n
+	}
+	dd, dn, err := b.Defaults.GetByMac(ctx, d.MACAddress)
+	if err != nil {
+		log := b.Log
+		if log.GetSink() == nil {
+			log = logr.Discard()
+		}
+		r := redact.FromContext(ctx)
+		log.V(1).Info("no defaults found, using primary record only", "mac", r.MAC(d.MACAddress), "error", r.Error(err))
+		return d, n
+	}
+
+	return DHCP(d, dd), Netboot(n, dn)
+}
+
+// DHCP returns a copy of primary with all unset fields taken from defaults.
+// The MAC address and IP address are always taken from primary. Options that are set by code, like OtherOptions,
+// are merged by code, and VIVendorOptions by enterprise number. The options of primary replace the options of defaults
+// with the same code.
+func DHCP(primary, defaults *data.DHCP) *data.DHCP {
+	if primary == nil {
+		return nil
+	}
+	r := *primary
+	if defaults == nil {
+		return &r
+	}
+	if len(r.SubnetMask) == 0 {
+		r.SubnetMask = defaults.SubnetMask
+	}
+	if len(r.DefaultGateways) == 0 {
+		r.DefaultGateways = defaults.DefaultGateways
+	}
+	if len(r.NameServers) == 0 {
+		r.NameServers = defaults.NameServers
+	}
+	if r.Hostname == "" {
+		r.Hostname = defaults.Hostname
+	}
+	if r.DomainName == "" {
+		r.DomainName = defaults.DomainName
+	}
+	if r.MTU == 0 {
+		r.MTU = defaults.MTU
+	}
+	if r.BroadcastAddress.Compare(netip.Addr{}) == 0 {
+		r.BroadcastAddress = defaults.BroadcastAddress
+	}
+	if len(r.NTPServers) == 0 {
+		r.NTPServers = defaults.NTPServers
+	}
+	if r.VLANID == "" {
+		r.VLANID = defaults.VLANID
+	}
+	if r.VLANTag == 0 {
+		r.VLANTag, r.VLANPriority = defaults.VLANTag, defaults.VLANPriority
+	}
+	if r.LeaseTime == 0 {
+		r.LeaseTime = defaults.LeaseTime
+	}
+	if r.TFTPServerName == "" {
+		r.TFTPServerName = defaults.TFTPServerName
+	}
+	if r.BootFileName == "" {
+		r.BootFileName = defaults.BootFileName
+	}
+	if r.Arch == "" {
+		r.Arch = defaults.Arch
+	}
+	if len(r.DomainSearch) == 0 {
+		r.DomainSearch = defaults.DomainSearch
+	}
+	if len(r.ClasslessStaticRoutes) == 0 {
+		r.ClasslessStaticRoutes = defaults.ClasslessStaticRoutes
+	}
+	r.OtherOptions = mergeMap(r.OtherOptions, defaults.OtherOptions)
+
+	if r.ProxyAutoConfigURL == nil {
+		r.ProxyAutoConfigURL = defaults.ProxyAutoConfigURL
+	}
+	r.VendorOptions = mergeMap(r.VendorOptions, defaults.VendorOptions)
+	if r.CaptivePortalURL == nil {
+		r.CaptivePortalURL = defaults.CaptivePortalURL
+	}
+	r.VIVendorOptions = mergeMap(r.VIVendorOptions, defaults.VIVendorOptions)
+	if len(r.SIPServerNames) == 0 && len(r.SIPServerAddresses) == 0 {
+		// only one of the encodings of option 120 can be set.
+		r.SIPServerNames, r.SIPServerAddresses = defaults.SIPServerNames, defaults.SIPServerAddresses
+	}
+	if r.TimeOffset == 0 && r.TZPOSIX == "" && r.TZDatabase == "" {
+		// the time offset and the timezone of a record describe one timezone, they are not mixed with those of defaults.
+		r.TimeOffset, r.TZPOSIX, r.TZDatabase = defaults.TimeOffset, defaults.TZPOSIX, defaults.TZDatabase
+	}
+	if len(r.ClientIdentifier) == 0 {
+		r.ClientIdentifier = defaults.ClientIdentifier
+	}
+	if len(r.ClientGUID) == 0 {
+		r.ClientGUID = defaults.ClientGUID
+	}
+	return &r
+}
+
+// mergeMap returns the entries of primary and the entries of defaults whose keys are not in primary.
+// It returns a new map when both have entries, so that neither is modified.
+func mergeMap[K comparable, V any](primary, defaults map[K]V) map[K]V {
+	if len(defaults) == 0 {
+		return primary
+	}
+	if len(primary) == 0 {
+		return defaults
+	}
+	r := make(map[K]V, len(primary)+len(defaults))
+	for k, v := range defaults {
+		r[k] = v
+	}
+	for k, v := range primary {
+		r[k] = v
+	}
+
+	return r
+}
+
+// Netboot returns a copy of primary with all unset fields taken from defaults.
+// AllowNetboot is always taken from primary.
+func Netboot(primary, defaults *data.Netboot) *data.Netboot {
+	if primary == nil {
+		primary = new(data.Netboot)
+	}
+	r := *primary
+	if defaults == nil {
+		return &r
+	}
+	if r.IPXEScriptURL == nil {
+		r.IPXEScriptURL = defaults.IPXEScriptURL
+	}
+	if r.IPXEScript == "" {
+		r.IPXEScript = defaults.IPXEScript
+	}
+	if r.Console == "" {
+		r.Console = defaults.Console
+	}
+	if r.Facility == "" {
+		r.Facility = defaults.Facility
+	}
+	if r.KernelParams == "" {
+		r.KernelParams = defaults.KernelParams
+	}
+	if r.IPXEBinary == "" {
+		r.IPXEBinary = defaults.IPXEBinary
+	}
+	if r.HTTPBootURI == nil {
+		r.HTTPBootURI = defaults.HTTPBootURI
+	}
+	if r.OSIE.BaseURL == nil {
+		r.OSIE.BaseURL = defaults.OSIE.BaseURL
+	}
+	if r.OSIE.Kernel == "" {
+		r.OSIE.Kernel = defaults.OSIE.Kernel
+	}
+	if r.OSIE.Initrd == "" {
+		r.OSIE.Initrd = defaults.OSIE.Initrd
+	}
+
+	return &r
+}
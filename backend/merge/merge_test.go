@@ -0,0 +1,228 @@
+package merge
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+type notFoundError struct{}
+
+func (notFoundError) NotFound() bool { return true }
+
+func (notFoundError) Error() string { return "not found" }
+
+type mockBackend struct {
+	dhcp    *data.DHCP
+	netboot *data.Netboot
+	err     error
+}
+
+func (m *mockBackend) GetByMac(context.Context, net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	return m.dhcp, m.netboot, m.err
+}
+
+func (m *mockBackend) GetByIP(context.Context, net.IP) (*data.DHCP, *data.Netboot, error) {
+	return m.dhcp, m.netboot, m.err
+}
+
+var (
+	primary = &mockBackend{
+		dhcp: &data.DHCP{
+			MACAddress: net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+			IPAddress:  netip.MustParseAddr("192.168.2.10"),
+			SubnetMask: net.IPv4Mask(255, 255, 255, 0),
+			Hostname:   "server-01",
+		},
+		netboot: &data.Netboot{AllowNetboot: true},
+	}
+	defaults = &mockBackend{
+		dhcp: &data.DHCP{
+			MACAddress:      net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			IPAddress:       netip.MustParseAddr("10.0.0.1"),
+			SubnetMask:      net.IPv4Mask(255, 0, 0, 0),
+			DefaultGateways: []netip.Addr{netip.MustParseAddr("192.168.2.1")},
+			NameServers:     []net.IP{{1, 1, 1, 1}},
+			Hostname:        "default",
+			NTPServers:      []net.IP{{132, 163, 96, 2}},
+			LeaseTime:       86400,
+			DomainSearch:    []string{"example.com"},
+		},
+		netboot: &data.Netboot{AllowNetboot: false, IPXEScriptURL: &url.URL{Scheme: "http", Host: "boot.netboot.xyz"}},
+	}
+)
+
+func TestGetByMac(t *testing.T) {
+	tests := map[string]struct {
+		primary     *mockBackend
+		defaults    *mockBackend
+		wantDHCP    *data.DHCP
+		wantNetboot *data.Netboot
+		wantErr     error
+	}{
+		"merged": {
+			primary:  primary,
+			defaults: defaults,
+			wantDHCP: &data.DHCP{
+				MACAddress:      net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+				IPAddress:       netip.MustParseAddr("192.168.2.10"),
+				SubnetMask:      net.IPv4Mask(255, 255, 255, 0),
+				DefaultGateways: []netip.Addr{netip.MustParseAddr("192.168.2.1")},
+				NameServers:     []net.IP{{1, 1, 1, 1}},
+				Hostname:        "server-01",
+				NTPServers:      []net.IP{{132, 163, 96, 2}},
+				LeaseTime:       86400,
+				DomainSearch:    []string{"example.com"},
+			},
+			wantNetboot: &data.Netboot{AllowNetboot: true, IPXEScriptURL: &url.URL{Scheme: "http", Host: "boot.netboot.xyz"}},
+		},
+		"no defaults found": {
+			primary:     primary,
+			defaults:    &mockBackend{err: notFoundError{}},
+			wantDHCP:    primary.dhcp,
+			wantNetboot: primary.netboot,
+		},
+		"primary not found": {
+			primary:  &mockBackend{err: notFoundError{}},
+			defaults: defaults,
+			wantErr:  notFoundError{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &Backend{Primary: tt.primary, Defaults: tt.defaults}
+			for _, fn := range []func() (*data.DHCP, *data.Netboot, error){
+				func() (*data.DHCP, *data.Netboot, error) {
+					return b.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05})
+				},
+				func() (*data.DHCP, *data.Netboot, error) {
+					return b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 10))
+				},
+			} {
+				d, n, err := fn()
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if diff := cmp.Diff(d, tt.wantDHCP, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+					t.Error(diff)
+				}
+				if diff := cmp.Diff(n, tt.wantNetboot); diff != "" {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
+
+func TestDHCP(t *testing.T) {
+	tests := map[string]struct {
+		primary  *data.DHCP
+		defaults *data.DHCP
+		want     *data.DHCP
+	}{
+		"other options": {
+			primary:  &data.DHCP{OtherOptions: map[uint8][]byte{224: []byte("host"), 225: []byte("host")}},
+			defaults: &data.DHCP{OtherOptions: map[uint8][]byte{225: []byte("site"), 226: []byte("site")}},
+			want:     &data.DHCP{OtherOptions: map[uint8][]byte{224: []byte("host"), 225: []byte("host"), 226: []byte("site")}},
+		},
+		"other options from defaults": {
+			primary:  &data.DHCP{},
+			defaults: &data.DHCP{OtherOptions: map[uint8][]byte{226: []byte("site")}},
+			want:     &data.DHCP{OtherOptions: map[uint8][]byte{226: []byte("site")}},
+		},
+		"proxy auto-config URL": {
+			primary:  &data.DHCP{},
+			defaults: &data.DHCP{ProxyAutoConfigURL: &url.URL{Scheme: "http", Host: "wpad.example.com", Path: "/wpad.dat"}},
+			want:     &data.DHCP{ProxyAutoConfigURL: &url.URL{Scheme: "http", Host: "wpad.example.com", Path: "/wpad.dat"}},
+		},
+		"vendor options": {
+			primary:  &data.DHCP{VendorOptions: map[uint8][]byte{1: []byte("host")}},
+			defaults: &data.DHCP{VendorOptions: map[uint8][]byte{1: []byte("site"), 2: []byte("site")}},
+			want:     &data.DHCP{VendorOptions: map[uint8][]byte{1: []byte("host"), 2: []byte("site")}},
+		},
+		"captive portal URL": {
+			primary:  &data.DHCP{},
+			defaults: &data.DHCP{CaptivePortalURL: &url.URL{Scheme: "https", Host: "portal.example.com", Path: "/api"}},
+			want:     &data.DHCP{CaptivePortalURL: &url.URL{Scheme: "https", Host: "portal.example.com", Path: "/api"}},
+		},
+		"vendor-identifying vendor options": {
+			primary:  &data.DHCP{VIVendorOptions: data.VIVendorOptions{3561: {1: []byte("host")}}},
+			defaults: &data.DHCP{VIVendorOptions: data.VIVendorOptions{3561: {1: []byte("site"), 2: []byte("site")}, 4491: {1: []byte("site")}}},
+			want:     &data.DHCP{VIVendorOptions: data.VIVendorOptions{3561: {1: []byte("host")}, 4491: {1: []byte("site")}}},
+		},
+		"SIP servers": {
+			primary:  &data.DHCP{},
+			defaults: &data.DHCP{SIPServerNames: []string{"sip.example.com"}},
+			want:     &data.DHCP{SIPServerNames: []string{"sip.example.com"}},
+		},
+		"SIP servers by address": {
+			primary:  &data.DHCP{SIPServerAddresses: []net.IP{{192, 168, 2, 5}}},
+			defaults: &data.DHCP{SIPServerNames: []string{"sip.example.com"}},
+			want:     &data.DHCP{SIPServerAddresses: []net.IP{{192, 168, 2, 5}}},
+		},
+		"timezone": {
+			primary:  &data.DHCP{},
+			defaults: &data.DHCP{TimeOffset: 3600, TZPOSIX: "CET-1CEST,M3.5.0,M10.5.0/3", TZDatabase: "Europe/Amsterdam"},
+			want:     &data.DHCP{TimeOffset: 3600, TZPOSIX: "CET-1CEST,M3.5.0,M10.5.0/3", TZDatabase: "Europe/Amsterdam"},
+		},
+		"timezone of primary": {
+			primary:  &data.DHCP{TZDatabase: "America/New_York"},
+			defaults: &data.DHCP{TimeOffset: 3600, TZPOSIX: "CET-1CEST,M3.5.0,M10.5.0/3", TZDatabase: "Europe/Amsterdam"},
+			want:     &data.DHCP{TZDatabase: "America/New_York"},
+		},
+		"client identifier and GUID": {
+			primary:  &data.DHCP{ClientIdentifier: []byte{0x01, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05}},
+			defaults: &data.DHCP{ClientIdentifier: []byte{0xff}, ClientGUID: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}},
+			want:     &data.DHCP{ClientIdentifier: []byte{0x01, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05}, ClientGUID: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, DHCP(tt.primary, tt.defaults), cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestNetboot(t *testing.T) {
+	uki := &url.URL{Scheme: "https", Host: "boot.example.com", Path: "/uki.efi"}
+	tests := map[string]struct {
+		primary  *data.Netboot
+		defaults *data.Netboot
+		want     *data.Netboot
+	}{
+		"HTTP boot URI": {
+			primary:  &data.Netboot{AllowNetboot: true},
+			defaults: &data.Netboot{HTTPBootURI: uki},
+			want:     &data.Netboot{AllowNetboot: true, HTTPBootURI: uki},
+		},
+		"HTTP boot URI of primary": {
+			primary:  &data.Netboot{HTTPBootURI: &url.URL{Scheme: "https", Host: "host.example.com", Path: "/uki.efi"}},
+			defaults: &data.Netboot{HTTPBootURI: uki},
+			want:     &data.Netboot{HTTPBootURI: &url.URL{Scheme: "https", Host: "host.example.com", Path: "/uki.efi"}},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, Netboot(tt.primary, tt.defaults)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestMergeDoesNotModifyInputs(t *testing.T) {
+	p := &data.DHCP{Hostname: "primary", OtherOptions: map[uint8][]byte{224: []byte("host")}}
+	DHCP(p, &data.DHCP{DomainName: "example.com", OtherOptions: map[uint8][]byte{226: []byte("site")}})
+	if p.DomainName != "" || len(p.OtherOptions) != 1 {
+		t.Fatalf("primary was modified: %+v", p)
+	}
+}
@@ -0,0 +1,122 @@
+// Package metrics is a backend that instruments another backend with Prometheus metrics.
+//
+// The number of reads, the number of failed reads by error class, and the read latency are recorded
+// for each backend and method (GetByMac or GetByIP).
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler"
+)
+
+// Error classes used in the class label of the errors metric.
+const (
+	ClassNotFound         = "not_found"
+	ClassDeadlineExceeded = "deadline_exceeded"
+	ClassCanceled         = "canceled"
+	ClassUnauthorized     = "unauthorized"
+	ClassUnavailable      = "unavailable"
+	ClassInvalidRecord    = "invalid_record"
+	ClassOther            = "other"
+)
+
+// Metrics holds the backend metric collectors. One Metrics can instrument any number of backends.
+type Metrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+// New returns Metrics with its collectors registered with r.
+func New(r prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dhcp_backend_requests_total",
+			Help: "Number of backend reads.",
+		}, []string{"backend", "method"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dhcp_backend_errors_total",
+			Help: "Number of failed backend reads by error class.",
+		}, []string{"backend", "method", "class"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "dhcp_backend_request_duration_seconds",
+			Help:    "Latency of backend reads.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14), // 0.5ms to ~4s
+		}, []string{"backend", "method"}),
+	}
+	for _, c := range []prometheus.Collector{m.requests, m.errors, m.latency} {
+		if err := r.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// Wrap returns b instrumented with m. name is used as the backend label.
+func (m *Metrics) Wrap(name string, b handler.BackendReader) *Backend {
+	return &Backend{Backend: b, Name: name, metrics: m}
+}
+
+// Backend wraps a handler.BackendReader and records metrics for every read.
+type Backend struct {
+	// Backend is the wrapped backend.
+	Backend handler.BackendReader
+	// Name is the value of the backend label.
+	Name string
+
+	metrics *Metrics
+}
+
+// GetByMac implements the handler.BackendReader interface.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	start := time.Now()
+	d, n, err := b.Backend.GetByMac(ctx, mac)
+	b.observe("GetByMac", start, err)
+
+	return d, n, err
+}
+
+// GetByIP implements the handler.BackendReader interface.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	start := time.Now()
+	d, n, err := b.Backend.GetByIP(ctx, ip)
+	b.observe("GetByIP", start, err)
+
+	return d, n, err
+}
+
+// observe records a read of method that started at start and returned err.
+func (b *Backend) observe(method string, start time.Time, err error) {
+	b.metrics.requests.WithLabelValues(b.Name, method).Inc()
+	b.metrics.latency.WithLabelValues(b.Name, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		b.metrics.errors.WithLabelValues(b.Name, method, Class(err)).Inc()
+	}
+}
+
+// Class returns the error class of err, used in the class label of the errors metric.
+func Class(err error) string {
+	switch {
+	case data.IsNotFound(err):
+		return ClassNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		return ClassDeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return ClassCanceled
+	case data.IsUnauthorized(err):
+		return ClassUnauthorized
+	case data.IsUnavailable(err):
+		return ClassUnavailable
+	case data.IsInvalidRecord(err):
+		return ClassInvalidRecord
+	}
+
+	return ClassOther
+}
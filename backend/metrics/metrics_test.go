@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+type notFoundError struct{}
+
+func (notFoundError) NotFound() bool { return true }
+
+func (notFoundError) Error() string { return "not found" }
+
+type mockBackend struct {
+	err error
+}
+
+func (m *mockBackend) GetByMac(context.Context, net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	if m.err != nil {
+		return nil, nil, m.err
+	}
+	return &data.DHCP{}, &data.Netboot{}, nil
+}
+
+func (m *mockBackend) GetByIP(context.Context, net.IP) (*data.DHCP, *data.Netboot, error) {
+	return m.GetByMac(context.Background(), nil)
+}
+
+func TestClass(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want string
+	}{
+		"not found":          {err: notFoundError{}, want: ClassNotFound},
+		"deadline exceeded":  {err: fmt.Errorf("failed listing hardware: %w", context.DeadlineExceeded), want: ClassDeadlineExceeded},
+		"canceled":           {err: context.Canceled, want: ClassCanceled},
+		"not found sentinel": {err: fmt.Errorf("record %w", data.ErrNotFound), want: ClassNotFound},
+		"unauthorized":       {err: fmt.Errorf("%w: 401", data.ErrUnauthorized), want: ClassUnauthorized},
+		"unavailable":        {err: fmt.Errorf("%w: 503", data.ErrUnavailable), want: ClassUnavailable},
+		"invalid record":     {err: fmt.Errorf("%w: no IP address", data.ErrInvalidRecord), want: ClassInvalidRecord},
+		"other":              {err: errors.New("boom"), want: ClassOther},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Class(tt.err); got != tt.want {
+				t.Fatalf("Class() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackend(t *testing.T) {
+	r := prometheus.NewRegistry()
+	m, err := New(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := &mockBackend{}
+	b := m.Wrap("file", mock)
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	if _, _, err := b.GetByMac(context.Background(), mac); err != nil {
+		t.Fatal(err)
+	}
+	mock.err = notFoundError{}
+	if _, _, err := b.GetByMac(context.Background(), mac); !errors.Is(err, notFoundError{}) {
+		t.Fatalf("GetByMac() error = %v, want the error of the wrapped backend", err)
+	}
+	if _, _, err := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 10)); err == nil {
+		t.Fatal("GetByIP() expected error")
+	}
+
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("file", "GetByMac")); got != 2 {
+		t.Errorf("got %v GetByMac requests, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("file", "GetByIP")); got != 1 {
+		t.Errorf("got %v GetByIP requests, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.errors.WithLabelValues("file", "GetByMac", ClassNotFound)); got != 1 {
+		t.Errorf("got %v GetByMac not found errors, want 1", got)
+	}
+	if got := testutil.CollectAndCount(m.latency); got != 2 {
+		t.Errorf("got %d latency series, want 2", got)
+	}
+}
+
+func TestNewAlreadyRegistered(t *testing.T) {
+	r := prometheus.NewRegistry()
+	if _, err := New(r); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(r); err == nil {
+		t.Fatal("New() expected error registering the collectors twice")
+	}
+}
@@ -0,0 +1,299 @@
+// Package mock is a programmable backend for tests of handlers, and of backends that wrap other backends, in this
+// module and downstream of it.
+//
+// The responses to the reads of each MAC or IP address are scripted as a sequence, for example two errors and then a
+// record, and can be delayed. Every read and lease event is recorded, so a test can check what the code under test
+// asked the backend for. For example:
+//
+//	b := mock.New(
+//		mock.WithMAC(mac, mock.Response{Err: data.ErrUnavailable}, mock.Response{DHCP: d, Netboot: n}),
+//		mock.WithDelay(10*time.Millisecond),
+//	)
+//	...
+//	if got := b.Calls(); len(got) != 2 {
+//		t.Fatalf("got %d backend reads, want 2", len(got))
+//	}
+package mock
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/tinkerbell/dhcp/data"
+)
+
+// Methods of the backend, as recorded in Call.Method.
+const (
+	MethodGetByMac      = "GetByMac"
+	MethodGetByIP       = "GetByIP"
+	MethodRecordAck     = "RecordAck"
+	MethodRecordRelease = "RecordRelease"
+	MethodRecordDecline = "RecordDecline"
+)
+
+// Response is a scripted response to a read.
+type Response struct {
+	// DHCP and Netboot are the data that is returned. The backend returns shallow copies of them, so the code under
+	// test can not change the script by setting their fields.
+	DHCP    *data.DHCP
+	Netboot *data.Netboot
+
+	// Err, when set, is returned instead of the data.
+	Err error
+
+	// Delay is how long the read takes, on top of the delay of WithDelay. A read whose context is done first returns
+	// the error of the context.
+	Delay time.Duration
+}
+
+// Call is a recorded call of a method of the backend.
+type Call struct {
+	// Method is the name of the method, one of the Method constants.
+	Method string
+	// MAC is the MAC address of GetByMac and of the lease events.
+	MAC net.HardwareAddr
+	// IP is the IP address of GetByIP and of the lease events.
+	IP netip.Addr
+	// BootFile is the boot file of RecordAck.
+	BootFile string
+	// Time is when the call returned.
+	Time time.Time
+	// Err is the error that the call returned.
+	Err error
+}
+
+// Option configures a Backend.
+type Option func(*Backend)
+
+// WithMAC scripts the responses to the reads of mac with GetByMac: the first read gets the first response, the second
+// read the second, and so on. Once the responses are used up, the last one is repeated.
+func WithMAC(mac net.HardwareAddr, responses ...Response) Option {
+	return func(b *Backend) {
+		b.byMAC[mac.String()] = &script{responses: responses}
+	}
+}
+
+// WithIP scripts the responses to the reads of ip with GetByIP, like WithMAC.
+func WithIP(ip netip.Addr, responses ...Response) Option {
+	return func(b *Backend) {
+		b.byIP[ip.Unmap()] = &script{responses: responses}
+	}
+}
+
+// WithDefault scripts the responses to the reads of the MAC and IP addresses that have no script of their own, like
+// WithMAC. Without it, those reads return an error that wraps data.ErrNotFound.
+func WithDefault(responses ...Response) Option {
+	return func(b *Backend) {
+		b.def = &script{responses: responses}
+	}
+}
+
+// WithDelay delays every read by d, for example to test the timeouts of the code under test.
+func WithDelay(d time.Duration) Option {
+	return func(b *Backend) {
+		b.delay = d
+	}
+}
+
+// WithWriteError makes the lease events, RecordAck, RecordRelease, and RecordDecline, return err.
+func WithWriteError(err error) Option {
+	return func(b *Backend) {
+		b.writeErr = err
+	}
+}
+
+// script is a sequence of responses, and the position of the next one.
+type script struct {
+	responses []Response
+	next      int
+}
+
+// response returns the next response of s, the last one once the responses are used up.
+func (s *script) response() Response {
+	if len(s.responses) == 0 {
+		return Response{}
+	}
+	r := s.responses[min(s.next, len(s.responses)-1)]
+	s.next++
+
+	return r
+}
+
+// Backend is a backend with scripted responses that records its calls. It implements handler.BackendReader and
+// handler.BackendWriter, and is safe for concurrent use.
+type Backend struct {
+	mu       sync.Mutex // protects the fields below
+	byMAC    map[string]*script
+	byIP     map[netip.Addr]*script
+	def      *script
+	delay    time.Duration
+	writeErr error
+	calls    []Call
+}
+
+// New returns a Backend scripted by opts.
+func New(opts ...Option) *Backend {
+	b := &Backend{byMAC: make(map[string]*script), byIP: make(map[netip.Addr]*script)}
+	for _, o := range opts {
+		o(b)
+	}
+
+	return b
+}
+
+// Script replaces the scripts of b with those of opts, keeping the scripts that opts do not replace. It can be called
+// while the backend is in use, for example to make a backend fail in the middle of a test.
+func (b *Backend) Script(opts ...Option) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, o := range opts {
+		o(b)
+	}
+}
+
+// GetByMac implements the handler.BackendReader interface and returns the next scripted response for mac.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	b.mu.Lock()
+	s, ok := b.byMAC[mac.String()]
+	if !ok {
+		s = b.def
+	}
+	r := b.response(s, fmt.Errorf("%w: %v", data.ErrNotFound, mac))
+	delay := b.delay
+	b.mu.Unlock()
+
+	d, n, err := read(ctx, r, delay)
+	b.record(Call{Method: MethodGetByMac, MAC: append(net.HardwareAddr(nil), mac...), Time: time.Now(), Err: err})
+
+	return d, n, err
+}
+
+// GetByIP implements the handler.BackendReader interface and returns the next scripted response for ip.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	addr, _ := netip.AddrFromSlice(ip)
+	addr = addr.Unmap()
+	b.mu.Lock()
+	s, ok := b.byIP[addr]
+	if !ok {
+		s = b.def
+	}
+	r := b.response(s, fmt.Errorf("%w: %v", data.ErrNotFound, ip))
+	delay := b.delay
+	b.mu.Unlock()
+
+	d, n, err := read(ctx, r, delay)
+	b.record(Call{Method: MethodGetByIP, IP: addr, Time: time.Now(), Err: err})
+
+	return d, n, err
+}
+
+// response returns the next response of s, or a response with notFound if s is nil. b.mu must be held.
+func (b *Backend) response(s *script, notFound error) Response {
+	if s == nil {
+		return Response{Err: notFound}
+	}
+
+	return s.response()
+}
+
+// read waits for delay and the delay of r, and returns the data of r.
+func read(ctx context.Context, r Response, delay time.Duration) (*data.DHCP, *data.Netboot, error) {
+	if d := delay + r.Delay; d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+	if r.Err != nil {
+		return nil, nil, r.Err
+	}
+	var d *data.DHCP
+	if r.DHCP != nil {
+		c := *r.DHCP
+		d = &c
+	}
+	var n *data.Netboot
+	if r.Netboot != nil {
+		c := *r.Netboot
+		n = &c
+	}
+
+	return d, n, nil
+}
+
+// RecordAck implements the handler.BackendWriter interface and records the event.
+func (b *Backend) RecordAck(_ context.Context, mac net.HardwareAddr, ip netip.Addr, bootfile string) error {
+	return b.write(Call{Method: MethodRecordAck, MAC: mac, IP: ip, BootFile: bootfile})
+}
+
+// RecordRelease implements the handler.BackendWriter interface and records the event.
+func (b *Backend) RecordRelease(_ context.Context, mac net.HardwareAddr, ip netip.Addr) error {
+	return b.write(Call{Method: MethodRecordRelease, MAC: mac, IP: ip})
+}
+
+// RecordDecline implements the handler.BackendWriter interface and records the event.
+func (b *Backend) RecordDecline(_ context.Context, mac net.HardwareAddr, ip netip.Addr) error {
+	return b.write(Call{Method: MethodRecordDecline, MAC: mac, IP: ip})
+}
+
+// write records c, a lease event, and returns the error of WithWriteError.
+func (b *Backend) write(c Call) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c.MAC = append(net.HardwareAddr(nil), c.MAC...)
+	c.Time = time.Now()
+	c.Err = b.writeErr
+	b.calls = append(b.calls, c)
+
+	return c.Err
+}
+
+// record records c.
+func (b *Backend) record(c Call) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls = append(b.calls, c)
+}
+
+// Calls returns the calls of b, in the order they returned.
+func (b *Backend) Calls() []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Call(nil), b.calls...)
+}
+
+// CallsOf returns the calls of b of method, in the order they returned.
+func (b *Backend) CallsOf(method string) []Call {
+	var calls []Call
+	for _, c := range b.Calls() {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls
+}
+
+// Reset forgets the recorded calls, and restarts the scripts from their first responses.
+func (b *Backend) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls = nil
+	for _, s := range b.byMAC {
+		s.next = 0
+	}
+	for _, s := range b.byIP {
+		s.next = 0
+	}
+	if b.def != nil {
+		b.def.next = 0
+	}
+}
@@ -0,0 +1,165 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler"
+)
+
+var (
+	_ handler.BackendReader = &Backend{}
+	_ handler.BackendWriter = &Backend{}
+)
+
+var errBackend = errors.New("backend error")
+
+func TestGetByMac(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	other := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x06}
+	d := &data.DHCP{MACAddress: mac, IPAddress: netip.MustParseAddr("192.168.2.150")}
+	n := &data.Netboot{AllowNetboot: true}
+	tests := map[string]struct {
+		opts []Option
+		mac  net.HardwareAddr
+		// want and wantErr are the data and the errors of consecutive reads.
+		want    []*data.DHCP
+		wantErr []error
+	}{
+		"not scripted": {mac: mac, want: []*data.DHCP{nil}, wantErr: []error{data.ErrNotFound}},
+		"record": {
+			opts:    []Option{WithMAC(mac, Response{DHCP: d, Netboot: n})},
+			mac:     mac,
+			want:    []*data.DHCP{d, d},
+			wantErr: []error{nil, nil},
+		},
+		"error sequence": {
+			opts:    []Option{WithMAC(mac, Response{Err: errBackend}, Response{Err: data.ErrUnavailable}, Response{DHCP: d})},
+			mac:     mac,
+			want:    []*data.DHCP{nil, nil, d, d},
+			wantErr: []error{errBackend, data.ErrUnavailable, nil, nil},
+		},
+		"other MAC": {opts: []Option{WithMAC(mac, Response{DHCP: d})}, mac: other, want: []*data.DHCP{nil}, wantErr: []error{data.ErrNotFound}},
+		"default": {
+			opts:    []Option{WithMAC(mac, Response{Err: errBackend}), WithDefault(Response{DHCP: d})},
+			mac:     other,
+			want:    []*data.DHCP{d},
+			wantErr: []error{nil},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := New(tt.opts...)
+			for i := range tt.want {
+				got, _, err := b.GetByMac(context.Background(), tt.mac)
+				if !errors.Is(err, tt.wantErr[i]) {
+					t.Fatalf("read %d: GetByMac() error = %v, wantErr %v", i, err, tt.wantErr[i])
+				}
+				if diff := cmp.Diff(tt.want[i], got, cmp.Comparer(func(a, b netip.Addr) bool { return a == b })); diff != "" {
+					t.Fatalf("read %d: %v", i, diff)
+				}
+			}
+			if got := len(b.CallsOf(MethodGetByMac)); got != len(tt.want) {
+				t.Fatalf("got %d recorded calls, want %d", got, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestGetByIP(t *testing.T) {
+	ip := netip.MustParseAddr("192.168.2.150")
+	d := &data.DHCP{IPAddress: ip}
+	b := New(WithIP(ip, Response{DHCP: d}))
+
+	got, _, err := b.GetByIP(context.Background(), net.IP{192, 168, 2, 150})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the data is a copy, changing it does not change the script.
+	got.Hostname = "changed"
+	if got, _, _ := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 150)); got.Hostname != "" {
+		t.Fatalf("got hostname %q, want the scripted empty hostname", got.Hostname)
+	}
+	if _, _, err := b.GetByIP(context.Background(), net.IP{192, 168, 2, 151}); !errors.Is(err, data.ErrNotFound) {
+		t.Fatalf("GetByIP() error = %v, want %v", err, data.ErrNotFound)
+	}
+	calls := b.Calls()
+	if len(calls) != 3 || calls[0].Method != MethodGetByIP || calls[0].IP != ip || calls[2].Err == nil {
+		t.Fatalf("got calls %+v, want three reads of GetByIP", calls)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	b := New(WithMAC(mac, Response{DHCP: &data.DHCP{}, Delay: time.Second}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := b.GetByMac(ctx, mac); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetByMac() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	b.Script(WithMAC(mac, Response{DHCP: &data.DHCP{}}), WithDelay(20*time.Millisecond))
+	start := time.Now()
+	if _, _, err := b.GetByMac(context.Background(), mac); err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Since(start); got < 20*time.Millisecond {
+		t.Fatalf("GetByMac() returned after %v, want at least 20ms", got)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	ip := netip.MustParseAddr("192.168.2.150")
+	b := New()
+	if err := b.RecordAck(context.Background(), mac, ip, "ipxe.efi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RecordRelease(context.Background(), mac, ip); err != nil {
+		t.Fatal(err)
+	}
+	b.Script(WithWriteError(errBackend))
+	if err := b.RecordDecline(context.Background(), mac, ip); !errors.Is(err, errBackend) {
+		t.Fatalf("RecordDecline() error = %v, want %v", err, errBackend)
+	}
+
+	want := []Call{
+		{Method: MethodRecordAck, MAC: mac, IP: ip, BootFile: "ipxe.efi"},
+		{Method: MethodRecordRelease, MAC: mac, IP: ip},
+		{Method: MethodRecordDecline, MAC: mac, IP: ip, Err: errBackend},
+	}
+	opts := []cmp.Option{
+		cmp.Comparer(func(a, b netip.Addr) bool { return a == b }),
+		cmp.Comparer(func(a, b error) bool { return errors.Is(a, b) }),
+		cmp.Comparer(func(time.Time, time.Time) bool { return true }),
+	}
+	if diff := cmp.Diff(want, b.Calls(), opts...); diff != "" {
+		t.Fatal(diff)
+	}
+
+	b.Reset()
+	if got := b.Calls(); len(got) != 0 {
+		t.Fatalf("got %d calls after Reset(), want none", len(got))
+	}
+}
+
+func TestReset(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	b := New(WithMAC(mac, Response{Err: errBackend}, Response{DHCP: &data.DHCP{}}))
+	for _, wantErr := range []error{errBackend, nil} {
+		if _, _, err := b.GetByMac(context.Background(), mac); !errors.Is(err, wantErr) {
+			t.Fatalf("GetByMac() error = %v, want %v", err, wantErr)
+		}
+	}
+	b.Reset()
+	if _, _, err := b.GetByMac(context.Background(), mac); !errors.Is(err, errBackend) {
+		t.Fatalf("GetByMac() error after Reset() = %v, want the first scripted error %v", err, errBackend)
+	}
+}
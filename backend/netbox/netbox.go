@@ -0,0 +1,514 @@
+// Package netbox is a backend implementation that uses the Netbox API to get DHCP data.
+package netbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/time/rate"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+const (
+	// DefaultCacheTTL is the default amount of time a Netbox lookup is cached.
+	DefaultCacheTTL = 30 * time.Second
+	// DefaultCacheMaxEntries is the default maximum number of cached Netbox lookups.
+	DefaultCacheMaxEntries = 10000
+	// DefaultRateLimit is the default number of requests per second sent to the Netbox API.
+	DefaultRateLimit = rate.Limit(10)
+	// DefaultBurst is the default number of requests that can be sent to the Netbox API at once.
+	DefaultBurst = 10
+)
+
+// Errors used by the Netbox backend.
+var (
+	errMultipleRecords = fmt.Errorf("%w: more than one record found", data.ErrInvalidRecord)
+	errNoInterface     = fmt.Errorf("%w: IP address is not assigned to a device interface", data.ErrInvalidRecord)
+	errStatus          = errors.New("unexpected status code from Netbox")
+)
+
+// Backend is a backend implementation that uses the Netbox API to get DHCP data.
+//
+// A DHCP record is built from the following Netbox objects:
+// * the dcim interface that has the requested MAC address.
+// * the IPv4 ipam IP address assigned to that interface, its prefix length is used for the subnet mask and broadcast address.
+// * the dcim device that owns the interface, its name is used as the hostname.
+//
+// Values that Netbox does not model natively are read from custom fields.
+// See ipCustomFields and deviceCustomFields for the expected custom field names.
+type Backend struct {
+	// URL is the base URL of the Netbox instance. For example, https://netbox.example.com.
+	URL *url.URL
+
+	// Token is the Netbox API token.
+	Token string
+
+	// Client is the HTTP client used to talk to the Netbox API.
+	Client *http.Client
+
+	// CacheTTL is how long a lookup result is cached. A zero value disables caching.
+	CacheTTL time.Duration
+
+	// CacheMaxEntries is the maximum number of cached lookup results. A zero value means no limit.
+	// When the cache is full, expired results are removed, or the result that expires first when none has expired.
+	CacheMaxEntries int
+
+	// Limiter limits the rate of requests sent to the Netbox API. A nil value disables rate limiting.
+	Limiter *rate.Limiter
+
+	// Log is the logger to be used in the Netbox backend.
+	Log logr.Logger
+
+	cacheMu sync.Mutex // protects cache
+	cache   map[string]cacheEntry
+}
+
+// cacheEntry is a single cached lookup result.
+type cacheEntry struct {
+	dhcp    *data.DHCP
+	netboot *data.Netboot
+	expires time.Time
+}
+
+// ipCustomFields are the custom fields read from a Netbox IP address object.
+// List values are comma separated.
+type ipCustomFields struct {
+	Gateway      string `json:"dhcp_gateway"`       // DHCP option 3.
+	NameServers  string `json:"dhcp_name_servers"`  // DHCP option 6.
+	NTPServers   string `json:"dhcp_ntp_servers"`   // DHCP option 42.
+	VLANID       string `json:"dhcp_vlan_id"`       // DHCP option 43.116.
+	LeaseTime    int    `json:"dhcp_lease_time"`    // DHCP option 51.
+	Arch         string `json:"dhcp_arch"`          // DHCP option 93.
+	DomainSearch string `json:"dhcp_domain_search"` // DHCP option 119.
+}
+
+// deviceCustomFields are the custom fields read from a Netbox device object.
+type deviceCustomFields struct {
+	AllowPXE      bool   `json:"netboot_allow_pxe"`
+	IPXEScriptURL string `json:"netboot_ipxe_script_url"`
+	IPXEScript    string `json:"netboot_ipxe_script"`
+	Console       string `json:"netboot_console"`
+	Facility      string `json:"netboot_facility"`
+	IPXEBinary    string `json:"netboot_ipxe_binary"`
+}
+
+type nestedDevice struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type iface struct {
+	ID         int          `json:"id"`
+	MACAddress string       `json:"mac_address"`
+	Device     nestedDevice `json:"device"`
+}
+
+type ipAddress struct {
+	ID                 int            `json:"id"`
+	Address            string         `json:"address"`
+	DNSName            string         `json:"dns_name"`
+	AssignedObjectType string         `json:"assigned_object_type"`
+	AssignedObjectID   int            `json:"assigned_object_id"`
+	CustomFields       ipCustomFields `json:"custom_fields"`
+}
+
+type device struct {
+	ID           int                `json:"id"`
+	Name         string             `json:"name"`
+	CustomFields deviceCustomFields `json:"custom_fields"`
+}
+
+type list[T any] struct {
+	Count   int `json:"count"`
+	Results []T `json:"results"`
+}
+
+// NewBackend returns a Netbox backend with the default cache TTL, cache size and rate limit.
+func NewBackend(l logr.Logger, u *url.URL, token string) *Backend {
+	return &Backend{
+		URL:             u,
+		Token:           token,
+		Client:          http.DefaultClient,
+		CacheTTL:        DefaultCacheTTL,
+		CacheMaxEntries: DefaultCacheMaxEntries,
+		Limiter:         rate.NewLimiter(DefaultRateLimit, DefaultBurst),
+		Log:             l,
+	}
+}
+
+// GetByMac implements the handler.BackendReader interface. It looks up the dcim interface with the mac_address mac,
+// the IPv4 address assigned to it and its device, in three API requests that are answered from the cache for CacheTTL.
+// No interface or no IPv4 address on it, or a 404 from the API, is a data.ErrNotFound error, and more than one is a
+// data.ErrInvalidRecord error.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.netbox.GetByMac")
+	defer span.End()
+
+	key := "mac:" + mac.String()
+	if d, n, ok := b.fromCache(key); ok {
+		span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+		span.SetAttributes(n.EncodeToAttributes()...)
+		span.SetStatus(codes.Ok, "")
+
+		return d, n, nil
+	}
+
+	ifaces := list[iface]{}
+	if err := b.get(ctx, "/api/dcim/interfaces/", url.Values{"mac_address": {mac.String()}}, &ifaces); err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, fmt.Errorf("failed listing interfaces for (%v): %w", mac, err)
+	}
+	i, err := one(ifaces)
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	ips := list[ipAddress]{}
+	if err := b.get(ctx, "/api/ipam/ip-addresses/", url.Values{"interface_id": {fmt.Sprint(i.ID)}, "family": {"4"}}, &ips); err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, fmt.Errorf("failed listing ip addresses for (%v): %w", mac, err)
+	}
+	ip, err := one(ips)
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	d, n, err := b.build(ctx, mac, ip, i.Device.ID)
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	b.toCache(key, d, n)
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface. It looks up the ipam IP address ip, the dcim interface it is
+// assigned to and the device of the interface, cached like GetByMac. An IP address that is not assigned to a dcim
+// interface, such as a virtual machine interface, is a data.ErrInvalidRecord error.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.netbox.GetByIP")
+	defer span.End()
+
+	key := "ip:" + ip.String()
+	if d, n, ok := b.fromCache(key); ok {
+		span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+		span.SetAttributes(n.EncodeToAttributes()...)
+		span.SetStatus(codes.Ok, "")
+
+		return d, n, nil
+	}
+
+	ips := list[ipAddress]{}
+	if err := b.get(ctx, "/api/ipam/ip-addresses/", url.Values{"address": {ip.String()}}, &ips); err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, fmt.Errorf("failed listing ip addresses for (%v): %w", ip, err)
+	}
+	addr, err := one(ips)
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	if addr.AssignedObjectType != "dcim.interface" {
+		err := fmt.Errorf("%w: %s", errNoInterface, ip)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	i := iface{}
+	if err := b.get(ctx, fmt.Sprintf("/api/dcim/interfaces/%d/", addr.AssignedObjectID), nil, &i); err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, fmt.Errorf("failed getting interface for (%v): %w", ip, err)
+	}
+	mac, err := net.ParseMAC(i.MACAddress)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", data.ErrInvalidRecord, err)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	d, n, err := b.build(ctx, mac, addr, i.Device.ID)
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	b.toCache(key, d, n)
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// build gets the device for an interface and converts the Netbox objects to data.DHCP and data.Netboot structs.
+func (b *Backend) build(ctx context.Context, mac net.HardwareAddr, ip ipAddress, deviceID int) (*data.DHCP, *data.Netboot, error) {
+	dev := device{}
+	if err := b.get(ctx, fmt.Sprintf("/api/dcim/devices/%d/", deviceID), nil, &dev); err != nil {
+		return nil, nil, fmt.Errorf("failed getting device (%d): %w", deviceID, err)
+	}
+
+	d, err := b.toDHCPData(mac, ip, dev)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to convert ip address to DHCP data: %w", data.ErrInvalidRecord, err)
+	}
+	n, err := toNetbootData(dev.CustomFields)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to convert device to netboot data: %w", data.ErrInvalidRecord, err)
+	}
+
+	return d, n, nil
+}
+
+// get sends a rate limited GET request to the Netbox API and decodes the JSON response into v.
+func (b *Backend) get(ctx context.Context, path string, query url.Values, v any) error {
+	if b.Limiter != nil {
+		if err := b.Limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	u := b.URL.JoinPath(path)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if b.Token != "" {
+		req.Header.Set("Authorization", "Token "+b.Token)
+	}
+
+	c := b.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %w", data.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound:
+		return data.HardwareNotFoundError{}
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %w: %d", data.ErrUnauthorized, errStatus, resp.StatusCode)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: %w: %d", data.ErrUnavailable, errStatus, resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: %d", errStatus, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// fromCache returns the cached data for key if it exists and has not expired.
+func (b *Backend) fromCache(key string) (*data.DHCP, *data.Netboot, bool) {
+	if b.CacheTTL <= 0 {
+		return nil, nil, false
+	}
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+	e, ok := b.cache[key]
+	if !ok {
+		return nil, nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(b.cache, key)
+		return nil, nil, false
+	}
+
+	return e.dhcp, e.netboot, true
+}
+
+// toCache stores the data for key.
+func (b *Backend) toCache(key string, d *data.DHCP, n *data.Netboot) {
+	if b.CacheTTL <= 0 {
+		return
+	}
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+	if b.cache == nil {
+		b.cache = make(map[string]cacheEntry)
+	}
+	now := time.Now()
+	if _, ok := b.cache[key]; !ok && b.CacheMaxEntries > 0 && len(b.cache) >= b.CacheMaxEntries {
+		b.evict(now)
+	}
+	b.cache[key] = cacheEntry{dhcp: d, netboot: n, expires: now.Add(b.CacheTTL)}
+}
+
+// evict removes the expired results from the cache, or the result that expires first when none has expired.
+// b.cacheMu must be held.
+func (b *Backend) evict(now time.Time) {
+	var first string
+	var expires time.Time
+	var removed bool
+	for k, e := range b.cache {
+		if now.After(e.expires) {
+			delete(b.cache, k)
+			removed = true
+			continue
+		}
+		if first == "" || e.expires.Before(expires) {
+			first, expires = k, e.expires
+		}
+	}
+	if !removed {
+		delete(b.cache, first)
+	}
+}
+
+// one returns the only result in l.
+func one[T any](l list[T]) (T, error) {
+	var t T
+	switch len(l.Results) {
+	case 0:
+		return t, data.HardwareNotFoundError{}
+	case 1:
+		return l.Results[0], nil
+	default:
+		return t, fmt.Errorf("%w: got %d", errMultipleRecords, len(l.Results))
+	}
+}
+
+// toDHCPData converts Netbox objects to a data.DHCP data structure.
+// The IP address is required and must be in CIDR notation.
+func (b *Backend) toDHCPData(mac net.HardwareAddr, ip ipAddress, dev device) (*data.DHCP, error) {
+	// ip address and subnet mask, required. The broadcast address is computed from them.
+	p, err := netip.ParsePrefix(ip.Address)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Addr().Is4() {
+		return nil, fmt.Errorf("not an IPv4 address: %v", ip.Address)
+	}
+
+	cf := ip.CustomFields
+	var opts []data.DHCPOption
+
+	// default gateway, optional
+	if cf.Gateway != "" {
+		if dg, err := netip.ParseAddr(cf.Gateway); err != nil {
+			b.Log.Info("failed to parse default gateway", "defaultGateway", cf.Gateway, "err", err)
+		} else {
+			opts = append(opts, data.WithDefaultGateways(dg))
+		}
+	}
+
+	// name servers, optional
+	var ns []net.IP
+	for _, s := range split(cf.NameServers) {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			b.Log.Info("failed to parse name server", "nameServer", s)
+			break
+		}
+		ns = append(ns, ip)
+	}
+	opts = append(opts, data.WithNameServers(ns...))
+
+	// hostname and domain name, optional. The dns name takes precedence over the device name.
+	if ip.DNSName != "" {
+		h, dn := data.SplitFQDN(ip.DNSName)
+		opts = append(opts, data.WithHostname(h), data.WithDomainName(dn))
+	} else {
+		opts = append(opts, data.WithHostname(dev.Name))
+	}
+
+	// ntp servers, optional
+	var ntp []net.IP
+	for _, s := range split(cf.NTPServers) {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			b.Log.Info("failed to parse ntp server", "ntpServer", s)
+			break
+		}
+		ntp = append(ntp, ip)
+	}
+	opts = append(opts, data.WithNTPServers(ntp...))
+
+	// lease time, data.DefaultLeaseTime when not set
+	if cf.LeaseTime > 0 {
+		opts = append(opts, data.WithLeaseTime(uint32(cf.LeaseTime)))
+	}
+
+	opts = append(opts,
+		data.WithVLANID(cf.VLANID),
+		data.WithArch(cf.Arch),
+		data.WithDomainSearch(split(cf.DomainSearch)...),
+	)
+
+	return data.NewDHCP(mac, p.Addr(), net.CIDRMask(p.Bits(), 32), opts...)
+}
+
+// toNetbootData converts device custom fields to a data.Netboot data structure.
+func toNetbootData(cf deviceCustomFields) (*data.Netboot, error) {
+	opts := []data.NetbootOption{
+		data.WithIPXEScript(cf.IPXEScript),
+		data.WithConsole(cf.Console),
+		data.WithFacility(cf.Facility),
+		data.WithIPXEBinary(cf.IPXEBinary),
+	}
+
+	// ipxe script url is optional but if provided, it must be a valid url
+	if cf.IPXEScriptURL != "" {
+		u, err := url.ParseRequestURI(cf.IPXEScriptURL)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, data.WithIPXEScriptURL(u))
+	}
+
+	return data.NewNetboot(cf.AllowPXE, opts...)
+}
+
+// split splits a comma separated custom field value, ignoring empty elements.
+func split(s string) []string {
+	var r []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			r = append(r, e)
+		}
+	}
+
+	return r
+}
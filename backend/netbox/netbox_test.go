@@ -0,0 +1,232 @@
+package netbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+const (
+	ifaceList = `{"count":1,"results":[{"id":7,"mac_address":"00:01:02:03:04:05","device":{"id":3,"name":"server-01"}}]}`
+	ifaceOne  = `{"id":7,"mac_address":"00:01:02:03:04:05","device":{"id":3,"name":"server-01"}}`
+	ipList    = `{"count":1,"results":[{"id":9,"address":"192.168.2.150/24","dns_name":"server-01.example.com","assigned_object_type":"dcim.interface","assigned_object_id":7,"custom_fields":{"dhcp_gateway":"192.168.2.1","dhcp_name_servers":"1.1.1.1, 8.8.8.8","dhcp_ntp_servers":"132.163.96.2","dhcp_lease_time":86400,"dhcp_domain_search":"example.com"}}]}`
+	deviceOne = `{"id":3,"name":"server-01","custom_fields":{"netboot_allow_pxe":true,"netboot_ipxe_script_url":"http://boot.netboot.xyz"}}`
+	emptyList = `{"count":0,"results":[]}`
+)
+
+func newServer(t *testing.T, routes map[string]string, calls *int32) *Backend {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if r.Header.Get("Authorization") != "Token secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(s.Close)
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewBackend(logr.Discard(), u, "secret")
+}
+
+var (
+	wantDHCP = &data.DHCP{
+		MACAddress:       net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+		IPAddress:        netip.MustParseAddr("192.168.2.150"),
+		SubnetMask:       net.IPv4Mask(255, 255, 255, 0),
+		DefaultGateways:  []netip.Addr{netip.MustParseAddr("192.168.2.1")},
+		NameServers:      []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("8.8.8.8")},
+		Hostname:         "server-01",
+		DomainName:       "example.com",
+		BroadcastAddress: netip.MustParseAddr("192.168.2.255"),
+		NTPServers:       []net.IP{net.ParseIP("132.163.96.2")},
+		LeaseTime:        86400,
+		DomainSearch:     []string{"example.com"},
+	}
+	wantNetboot = &data.Netboot{
+		AllowNetboot:  true,
+		IPXEScriptURL: &url.URL{Scheme: "http", Host: "boot.netboot.xyz"},
+	}
+)
+
+func TestGetByMac(t *testing.T) {
+	tests := map[string]struct {
+		routes  map[string]string
+		wantErr error
+	}{
+		"success": {routes: map[string]string{
+			"/api/dcim/interfaces/":   ifaceList,
+			"/api/ipam/ip-addresses/": ipList,
+			"/api/dcim/devices/3/":    deviceOne,
+		}},
+		"no interface": {routes: map[string]string{
+			"/api/dcim/interfaces/": emptyList,
+		}, wantErr: data.HardwareNotFoundError{}},
+		"no ip address": {routes: map[string]string{
+			"/api/dcim/interfaces/":   ifaceList,
+			"/api/ipam/ip-addresses/": emptyList,
+		}, wantErr: data.HardwareNotFoundError{}},
+		"multiple interfaces": {routes: map[string]string{
+			"/api/dcim/interfaces/": `{"count":2,"results":[{"id":1},{"id":2}]}`,
+		}, wantErr: errMultipleRecords},
+		"bad status": {routes: map[string]string{}, wantErr: data.HardwareNotFoundError{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls int32
+			b := newServer(t, tt.routes, &calls)
+			d, n, err := b.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if diff := cmp.Diff(d, wantDHCP, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(n, wantNetboot); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestGetByIP(t *testing.T) {
+	tests := map[string]struct {
+		routes  map[string]string
+		wantErr error
+	}{
+		"success": {routes: map[string]string{
+			"/api/ipam/ip-addresses/": ipList,
+			"/api/dcim/interfaces/7/": ifaceOne,
+			"/api/dcim/devices/3/":    deviceOne,
+		}},
+		"no ip address": {routes: map[string]string{
+			"/api/ipam/ip-addresses/": emptyList,
+		}, wantErr: data.HardwareNotFoundError{}},
+		"not assigned to an interface": {routes: map[string]string{
+			"/api/ipam/ip-addresses/": `{"count":1,"results":[{"id":9,"address":"192.168.2.150/24","assigned_object_type":"virtualization.vminterface"}]}`,
+		}, wantErr: errNoInterface},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls int32
+			b := newServer(t, tt.routes, &calls)
+			d, n, err := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 150))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if diff := cmp.Diff(d, wantDHCP, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(n, wantNetboot); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	routes := map[string]string{
+		"/api/dcim/interfaces/":   ifaceList,
+		"/api/ipam/ip-addresses/": ipList,
+		"/api/dcim/devices/3/":    deviceOne,
+	}
+	tests := map[string]struct {
+		ttl       time.Duration
+		wantCalls int32
+	}{
+		"cache enabled":  {ttl: time.Minute, wantCalls: 3},
+		"cache disabled": {ttl: 0, wantCalls: 6},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls int32
+			b := newServer(t, routes, &calls)
+			b.CacheTTL = tt.ttl
+			for i := 0; i < 2; i++ {
+				if _, _, err := b.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Fatalf("got %d calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCacheMaxEntries(t *testing.T) {
+	now := time.Now()
+	tests := map[string]struct {
+		cached  map[string]cacheEntry
+		max     int
+		wantLen int
+		wantOut []string
+	}{
+		"not full": {
+			cached:  map[string]cacheEntry{"mac:a": {expires: now.Add(time.Minute)}},
+			max:     2,
+			wantLen: 2,
+		},
+		"evicts the first to expire": {
+			cached:  map[string]cacheEntry{"mac:a": {expires: now.Add(2 * time.Minute)}, "mac:b": {expires: now.Add(time.Minute)}},
+			max:     2,
+			wantLen: 2,
+			wantOut: []string{"mac:b"},
+		},
+		"removes the expired": {
+			cached:  map[string]cacheEntry{"mac:a": {expires: now.Add(-time.Second)}, "mac:b": {expires: now.Add(-time.Minute)}, "mac:c": {expires: now.Add(time.Minute)}},
+			max:     3,
+			wantLen: 2,
+			wantOut: []string{"mac:a", "mac:b"},
+		},
+		"no limit": {
+			cached:  map[string]cacheEntry{"mac:a": {expires: now.Add(time.Minute)}, "mac:b": {expires: now.Add(time.Minute)}},
+			wantLen: 3,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &Backend{CacheTTL: time.Hour, CacheMaxEntries: tt.max, cache: tt.cached}
+			b.toCache("mac:new", &data.DHCP{}, &data.Netboot{})
+			if len(b.cache) != tt.wantLen {
+				t.Fatalf("got %d cached results, want %d", len(b.cache), tt.wantLen)
+			}
+			if _, ok := b.cache["mac:new"]; !ok {
+				t.Fatal("new result not cached")
+			}
+			for _, k := range tt.wantOut {
+				if _, ok := b.cache[k]; ok {
+					t.Errorf("%v not evicted", k)
+				}
+			}
+		})
+	}
+}
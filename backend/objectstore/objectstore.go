@@ -0,0 +1,266 @@
+// Package objectstore is a backend that serves the reservations in an object of an S3 bucket or a Google Cloud
+// Storage (GCS) bucket. It is meant for edge sites next to a cloud that have neither a filesystem to mount
+// nor access to a cluster: the object is published, for example from CI, and picked up by every site.
+//
+// The object uses the data model of the file backend, see backend/file/testdata/example.yaml. It is fetched
+// by a remote backend, on a schedule and, with NotificationHandler, whenever the bucket notifies of a change.
+//
+// Requests to S3 and to S3 compatible object stores are signed with AWS Signature Version 4. Requests to GCS
+// use HMAC keys with the interoperable XML API when credentials are given, and otherwise the access token of
+// the service account of the virtual machine, from the GCE metadata server.
+package objectstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/dhcp/backend/remote"
+	"github.com/tinkerbell/dhcp/internal/sigv4"
+)
+
+// DefaultGCSEndpoint is the endpoint of the GCS XML API.
+const DefaultGCSEndpoint = "https://storage.googleapis.com"
+
+// DefaultMetadataURL is the URL of the GCE metadata server endpoint that returns an access token for the
+// default service account of the virtual machine.
+const DefaultMetadataURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// emptyHash is the SHA-256 hash of an empty request body, sent by S3 requests as X-Amz-Content-Sha256.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Errors used by the object storage backend.
+var (
+	errNoObject  = errors.New("bucket and object key must be set")
+	errNoRegion  = errors.New("region must be set")
+	errToken     = errors.New("failed to get an access token from the metadata server")
+	errSubscribe = errors.New("SNS SubscribeURL is not an amazonaws.com HTTPS URL")
+)
+
+// Credentials are the access key and secret used to sign requests, AWS credentials for S3 or HMAC keys for GCS.
+type Credentials = sigv4.Credentials
+
+// CredentialsFromEnv returns the credentials in the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables.
+func CredentialsFromEnv() (Credentials, error) {
+	return sigv4.CredentialsFromEnv()
+}
+
+// S3 is the location of an object in an S3 bucket.
+type S3 struct {
+	// Bucket is the name of the bucket.
+	Bucket string
+	// Key is the key of the object.
+	Key string
+	// Region is the AWS region of the bucket. For example, us-east-1.
+	Region string
+	// Endpoint is the URL of an S3 compatible object store, for example MinIO. When it is set the object is
+	// addressed path style, <Endpoint>/<Bucket>/<Key>. Defaults to the virtual hosted style URL of the bucket in AWS.
+	Endpoint *url.URL
+	// Credentials are used to sign the requests.
+	Credentials Credentials
+}
+
+// NewS3Backend returns a remote backend that fetches the S3 object described by o.
+// The object is not fetched until Refresh or Start is called.
+func NewS3Backend(l logr.Logger, o S3) (*remote.Backend, error) {
+	if o.Bucket == "" || o.Key == "" {
+		return nil, errNoObject
+	}
+	if o.Region == "" {
+		return nil, errNoRegion
+	}
+	u := &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", o.Bucket, o.Region), Path: "/" + o.Key}
+	if o.Endpoint != nil {
+		u = o.Endpoint.JoinPath(o.Bucket, o.Key)
+	}
+	b := remote.NewBackend(l, u)
+	b.Prepare = signer(o.Credentials, o.Region)
+
+	return b, nil
+}
+
+// GCS is the location of an object in a GCS bucket.
+type GCS struct {
+	// Bucket is the name of the bucket.
+	Bucket string
+	// Object is the name of the object.
+	Object string
+	// Endpoint is the URL of the GCS XML API. Defaults to DefaultGCSEndpoint.
+	Endpoint *url.URL
+	// Credentials are HMAC keys used to sign the requests. When they are not set, the requests are authorized
+	// with an access token for the service account of the virtual machine, from the metadata server.
+	Credentials Credentials
+	// MetadataURL is the URL of the metadata server endpoint that returns access tokens. Defaults to DefaultMetadataURL.
+	MetadataURL string
+}
+
+// NewGCSBackend returns a remote backend that fetches the GCS object described by o.
+// The object is not fetched until Refresh or Start is called.
+func NewGCSBackend(l logr.Logger, o GCS) (*remote.Backend, error) {
+	if o.Bucket == "" || o.Object == "" {
+		return nil, errNoObject
+	}
+	e := o.Endpoint
+	if e == nil {
+		var err error
+		if e, err = url.Parse(DefaultGCSEndpoint); err != nil {
+			return nil, err
+		}
+	}
+	b := remote.NewBackend(l, e.JoinPath(o.Bucket, o.Object))
+	if o.Credentials.AccessKeyID != "" {
+		// the XML API accepts Signature Version 4 with HMAC keys, with the region "auto".
+		b.Prepare = signer(o.Credentials, "auto")
+		return b, nil
+	}
+	t := &tokenSource{URL: o.MetadataURL, Client: b.Client}
+	if t.URL == "" {
+		t.URL = DefaultMetadataURL
+	}
+	b.Prepare = func(r *http.Request) error {
+		tok, err := t.token(r)
+		if err != nil {
+			return err
+		}
+		r.Header.Set("Authorization", "Bearer "+tok)
+
+		return nil
+	}
+
+	return b, nil
+}
+
+// signer returns a remote.Backend Prepare function that signs requests for the S3 API.
+func signer(c Credentials, region string) func(*http.Request) error {
+	return func(r *http.Request) error {
+		r.Header.Set("X-Amz-Content-Sha256", emptyHash)
+		sigv4.Sign(r, nil, c, region, "s3", time.Now())
+
+		return nil
+	}
+}
+
+// tokenSource gets access tokens from the GCE metadata server and caches them until shortly before they expire.
+type tokenSource struct {
+	URL    string
+	Client *http.Client
+
+	mu      sync.Mutex // protects the fields below
+	value   string
+	expires time.Time
+}
+
+// token returns a valid access token. The token is requested with the context of r.
+func (t *tokenSource) token(r *http.Request) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.value != "" && time.Now().Before(t.expires) {
+		return t.value, nil
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, t.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	c := t.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errToken, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: status code %d", errToken, resp.StatusCode)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("%w: %w", errToken, err)
+	}
+	if tok.AccessToken == "" {
+		return "", fmt.Errorf("%w: empty access token", errToken)
+	}
+	t.value = tok.AccessToken
+	// refresh a minute early so a token does not expire while a request is in flight.
+	t.expires = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+
+	return t.value, nil
+}
+
+// NotificationHandler returns an HTTP handler that refreshes b when the bucket notifies of a change to the object.
+// It accepts POST requests from Pub/Sub push subscriptions of GCS notifications, and from SNS topics that S3 event
+// notifications are published to. SNS subscription confirmations are confirmed.
+//
+// The contents of notifications are not trusted: any notification only makes b fetch the object, which is a
+// conditional request that does not download the object when it did not change.
+func NotificationHandler(l logr.Logger, b *remote.Backend) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("X-Amz-Sns-Message-Type") == "SubscriptionConfirmation" {
+			if err := confirm(r, b.Client); err != nil {
+				l.Error(err, "failed to confirm SNS subscription")
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			l.Info("confirmed SNS subscription")
+			return
+		}
+		if err := b.Refresh(r.Context()); err != nil {
+			l.Error(err, "failed to refresh object on notification")
+			// a failed response makes SNS and Pub/Sub retry the notification.
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// confirm confirms the SNS subscription in the SubscriptionConfirmation message in the body of r.
+func confirm(r *http.Request, c *http.Client) error {
+	var m struct {
+		SubscribeURL string `json:"SubscribeURL"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 64<<10)).Decode(&m); err != nil {
+		return err
+	}
+	u, err := url.Parse(m.SubscribeURL)
+	if err != nil {
+		return err
+	}
+	// only visit SNS, so a forged message cannot make the server request arbitrary URLs.
+	if u.Scheme != "https" || !strings.HasSuffix(u.Hostname(), ".amazonaws.com") {
+		return errSubscribe
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("SNS subscription confirmation returned status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,276 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+)
+
+const document = `{"00:00:00:00:00:01": {"ipAddress": "192.168.2.1", "subnetMask": "255.255.255.0"}}`
+
+var mac = net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+// store is a fake object store that serves document and records the requests it gets.
+type store struct {
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (s *store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Clone(context.Background()))
+	w.Header().Set("ETag", `"v1"`)
+	fmt.Fprint(w, document)
+}
+
+func (s *store) last() *http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+
+	return s.requests[len(s.requests)-1]
+}
+
+func newStore(t *testing.T) (*store, *url.URL) {
+	t.Helper()
+	s := &store{}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, u
+}
+
+func TestNewS3Backend(t *testing.T) {
+	b, err := NewS3Backend(logr.Discard(), S3{Bucket: "bucket", Key: "sites/edge 1.json", Region: "us-east-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("https://bucket.s3.us-east-1.amazonaws.com/sites/edge%201.json", b.URL.String()); diff != "" {
+		t.Fatal(diff)
+	}
+
+	tests := map[string]struct {
+		o       S3
+		wantErr error
+	}{
+		"no bucket": {o: S3{Key: "k", Region: "us-east-1"}, wantErr: errNoObject},
+		"no key":    {o: S3{Bucket: "b", Region: "us-east-1"}, wantErr: errNoObject},
+		"no region": {o: S3{Bucket: "b", Key: "k"}, wantErr: errNoRegion},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewS3Backend(logr.Discard(), tt.o); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewS3Backend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestS3Refresh(t *testing.T) {
+	ctx := context.Background()
+	s, u := newStore(t)
+	b, err := NewS3Backend(logr.Discard(), S3{
+		Bucket:      "bucket",
+		Key:         "hardware.json",
+		Region:      "eu-west-1",
+		Endpoint:    u,
+		Credentials: Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.GetByMac(ctx, mac); err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.last()
+	if diff := cmp.Diff("/bucket/hardware.json", r.URL.Path); diff != "" {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff(emptyHash, r.Header.Get("X-Amz-Content-Sha256")); diff != "" {
+		t.Fatal(diff)
+	}
+	if a := r.Header.Get("Authorization"); !strings.HasPrefix(a, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(a, "/eu-west-1/s3/aws4_request") {
+		t.Fatalf("Authorization = %q, want a Signature Version 4 for eu-west-1 and s3", a)
+	}
+}
+
+func TestGCSRefreshHMAC(t *testing.T) {
+	ctx := context.Background()
+	s, u := newStore(t)
+	b, err := NewGCSBackend(logr.Discard(), GCS{
+		Bucket:      "bucket",
+		Object:      "hardware.yaml",
+		Endpoint:    u,
+		Credentials: Credentials{AccessKeyID: "GOOG1", SecretAccessKey: "secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.last()
+	if diff := cmp.Diff("/bucket/hardware.yaml", r.URL.Path); diff != "" {
+		t.Fatal(diff)
+	}
+	if a := r.Header.Get("Authorization"); !strings.HasPrefix(a, "AWS4-HMAC-SHA256 Credential=GOOG1/") || !strings.Contains(a, "/auto/s3/aws4_request") {
+		t.Fatalf("Authorization = %q, want a Signature Version 4 for auto and s3", a)
+	}
+}
+
+func TestGCSRefreshMetadataToken(t *testing.T) {
+	ctx := context.Background()
+	s, u := newStore(t)
+
+	var mu sync.Mutex
+	tokens := 0
+	status := http.StatusOK
+	md := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		tokens++
+		fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": 3599, "token_type": "Bearer"}`, tokens)
+	}))
+	t.Cleanup(md.Close)
+
+	b, err := NewGCSBackend(logr.Discard(), GCS{Bucket: "bucket", Object: "hardware.yaml", Endpoint: u, MetadataURL: md.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := b.Refresh(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if diff := cmp.Diff("Bearer token-1", s.last().Header.Get("Authorization")); diff != "" {
+		t.Fatal(diff)
+	}
+	mu.Lock()
+	if tokens != 1 {
+		t.Fatalf("metadata server got %d token requests, want 1, the token is cached", tokens)
+	}
+	mu.Unlock()
+
+	// a new backend does not have a cached token.
+	mu.Lock()
+	status = http.StatusNotFound
+	mu.Unlock()
+	b, err = NewGCSBackend(logr.Discard(), GCS{Bucket: "bucket", Object: "hardware.yaml", Endpoint: u, MetadataURL: md.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Refresh(ctx); !errors.Is(err, errToken) {
+		t.Fatalf("Refresh() error = %v, wantErr %v", err, errToken)
+	}
+}
+
+// roundTripper sends every request to the test server at u, whatever the host of the request.
+type roundTripper struct{ u *url.URL }
+
+func (rt roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = rt.u.Scheme, rt.u.Host
+
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestNotificationHandler(t *testing.T) {
+	s, u := newStore(t)
+	b, err := NewGCSBackend(logr.Discard(), GCS{Bucket: "bucket", Object: "hardware.yaml", Endpoint: u, Credentials: Credentials{AccessKeyID: "GOOG1", SecretAccessKey: "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Client = &http.Client{Transport: roundTripper{u: u}}
+	h := NotificationHandler(logr.Discard(), b)
+
+	tests := map[string]struct {
+		method     string
+		header     http.Header
+		body       string
+		wantStatus int
+		wantPath   string
+	}{
+		"get": {method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+		"pubsub push": {
+			method:     http.MethodPost,
+			body:       `{"message": {"attributes": {"eventType": "OBJECT_FINALIZE"}}}`,
+			wantStatus: http.StatusNoContent,
+			wantPath:   "/bucket/hardware.yaml",
+		},
+		"sns notification": {
+			method:     http.MethodPost,
+			header:     http.Header{"X-Amz-Sns-Message-Type": {"Notification"}},
+			body:       `{"Type": "Notification"}`,
+			wantStatus: http.StatusNoContent,
+			wantPath:   "/bucket/hardware.yaml",
+		},
+		"sns subscription confirmation": {
+			method:     http.MethodPost,
+			header:     http.Header{"X-Amz-Sns-Message-Type": {"SubscriptionConfirmation"}},
+			body:       `{"Type": "SubscriptionConfirmation", "SubscribeURL": "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription"}`,
+			wantStatus: http.StatusOK,
+			wantPath:   "/",
+		},
+		"sns subscription confirmation to another host": {
+			method:     http.MethodPost,
+			header:     http.Header{"X-Amz-Sns-Message-Type": {"SubscriptionConfirmation"}},
+			body:       `{"Type": "SubscriptionConfirmation", "SubscribeURL": "https://example.com/amazonaws.com"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s.mu.Lock()
+			s.requests = nil
+			s.mu.Unlock()
+
+			r := httptest.NewRequest(tt.method, "/notify", strings.NewReader(tt.body))
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			path := ""
+			if r := s.last(); r != nil {
+				path = r.URL.Path
+			}
+			if diff := cmp.Diff(tt.wantPath, path); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Package prefix is a backend that matches MAC addresses by OUI prefix or wildcard pattern instead of by exact MAC address.
+package prefix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+// errPattern is returned when a Rule pattern cannot be parsed.
+var errPattern = errors.New("invalid MAC address pattern")
+
+// Rule maps a MAC address pattern to the data to serve for all matching MAC addresses.
+type Rule struct {
+	// Pattern is a MAC address prefix or wildcard pattern.
+	// Octets are separated by ":" or "-" and a "*" octet matches any value.
+	// Missing trailing octets match any value, so "00:25:90" matches the whole Supermicro OUI.
+	// Examples: "00:25:90", "00:25:90:*:*:*", "*:*:*:*:*:01", "*".
+	Pattern string
+
+	// DHCP is the DHCP data to serve. The MAC address is always set to the requesting MAC address.
+	DHCP data.DHCP
+
+	// Netboot is the netboot data to serve.
+	Netboot data.Netboot
+}
+
+// compiled is a parsed Rule.
+type compiled struct {
+	rule     Rule
+	octets   [6]byte
+	wildcard [6]bool
+	// specificity is the number of non wildcard octets.
+	specificity int
+}
+
+// Backend matches MAC addresses against a list of Rules.
+// When multiple rules match, the most specific rule (the one with the most non wildcard octets) is used.
+// Ties are broken by the order of the rules.
+//
+// Backend only supports GetByMac. It can be used on its own or as the last backend in a fallback chain,
+// as a not found error is returned when no rule matches.
+type Backend struct {
+	rules []compiled
+}
+
+// NewBackend returns a Backend for rules. An error is returned if any rule pattern is invalid.
+func NewBackend(rules ...Rule) (*Backend, error) {
+	b := &Backend{}
+	for _, r := range rules {
+		c, err := compile(r)
+		if err != nil {
+			return nil, err
+		}
+		b.rules = append(b.rules, c)
+	}
+
+	return b, nil
+}
+
+// GetByMac implements the handler.BackendReader interface and returns the data for the most specific matching rule.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	_, span := tracer.Start(ctx, "backend.prefix.GetByMac")
+	defer span.End()
+
+	var match *compiled
+	for i := range b.rules {
+		if b.rules[i].matches(mac) && (match == nil || b.rules[i].specificity > match.specificity) {
+			match = &b.rules[i]
+		}
+	}
+	if match == nil {
+		err := data.HardwareNotFoundError{}
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	d := match.rule.DHCP
+	d.MACAddress = mac
+	n := match.rule.Netboot
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return &d, &n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface.
+// Patterns do not identify a single client so lookups by IP address always return a not found error.
+func (b *Backend) GetByIP(ctx context.Context, _ net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	_, span := tracer.Start(ctx, "backend.prefix.GetByIP")
+	defer span.End()
+
+	err := data.HardwareNotFoundError{}
+	span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+	return nil, nil, err
+}
+
+// matches returns true if mac matches the compiled pattern.
+func (c compiled) matches(mac net.HardwareAddr) bool {
+	if len(mac) != 6 {
+		return false
+	}
+	for i := range mac {
+		if !c.wildcard[i] && mac[i] != c.octets[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compile parses the pattern of r.
+func compile(r Rule) (compiled, error) {
+	c := compiled{rule: r}
+	parts := strings.FieldsFunc(r.Pattern, func(r rune) bool { return r == ':' || r == '-' })
+	if len(parts) == 0 || len(parts) > 6 {
+		return compiled{}, fmt.Errorf("%w: %q", errPattern, r.Pattern)
+	}
+	for i := range c.wildcard {
+		c.wildcard[i] = true
+	}
+	for i, p := range parts {
+		if p == "*" {
+			continue
+		}
+		v, err := strconv.ParseUint(p, 16, 8)
+		if err != nil || len(p) > 2 {
+			return compiled{}, fmt.Errorf("%w: %q", errPattern, r.Pattern)
+		}
+		c.octets[i] = byte(v)
+		c.wildcard[i] = false
+		c.specificity++
+	}
+
+	return c, nil
+}
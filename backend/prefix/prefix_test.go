@@ -0,0 +1,87 @@
+package prefix
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/tinkerbell/dhcp/data"
+)
+
+func TestNewBackend(t *testing.T) {
+	tests := map[string]struct {
+		pattern string
+		wantErr error
+	}{
+		"oui":              {pattern: "00:25:90"},
+		"dash separated":   {pattern: "00-25-90"},
+		"wildcards":        {pattern: "00:25:90:*:*:*"},
+		"match all":        {pattern: "*"},
+		"empty":            {pattern: "", wantErr: errPattern},
+		"too many octets":  {pattern: "00:01:02:03:04:05:06", wantErr: errPattern},
+		"not hex":          {pattern: "zz:25:90", wantErr: errPattern},
+		"octet too long":   {pattern: "025:90", wantErr: errPattern},
+		"partial wildcard": {pattern: "0*:25:90", wantErr: errPattern},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewBackend(Rule{Pattern: tt.pattern}); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewBackend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetByMac(t *testing.T) {
+	rules := []Rule{
+		{Pattern: "*", DHCP: data.DHCP{Hostname: "catch-all"}},
+		{Pattern: "00:25:90", DHCP: data.DHCP{Hostname: "supermicro"}, Netboot: data.Netboot{AllowNetboot: true}},
+		{Pattern: "00:25:90:*:*:01", DHCP: data.DHCP{Hostname: "supermicro-01"}},
+		{Pattern: "*:*:*:*:*:02", DHCP: data.DHCP{Hostname: "ends-with-02"}},
+	}
+	tests := map[string]struct {
+		rules        []Rule
+		mac          net.HardwareAddr
+		wantHostname string
+		wantErr      error
+	}{
+		"oui match":        {rules: rules, mac: net.HardwareAddr{0x00, 0x25, 0x90, 0xaa, 0xbb, 0xcc}, wantHostname: "supermicro"},
+		"most specific":    {rules: rules, mac: net.HardwareAddr{0x00, 0x25, 0x90, 0xaa, 0xbb, 0x01}, wantHostname: "supermicro-01"},
+		"tie uses order":   {rules: rules, mac: net.HardwareAddr{0x00, 0x25, 0x90, 0xaa, 0xbb, 0x02}, wantHostname: "supermicro"},
+		"catch all":        {rules: rules, mac: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67}, wantHostname: "catch-all"},
+		"no match":         {rules: rules[1:], mac: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67}, wantErr: data.HardwareNotFoundError{}},
+		"not a 48 bit mac": {rules: rules, mac: net.HardwareAddr{0x00, 0x25, 0x90}, wantErr: data.HardwareNotFoundError{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := NewBackend(tt.rules...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, _, err := b.GetByMac(context.Background(), tt.mac)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if d.Hostname != tt.wantHostname {
+				t.Errorf("got hostname %q, want %q", d.Hostname, tt.wantHostname)
+			}
+			if d.MACAddress.String() != tt.mac.String() {
+				t.Errorf("got mac %v, want %v", d.MACAddress, tt.mac)
+			}
+		})
+	}
+}
+
+func TestGetByIP(t *testing.T) {
+	b, err := NewBackend(Rule{Pattern: "*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 1)); !errors.Is(err, data.HardwareNotFoundError{}) {
+		t.Fatalf("GetByIP() error = %v, want not found", err)
+	}
+}
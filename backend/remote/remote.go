@@ -0,0 +1,247 @@
+// Package remote is a backend that periodically fetches a YAML or JSON document of reservations over HTTP(S)
+// and serves it like the file backend. Reservations can be published, for example from CI to an object store,
+// and picked up without a shared filesystem.
+//
+// The document uses the data model of the file backend, see backend/file/testdata/example.yaml.
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/dhcp/backend/file"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+// Default values used by NewBackend.
+const (
+	DefaultInterval = time.Minute
+	// DefaultMaxSize is the maximum size of a document.
+	DefaultMaxSize = 32 << 20
+)
+
+// Errors used by the remote backend.
+var (
+	errStatus  = errors.New("unexpected status code")
+	errTooBig  = errors.New("document is larger than the maximum size")
+	errNoFetch = fmt.Errorf("%w: document has not been fetched", data.ErrUnavailable)
+)
+
+// Backend serves the reservations in a document fetched from URL.
+//
+// The document is fetched again every Interval. Conditional requests, with the ETag and Last-Modified of the
+// last fetched document, avoid downloading a document that did not change. When a fetch fails or the fetched
+// document is invalid, the last good document continues to be served and Healthy returns an error.
+type Backend struct {
+	// URL is the URL of the document.
+	URL *url.URL
+
+	// Format is the format of the document. Defaults to file.FormatAuto,
+	// which detects the format from the extension of the URL path and the contents of the document.
+	Format file.Format
+
+	// Header is added to every request, for example an Authorization header.
+	Header http.Header
+
+	// Prepare, when set, is called with every request before it is sent, for example to sign the request.
+	Prepare func(*http.Request) error
+
+	// Client is the HTTP client used to fetch the document.
+	Client *http.Client
+
+	// Interval is the time between fetches.
+	Interval time.Duration
+
+	// MaxSize is the maximum size, in bytes, of a document.
+	MaxSize int64
+
+	// Log is the logger to be used in the remote backend.
+	Log logr.Logger
+
+	mu           sync.Mutex // protects the fields below
+	files        *file.Watcher
+	etag         string
+	lastModified string
+	fetchErr     error
+	fetched      time.Time
+}
+
+// NewBackend returns a Backend for the document at u. The document is not fetched until Refresh or Start is called.
+func NewBackend(l logr.Logger, u *url.URL) *Backend {
+	return &Backend{
+		URL:      u,
+		Client:   http.DefaultClient,
+		Interval: DefaultInterval,
+		MaxSize:  DefaultMaxSize,
+		Log:      l,
+	}
+}
+
+// Start fetches the document every Interval until ctx is done.
+// Start is a blocking method. Use a context cancellation to exit.
+func (b *Backend) Start(ctx context.Context) {
+	interval := b.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := b.Refresh(ctx); err != nil && ctx.Err() == nil {
+			b.Log.Error(err, "failed to refresh document, serving last good data", "url", b.URL.Redacted())
+		}
+		select {
+		case <-ctx.Done():
+			b.Log.Info("stopping remote backend")
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Refresh fetches the document now. It is called by Start and can also be called when the document
+// is known to have changed, for example on an object store notification.
+func (b *Backend) Refresh(ctx context.Context) error {
+	err := b.refresh(ctx)
+	b.mu.Lock()
+	b.fetchErr = err
+	b.mu.Unlock()
+
+	return err
+}
+
+func (b *Backend) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.URL.String(), nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range b.Header {
+		req.Header[k] = v
+	}
+	b.mu.Lock()
+	if b.files != nil {
+		if b.etag != "" {
+			req.Header.Set("If-None-Match", b.etag)
+		}
+		if b.lastModified != "" {
+			req.Header.Set("If-Modified-Since", b.lastModified)
+		}
+	}
+	b.mu.Unlock()
+	if b.Prepare != nil {
+		if err := b.Prepare(req); err != nil {
+			return err
+		}
+	}
+
+	c := b.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		b.mu.Lock()
+		b.fetched = time.Now()
+		b.mu.Unlock()
+		return nil
+	default:
+		return fmt.Errorf("%w: %d", errStatus, resp.StatusCode)
+	}
+
+	maxSize := b.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > maxSize {
+		return fmt.Errorf("%w: %d bytes", errTooBig, maxSize)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.files == nil {
+		b.files = &file.Watcher{FilePath: b.URL.Redacted(), Format: b.Format, Log: b.Log}
+	}
+	if err := b.files.Update(b.URL.Path, body); err != nil {
+		return err
+	}
+	b.etag = resp.Header.Get("ETag")
+	b.lastModified = resp.Header.Get("Last-Modified")
+	b.fetched = time.Now()
+	b.Log.Info("fetched document", "url", b.URL.Redacted(), "etag", b.etag)
+
+	return nil
+}
+
+// watcher returns the file watcher that serves the last good document, or nil if no document was fetched.
+func (b *Backend) watcher() *file.Watcher {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.files
+}
+
+// GetByMac implements the handler.BackendReader interface. It reads the last valid document that was fetched, so a
+// failed or invalid fetch does not change what is served. A MAC address that is not in the document is a
+// data.ErrNotFound error, and every read before the first valid fetch is a data.ErrUnavailable error.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	w := b.watcher()
+	if w == nil {
+		return nil, nil, errNoFetch
+	}
+
+	return w.GetByMac(ctx, mac)
+}
+
+// GetByIP implements the handler.BackendReader interface. It reads the last valid document that was fetched like
+// GetByMac.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	w := b.watcher()
+	if w == nil {
+		return nil, nil, errNoFetch
+	}
+
+	return w.GetByIP(ctx, ip)
+}
+
+// Healthy implements the handler.HealthChecker interface.
+// It returns an error if no document was fetched yet, or if the last fetch failed or returned an invalid document.
+func (b *Backend) Healthy(ctx context.Context) error {
+	b.mu.Lock()
+	err, w := b.fetchErr, b.files
+	b.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("last fetch failed, serving last good data: %w", err)
+	}
+	if w == nil {
+		return errNoFetch
+	}
+
+	return w.Healthy(ctx)
+}
+
+// LastFetched returns the time the document was last fetched successfully, or confirmed to be unchanged.
+func (b *Backend) LastFetched() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.fetched
+}
@@ -0,0 +1,197 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+var mac = net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+func document(ip string) string {
+	return fmt.Sprintf(`{"00:00:00:00:00:01": {"ipAddress": %q, "subnetMask": "255.255.255.0"}}`, ip)
+}
+
+// server is a fake HTTP server that serves body with an ETag and answers conditional requests.
+type server struct {
+	mu          sync.Mutex
+	body        string
+	etag        string
+	status      int
+	requests    int
+	notModified int
+	header      http.Header
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	s.header = r.Header.Clone()
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	if r.Header.Get("If-None-Match") == s.etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", s.etag)
+	fmt.Fprint(w, s.body)
+}
+
+func (s *server) set(body, etag string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.body, s.etag, s.status = body, etag, status
+}
+
+func newBackend(t *testing.T, s *server) *Backend {
+	t.Helper()
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	u, err := url.Parse(ts.URL + "/hardware.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewBackend(logr.Discard(), u)
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	s := &server{body: document("192.168.2.1"), etag: `"v1"`}
+	b := newBackend(t, s)
+	b.Header = http.Header{"Authorization": {"Bearer token"}}
+
+	if _, _, err := b.GetByMac(ctx, mac); !errors.Is(err, errNoFetch) {
+		t.Fatalf("GetByMac() error = %v, wantErr %v", err, errNoFetch)
+	}
+	if err := b.Healthy(ctx); !errors.Is(err, errNoFetch) {
+		t.Fatalf("Healthy() error = %v, wantErr %v", err, errNoFetch)
+	}
+
+	if err := b.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ip := func() string {
+		d, _, err := b.GetByMac(ctx, mac)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.IPAddress.String()
+	}
+	if got := ip(); got != "192.168.2.1" {
+		t.Fatalf("got IP %v, want 192.168.2.1", got)
+	}
+	if got := s.header.Get("Authorization"); got != "Bearer token" {
+		t.Fatalf("got Authorization %q, want the configured header", got)
+	}
+
+	// an unchanged document is not downloaded again.
+	if err := b.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.notModified != 1 {
+		t.Fatalf("got %d not modified responses, want 1", s.notModified)
+	}
+
+	s.set(document("192.168.2.2"), `"v2"`, 0)
+	if err := b.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := ip(); got != "192.168.2.2" {
+		t.Fatalf("got IP %v, want 192.168.2.2", got)
+	}
+	if err := b.Healthy(ctx); err != nil {
+		t.Fatalf("Healthy() error = %v, want nil", err)
+	}
+
+	// failed fetches and invalid documents keep the last good data.
+	for name, update := range map[string]func(){
+		"server error":     func() { s.set(document("192.168.2.3"), `"v3"`, http.StatusInternalServerError) },
+		"invalid document": func() { s.set("{", `"v4"`, 0) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			update()
+			if err := b.Refresh(ctx); err == nil {
+				t.Fatal("Refresh() expected error")
+			}
+			if got := ip(); got != "192.168.2.2" {
+				t.Fatalf("got IP %v, want the last good data", got)
+			}
+			if err := b.Healthy(ctx); err == nil {
+				t.Fatal("Healthy() expected error")
+			}
+		})
+	}
+}
+
+func TestRefreshTooBig(t *testing.T) {
+	b := newBackend(t, &server{body: document("192.168.2.1"), etag: `"v1"`})
+	b.MaxSize = 10
+	if err := b.Refresh(context.Background()); !errors.Is(err, errTooBig) {
+		t.Fatalf("Refresh() error = %v, wantErr %v", err, errTooBig)
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	s := &server{body: document("192.168.2.1"), etag: `"v1"`}
+	b := newBackend(t, s)
+	b.Prepare = func(r *http.Request) error {
+		r.Header.Set("X-Signed", "yes")
+		return nil
+	}
+	if err := b.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s.header.Get("X-Signed") != "yes" {
+		t.Fatal("request was not prepared")
+	}
+
+	wantErr := errors.New("no credentials")
+	b.Prepare = func(*http.Request) error { return wantErr }
+	if err := b.Refresh(context.Background()); !errors.Is(err, wantErr) {
+		t.Fatalf("Refresh() error = %v, wantErr %v", err, wantErr)
+	}
+}
+
+func TestStart(t *testing.T) {
+	s := &server{body: document("192.168.2.1"), etag: `"v1"`}
+	b := newBackend(t, s)
+	b.Interval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Start(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		n := s.notModified
+		s.mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("document was not polled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if b.LastFetched().IsZero() {
+		t.Fatal("LastFetched() is zero after successful fetches")
+	}
+}
@@ -0,0 +1,281 @@
+// Package resilient is a backend that adds per request timeouts, retries with exponential backoff,
+// and a circuit breaker to another backend.
+//
+// It is meant to wrap backends that call a remote service, for example a gRPC or HTTP API,
+// so that a slow or unavailable service does not stall every DHCP request.
+package resilient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+// Default values used by NewBackend.
+const (
+	DefaultTimeout          = 2 * time.Second
+	DefaultMaxRetries       = 2
+	DefaultInitialBackoff   = 100 * time.Millisecond
+	DefaultMaxBackoff       = 2 * time.Second
+	DefaultFailureThreshold = 5
+	DefaultOpenDuration     = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned, without calling the wrapped backend, while the circuit breaker is open.
+// It wraps data.ErrUnavailable.
+var ErrCircuitOpen = fmt.Errorf("%w: circuit breaker is open", data.ErrUnavailable)
+
+// State is the state of the circuit breaker.
+type State int
+
+// Circuit breaker states.
+const (
+	// StateClosed passes all reads to the wrapped backend.
+	StateClosed State = iota
+	// StateOpen fails all reads with ErrCircuitOpen.
+	StateOpen
+	// StateHalfOpen passes a single trial read to the wrapped backend. Its result closes or opens the circuit.
+	StateHalfOpen
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// Backend wraps a handler.BackendReader with timeouts, retries, and a circuit breaker.
+//
+// Every attempt is bounded by Timeout. Attempts that fail with a Retryable error are retried up to MaxRetries times,
+// waiting an exponentially increasing backoff, starting at InitialBackoff and capped at MaxBackoff, between attempts.
+// After FailureThreshold consecutive failed reads the circuit opens and reads fail fast with ErrCircuitOpen.
+// After OpenDuration a single trial read is allowed; if it succeeds the circuit closes, otherwise it opens again.
+//
+// A not found error (see data.IsNotFound) is a successful read for the circuit breaker and is never retried.
+type Backend struct {
+	// Backend is the wrapped backend.
+	Backend handler.BackendReader
+
+	// Timeout bounds each attempt. A zero value means no timeout.
+	Timeout time.Duration
+
+	// MaxRetries is the number of times a failed attempt is retried. A zero value disables retries.
+	MaxRetries int
+
+	// InitialBackoff is the wait before the first retry. It doubles for each following retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between retries.
+	MaxBackoff time.Duration
+
+	// Retryable reports whether an error is transient and the attempt should be retried.
+	// Defaults to retrying every error. Not found, unauthorized, and invalid record errors are never retried. Backends that call a gRPC API can,
+	// for example, only retry the Unavailable, DeadlineExceeded, and ResourceExhausted status codes.
+	Retryable func(error) bool
+
+	// FailureThreshold is the number of consecutive failed reads that opens the circuit. A zero value disables the circuit breaker.
+	FailureThreshold int
+
+	// OpenDuration is how long the circuit stays open before a trial read is allowed.
+	OpenDuration time.Duration
+
+	mu       sync.Mutex // protects state, failures, openedAt, and trial
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial read is in progress
+}
+
+// NewBackend returns a Backend wrapping b with the default timeout, retry, and circuit breaker settings.
+func NewBackend(b handler.BackendReader) *Backend {
+	return &Backend{
+		Backend:          b,
+		Timeout:          DefaultTimeout,
+		MaxRetries:       DefaultMaxRetries,
+		InitialBackoff:   DefaultInitialBackoff,
+		MaxBackoff:       DefaultMaxBackoff,
+		FailureThreshold: DefaultFailureThreshold,
+		OpenDuration:     DefaultOpenDuration,
+	}
+}
+
+// GetByMac implements the handler.BackendReader interface.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.resilient.GetByMac")
+	defer span.End()
+
+	return b.read(ctx, span, func(ctx context.Context) (*data.DHCP, *data.Netboot, error) {
+		return b.Backend.GetByMac(ctx, mac)
+	})
+}
+
+// GetByIP implements the handler.BackendReader interface.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.resilient.GetByIP")
+	defer span.End()
+
+	return b.read(ctx, span, func(ctx context.Context) (*data.DHCP, *data.Netboot, error) {
+		return b.Backend.GetByIP(ctx, ip)
+	})
+}
+
+// State returns the current state of the circuit breaker.
+func (b *Backend) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.OpenDuration {
+		return StateHalfOpen
+	}
+
+	return b.state
+}
+
+// Healthy implements the handler.HealthChecker interface. It returns ErrCircuitOpen while the circuit breaker is open.
+// Otherwise it returns the health of the wrapped backend, if the wrapped backend implements handler.HealthChecker.
+func (b *Backend) Healthy(ctx context.Context) error {
+	if b.State() == StateOpen {
+		return ErrCircuitOpen
+	}
+	if hc, ok := b.Backend.(handler.HealthChecker); ok {
+		return hc.Healthy(ctx)
+	}
+
+	return nil
+}
+
+// read calls get, with retries, if the circuit breaker allows it and records the result in the circuit breaker.
+func (b *Backend) read(ctx context.Context, span trace.Span, get func(context.Context) (*data.DHCP, *data.Netboot, error)) (*data.DHCP, *data.Netboot, error) {
+	state, ok := b.allow()
+	span.SetAttributes(attribute.String("resilient.circuit.state", state.String()))
+	if !ok {
+		span.SetStatus(codes.Error, ErrCircuitOpen.Error())
+
+		return nil, nil, ErrCircuitOpen
+	}
+
+	d, n, attempts, err := b.retry(ctx, get)
+	b.record(err)
+	span.SetAttributes(attribute.Int("resilient.attempts", attempts))
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// retry calls get until it succeeds, returns an error that is not retryable, or MaxRetries is reached.
+// It returns the number of attempts made.
+func (b *Backend) retry(ctx context.Context, get func(context.Context) (*data.DHCP, *data.Netboot, error)) (*data.DHCP, *data.Netboot, int, error) {
+	backoff := b.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		d, n, err := b.attempt(ctx, get)
+		if err == nil || attempt > b.MaxRetries || !b.retryable(err) || ctx.Err() != nil {
+			return d, n, attempt, err
+		}
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, nil, attempt, err
+		case <-t.C:
+		}
+		backoff *= 2
+		if b.MaxBackoff > 0 && backoff > b.MaxBackoff {
+			backoff = b.MaxBackoff
+		}
+	}
+}
+
+// attempt calls get, bounded by Timeout.
+func (b *Backend) attempt(ctx context.Context, get func(context.Context) (*data.DHCP, *data.Netboot, error)) (*data.DHCP, *data.Netboot, error) {
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+
+	return get(ctx)
+}
+
+// retryable reports whether err should be retried.
+func (b *Backend) retryable(err error) bool {
+	if data.IsNotFound(err) || data.IsUnauthorized(err) || data.IsInvalidRecord(err) {
+		return false
+	}
+	if b.Retryable != nil {
+		return b.Retryable(err)
+	}
+
+	return true
+}
+
+// allow reports whether a read is allowed by the circuit breaker and returns the state the read is made in.
+func (b *Backend) allow() (State, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.OpenDuration {
+			return StateOpen, false
+		}
+		b.state = StateHalfOpen
+		b.trial = true
+
+		return StateHalfOpen, true
+	case StateHalfOpen:
+		if b.trial {
+			return StateHalfOpen, false
+		}
+		b.trial = true
+
+		return StateHalfOpen, true
+	}
+
+	return StateClosed, true
+}
+
+// record updates the circuit breaker with the result of a read.
+func (b *Backend) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if err == nil || data.IsNotFound(err) {
+		b.state = StateClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == StateHalfOpen || (b.FailureThreshold > 0 && b.failures >= b.FailureThreshold) {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
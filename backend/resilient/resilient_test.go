@@ -0,0 +1,167 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tinkerbell/dhcp/data"
+)
+
+var errBackend = errors.New("backend error")
+
+type notFoundError struct{}
+
+func (notFoundError) NotFound() bool { return true }
+
+func (notFoundError) Error() string { return "not found" }
+
+// mockBackend fails the first failures calls with err and then succeeds.
+type mockBackend struct {
+	calls     atomic.Int32
+	failures  int32
+	err       error
+	delay     time.Duration
+	healthErr error
+}
+
+func (m *mockBackend) Healthy(context.Context) error {
+	return m.healthErr
+}
+
+func (m *mockBackend) read(ctx context.Context) (*data.DHCP, *data.Netboot, error) {
+	n := m.calls.Add(1)
+	if m.delay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(m.delay):
+		}
+	}
+	if n <= m.failures {
+		return nil, nil, m.err
+	}
+
+	return &data.DHCP{Hostname: "server-01"}, &data.Netboot{}, nil
+}
+
+func (m *mockBackend) GetByMac(ctx context.Context, _ net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	return m.read(ctx)
+}
+
+func (m *mockBackend) GetByIP(ctx context.Context, _ net.IP) (*data.DHCP, *data.Netboot, error) {
+	return m.read(ctx)
+}
+
+func TestRetry(t *testing.T) {
+	tests := map[string]struct {
+		backend   *mockBackend
+		retryable func(error) bool
+		wantCalls int32
+		wantErr   error
+	}{
+		"success":                     {backend: &mockBackend{}, wantCalls: 1},
+		"retried then success":        {backend: &mockBackend{failures: 2, err: errBackend}, wantCalls: 3},
+		"retries exhausted":           {backend: &mockBackend{failures: 5, err: errBackend}, wantCalls: 3, wantErr: errBackend},
+		"not found is not retried":    {backend: &mockBackend{failures: 5, err: notFoundError{}}, wantCalls: 1, wantErr: notFoundError{}},
+		"unauthorized is not retried": {backend: &mockBackend{failures: 5, err: data.ErrUnauthorized}, wantCalls: 1, wantErr: data.ErrUnauthorized},
+		"not retryable":               {backend: &mockBackend{failures: 5, err: errBackend}, retryable: func(error) bool { return false }, wantCalls: 1, wantErr: errBackend},
+		"timeout is retried":          {backend: &mockBackend{failures: 1, delay: 50 * time.Millisecond}, wantCalls: 3, wantErr: context.DeadlineExceeded},
+		"retryable func is consulted": {backend: &mockBackend{failures: 1, err: errBackend}, retryable: func(err error) bool { return errors.Is(err, errBackend) }, wantCalls: 2},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := NewBackend(tt.backend)
+			b.Timeout = 10 * time.Millisecond
+			b.InitialBackoff = time.Millisecond
+			b.Retryable = tt.retryable
+			d, _, err := b.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tt.backend.calls.Load(); got != tt.wantCalls {
+				t.Errorf("got %d calls, want %d", got, tt.wantCalls)
+			}
+			if tt.wantErr == nil && d.Hostname != "server-01" {
+				t.Errorf("got hostname %q, want %q", d.Hostname, "server-01")
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	m := &mockBackend{failures: 2, err: errBackend}
+	b := &Backend{Backend: m, FailureThreshold: 2, OpenDuration: 20 * time.Millisecond}
+	ip := net.IPv4(192, 168, 2, 10)
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := b.GetByIP(context.Background(), ip); !errors.Is(err, errBackend) {
+			t.Fatalf("GetByIP() error = %v, want %v", err, errBackend)
+		}
+	}
+	if got := b.State(); got != StateOpen {
+		t.Fatalf("got state %v, want %v", got, StateOpen)
+	}
+	if err := b.Healthy(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Healthy() error = %v, want %v", err, ErrCircuitOpen)
+	}
+	if _, _, err := b.GetByIP(context.Background(), ip); !errors.Is(err, ErrCircuitOpen) || !data.IsUnavailable(err) {
+		t.Fatalf("GetByIP() error = %v, want %v", err, ErrCircuitOpen)
+	}
+	if got := m.calls.Load(); got != 2 {
+		t.Fatalf("got %d calls, want 2, an open circuit must not call the backend", got)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if got := b.State(); got != StateHalfOpen {
+		t.Fatalf("got state %v, want %v", got, StateHalfOpen)
+	}
+	if _, _, err := b.GetByIP(context.Background(), ip); err != nil {
+		t.Fatalf("GetByIP() error = %v, want nil", err)
+	}
+	if got := b.State(); got != StateClosed {
+		t.Fatalf("got state %v, want %v", got, StateClosed)
+	}
+	if err := b.Healthy(context.Background()); err != nil {
+		t.Fatalf("Healthy() error = %v, want nil", err)
+	}
+}
+
+func TestCircuitBreakerTrialFails(t *testing.T) {
+	m := &mockBackend{failures: 10, err: errBackend}
+	b := &Backend{Backend: m, FailureThreshold: 1, OpenDuration: 10 * time.Millisecond}
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+
+	if _, _, err := b.GetByMac(context.Background(), mac); !errors.Is(err, errBackend) {
+		t.Fatalf("GetByMac() error = %v, want %v", err, errBackend)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, _, err := b.GetByMac(context.Background(), mac); !errors.Is(err, errBackend) {
+		t.Fatalf("trial GetByMac() error = %v, want %v", err, errBackend)
+	}
+	if got := b.State(); got != StateOpen {
+		t.Fatalf("got state %v, want %v", got, StateOpen)
+	}
+}
+
+func TestNotFoundDoesNotOpenCircuit(t *testing.T) {
+	b := &Backend{Backend: &mockBackend{failures: 10, err: notFoundError{}}, FailureThreshold: 1, OpenDuration: time.Minute}
+	for i := 0; i < 3; i++ {
+		if _, _, err := b.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}); !errors.Is(err, notFoundError{}) {
+			t.Fatalf("GetByMac() error = %v, want not found", err)
+		}
+	}
+	if got := b.State(); got != StateClosed {
+		t.Fatalf("got state %v, want %v", got, StateClosed)
+	}
+}
+
+func TestHealthyWrapped(t *testing.T) {
+	b := NewBackend(&mockBackend{healthErr: errBackend})
+	if err := b.Healthy(context.Background()); !errors.Is(err, errBackend) {
+		t.Fatalf("Healthy() error = %v, want %v", err, errBackend)
+	}
+}
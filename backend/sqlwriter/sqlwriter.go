@@ -0,0 +1,134 @@
+// Package sqlwriter records DHCP lease events (ACKs, releases, and declines) in a SQL database.
+//
+// It implements the handler.BackendWriter interface and works with any database/sql driver,
+// for example SQLite, so that systems downstream of the DHCP server can query what happened on the wire.
+// The driver must be registered, by importing it, by the caller.
+package sqlwriter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+	"time"
+
+	oteldhcp "github.com/tinkerbell/dhcp/otel"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+// DefaultTable is the table that NewWriter records events in.
+const DefaultTable = "dhcp_lease_events"
+
+// Event types recorded in the event column.
+const (
+	EventAck     = "ack"
+	EventRelease = "release"
+	EventDecline = "decline"
+)
+
+// Dialect is the bind parameter style of a database.
+type Dialect int
+
+const (
+	// DialectQuestion uses "?" bind parameters, as used by SQLite and MySQL.
+	DialectQuestion Dialect = iota
+	// DialectDollar uses "$1" style bind parameters, as used by PostgreSQL.
+	DialectDollar
+)
+
+// tableName is the allowed format of a table name. The table name is part of the query, it can't be a bind parameter.
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Writer records lease events as rows in a table with the columns time, event, mac, ip, and bootfile.
+type Writer struct {
+	// DB is the database to write to.
+	DB *sql.DB
+
+	// Table is the table that events are written to.
+	Table string
+
+	// Dialect is the bind parameter style of DB.
+	Dialect Dialect
+}
+
+// NewWriter returns a Writer that records events in DefaultTable of db, using "?" bind parameters.
+func NewWriter(db *sql.DB) *Writer {
+	return &Writer{DB: db, Table: DefaultTable, Dialect: DialectQuestion}
+}
+
+// CreateTable creates the events table if it does not exist.
+func (w *Writer) CreateTable(ctx context.Context) error {
+	if !tableName.MatchString(w.Table) {
+		return fmt.Errorf("invalid table name %q", w.Table)
+	}
+	q := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (time TIMESTAMP NOT NULL, event VARCHAR(16) NOT NULL, mac VARCHAR(64) NOT NULL, ip VARCHAR(64) NOT NULL, bootfile TEXT NOT NULL)", w.Table)
+	if _, err := w.DB.ExecContext(ctx, q); err != nil {
+		return fmt.Errorf("failed to create table %v: %w", w.Table, err)
+	}
+
+	return nil
+}
+
+// RecordAck implements the handler.BackendWriter interface.
+func (w *Writer) RecordAck(ctx context.Context, mac net.HardwareAddr, ip netip.Addr, bootfile string) error {
+	return w.insert(ctx, EventAck, mac, ip, bootfile)
+}
+
+// RecordRelease implements the handler.BackendWriter interface.
+func (w *Writer) RecordRelease(ctx context.Context, mac net.HardwareAddr, ip netip.Addr) error {
+	return w.insert(ctx, EventRelease, mac, ip, "")
+}
+
+// RecordDecline implements the handler.BackendWriter interface.
+func (w *Writer) RecordDecline(ctx context.Context, mac net.HardwareAddr, ip netip.Addr) error {
+	return w.insert(ctx, EventDecline, mac, ip, "")
+}
+
+// insert adds a row for event to the events table.
+func (w *Writer) insert(ctx context.Context, event string, mac net.HardwareAddr, ip netip.Addr, bootfile string) error {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.sqlwriter.Record")
+	defer span.End()
+	span.SetAttributes(attribute.String(oteldhcp.KeyEvent, event), attribute.String(oteldhcp.KeyMACAddress, redact.FromContext(ctx).MAC(mac)))
+
+	if !tableName.MatchString(w.Table) {
+		err := fmt.Errorf("invalid table name %q", w.Table)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return err
+	}
+	q := fmt.Sprintf("INSERT INTO %s (time, event, mac, ip, bootfile) VALUES (%s)", w.Table, w.placeholders(5))
+	if _, err := w.DB.ExecContext(ctx, q, time.Now().UTC(), event, mac.String(), ip.String(), bootfile); err != nil {
+		err = fmt.Errorf("failed to record %v for %v: %w", event, mac, err)
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return err
+	}
+	span.SetStatus(codes.Ok, "")
+
+	return nil
+}
+
+// placeholders returns n comma separated bind parameters in the style of the Dialect.
+func (w *Writer) placeholders(n int) string {
+	var s string
+	for i := 1; i <= n; i++ {
+		if i > 1 {
+			s += ", "
+		}
+		if w.Dialect == DialectDollar {
+			s += fmt.Sprintf("$%d", i)
+		} else {
+			s += "?"
+		}
+	}
+
+	return s
+}
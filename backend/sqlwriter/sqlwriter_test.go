@@ -0,0 +1,153 @@
+package sqlwriter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// recorder is a database/sql driver that records the statements it executes.
+type recorder struct {
+	mu    sync.Mutex
+	execs []exec
+	err   error
+}
+
+type exec struct {
+	query string
+	args  []driver.Value
+}
+
+func (r *recorder) Open(string) (driver.Conn, error) { return &conn{r: r}, nil }
+
+type conn struct{ r *recorder }
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) { return &stmt{r: c.r, query: query}, nil }
+func (c *conn) Close() error                              { return nil }
+func (c *conn) Begin() (driver.Tx, error)                 { return nil, errors.New("not implemented") }
+
+type stmt struct {
+	r     *recorder
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	if s.r.err != nil {
+		return nil, s.r.err
+	}
+	s.r.execs = append(s.r.execs, exec{query: s.query, args: args})
+
+	return driver.RowsAffected(1), nil
+}
+
+func (s *stmt) Query([]driver.Value) (driver.Rows, error) { return nil, errors.New("not implemented") }
+
+func newDB(t *testing.T, r *recorder) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(connector{r: r})
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+type connector struct{ r *recorder }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return &conn{r: c.r}, nil }
+func (c connector) Driver() driver.Driver                        { return c.r }
+
+func TestRecord(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	ip := netip.MustParseAddr("192.168.2.10")
+	tests := map[string]struct {
+		dialect   Dialect
+		record    func(*Writer) error
+		wantQuery string
+		wantArgs  []driver.Value
+	}{
+		"ack": {
+			record:    func(w *Writer) error { return w.RecordAck(context.Background(), mac, ip, "ipxe.efi") },
+			wantQuery: "INSERT INTO dhcp_lease_events (time, event, mac, ip, bootfile) VALUES (?, ?, ?, ?, ?)",
+			wantArgs:  []driver.Value{EventAck, "00:01:02:03:04:05", "192.168.2.10", "ipxe.efi"},
+		},
+		"release": {
+			record:    func(w *Writer) error { return w.RecordRelease(context.Background(), mac, ip) },
+			wantQuery: "INSERT INTO dhcp_lease_events (time, event, mac, ip, bootfile) VALUES (?, ?, ?, ?, ?)",
+			wantArgs:  []driver.Value{EventRelease, "00:01:02:03:04:05", "192.168.2.10", ""},
+		},
+		"decline with dollar dialect": {
+			dialect:   DialectDollar,
+			record:    func(w *Writer) error { return w.RecordDecline(context.Background(), mac, ip) },
+			wantQuery: "INSERT INTO dhcp_lease_events (time, event, mac, ip, bootfile) VALUES ($1, $2, $3, $4, $5)",
+			wantArgs:  []driver.Value{EventDecline, "00:01:02:03:04:05", "192.168.2.10", ""},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := &recorder{}
+			w := NewWriter(newDB(t, r))
+			w.Dialect = tt.dialect
+			if err := tt.record(w); err != nil {
+				t.Fatal(err)
+			}
+			if len(r.execs) != 1 {
+				t.Fatalf("got %d statements, want 1", len(r.execs))
+			}
+			if diff := cmp.Diff(r.execs[0].query, tt.wantQuery); diff != "" {
+				t.Error(diff)
+			}
+			if _, ok := r.execs[0].args[0].(time.Time); !ok {
+				t.Errorf("got time arg %T, want time.Time", r.execs[0].args[0])
+			}
+			if diff := cmp.Diff(r.execs[0].args[1:], tt.wantArgs); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	errDB := errors.New("database is locked")
+	w := NewWriter(newDB(t, &recorder{err: errDB}))
+	if err := w.RecordAck(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}, netip.MustParseAddr("192.168.2.10"), ""); !errors.Is(err, errDB) {
+		t.Fatalf("RecordAck() error = %v, want %v", err, errDB)
+	}
+}
+
+func TestInvalidTable(t *testing.T) {
+	r := &recorder{}
+	w := NewWriter(newDB(t, r))
+	w.Table = "events; DROP TABLE hardware"
+	if err := w.CreateTable(context.Background()); err == nil {
+		t.Fatal("CreateTable() expected error for invalid table name")
+	}
+	if err := w.RecordRelease(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}, netip.MustParseAddr("192.168.2.10")); err == nil {
+		t.Fatal("RecordRelease() expected error for invalid table name")
+	}
+	if len(r.execs) != 0 {
+		t.Fatalf("got %d statements, want 0", len(r.execs))
+	}
+}
+
+func TestCreateTable(t *testing.T) {
+	r := &recorder{}
+	if err := NewWriter(newDB(t, r)).CreateTable(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := "CREATE TABLE IF NOT EXISTS dhcp_lease_events (time TIMESTAMP NOT NULL, event VARCHAR(16) NOT NULL, mac VARCHAR(64) NOT NULL, ip VARCHAR(64) NOT NULL, bootfile TEXT NOT NULL)"
+	if len(r.execs) != 1 || r.execs[0].query != want {
+		t.Fatalf("got %+v, want a single %q", r.execs, want)
+	}
+}
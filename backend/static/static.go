@@ -0,0 +1,151 @@
+// Package static is a backend whose reservations are declared in Go code when the backend is created.
+// It is useful for embedded appliances and for tests that do not want a file or a cluster.
+package static
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+// Errors returned by New.
+var (
+	errNoMAC        = errors.New("record has no MAC address")
+	errDuplicateMAC = errors.New("duplicate MAC address")
+	errDuplicateIP  = errors.New("duplicate IP address")
+)
+
+// Option configures a Backend. Record is an Option.
+type Option interface {
+	apply(*Backend) error
+}
+
+// optionFunc is an Option implemented by a function.
+type optionFunc func(*Backend) error
+
+func (f optionFunc) apply(b *Backend) error { return f(b) }
+
+// Record is a reservation. DHCP.MACAddress is required and identifies the record.
+// Records without a DHCP.IPAddress are not found by GetByIP.
+type Record struct {
+	DHCP    data.DHCP
+	Netboot data.Netboot
+}
+
+func (r Record) apply(b *Backend) error {
+	if len(r.DHCP.MACAddress) == 0 {
+		return errNoMAC
+	}
+	mac := r.DHCP.MACAddress.String()
+	if _, ok := b.byMAC[mac]; ok {
+		return fmt.Errorf("%w: %v", errDuplicateMAC, mac)
+	}
+	if ip := r.DHCP.IPAddress.Unmap(); ip.IsValid() {
+		if _, ok := b.byIP[ip]; ok {
+			return fmt.Errorf("%w: %v", errDuplicateIP, ip)
+		}
+		b.byIP[ip] = r
+	}
+	b.byMAC[mac] = r
+
+	return nil
+}
+
+// WithRecords adds records to the Backend. It is useful when the records are built in a loop.
+func WithRecords(records ...Record) Option {
+	return optionFunc(func(b *Backend) error {
+		for _, r := range records {
+			if err := r.apply(b); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Backend serves a fixed set of Records. It is safe for concurrent use.
+type Backend struct {
+	byMAC map[string]Record
+	byIP  map[netip.Addr]Record
+}
+
+// New returns a Backend with the records in opts, for example:
+//
+//	d, err := data.NewDHCP(mac, ip, mask, data.WithDefaultGateways(gw))
+//	...
+//	b, err := static.New(
+//		static.Record{DHCP: *d},
+//		static.WithRecords(more...),
+//	)
+//
+// An error is returned if a record has no MAC address, or if two records have the same MAC address or IP address.
+func New(opts ...Option) (*Backend, error) {
+	b := &Backend{byMAC: make(map[string]Record), byIP: make(map[netip.Addr]Record)}
+	for _, o := range opts {
+		if err := o.apply(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// GetByMac implements the handler.BackendReader interface. It returns a copy of the record that was added for mac, so
+// callers can not change the records of b. A MAC address without a record is a data.ErrNotFound error.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	_, span := tracer.Start(ctx, "backend.static.GetByMac")
+	defer span.End()
+
+	r, ok := b.byMAC[mac.String()]
+	if !ok {
+		err := data.HardwareNotFoundError{}
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	d, n := r.DHCP, r.Netboot
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return &d, &n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface. It returns a copy of the record whose IP address is ip,
+// IPv4-mapped IPv6 addresses included. An IP address without a record is a data.ErrNotFound error.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	_, span := tracer.Start(ctx, "backend.static.GetByIP")
+	defer span.End()
+
+	addr, ok := netip.AddrFromSlice(ip)
+	var r Record
+	if ok {
+		r, ok = b.byIP[addr.Unmap()]
+	}
+	if !ok {
+		err := data.HardwareNotFoundError{}
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	d, n := r.DHCP, r.Netboot
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return &d, &n, nil
+}
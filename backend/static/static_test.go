@@ -0,0 +1,126 @@
+package static
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/tinkerbell/dhcp/data"
+)
+
+var (
+	mac1 = net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	mac2 = net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x06}
+	ip1  = netip.MustParseAddr("192.168.2.10")
+	ip2  = netip.MustParseAddr("192.168.2.11")
+)
+
+func TestNew(t *testing.T) {
+	tests := map[string]struct {
+		opts    []Option
+		wantErr error
+	}{
+		"no records": {},
+		"records": {opts: []Option{
+			Record{DHCP: data.DHCP{MACAddress: mac1, IPAddress: ip1}},
+			WithRecords(Record{DHCP: data.DHCP{MACAddress: mac2, IPAddress: ip2}}),
+		}},
+		"no ip address": {opts: []Option{Record{DHCP: data.DHCP{MACAddress: mac1}}, Record{DHCP: data.DHCP{MACAddress: mac2}}}},
+		"no mac":        {opts: []Option{Record{DHCP: data.DHCP{IPAddress: ip1}}}, wantErr: errNoMAC},
+		"duplicate mac": {
+			opts:    []Option{Record{DHCP: data.DHCP{MACAddress: mac1}}, WithRecords(Record{DHCP: data.DHCP{MACAddress: mac1}})},
+			wantErr: errDuplicateMAC,
+		},
+		"duplicate ip": {
+			opts:    []Option{Record{DHCP: data.DHCP{MACAddress: mac1, IPAddress: ip1}}, Record{DHCP: data.DHCP{MACAddress: mac2, IPAddress: ip1}}},
+			wantErr: errDuplicateIP,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(tt.opts...); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	b, err := New(
+		Record{DHCP: data.DHCP{MACAddress: mac1, IPAddress: ip1, Hostname: "one"}, Netboot: data.Netboot{AllowNetboot: true}},
+		Record{DHCP: data.DHCP{MACAddress: mac2, Hostname: "two"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		get          func() (*data.DHCP, *data.Netboot, error)
+		wantHostname string
+		wantNetboot  bool
+		wantErr      error
+	}{
+		"by mac": {
+			get:          func() (*data.DHCP, *data.Netboot, error) { return b.GetByMac(context.Background(), mac1) },
+			wantHostname: "one",
+			wantNetboot:  true,
+		},
+		"by mac without ip": {
+			get:          func() (*data.DHCP, *data.Netboot, error) { return b.GetByMac(context.Background(), mac2) },
+			wantHostname: "two",
+		},
+		"by ip": {
+			get: func() (*data.DHCP, *data.Netboot, error) {
+				return b.GetByIP(context.Background(), net.ParseIP("192.168.2.10"))
+			},
+			wantHostname: "one",
+			wantNetboot:  true,
+		},
+		"mac not found": {
+			get: func() (*data.DHCP, *data.Netboot, error) {
+				return b.GetByMac(context.Background(), net.HardwareAddr{0, 0, 0, 0, 0, 1})
+			},
+			wantErr: data.HardwareNotFoundError{},
+		},
+		"ip not found": {
+			get: func() (*data.DHCP, *data.Netboot, error) {
+				return b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 11))
+			},
+			wantErr: data.HardwareNotFoundError{},
+		},
+		"invalid ip": {
+			get:     func() (*data.DHCP, *data.Netboot, error) { return b.GetByIP(context.Background(), nil) },
+			wantErr: data.HardwareNotFoundError{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, n, err := tt.get()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if d.Hostname != tt.wantHostname || n.AllowNetboot != tt.wantNetboot {
+				t.Fatalf("got hostname %q, netboot %v, want %q, %v", d.Hostname, n.AllowNetboot, tt.wantHostname, tt.wantNetboot)
+			}
+		})
+	}
+}
+
+func TestGetReturnsCopy(t *testing.T) {
+	b, err := New(Record{DHCP: data.DHCP{MACAddress: mac1, Hostname: "one"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _, err := b.GetByMac(context.Background(), mac1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Hostname = "changed"
+	if d, _, _ := b.GetByMac(context.Background(), mac1); d.Hostname != "one" {
+		t.Fatalf("got hostname %q, a caller changing the returned data must not change the record", d.Hostname)
+	}
+}
@@ -0,0 +1,159 @@
+// Package subnet is a backend that adds subnet level defaults to the per host records of another backend.
+package subnet
+
+import (
+	"context"
+	"net"
+	"net/netip"
+
+	"github.com/tinkerbell/dhcp/backend/merge"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler"
+	"github.com/tinkerbell/dhcp/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/tinkerbell/dhcp"
+
+// Subnet holds the DHCP data shared by all hosts in a CIDR.
+type Subnet struct {
+	// Prefix is the CIDR of the subnet. The subnet mask (DHCP option 1) and
+	// broadcast address (DHCP option 28) are computed from it.
+	Prefix netip.Prefix
+
+	// Interface is the name of the interface that the server receives the messages of the hosts on the link of the
+	// subnet on, for example eth1. It is only needed when the server listens on more than one link.
+	Interface string
+
+	DefaultGateways []netip.Addr // DHCP option 3, in order of preference.
+	NameServers     []net.IP     // DHCP option 6.
+	DomainName      string       // DHCP option 15.
+	NTPServers      []net.IP     // DHCP option 42.
+	LeaseTime       uint32       // DHCP option 51.
+	DomainSearch    []string     // DHCP option 119.
+}
+
+// Backend reads a per host record from Backend and fills in the fields the record does not set from a Subnet.
+// Values in the per host record always take precedence.
+//
+// The subnet is selected from the requesting context of the DHCP message that the read is for, see data.FromContext,
+// like a DHCP server selects the subnet of a client (https://www.rfc-editor.org/rfc/rfc2131.html#section-4.3.1):
+// the subnet that contains the relay agent address (giaddr) of a relayed message, or else the subnet of the
+// interface that the message was received on. When there is no such subnet, for example for a read that is not made
+// for a DHCP message, the subnet that contains the record's IP address is used. When multiple subnets contain an IP
+// address, the most specific (longest prefix) subnet is used.
+type Backend struct {
+	// Backend is the backend that provides the per host records.
+	Backend handler.BackendReader
+
+	// Subnets are the subnet level defaults.
+	Subnets []Subnet
+}
+
+// GetByMac implements the handler.BackendReader interface.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.subnet.GetByMac")
+	defer span.End()
+
+	d, n, err := b.Backend.GetByMac(ctx, mac)
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	d = b.apply(ctx, d)
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.subnet.GetByIP")
+	defer span.End()
+
+	d, n, err := b.Backend.GetByIP(ctx, ip)
+	if err != nil {
+		span.SetStatus(codes.Error, redact.FromContext(ctx).Error(err))
+
+		return nil, nil, err
+	}
+	d = b.apply(ctx, d)
+
+	span.SetAttributes(redact.FromContext(ctx).Attributes(d.EncodeToAttributes())...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// apply returns a copy of d with the unset fields filled in from the subnet of the read with ctx.
+func (b *Backend) apply(ctx context.Context, d *data.DHCP) *data.DHCP {
+	s, ok := b.lookup(ctx, d.IPAddress)
+	if !ok {
+		return d
+	}
+
+	return merge.DHCP(d, s.toDHCP())
+}
+
+// lookup returns the subnet of the relay agent of the DHCP message of ctx, the subnet of the interface it was received
+// on, or the subnet containing ip, in that order.
+func (b *Backend) lookup(ctx context.Context, ip netip.Addr) (Subnet, bool) {
+	if p, ok := data.FromContext(ctx); ok {
+		if p.Pkt != nil {
+			if giaddr, ok := netip.AddrFromSlice(p.Pkt.GatewayIPAddr.To4()); ok && !giaddr.IsUnspecified() {
+				if s, ok := b.containing(giaddr); ok {
+					return s, true
+				}
+			}
+		}
+		if p.Md != nil && p.Md.IfName != "" {
+			for _, s := range b.Subnets {
+				if s.Interface == p.Md.IfName {
+					return s, true
+				}
+			}
+		}
+	}
+
+	return b.containing(ip)
+}
+
+// containing returns the most specific subnet containing ip.
+func (b *Backend) containing(ip netip.Addr) (Subnet, bool) {
+	var match Subnet
+	var found bool
+	for _, s := range b.Subnets {
+		if s.Prefix.Contains(ip) && (!found || s.Prefix.Bits() > match.Prefix.Bits()) {
+			match = s
+			found = true
+		}
+	}
+
+	return match, found
+}
+
+// toDHCP converts s to a data.DHCP, computing the subnet mask and broadcast address from s.Prefix.
+func (s Subnet) toDHCP() *data.DHCP {
+	d := &data.DHCP{
+		DefaultGateways: s.DefaultGateways,
+		NameServers:     s.NameServers,
+		DomainName:      s.DomainName,
+		NTPServers:      s.NTPServers,
+		LeaseTime:       s.LeaseTime,
+		DomainSearch:    s.DomainSearch,
+	}
+	if s.Prefix.Addr().Is4() {
+		d.SubnetMask = net.CIDRMask(s.Prefix.Bits(), 32)
+		d.BroadcastAddress = data.Broadcast(s.Prefix.Addr(), d.SubnetMask)
+	}
+
+	return d
+}
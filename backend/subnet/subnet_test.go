@@ -0,0 +1,139 @@
+package subnet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+var errBackend = errors.New("backend error")
+
+type mockBackend struct {
+	dhcp *data.DHCP
+	err  error
+}
+
+func (m *mockBackend) GetByMac(context.Context, net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	return m.dhcp, &data.Netboot{}, m.err
+}
+
+func (m *mockBackend) GetByIP(context.Context, net.IP) (*data.DHCP, *data.Netboot, error) {
+	return m.dhcp, &data.Netboot{}, m.err
+}
+
+func TestGetByMac(t *testing.T) {
+	subnets := []Subnet{
+		{
+			Prefix:          netip.MustParsePrefix("192.168.0.0/16"),
+			DefaultGateways: []netip.Addr{netip.MustParseAddr("192.168.0.1")},
+			LeaseTime:       3600,
+		},
+		{
+			Prefix:          netip.MustParsePrefix("192.168.2.0/24"),
+			DefaultGateways: []netip.Addr{netip.MustParseAddr("192.168.2.1"), netip.MustParseAddr("192.168.2.2")},
+			NameServers:     []net.IP{{1, 1, 1, 1}},
+			NTPServers:      []net.IP{{132, 163, 96, 2}},
+			LeaseTime:       86400,
+			DomainSearch:    []string{"example.com"},
+		},
+	}
+	tests := map[string]struct {
+		host    *data.DHCP
+		err     error
+		want    *data.DHCP
+		wantErr error
+	}{
+		"most specific subnet": {
+			host: &data.DHCP{IPAddress: netip.MustParseAddr("192.168.2.10"), Hostname: "server-01"},
+			want: &data.DHCP{
+				IPAddress:        netip.MustParseAddr("192.168.2.10"),
+				SubnetMask:       net.IPv4Mask(255, 255, 255, 0),
+				DefaultGateways:  []netip.Addr{netip.MustParseAddr("192.168.2.1"), netip.MustParseAddr("192.168.2.2")},
+				NameServers:      []net.IP{{1, 1, 1, 1}},
+				Hostname:         "server-01",
+				BroadcastAddress: netip.MustParseAddr("192.168.2.255"),
+				NTPServers:       []net.IP{{132, 163, 96, 2}},
+				LeaseTime:        86400,
+				DomainSearch:     []string{"example.com"},
+			},
+		},
+		"host values take precedence": {
+			host: &data.DHCP{IPAddress: netip.MustParseAddr("192.168.3.10"), LeaseTime: 60},
+			want: &data.DHCP{
+				IPAddress:        netip.MustParseAddr("192.168.3.10"),
+				SubnetMask:       net.IPv4Mask(255, 255, 0, 0),
+				DefaultGateways:  []netip.Addr{netip.MustParseAddr("192.168.0.1")},
+				BroadcastAddress: netip.MustParseAddr("192.168.255.255"),
+				LeaseTime:        60,
+			},
+		},
+		"no matching subnet": {
+			host: &data.DHCP{IPAddress: netip.MustParseAddr("10.0.0.10")},
+			want: &data.DHCP{IPAddress: netip.MustParseAddr("10.0.0.10")},
+		},
+		"backend error": {
+			err:     errBackend,
+			wantErr: errBackend,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &Backend{Backend: &mockBackend{dhcp: tt.host, err: tt.err}, Subnets: subnets}
+			d, _, err := b.GetByMac(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(d, tt.want, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+			d, _, err = b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 10))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(d, tt.want, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestRequestingContext(t *testing.T) {
+	subnets := []Subnet{
+		{Prefix: netip.MustParsePrefix("192.168.2.0/24"), Interface: "eth1", DefaultGateways: []netip.Addr{netip.MustParseAddr("192.168.2.1")}},
+		{Prefix: netip.MustParsePrefix("10.0.0.0/24"), Interface: "eth2", DefaultGateways: []netip.Addr{netip.MustParseAddr("10.0.0.1")}},
+		{Prefix: netip.MustParsePrefix("172.16.0.0/24"), DefaultGateways: []netip.Addr{netip.MustParseAddr("172.16.0.1")}},
+	}
+	tests := map[string]struct {
+		p    *data.Packet
+		want netip.Addr
+	}{
+		"no message":               {want: netip.MustParseAddr("192.168.2.1")},
+		"relay agent":              {p: &data.Packet{Pkt: &dhcpv4.DHCPv4{GatewayIPAddr: net.IP{172, 16, 0, 1}}, Md: &data.Metadata{IfName: "eth2"}}, want: netip.MustParseAddr("172.16.0.1")},
+		"interface":                {p: &data.Packet{Pkt: &dhcpv4.DHCPv4{GatewayIPAddr: net.IPv4zero}, Md: &data.Metadata{IfName: "eth2"}}, want: netip.MustParseAddr("10.0.0.1")},
+		"unknown relay agent":      {p: &data.Packet{Pkt: &dhcpv4.DHCPv4{GatewayIPAddr: net.IP{172, 17, 0, 1}}}, want: netip.MustParseAddr("192.168.2.1")},
+		"interface with no subnet": {p: &data.Packet{Pkt: &dhcpv4.DHCPv4{}, Md: &data.Metadata{IfName: "eth3"}}, want: netip.MustParseAddr("192.168.2.1")},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &Backend{Backend: &mockBackend{dhcp: &data.DHCP{IPAddress: netip.MustParseAddr("192.168.2.10")}}, Subnets: subnets}
+			ctx := context.Background()
+			if tt.p != nil {
+				ctx = data.NewContext(ctx, *tt.p)
+			}
+			d, _, err := b.GetByMac(ctx, net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff([]netip.Addr{tt.want}, d.DefaultGateways, cmpopts.EquateComparable(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
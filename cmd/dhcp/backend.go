@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/dhcp/backend/cache"
+	"github.com/tinkerbell/dhcp/backend/configmap"
+	"github.com/tinkerbell/dhcp/backend/csv"
+	"github.com/tinkerbell/dhcp/backend/dynamodb"
+	"github.com/tinkerbell/dhcp/backend/file"
+	"github.com/tinkerbell/dhcp/backend/git"
+	"github.com/tinkerbell/dhcp/backend/hegel"
+	"github.com/tinkerbell/dhcp/backend/isc"
+	"github.com/tinkerbell/dhcp/backend/kube"
+	"github.com/tinkerbell/dhcp/backend/maas"
+	"github.com/tinkerbell/dhcp/backend/merge"
+	"github.com/tinkerbell/dhcp/backend/netbox"
+	"github.com/tinkerbell/dhcp/backend/objectstore"
+	"github.com/tinkerbell/dhcp/backend/remote"
+	"github.com/tinkerbell/dhcp/backend/subnet"
+	"github.com/tinkerbell/dhcp/config"
+	"github.com/tinkerbell/dhcp/handler"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+// kubeSyncTimeout bounds the wait for the Hardware cache of the kube backend to sync.
+const kubeSyncTimeout = 30 * time.Second
+
+var errUnknownBackend = errors.New("unknown backend")
+
+// newBackend returns the backend selected by b, without the wrappers of wrapBackend. Backends that watch or fetch their
+// data are started, until ctx is done.
+func newBackend(ctx context.Context, l logr.Logger, b config.Backend) (handler.BackendReader, error) {
+	switch b.Type {
+	case config.BackendFile:
+		w, err := file.NewWatcher(l, b.File.Path)
+		if err != nil {
+			return nil, err
+		}
+		go w.Start(ctx)
+		return w, nil
+	case config.BackendCSV:
+		w, err := csv.NewWatcher(l, b.CSV.Path)
+		if err != nil {
+			return nil, err
+		}
+		go w.Start(ctx)
+		return w, nil
+	case config.BackendISC:
+		return isc.NewBackend(l, b.ISC.Path, isc.Format(b.ISC.Format))
+	case config.BackendRemote:
+		u, err := url.Parse(b.Remote.URL)
+		if err != nil {
+			return nil, fmt.Errorf("backend.remote.url: %w", err)
+		}
+		r := remote.NewBackend(l, u)
+		go r.Start(ctx)
+		return r, nil
+	case config.BackendHegel:
+		u, err := url.Parse(b.Hegel.URL)
+		if err != nil {
+			return nil, fmt.Errorf("backend.hegel.url: %w", err)
+		}
+		return hegel.NewBackend(l, u, b.Hegel.MACURL)
+	case config.BackendKube:
+		return kubeBackend(ctx, l, b.Kube)
+	case config.BackendNetbox:
+		u, err := url.Parse(b.Netbox.URL)
+		if err != nil {
+			return nil, fmt.Errorf("backend.netbox.url: %w", err)
+		}
+		return netbox.NewBackend(l, u, b.Netbox.Token), nil
+	case config.BackendMAAS:
+		u, err := url.Parse(b.MAAS.URL)
+		if err != nil {
+			return nil, fmt.Errorf("backend.maas.url: %w", err)
+		}
+		return maas.NewBackend(l, u, b.MAAS.APIKey)
+	case config.BackendDynamoDB:
+		return dynamoDBBackend(l, b.DynamoDB)
+	case config.BackendObjectStore:
+		r, err := objectStoreBackend(l, b.ObjectStore)
+		if err != nil {
+			return nil, err
+		}
+		go r.Start(ctx)
+		return r, nil
+	case config.BackendGit:
+		g := git.NewBackend(l, b.Git.Repository, b.Git.Path)
+		g.Branch = b.Git.Branch
+		go g.Start(ctx)
+		return g, nil
+	case config.BackendConfigMap:
+		conf, err := kubeConfig(b.ConfigMap.Kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		c, err := configmap.NewBackend(l, conf, b.ConfigMap.Namespace, b.ConfigMap.Name)
+		if err != nil {
+			return nil, err
+		}
+		c.Key = b.ConfigMap.Key
+		go func() {
+			if err := c.Start(ctx); err != nil {
+				l.Error(err, "configmap backend stopped")
+			}
+		}()
+		return c, nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", errUnknownBackend, b.Type)
+}
+
+// wrapBackend returns r wrapped, in this order, by the cache, the defaults, and the subnets of b, when they are set.
+// The reads of the defaults and subnets are not cached, they are local and fast.
+func wrapBackend(ctx context.Context, l logr.Logger, b config.Backend, r handler.BackendReader) (handler.BackendReader, error) {
+	if c := b.Cache; c.Enabled {
+		cb := cache.NewBackend(r)
+		if c.TTL > 0 {
+			cb.TTL = time.Duration(c.TTL)
+		}
+		cb.NegativeTTL = time.Duration(c.NegativeTTL)
+		if c.MaxEntries > 0 {
+			cb.MaxEntries = c.MaxEntries
+		}
+		r = cb
+	}
+	if b.Defaults.Path != "" {
+		w, err := file.NewWatcher(l, b.Defaults.Path)
+		if err != nil {
+			return nil, fmt.Errorf("backend.defaults.path: %w", err)
+		}
+		go w.Start(ctx)
+		r = &merge.Backend{Primary: r, Defaults: w, Log: l}
+	}
+	if len(b.Subnets) > 0 {
+		s, err := subnets(b.Subnets)
+		if err != nil {
+			return nil, err
+		}
+		r = &subnet.Backend{Backend: r, Subnets: s}
+	}
+
+	return r, nil
+}
+
+// subnets returns the subnets of the backend/subnet package of c.
+func subnets(c []config.Subnet) ([]subnet.Subnet, error) {
+	s := make([]subnet.Subnet, 0, len(c))
+	for i, cs := range c {
+		p, err := netip.ParsePrefix(cs.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("backend.subnets[%d].prefix: %w", i, err)
+		}
+		sn := subnet.Subnet{
+			Prefix:       p.Masked(),
+			Interface:    cs.Interface,
+			DomainName:   cs.DomainName,
+			LeaseTime:    cs.LeaseTime,
+			DomainSearch: cs.DomainSearch,
+		}
+		for _, g := range cs.Gateways {
+			a, err := netip.ParseAddr(g)
+			if err != nil {
+				return nil, fmt.Errorf("backend.subnets[%d].gateways: %w", i, err)
+			}
+			sn.DefaultGateways = append(sn.DefaultGateways, a)
+		}
+		if sn.NameServers, err = ips(cs.NameServers); err != nil {
+			return nil, fmt.Errorf("backend.subnets[%d].nameServers: %w", i, err)
+		}
+		if sn.NTPServers, err = ips(cs.NTPServers); err != nil {
+			return nil, fmt.Errorf("backend.subnets[%d].ntpServers: %w", i, err)
+		}
+		s = append(s, sn)
+	}
+
+	return s, nil
+}
+
+// ips parses the IP addresses of s.
+func ips(s []string) ([]net.IP, error) {
+	var r []net.IP
+	for _, v := range s {
+		a, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, net.IP(a.AsSlice()))
+	}
+
+	return r, nil
+}
+
+// dynamoDBBackend returns a dynamodb backend signed with the credentials of the environment.
+func dynamoDBBackend(l logr.Logger, c config.DynamoDB) (*dynamodb.Backend, error) {
+	var endpoint *url.URL
+	if c.Endpoint != "" {
+		var err error
+		if endpoint, err = url.Parse(c.Endpoint); err != nil {
+			return nil, fmt.Errorf("backend.dynamodb.endpoint: %w", err)
+		}
+	}
+	creds, err := dynamodb.CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return dynamodb.NewBackend(l, endpoint, c.Region, c.Table, creds)
+}
+
+// objectStoreBackend returns the S3 or GCS backend of c, signed with the credentials of the environment. GCS requests
+// are authorized by the metadata server when the environment has no credentials.
+func objectStoreBackend(l logr.Logger, c config.ObjectStore) (*remote.Backend, error) {
+	var endpoint *url.URL
+	if c.Endpoint != "" {
+		var err error
+		if endpoint, err = url.Parse(c.Endpoint); err != nil {
+			return nil, fmt.Errorf("backend.objectstore.endpoint: %w", err)
+		}
+	}
+	creds, err := objectstore.CredentialsFromEnv()
+	if c.Provider == "gcs" {
+		return objectstore.NewGCSBackend(l, objectstore.GCS{Bucket: c.Bucket, Object: c.Object, Endpoint: endpoint, Credentials: creds})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return objectstore.NewS3Backend(l, objectstore.S3{Bucket: c.Bucket, Key: c.Object, Region: c.Region, Endpoint: endpoint, Credentials: creds})
+}
+
+// kubeBackend returns a started kube backend once its Hardware cache has synced, so that early DHCP requests are not
+// answered with "no hardware found".
+func kubeBackend(ctx context.Context, l logr.Logger, c config.Kube) (*kube.Backend, error) {
+	conf, err := kubeConfig(c.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	var opts []cluster.Option
+	if c.Namespace != "" {
+		opts = append(opts, kube.WithNamespace(c.Namespace))
+	}
+	k, err := kube.NewBackend(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := k.Start(ctx); err != nil {
+			l.Error(err, "kube backend stopped")
+		}
+	}()
+	sctx, cancel := context.WithTimeout(ctx, kubeSyncTimeout)
+	defer cancel()
+	if !k.WaitForCacheSync(sctx) {
+		return nil, errors.New("timed out waiting for the hardware cache to sync")
+	}
+
+	return k, nil
+}
+
+// kubeConfig returns the configuration of the kubeconfig at path, or the in-cluster configuration when path is empty.
+func kubeConfig(path string) (*rest.Config, error) {
+	if path == "" {
+		return rest.InClusterConfig()
+	}
+
+	return clientcmd.BuildConfigFromFlags("", path)
+}
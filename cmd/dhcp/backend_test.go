@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/dhcp/backend/mock"
+	"github.com/tinkerbell/dhcp/config"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+func TestWrapBackend(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x00, 0x5e, 0x00, 0x53, 0x01}
+	m := mock.New(mock.WithMAC(mac, mock.Response{DHCP: &data.DHCP{MACAddress: mac, IPAddress: netip.MustParseAddr("192.168.2.10")}, Netboot: &data.Netboot{}}))
+	b := config.Backend{
+		Cache:   config.Cache{Enabled: true},
+		Subnets: []config.Subnet{{Prefix: "192.168.2.0/24", Gateways: []string{"192.168.2.1"}, NameServers: []string{"1.1.1.1"}}},
+	}
+	r, err := wrapBackend(context.Background(), logr.Discard(), b, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		d, _, err := r.GetByMac(context.Background(), mac)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]netip.Addr{netip.MustParseAddr("192.168.2.1")}, d.DefaultGateways, cmp.Comparer(func(a, b netip.Addr) bool { return a == b })); diff != "" {
+			t.Fatal(diff)
+		}
+		if diff := cmp.Diff([]net.IP{net.IPv4(1, 1, 1, 1).To4()}, d.NameServers); diff != "" {
+			t.Fatal(diff)
+		}
+	}
+	if n := len(m.CallsOf(mock.MethodGetByMac)); n != 1 {
+		t.Fatalf("backend read %d times, want 1, the second read is cached", n)
+	}
+}
@@ -0,0 +1,198 @@
+// Command dhcp is a DHCP server that serves the reservations of one of the bundled backends, with optional netboot
+// options for iPXE.
+//
+// The settings are read from a YAML or JSON configuration file given with -config, see the config package, and most
+// can also be set with a flag, or an environment variable, DHCP_ and the name of the flag in upper case with dashes
+// replaced by underscores. Flags win over environment variables, which win over the configuration file. For example:
+//
+//	dhcp -ip 192.168.2.225 -backend file -file-path hardware.yaml -netboot -ipxe-tftp 192.168.2.225:69
+//
+// On SIGHUP, the handler settings, the server IP and the netboot options, are reloaded from the environment and the
+// configuration file without restarting the listener; the listener and backend settings require a restart.
+// SIGINT and SIGTERM shut the server down.
+//
+// The replay subcommand replays the DHCP requests of a capture of a client, for example one that fails to netboot in
+// the field, to the handler and backend configured by the server flags, or to a running server, and prints the replies:
+//
+//	dhcp replay capture.pcap -config dhcp.yaml
+//	dhcp replay -to 192.168.2.225:67 capture.pcap
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/equinix-labs/otel-init-go/otelinit"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/stdr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/dhcp"
+	"github.com/tinkerbell/dhcp/admin"
+	"github.com/tinkerbell/dhcp/handler"
+	"github.com/tinkerbell/dhcp/handler/reservation"
+	"github.com/tinkerbell/dhcp/metrics"
+	"github.com/tinkerbell/dhcp/metrics/debug"
+)
+
+func main() {
+	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		err = runReplay(ctx, os.Args[2:], os.Stdout)
+	} else {
+		err = run(ctx, os.Args[1:])
+	}
+	done()
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run runs the server with the options of args until ctx is done.
+func run(ctx context.Context, args []string) error {
+	cfg, err := parse(args, os.LookupEnv, os.Stderr)
+	if err != nil {
+		return err
+	}
+	c, err := handlerConfig(cfg)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddrPort(cfg.Listener.Addr)
+	if err != nil {
+		return fmt.Errorf("listener.addr: %w", err)
+	}
+	// fail before the backend is started, which can take a while, when the listener can not be opened.
+	if err := dhcp.CheckPrivileges(int(addr.Port()), false); err != nil {
+		return err
+	}
+
+	stdr.SetVerbosity(cfg.Log.Level)
+	l := stdr.New(log.New(os.Stdout, "", log.Lshortfile)).WithName("github.com/tinkerbell/dhcp")
+	if o := cfg.Telemetry.OTEL; o.Enabled {
+		// otelinit is configured with the standard OpenTelemetry environment variables.
+		if o.Endpoint != "" {
+			_ = os.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", o.Endpoint)
+		}
+		if o.Insecure {
+			_ = os.Setenv("OTEL_EXPORTER_OTLP_INSECURE", "true")
+		}
+		var otelShutdown otelinit.OtelShutdown
+		ctx, otelShutdown = otelinit.InitOpenTelemetry(ctx, "github.com/tinkerbell/dhcp")
+		defer otelShutdown(context.Background())
+	}
+
+	backend, err := newBackend(ctx, l, cfg.Backend)
+	if err != nil {
+		return fmt.Errorf("backend %v: %w", cfg.Backend.Type, err)
+	}
+	reader, err := wrapBackend(ctx, l, cfg.Backend, backend)
+	if err != nil {
+		return fmt.Errorf("backend %v: %w", cfg.Backend.Type, err)
+	}
+	reg := prometheus.NewRegistry()
+	m, err := metrics.New(reg)
+	if err != nil {
+		return err
+	}
+	// the wrappers do not write or report health, so the writer and health checker are those of the backend.
+	h := &reservation.Handler{Log: l, Backend: reader, Metrics: m}
+	if w, ok := backend.(handler.BackendWriter); ok {
+		h.Writer = w
+	}
+	if err := h.Reload(c); err != nil {
+		return err
+	}
+	go reloadOnHUP(ctx, l, h, args)
+
+	conn, err := dhcp.NewConn(cfg.Listener.Interface, net.UDPAddrFromAddrPort(addr))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	server := &dhcp.Server{Logger: l, Conn: conn, Handlers: []dhcp.Handler{h}, Metrics: m}
+	if cfg.Listener.Unicast {
+		if err := server.ListenUnicast(cfg.Listener.Interface, c.IPAddr); err != nil {
+			return err
+		}
+	}
+	if cfg.Listener.PXE {
+		if err := server.ListenPXE(cfg.Listener.Interface); err != nil {
+			return err
+		}
+	}
+	server.HealthCheckers = map[string]handler.HealthChecker{"listener": server}
+	if hc, ok := backend.(handler.HealthChecker); ok {
+		server.HealthCheckers[string(cfg.Backend.Type)] = hc
+	}
+
+	t := cfg.Telemetry
+	if t.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		metrics.Register(mux, reg, server.HealthHandler())
+		if t.DebugEndpoints {
+			debug.Register(mux)
+		}
+		go func() {
+			if err := metrics.ListenAndServe(ctx, t.MetricsAddr, mux); err != nil {
+				l.Error(err, "metrics server stopped")
+			}
+		}()
+	}
+	if t.AdminAddr != "" {
+		a := &admin.Server{Handler: h, Health: server.HealthHandler()}
+		go func() {
+			if err := metrics.ListenAndServe(ctx, t.AdminAddr, a); err != nil {
+				l.Error(err, "admin server stopped")
+			}
+		}()
+	}
+
+	l.Info("starting server", "addr", addr, "ip", c.IPAddr, "backend", cfg.Backend.Type)
+	if err := server.Serve(ctx); err != nil {
+		return err
+	}
+	l.Info("done")
+
+	return nil
+}
+
+// reloadOnHUP reloads the handler configuration of h from the environment and the configuration file on SIGHUP, until
+// ctx is done. The flags of args are parsed again, so that they keep winning over the other sources.
+func reloadOnHUP(ctx context.Context, l logr.Logger, h *reservation.Handler, args []string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+		cfg, err := parse(args, os.LookupEnv, io.Discard)
+		if err == nil {
+			var c reservation.Config
+			if c, err = handlerConfig(cfg); err == nil {
+				err = h.Reload(c)
+			}
+		}
+		if err != nil {
+			l.Error(err, "failed to reload configuration, serving with the current configuration")
+			continue
+		}
+		l.Info("reloaded configuration")
+	}
+}
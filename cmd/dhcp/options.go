@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/config"
+	"github.com/tinkerbell/dhcp/handler/reservation"
+)
+
+// envPrefix is the prefix of the environment variables that set the flags, for example DHCP_BACKEND sets -backend.
+const envPrefix = "DHCP_"
+
+// flagSet returns the flags that set the settings of c, with the settings of c as their defaults, and the path of the
+// configuration file.
+func flagSet(c *config.Config, path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("dhcp", flag.ContinueOnError)
+	fs.StringVar(path, "config", "", "path to a YAML or JSON configuration file, see the config package")
+
+	fs.StringVar(&c.Listener.Interface, "interface", c.Listener.Interface, "interface to listen on, all interfaces when empty")
+	fs.StringVar(&c.Listener.Addr, "listen-addr", c.Listener.Addr, "IP:port to listen on for DHCP messages")
+	fs.BoolVar(&c.Listener.Unicast, "unicast", c.Listener.Unicast, "also listen on the server IP for unicast renewals")
+	fs.BoolVar(&c.Listener.PXE, "pxe", c.Listener.PXE, "also listen on the PXE boot server port, 4011")
+	fs.StringVar((*string)(&c.Handler.Mode), "mode", string(c.Handler.Mode), "handler mode")
+	fs.StringVar(&c.Handler.IP, "ip", c.Handler.IP, "IPv4 address of the server, sent to clients as the server identifier (required)")
+
+	fs.StringVar((*string)(&c.Backend.Type), "backend", string(c.Backend.Type), "backend to read DHCP data from: file, csv, isc, remote, hegel, kube, netbox, maas, dynamodb, objectstore, git, or configmap")
+	fs.StringVar(&c.Backend.File.Path, "file-path", c.Backend.File.Path, "path to the hardware file of the file backend")
+	fs.StringVar(&c.Backend.CSV.Path, "csv-path", c.Backend.CSV.Path, "path to the CSV file of the csv backend")
+	fs.StringVar(&c.Backend.ISC.Path, "isc-path", c.Backend.ISC.Path, "path to the dhcpd or Kea configuration of the isc backend")
+	fs.StringVar(&c.Backend.ISC.Format, "isc-format", c.Backend.ISC.Format, "format of the isc backend file, dhcpd or kea, detected from its extension when empty")
+	fs.StringVar(&c.Backend.Remote.URL, "remote-url", c.Backend.Remote.URL, "URL of the hardware document of the remote backend")
+	fs.StringVar(&c.Backend.Hegel.URL, "hegel-url", c.Backend.Hegel.URL, "URL of Hegel for the hegel backend")
+	fs.StringVar(&c.Backend.Hegel.MACURL, "hegel-mac-url", c.Backend.Hegel.MACURL, "URL template with a {mac} placeholder of the hegel backend")
+	fs.StringVar(&c.Backend.Kube.Kubeconfig, "kubeconfig", c.Backend.Kube.Kubeconfig, "kubeconfig of the kube backend, the in-cluster configuration when empty")
+	fs.StringVar(&c.Backend.Kube.Namespace, "kube-namespace", c.Backend.Kube.Namespace, "namespace of the kube backend, all namespaces when empty")
+	fs.StringVar(&c.Backend.Netbox.URL, "netbox-url", c.Backend.Netbox.URL, "URL of Netbox for the netbox backend")
+	fs.StringVar(&c.Backend.Netbox.Token, "netbox-token", c.Backend.Netbox.Token, "API token of the netbox backend, better set with DHCP_NETBOX_TOKEN")
+	fs.StringVar(&c.Backend.MAAS.URL, "maas-url", c.Backend.MAAS.URL, "URL of the MAAS region controller for the maas backend")
+	fs.StringVar(&c.Backend.MAAS.APIKey, "maas-api-key", c.Backend.MAAS.APIKey, "API key of the maas backend, better set with DHCP_MAAS_API_KEY")
+	fs.StringVar(&c.Backend.DynamoDB.Table, "dynamodb-table", c.Backend.DynamoDB.Table, "table of the dynamodb backend")
+	fs.StringVar(&c.Backend.DynamoDB.Region, "dynamodb-region", c.Backend.DynamoDB.Region, "AWS region of the dynamodb backend")
+	fs.StringVar(&c.Backend.DynamoDB.Endpoint, "dynamodb-endpoint", c.Backend.DynamoDB.Endpoint, "URL of the DynamoDB API, the regional endpoint when empty")
+	fs.StringVar(&c.Backend.ObjectStore.Provider, "objectstore-provider", c.Backend.ObjectStore.Provider, "provider of the objectstore backend, s3 or gcs")
+	fs.StringVar(&c.Backend.ObjectStore.Bucket, "objectstore-bucket", c.Backend.ObjectStore.Bucket, "bucket of the objectstore backend")
+	fs.StringVar(&c.Backend.ObjectStore.Object, "objectstore-object", c.Backend.ObjectStore.Object, "key of the object of the objectstore backend")
+	fs.StringVar(&c.Backend.ObjectStore.Region, "objectstore-region", c.Backend.ObjectStore.Region, "AWS region of the bucket of the objectstore backend, required for s3")
+	fs.StringVar(&c.Backend.ObjectStore.Endpoint, "objectstore-endpoint", c.Backend.ObjectStore.Endpoint, "URL of an S3 compatible object store or of the GCS XML API, the provider's when empty")
+	fs.StringVar(&c.Backend.Git.Repository, "git-repository", c.Backend.Git.Repository, "URL or path of the repository of the git backend")
+	fs.StringVar(&c.Backend.Git.Branch, "git-branch", c.Backend.Git.Branch, "branch of the git backend, the default branch when empty")
+	fs.StringVar(&c.Backend.Git.Path, "git-path", c.Backend.Git.Path, "path of the hardware file in the repository of the git backend")
+	fs.StringVar(&c.Backend.ConfigMap.Kubeconfig, "configmap-kubeconfig", c.Backend.ConfigMap.Kubeconfig, "kubeconfig of the configmap backend, the in-cluster configuration when empty")
+	fs.StringVar(&c.Backend.ConfigMap.Namespace, "configmap-namespace", c.Backend.ConfigMap.Namespace, "namespace of the ConfigMap of the configmap backend")
+	fs.StringVar(&c.Backend.ConfigMap.Name, "configmap-name", c.Backend.ConfigMap.Name, "name of the ConfigMap of the configmap backend")
+	fs.StringVar(&c.Backend.ConfigMap.Key, "configmap-key", c.Backend.ConfigMap.Key, "data key of the hardware document in the ConfigMap, its only key when empty")
+	fs.BoolVar(&c.Backend.Cache.Enabled, "cache", c.Backend.Cache.Enabled, "cache the reads of the backend")
+	fs.DurationVar((*time.Duration)(&c.Backend.Cache.TTL), "cache-ttl", time.Duration(c.Backend.Cache.TTL), "how long a read is cached, 30s when zero")
+	fs.DurationVar((*time.Duration)(&c.Backend.Cache.NegativeTTL), "cache-negative-ttl", time.Duration(c.Backend.Cache.NegativeTTL), "how long a hardware not found read is cached, not cached when zero")
+	fs.IntVar(&c.Backend.Cache.MaxEntries, "cache-max-entries", c.Backend.Cache.MaxEntries, "maximum number of cached reads, 10000 when zero")
+	fs.StringVar(&c.Backend.Defaults.Path, "defaults-path", c.Backend.Defaults.Path, "path to a hardware file whose records fill in the fields the backend does not set")
+
+	fs.BoolVar(&c.Netboot.Enabled, "netboot", c.Netboot.Enabled, "send netboot options to clients allowed to netboot")
+	fs.StringVar(&c.Netboot.IPXETFTP, "ipxe-tftp", c.Netboot.IPXETFTP, "IP:port of the TFTP server of the iPXE binaries")
+	fs.StringVar(&c.Netboot.IPXEHTTP, "ipxe-http", c.Netboot.IPXEHTTP, "URL of the HTTP server of the iPXE binaries")
+	fs.StringVar(&c.Netboot.IPXEScriptURL, "ipxe-script-url", c.Netboot.IPXEScriptURL, "URL of the iPXE script")
+	fs.StringVar(&c.Netboot.UserClass, "user-class", c.Netboot.UserClass, "user class (option 77) that breaks out of an iPXE loop")
+
+	fs.IntVar(&c.Log.Level, "log-level", c.Log.Level, "log verbosity, higher is more verbose")
+	fs.BoolVar(&c.Telemetry.OTEL.Enabled, "otel", c.Telemetry.OTEL.Enabled, "export traces with OpenTelemetry")
+	fs.StringVar(&c.Telemetry.OTEL.Endpoint, "otel-endpoint", c.Telemetry.OTEL.Endpoint, "OTLP endpoint traces are exported to, OTEL_EXPORTER_OTLP_ENDPOINT when empty")
+	fs.BoolVar(&c.Telemetry.OTEL.Insecure, "otel-insecure", c.Telemetry.OTEL.Insecure, "export traces without TLS")
+	fs.StringVar(&c.Telemetry.MetricsAddr, "metrics-addr", c.Telemetry.MetricsAddr, "IP:port of /metrics and /healthz, disabled when empty")
+	fs.BoolVar(&c.Telemetry.DebugEndpoints, "debug-endpoints", c.Telemetry.DebugEndpoints, "serve pprof and expvar next to the metrics")
+	fs.StringVar(&c.Telemetry.AdminAddr, "admin-addr", c.Telemetry.AdminAddr, "IP:port of the admin API, disabled when empty")
+
+	return fs
+}
+
+// parse returns the validated configuration set by args, the environment variables returned by lookupEnv, and the
+// configuration file. Flags win over environment variables, which win over the configuration file.
+func parse(args []string, lookupEnv func(string) (string, bool), output io.Writer) (config.Config, error) {
+	// the flags are parsed once to find the configuration file, and once more over its settings.
+	var path string
+	c := config.Default()
+	fs := flagSet(&c, &path)
+	fs.SetOutput(output)
+	if err := fs.Parse(args); err != nil {
+		return config.Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return config.Config{}, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if v, ok := lookupEnv(envName("config")); ok && !set["config"] {
+		path = v
+	}
+
+	c = config.Default()
+	if path != "" {
+		var err error
+		if c, err = config.Load(path); err != nil {
+			return config.Config{}, err
+		}
+	}
+	fs = flagSet(&c, new(string))
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return config.Config{}, err
+	}
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || f.Name == "config" || err != nil {
+			return
+		}
+		if v, ok := lookupEnv(envName(f.Name)); ok {
+			if serr := fs.Set(f.Name, v); serr != nil {
+				err = fmt.Errorf("invalid value %q for %v: %w", v, envName(f.Name), serr)
+			}
+		}
+	})
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	return c, c.Validate()
+}
+
+// envName returns the name of the environment variable of the flag name.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// handlerConfig returns the handler configuration of c.
+func handlerConfig(c config.Config) (reservation.Config, error) {
+	ip, err := netip.ParseAddr(c.Handler.IP)
+	if err != nil {
+		return reservation.Config{}, fmt.Errorf("handler.ip: %w", err)
+	}
+	rc := reservation.Config{
+		IPAddr:      ip,
+		OTELEnabled: c.Telemetry.OTEL.Enabled,
+		Netboot: reservation.Netboot{
+			Enabled:   c.Netboot.Enabled,
+			UserClass: reservation.UserClass(c.Netboot.UserClass),
+		},
+	}
+	if c.Netboot.IPXETFTP != "" {
+		if rc.Netboot.IPXEBinServerTFTP, err = netip.ParseAddrPort(c.Netboot.IPXETFTP); err != nil {
+			return reservation.Config{}, fmt.Errorf("netboot.ipxeTFTP: %w", err)
+		}
+	}
+	if c.Netboot.IPXEHTTP != "" {
+		if rc.Netboot.IPXEBinServerHTTP, err = url.Parse(c.Netboot.IPXEHTTP); err != nil {
+			return reservation.Config{}, fmt.Errorf("netboot.ipxeHTTP: %w", err)
+		}
+	}
+	if c.Netboot.IPXEScriptURL != "" {
+		script, err := url.Parse(c.Netboot.IPXEScriptURL)
+		if err != nil {
+			return reservation.Config{}, fmt.Errorf("netboot.ipxeScriptURL: %w", err)
+		}
+		rc.Netboot.IPXEScriptURL = func(*dhcpv4.DHCPv4) *url.URL {
+			return script
+		}
+	}
+
+	return rc, nil
+}
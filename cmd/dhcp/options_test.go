@@ -0,0 +1,140 @@
+package main
+
+import (
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/dhcp/config"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		args    []string
+		env     map[string]string
+		file    string
+		want    func(*config.Config)
+		wantErr bool
+	}{
+		"flags": {
+			args: []string{"-ip", "192.168.2.225", "-backend", "csv", "-csv-path", "hosts.csv", "-pxe"},
+			want: func(c *config.Config) {
+				c.Handler.IP, c.Backend.Type, c.Backend.CSV.Path, c.Listener.PXE = "192.168.2.225", config.BackendCSV, "hosts.csv", true
+			},
+		},
+		"env": {
+			env: map[string]string{"DHCP_IP": "192.168.2.225", "DHCP_FILE_PATH": "hardware.yaml", "DHCP_LOG_LEVEL": "2"},
+			want: func(c *config.Config) {
+				c.Handler.IP, c.Backend.File.Path, c.Log.Level = "192.168.2.225", "hardware.yaml", 2
+			},
+		},
+		"file": {
+			file: "handler:\n  ip: 192.168.2.225\nbackend:\n  file:\n    path: hardware.yaml\nlog:\n  level: 1\n",
+			want: func(c *config.Config) {
+				c.Handler.IP, c.Backend.File.Path, c.Log.Level = "192.168.2.225", "hardware.yaml", 1
+			},
+		},
+		"flags win over env and env over file": {
+			args: []string{"-ip", "192.168.2.1"},
+			env:  map[string]string{"DHCP_IP": "192.168.2.2", "DHCP_ISC_PATH": "kea.json"},
+			file: "handler:\n  ip: 192.168.2.3\nbackend:\n  type: isc\n  isc:\n    path: dhcpd.conf\n    format: kea\n",
+			want: func(c *config.Config) {
+				c.Handler.IP, c.Backend.Type, c.Backend.ISC = "192.168.2.1", config.BackendISC, config.ISC{Path: "kea.json", Format: "kea"}
+			},
+		},
+		"secret from env": {
+			args: []string{"-ip", "192.168.2.225", "-backend", "netbox", "-netbox-url", "https://netbox.example.com"},
+			env:  map[string]string{"DHCP_NETBOX_TOKEN": "0123456789abcdef"},
+			want: func(c *config.Config) {
+				c.Handler.IP, c.Backend.Type = "192.168.2.225", config.BackendNetbox
+				c.Backend.Netbox = config.Netbox{URL: "https://netbox.example.com", Token: "0123456789abcdef"}
+			},
+		},
+		"cache": {
+			args: []string{"-ip", "192.168.2.225", "-file-path", "hardware.yaml", "-cache", "-cache-ttl", "1m"},
+			env:  map[string]string{"DHCP_CACHE_NEGATIVE_TTL": "5s"},
+			want: func(c *config.Config) {
+				c.Handler.IP, c.Backend.File.Path = "192.168.2.225", "hardware.yaml"
+				c.Backend.Cache = config.Cache{Enabled: true, TTL: config.Duration(time.Minute), NegativeTTL: config.Duration(5 * time.Second)}
+			},
+		},
+		"config from env": {
+			env:  map[string]string{"DHCP_CONFIG": "file"},
+			file: "handler:\n  ip: 192.168.2.225\nbackend:\n  file:\n    path: hardware.yaml\n",
+			want: func(c *config.Config) { c.Handler.IP, c.Backend.File.Path = "192.168.2.225", "hardware.yaml" },
+		},
+		"unknown file setting": {
+			file:    "handler:\n  ip: 192.168.2.225\n  listen: 0.0.0.0:67\n",
+			wantErr: true,
+		},
+		"invalid": {
+			args:    []string{"-ip", "192.168.2.225"},
+			wantErr: true,
+		},
+		"invalid env value": {
+			args:    []string{"-ip", "192.168.2.225", "-file-path", "hardware.yaml"},
+			env:     map[string]string{"DHCP_NETBOOT": "maybe"},
+			wantErr: true,
+		},
+		"unexpected argument": {
+			args:    []string{"serve"},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			args := tt.args
+			env := map[string]string{}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			if tt.file != "" {
+				p := filepath.Join(t.TempDir(), "dhcp.yaml")
+				if err := os.WriteFile(p, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if env["DHCP_CONFIG"] != "" {
+					env["DHCP_CONFIG"] = p
+				} else {
+					args = append([]string{"-config", p}, args...)
+				}
+			}
+			lookupEnv := func(k string) (string, bool) {
+				v, ok := env[k]
+				return v, ok
+			}
+			got, err := parse(args, lookupEnv, io.Discard)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			want := config.Default()
+			tt.want(&want)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestHandlerConfig(t *testing.T) {
+	c := config.Default()
+	c.Handler.IP = "192.168.2.225"
+	c.Netboot = config.Netboot{Enabled: true, IPXETFTP: "192.168.2.225:69", IPXEHTTP: "http://192.168.2.225:8080", IPXEScriptURL: "http://192.168.2.225/auto.ipxe"}
+	got, err := handlerConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.IPAddr != netip.MustParseAddr("192.168.2.225") || !got.Netboot.Enabled || got.Netboot.IPXEBinServerTFTP.Port() != 69 {
+		t.Fatalf("handlerConfig() = %+v", got)
+	}
+	if s := got.Netboot.IPXEScriptURL(nil).String(); s != c.Netboot.IPXEScriptURL {
+		t.Fatalf("IPXEScriptURL() = %v, want %v", s, c.Netboot.IPXEScriptURL)
+	}
+}
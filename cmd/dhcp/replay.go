@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/go-logr/stdr"
+	"github.com/tinkerbell/dhcp/dhcptest"
+	"github.com/tinkerbell/dhcp/handler/reservation"
+	"github.com/tinkerbell/dhcp/replay"
+)
+
+// replayUsage is the usage of the replay subcommand.
+const replayUsage = `usage: dhcp replay [-to IP:port] [-timeout duration] capture.pcap [server flags]
+
+Replays the DHCP requests of a pcap or pcapng capture to the handler and backend configured by the server flags,
+or to the server at the address of -to, and prints the replies next to the replies in the capture.`
+
+// runReplay runs the replay subcommand with args, the arguments after "replay", and prints the results to w.
+// Lease events are not recorded and the backend is only read, so a capture can be replayed against production data.
+func runReplay(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("dhcp replay", flag.ContinueOnError)
+	to := fs.String("to", "", "IP:port of a running server to replay the capture to, instead of the handler configured by the server flags")
+	timeout := fs.Duration("timeout", dhcptest.DefaultTimeout, "how long to wait for the reply to each request")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), replayUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no capture file")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	packets, err := replay.Read(f)
+	if err != nil {
+		return fmt.Errorf("%v: %w", fs.Arg(0), err)
+	}
+
+	var c *dhcptest.Client
+	if *to != "" {
+		if fs.NArg() > 1 {
+			return fmt.Errorf("server flags %v can not be used with -to", fs.Args()[1:])
+		}
+		c, err = dhcptest.Dial(*to)
+	} else {
+		c, err = replayHandler(ctx, fs.Args()[1:])
+	}
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	c.Timeout = *timeout
+
+	return replay.Print(w, replay.Replay(ctx, c, packets))
+}
+
+// replayHandler returns a client of the reservation handler configured by args, the flags of the server.
+// The handler logs to stderr, so its logs do not mix with the results.
+func replayHandler(ctx context.Context, args []string) (*dhcptest.Client, error) {
+	cfg, err := parse(args, os.LookupEnv, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := handlerConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	stdr.SetVerbosity(cfg.Log.Level)
+	l := stdr.New(log.New(os.Stderr, "", log.Lshortfile)).WithName("github.com/tinkerbell/dhcp")
+	backend, err := newBackend(ctx, l, cfg.Backend)
+	if err != nil {
+		return nil, fmt.Errorf("backend %v: %w", cfg.Backend.Type, err)
+	}
+	if backend, err = wrapBackend(ctx, l, cfg.Backend, backend); err != nil {
+		return nil, fmt.Errorf("backend %v: %w", cfg.Backend.Type, err)
+	}
+	h := &reservation.Handler{Log: l, Backend: backend}
+	if err := h.Reload(c); err != nil {
+		return nil, err
+	}
+
+	return dhcptest.New(h)
+}
@@ -0,0 +1,354 @@
+// Package config is the configuration file of the cmd/dhcp server, in YAML or JSON. It covers the listener, the handler
+// mode, the backend with its settings, the netboot options, and the logging and telemetry.
+//
+// Load and Parse reject unknown keys, so a misspelled setting is an error instead of being silently ignored.
+// Validate reports every invalid setting at once, each prefixed with the path of its key, for example:
+//
+//	backend.file.path: is required when backend.type is "file"
+//	netboot.ipxeTFTP: "192.168.2.225" is not an IP:port
+//
+// A minimal configuration:
+//
+//	handler:
+//	  ip: 192.168.2.225
+//	backend:
+//	  type: file
+//	  file:
+//	    path: /etc/dhcp/hardware.yaml
+//
+// Every bundled backend that reads its records from a data source can be selected with backend.type. The static and
+// prefix backends are not, their records are declared in Go code when they are created; serve such records from a
+// file backend instead. The cache, merge, and subnet wrappers are applied to the selected backend with backend.cache,
+// backend.defaults, and backend.subnets.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+// Mode is the mode of the handler, how IP addresses are given to clients.
+type Mode string
+
+// ModeReservation serves the IP address reserved for a client in the backend, and nothing to unknown clients. It is the
+// only mode of the handler.
+const ModeReservation Mode = "reservation"
+
+// BackendType is the type of the backend the handler reads the DHCP data of clients from.
+type BackendType string
+
+// Types of backends.
+const (
+	BackendFile        BackendType = "file"
+	BackendCSV         BackendType = "csv"
+	BackendISC         BackendType = "isc"
+	BackendRemote      BackendType = "remote"
+	BackendHegel       BackendType = "hegel"
+	BackendKube        BackendType = "kube"
+	BackendNetbox      BackendType = "netbox"
+	BackendMAAS        BackendType = "maas"
+	BackendDynamoDB    BackendType = "dynamodb"
+	BackendObjectStore BackendType = "objectstore"
+	BackendGit         BackendType = "git"
+	BackendConfigMap   BackendType = "configmap"
+)
+
+// Config is the configuration of the server.
+type Config struct {
+	Listener  Listener  `json:"listener"`
+	Handler   Handler   `json:"handler"`
+	Backend   Backend   `json:"backend"`
+	Netboot   Netboot   `json:"netboot"`
+	Log       Log       `json:"log"`
+	Telemetry Telemetry `json:"telemetry"`
+}
+
+// Listener is where DHCP messages are received.
+type Listener struct {
+	// Interface is the name of the interface to listen on. All interfaces when empty.
+	Interface string `json:"interface,omitempty"`
+	// Addr is the IP:port to listen on. Defaults to 0.0.0.0:67.
+	Addr string `json:"addr"`
+	// Unicast, when set, also listens on the IP of the handler for the DHCPREQUESTs that renewing clients unicast.
+	Unicast bool `json:"unicast,omitempty"`
+	// PXE, when set, also listens on the PXE boot server port, 4011.
+	PXE bool `json:"pxe,omitempty"`
+}
+
+// Handler is how DHCP messages are answered.
+type Handler struct {
+	// Mode is the mode of the handler. Defaults to ModeReservation.
+	Mode Mode `json:"mode"`
+	// IP is the IPv4 address of the server, sent to clients in the server identifier option.
+	IP string `json:"ip"`
+}
+
+// Backend selects the backend with Type. Only the settings of the selected backend may be set.
+type Backend struct {
+	// Type is the type of the backend. Defaults to BackendFile.
+	Type        BackendType `json:"type"`
+	File        File        `json:"file"`
+	CSV         CSV         `json:"csv"`
+	ISC         ISC         `json:"isc"`
+	Remote      Remote      `json:"remote"`
+	Hegel       Hegel       `json:"hegel"`
+	Kube        Kube        `json:"kube"`
+	Netbox      Netbox      `json:"netbox"`
+	MAAS        MAAS        `json:"maas"`
+	DynamoDB    DynamoDB    `json:"dynamodb"`
+	ObjectStore ObjectStore `json:"objectstore"`
+	Git         Git         `json:"git"`
+	ConfigMap   ConfigMap   `json:"configmap"`
+
+	// Cache caches the reads of the selected backend.
+	Cache Cache `json:"cache"`
+	// Defaults fills in the fields that the records of the selected backend do not set.
+	Defaults Defaults `json:"defaults"`
+	// Subnets fill in the subnet level fields that the records of the selected backend, and of Defaults, do not set.
+	Subnets []Subnet `json:"subnets,omitempty"`
+}
+
+// File is the file backend, a YAML or JSON file of hardware records.
+type File struct {
+	Path string `json:"path,omitempty"`
+}
+
+// CSV is the csv backend, a CSV file with a row per client.
+type CSV struct {
+	Path string `json:"path,omitempty"`
+}
+
+// ISC is the isc backend, the host reservations of an ISC dhcpd or Kea configuration file.
+type ISC struct {
+	Path string `json:"path,omitempty"`
+	// Format is "dhcpd" or "kea". When empty, it is detected from the extension of Path.
+	Format string `json:"format,omitempty"`
+}
+
+// Remote is the remote backend, a YAML or JSON document of hardware records fetched over HTTP.
+type Remote struct {
+	URL string `json:"url,omitempty"`
+}
+
+// Hegel is the hegel backend, the Tinkerbell metadata service.
+type Hegel struct {
+	URL string `json:"url,omitempty"`
+	// MACURL is a URL template with a {mac} placeholder that serves the metadata document for a MAC address.
+	MACURL string `json:"macURL,omitempty"`
+}
+
+// Kube is the kube backend, the Tinkerbell Hardware objects of a Kubernetes cluster.
+type Kube struct {
+	// Kubeconfig is the path of a kubeconfig. The in-cluster configuration is used when empty.
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	// Namespace is the namespace of the Hardware objects. All namespaces when empty.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Netbox is the netbox backend, the interfaces and IP addresses of a Netbox instance.
+type Netbox struct {
+	URL string `json:"url,omitempty"`
+	// Token is the API token. It is better set with the DHCP_NETBOX_TOKEN environment variable than in the file.
+	Token string `json:"token,omitempty"`
+}
+
+// MAAS is the maas backend, the machines enlisted in a MAAS region controller.
+type MAAS struct {
+	URL string `json:"url,omitempty"`
+	// APIKey is the API key, <consumer key>:<token key>:<token secret>. It is better set with the DHCP_MAAS_API_KEY
+	// environment variable than in the file.
+	APIKey string `json:"apiKey,omitempty"`
+}
+
+// DynamoDB is the dynamodb backend, a DynamoDB table keyed by MAC address. The requests are signed with the
+// credentials in the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables.
+type DynamoDB struct {
+	Table  string `json:"table,omitempty"`
+	Region string `json:"region,omitempty"`
+	// Endpoint is the URL of the DynamoDB API. The regional endpoint when empty.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// ObjectStore is the objectstore backend, a document of hardware records stored as an object in an S3 or GCS bucket.
+// The requests are signed with the credentials in the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment
+// variables, the HMAC keys for GCS. Without them, GCS requests are authorized with the service account of the virtual
+// machine.
+type ObjectStore struct {
+	// Provider is "s3" or "gcs".
+	Provider string `json:"provider,omitempty"`
+	Bucket   string `json:"bucket,omitempty"`
+	// Object is the key of the object.
+	Object string `json:"object,omitempty"`
+	// Region is the AWS region of the bucket, required for S3.
+	Region string `json:"region,omitempty"`
+	// Endpoint is the URL of an S3 compatible object store, or of the GCS XML API. The provider's when empty.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Git is the git backend, a document of hardware records in a git repository that is pulled on an interval.
+type Git struct {
+	// Repository is the URL or path of the repository.
+	Repository string `json:"repository,omitempty"`
+	// Branch is the branch to serve. The default branch of the repository when empty.
+	Branch string `json:"branch,omitempty"`
+	// Path is the path of the document in the repository.
+	Path string `json:"path,omitempty"`
+}
+
+// ConfigMap is the configmap backend, a document of hardware records in a Kubernetes ConfigMap.
+type ConfigMap struct {
+	// Kubeconfig is the path of a kubeconfig. The in-cluster configuration is used when empty.
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name,omitempty"`
+	// Key is the data key of the document. When empty, the ConfigMap must have exactly one data key.
+	Key string `json:"key,omitempty"`
+}
+
+// Cache caches the reads of the backend, see the backend/cache package. Writes of the kube backend are not seen
+// through the cache until the cached read expires.
+type Cache struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// TTL is how long a read is cached. Defaults to 30s.
+	TTL Duration `json:"ttl,omitempty"`
+	// NegativeTTL is how long a hardware not found read is cached. Not found reads are not cached when it is zero.
+	NegativeTTL Duration `json:"negativeTTL,omitempty"`
+	// MaxEntries is the maximum number of cached reads. Defaults to 10000.
+	MaxEntries int `json:"maxEntries,omitempty"`
+}
+
+// Defaults are the records, looked up by MAC address, whose fields fill in the fields that the records of the backend
+// do not set, see the backend/merge package. For example, the site wide name servers of clients served by kube.
+type Defaults struct {
+	// Path is the path of a file in the data model of the file backend.
+	Path string `json:"path,omitempty"`
+}
+
+// Subnet is the data shared by the clients of a subnet, see the backend/subnet package.
+type Subnet struct {
+	// Prefix is the IPv4 CIDR of the subnet, for example 192.168.2.0/24.
+	Prefix string `json:"prefix"`
+	// Interface is the name of the interface the messages of the clients in the subnet are received on. It is only
+	// needed when the server listens on more than one link.
+	Interface    string   `json:"interface,omitempty"`
+	Gateways     []string `json:"gateways,omitempty"`
+	NameServers  []string `json:"nameServers,omitempty"`
+	DomainName   string   `json:"domainName,omitempty"`
+	NTPServers   []string `json:"ntpServers,omitempty"`
+	LeaseTime    uint32   `json:"leaseTime,omitempty"`
+	DomainSearch []string `json:"domainSearch,omitempty"`
+}
+
+// Duration is a time.Duration that is a string like "30s" or "1m30s" in the configuration file.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration %s is not a string like \"30s\"", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Netboot are the netboot options sent to the clients that are allowed to netboot.
+type Netboot struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// IPXETFTP is the IP:port of the TFTP server of the iPXE binaries.
+	IPXETFTP string `json:"ipxeTFTP,omitempty"`
+	// IPXEHTTP is the URL of the HTTP server of the iPXE binaries. Without it, UEFI HTTP Boot clients are answered like PXE
+	// clients, with the iPXE binary on the TFTP server.
+	IPXEHTTP string `json:"ipxeHTTP,omitempty"`
+	// IPXEScriptURL is the URL of the iPXE script.
+	IPXEScriptURL string `json:"ipxeScriptURL,omitempty"`
+	// UserClass is the user class, option 77, that breaks out of an iPXE loop.
+	UserClass string `json:"userClass,omitempty"`
+}
+
+// Log is the logging of the server.
+type Log struct {
+	// Level is the verbosity of the logs, higher is more verbose.
+	Level int `json:"level,omitempty"`
+}
+
+// Telemetry are the traces, metrics, and the HTTP endpoints of the server.
+type Telemetry struct {
+	OTEL OTEL `json:"otel"`
+	// MetricsAddr is the IP:port of /metrics and /healthz. Defaults to 127.0.0.1:9090, they are not served when empty.
+	MetricsAddr string `json:"metricsAddr"`
+	// DebugEndpoints, when set, serves the pprof and expvar endpoints next to the metrics.
+	DebugEndpoints bool `json:"debugEndpoints,omitempty"`
+	// AdminAddr is the IP:port of the admin API. It is not served when empty.
+	AdminAddr string `json:"adminAddr,omitempty"`
+}
+
+// OTEL is the export of traces with OpenTelemetry.
+type OTEL struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Endpoint is the OTLP endpoint traces are exported to. OTEL_EXPORTER_OTLP_ENDPOINT is used when empty.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Insecure, when set, exports traces without TLS.
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// Default returns the default configuration. It is not valid until at least Handler.IP is set.
+func Default() Config {
+	return Config{
+		Listener:  Listener{Addr: "0.0.0.0:67"},
+		Handler:   Handler{Mode: ModeReservation},
+		Backend:   Backend{Type: BackendFile},
+		Telemetry: Telemetry{MetricsAddr: "127.0.0.1:9090"},
+	}
+}
+
+// Load returns the configuration in the YAML or JSON file at path, over the defaults. It is not validated, so that
+// other sources, like flags, can complete it before Validate is called.
+func Load(path string) (Config, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return Config{}, err
+	}
+	c, err := Parse(b)
+	if err != nil {
+		return Config{}, fmt.Errorf("config %v: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// Parse returns the configuration in the YAML or JSON document b, over the defaults. Unknown keys are an error.
+func Parse(b []byte) (Config, error) {
+	j, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return Config{}, err
+	}
+	c := Default()
+	// an empty document is null.
+	if t := bytes.TrimSpace(j); len(t) == 0 || string(t) == "null" {
+		return c, nil
+	}
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&c); err != nil {
+		return Config{}, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// valid returns a valid configuration.
+func valid() Config {
+	c := Default()
+	c.Handler.IP = "192.168.2.225"
+	c.Backend.File.Path = "hardware.yaml"
+
+	return c
+}
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		doc     string
+		want    func(*Config)
+		wantErr string
+	}{
+		"empty": {want: func(*Config) {}},
+		"yaml": {
+			doc: `
+listener:
+  interface: eth0
+  unicast: true
+handler:
+  ip: 192.168.2.225
+backend:
+  type: isc
+  isc:
+    path: /etc/dhcp/dhcpd.conf
+netboot:
+  enabled: true
+  ipxeTFTP: 192.168.2.225:69
+log:
+  level: 2
+`,
+			want: func(c *Config) {
+				c.Listener.Interface, c.Listener.Unicast = "eth0", true
+				c.Handler.IP = "192.168.2.225"
+				c.Backend.Type, c.Backend.ISC.Path = BackendISC, "/etc/dhcp/dhcpd.conf"
+				c.Netboot.Enabled, c.Netboot.IPXETFTP = true, "192.168.2.225:69"
+				c.Log.Level = 2
+			},
+		},
+		"wrappers": {
+			doc: `
+backend:
+  file:
+    path: hardware.yaml
+  cache:
+    enabled: true
+    ttl: 1m
+  subnets:
+    - prefix: 192.168.2.0/24
+      gateways: [192.168.2.1]
+`,
+			want: func(c *Config) {
+				c.Backend.File.Path = "hardware.yaml"
+				c.Backend.Cache = Cache{Enabled: true, TTL: Duration(time.Minute)}
+				c.Backend.Subnets = []Subnet{{Prefix: "192.168.2.0/24", Gateways: []string{"192.168.2.1"}}}
+			},
+		},
+		"json": {
+			doc:  `{"handler": {"ip": "192.168.2.225"}, "telemetry": {"metricsAddr": ""}}`,
+			want: func(c *Config) { c.Handler.IP, c.Telemetry.MetricsAddr = "192.168.2.225", "" },
+		},
+		"unknown key": {
+			doc:     "handler:\n  ipAddr: 192.168.2.225\n",
+			wantErr: `unknown field "ipAddr"`,
+		},
+		"wrong type": {
+			doc:     "listener:\n  pxe: sometimes\n",
+			wantErr: "cannot unmarshal",
+		},
+		"duration": {
+			doc:     "backend:\n  cache:\n    ttl: 30\n",
+			wantErr: `duration 30 is not a string like "30s"`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.doc))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Default()
+			tt.want(&want)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "dhcp.yaml")
+	if err := os.WriteFile(p, []byte("handler:\n  ip: 192.168.2.225\n  mdoe: proxy\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(p); err == nil || !strings.Contains(err.Error(), p) {
+		t.Fatalf("Load() error = %v, want an error naming %v", err, p)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestExample(t *testing.T) {
+	c, err := Load("testdata/example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		c    func(*Config)
+		want []string
+	}{
+		"valid": {c: func(*Config) {}},
+		"default": {
+			c:    func(c *Config) { *c = Default() },
+			want: []string{"handler.ip: is required", `backend.file.path: is required when backend.type is "file"`},
+		},
+		"listener": {
+			c:    func(c *Config) { c.Listener.Addr = "[::]:67" },
+			want: []string{`listener.addr: "[::]:67" is not an IPv4 address`},
+		},
+		"unknown mode": {
+			c:    func(c *Config) { c.Handler.Mode = "proxy" },
+			want: []string{`handler.mode: "proxy" is not "reservation", the only supported mode`},
+		},
+		"ipv6 ip": {
+			c:    func(c *Config) { c.Handler.IP = "fd00::1" },
+			want: []string{`handler.ip: "fd00::1" is not an IPv4 address`},
+		},
+		"unknown backend": {
+			c:    func(c *Config) { c.Backend.Type = "ldap" },
+			want: []string{`backend.type: "ldap" is not one of "file", "csv", "isc", "remote", "hegel", "kube", "netbox", "maas", "dynamodb", "objectstore", "git", or "configmap"`},
+		},
+		"settings of another backend": {
+			c: func(c *Config) {
+				c.Backend.Type, c.Backend.File = BackendCSV, File{}
+				c.Backend.CSV.Path, c.Backend.Kube.Namespace = "hosts.csv", "tink-system"
+			},
+			want: []string{`backend.kube: is set but backend.type is "csv"`},
+		},
+		"isc format": {
+			c: func(c *Config) {
+				c.Backend.Type, c.Backend.File = BackendISC, File{}
+				c.Backend.ISC = ISC{Path: "dhcpd.conf", Format: "bind"}
+			},
+			want: []string{`backend.isc.format: "bind" is not one of "dhcpd" or "kea"`},
+		},
+		"remote url": {
+			c: func(c *Config) {
+				c.Backend.Type, c.Backend.File = BackendRemote, File{}
+				c.Backend.Remote.URL = "hardware.yaml"
+			},
+			want: []string{`backend.remote.url: "hardware.yaml" is not an http or https URL`},
+		},
+		"hegel": {
+			c: func(c *Config) {
+				c.Backend.Type, c.Backend.File = BackendHegel, File{}
+				c.Backend.Hegel.MACURL = "http://metadata/by-mac"
+			},
+			want: []string{
+				`backend.hegel.url: is required when backend.type is "hegel"`,
+				`backend.hegel.macURL: "http://metadata/by-mac" has no {mac} placeholder`,
+			},
+		},
+		"maas": {
+			c: func(c *Config) {
+				c.Backend.Type, c.Backend.File = BackendMAAS, File{}
+				c.Backend.MAAS = MAAS{URL: "http://maas:5240/MAAS", APIKey: "secret"}
+			},
+			want: []string{"backend.maas.apiKey: is not <consumer key>:<token key>:<token secret>"},
+		},
+		"objectstore": {
+			c: func(c *Config) {
+				c.Backend.Type, c.Backend.File = BackendObjectStore, File{}
+				c.Backend.ObjectStore = ObjectStore{Provider: "s3", Bucket: "dhcp"}
+			},
+			want: []string{
+				`backend.objectstore.object: is required when backend.type is "objectstore"`,
+				`backend.objectstore.region: is required when backend.objectstore.provider is "s3"`,
+			},
+		},
+		"cache": {
+			c:    func(c *Config) { c.Backend.Cache = Cache{TTL: Duration(-time.Second)} },
+			want: []string{"backend.cache: is set but backend.cache.enabled is false", "backend.cache.ttl: -1s is negative"},
+		},
+		"subnets": {
+			c: func(c *Config) {
+				c.Backend.Subnets = []Subnet{
+					{Prefix: "192.168.2.0/24", Gateways: []string{"192.168.2.1"}},
+					{Prefix: "fd00::/64", NameServers: []string{"1.1.1.1", "dns"}},
+				}
+			},
+			want: []string{
+				`backend.subnets[1].prefix: "fd00::/64" is not an IPv4 CIDR`,
+				`backend.subnets[1].nameServers[1]: "dns" is not an IPv4 address`,
+			},
+		},
+		"netboot": {
+			c: func(c *Config) {
+				c.Netboot = Netboot{Enabled: true, IPXEScriptURL: "tftp://192.168.2.225/auto.ipxe"}
+			},
+			want: []string{
+				`netboot.ipxeScriptURL: "tftp://192.168.2.225/auto.ipxe" is not an http or https URL`,
+				"netboot.enabled: requires netboot.ipxeTFTP or netboot.ipxeHTTP, where clients download iPXE from",
+			},
+		},
+		"telemetry": {
+			c: func(c *Config) {
+				c.Log.Level = -1
+				c.Telemetry = Telemetry{DebugEndpoints: true, AdminAddr: "8081", OTEL: OTEL{Insecure: true}}
+			},
+			want: []string{
+				"log.level: -1 is negative",
+				`telemetry.adminAddr: "8081" is not a host:port`,
+				"telemetry.debugEndpoints: requires telemetry.metricsAddr, they are served next to the metrics",
+				"telemetry.otel: is set but telemetry.otel.enabled is false",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := valid()
+			tt.c(&c)
+			err := c.Validate()
+			var got []string
+			if err != nil {
+				got = strings.Split(err.Error(), "\n")
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+			if err != nil {
+				var fe *FieldError
+				if !errors.As(err, &fe) {
+					t.Fatalf("Validate() error = %v, want a *FieldError", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FieldError is an invalid setting.
+type FieldError struct {
+	// Field is the path of the key of the setting, for example "backend.file.path".
+	Field string
+	// Msg describes what is wrong with the setting.
+	Msg string
+}
+
+// Error implements the error interface.
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Msg
+}
+
+// validator collects the FieldErrors of a configuration.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.errs = append(v.errs, &FieldError{Field: field, Msg: fmt.Sprintf(format, args...)})
+}
+
+// Validate returns the invalid settings of c, each a *FieldError, joined with errors.Join. It returns nil if c is valid.
+func (c Config) Validate() error {
+	v := &validator{}
+	c.validateListener(v)
+	c.validateHandler(v)
+	c.Backend.validate(v)
+	c.Netboot.validate(v)
+	if c.Log.Level < 0 {
+		v.add("log.level", "%d is negative", c.Log.Level)
+	}
+	c.validateTelemetry(v)
+
+	return errors.Join(v.errs...)
+}
+
+func (c Config) validateListener(v *validator) {
+	if a, err := netip.ParseAddrPort(c.Listener.Addr); err != nil {
+		v.add("listener.addr", "%q is not an IP:port", c.Listener.Addr)
+	} else if !a.Addr().Is4() {
+		v.add("listener.addr", "%q is not an IPv4 address", c.Listener.Addr)
+	}
+}
+
+func (c Config) validateHandler(v *validator) {
+	if c.Handler.Mode != ModeReservation {
+		v.add("handler.mode", "%q is not %q, the only supported mode", c.Handler.Mode, ModeReservation)
+	}
+	if c.Handler.IP == "" {
+		v.add("handler.ip", "is required")
+	} else if ip, err := netip.ParseAddr(c.Handler.IP); err != nil || !ip.Is4() {
+		v.add("handler.ip", "%q is not an IPv4 address", c.Handler.IP)
+	}
+}
+
+// backendTypes are the types of the backends, in the order they are listed in errors.
+var backendTypes = []BackendType{
+	BackendFile, BackendCSV, BackendISC, BackendRemote, BackendHegel, BackendKube,
+	BackendNetbox, BackendMAAS, BackendDynamoDB, BackendObjectStore, BackendGit, BackendConfigMap,
+}
+
+// validate checks the settings of the selected backend, and that the settings of the others are not set, which is
+// usually a mistake in Type. Then it checks the settings of the wrappers.
+func (b Backend) validate(v *validator) {
+	sections := map[BackendType]bool{
+		BackendFile:        b.File != File{},
+		BackendCSV:         b.CSV != CSV{},
+		BackendISC:         b.ISC != ISC{},
+		BackendRemote:      b.Remote != Remote{},
+		BackendHegel:       b.Hegel != Hegel{},
+		BackendKube:        b.Kube != Kube{},
+		BackendNetbox:      b.Netbox != Netbox{},
+		BackendMAAS:        b.MAAS != MAAS{},
+		BackendDynamoDB:    b.DynamoDB != DynamoDB{},
+		BackendObjectStore: b.ObjectStore != ObjectStore{},
+		BackendGit:         b.Git != Git{},
+		BackendConfigMap:   b.ConfigMap != ConfigMap{},
+	}
+	if _, ok := sections[b.Type]; !ok {
+		quoted := make([]string, len(backendTypes))
+		for i, t := range backendTypes {
+			quoted[i] = fmt.Sprintf("%q", t)
+		}
+		v.add("backend.type", "%q is not one of %v, or %v", b.Type, strings.Join(quoted[:len(quoted)-1], ", "), quoted[len(quoted)-1])
+	} else {
+		for _, t := range backendTypes {
+			if t != b.Type && sections[t] {
+				v.add("backend."+string(t), "is set but backend.type is %q", b.Type)
+			}
+		}
+	}
+
+	switch b.Type {
+	case BackendFile:
+		required(v, "backend.file.path", b.File.Path, b.Type)
+	case BackendCSV:
+		required(v, "backend.csv.path", b.CSV.Path, b.Type)
+	case BackendISC:
+		required(v, "backend.isc.path", b.ISC.Path, b.Type)
+		if f := b.ISC.Format; f != "" && f != "dhcpd" && f != "kea" {
+			v.add("backend.isc.format", "%q is not one of \"dhcpd\" or \"kea\"", f)
+		}
+	case BackendRemote:
+		if required(v, "backend.remote.url", b.Remote.URL, b.Type) {
+			httpURL(v, "backend.remote.url", b.Remote.URL)
+		}
+	case BackendHegel:
+		if required(v, "backend.hegel.url", b.Hegel.URL, b.Type) {
+			httpURL(v, "backend.hegel.url", b.Hegel.URL)
+		}
+		if b.Hegel.MACURL != "" && !strings.Contains(b.Hegel.MACURL, "{mac}") {
+			v.add("backend.hegel.macURL", "%q has no {mac} placeholder", b.Hegel.MACURL)
+		}
+	case BackendKube:
+	case BackendNetbox:
+		if required(v, "backend.netbox.url", b.Netbox.URL, b.Type) {
+			httpURL(v, "backend.netbox.url", b.Netbox.URL)
+		}
+		required(v, "backend.netbox.token", b.Netbox.Token, b.Type)
+	case BackendMAAS:
+		if required(v, "backend.maas.url", b.MAAS.URL, b.Type) {
+			httpURL(v, "backend.maas.url", b.MAAS.URL)
+		}
+		// the key is a secret, so it is not quoted in the error.
+		if required(v, "backend.maas.apiKey", b.MAAS.APIKey, b.Type) && strings.Count(b.MAAS.APIKey, ":") != 2 {
+			v.add("backend.maas.apiKey", "is not <consumer key>:<token key>:<token secret>")
+		}
+	case BackendDynamoDB:
+		required(v, "backend.dynamodb.table", b.DynamoDB.Table, b.Type)
+		required(v, "backend.dynamodb.region", b.DynamoDB.Region, b.Type)
+		if b.DynamoDB.Endpoint != "" {
+			httpURL(v, "backend.dynamodb.endpoint", b.DynamoDB.Endpoint)
+		}
+	case BackendObjectStore:
+		o := b.ObjectStore
+		if required(v, "backend.objectstore.provider", o.Provider, b.Type) && o.Provider != "s3" && o.Provider != "gcs" {
+			v.add("backend.objectstore.provider", "%q is not one of \"s3\" or \"gcs\"", o.Provider)
+		}
+		required(v, "backend.objectstore.bucket", o.Bucket, b.Type)
+		required(v, "backend.objectstore.object", o.Object, b.Type)
+		if o.Provider == "s3" && o.Region == "" {
+			v.add("backend.objectstore.region", "is required when backend.objectstore.provider is \"s3\"")
+		}
+		if o.Endpoint != "" {
+			httpURL(v, "backend.objectstore.endpoint", o.Endpoint)
+		}
+	case BackendGit:
+		required(v, "backend.git.repository", b.Git.Repository, b.Type)
+		required(v, "backend.git.path", b.Git.Path, b.Type)
+	case BackendConfigMap:
+		required(v, "backend.configmap.namespace", b.ConfigMap.Namespace, b.Type)
+		required(v, "backend.configmap.name", b.ConfigMap.Name, b.Type)
+	}
+
+	b.Cache.validate(v)
+	for i, s := range b.Subnets {
+		s.validate(v, fmt.Sprintf("backend.subnets[%d]", i))
+	}
+}
+
+func (c Cache) validate(v *validator) {
+	if !c.Enabled && c != (Cache{}) {
+		v.add("backend.cache", "is set but backend.cache.enabled is false")
+	}
+	if c.TTL < 0 {
+		v.add("backend.cache.ttl", "%v is negative", time.Duration(c.TTL))
+	}
+	if c.NegativeTTL < 0 {
+		v.add("backend.cache.negativeTTL", "%v is negative", time.Duration(c.NegativeTTL))
+	}
+	if c.MaxEntries < 0 {
+		v.add("backend.cache.maxEntries", "%d is negative", c.MaxEntries)
+	}
+}
+
+// validate checks the subnet s, whose settings are under the key field.
+func (s Subnet) validate(v *validator, field string) {
+	if s.Prefix == "" {
+		v.add(field+".prefix", "is required")
+	} else if p, err := netip.ParsePrefix(s.Prefix); err != nil || !p.Addr().Is4() {
+		v.add(field+".prefix", "%q is not an IPv4 CIDR", s.Prefix)
+	}
+	ipv4s(v, field+".gateways", s.Gateways)
+	ipv4s(v, field+".nameServers", s.NameServers)
+	ipv4s(v, field+".ntpServers", s.NTPServers)
+}
+
+// ipv4s adds an error for each of ips that is not an IPv4 address.
+func ipv4s(v *validator, field string, ips []string) {
+	for i, ip := range ips {
+		if a, err := netip.ParseAddr(ip); err != nil || !a.Is4() {
+			v.add(fmt.Sprintf("%v[%d]", field, i), "%q is not an IPv4 address", ip)
+		}
+	}
+}
+
+func (n Netboot) validate(v *validator) {
+	if n.IPXETFTP != "" {
+		if _, err := netip.ParseAddrPort(n.IPXETFTP); err != nil {
+			v.add("netboot.ipxeTFTP", "%q is not an IP:port", n.IPXETFTP)
+		}
+	}
+	if n.IPXEHTTP != "" {
+		httpURL(v, "netboot.ipxeHTTP", n.IPXEHTTP)
+	}
+	if n.IPXEScriptURL != "" {
+		httpURL(v, "netboot.ipxeScriptURL", n.IPXEScriptURL)
+	}
+	if n.Enabled && n.IPXETFTP == "" && n.IPXEHTTP == "" {
+		v.add("netboot.enabled", "requires netboot.ipxeTFTP or netboot.ipxeHTTP, where clients download iPXE from")
+	}
+}
+
+func (c Config) validateTelemetry(v *validator) {
+	t := c.Telemetry
+	if t.MetricsAddr != "" {
+		hostPort(v, "telemetry.metricsAddr", t.MetricsAddr)
+	}
+	if t.AdminAddr != "" {
+		hostPort(v, "telemetry.adminAddr", t.AdminAddr)
+		if t.AdminAddr == t.MetricsAddr {
+			v.add("telemetry.adminAddr", "%q is also telemetry.metricsAddr", t.AdminAddr)
+		}
+	}
+	if t.DebugEndpoints && t.MetricsAddr == "" {
+		v.add("telemetry.debugEndpoints", "requires telemetry.metricsAddr, they are served next to the metrics")
+	}
+	if !t.OTEL.Enabled && (t.OTEL.Endpoint != "" || t.OTEL.Insecure) {
+		v.add("telemetry.otel", "is set but telemetry.otel.enabled is false")
+	}
+}
+
+// required adds an error when the setting s of the backend of type t is empty. It returns true if s is set.
+func required(v *validator, field, s string, t BackendType) bool {
+	if s == "" {
+		v.add(field, "is required when backend.type is %q", t)
+		return false
+	}
+
+	return true
+}
+
+// httpURL adds an error when s is not an absolute http or https URL.
+func httpURL(v *validator, field, s string) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(field, "%q is not an http or https URL", s)
+	}
+}
+
+// hostPort adds an error when s is not a host:port.
+func hostPort(v *validator, field, s string) {
+	if _, _, err := net.SplitHostPort(s); err != nil {
+		v.add(field, "%q is not a host:port", s)
+	}
+}
@@ -0,0 +1,60 @@
+package dhcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// NewConn opens a UDP connection on addr for a Server. When ifname is set, the connection only receives the messages
+// received on the interface ifname. The socket can send broadcasts, and shares its port with other connections, for
+// example the one on the server IP opened by Server.ListenUnicast.
+//
+// The platforms differ in how a socket is bound to an interface:
+//
+//   - On Linux, the socket is bound with SO_BINDTODEVICE. This is the platform the server is deployed on.
+//   - On macOS and the BSDs, the socket is bound with IP_BOUND_IF, or the closest option the platform has, so the
+//     server can be run locally during development.
+//   - On Windows, support is best-effort: a socket can not be bound to an interface, ifname is ignored and
+//     the connection receives the messages of all interfaces. The interface of a message is not known either,
+//     so handlers get an empty interface name and index.
+func NewConn(ifname string, addr *net.UDPAddr) (net.PacketConn, error) {
+	lc := net.ListenConfig{
+		Control: func(_, _ string, rc syscall.RawConn) error {
+			var serr error
+			if err := rc.Control(func(fd uintptr) {
+				serr = setSockopts(fd, ifname)
+			}); err != nil {
+				return err
+			}
+
+			return serr
+		},
+	}
+
+	return lc.ListenPacket(context.Background(), "udp4", addr.String())
+}
+
+// bindToInterface binds the socket of conn to the interface ifname.
+func bindToInterface(conn net.PacketConn, ifname string) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("can not bind %T to interface %v, it does not expose its socket", conn, ifname)
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var berr error
+	if err := rc.Control(func(fd uintptr) {
+		berr = bindDevice(fd, ifname)
+	}); err != nil {
+		return err
+	}
+	if berr != nil {
+		return fmt.Errorf("can not bind to interface %v: %w", ifname, berr)
+	}
+
+	return nil
+}
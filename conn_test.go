@@ -0,0 +1,40 @@
+package dhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNewConn(t *testing.T) {
+	conn, err := NewConn("", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// a second connection on the server IP shares the port, as Server.ListenUnicast does.
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	unicast, err := NewConn("", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatalf("NewConn() error = %v, want the port shared", err)
+	}
+	defer unicast.Close()
+
+	c, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.WriteTo([]byte("message"), unicast.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	_ = unicast.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 16)
+	n, _, err := unicast.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "message" {
+		t.Fatalf("got %q, want %q", got, "message")
+	}
+}
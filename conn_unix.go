@@ -0,0 +1,31 @@
+//go:build unix
+
+package dhcp
+
+import (
+	"github.com/insomniacslk/dhcp/interfaces"
+	"golang.org/x/sys/unix"
+)
+
+// interfaceControlMessage is true when the interface a message was received on is known from its control message.
+const interfaceControlMessage = true
+
+// setSockopts sets the options of a socket opened by NewConn, before it is bound to its address.
+func setSockopts(fd uintptr, ifname string) error {
+	if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+		return err
+	}
+	if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_BROADCAST, 1); err != nil {
+		return err
+	}
+	if ifname == "" {
+		return nil
+	}
+
+	return bindDevice(fd, ifname)
+}
+
+// bindDevice binds the socket fd to the interface ifname, with SO_BINDTODEVICE on Linux and IP_BOUND_IF on macOS.
+func bindDevice(fd uintptr, ifname string) error {
+	return interfaces.BindToInterface(int(fd), ifname)
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package dhcp
+
+import (
+	"errors"
+	"syscall"
+)
+
+// interfaceControlMessage is true when the interface a message was received on is known from its control message.
+// Windows does not support the control message.
+const interfaceControlMessage = false
+
+// setSockopts sets the options of a socket opened by NewConn, before it is bound to its address.
+// A socket can not be bound to an interface on Windows, so ifname is ignored.
+func setSockopts(fd uintptr, _ string) error {
+	return syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+}
+
+// bindDevice returns an error, a socket can not be bound to an interface on Windows.
+func bindDevice(uintptr, string) error {
+	return errors.New("binding to an interface is not supported on Windows")
+}
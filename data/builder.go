@@ -0,0 +1,285 @@
+package data
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+)
+
+// DefaultLeaseTime is the lease time, in seconds, of a DHCP record built by NewDHCP without WithLeaseTime.
+const DefaultLeaseTime uint32 = 86400
+
+// DHCPOption configures a DHCP record built by NewDHCP.
+type DHCPOption func(*DHCP)
+
+// NetbootOption configures a Netboot record built by NewNetboot.
+type NetbootOption func(*Netboot)
+
+// NewDHCP returns a DHCP record for the client mac with the IPv4 address ip in a network with the subnet mask mask,
+// configured by opts. For example:
+//
+//	d, err := data.NewDHCP(mac, netip.MustParseAddr("192.168.2.150"), net.CIDRMask(24, 32),
+//		data.WithDefaultGateways(netip.MustParseAddr("192.168.2.1")),
+//		data.WithNameServers(net.IPv4(1, 1, 1, 1)),
+//	)
+//
+// The broadcast address is derived from ip and mask and the lease time is DefaultLeaseTime, unless opts set them.
+// An ErrInvalidRecord error is returned if a required field is missing or a field can not be sent on the wire.
+func NewDHCP(mac net.HardwareAddr, ip netip.Addr, mask net.IPMask, opts ...DHCPOption) (*DHCP, error) {
+	d := &DHCP{MACAddress: mac, IPAddress: ip.Unmap(), SubnetMask: mask, LeaseTime: DefaultLeaseTime}
+	for _, o := range opts {
+		o(d)
+	}
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	if !d.BroadcastAddress.IsValid() {
+		d.BroadcastAddress = Broadcast(d.IPAddress, d.SubnetMask)
+	}
+
+	return d, nil
+}
+
+// Broadcast returns the broadcast address, DHCP option 28, of the network of the IPv4 address ip with the subnet mask
+// mask. The zero Addr is returned if ip is not an IPv4 address or mask is not an IPv4 mask.
+func Broadcast(ip netip.Addr, mask net.IPMask) netip.Addr {
+	ip = ip.Unmap()
+	if !ip.Is4() || len(mask) != net.IPv4len {
+		return netip.Addr{}
+	}
+	b := ip.As4()
+	for i := range b {
+		b[i] |= ^mask[i]
+	}
+
+	return netip.AddrFrom4(b)
+}
+
+// validate returns an ErrInvalidRecord error describing the first field of d that is missing or not valid.
+func (d *DHCP) validate() error {
+	if len(d.MACAddress) == 0 {
+		return fmt.Errorf("%w: no MAC address", ErrInvalidRecord)
+	}
+	if !d.IPAddress.Is4() || d.IPAddress.IsUnspecified() {
+		return fmt.Errorf("%w: IP address %q is not a valid IPv4 address", ErrInvalidRecord, d.IPAddress)
+	}
+	if ones, bits := d.SubnetMask.Size(); bits != 32 || ones == 0 {
+		return fmt.Errorf("%w: subnet mask %v is not a valid IPv4 mask", ErrInvalidRecord, net.IP(d.SubnetMask))
+	}
+	for _, gw := range d.DefaultGateways {
+		if !gw.Unmap().Is4() {
+			return fmt.Errorf("%w: default gateway %q is not an IPv4 address", ErrInvalidRecord, gw)
+		}
+	}
+	for _, ips := range []struct {
+		name string
+		ips  []net.IP
+	}{{"name server", d.NameServers}, {"NTP server", d.NTPServers}} {
+		for _, ip := range ips.ips {
+			if ip.To4() == nil {
+				return fmt.Errorf("%w: %v %q is not an IPv4 address", ErrInvalidRecord, ips.name, ip)
+			}
+		}
+	}
+	if d.BroadcastAddress.IsValid() && !d.BroadcastAddress.Unmap().Is4() {
+		return fmt.Errorf("%w: broadcast address %q is not an IPv4 address", ErrInvalidRecord, d.BroadcastAddress)
+	}
+	if len(d.SIPServerNames) > 0 && len(d.SIPServerAddresses) > 0 {
+		return fmt.Errorf("%w: SIP servers are set as both names and addresses", ErrInvalidRecord)
+	}
+	for _, ip := range d.SIPServerAddresses {
+		if ip.To4() == nil {
+			return fmt.Errorf("%w: SIP server %q is not an IPv4 address", ErrInvalidRecord, ip)
+		}
+	}
+	// 4095 is reserved by 802.1Q and the priority is a 3 bit field.
+	if d.VLANTag > 4094 {
+		return fmt.Errorf("%w: VLAN tag %d is more than the maximum of 4094", ErrInvalidRecord, d.VLANTag)
+	}
+	if d.VLANPriority > 7 {
+		return fmt.Errorf("%w: VLAN priority %d is more than the maximum of 7", ErrInvalidRecord, d.VLANPriority)
+	}
+	// RFC 2132 section 5.1, the MTU can not be less than 68.
+	if d.MTU != 0 && d.MTU < 68 {
+		return fmt.Errorf("%w: MTU %d is less than the minimum of 68", ErrInvalidRecord, d.MTU)
+	}
+	if d.LeaseTime == 0 {
+		return fmt.Errorf("%w: lease time is 0", ErrInvalidRecord)
+	}
+
+	return nil
+}
+
+// WithDefaultGateways sets the default gateways, DHCP option 3, in order of preference.
+func WithDefaultGateways(gws ...netip.Addr) DHCPOption {
+	return func(d *DHCP) { d.DefaultGateways = gws }
+}
+
+// WithNameServers sets the DNS servers, DHCP option 6.
+func WithNameServers(ips ...net.IP) DHCPOption {
+	return func(d *DHCP) { d.NameServers = ips }
+}
+
+// WithHostname sets the hostname, DHCP option 12.
+func WithHostname(name string) DHCPOption {
+	return func(d *DHCP) { d.Hostname = name }
+}
+
+// WithDomainName sets the domain name, DHCP option 15.
+func WithDomainName(name string) DHCPOption {
+	return func(d *DHCP) { d.DomainName = name }
+}
+
+// WithMTU sets the interface MTU, DHCP option 26.
+func WithMTU(mtu uint16) DHCPOption {
+	return func(d *DHCP) { d.MTU = mtu }
+}
+
+// WithBroadcastAddress sets the broadcast address, DHCP option 28, instead of deriving it from the IP address and subnet mask.
+func WithBroadcastAddress(ip netip.Addr) DHCPOption {
+	return func(d *DHCP) { d.BroadcastAddress = ip }
+}
+
+// WithNTPServers sets the NTP servers, DHCP option 42.
+func WithNTPServers(ips ...net.IP) DHCPOption {
+	return func(d *DHCP) { d.NTPServers = ips }
+}
+
+// WithVLANID sets the VLAN ID, DHCP option 43.116, that the client's iPXE configures.
+func WithVLANID(id string) DHCPOption {
+	return func(d *DHCP) { d.VLANID = id }
+}
+
+// WithVendorOption sets the DHCP option 43 sub-option code to value, see DHCP.VendorOptions.
+func WithVendorOption(code uint8, value []byte) DHCPOption {
+	return func(d *DHCP) {
+		if d.VendorOptions == nil {
+			d.VendorOptions = make(map[uint8][]byte)
+		}
+		d.VendorOptions[code] = value
+	}
+}
+
+// WithLeaseTime sets the lease time in seconds, DHCP option 51.
+func WithLeaseTime(seconds uint32) DHCPOption {
+	return func(d *DHCP) { d.LeaseTime = seconds }
+}
+
+// WithTFTPServerName sets the TFTP server name, DHCP option 66.
+func WithTFTPServerName(name string) DHCPOption {
+	return func(d *DHCP) { d.TFTPServerName = name }
+}
+
+// WithBootFileName sets the boot file name, DHCP option 67.
+func WithBootFileName(name string) DHCPOption {
+	return func(d *DHCP) { d.BootFileName = name }
+}
+
+// WithArch sets the architecture of the client, DHCP option 93, e.g. "x86_64".
+func WithArch(arch string) DHCPOption {
+	return func(d *DHCP) { d.Arch = arch }
+}
+
+// WithTimezone sets the POSIX TZ string, DHCP option 100, and the tz database name, DHCP option 101, of the client.
+// An empty string is not sent.
+func WithTimezone(posix, database string) DHCPOption {
+	return func(d *DHCP) { d.TZPOSIX, d.TZDatabase = posix, database }
+}
+
+// WithDomainSearch sets the domain search list, DHCP option 119.
+func WithDomainSearch(domains ...string) DHCPOption {
+	return func(d *DHCP) { d.DomainSearch = domains }
+}
+
+// WithSIPServerNames sets the SIP servers, DHCP option 120, as domain names.
+// It can not be combined with WithSIPServerAddresses.
+func WithSIPServerNames(names ...string) DHCPOption {
+	return func(d *DHCP) { d.SIPServerNames = names }
+}
+
+// WithSIPServerAddresses sets the SIP servers, DHCP option 120, as IPv4 addresses.
+// It can not be combined with WithSIPServerNames.
+func WithSIPServerAddresses(ips ...net.IP) DHCPOption {
+	return func(d *DHCP) { d.SIPServerAddresses = ips }
+}
+
+// WithClasslessStaticRoutes sets the classless static routes, DHCP option 121.
+func WithClasslessStaticRoutes(routes ...Route) DHCPOption {
+	return func(d *DHCP) { d.ClasslessStaticRoutes = routes }
+}
+
+// WithVLAN sets the 802.1Q VLAN ID, DHCP option 132, and the 802.1p priority, DHCP option 133, that the client tags its
+// traffic with.
+func WithVLAN(tag uint16, priority uint8) DHCPOption {
+	return func(d *DHCP) { d.VLANTag, d.VLANPriority = tag, priority }
+}
+
+// WithOtherOption sets the DHCP option code to value, see DHCP.OtherOptions.
+func WithOtherOption(code uint8, value []byte) DHCPOption {
+	return func(d *DHCP) {
+		if d.OtherOptions == nil {
+			d.OtherOptions = make(map[uint8][]byte)
+		}
+		d.OtherOptions[code] = value
+	}
+}
+
+// NewNetboot returns a Netboot record configured by opts. The client is allowed to netboot when allow is true.
+// An ErrInvalidRecord error is returned if a URL is not absolute.
+func NewNetboot(allow bool, opts ...NetbootOption) (*Netboot, error) {
+	n := &Netboot{AllowNetboot: allow}
+	for _, o := range opts {
+		o(n)
+	}
+	for _, u := range []struct {
+		name string
+		u    *url.URL
+	}{{"iPXE script URL", n.IPXEScriptURL}, {"OSIE base URL", n.OSIE.BaseURL}, {"HTTP boot URI", n.HTTPBootURI}} {
+		if u.u != nil && (u.u.Scheme == "" || u.u.Host == "") {
+			return nil, fmt.Errorf("%w: %v %q is not an absolute URL", ErrInvalidRecord, u.name, u.u.Redacted())
+		}
+	}
+
+	return n, nil
+}
+
+// WithIPXEScriptURL sets the URL of the iPXE script that the client chains to.
+func WithIPXEScriptURL(u *url.URL) NetbootOption {
+	return func(n *Netboot) { n.IPXEScriptURL = u }
+}
+
+// WithIPXEScript sets the iPXE script of the client.
+func WithIPXEScript(script string) NetbootOption {
+	return func(n *Netboot) { n.IPXEScript = script }
+}
+
+// WithIPXEBinary sets the iPXE binary of the client, instead of the one for its architecture.
+func WithIPXEBinary(name string) NetbootOption {
+	return func(n *Netboot) { n.IPXEBinary = name }
+}
+
+// WithHTTPBootURI sets the URI that UEFI HTTP Boot clients boot directly.
+func WithHTTPBootURI(u *url.URL) NetbootOption {
+	return func(n *Netboot) { n.HTTPBootURI = u }
+}
+
+// WithConsole sets the serial console of the client, e.g. "ttyS1,115200".
+func WithConsole(console string) NetbootOption {
+	return func(n *Netboot) { n.Console = console }
+}
+
+// WithFacility sets the facility code of the client.
+func WithFacility(facility string) NetbootOption {
+	return func(n *Netboot) { n.Facility = facility }
+}
+
+// WithKernelParams sets extra kernel command line parameters.
+func WithKernelParams(params string) NetbootOption {
+	return func(n *Netboot) { n.KernelParams = params }
+}
+
+// WithOSIE sets the location of the Operating System Installation Environment.
+func WithOSIE(o OSIE) NetbootOption {
+	return func(n *Netboot) { n.OSIE = o }
+}
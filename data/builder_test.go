@@ -0,0 +1,149 @@
+package data
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewDHCP(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	ip := netip.MustParseAddr("192.168.2.150")
+	mask := net.CIDRMask(24, 32)
+	tests := map[string]struct {
+		mac     net.HardwareAddr
+		ip      netip.Addr
+		mask    net.IPMask
+		opts    []DHCPOption
+		want    *DHCP
+		wantErr error
+	}{
+		"defaults": {
+			mac: mac, ip: ip, mask: mask,
+			want: &DHCP{MACAddress: mac, IPAddress: ip, SubnetMask: mask, BroadcastAddress: netip.MustParseAddr("192.168.2.255"), LeaseTime: DefaultLeaseTime},
+		},
+		"options": {
+			mac: mac, ip: ip, mask: mask,
+			opts: []DHCPOption{
+				WithDefaultGateways(netip.MustParseAddr("192.168.2.1")),
+				WithNameServers(net.IP{1, 1, 1, 1}),
+				WithHostname("server"),
+				WithDomainName("example.com"),
+				WithBroadcastAddress(netip.MustParseAddr("192.168.2.254")),
+				WithLeaseTime(3600),
+				WithVLANID("100"),
+				WithVendorOption(1, []byte{0x0a}),
+				WithArch("x86_64"),
+				WithTimezone("CET-1CEST,M3.5.0,M10.5.0/3", "Europe/Amsterdam"),
+				WithSIPServerNames("sip.example.com"),
+				WithVLAN(100, 3),
+				WithOtherOption(224, []byte{0x01}),
+			},
+			want: &DHCP{
+				MACAddress:       mac,
+				IPAddress:        ip,
+				SubnetMask:       mask,
+				DefaultGateways:  []netip.Addr{netip.MustParseAddr("192.168.2.1")},
+				NameServers:      []net.IP{{1, 1, 1, 1}},
+				Hostname:         "server",
+				DomainName:       "example.com",
+				BroadcastAddress: netip.MustParseAddr("192.168.2.254"),
+				LeaseTime:        3600,
+				VLANID:           "100",
+				VendorOptions:    map[uint8][]byte{1: {0x0a}},
+				Arch:             "x86_64",
+				TZPOSIX:          "CET-1CEST,M3.5.0,M10.5.0/3",
+				TZDatabase:       "Europe/Amsterdam",
+				SIPServerNames:   []string{"sip.example.com"},
+				VLANTag:          100,
+				VLANPriority:     3,
+				OtherOptions:     map[uint8][]byte{224: {0x01}},
+			},
+		},
+		"IPv4-mapped IP": {
+			mac: mac, ip: netip.MustParseAddr("::ffff:192.168.2.150"), mask: mask,
+			want: &DHCP{MACAddress: mac, IPAddress: ip, SubnetMask: mask, BroadcastAddress: netip.MustParseAddr("192.168.2.255"), LeaseTime: DefaultLeaseTime},
+		},
+		"no MAC":             {ip: ip, mask: mask, wantErr: ErrInvalidRecord},
+		"no IP":              {mac: mac, mask: mask, wantErr: ErrInvalidRecord},
+		"IPv6":               {mac: mac, ip: netip.MustParseAddr("2001:db8::1"), mask: mask, wantErr: ErrInvalidRecord},
+		"no mask":            {mac: mac, ip: ip, wantErr: ErrInvalidRecord},
+		"IPv6 mask":          {mac: mac, ip: ip, mask: net.CIDRMask(64, 128), wantErr: ErrInvalidRecord},
+		"IPv6 gateway":       {mac: mac, ip: ip, mask: mask, opts: []DHCPOption{WithDefaultGateways(netip.MustParseAddr("2001:db8::1"))}, wantErr: ErrInvalidRecord},
+		"IPv6 name server":   {mac: mac, ip: ip, mask: mask, opts: []DHCPOption{WithNameServers(net.ParseIP("2001:db8::1"))}, wantErr: ErrInvalidRecord},
+		"MTU too small":      {mac: mac, ip: ip, mask: mask, opts: []DHCPOption{WithMTU(60)}, wantErr: ErrInvalidRecord},
+		"lease time of zero": {mac: mac, ip: ip, mask: mask, opts: []DHCPOption{WithLeaseTime(0)}, wantErr: ErrInvalidRecord},
+		"SIP names and addresses": {
+			mac: mac, ip: ip, mask: mask,
+			opts:    []DHCPOption{WithSIPServerNames("sip.example.com"), WithSIPServerAddresses(net.IP{192, 168, 2, 5})},
+			wantErr: ErrInvalidRecord,
+		},
+		"IPv6 SIP server": {mac: mac, ip: ip, mask: mask, opts: []DHCPOption{WithSIPServerAddresses(net.ParseIP("2001:db8::1"))}, wantErr: ErrInvalidRecord},
+		"reserved VLAN":   {mac: mac, ip: ip, mask: mask, opts: []DHCPOption{WithVLAN(4095, 0)}, wantErr: ErrInvalidRecord},
+		"VLAN priority":   {mac: mac, ip: ip, mask: mask, opts: []DHCPOption{WithVLAN(100, 8)}, wantErr: ErrInvalidRecord},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewDHCP(tt.mac, tt.ip, tt.mask, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewDHCP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.Comparer(func(a, b netip.Addr) bool { return a == b })); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	tests := map[string]struct {
+		ip   netip.Addr
+		mask net.IPMask
+		want netip.Addr
+	}{
+		"/24":         {ip: netip.MustParseAddr("192.168.2.150"), mask: net.CIDRMask(24, 32), want: netip.MustParseAddr("192.168.2.255")},
+		"/20":         {ip: netip.MustParseAddr("10.0.17.3"), mask: net.CIDRMask(20, 32), want: netip.MustParseAddr("10.0.31.255")},
+		"IPv4-mapped": {ip: netip.MustParseAddr("::ffff:192.168.2.150"), mask: net.CIDRMask(24, 32), want: netip.MustParseAddr("192.168.2.255")},
+		"IPv6":        {ip: netip.MustParseAddr("2001:db8::1"), mask: net.CIDRMask(24, 32)},
+		"IPv6 mask":   {ip: netip.MustParseAddr("192.168.2.150"), mask: net.CIDRMask(64, 128)},
+		"no mask":     {ip: netip.MustParseAddr("192.168.2.150")},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Broadcast(tt.ip, tt.mask); got != tt.want {
+				t.Errorf("Broadcast() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewNetboot(t *testing.T) {
+	script := &url.URL{Scheme: "http", Host: "192.168.2.5", Path: "/auto.ipxe"}
+	tests := map[string]struct {
+		opts    []NetbootOption
+		want    *Netboot
+		wantErr error
+	}{
+		"options": {
+			opts: []NetbootOption{WithIPXEScriptURL(script), WithConsole("ttyS0"), WithKernelParams("quiet")},
+			want: &Netboot{AllowNetboot: true, IPXEScriptURL: script, Console: "ttyS0", KernelParams: "quiet"},
+		},
+		"relative script URL": {opts: []NetbootOption{WithIPXEScriptURL(&url.URL{Path: "auto.ipxe"})}, wantErr: ErrInvalidRecord},
+		"relative OSIE URL":   {opts: []NetbootOption{WithOSIE(OSIE{BaseURL: &url.URL{Path: "/osie"}})}, wantErr: ErrInvalidRecord},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewNetboot(true, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewNetboot() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package data
+
+import "context"
+
+// contextKey is the key of the Packet of a context.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries p, the DHCP message that the backends read with ctx are read for. It
+// lets a backend compute data from the requesting context of the message, such as its relay agent or the interface it
+// was received on.
+func NewContext(ctx context.Context, p Packet) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the Packet of ctx, and false if ctx has none, for example for a read that is not made for a DHCP
+// message.
+func FromContext(ctx context.Context) (Packet, bool) {
+	p, ok := ctx.Value(contextKey{}).(Packet)
+
+	return p, ok
+}
@@ -0,0 +1,20 @@
+package data
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Fatal("FromContext() ok = true, want false for a context without a packet")
+	}
+	p := Packet{Pkt: &dhcpv4.DHCPv4{GatewayIPAddr: net.IP{192, 168, 2, 1}}, Md: &Metadata{IfName: "eth0"}}
+	got, ok := FromContext(NewContext(context.Background(), p))
+	if !ok || got.Pkt != p.Pkt || got.Md != p.Md {
+		t.Fatalf("FromContext() = %+v, %v, want %+v, true", got, ok, p)
+	}
+}
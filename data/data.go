@@ -2,10 +2,12 @@
 package data
 
 import (
+	"fmt"
 	"net"
 	"net/netip"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"go.opentelemetry.io/otel/attribute"
@@ -27,24 +29,65 @@ type Metadata struct {
 	IfName string
 	// IfIndex is the index of the interface that the DHCP message was received on.
 	IfIndex int
+	// LocalIP is the destination IP address of the DHCP message, the address of the server for a unicast or
+	// relayed message and 255.255.255.255 for a broadcast. It is not valid when the platform does not report it.
+	LocalIP netip.Addr
+	// VLANID is the 802.1Q VLAN ID of the frame that the DHCP message was received in.
+	// It is only known for messages received from a raw socket, and is 0 for untagged frames.
+	VLANID uint16
+	// SrcPort is the UDP source port of the DHCP message, 68 for a client and 67 for a relay agent.
+	SrcPort uint16
+	// Received is when the DHCP message was read from the socket.
+	Received time.Time
+	// ReplyToPeer is set when every reply to the DHCP message, including a DHCPNAK that is otherwise broadcast, must be
+	// written to the peer of the packet. A server that sends the replies itself, like a RawServer, reads them from the peer.
+	ReplyToPeer bool
 }
 
 // DHCP holds the DHCP headers and options to be set in a DHCP handler response.
 // This is the API between a DHCP handler and a backend.
 type DHCP struct {
-	MACAddress       net.HardwareAddr // chaddr DHCP header.
-	IPAddress        netip.Addr       // yiaddr DHCP header.
-	SubnetMask       net.IPMask       // DHCP option 1.
-	DefaultGateway   netip.Addr       // DHCP option 3.
-	NameServers      []net.IP         // DHCP option 6.
-	Hostname         string           // DHCP option 12.
-	DomainName       string           // DHCP option 15.
-	BroadcastAddress netip.Addr       // DHCP option 28.
-	NTPServers       []net.IP         // DHCP option 42.
-	VLANID           string           // DHCP option 43.116.
-	LeaseTime        uint32           // DHCP option 51.
-	Arch             string           // DHCP option 93.
-	DomainSearch     []string         // DHCP option 119.
+	MACAddress            net.HardwareAddr // chaddr DHCP header.
+	IPAddress             netip.Addr       // yiaddr DHCP header.
+	SubnetMask            net.IPMask       // DHCP option 1.
+	TimeOffset            int32            // DHCP option 2, seconds east of UTC. Not sent when zero, clients should prefer option 100 or 101.
+	DefaultGateways       []netip.Addr     // DHCP option 3, in order of preference.
+	NameServers           []net.IP         // DHCP option 6.
+	Hostname              string           // DHCP option 12.
+	DomainName            string           // DHCP option 15.
+	MTU                   uint16           // DHCP option 26.
+	BroadcastAddress      netip.Addr       // DHCP option 28.
+	NTPServers            []net.IP         // DHCP option 42.
+	VLANID                string           // DHCP option 43.116.
+	VendorOptions         map[uint8][]byte // DHCP option 43 sub-options, merged with the PXE sub-options of a netboot reply.
+	LeaseTime             uint32           // DHCP option 51.
+	ClientIdentifier      []byte           // DHCP option 61, the identifier type followed by the identifier, as the client sends it.
+	TFTPServerName        string           // DHCP option 66.
+	BootFileName          string           // DHCP option 67.
+	Arch                  string           // DHCP option 93.
+	ClientGUID            []byte           // DHCP option 97, the 16 byte client machine identifier without its type byte (https://www.rfc-editor.org/rfc/rfc4578.html#section-2.3).
+	TZPOSIX               string           // DHCP option 100, a POSIX TZ string, e.g. "CET-1CEST,M3.5.0,M10.5.0/3" (https://www.rfc-editor.org/rfc/rfc4833.html).
+	TZDatabase            string           // DHCP option 101, a tz database name, e.g. "Europe/Amsterdam".
+	CaptivePortalURL      *url.URL         // DHCP option 114 (https://www.rfc-editor.org/rfc/rfc8910.html).
+	DomainSearch          []string         // DHCP option 119.
+	SIPServerNames        []string         // DHCP option 120, domain name encoding (https://www.rfc-editor.org/rfc/rfc3361.html).
+	SIPServerAddresses    []net.IP         // DHCP option 120, IPv4 address encoding. Only one of the encodings can be set.
+	ClasslessStaticRoutes []Route          // DHCP option 121.
+	VIVendorOptions       VIVendorOptions  // DHCP option 125.
+	VLANTag               uint16           // DHCP option 132, the 802.1Q VLAN ID, 1 to 4094, that the client tags its traffic with. Not sent when zero.
+	VLANPriority          uint8            // DHCP option 133, the 802.1p priority, 0 to 7, of the tagged traffic. Only sent with VLANTag.
+	ProxyAutoConfigURL    *url.URL         // DHCP option 252, the WPAD proxy auto-config file.
+	// OtherOptions are DHCP options, by option code, that are set verbatim in a reply.
+	// They are set after all other options, so an option here replaces an option of the same code from the fields above.
+	OtherOptions map[uint8][]byte
+	// DHCPv6, when set, is the DHCPv6 data of a dual-stack client.
+	DHCPv6 *DHCPv6
+}
+
+// Route is a classless static route, DHCP option 121 (https://www.rfc-editor.org/rfc/rfc3442.html).
+type Route struct {
+	Destination netip.Prefix // The destination network.
+	Router      netip.Addr   // The router for the destination network.
 }
 
 // Netboot holds info used in netbooting a client.
@@ -52,8 +95,37 @@ type Netboot struct {
 	AllowNetboot  bool     // If true, the client will be provided netboot options in the DHCP offer/ack.
 	IPXEScriptURL *url.URL // Overrides a default value that is passed into DHCP on startup.
 	IPXEScript    string   // Overrides a default value that is passed into DHCP on startup.
-	Console       string
-	Facility      string
+	Console       string   // The serial console of the client, e.g. "ttyS1,115200".
+	Facility      string   // The facility code of the client.
+	KernelParams  string   // Extra kernel command line parameters.
+	IPXEBinary    string   // Overrides the iPXE binary that is chosen based on the client architecture.
+	HTTPBootURI   *url.URL // Booted directly by UEFI HTTP Boot clients instead of iPXE, e.g. a signed UKI.
+	OSIE          OSIE
+}
+
+// OSIE holds the location of the Operating System Installation Environment that a client boots into.
+type OSIE struct {
+	BaseURL *url.URL // The URL that Kernel and Initrd are relative to.
+	Kernel  string   // The kernel file name.
+	Initrd  string   // The initrd file name.
+}
+
+// DefaultGateway returns the first, most preferred, default gateway or the zero netip.Addr when there is none.
+func (d *DHCP) DefaultGateway() netip.Addr {
+	if len(d.DefaultGateways) == 0 {
+		return netip.Addr{}
+	}
+
+	return d.DefaultGateways[0]
+}
+
+// SetDefaultGateway sets gw as the only default gateway. The default gateways are cleared when gw is not valid.
+func (d *DHCP) SetDefaultGateway(gw netip.Addr) {
+	if !gw.IsValid() {
+		d.DefaultGateways = nil
+		return
+	}
+	d.DefaultGateways = []netip.Addr{gw}
 }
 
 // EncodeToAttributes returns a slice of opentelemetry attributes that can be used to set span.SetAttributes.
@@ -78,9 +150,9 @@ func (d *DHCP) EncodeToAttributes() []attribute.KeyValue {
 		sm = net.IP(d.SubnetMask).String()
 	}
 
-	var dfg string
-	if d.DefaultGateway.Compare(netip.Addr{}) != 0 {
-		dfg = d.DefaultGateway.String()
+	var dfg []string
+	for _, e := range d.DefaultGateways {
+		dfg = append(dfg, e.String())
 	}
 
 	var ba string
@@ -88,11 +160,11 @@ func (d *DHCP) EncodeToAttributes() []attribute.KeyValue {
 		ba = d.BroadcastAddress.String()
 	}
 
-	return []attribute.KeyValue{
+	attrs := []attribute.KeyValue{
 		attribute.String("DHCP.MACAddress", d.MACAddress.String()),
 		attribute.String("DHCP.IPAddress", ip),
 		attribute.String("DHCP.SubnetMask", sm),
-		attribute.String("DHCP.DefaultGateway", dfg),
+		attribute.String("DHCP.DefaultGateway", strings.Join(dfg, ",")),
 		attribute.String("DHCP.NameServers", strings.Join(ns, ",")),
 		attribute.String("DHCP.Hostname", d.Hostname),
 		attribute.String("DHCP.DomainName", d.DomainName),
@@ -100,7 +172,69 @@ func (d *DHCP) EncodeToAttributes() []attribute.KeyValue {
 		attribute.String("DHCP.NTPServers", strings.Join(ntp, ",")),
 		attribute.Int64("DHCP.LeaseTime", int64(d.LeaseTime)),
 		attribute.String("DHCP.DomainSearch", strings.Join(d.DomainSearch, ",")),
+		attribute.String("DHCP.ClientIdentifier", hexString(d.ClientIdentifier)),
+		attribute.String("DHCP.ClientGUID", hexString(d.ClientGUID)),
+	}
+	if d.DHCPv6 != nil {
+		attrs = append(attrs, d.DHCPv6.EncodeToAttributes()...)
+	}
+
+	return attrs
+}
+
+// hexString returns b as colon separated hex, like a MAC address.
+func hexString(b []byte) string {
+	h := make([]string, 0, len(b))
+	for _, v := range b {
+		h = append(h, fmt.Sprintf("%02x", v))
 	}
+
+	return strings.Join(h, ":")
+}
+
+// Cmdline returns the kernel command line for directly booting the OSIE: the console and facility, when set,
+// followed by KernelParams.
+func (n *Netboot) Cmdline() string {
+	var c []string
+	if n.Console != "" {
+		c = append(c, "console="+n.Console)
+	}
+	if n.Facility != "" {
+		c = append(c, "facility="+n.Facility)
+	}
+	if n.KernelParams != "" {
+		c = append(c, n.KernelParams)
+	}
+
+	return strings.Join(c, " ")
+}
+
+// KernelURL returns the URL of the kernel, Kernel relative to BaseURL. An absolute Kernel URL is returned as is.
+// It is nil when Kernel is not set or is not a valid URL.
+func (o OSIE) KernelURL() *url.URL {
+	return o.resolve(o.Kernel)
+}
+
+// InitrdURL returns the URL of the initrd, Initrd relative to BaseURL. An absolute Initrd URL is returned as is.
+// It is nil when Initrd is not set or is not a valid URL.
+func (o OSIE) InitrdURL() *url.URL {
+	return o.resolve(o.Initrd)
+}
+
+// resolve returns the URL of the file name f.
+func (o OSIE) resolve(f string) *url.URL {
+	if f == "" {
+		return nil
+	}
+	u, err := url.Parse(f)
+	if err != nil {
+		return nil
+	}
+	if u.IsAbs() || o.BaseURL == nil {
+		return u
+	}
+
+	return o.BaseURL.JoinPath(f)
 }
 
 // EncodeToAttributes returns a slice of opentelemetry attributes that can be used to set span.SetAttributes.
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"go.opentelemetry.io/otel/attribute"
 )
 
@@ -29,6 +30,8 @@ func TestDHCPEncodeToAttributes(t *testing.T) {
 				attribute.String("DHCP.NTPServers", ""),
 				attribute.Int64("DHCP.LeaseTime", 0),
 				attribute.String("DHCP.DomainSearch", ""),
+				attribute.String("DHCP.ClientIdentifier", ""),
+				attribute.String("DHCP.ClientGUID", ""),
 			},
 		},
 		"successful encode of populated DHCP struct": {
@@ -36,7 +39,7 @@ func TestDHCPEncodeToAttributes(t *testing.T) {
 				MACAddress:       []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
 				IPAddress:        netip.MustParseAddr("192.168.2.150"),
 				SubnetMask:       []byte{255, 255, 255, 0},
-				DefaultGateway:   netip.MustParseAddr("192.168.2.1"),
+				DefaultGateways:  []netip.Addr{netip.MustParseAddr("192.168.2.1"), netip.MustParseAddr("192.168.2.2")},
 				NameServers:      []net.IP{{1, 1, 1, 1}, {8, 8, 8, 8}},
 				Hostname:         "test",
 				DomainName:       "example.com",
@@ -44,19 +47,23 @@ func TestDHCPEncodeToAttributes(t *testing.T) {
 				NTPServers:       []net.IP{{132, 163, 96, 2}},
 				LeaseTime:        86400,
 				DomainSearch:     []string{"example.com", "example.org"},
+				ClientIdentifier: []byte{0x01, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+				ClientGUID:       []byte{0x4c, 0x4c, 0x45, 0x44, 0x00, 0x51, 0x10, 0x80, 0x80, 0x4a, 0xb4, 0xc0, 0x4f, 0x4e, 0x38, 0x32},
 			},
 			want: []attribute.KeyValue{
 				attribute.String("DHCP.MACAddress", "00:01:02:03:04:05"),
 				attribute.String("DHCP.IPAddress", "192.168.2.150"),
 				attribute.String("DHCP.Hostname", "test"),
 				attribute.String("DHCP.SubnetMask", "255.255.255.0"),
-				attribute.String("DHCP.DefaultGateway", "192.168.2.1"),
+				attribute.String("DHCP.DefaultGateway", "192.168.2.1,192.168.2.2"),
 				attribute.String("DHCP.NameServers", "1.1.1.1,8.8.8.8"),
 				attribute.String("DHCP.DomainName", "example.com"),
 				attribute.String("DHCP.BroadcastAddress", "192.168.2.255"),
 				attribute.String("DHCP.NTPServers", "132.163.96.2"),
 				attribute.Int64("DHCP.LeaseTime", 86400),
 				attribute.String("DHCP.DomainSearch", "example.com,example.org"),
+				attribute.String("DHCP.ClientIdentifier", "01:00:01:02:03:04:05"),
+				attribute.String("DHCP.ClientGUID", "4c:4c:45:44:00:51:10:80:80:4a:b4:c0:4f:4e:38:32"),
 			},
 		},
 	}
@@ -106,3 +113,81 @@ func TestNetbootEncodeToAttributes(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultGateway(t *testing.T) {
+	gw := netip.MustParseAddr("192.168.2.1")
+	d := &DHCP{}
+	if got := d.DefaultGateway(); got.IsValid() {
+		t.Fatalf("DefaultGateway() = %v, want the zero value", got)
+	}
+	d.DefaultGateways = []netip.Addr{netip.MustParseAddr("192.168.2.2"), gw}
+	d.SetDefaultGateway(gw)
+	if diff := cmp.Diff([]netip.Addr{gw}, d.DefaultGateways, cmpopts.EquateComparable(netip.Addr{})); diff != "" {
+		t.Fatal(diff)
+	}
+	if got := d.DefaultGateway(); got != gw {
+		t.Fatalf("DefaultGateway() = %v, want %v", got, gw)
+	}
+	d.SetDefaultGateway(netip.Addr{})
+	if d.DefaultGateways != nil {
+		t.Fatalf("DefaultGateways = %v, want nil", d.DefaultGateways)
+	}
+}
+
+func TestNetbootCmdline(t *testing.T) {
+	tests := map[string]struct {
+		netboot *Netboot
+		want    string
+	}{
+		"empty":         {netboot: &Netboot{}, want: ""},
+		"kernel params": {netboot: &Netboot{KernelParams: "quiet"}, want: "quiet"},
+		"all": {
+			netboot: &Netboot{Console: "ttyS1,115200", Facility: "onprem", KernelParams: "quiet"},
+			want:    "console=ttyS1,115200 facility=onprem quiet",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.netboot.Cmdline(); got != tt.want {
+				t.Fatalf("Cmdline() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOSIEURLs(t *testing.T) {
+	tests := map[string]struct {
+		osie       OSIE
+		wantKernel string
+		wantInitrd string
+	}{
+		"not set": {},
+		"relative to base url": {
+			osie:       OSIE{BaseURL: &url.URL{Scheme: "http", Host: "192.168.2.1:8080", Path: "/osie"}, Kernel: "vmlinuz-x86_64", Initrd: "initramfs-x86_64"},
+			wantKernel: "http://192.168.2.1:8080/osie/vmlinuz-x86_64",
+			wantInitrd: "http://192.168.2.1:8080/osie/initramfs-x86_64",
+		},
+		"absolute": {
+			osie:       OSIE{BaseURL: &url.URL{Scheme: "http", Host: "192.168.2.1:8080"}, Kernel: "https://example.com/vmlinuz"},
+			wantKernel: "https://example.com/vmlinuz",
+		},
+		"no base url": {osie: OSIE{Kernel: "vmlinuz"}, wantKernel: "vmlinuz"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotKernel, gotInitrd string
+			if u := tt.osie.KernelURL(); u != nil {
+				gotKernel = u.String()
+			}
+			if u := tt.osie.InitrdURL(); u != nil {
+				gotInitrd = u.String()
+			}
+			if gotKernel != tt.wantKernel {
+				t.Errorf("KernelURL() = %q, want %q", gotKernel, tt.wantKernel)
+			}
+			if gotInitrd != tt.wantInitrd {
+				t.Errorf("InitrdURL() = %q, want %q", gotInitrd, tt.wantInitrd)
+			}
+		})
+	}
+}
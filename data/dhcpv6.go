@@ -0,0 +1,55 @@
+package data
+
+import (
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DHCPv6 holds the DHCPv6 data of a client (https://www.rfc-editor.org/rfc/rfc8415.html), so that a backend
+// can return a dual-stack record. It is set in DHCP.DHCPv6 and is not used by the DHCPv4 handler.
+type DHCPv6 struct {
+	DUID              []byte         // DHCPv6 option 1, the DUID of the client, including its 2 byte type.
+	Addresses         []netip.Addr   // DHCPv6 option 5, the non-temporary addresses of the client in option 3.
+	Prefixes          []netip.Prefix // DHCPv6 option 26, the prefixes delegated to the client in option 25.
+	PreferredLifetime uint32         // The preferred lifetime of the addresses and prefixes, in seconds.
+	ValidLifetime     uint32         // The valid lifetime of the addresses and prefixes, in seconds.
+	NameServers       []netip.Addr   // DHCPv6 option 23 (https://www.rfc-editor.org/rfc/rfc3646.html).
+	DomainSearch      []string       // DHCPv6 option 24.
+	NTPServers        []netip.Addr   // DHCPv6 option 56 (https://www.rfc-editor.org/rfc/rfc5908.html).
+	BootFileURL       *url.URL       // DHCPv6 option 59 (https://www.rfc-editor.org/rfc/rfc5970.html).
+	BootFileParams    []string       // DHCPv6 option 60.
+}
+
+// EncodeToAttributes returns a slice of opentelemetry attributes that can be used to set span.SetAttributes.
+func (d *DHCPv6) EncodeToAttributes() []attribute.KeyValue {
+	var bf string
+	if d.BootFileURL != nil {
+		bf = d.BootFileURL.String()
+	}
+
+	return []attribute.KeyValue{
+		attribute.String("DHCPv6.DUID", hexString(d.DUID)),
+		attribute.String("DHCPv6.Addresses", joinStrings(d.Addresses)),
+		attribute.String("DHCPv6.Prefixes", joinStrings(d.Prefixes)),
+		attribute.Int64("DHCPv6.PreferredLifetime", int64(d.PreferredLifetime)),
+		attribute.Int64("DHCPv6.ValidLifetime", int64(d.ValidLifetime)),
+		attribute.String("DHCPv6.NameServers", joinStrings(d.NameServers)),
+		attribute.String("DHCPv6.DomainSearch", strings.Join(d.DomainSearch, ",")),
+		attribute.String("DHCPv6.NTPServers", joinStrings(d.NTPServers)),
+		attribute.String("DHCPv6.BootFileURL", bf),
+		attribute.String("DHCPv6.BootFileParams", strings.Join(d.BootFileParams, ",")),
+	}
+}
+
+// joinStrings returns the string forms of v joined with commas.
+func joinStrings[T interface{ String() string }](v []T) string {
+	s := make([]string, 0, len(v))
+	for _, e := range v {
+		s = append(s, e.String())
+	}
+
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,77 @@
+package data
+
+import (
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestDHCPv6EncodeToAttributes(t *testing.T) {
+	tests := map[string]struct {
+		dhcp *DHCPv6
+		want []attribute.KeyValue
+	}{
+		"zero value": {
+			dhcp: &DHCPv6{},
+			want: []attribute.KeyValue{
+				attribute.String("DHCPv6.DUID", ""),
+				attribute.String("DHCPv6.Addresses", ""),
+				attribute.String("DHCPv6.Prefixes", ""),
+				attribute.Int64("DHCPv6.PreferredLifetime", 0),
+				attribute.Int64("DHCPv6.ValidLifetime", 0),
+				attribute.String("DHCPv6.NameServers", ""),
+				attribute.String("DHCPv6.DomainSearch", ""),
+				attribute.String("DHCPv6.NTPServers", ""),
+				attribute.String("DHCPv6.BootFileURL", ""),
+				attribute.String("DHCPv6.BootFileParams", ""),
+			},
+		},
+		"populated": {
+			dhcp: &DHCPv6{
+				DUID:              []byte{0x00, 0x03, 0x00, 0x01, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+				Addresses:         []netip.Addr{netip.MustParseAddr("2001:db8::150")},
+				Prefixes:          []netip.Prefix{netip.MustParsePrefix("2001:db8:1::/56")},
+				PreferredLifetime: 3600,
+				ValidLifetime:     7200,
+				NameServers:       []netip.Addr{netip.MustParseAddr("2001:4860:4860::8888"), netip.MustParseAddr("2001:4860:4860::8844")},
+				DomainSearch:      []string{"example.com"},
+				NTPServers:        []netip.Addr{netip.MustParseAddr("2001:db8::123")},
+				BootFileURL:       &url.URL{Scheme: "http", Host: "[2001:db8::5]", Path: "/snp.efi"},
+				BootFileParams:    []string{"console=ttyS0"},
+			},
+			want: []attribute.KeyValue{
+				attribute.String("DHCPv6.DUID", "00:03:00:01:00:01:02:03:04:05"),
+				attribute.String("DHCPv6.Addresses", "2001:db8::150"),
+				attribute.String("DHCPv6.Prefixes", "2001:db8:1::/56"),
+				attribute.Int64("DHCPv6.PreferredLifetime", 3600),
+				attribute.Int64("DHCPv6.ValidLifetime", 7200),
+				attribute.String("DHCPv6.NameServers", "2001:4860:4860::8888,2001:4860:4860::8844"),
+				attribute.String("DHCPv6.DomainSearch", "example.com"),
+				attribute.String("DHCPv6.NTPServers", "2001:db8::123"),
+				attribute.String("DHCPv6.BootFileURL", "http://[2001:db8::5]/snp.efi"),
+				attribute.String("DHCPv6.BootFileParams", "console=ttyS0"),
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			want := attribute.NewSet(tt.want...)
+			got := attribute.NewSet(tt.dhcp.EncodeToAttributes()...)
+			enc := attribute.DefaultEncoder()
+			if diff := cmp.Diff(got.Encoded(enc), want.Encoded(enc)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestDHCPEncodeToAttributesDualStack(t *testing.T) {
+	d := &DHCP{DHCPv6: &DHCPv6{Addresses: []netip.Addr{netip.MustParseAddr("2001:db8::150")}}}
+	got := attribute.NewSet(d.EncodeToAttributes()...)
+	if v, ok := got.Value("DHCPv6.Addresses"); !ok || v.AsString() != "2001:db8::150" {
+		t.Fatalf("DHCPv6.Addresses = %q, %v, want %q", v.AsString(), ok, "2001:db8::150")
+	}
+}
@@ -0,0 +1,63 @@
+package data
+
+import "strings"
+
+// NormalizeDomain returns the domain name s in lower case, without surrounding white space and a trailing dot.
+// Domain names are case insensitive, and a trailing dot, which marks a name as fully qualified, is not sent
+// in DHCP options 15 and 119.
+func NormalizeDomain(s string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), "."))
+}
+
+// SplitFQDN splits the fully qualified domain name fqdn, e.g. "server1.example.com.", into the host name "server1"
+// and the normalized domain name "example.com". The domain name is empty when fqdn has no dot.
+func SplitFQDN(fqdn string) (host, domain string) {
+	host, domain, _ = strings.Cut(strings.TrimSuffix(strings.TrimSpace(fqdn), "."), ".")
+
+	return host, NormalizeDomain(domain)
+}
+
+// Domain returns the domain name that is sent in DHCP option 15: DomainName, or the first search domain when
+// DomainName is not set, which is what most inventory systems store. The domain name is normalized.
+func (d *DHCP) Domain() string {
+	if dn := NormalizeDomain(d.DomainName); dn != "" {
+		return dn
+	}
+	if s := d.SearchDomains(); len(s) > 0 {
+		return s[0]
+	}
+
+	return ""
+}
+
+// SearchDomains returns the domain search list that is sent in DHCP option 119: DomainSearch normalized,
+// without empty and duplicate domains.
+func (d *DHCP) SearchDomains() []string {
+	var r []string
+	seen := make(map[string]bool, len(d.DomainSearch))
+	for _, s := range d.DomainSearch {
+		s = NormalizeDomain(s)
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		r = append(r, s)
+	}
+
+	return r
+}
+
+// FQDN returns the fully qualified domain name of the client, Hostname in the domain returned by Domain.
+// Hostname is returned without a trailing dot when it already has a domain or there is no domain.
+func (d *DHCP) FQDN() string {
+	host := strings.TrimSuffix(strings.TrimSpace(d.Hostname), ".")
+	if host == "" {
+		return ""
+	}
+	dn := d.Domain()
+	if strings.Contains(host, ".") || dn == "" {
+		return host
+	}
+
+	return host + "." + dn
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitFQDN(t *testing.T) {
+	tests := map[string]struct {
+		fqdn       string
+		wantHost   string
+		wantDomain string
+	}{
+		"fqdn":          {fqdn: "server1.example.com", wantHost: "server1", wantDomain: "example.com"},
+		"trailing dot":  {fqdn: "server1.Example.COM.", wantHost: "server1", wantDomain: "example.com"},
+		"host only":     {fqdn: "server1", wantHost: "server1"},
+		"host case":     {fqdn: "Server1.example.com", wantHost: "Server1", wantDomain: "example.com"},
+		"empty":         {},
+		"white space":   {fqdn: " server1.example.com\n", wantHost: "server1", wantDomain: "example.com"},
+		"subdomain":     {fqdn: "server1.rack1.example.com", wantHost: "server1", wantDomain: "rack1.example.com"},
+		"root dot only": {fqdn: ".", wantHost: ""},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			host, domain := SplitFQDN(tt.fqdn)
+			if host != tt.wantHost || domain != tt.wantDomain {
+				t.Fatalf("SplitFQDN() = %q, %q, want %q, %q", host, domain, tt.wantHost, tt.wantDomain)
+			}
+		})
+	}
+}
+
+func TestDomain(t *testing.T) {
+	tests := map[string]struct {
+		d          *DHCP
+		wantDomain string
+		wantSearch []string
+		wantFQDN   string
+	}{
+		"empty": {d: &DHCP{}},
+		"domain name": {
+			d:          &DHCP{Hostname: "server1", DomainName: "Example.com.", DomainSearch: []string{"example.org"}},
+			wantDomain: "example.com",
+			wantSearch: []string{"example.org"},
+			wantFQDN:   "server1.example.com",
+		},
+		"search list only": {
+			d:          &DHCP{Hostname: "server1", DomainSearch: []string{"", "Example.org.", "example.org", "example.net"}},
+			wantDomain: "example.org",
+			wantSearch: []string{"example.org", "example.net"},
+			wantFQDN:   "server1.example.org",
+		},
+		"qualified hostname": {
+			d:          &DHCP{Hostname: "server1.example.net.", DomainName: "example.com"},
+			wantDomain: "example.com",
+			wantFQDN:   "server1.example.net",
+		},
+		"no domain": {d: &DHCP{Hostname: "server1"}, wantFQDN: "server1"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.d.Domain(); got != tt.wantDomain {
+				t.Errorf("Domain() = %q, want %q", got, tt.wantDomain)
+			}
+			if diff := cmp.Diff(tt.wantSearch, tt.d.SearchDomains()); diff != "" {
+				t.Errorf("SearchDomains() %v", diff)
+			}
+			if got := tt.d.FQDN(); got != tt.wantFQDN {
+				t.Errorf("FQDN() = %q, want %q", got, tt.wantFQDN)
+			}
+		})
+	}
+}
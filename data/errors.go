@@ -0,0 +1,56 @@
+package data
+
+import "errors"
+
+// Errors that classify why a backend read failed. Backends wrap them, for example with fmt.Errorf("...: %w", data.ErrUnavailable),
+// or return an error whose Is method matches them, so that handlers can decide what to do without knowing the backend.
+var (
+	// ErrNotFound is returned when the backend has no record for the MAC or IP address.
+	ErrNotFound = errors.New("not found")
+	// ErrUnauthorized is returned when the backend rejects the credentials of the DHCP server.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrUnavailable is returned when the backend can not be reached or can not serve reads at the moment.
+	// Reads that fail with it may succeed when they are retried.
+	ErrUnavailable = errors.New("backend unavailable")
+	// ErrInvalidRecord is returned when the backend has a record for the MAC or IP address that is not valid.
+	ErrInvalidRecord = errors.New("invalid record")
+)
+
+// IsNotFound returns true if err is, or wraps, ErrNotFound.
+// Errors that implement `NotFound() bool`, the not found contract before ErrNotFound, are also recognized.
+func IsNotFound(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return true
+	}
+	var nf interface{ NotFound() bool }
+
+	return errors.As(err, &nf) && nf.NotFound()
+}
+
+// IsUnauthorized returns true if err is, or wraps, ErrUnauthorized.
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// IsUnavailable returns true if err is, or wraps, ErrUnavailable.
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrUnavailable)
+}
+
+// IsInvalidRecord returns true if err is, or wraps, ErrInvalidRecord.
+func IsInvalidRecord(err error) bool {
+	return errors.Is(err, ErrInvalidRecord)
+}
+
+// HardwareNotFoundError is the error the bundled backends return when they have no record for the MAC or IP address.
+// It matches ErrNotFound, and implements `NotFound() bool` for callers that still use the not found contract before
+// ErrNotFound.
+type HardwareNotFoundError struct{}
+
+// NotFound returns true.
+func (HardwareNotFoundError) NotFound() bool { return true }
+
+func (HardwareNotFoundError) Error() string { return "hardware not found" }
+
+// Is makes HardwareNotFoundError match ErrNotFound.
+func (HardwareNotFoundError) Is(target error) bool { return target == ErrNotFound }
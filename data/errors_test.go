@@ -0,0 +1,50 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type legacyNotFound struct{}
+
+func (legacyNotFound) NotFound() bool { return true }
+func (legacyNotFound) Error() string  { return "hardware not found" }
+
+func TestErrorPredicates(t *testing.T) {
+	tests := map[string]struct {
+		err              error
+		wantNotFound     bool
+		wantUnauthorized bool
+		wantUnavailable  bool
+		wantInvalid      bool
+	}{
+		"nil":                 {},
+		"other":               {err: errors.New("other")},
+		"not found":           {err: ErrNotFound, wantNotFound: true},
+		"wrapped not found":   {err: fmt.Errorf("record %w", ErrNotFound), wantNotFound: true},
+		"legacy not found":    {err: legacyNotFound{}, wantNotFound: true},
+		"wrapped legacy":      {err: fmt.Errorf("lookup: %w", legacyNotFound{}), wantNotFound: true},
+		"hardware not found":  {err: HardwareNotFoundError{}, wantNotFound: true},
+		"unauthorized":        {err: fmt.Errorf("%w: status 401", ErrUnauthorized), wantUnauthorized: true},
+		"unavailable":         {err: fmt.Errorf("%w: connection refused", ErrUnavailable), wantUnavailable: true},
+		"invalid record":      {err: fmt.Errorf("%w: bad netmask", ErrInvalidRecord), wantInvalid: true},
+		"joined invalid read": {err: errors.Join(ErrUnavailable, ErrInvalidRecord), wantUnavailable: true, wantInvalid: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := IsNotFound(tt.err); got != tt.wantNotFound {
+				t.Errorf("IsNotFound() = %v, want %v", got, tt.wantNotFound)
+			}
+			if got := IsUnauthorized(tt.err); got != tt.wantUnauthorized {
+				t.Errorf("IsUnauthorized() = %v, want %v", got, tt.wantUnauthorized)
+			}
+			if got := IsUnavailable(tt.err); got != tt.wantUnavailable {
+				t.Errorf("IsUnavailable() = %v, want %v", got, tt.wantUnavailable)
+			}
+			if got := IsInvalidRecord(tt.err); got != tt.wantInvalid {
+				t.Errorf("IsInvalidRecord() = %v, want %v", got, tt.wantInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package data
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// errVIVendorOptions is returned when DHCP option 125 can not be parsed.
+var errVIVendorOptions = errors.New("invalid vendor-identifying vendor options")
+
+// VIVendorOptions are the vendor-identifying vendor-specific options, DHCP option 125 (https://www.rfc-editor.org/rfc/rfc3925.html).
+// They are keyed by IANA enterprise number, and the sub-options of each enterprise are keyed by sub-option code.
+type VIVendorOptions map[uint32]map[uint8][]byte
+
+// ToBytes returns the DHCP option 125 encoding of v, with enterprises in ascending order.
+// The sub-options of an enterprise must encode to at most 255 bytes.
+func (v VIVendorOptions) ToBytes() []byte {
+	ents := make([]uint32, 0, len(v))
+	for e := range v {
+		ents = append(ents, e)
+	}
+	slices.Sort(ents)
+
+	var b []byte
+	for _, e := range ents {
+		sub := dhcpv4.Options(v[e]).ToBytes()
+		b = binary.BigEndian.AppendUint32(b, e)
+		b = append(b, uint8(len(sub)))
+		b = append(b, sub...)
+	}
+
+	return b
+}
+
+// ParseVIVendorOptions parses the value of DHCP option 125, for example from a client request.
+func ParseVIVendorOptions(b []byte) (VIVendorOptions, error) {
+	v := VIVendorOptions{}
+	for len(b) > 0 {
+		if len(b) < 5 {
+			return nil, fmt.Errorf("%w: %d bytes left, want at least 5", errVIVendorOptions, len(b))
+		}
+		e := binary.BigEndian.Uint32(b)
+		n := int(b[4])
+		b = b[5:]
+		if len(b) < n {
+			return nil, fmt.Errorf("%w: enterprise %d has %d bytes of data, want %d", errVIVendorOptions, e, len(b), n)
+		}
+		sub := dhcpv4.Options{}
+		if err := sub.FromBytes(b[:n]); err != nil {
+			return nil, fmt.Errorf("%w: enterprise %d: %w", errVIVendorOptions, e, err)
+		}
+		// an enterprise can be listed more than once, its sub-options are combined.
+		if v[e] == nil {
+			v[e] = map[uint8][]byte{}
+		}
+		for c, d := range sub {
+			v[e][c] = append(v[e][c], d...)
+		}
+		b = b[n:]
+	}
+
+	return v, nil
+}
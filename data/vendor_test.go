@@ -0,0 +1,58 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVIVendorOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts VIVendorOptions
+		want []byte
+	}{
+		"empty": {opts: VIVendorOptions{}},
+		"one enterprise": {
+			opts: VIVendorOptions{3561: {1: []byte("abc")}},
+			want: []byte{0x00, 0x00, 0x0d, 0xe9, 5, 1, 3, 'a', 'b', 'c'},
+		},
+		"enterprises in order": {
+			opts: VIVendorOptions{4491: {2: {0x01}}, 3561: {1: {0x02}, 3: {0x03, 0x04}}},
+			want: []byte{
+				0x00, 0x00, 0x0d, 0xe9, 7, 1, 1, 0x02, 3, 2, 0x03, 0x04,
+				0x00, 0x00, 0x11, 0x8b, 3, 2, 1, 0x01,
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := tt.opts.ToBytes()
+			if diff := cmp.Diff(tt.want, b); diff != "" {
+				t.Fatal(diff)
+			}
+			got, err := ParseVIVendorOptions(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.opts, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestParseVIVendorOptionsError(t *testing.T) {
+	tests := map[string][]byte{
+		"short header":     {0x00, 0x00, 0x0d},
+		"short data":       {0x00, 0x00, 0x0d, 0xe9, 5, 1, 3, 'a'},
+		"short sub-option": {0x00, 0x00, 0x0d, 0xe9, 3, 1, 3, 'a'},
+	}
+	for name, b := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseVIVendorOptions(b); !errors.Is(err, errVIVendorOptions) {
+				t.Fatalf("ParseVIVendorOptions() error = %v, want %v", err, errVIVendorOptions)
+			}
+		})
+	}
+}
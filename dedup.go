@@ -0,0 +1,63 @@
+package dhcp
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// dedupKey identifies the retransmissions of a message.
+type dedupKey struct {
+	xid dhcpv4.TransactionID
+	mac string
+	mt  dhcpv4.MessageType
+}
+
+// dedup suppresses the messages that were already received within window.
+// A nil dedup suppresses no messages.
+type dedup struct {
+	window     time.Duration
+	suppressed *atomic.Uint64
+
+	mu    sync.Mutex // protects the fields below
+	seen  map[dedupKey]time.Time
+	swept time.Time
+}
+
+// newDedup returns a dedup that counts suppressed messages in suppressed. It returns nil when window is not positive.
+func newDedup(window time.Duration, suppressed *atomic.Uint64) *dedup {
+	if window <= 0 {
+		return nil
+	}
+
+	return &dedup{window: window, suppressed: suppressed, seen: make(map[dedupKey]time.Time)}
+}
+
+// duplicate returns true if a message with the transaction ID, client hardware address, and message type of m
+// was received within the window before now. The window starts at the first message, retransmissions do not extend it.
+func (d *dedup) duplicate(m *dhcpv4.DHCPv4, now time.Time) bool {
+	if d == nil {
+		return false
+	}
+	k := dedupKey{xid: m.TransactionID, mac: string(m.ClientHWAddr), mt: m.MessageType()}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if now.Sub(d.swept) > d.window {
+		for k, t := range d.seen {
+			if now.Sub(t) > d.window {
+				delete(d.seen, k)
+			}
+		}
+		d.swept = now
+	}
+	if t, ok := d.seen[k]; ok && now.Sub(t) <= d.window {
+		d.suppressed.Add(1)
+		return true
+	}
+	d.seen[k] = now
+
+	return false
+}
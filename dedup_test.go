@@ -0,0 +1,106 @@
+package dhcp
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+func TestDedup(t *testing.T) {
+	msg := func(xid byte, mac byte, mt dhcpv4.MessageType) *dhcpv4.DHCPv4 {
+		m, err := dhcpv4.New(
+			dhcpv4.WithTransactionID(dhcpv4.TransactionID{0, 0, 0, xid}),
+			dhcpv4.WithHwAddr(net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, mac}),
+			dhcpv4.WithMessageType(mt),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	start := time.Now()
+	type recv struct {
+		m     *dhcpv4.DHCPv4
+		after time.Duration
+		want  bool
+	}
+	tests := map[string][]recv{
+		"retransmission": {
+			{m: msg(1, 1, dhcpv4.MessageTypeDiscover)},
+			{m: msg(1, 1, dhcpv4.MessageTypeDiscover), after: 100 * time.Millisecond, want: true},
+		},
+		"after the window": {
+			{m: msg(1, 1, dhcpv4.MessageTypeDiscover)},
+			{m: msg(1, 1, dhcpv4.MessageTypeDiscover), after: 2 * time.Second},
+		},
+		"retransmissions do not extend the window": {
+			{m: msg(1, 1, dhcpv4.MessageTypeDiscover)},
+			{m: msg(1, 1, dhcpv4.MessageTypeDiscover), after: 900 * time.Millisecond, want: true},
+			{m: msg(1, 1, dhcpv4.MessageTypeDiscover), after: 1100 * time.Millisecond},
+		},
+		"other transaction": {
+			{m: msg(1, 1, dhcpv4.MessageTypeDiscover)},
+			{m: msg(2, 1, dhcpv4.MessageTypeDiscover)},
+		},
+		"other client": {
+			{m: msg(1, 1, dhcpv4.MessageTypeDiscover)},
+			{m: msg(1, 2, dhcpv4.MessageTypeDiscover)},
+		},
+		"other message type": {
+			{m: msg(1, 1, dhcpv4.MessageTypeDiscover)},
+			{m: msg(1, 1, dhcpv4.MessageTypeRequest)},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var suppressed atomic.Uint64
+			d := newDedup(time.Second, &suppressed)
+			var want uint64
+			for i, r := range tt {
+				if got := d.duplicate(r.m, start.Add(r.after)); got != r.want {
+					t.Fatalf("message %d: duplicate() = %v, want %v", i, got, r.want)
+				}
+				if r.want {
+					want++
+				}
+			}
+			if got := suppressed.Load(); got != want {
+				t.Fatalf("got %d suppressed, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestDedupSweep(t *testing.T) {
+	d := newDedup(time.Second, &atomic.Uint64{})
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		m, err := dhcpv4.New(dhcpv4.WithTransactionID(dhcpv4.TransactionID{0, 0, 0, byte(i)}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.duplicate(m, start)
+	}
+	m, err := dhcpv4.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.duplicate(m, start.Add(2*time.Second))
+	if got := len(d.seen); got != 1 {
+		t.Fatalf("got %d entries, want the expired entries to be removed", got)
+	}
+}
+
+func TestNilDedup(t *testing.T) {
+	d := newDedup(0, nil)
+	m, err := dhcpv4.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.duplicate(m, time.Now()) || d.duplicate(m, time.Now()) {
+		t.Fatal("duplicate() = true, want false without a window")
+	}
+}
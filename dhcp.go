@@ -3,13 +3,51 @@ package dhcp
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/insomniacslk/dhcp/dhcpv4"
-	"github.com/insomniacslk/dhcp/dhcpv4/server4"
 	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler"
+	"github.com/tinkerbell/dhcp/metrics"
+	"github.com/tinkerbell/dhcp/redact"
 	"golang.org/x/net/ipv4"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+)
+
+// DefaultMaxMessageSize is the default largest message that is read, in bytes. The largest UDP payload that fits in an
+// Ethernet frame is 1472 bytes, DHCP messages are rarely larger than the 576 bytes that every client must accept.
+const DefaultMaxMessageSize = 1500
+
+// maxUDPPayload is the largest payload of a UDP datagram over IPv4, and so the largest message that can be received.
+const maxUDPPayload = 65507
+
+// PXEPort is the UDP port of the PXE boot server service. PXE clients that receive a ProxyDHCP offer, one with the
+// boot options but without an IP address, send a DHCPREQUEST for their boot file to the offering server on this port.
+const PXEPort = 4011
+
+// bufPool holds the buffers that messages are read into, as *[]byte, so a busy server does not allocate one per message.
+var bufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, DefaultMaxMessageSize+1)
+		return &b
+	},
+}
+
+var (
+	// errMalformed is returned, wrapping the parse error, for a received message that is not a valid DHCPv4 message.
+	errMalformed = errors.New("malformed DHCPv4 message")
+	// errOversized is returned for a received message that is larger than the maximum message size.
+	errOversized = errors.New("DHCPv4 message is larger than the maximum message size")
+	// errNotRequest is returned for a received message that is not a BOOTREQUEST, for example the reply of another server.
+	errNotRequest = errors.New("DHCPv4 message is not a BOOTREQUEST")
 )
 
 // Handler is a type that defines the handler function to be called every time a
@@ -24,30 +62,203 @@ type Server struct {
 	Conn     net.PacketConn
 	Handlers []Handler
 	Logger   logr.Logger
+
+	// Interface, when set, binds Conn to the named interface (SO_BINDTODEVICE on Linux) when Serve is called, so the
+	// server only sees messages received on it, even when other interfaces of the host are in overlapping subnets.
+	// NewServer binds the connection it opens to its interface already, this is for a Conn that is opened by the caller.
+	Interface string
+
+	// Workers bounds the number of messages that are handled at once. A broadcast storm then can not start an unbounded
+	// number of goroutines. A zero value handles every message in its own goroutine.
+	Workers int
+
+	// QueueSize is the number of messages that wait for a worker when all Workers are busy. Messages received while
+	// the queue is full are dropped, see Dropped. Defaults to Workers.
+	QueueSize int
+
+	// DedupWindow, when set, suppresses the retransmissions of a message, with the same transaction ID, client hardware
+	// address, and message type, that are received within DedupWindow of it, so they do not multiply backend reads and traces.
+	// Keep it shorter than the retransmission interval of clients, a few seconds for PXE ROMs, so that a client whose
+	// reply was lost is still answered. See Suppressed.
+	DedupWindow time.Duration
+
+	// SerializeClients, when set, handles the messages of each client, by client hardware address, one at a time
+	// and in the order they were received, so the replies to retransmissions do not interleave out of order, which
+	// confuses some firmware. Messages of different clients are still handled concurrently.
+	SerializeClients bool
+
+	// HandlerTimeout, when set, bounds the handling of each message: the context passed to a handler is done after
+	// HandlerTimeout, so a stuck backend or a slow span exporter can not hold a worker forever. See Expired.
+	HandlerTimeout time.Duration
+
+	// ReceiveBufferSize, when set, is the size of the socket receive buffer (SO_RCVBUF) of Conn, in bytes. A larger buffer
+	// holds more messages during a burst, for example when a rack of machines PXE boots at once, before the kernel drops them.
+	// On Linux, the size is capped by the net.core.rmem_max sysctl.
+	ReceiveBufferSize int
+
+	// MaxMessageSize is the largest message that is read, in bytes. Larger messages are dropped and logged instead of
+	// being truncated, see Oversized. Defaults to DefaultMaxMessageSize, and is capped at the largest UDP payload.
+	MaxMessageSize int
+
+	// UnicastConn, when set, is a connection bound to the server IP, the address in the server identifier option,
+	// that is served and closed together with Conn, with the same Handlers. See ListenUnicast.
+	//
+	// Clients broadcast their first messages, which only a connection on 0.0.0.0 receives, but a renewing client
+	// unicasts its DHCPREQUEST to the server identifier. When Conn is bound to 0.0.0.0 it receives those too,
+	// but replies from it are sent from whichever address the kernel picks. A connection bound to the server IP
+	// receives the unicast messages instead of Conn, and replies to them from the address the client renews with.
+	UnicastConn net.PacketConn
+
+	// PXEConn, when set, is another connection, usually on PXEPort, that is served and closed together with Conn.
+	// See ListenPXE. The Interface, Workers, QueueSize, DedupWindow, ReceiveBufferSize, and MaxMessageSize
+	// settings apply to each connection on its own.
+	PXEConn net.PacketConn
+
+	// PXEHandlers handle the messages received on PXEConn. Defaults to Handlers.
+	PXEHandlers []Handler
+
+	// Limiter, when set, caps the rate of messages that are handled, over all connections of the server. Messages over
+	// the rate are dropped as soon as they are read, before any handler or backend sees them, to protect the process and
+	// backends during a broadcast storm or a loop on the network. See RateLimited. A nil Limiter does not rate limit.
+	Limiter *rate.Limiter
+
+	// Redact redacts the MAC addresses in the logs of the server. Handlers are configured on their own.
+	Redact redact.Redactor
+
+	// Metrics, when set, records the received messages, the messages that were dropped, and the duration of handler calls.
+	// See the metrics package for its implementations.
+	Metrics metrics.Metrics
+
+	// HealthCheckers are checked by Health, keyed by a name that identifies them in the report, for example "kube".
+	// Backends that implement handler.HealthChecker are usually added here.
+	HealthCheckers map[string]handler.HealthChecker
+
+	dropped    atomic.Uint64
+	suppressed atomic.Uint64
+	oversized  atomic.Uint64
+	panics     atomic.Uint64
+	expired    atomic.Uint64
+	limited    atomic.Uint64
+	listening  atomic.Int32
+}
+
+// RateLimited returns the number of messages that were dropped because they were over the rate of Limiter.
+func (s *Server) RateLimited() uint64 {
+	return s.limited.Load()
+}
+
+// Expired returns the number of handler calls that did not finish within HandlerTimeout.
+func (s *Server) Expired() uint64 {
+	return s.expired.Load()
+}
+
+// Panics returns the number of handler calls that panicked. The panics are recovered and logged.
+func (s *Server) Panics() uint64 {
+	return s.panics.Load()
+}
+
+// Oversized returns the number of messages that were dropped because they were larger than MaxMessageSize.
+func (s *Server) Oversized() uint64 {
+	return s.oversized.Load()
+}
+
+// Suppressed returns the number of messages that were suppressed as retransmissions, see DedupWindow.
+func (s *Server) Suppressed() uint64 {
+	return s.suppressed.Load()
+}
+
+// Dropped returns the number of handler calls that were dropped because all Workers were busy and the queue was full.
+// With one handler, it is the number of dropped messages.
+func (s *Server) Dropped() uint64 {
+	return s.dropped.Load()
 }
 
 // Serve serves requests.
+// When UnicastConn or PXEConn are set, they are served too, and Serve returns when all connections are stopped.
 func (s *Server) Serve(ctx context.Context) error {
+	if s.UnicastConn == nil && s.PXEConn == nil {
+		return s.serve(ctx, s.Conn, s.Handlers)
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error { return s.serve(ctx, s.Conn, s.Handlers) })
+	if s.UnicastConn != nil {
+		g.Go(func() error { return s.serve(ctx, s.UnicastConn, s.Handlers) })
+	}
+	if s.PXEConn != nil {
+		g.Go(func() error {
+			h := s.PXEHandlers
+			if len(h) == 0 {
+				h = s.Handlers
+			}
+			return s.serve(ctx, s.PXEConn, h)
+		})
+	}
+
+	return g.Wait()
+}
+
+// serve reads messages from conn and handles them with handlers until ctx is done or conn is closed.
+func (s *Server) serve(ctx context.Context, conn net.PacketConn, handlers []Handler) error {
 	go func() {
 		<-ctx.Done()
-		_ = s.Close()
+		_ = conn.Close()
 	}()
-	s.Logger.Info("Server listening on", "addr", s.Conn.LocalAddr())
+	if s.Interface != "" {
+		if err := bindToInterface(conn, s.Interface); err != nil {
+			s.Logger.Info("error binding to interface", "interface", s.Interface, "err", err)
+			return err
+		}
+	}
+	if s.ReceiveBufferSize > 0 {
+		if err := setReadBuffer(conn, s.ReceiveBufferSize); err != nil {
+			s.Logger.Info("error setting receive buffer size", "size", s.ReceiveBufferSize, "err", err)
+			return err
+		}
+	}
+	s.Logger.Info("Server listening on", "addr", conn.LocalAddr())
 
-	nConn := ipv4.NewPacketConn(s.Conn)
-	if err := nConn.SetControlMessage(ipv4.FlagInterface, true); err != nil {
-		s.Logger.Info("error setting control message", "err", err)
-		return err
+	nConn := ipv4.NewPacketConn(conn)
+	if interfaceControlMessage {
+		if err := nConn.SetControlMessage(ipv4.FlagInterface|ipv4.FlagDst, true); err != nil {
+			s.Logger.Info("error setting control message", "err", err)
+			return err
+		}
 	}
 
 	defer func() {
 		_ = nConn.Close()
 	}()
+	s.listening.Add(1)
+	defer s.listening.Add(-1)
+	p := newPool(s.Workers, s.QueueSize, &s.dropped)
+	defer p.stop()
+	rec := metrics.OrNoop(s.Metrics)
+	dd := newDedup(s.DedupWindow, &s.suppressed)
+	sz := newSerializer(s.SerializeClients)
+	names := make(ifNames)
+	d := dispatch{log: s.Logger, timeout: s.HandlerTimeout, panics: &s.panics, expired: &s.expired, metrics: rec, redact: s.Redact}
+	maxSize := s.MaxMessageSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	maxSize = min(maxSize, maxUDPPayload)
 	for {
-		// Max UDP packet size is 65535. Max DHCPv4 packet size is 576. An ethernet frame is 1500 bytes.
-		// We use 4096 as a reasonable buffer size. dhcpv4.FromBytes will handle the rest.
-		rbuf := make([]byte, 4096)
-		n, cm, peer, err := nConn.ReadFrom(rbuf)
+		m, cm, peer, err := readMessage(nConn, maxSize)
+		if errors.Is(err, errMalformed) {
+			rec.Error(metrics.ReasonMalformed)
+			s.Logger.Info("error parsing DHCPv4 request", "err", err)
+			continue
+		}
+		if errors.Is(err, errOversized) {
+			s.oversized.Add(1)
+			rec.Error(metrics.ReasonOversized)
+			s.Logger.Info("dropping DHCPv4 request", "err", err)
+			continue
+		}
+		if errors.Is(err, errNotRequest) {
+			s.Logger.V(1).Info("ignoring DHCPv4 message", "err", err)
+			continue
+		}
 		if err != nil {
 			select {
 			case <-ctx.Done():
@@ -57,10 +268,15 @@ func (s *Server) Serve(ctx context.Context) error {
 			s.Logger.Info("error reading from packet conn", "err", err)
 			return err
 		}
-
-		m, err := dhcpv4.FromBytes(rbuf[:n])
-		if err != nil {
-			s.Logger.Info("error parsing DHCPv4 request", "err", err)
+		if s.Limiter != nil && !s.Limiter.Allow() {
+			s.limited.Add(1)
+			rec.Error(metrics.ReasonRateLimited)
+			continue
+		}
+		rec.Received(m.MessageType())
+		now := time.Now()
+		if dd.duplicate(m, now) {
+			rec.Error(metrics.ReasonDuplicate)
 			continue
 		}
 
@@ -69,6 +285,7 @@ func (s *Server) Serve(ctx context.Context) error {
 			s.Logger.Info("not a UDP connection? Peer is", "peer", peer)
 			continue
 		}
+		md := metadata(cm, upeer, now, names)
 		// Set peer to broadcast if the client did not have an IP.
 		if upeer.IP == nil || upeer.IP.To4().Equal(net.IPv4zero) {
 			upeer = &net.UDPAddr{
@@ -77,23 +294,170 @@ func (s *Server) Serve(ctx context.Context) error {
 			}
 		}
 
-		var ifName string
-		if n, err := net.InterfaceByIndex(cm.IfIndex); err == nil {
-			ifName = n.Name
+		for i, handler := range handlers {
+			handler := handler
+			run, skip := sz.wrap(i, m.ClientHWAddr, func() {
+				d.handle(ctx, handler, nConn, data.Packet{Peer: upeer, Pkt: m, Md: md})
+			})
+			if !p.submit(run) {
+				skip()
+				rec.Error(metrics.ReasonQueueFull)
+			}
 		}
+	}
+}
 
-		for _, handler := range s.Handlers {
-			go handler.Handle(ctx, nConn, data.Packet{Peer: upeer, Pkt: m, Md: &data.Metadata{IfName: ifName, IfIndex: cm.IfIndex}})
+// metadata returns the metadata of a message from peer that was received at t with the control message cm, which may be nil.
+// The interface name is read from names.
+func metadata(cm *ipv4.ControlMessage, peer *net.UDPAddr, t time.Time, names ifNames) *data.Metadata {
+	md := &data.Metadata{SrcPort: uint16(peer.Port), Received: t}
+	if cm != nil {
+		md.IfIndex = cm.IfIndex
+		if ip, ok := netip.AddrFromSlice(cm.Dst.To4()); ok {
+			md.LocalIP = ip
 		}
 	}
+	md.IfName = names.name(md.IfIndex)
+
+	return md
+}
+
+// ifNames caches the names of the interfaces of the host by index, so that reading a message does not list the
+// interfaces of the host. It is only used by the goroutine that reads from a conn.
+type ifNames map[int]string
+
+// name returns the name of the interface with index i, or "" when there is none. Index 0 is no interface. An index that
+// is not cached is looked up, so interfaces that are added while serving are found.
+func (c ifNames) name(i int) string {
+	if i == 0 {
+		return ""
+	}
+	if n, ok := c[i]; ok {
+		return n
+	}
+	ifi, err := net.InterfaceByIndex(i)
+	if err != nil {
+		return ""
+	}
+	c[i] = ifi.Name
+
+	return ifi.Name
+}
+
+// readMessage reads a message of up to maxSize bytes from conn into a buffer from bufPool and parses it.
+// A larger message is returned as an errOversized error, a message that can not be parsed as an errMalformed error,
+// and a message that is not a request, see checkRequest, as its error.
+func readMessage(conn *ipv4.PacketConn, maxSize int) (*dhcpv4.DHCPv4, *ipv4.ControlMessage, net.Addr, error) {
+	bp := bufPool.Get().(*[]byte)
+	defer bufPool.Put(bp)
+	// the buffer is one byte larger than a message can be, so a larger message fills it instead of being truncated unnoticed.
+	if cap(*bp) < maxSize+1 {
+		*bp = make([]byte, maxSize+1)
+	}
+	buf := (*bp)[:maxSize+1]
+	n, cm, peer, err := conn.ReadFrom(buf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if n > maxSize {
+		return nil, nil, nil, fmt.Errorf("%w of %d bytes, from %v", errOversized, maxSize, peer)
+	}
+	// dhcpv4.FromBytes copies what it keeps of the buffer, so the buffer can be reused once the message is parsed.
+	m, err := dhcpv4.FromBytes(buf[:n])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %w", errMalformed, err)
+	}
+	if err := checkRequest(m); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w, from %v", err, peer)
+	}
+
+	return m, cm, peer, nil
+}
+
+// checkRequest returns an error for a message that handlers can not reply to: an errNotRequest error for a message
+// that is not a BOOTREQUEST, and an errMalformed error for a message without a client hardware address, which
+// identifies the client to the backends and is the destination of the replies on the link.
+func checkRequest(m *dhcpv4.DHCPv4) error {
+	if m.OpCode != dhcpv4.OpcodeBootRequest {
+		return fmt.Errorf("%w: op code %v", errNotRequest, m.OpCode)
+	}
+	if len(m.ClientHWAddr) == 0 {
+		return fmt.Errorf("%w: no client hardware address", errMalformed)
+	}
+
+	return nil
+}
+
+// setReadBuffer sets the size of the socket receive buffer of conn.
+func setReadBuffer(conn net.PacketConn, size int) error {
+	rb, ok := conn.(interface{ SetReadBuffer(int) error })
+	if !ok {
+		return fmt.Errorf("can not set the receive buffer size of %T", conn)
+	}
+
+	return rb.SetReadBuffer(size)
 }
 
-// Close sends a termination request to the server, and closes the UDP listener.
+// Close sends a termination request to the server, and closes the UDP listener, UnicastConn, and PXEConn.
 func (s *Server) Close() error {
-	return s.Conn.Close()
+	err := closeConn(s.Conn)
+	if s.UnicastConn != nil {
+		err = errors.Join(err, closeConn(s.UnicastConn))
+	}
+	if s.PXEConn != nil {
+		err = errors.Join(err, closeConn(s.PXEConn))
+	}
+
+	return err
+}
+
+// closeConn closes conn. A connection that is already closed, for example by Serve when another of the connections
+// of the server was closed, is not an error.
+func closeConn(conn net.PacketConn) error {
+	if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// ListenUnicast opens UnicastConn on the DHCP server port of ip, on ifname, so Serve receives the messages that
+// renewing clients unicast to ip on a connection bound to it. Conn is usually bound to 0.0.0.0 on the same port,
+// for the broadcast messages, both connections set SO_REUSEADDR so they can share the port.
+func (s *Server) ListenUnicast(ifname string, ip netip.Addr) error {
+	if !ip.Is4() {
+		return fmt.Errorf("can not listen for unicast messages on %v, it is not an IPv4 address", ip)
+	}
+	if err := CheckPrivileges(dhcpv4.ServerPort, false); err != nil {
+		return err
+	}
+	conn, err := NewConn(ifname, &net.UDPAddr{IP: ip.AsSlice(), Port: dhcpv4.ServerPort})
+	if err != nil {
+		return err
+	}
+	s.UnicastConn = conn
+
+	return nil
+}
+
+// ListenPXE opens PXEConn on the PXE boot server port of ifname, on all addresses, so Serve also answers the
+// requests that PXE clients send there. Handlers that reply to those requests must address the reply to the peer.
+func (s *Server) ListenPXE(ifname string) error {
+	if err := CheckPrivileges(PXEPort, false); err != nil {
+		return err
+	}
+	conn, err := NewConn(ifname, &net.UDPAddr{Port: PXEPort})
+	if err != nil {
+		return err
+	}
+	s.PXEConn = conn
+
+	return nil
 }
 
 // NewServer initializes and returns a new Server object.
+// When addr is the server IP instead of 0.0.0.0, the server does not receive broadcast messages,
+// use a 0.0.0.0 addr and ListenUnicast to receive both.
 func NewServer(ifname string, addr *net.UDPAddr, handler ...Handler) (*Server, error) {
 	s := &Server{
 		Handlers: handler,
@@ -101,8 +465,10 @@ func NewServer(ifname string, addr *net.UDPAddr, handler ...Handler) (*Server, e
 	}
 
 	if s.Conn == nil {
-		var err error
-		conn, err := server4.NewIPv4UDPConn(ifname, addr)
+		if err := CheckPrivileges(addr.Port, false); err != nil {
+			return nil, err
+		}
+		conn, err := NewConn(ifname, addr)
 		if err != nil {
 			return nil, err
 		}
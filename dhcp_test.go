@@ -2,9 +2,12 @@ package dhcp
 
 import (
 	"context"
+	"errors"
 	"net"
 	"net/netip"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/insomniacslk/dhcp/dhcpv4"
@@ -12,6 +15,7 @@ import (
 	"github.com/tinkerbell/dhcp/data"
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/nettest"
+	"golang.org/x/time/rate"
 )
 
 type mock struct {
@@ -25,8 +29,10 @@ type mock struct {
 }
 
 func (m *mock) Handle(_ context.Context, conn *ipv4.PacketConn, d data.Packet) {
-	if m.Log.GetSink() == nil {
-		m.Log = logr.Discard()
+	// m is shared by the goroutines serving each listener, so the logger is not defaulted in place.
+	log := m.Log
+	if log.GetSink() == nil {
+		log = logr.Discard()
 	}
 
 	mods := m.setOpts()
@@ -38,20 +44,20 @@ func (m *mock) Handle(_ context.Context, conn *ipv4.PacketConn, d data.Packet) {
 	case dhcpv4.MessageTypeRelease:
 		mods = append(mods, dhcpv4.WithMessageType(dhcpv4.MessageTypeAck))
 	default:
-		m.Log.Info("unsupported message type", "type", mt.String())
+		log.Info("unsupported message type", "type", mt.String())
 		return
 	}
 	reply, err := dhcpv4.NewReplyFromRequest(d.Pkt, mods...)
 	if err != nil {
-		m.Log.Error(err, "error creating reply")
+		log.Error(err, "error creating reply")
 		return
 	}
 	cm := &ipv4.ControlMessage{IfIndex: d.Md.IfIndex}
 	if _, err := conn.WriteTo(reply.ToBytes(), cm, d.Peer); err != nil {
-		m.Log.Error(err, "failed to send reply")
+		log.Error(err, "failed to send reply")
 		return
 	}
-	m.Log.Info("sent reply")
+	log.Info("sent reply")
 }
 
 func (m *mock) setOpts() []dhcpv4.Modifier {
@@ -114,3 +120,355 @@ func TestServe(t *testing.T) {
 		})
 	}
 }
+
+func TestServeInterface(t *testing.T) {
+	tests := map[string]struct {
+		iface   string
+		wantErr bool
+	}{
+		"loopback":             {iface: "lo"},
+		"interface not exists": {iface: "does-not-exist0", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := &Server{Conn: conn, Interface: tt.iface, Handlers: []Handler{&mock{ServerIP: net.IP{127, 0, 0, 1}}}, Logger: logr.Discard()}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			go func() { done <- s.Serve(ctx) }()
+			if tt.wantErr {
+				if err := <-done; err == nil {
+					t.Fatal("Serve() error = nil, want an error")
+				}
+				return
+			}
+
+			reply := exchange(t, conn.LocalAddr())
+			if got := reply.ServerIdentifier().To4(); !got.Equal(net.IP{127, 0, 0, 1}) {
+				t.Fatalf("got server identifier %v, want 127.0.0.1", got)
+			}
+			cancel()
+			if err := <-done; err != nil {
+				t.Fatalf("Serve() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestServePXE(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pxe, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		Conn:        conn,
+		Handlers:    []Handler{&mock{ServerIP: net.IP{127, 0, 0, 1}}},
+		PXEConn:     pxe,
+		PXEHandlers: []Handler{&mock{ServerIP: net.IP{127, 0, 0, 2}}},
+		Logger:      logr.Discard(),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx) }()
+
+	if got := exchange(t, conn.LocalAddr()).ServerIdentifier().To4(); !got.Equal(net.IP{127, 0, 0, 1}) {
+		t.Fatalf("got server identifier %v from Conn, want 127.0.0.1", got)
+	}
+	if got := exchange(t, pxe.LocalAddr()).ServerIdentifier().To4(); !got.Equal(net.IP{127, 0, 0, 2}) {
+		t.Fatalf("got server identifier %v from PXEConn, want 127.0.0.2", got)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	if _, err := pxe.WriteTo([]byte{0x01}, conn.LocalAddr()); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("PXEConn write error = %v, want it closed with the server", err)
+	}
+}
+
+func TestServeUnicast(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Conn: conn, Handlers: []Handler{&mock{ServerIP: net.IP{127, 0, 0, 1}}}, Logger: logr.Discard()}
+	if err := s.ListenUnicast("", netip.MustParseAddr("::1")); err == nil {
+		t.Fatal("ListenUnicast() error = nil, want an error for an IPv6 address")
+	}
+	s.UnicastConn, err = net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx) }()
+
+	for _, addr := range []net.Addr{conn.LocalAddr(), s.UnicastConn.LocalAddr()} {
+		if got := exchange(t, addr).ServerIdentifier().To4(); !got.Equal(net.IP{127, 0, 0, 1}) {
+			t.Fatalf("got server identifier %v from %v, want 127.0.0.1", got, addr)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := <-done; !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Serve() error = %v, want %v", err, net.ErrClosed)
+	}
+}
+
+func TestServeRateLimit(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	close(release)
+	h := &blocking{release: release}
+	s := &Server{Conn: conn, Handlers: []Handler{h}, Logger: logr.Discard(), Limiter: rate.NewLimiter(0, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx) }()
+
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	req, err := dhcpv4.NewDiscovery(net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := pc.WriteTo(req.ToBytes(), conn.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for start := time.Now(); (s.RateLimited() < 2 || h.calls.Load() < 1) && time.Since(start) < 2*time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := s.RateLimited(); got != 2 {
+		t.Fatalf("got %d rate limited, want 2 with a burst of 1", got)
+	}
+	if got := h.calls.Load(); got != 1 {
+		t.Fatalf("got %d handler calls, want 1", got)
+	}
+}
+
+// packetConns returns a connection to read messages from and a client connection to send them with.
+func packetConns(tb testing.TB) (*ipv4.PacketConn, net.PacketConn) {
+	tb.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = conn.Close() })
+	c, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = c.Close() })
+
+	return ipv4.NewPacketConn(conn), c
+}
+
+func TestReadMessage(t *testing.T) {
+	req, err := dhcpv4.NewDiscovery(net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		msg     []byte
+		wantErr error
+	}{
+		"valid":     {msg: req.ToBytes()},
+		"malformed": {msg: []byte{0x01, 0x02, 0x03}, wantErr: errMalformed},
+		"oversized": {msg: append(req.ToBytes(), make([]byte, DefaultMaxMessageSize)...), wantErr: errOversized},
+		"reply": {msg: func() []byte {
+			r, _ := dhcpv4.NewReplyFromRequest(req)
+			return r.ToBytes()
+		}(), wantErr: errNotRequest},
+		"no client hardware address": {msg: func() []byte {
+			b := req.ToBytes()
+			b[2] = 0 // hlen
+			return b
+		}(), wantErr: errMalformed},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			conn, c := packetConns(t)
+			if _, err := c.WriteTo(tt.msg, conn.LocalAddr()); err != nil {
+				t.Fatal(err)
+			}
+			m, _, peer, err := readMessage(conn, DefaultMaxMessageSize)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if m.TransactionID != req.TransactionID {
+				t.Fatalf("got transaction ID %v, want %v", m.TransactionID, req.TransactionID)
+			}
+			if peer.String() != c.LocalAddr().String() {
+				t.Fatalf("got peer %v, want %v", peer, c.LocalAddr())
+			}
+		})
+	}
+}
+
+// FuzzReadMessage feeds arbitrary datagrams to the serve loop's reader. It must not panic, and must either return a
+// message that handlers can reply to or one of the errors that the serve loop drops a message for.
+func FuzzReadMessage(f *testing.F) {
+	req, err := dhcpv4.NewDiscovery(net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+		dhcpv4.WithOption(dhcpv4.OptClassIdentifier("PXEClient:Arch:00007:UNDI:003016")),
+		dhcpv4.WithGeneric(dhcpv4.OptionClientMachineIdentifier, make([]byte, 17)),
+	)
+	if err != nil {
+		f.Fatal(err)
+	}
+	b := req.ToBytes()
+	f.Add(b)
+	f.Add(b[:240])                         // the fixed fields and the magic cookie, without options.
+	f.Add(b[:len(b)-1])                    // truncated within the options.
+	f.Add(append(b[:240:240], 0x35, 0xff)) // an option that is longer than the message.
+	f.Add([]byte{})
+	conn, c := packetConns(f)
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		if len(msg) > maxUDPPayload {
+			t.Skip("larger than a UDP datagram")
+		}
+		if _, err := c.WriteTo(msg, conn.LocalAddr()); err != nil {
+			t.Skip(err)
+		}
+		if err := conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+		m, _, _, err := readMessage(conn, DefaultMaxMessageSize)
+		switch {
+		case errors.Is(err, errMalformed), errors.Is(err, errOversized), errors.Is(err, errNotRequest):
+			return
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			t.Skip("the datagram was dropped")
+		case err != nil:
+			t.Fatal(err)
+		}
+		if m.OpCode != dhcpv4.OpcodeBootRequest || len(m.ClientHWAddr) == 0 {
+			t.Fatalf("readMessage() returned a message that is not a request: %v", m)
+		}
+		// handlers build their replies from the message.
+		if _, err := dhcpv4.NewReplyFromRequest(m); err != nil {
+			t.Fatal(err)
+		}
+		_ = m.ToBytes()
+		_ = m.String()
+	})
+}
+
+func TestMetadata(t *testing.T) {
+	now := time.Now()
+	peer := &net.UDPAddr{IP: net.IPv4(192, 168, 2, 1), Port: dhcpv4.ServerPort}
+	tests := map[string]struct {
+		cm   *ipv4.ControlMessage
+		want *data.Metadata
+	}{
+		"no control message": {want: &data.Metadata{SrcPort: dhcpv4.ServerPort, Received: now}},
+		"destination": {
+			cm:   &ipv4.ControlMessage{Dst: net.IPv4(192, 168, 1, 1)},
+			want: &data.Metadata{LocalIP: netip.MustParseAddr("192.168.1.1"), SrcPort: dhcpv4.ServerPort, Received: now},
+		},
+		"unknown interface": {
+			cm:   &ipv4.ControlMessage{IfIndex: 1 << 30, Dst: net.IPv4bcast},
+			want: &data.Metadata{IfIndex: 1 << 30, LocalIP: netip.MustParseAddr("255.255.255.255"), SrcPort: dhcpv4.ServerPort, Received: now},
+		},
+		"cached interface": {
+			cm:   &ipv4.ControlMessage{IfIndex: 1 << 29, Dst: net.IPv4bcast},
+			want: &data.Metadata{IfIndex: 1 << 29, IfName: "eth9", LocalIP: netip.MustParseAddr("255.255.255.255"), SrcPort: dhcpv4.ServerPort, Received: now},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			names := ifNames{0: "unused", 1 << 29: "eth9"}
+			if got := metadata(tt.cm, peer, now, names); *got != *tt.want {
+				t.Fatalf("metadata() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIfNames(t *testing.T) {
+	lo, err := net.InterfaceByIndex(1)
+	if err != nil {
+		t.Skipf("no interface with index 1: %v", err)
+	}
+	names := make(ifNames)
+	if got := names.name(1); got != lo.Name {
+		t.Fatalf("name(1) = %q, want %q", got, lo.Name)
+	}
+	if got := names[1]; got != lo.Name {
+		t.Fatalf("cached name = %q, want %q", got, lo.Name)
+	}
+	if got := names.name(0); got != "" {
+		t.Fatalf("name(0) = %q, want no interface", got)
+	}
+}
+
+// readMessageUnpooled is readMessage reading into a new buffer for every message, as Serve did before bufPool.
+func readMessageUnpooled(conn *ipv4.PacketConn) (*dhcpv4.DHCPv4, error) {
+	buf := make([]byte, 4096)
+	n, _, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return dhcpv4.FromBytes(buf[:n])
+}
+
+// benchmarkRead sends a DHCPDISCOVER and reads it with read b.N times.
+func benchmarkRead(b *testing.B, read func(*ipv4.PacketConn) error) {
+	conn, c := packetConns(b)
+	req, err := dhcpv4.NewDiscovery(net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01})
+	if err != nil {
+		b.Fatal(err)
+	}
+	msg := req.ToBytes()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.WriteTo(msg, conn.LocalAddr()); err != nil {
+			b.Fatal(err)
+		}
+		if err := read(conn); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkReadMessage and BenchmarkReadMessageUnpooled compare reading into a buffer from bufPool with
+// allocating a buffer for every message, see the B/op of each.
+func BenchmarkReadMessage(b *testing.B) {
+	benchmarkRead(b, func(conn *ipv4.PacketConn) error {
+		_, _, _, err := readMessage(conn, DefaultMaxMessageSize)
+		return err
+	})
+}
+
+func BenchmarkReadMessageUnpooled(b *testing.B) {
+	benchmarkRead(b, func(conn *ipv4.PacketConn) error {
+		_, err := readMessageUnpooled(conn)
+		return err
+	})
+}
@@ -0,0 +1,113 @@
+package dhcptest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// errNoReply is returned by Verify for a nil reply.
+var errNoReply = errors.New("no reply")
+
+// Check checks a reply. It returns an error that describes how the reply differs from what is expected.
+type Check func(reply *dhcpv4.DHCPv4) error
+
+// Verify returns the errors of the checks of reply, joined with errors.Join, or nil if all of them pass.
+func Verify(reply *dhcpv4.DHCPv4, checks ...Check) error {
+	if reply == nil {
+		return errNoReply
+	}
+	var errs []error
+	for _, c := range checks {
+		if err := c(reply); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Assert fails tb when a check of reply fails.
+func Assert(tb testing.TB, reply *dhcpv4.DHCPv4, checks ...Check) {
+	tb.Helper()
+	if err := Verify(reply, checks...); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// MessageType checks that the reply is a message of type mt.
+func MessageType(mt dhcpv4.MessageType) Check {
+	return func(r *dhcpv4.DHCPv4) error {
+		if got := r.MessageType(); got != mt {
+			return fmt.Errorf("message type is %v, want %v", got, mt)
+		}
+		return nil
+	}
+}
+
+// YourIP checks the IP address given to the client, yiaddr.
+func YourIP(ip string) Check {
+	return func(r *dhcpv4.DHCPv4) error {
+		if !r.YourIPAddr.Equal(net.ParseIP(ip)) {
+			return fmt.Errorf("yiaddr is %v, want %v", r.YourIPAddr, ip)
+		}
+		return nil
+	}
+}
+
+// NextServer checks the IP address of the next server, siaddr, the TFTP server netbooting clients download from.
+func NextServer(ip string) Check {
+	return func(r *dhcpv4.DHCPv4) error {
+		if !r.ServerIPAddr.Equal(net.ParseIP(ip)) {
+			return fmt.Errorf("siaddr is %v, want %v", r.ServerIPAddr, ip)
+		}
+		return nil
+	}
+}
+
+// BootFileName checks the boot file name, file.
+func BootFileName(name string) Check {
+	return func(r *dhcpv4.DHCPv4) error {
+		if r.BootFileName != name {
+			return fmt.Errorf("boot file name is %q, want %q", r.BootFileName, name)
+		}
+		return nil
+	}
+}
+
+// HasOption checks that the option code is set, with any value.
+func HasOption(code dhcpv4.OptionCode) Check {
+	return func(r *dhcpv4.DHCPv4) error {
+		if !r.Options.Has(code) {
+			return fmt.Errorf("option %v is not set", code)
+		}
+		return nil
+	}
+}
+
+// NoOption checks that the option code is not set.
+func NoOption(code dhcpv4.OptionCode) Check {
+	return func(r *dhcpv4.DHCPv4) error {
+		if r.Options.Has(code) {
+			return fmt.Errorf("option %v is set to %q, want it not set", code, r.GetOneOption(code))
+		}
+		return nil
+	}
+}
+
+// OptionValue checks that the option code is set to value.
+func OptionValue(code dhcpv4.OptionCode, value []byte) Check {
+	return func(r *dhcpv4.DHCPv4) error {
+		if !r.Options.Has(code) {
+			return fmt.Errorf("option %v is not set, want %q", code, value)
+		}
+		if got := r.GetOneOption(code); !bytes.Equal(got, value) {
+			return fmt.Errorf("option %v is %q, want %q", code, got, value)
+		}
+		return nil
+	}
+}
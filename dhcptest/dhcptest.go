@@ -0,0 +1,186 @@
+// Package dhcptest is a programmable DHCP client for end-to-end tests of handlers and backends, without shelling out
+// to dhclient.
+//
+// A Client sends messages to a handler in-process, see New, or to a server over the network, see Dial, and captures
+// the replies. The messages are crafted with Discover, Request, and Inform and modified with the modifiers of this
+// package, for the netboot options 60, 77, 93, 94, and 97, or any dhcpv4.Modifier. The replies are checked with Verify
+// or Assert. For example:
+//
+//	c, err := dhcptest.New(handler)
+//	...
+//	defer c.Close()
+//	offer, ack, err := c.DORA(ctx, mac, dhcptest.WithPXE(iana.EFI_X86_64), dhcptest.WithUserClass("iPXE"))
+//	...
+//	dhcptest.Assert(t, ack, dhcptest.MessageType(dhcpv4.MessageTypeAck), dhcptest.YourIP("192.168.2.150"))
+package dhcptest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp"
+	"github.com/tinkerbell/dhcp/data"
+	"golang.org/x/net/ipv4"
+)
+
+// DefaultTimeout is the default Client.Timeout.
+const DefaultTimeout = time.Second
+
+// maxMessageSize is the size of the buffer replies are read in, larger than any DHCP message in a UDP datagram.
+const maxMessageSize = 65535
+
+// ErrNoReply is returned by Exchange when no reply to the message is received before the timeout.
+var ErrNoReply = errors.New("no reply received")
+
+// Client is a DHCP client. Its methods are safe for concurrent use, messages are exchanged one at a time.
+type Client struct {
+	// Timeout bounds the wait for the reply to a message. Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	conn  net.PacketConn // receives the replies.
+	send  func(ctx context.Context, m *dhcpv4.DHCPv4) error
+	close func() error
+
+	mu      sync.Mutex // protects replies, and serializes the exchanges.
+	replies []*dhcpv4.DHCPv4
+}
+
+// New returns a Client that hands its messages to h in-process, as messages received from the client on the loopback
+// interface. The replies h sends to the client are captured. Replies that are broadcast, like a DHCPNAK to a client that
+// is not behind a relay agent, are not.
+func New(h dhcp.Handler) (*Client, error) {
+	srv, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		_ = srv.Close()
+		return nil, err
+	}
+	pc := ipv4.NewPacketConn(srv)
+	send := func(ctx context.Context, m *dhcpv4.DHCPv4) error {
+		md := &data.Metadata{
+			LocalIP:  netip.AddrFrom4([4]byte{255, 255, 255, 255}),
+			SrcPort:  dhcpv4.ClientPort,
+			Received: time.Now(),
+		}
+		h.Handle(ctx, pc, data.Packet{Peer: conn.LocalAddr(), Pkt: m, Md: md})
+
+		return nil
+	}
+
+	return &Client{conn: conn, send: send, close: srv.Close}, nil
+}
+
+// Dial returns a Client that sends its messages to the server at addr, an IP:port, over UDP. The server must reply to
+// the address the messages are sent from, which it does for a client that is not behind a relay agent.
+func Dial(addr string) (*Client, error) {
+	raddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	send := func(_ context.Context, m *dhcpv4.DHCPv4) error {
+		_, err := conn.WriteTo(m.ToBytes(), raddr)
+		return err
+	}
+
+	return &Client{conn: conn, send: send, close: func() error { return nil }}, nil
+}
+
+// Close closes the connections of c.
+func (c *Client) Close() error {
+	return errors.Join(c.conn.Close(), c.close())
+}
+
+// Send sends m without waiting for a reply, for messages that have none, like a DHCPRELEASE or DHCPDECLINE.
+func (c *Client) Send(ctx context.Context, m *dhcpv4.DHCPv4) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.send(ctx, m)
+}
+
+// Exchange sends m and returns the first reply with its transaction ID. Other replies received in the meantime are
+// captured too, see Replies. It returns ErrNoReply if no reply is received before the timeout, or the deadline of ctx.
+func (c *Client) Exchange(ctx context.Context, m *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.send(ctx, m); err != nil {
+		return nil, err
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, _, err := c.conn.ReadFrom(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, fmt.Errorf("%w to %v %v", ErrNoReply, m.MessageType(), m.TransactionID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		r, err := dhcpv4.FromBytes(buf[:n])
+		if err != nil {
+			// not a DHCP message, a test of a handler is not interested in it.
+			continue
+		}
+		c.replies = append(c.replies, r)
+		if r.OpCode == dhcpv4.OpcodeBootReply && r.TransactionID == m.TransactionID {
+			return r, nil
+		}
+	}
+}
+
+// DORA runs a DISCOVER, OFFER, REQUEST, ACK exchange for mac. The modifiers are applied to both the DHCPDISCOVER and
+// the DHCPREQUEST. It returns the DHCPOFFER, and the reply to the DHCPREQUEST, a DHCPACK or a DHCPNAK.
+func (c *Client) DORA(ctx context.Context, mac net.HardwareAddr, modifiers ...dhcpv4.Modifier) (offer, ack *dhcpv4.DHCPv4, err error) {
+	d, err := Discover(mac, modifiers...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if offer, err = c.Exchange(ctx, d); err != nil {
+		return nil, nil, err
+	}
+	if mt := offer.MessageType(); mt != dhcpv4.MessageTypeOffer {
+		return offer, nil, fmt.Errorf("got a %v in reply to the DHCPDISCOVER, want a DHCPOFFER", mt)
+	}
+	r, err := Request(offer, modifiers...)
+	if err != nil {
+		return offer, nil, err
+	}
+	if ack, err = c.Exchange(ctx, r); err != nil {
+		return offer, nil, err
+	}
+
+	return offer, ack, nil
+}
+
+// Replies returns the replies captured by c, in the order they were received.
+func (c *Client) Replies() []*dhcpv4.DHCPv4 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]*dhcpv4.DHCPv4(nil), c.replies...)
+}
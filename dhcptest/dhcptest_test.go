@@ -0,0 +1,165 @@
+package dhcptest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/dhcp/backend/static"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler/reservation"
+)
+
+func TestDORA(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	b, err := static.New(static.Record{
+		DHCP:    data.DHCP{MACAddress: mac, IPAddress: netip.MustParseAddr("192.168.2.150"), SubnetMask: net.IPv4Mask(255, 255, 255, 0), LeaseTime: 3600},
+		Netboot: data.Netboot{AllowNetboot: true, IPXEScriptURL: &url.URL{Scheme: "http", Host: "192.168.2.225", Path: "auto.ipxe"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &reservation.Handler{Log: logr.Discard(), IPAddr: netip.MustParseAddr("192.168.2.225"), Netboot: reservation.Netboot{Enabled: true}, Backend: b}
+	c, err := New(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	offer, ack, err := c.DORA(context.Background(), mac, WithPXE(iana.EFI_X86_64), WithUserClass("Tinkerbell"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(t, offer,
+		MessageType(dhcpv4.MessageTypeOffer),
+		YourIP("192.168.2.150"),
+		BootFileName("http://192.168.2.225/auto.ipxe"),
+		OptionValue(dhcpv4.OptionServerIdentifier, []byte{192, 168, 2, 225}),
+		HasOption(dhcpv4.OptionClientMachineIdentifier),
+		NoOption(dhcpv4.OptionClassIdentifier),
+	)
+	Assert(t, ack, MessageType(dhcpv4.MessageTypeAck), YourIP("192.168.2.150"))
+	if got := len(c.Replies()); got != 2 {
+		t.Fatalf("got %d replies, want 2", got)
+	}
+
+	// a client that is not in the backend gets no reply.
+	c.Timeout = 50 * time.Millisecond
+	d, err := Discover(net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x06})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Exchange(context.Background(), d); !errors.Is(err, ErrNoReply) {
+		t.Fatalf("Exchange() error = %v, want %v", err, ErrNoReply)
+	}
+}
+
+func TestDial(t *testing.T) {
+	srv, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	// the server replies to every message with an offer of 192.168.2.150.
+	go func() {
+		buf := make([]byte, maxMessageSize)
+		for {
+			n, peer, err := srv.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			m, err := dhcpv4.FromBytes(buf[:n])
+			if err != nil {
+				continue
+			}
+			r, err := dhcpv4.NewReplyFromRequest(m, dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer), dhcpv4.WithYourIP(net.IP{192, 168, 2, 150}))
+			if err != nil {
+				continue
+			}
+			_, _ = srv.WriteTo(r.ToBytes(), peer)
+		}
+	}()
+
+	c, err := Dial(srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	d, err := Discover(net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}, WithVendorClass("HTTPClient"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.Exchange(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(t, r, MessageType(dhcpv4.MessageTypeOffer), YourIP("192.168.2.150"))
+	if r.TransactionID != d.TransactionID {
+		t.Fatalf("got transaction ID %v, want %v", r.TransactionID, d.TransactionID)
+	}
+}
+
+func TestWithPXE(t *testing.T) {
+	m, err := Discover(net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}, WithPXE(iana.EFI_X86_64), WithUserClass("iPXE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Verify(m,
+		OptionValue(dhcpv4.OptionClassIdentifier, []byte("PXEClient:Arch:00007:UNDI:003016")),
+		OptionValue(dhcpv4.OptionClientSystemArchitectureType, []byte{0, 7}),
+		OptionValue(dhcpv4.OptionClientNetworkInterfaceIdentifier, []byte{1, 3, 16}),
+		OptionValue(dhcpv4.OptionClientMachineIdentifier, make([]byte, 17)),
+		OptionValue(dhcpv4.OptionUserClassInformation, []byte("iPXE")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	r := &dhcpv4.DHCPv4{
+		YourIPAddr:   net.IP{192, 168, 2, 150},
+		ServerIPAddr: net.IP{192, 168, 2, 225},
+		BootFileName: "ipxe.efi",
+		Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeOffer), dhcpv4.OptClassIdentifier("PXEClient")),
+	}
+	tests := map[string]struct {
+		checks  []Check
+		wantErr bool
+	}{
+		"pass": {checks: []Check{
+			MessageType(dhcpv4.MessageTypeOffer),
+			YourIP("192.168.2.150"),
+			NextServer("192.168.2.225"),
+			BootFileName("ipxe.efi"),
+			HasOption(dhcpv4.OptionClassIdentifier),
+			NoOption(dhcpv4.OptionUserClassInformation),
+			OptionValue(dhcpv4.OptionClassIdentifier, []byte("PXEClient")),
+		}},
+		"message type":  {checks: []Check{MessageType(dhcpv4.MessageTypeAck)}, wantErr: true},
+		"your ip":       {checks: []Check{YourIP("192.168.2.151")}, wantErr: true},
+		"next server":   {checks: []Check{NextServer("192.168.2.1")}, wantErr: true},
+		"boot file":     {checks: []Check{BootFileName("snp.efi")}, wantErr: true},
+		"has option":    {checks: []Check{HasOption(dhcpv4.OptionUserClassInformation)}, wantErr: true},
+		"no option":     {checks: []Check{NoOption(dhcpv4.OptionClassIdentifier)}, wantErr: true},
+		"option value":  {checks: []Check{OptionValue(dhcpv4.OptionClassIdentifier, []byte("HTTPClient"))}, wantErr: true},
+		"option absent": {checks: []Check{OptionValue(dhcpv4.OptionUserClassInformation, []byte("iPXE"))}, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := Verify(r, tt.checks...); (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if err := Verify(nil); !errors.Is(err, errNoReply) {
+		t.Fatalf("Verify(nil) error = %v, want %v", err, errNoReply)
+	}
+}
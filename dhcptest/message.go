@@ -0,0 +1,60 @@
+package dhcptest
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// Discover returns a DHCPDISCOVER from mac, with the broadcast flag set, like the first message of a client.
+func Discover(mac net.HardwareAddr, modifiers ...dhcpv4.Modifier) (*dhcpv4.DHCPv4, error) {
+	return dhcpv4.NewDiscovery(mac, modifiers...)
+}
+
+// Request returns the DHCPREQUEST that accepts offer, with the server identifier and requested IP address of offer.
+func Request(offer *dhcpv4.DHCPv4, modifiers ...dhcpv4.Modifier) (*dhcpv4.DHCPv4, error) {
+	return dhcpv4.NewRequestFromOffer(offer, modifiers...)
+}
+
+// Inform returns a DHCPINFORM from mac, a client that already has the IP address ip, asking for its configuration.
+func Inform(mac net.HardwareAddr, ip net.IP, modifiers ...dhcpv4.Modifier) (*dhcpv4.DHCPv4, error) {
+	return dhcpv4.NewInform(mac, ip, modifiers...)
+}
+
+// WithVendorClass sets the vendor class identifier, option 60, for example "PXEClient" or "HTTPClient".
+func WithVendorClass(class string) dhcpv4.Modifier {
+	return dhcpv4.WithOption(dhcpv4.OptClassIdentifier(class))
+}
+
+// WithUserClass sets the user class, option 77, as iPXE sends it: the bare class, not in the format of RFC 3004.
+func WithUserClass(class string) dhcpv4.Modifier {
+	return dhcpv4.WithGeneric(dhcpv4.OptionUserClassInformation, []byte(class))
+}
+
+// WithArch sets the client system architectures, option 93.
+func WithArch(archs ...iana.Arch) dhcpv4.Modifier {
+	return dhcpv4.WithOption(dhcpv4.OptClientArch(archs...))
+}
+
+// WithInterfaceID sets the client network interface identifier, option 94, of a UNDI interface of version major.minor.
+func WithInterfaceID(major, minor uint8) dhcpv4.Modifier {
+	return dhcpv4.WithGeneric(dhcpv4.OptionClientNetworkInterfaceIdentifier, []byte{1, major, minor})
+}
+
+// WithMachineID sets the client machine identifier, option 97, a GUID.
+func WithMachineID(guid [16]byte) dhcpv4.Modifier {
+	return dhcpv4.WithGeneric(dhcpv4.OptionClientMachineIdentifier, append([]byte{0}, guid[:]...))
+}
+
+// WithPXE sets the options of a PXE firmware of architecture arch, 60, 93, 94, and 97, as the firmware of a machine
+// sends them when it netboots. Options set after it, for example with WithVendorClass, replace them.
+func WithPXE(arch iana.Arch) dhcpv4.Modifier {
+	return func(m *dhcpv4.DHCPv4) {
+		WithVendorClass(fmt.Sprintf("PXEClient:Arch:%05d:UNDI:003016", uint16(arch)))(m)
+		WithArch(arch)(m)
+		WithInterfaceID(3, 16)(m)
+		WithMachineID([16]byte{})(m)
+	}
+}
@@ -0,0 +1,64 @@
+package dhcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/metrics"
+	"github.com/tinkerbell/dhcp/redact"
+	"golang.org/x/net/ipv4"
+)
+
+// dispatch calls handlers with the messages received by a server, with the settings and counters of the server.
+type dispatch struct {
+	log     logr.Logger
+	timeout time.Duration
+	panics  *atomic.Uint64
+	expired *atomic.Uint64
+	metrics metrics.Metrics
+	redact  redact.Redactor
+}
+
+// handle calls h with p, recovering from a panic in h. Handlers run in their own goroutines, where a panic would stop
+// the process, so a panic is logged with its stack and the message that caused it, and counted in panics instead.
+// When timeout is set, the context of h is done after timeout, and a message whose context expired is counted in expired.
+func (d dispatch) handle(ctx context.Context, h Handler, conn *ipv4.PacketConn, p data.Packet) {
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+	m := metrics.OrNoop(d.metrics)
+	start := time.Now()
+	defer func() {
+		m.Handled(fmt.Sprintf("%T", h), time.Since(start))
+		if r := recover(); r != nil {
+			d.panics.Add(1)
+			m.Error(metrics.ReasonPanic)
+			d.log.Error(fmt.Errorf("%v", r), "recovered from panic in handler", append(d.packetValues(h, p), "stack", string(debug.Stack()))...)
+			return
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			d.expired.Add(1)
+			m.Error(metrics.ReasonExpired)
+			d.log.V(1).Info("handler did not finish within the timeout", append(d.packetValues(h, p), "timeout", d.timeout)...)
+		}
+	}()
+	h.Handle(ctx, conn, p)
+}
+
+// packetValues returns the key value pairs that identify h and the message p in a log, with the MAC address redacted.
+func (d dispatch) packetValues(h Handler, p data.Packet) []any {
+	kv := []any{"handler", fmt.Sprintf("%T", h), "peer", p.Peer}
+	if p.Pkt != nil {
+		kv = append(kv, "mac", d.redact.MAC(p.Pkt.ClientHWAddr), "xid", p.Pkt.TransactionID.String(), "type", p.Pkt.MessageType().String())
+	}
+
+	return kv
+}
@@ -0,0 +1,79 @@
+package dhcp
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/stdr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/data"
+	"golang.org/x/net/ipv4"
+)
+
+// panicking is a handler that panics on every message.
+type panicking struct{}
+
+func (panicking) Handle(context.Context, *ipv4.PacketConn, data.Packet) {
+	panic("boom")
+}
+
+func TestHandleRecover(t *testing.T) {
+	req, err := dhcpv4.NewDiscovery(net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	l := stdr.New(log.New(out, "", 0))
+	var panics atomic.Uint64
+	d := dispatch{log: l, panics: &panics}
+	d.handle(context.Background(), panicking{}, nil, data.Packet{Pkt: req})
+	d.handle(context.Background(), panicking{}, nil, data.Packet{})
+
+	if got := panics.Load(); got != 2 {
+		t.Fatalf("got %d panics, want 2", got)
+	}
+	for _, want := range []string{"recovered from panic in handler", "boom", "00:00:00:00:00:01", "stack"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("log %q does not contain %q", out.String(), want)
+		}
+	}
+}
+
+// waiting is a handler that waits for its context to be done.
+type waiting struct{}
+
+func (waiting) Handle(ctx context.Context, _ *ipv4.PacketConn, _ data.Packet) {
+	<-ctx.Done()
+}
+
+func TestHandleTimeout(t *testing.T) {
+	var expired atomic.Uint64
+	d := dispatch{log: logr.Discard(), timeout: 10 * time.Millisecond, expired: &expired}
+	done := make(chan struct{})
+	go func() {
+		d.handle(context.Background(), waiting{}, nil, data.Packet{})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handle() did not return after the timeout")
+	}
+	if got := expired.Load(); got != 1 {
+		t.Fatalf("got %d expired, want 1", got)
+	}
+
+	release := make(chan struct{})
+	close(release)
+	d.handle(context.Background(), &blocking{release: release}, nil, data.Packet{})
+	if got := expired.Load(); got != 1 {
+		t.Fatalf("got %d expired, want a handler that returns in time not counted", got)
+	}
+}
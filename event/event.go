@@ -0,0 +1,206 @@
+// Package event notifies other systems, like inventory and monitoring, of the provisioning activity of a DHCP server
+// as it happens: the offers, acks, and naks sent, the releases and declines received, and the backend errors.
+//
+// A Notifier receives the events. Webhook POSTs them as JSON to a URL, Broker publishes them as JSON to a message
+// broker like NATS or Kafka, and Queue delivers them in the background so that a slow receiver does not delay replies.
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// Type is the type of an event.
+type Type string
+
+// Types of events.
+const (
+	TypeOffer        Type = "offer"
+	TypeAck          Type = "ack"
+	TypeNAK          Type = "nak"
+	TypeRelease      Type = "release"
+	TypeDecline      Type = "decline"
+	TypeBackendError Type = "backend_error"
+)
+
+// ErrQueueFull is returned by Queue.Notify when the event is dropped because the queue is full.
+var ErrQueueFull = errors.New("event queue is full")
+
+// Event is an event of the DHCP exchange of a client.
+type Event struct {
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	// MAC is the hardware address of the client, redacted when the handler redacts MAC addresses.
+	MAC string `json:"mac"`
+	// TransactionID is the transaction ID of the message, for example "0x3903f326".
+	TransactionID string `json:"xid"`
+	// IP is the IP address offered or acked, or released or declined by the client.
+	IP string `json:"ip,omitempty"`
+	// Hostname is the hostname sent to the client, redacted like MAC.
+	Hostname string `json:"hostname,omitempty"`
+	// BootFileName is the boot file sent to the client.
+	BootFileName string `json:"bootFileName,omitempty"`
+	// Error is the backend error of a backend_error or nak event.
+	Error string `json:"error,omitempty"`
+}
+
+// Notifier receives events. Notify is called by handlers after the reply to a message is sent, its errors are logged.
+// Implementations must be safe for concurrent use.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// NotifierFunc is a function that implements Notifier.
+type NotifierFunc func(ctx context.Context, e Event) error
+
+// Notify implements Notifier.
+func (f NotifierFunc) Notify(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+// Webhook is a Notifier that POSTs each event as JSON to URL.
+type Webhook struct {
+	// URL is the URL the events are POSTed to.
+	URL string
+	// Header, when set, is added to the requests, for example an Authorization header.
+	Header http.Header
+	// Client sends the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Notify implements Notifier. Responses with a status code other than 2xx are errors.
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for k, v := range w.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c := w.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %v responded with status code %d", w.URL, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Publisher publishes data to a subject or topic of a message broker. It is the Publish method of a NATS connection,
+// and small enough to wrap the client of any other broker, like Kafka, without this package depending on them.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// Broker is a Notifier that publishes each event as JSON to Subject with Publisher.
+type Broker struct {
+	Publisher Publisher
+	Subject   string
+}
+
+// Notify implements Notifier.
+func (b *Broker) Notify(_ context.Context, e Event) error {
+	d, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return b.Publisher.Publish(b.Subject, d)
+}
+
+// Queue is a Notifier that queues events and delivers them to Notifier in the background, from Run, so that a slow
+// receiver does not delay the handling of messages. Events are dropped when the queue is full.
+// A Queue can be declared as a struct literal, or returned by NewQueue.
+type Queue struct {
+	// Notifier receives the events.
+	Notifier Notifier
+	// Log is used to log the errors of Notifier.
+	Log logr.Logger
+	// Timeout bounds each delivery. Defaults to DefaultTimeout.
+	Timeout time.Duration
+	// Size is the number of events the queue holds. Defaults to DefaultQueueSize.
+	// It must not be changed after the first call of Notify or Run.
+	Size int
+
+	once   sync.Once
+	events chan Event
+}
+
+// Defaults of Queue.
+const (
+	// DefaultTimeout is the default Queue.Timeout.
+	DefaultTimeout = 5 * time.Second
+	// DefaultQueueSize is the default Queue.Size.
+	DefaultQueueSize = 1024
+)
+
+// NewQueue returns a Queue that holds up to size events for n.
+func NewQueue(n Notifier, size int, log logr.Logger) *Queue {
+	return &Queue{Notifier: n, Log: log, Size: size}
+}
+
+// queue returns the channel of the queued events, which is made on first use.
+func (q *Queue) queue() chan Event {
+	q.once.Do(func() {
+		size := q.Size
+		if size <= 0 {
+			size = DefaultQueueSize
+		}
+		q.events = make(chan Event, size)
+	})
+
+	return q.events
+}
+
+// Notify implements Notifier. It returns ErrQueueFull when the event is dropped.
+func (q *Queue) Notify(_ context.Context, e Event) error {
+	select {
+	case q.queue() <- e:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run delivers the queued events until ctx is done.
+func (q *Queue) Run(ctx context.Context) {
+	timeout := q.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	events := q.queue()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-events:
+			nctx, cancel := context.WithTimeout(ctx, timeout)
+			if err := q.Notifier.Notify(nctx, e); err != nil && q.Log.GetSink() != nil {
+				q.Log.Info("error delivering event", "type", e.Type, "mac", e.MAC, "error", err)
+			}
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+)
+
+var testEvent = Event{
+	Type:          TypeAck,
+	Time:          time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	MAC:           "00:01:02:03:04:05",
+	TransactionID: "0x3903f326",
+	IP:            "192.168.2.150",
+	Hostname:      "server1",
+}
+
+const testJSON = `{"type":"ack","time":"2024-01-02T03:04:05Z","mac":"00:01:02:03:04:05","xid":"0x3903f326","ip":"192.168.2.150","hostname":"server1"}`
+
+func TestWebhook(t *testing.T) {
+	tests := map[string]struct {
+		status  int
+		wantErr bool
+	}{
+		"ok":           {status: http.StatusOK},
+		"no content":   {status: http.StatusNoContent},
+		"server error": {status: http.StatusInternalServerError, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var body, contentType, auth string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				body, contentType, auth = string(b), r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			w := &Webhook{URL: srv.URL, Header: http.Header{"Authorization": []string{"Bearer token"}}}
+			err := w.Notify(context.Background(), testEvent)
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Fatalf("Notify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if body != testJSON {
+				t.Fatalf("body = %s, want %s", body, testJSON)
+			}
+			if contentType != "application/json" || auth != "Bearer token" {
+				t.Fatalf("Content-Type = %q, Authorization = %q", contentType, auth)
+			}
+		})
+	}
+}
+
+type publisher struct {
+	subject string
+	data    []byte
+	err     error
+}
+
+func (p *publisher) Publish(subject string, data []byte) error {
+	p.subject, p.data = subject, data
+	return p.err
+}
+
+func TestBroker(t *testing.T) {
+	p := &publisher{}
+	b := &Broker{Publisher: p, Subject: "dhcp.events"}
+	if err := b.Notify(context.Background(), testEvent); err != nil {
+		t.Fatal(err)
+	}
+	if p.subject != "dhcp.events" || string(p.data) != testJSON {
+		t.Fatalf("published %s to %q, want %s to %q", p.data, p.subject, testJSON, "dhcp.events")
+	}
+
+	p.err = errors.New("not connected")
+	if err := b.Notify(context.Background(), testEvent); !errors.Is(err, p.err) {
+		t.Fatalf("Notify() error = %v, want %v", err, p.err)
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	var got Event
+	if err := json.Unmarshal([]byte(testJSON), &got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(testEvent, got); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	got := make(chan Event)
+	n := NotifierFunc(func(ctx context.Context, e Event) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("delivery has no timeout")
+		}
+		got <- e
+		return nil
+	})
+	q := NewQueue(n, 1, logr.Discard())
+	if err := q.Notify(context.Background(), testEvent); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Notify(context.Background(), testEvent); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Notify() error = %v, want %v", err, ErrQueueFull)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+	if e := <-got; e != testEvent {
+		t.Fatalf("delivered %v, want %v", e, testEvent)
+	}
+	cancel()
+	<-done
+}
+
+func TestQueueLiteral(t *testing.T) {
+	got := make(chan Event, 1)
+	q := &Queue{Notifier: NotifierFunc(func(_ context.Context, e Event) error {
+		got <- e
+		return nil
+	})}
+	if err := q.Notify(context.Background(), testEvent); err != nil {
+		t.Fatalf("Notify() error = %v, want the event queued", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+	if e := <-got; e != testEvent {
+		t.Fatalf("delivered %v, want %v", e, testEvent)
+	}
+}
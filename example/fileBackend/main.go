@@ -5,6 +5,7 @@ import (
 	"context"
 	"log"
 	"net"
+	"net/http"
 	"net/netip"
 	"net/url"
 	"os"
@@ -16,14 +17,17 @@ import (
 	"github.com/go-logr/stdr"
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/dhcpv4/server4"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tinkerbell/dhcp"
 	"github.com/tinkerbell/dhcp/backend/file"
 	"github.com/tinkerbell/dhcp/handler"
 	"github.com/tinkerbell/dhcp/handler/reservation"
+	"github.com/tinkerbell/dhcp/metrics"
+	"github.com/tinkerbell/dhcp/metrics/debug"
 )
 
 func main() {
-	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGHUP, syscall.SIGTERM)
+	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer done()
 	ctx, otelShutdown := otelinit.InitOpenTelemetry(ctx, "github.com/tinkerbell/dhcp")
 	defer otelShutdown(ctx)
@@ -38,20 +42,23 @@ func main() {
 		panic(err)
 	}
 
-	h := &reservation.Handler{
-		Log:    l,
-		IPAddr: netip.MustParseAddr("192.168.2.225"),
-		Netboot: reservation.Netboot{
-			IPXEBinServerTFTP: netip.MustParseAddrPort("192.168.1.34:69"),
-			IPXEBinServerHTTP: &url.URL{Scheme: "http", Host: "192.168.1.34:8080"},
-			IPXEScriptURL: func(*dhcpv4.DHCPv4) *url.URL {
-				return &url.URL{Scheme: "https", Host: "boot.netboot.xyz"}
-			},
-			Enabled: true,
-		},
-		OTELEnabled: true,
-		Backend:     backend,
+	h := &reservation.Handler{Log: l, Backend: backend}
+	if err := h.Reload(config()); err != nil {
+		panic(err)
 	}
+	// reload the handler configuration on SIGHUP, without restarting the listener.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := h.Reload(config()); err != nil {
+				l.Error(err, "failed to reload configuration, serving with the current configuration")
+				continue
+			}
+			l.Info("reloaded configuration")
+		}
+	}()
+
 	conn, err := server4.NewIPv4UDPConn("", net.UDPAddrFromAddrPort(netip.MustParseAddrPort("0.0.0.0:67")))
 	if err != nil {
 		panic(err)
@@ -60,12 +67,53 @@ func main() {
 	defer func() {
 		_ = conn.Close()
 	}()
-	server := &dhcp.Server{Logger: l, Conn: conn, Handlers: []dhcp.Handler{h}}
-	l.Info("starting server", "addr", h.IPAddr)
+	// serve /metrics and /healthz for alerting, and the pprof and expvar endpoints when DEBUG_ENDPOINTS is set to true.
+	reg := prometheus.NewRegistry()
+	m, err := metrics.New(reg)
+	if err != nil {
+		panic(err)
+	}
+	h.Metrics = m
+	server := &dhcp.Server{Logger: l, Conn: conn, Handlers: []dhcp.Handler{h}, Metrics: m}
+	server.HealthCheckers = map[string]handler.HealthChecker{"listener": server, "file": backend.(handler.HealthChecker)}
+	mux := http.NewServeMux()
+	metrics.Register(mux, reg, server.HealthHandler())
+	if os.Getenv("DEBUG_ENDPOINTS") == "true" {
+		debug.Register(mux)
+	}
+	go func() {
+		if err := metrics.ListenAndServe(ctx, "127.0.0.1:9090", mux); err != nil {
+			l.Error(err, "metrics server stopped")
+		}
+	}()
+	l.Info("starting server", "addr", h.Config().IPAddr)
 	l.Error(server.Serve(ctx), "done")
 	l.Info("done")
 }
 
+// config returns the handler configuration. The iPXE script URL can be changed with the IPXE_SCRIPT_URL environment variable.
+func config() reservation.Config {
+	script := &url.URL{Scheme: "https", Host: "boot.netboot.xyz"}
+	if s := os.Getenv("IPXE_SCRIPT_URL"); s != "" {
+		if u, err := url.Parse(s); err == nil {
+			script = u
+		}
+	}
+
+	return reservation.Config{
+		IPAddr: netip.MustParseAddr("192.168.2.225"),
+		Netboot: reservation.Netboot{
+			IPXEBinServerTFTP: netip.MustParseAddrPort("192.168.1.34:69"),
+			IPXEBinServerHTTP: &url.URL{Scheme: "http", Host: "192.168.1.34:8080"},
+			IPXEScriptURL: func(*dhcpv4.DHCPv4) *url.URL {
+				return script
+			},
+			Enabled: true,
+		},
+		OTELEnabled: true,
+	}
+}
+
 func fileBackend(ctx context.Context, l logr.Logger, f string) (handler.BackendReader, error) {
 	fb, err := file.NewWatcher(l, f)
 	if err != nil {
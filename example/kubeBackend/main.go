@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net"
 	"net/netip"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/equinix-labs/otel-init-go/otelinit"
 	"github.com/go-logr/stdr"
@@ -17,7 +19,6 @@ import (
 	"github.com/insomniacslk/dhcp/dhcpv4/server4"
 	"github.com/tinkerbell/dhcp"
 	"github.com/tinkerbell/dhcp/backend/kube"
-	"github.com/tinkerbell/dhcp/handler"
 	"github.com/tinkerbell/dhcp/handler/reservation"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
@@ -52,6 +53,7 @@ func main() {
 		},
 		OTELEnabled: true,
 		Backend:     backend,
+		Writer:      backend,
 	}
 	conn, err := server4.NewIPv4UDPConn("", net.UDPAddrFromAddrPort(netip.MustParseAddrPort("0.0.0.0:67")))
 	if err != nil {
@@ -66,7 +68,7 @@ func main() {
 	l.Info("done")
 }
 
-func kubeBackend(ctx context.Context) (handler.BackendReader, error) {
+func kubeBackend(ctx context.Context) (*kube.Backend, error) {
 	ccfg := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
 		&clientcmd.ClientConfigLoadingRules{
 			ExplicitPath: "/home/tink/.kube/config",
@@ -83,7 +85,7 @@ func kubeBackend(ctx context.Context) (handler.BackendReader, error) {
 		return nil, err
 	}
 
-	k, err := kube.NewBackend(config)
+	k, err := kube.NewBackend(config, kube.WithNamespace("tink-system"))
 	if err != nil {
 		return nil, err
 	}
@@ -92,5 +94,12 @@ func kubeBackend(ctx context.Context) (handler.BackendReader, error) {
 		_ = k.Start(ctx)
 	}()
 
+	// Wait for the Hardware cache to sync so that early DHCP requests are not answered with "no hardware found".
+	sctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if !k.WaitForCacheSync(sctx) {
+		return nil, errors.New("timed out waiting for the hardware cache to sync")
+	}
+
 	return k, nil
 }
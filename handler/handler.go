@@ -4,6 +4,8 @@ package handler
 import (
 	"context"
 	"net"
+	"net/netip"
+	"time"
 
 	"github.com/tinkerbell/dhcp/data"
 )
@@ -17,3 +19,44 @@ type BackendReader interface {
 	GetByMac(context.Context, net.HardwareAddr) (*data.DHCP, *data.Netboot, error)
 	GetByIP(context.Context, net.IP) (*data.DHCP, *data.Netboot, error)
 }
+
+// BackendWriter is the interface for recording lease events in a backend.
+//
+// Backends optionally implement this interface so that systems downstream of the backend learn
+// what actually happened on the wire. Handlers call it after an event occurs; errors are logged and do not
+// change the DHCP response.
+type BackendWriter interface {
+	// RecordAck records that a DHCP ACK assigning ip, and serving bootfile, was sent to mac.
+	RecordAck(ctx context.Context, mac net.HardwareAddr, ip netip.Addr, bootfile string) error
+	// RecordRelease records that mac released ip.
+	RecordRelease(ctx context.Context, mac net.HardwareAddr, ip netip.Addr) error
+	// RecordDecline records that mac declined ip, usually because the address is already in use.
+	RecordDecline(ctx context.Context, mac net.HardwareAddr, ip netip.Addr) error
+}
+
+// HealthChecker is the interface for checking the health of a backend.
+//
+// Backends optionally implement this interface so that their health, for example whether a client-side
+// cache is synced, can be reported by the server.
+type HealthChecker interface {
+	// Healthy returns nil if the backend is able to serve data and an error describing the problem otherwise.
+	// It must not block for longer than ctx allows.
+	Healthy(ctx context.Context) error
+}
+
+// Status is the health of a handler.
+type Status struct {
+	// Backend is the result of the health check of the backend of the handler: nil when it is healthy or does not
+	// implement HealthChecker. For example, the check of the kube backend fails until its cache is synced.
+	Backend error
+	// LastRead is when a backend read of the handler last succeeded, the zero time if none has yet.
+	LastRead time.Time
+}
+
+// HealthReporter is the interface for reporting the health of a handler.
+//
+// Handlers optionally implement this interface so that their health is reported by the server with its own.
+type HealthReporter interface {
+	// Health returns the status of the handler. It must not block for longer than ctx allows.
+	Health(ctx context.Context) Status
+}
@@ -0,0 +1,45 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// SetMaintenance enables or disables maintenance mode. While it is enabled the handler does not reply to any message,
+// so that another DHCP server can take over or the backend can be worked on without clients receiving partial data.
+// It is safe to call while the handler is serving.
+func (h *Handler) SetMaintenance(enabled bool) {
+	h.maintenance.Store(enabled)
+}
+
+// Maintenance reports whether maintenance mode is enabled.
+func (h *Handler) Maintenance() bool {
+	enabled, _ := h.maintenance.Load().(bool)
+
+	return enabled
+}
+
+// DryRun returns the DHCPOFFER that the handler would send in reply to a DHCPDISCOVER from mac, without sending it.
+// It returns the backend read error, for example a data.ErrNotFound error, when no offer would be sent.
+// The DHCPDISCOVER is not from a netboot client, so the offer holds no netboot options. Maintenance mode is ignored.
+func (h *Handler) DryRun(ctx context.Context, mac net.HardwareAddr) (*dhcpv4.DHCPv4, error) {
+	h.setDefaults()
+	h = h.current()
+	pkt, err := dhcpv4.NewDiscovery(mac)
+	if err != nil {
+		return nil, err
+	}
+	d, n, err := h.readBackend(ctx, mac)
+	if err != nil {
+		return nil, err
+	}
+	reply := h.updateMsg(ctx, pkt, d, n, dhcpv4.MessageTypeOffer)
+	if reply == nil {
+		return nil, errors.New("failed to build DHCP offer")
+	}
+
+	return reply, nil
+}
@@ -0,0 +1,88 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/backend/mock"
+	"github.com/tinkerbell/dhcp/data"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/nettest"
+)
+
+func TestMaintenance(t *testing.T) {
+	req := &dhcpv4.DHCPv4{
+		OpCode:       dhcpv4.OpcodeBootRequest,
+		ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+		Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover)),
+	}
+	w := &mockWriter{}
+	h := &Handler{Backend: newMockBackend(data.Netboot{}), Writer: w, IPAddr: netip.MustParseAddr("127.0.0.1")}
+	conn, err := nettest.NewLocalPacketListener("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	pc, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	peer := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: pc.LocalAddr().(*net.UDPAddr).Port}
+
+	for _, enabled := range []bool{true, false} {
+		h.SetMaintenance(enabled)
+		if got := h.Maintenance(); got != enabled {
+			t.Fatalf("Maintenance() = %v, want %v", got, enabled)
+		}
+		h.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: peer, Pkt: req})
+		if _, err := client(pc); (err == nil) == enabled {
+			t.Fatalf("got reply = %v in maintenance mode = %v", err == nil, enabled)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	tests := map[string]struct {
+		backend *mock.Backend
+		wantIP  net.IP
+		wantErr error
+	}{
+		"offer":     {backend: newMockBackend(data.Netboot{}), wantIP: net.IP{192, 168, 1, 100}},
+		"not found": {backend: failingBackend(data.ErrNotFound), wantErr: data.ErrNotFound},
+		"error":     {backend: failingBackend(errBadBackend), wantErr: errBadBackend},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := &mockWriter{}
+			h := &Handler{Backend: tt.backend, Writer: w, IPAddr: netip.MustParseAddr("192.168.1.1")}
+			// maintenance mode does not stop a dry run.
+			h.SetMaintenance(true)
+			got, err := h.DryRun(context.Background(), mac)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DryRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.MessageType() != dhcpv4.MessageTypeOffer {
+				t.Fatalf("got message type %v, want %v", got.MessageType(), dhcpv4.MessageTypeOffer)
+			}
+			if diff := cmp.Diff(tt.wantIP, got.YourIPAddr.To4()); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(mac, got.ClientHWAddr); diff != "" {
+				t.Fatal(diff)
+			}
+			if len(w.events) != 0 {
+				t.Fatalf("got events %v, want nothing recorded", w.events)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package reservation
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/backend/metrics"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+// Outcomes of a handled message, logged in the outcome key of the decision record.
+const (
+	outcomeSent        = "sent"
+	outcomeReadFailed  = "backend read failed"
+	outcomeNoReply     = "no reply required"
+	outcomeUnknownType = "unknown message type"
+	outcomeNAKFailed   = "building NAK failed"
+	outcomeSendFailed  = "send failed"
+)
+
+// decision is the decision path of a handled message. When DecisionLog is set, Handle logs it as one record,
+// so that why a client got the reply it got, or none, can be read from a single line.
+type decision struct {
+	d       *data.DHCP
+	n       *data.Netboot
+	readErr error
+	reply   *dhcpv4.DHCPv4
+	outcome string
+}
+
+// read records the result of the backend read.
+func (dec *decision) read(d *data.DHCP, n *data.Netboot, err error) {
+	dec.d, dec.n, dec.readErr = d, n, err
+}
+
+// logDecision logs the decision path dec of the message pkt as one record, when DecisionLog is set.
+func (h *Handler) logDecision(log logr.Logger, pkt *dhcpv4.DHCPv4, dec *decision) {
+	if !h.DecisionLog {
+		return
+	}
+	kv := []any{"type", pkt.MessageType().String(), "outcome", dec.outcome}
+
+	switch {
+	case dec.readErr != nil:
+		kv = append(kv, "backend", metrics.Class(dec.readErr), "backendError", h.Redact.Error(dec.readErr))
+	case dec.d != nil:
+		kv = append(kv, "backend", "found", "recordIP", dec.d.IPAddress.String(), "hostname", h.Redact.Hostname(dec.d.Hostname))
+		if dec.n != nil {
+			kv = append(kv, "allowNetboot", dec.n.AllowNetboot)
+		}
+	}
+
+	if err := h.isNetbootClient(pkt); err != nil {
+		kv = append(kv, "netbootClient", false, "netbootCheck", err.Error())
+	} else {
+		kv = append(kv, "netbootClient", true)
+	}
+	kv = append(kv,
+		"netbootEnabled", h.Netboot.Enabled,
+		"arch", arch(pkt).String(),
+		"userClass", string(pkt.GetOneOption(dhcpv4.OptionUserClassInformation)),
+	)
+
+	if r := dec.reply; r != nil {
+		kv = append(kv, "reply", r.MessageType().String(), "yourIP", r.YourIPAddr.String(), "bootFileName", r.BootFileName)
+		if r.ServerIPAddr != nil {
+			kv = append(kv, "nextServer", r.ServerIPAddr.String())
+		}
+	} else {
+		kv = append(kv, "reply", "none")
+	}
+
+	log.Info("DHCP decision", kv...)
+}
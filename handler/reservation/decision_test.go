@@ -0,0 +1,79 @@
+package reservation
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tonglil/buflogr"
+)
+
+func TestLogDecision(t *testing.T) {
+	discover := &dhcpv4.DHCPv4{
+		OpCode:       dhcpv4.OpcodeBootRequest,
+		ClientHWAddr: net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+		Options: dhcpv4.OptionsFromList(
+			dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover),
+			dhcpv4.OptClassIdentifier("PXEClient:Arch:00007:UNDI:003001"),
+			dhcpv4.OptClientArch(iana.EFI_X86_64),
+			dhcpv4.OptGeneric(dhcpv4.OptionClientNetworkInterfaceIdentifier, []byte{0x01, 0x03, 0x01}),
+			dhcpv4.OptUserClass("iPXE"),
+		),
+	}
+	offer, err := dhcpv4.NewReplyFromRequest(discover, dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer), dhcpv4.WithYourIP(net.IP{192, 168, 1, 100}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offer.BootFileName = "snp.efi"
+	offer.ServerIPAddr = net.IP{192, 168, 1, 1}
+
+	tests := map[string]struct {
+		disabled bool
+		pkt      *dhcpv4.DHCPv4
+		dec      *decision
+		want     []string
+	}{
+		"disabled": {disabled: true, pkt: discover, dec: &decision{outcome: outcomeSent}},
+		"sent": {
+			pkt: discover,
+			dec: &decision{
+				d:       &data.DHCP{IPAddress: netip.MustParseAddr("192.168.1.100"), Hostname: "server1"},
+				n:       &data.Netboot{AllowNetboot: true},
+				reply:   offer,
+				outcome: outcomeSent,
+			},
+			want: []string{
+				"INFO DHCP decision type DISCOVER outcome sent",
+				"backend found recordIP 192.168.1.100 hostname server1 allowNetboot true",
+				"netbootClient true",
+				"userClass iPXE",
+				"reply OFFER yourIP 192.168.1.100 bootFileName snp.efi nextServer 192.168.1.1",
+			},
+		},
+		"backend not found": {
+			pkt:  &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest))},
+			dec:  &decision{readErr: hwNotFoundError{}, outcome: outcomeReadFailed},
+			want: []string{"type REQUEST", "backend not_found backendError not found", "netbootClient false netbootCheck", "reply none"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := &Handler{DecisionLog: !tt.disabled}
+			h.logDecision(buflogr.NewWithBuffer(&buf), tt.pkt, tt.dec)
+			if tt.disabled && buf.Len() != 0 {
+				t.Fatalf("got a decision record %q with DecisionLog not set", buf.String())
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(buf.String(), w) {
+					t.Errorf("decision record %q does not contain %q", buf.String(), w)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package reservation
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/event"
+)
+
+// replyEvents are the types of the events of the replies that are sent.
+var replyEvents = map[dhcpv4.MessageType]event.Type{
+	dhcpv4.MessageTypeOffer: event.TypeOffer,
+	dhcpv4.MessageTypeAck:   event.TypeAck,
+	dhcpv4.MessageTypeNak:   event.TypeNAK,
+}
+
+// newEvent returns an event of type t for the message m, with the IP address ip when it is set.
+// The MAC address is redacted with Redact.
+func (h *Handler) newEvent(t event.Type, m *dhcpv4.DHCPv4, ip net.IP) event.Event {
+	e := event.Event{Type: t, Time: time.Now(), MAC: h.Redact.MAC(m.ClientHWAddr), TransactionID: m.TransactionID.String()}
+	if ip != nil && !ip.IsUnspecified() {
+		e.IP = ip.String()
+	}
+
+	return e
+}
+
+// replyEvent returns the event of the reply that was sent, and false when replies of its type are not notified.
+// err is the backend error that caused a NAK. The hostname and the error are redacted with Redact, like the MAC address.
+func (h *Handler) replyEvent(reply *dhcpv4.DHCPv4, err error) (event.Event, bool) {
+	t, ok := replyEvents[reply.MessageType()]
+	if !ok {
+		return event.Event{}, false
+	}
+	e := h.newEvent(t, reply, reply.YourIPAddr)
+	e.Hostname, e.BootFileName = h.Redact.Hostname(reply.HostName()), reply.BootFileName
+	e.Error = h.Redact.Error(err)
+
+	return e, true
+}
+
+// notify sends e to Notifier. It does nothing when no Notifier is configured.
+// Errors are only logged, they never change the DHCP response.
+func (h *Handler) notify(ctx context.Context, log logr.Logger, e event.Event) {
+	if h.Notifier == nil {
+		return
+	}
+	if err := h.Notifier.Notify(ctx, e); err != nil {
+		log.Info("error sending event", "event", e.Type, "error", h.Redact.Error(err))
+	}
+}
@@ -0,0 +1,84 @@
+package reservation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exchangeWindow is how long after the first message of an exchange the later messages of the exchange are traced
+// in its trace. A client sends its REQUEST within seconds of the OFFER, the window leaves room for retransmissions.
+const exchangeWindow = 30 * time.Second
+
+// exchangeKey identifies the messages of one exchange, for example a DISCOVER and the REQUEST that follows its OFFER.
+// A client uses the transaction ID of the DISCOVER in its REQUEST (RFC 2131, section 4.4.1).
+type exchangeKey struct {
+	xid dhcpv4.TransactionID
+	mac string
+}
+
+// exchangeSpan is the span of the first message of an exchange and when it was received.
+type exchangeSpan struct {
+	sc   trace.SpanContext
+	seen time.Time
+}
+
+// exchanges remembers the span of the first message of each exchange, so that the spans of the later messages are
+// started in the same trace and a whole DORA exchange shows up as one trace, instead of one trace per message.
+// The zero value is ready to use.
+type exchanges struct {
+	mu    sync.Mutex // protects the fields below
+	spans map[exchangeKey]exchangeSpan
+	swept time.Time
+}
+
+// start returns the options to start the span of message m with, and ctx with the span of the first message of
+// the exchange of m as its parent. When m is the first message of its exchange, ctx and no options are returned,
+// and the span started for m has to be recorded with record. When ctx already has a span, for example one started
+// by the caller, it is kept as the parent and the span of the exchange is only linked.
+func (e *exchanges) start(ctx context.Context, m *dhcpv4.DHCPv4, now time.Time) (context.Context, []trace.SpanStartOption) {
+	k := exchangeKey{xid: m.TransactionID, mac: string(m.ClientHWAddr)}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if now.Sub(e.swept) > exchangeWindow {
+		for k, s := range e.spans {
+			if now.Sub(s.seen) > exchangeWindow {
+				delete(e.spans, k)
+			}
+		}
+		e.swept = now
+	}
+	s, ok := e.spans[k]
+	if !ok || now.Sub(s.seen) > exchangeWindow {
+		return ctx, nil
+	}
+	opts := []trace.SpanStartOption{trace.WithLinks(trace.Link{SpanContext: s.sc})}
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, s.sc)
+	}
+
+	return ctx, opts
+}
+
+// record records sc as the span of m, the first message of its exchange. Span contexts that are not sampled,
+// from a tracer that does not record or a message that is not traced, are not recorded.
+func (e *exchanges) record(m *dhcpv4.DHCPv4, sc trace.SpanContext, now time.Time) {
+	if !sc.IsSampled() {
+		return
+	}
+	k := exchangeKey{xid: m.TransactionID, mac: string(m.ClientHWAddr)}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.spans == nil {
+		e.spans = make(map[exchangeKey]exchangeSpan)
+	}
+	if s, ok := e.spans[k]; ok && now.Sub(s.seen) <= exchangeWindow {
+		return
+	}
+	e.spans[k] = exchangeSpan{sc: sc, seen: now}
+}
@@ -0,0 +1,68 @@
+package reservation
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestExchanges(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	discover := &dhcpv4.DHCPv4{ClientHWAddr: mac, TransactionID: dhcpv4.TransactionID{0x01, 0x02, 0x03, 0x04}}
+	request := &dhcpv4.DHCPv4{ClientHWAddr: mac, TransactionID: discover.TransactionID}
+	other := &dhcpv4.DHCPv4{ClientHWAddr: mac, TransactionID: dhcpv4.TransactionID{0x05, 0x06, 0x07, 0x08}}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x01},
+		TraceFlags: trace.FlagsSampled,
+	})
+	caller := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{0x02}, SpanID: trace.SpanID{0x02}})
+	now := time.Now()
+
+	tests := map[string]struct {
+		ctx        context.Context
+		m          *dhcpv4.DHCPv4
+		now        time.Time
+		wantLinked bool
+		wantParent trace.SpanContext
+	}{
+		"same exchange":       {ctx: context.Background(), m: request, now: now.Add(time.Second), wantLinked: true, wantParent: sc},
+		"caller span is kept": {ctx: trace.ContextWithSpanContext(context.Background(), caller), m: request, now: now.Add(time.Second), wantLinked: true, wantParent: caller},
+		"other transaction":   {ctx: context.Background(), m: other, now: now.Add(time.Second)},
+		"after the window":    {ctx: context.Background(), m: request, now: now.Add(exchangeWindow + time.Second)},
+		"other client":        {ctx: context.Background(), m: &dhcpv4.DHCPv4{ClientHWAddr: net.HardwareAddr{0x01}, TransactionID: discover.TransactionID}, now: now},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := &exchanges{}
+			e.record(discover, sc, now)
+			ctx, opts := e.start(tt.ctx, tt.m, tt.now)
+			if linked := len(opts) > 0; linked != tt.wantLinked {
+				t.Fatalf("start() returned %d options, want a link: %v", len(opts), tt.wantLinked)
+			}
+			if got := trace.SpanContextFromContext(ctx); !got.Equal(tt.wantParent) {
+				t.Fatalf("parent = %v, want %v", got, tt.wantParent)
+			}
+		})
+	}
+}
+
+func TestExchangesRecordUnsampled(t *testing.T) {
+	m := &dhcpv4.DHCPv4{ClientHWAddr: net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}}
+	for name, sc := range map[string]trace.SpanContext{
+		"invalid":     {},
+		"not sampled": trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{0x01}, SpanID: trace.SpanID{0x01}}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			e := &exchanges{}
+			e.record(m, sc, time.Now())
+			if _, opts := e.start(context.Background(), m, time.Now()); len(opts) != 0 {
+				t.Fatal("start() linked to a span context that is not sampled")
+			}
+		})
+	}
+}
@@ -5,13 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/netip"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/backend/metrics"
 	"github.com/tinkerbell/dhcp/backend/noop"
 	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/event"
+	"github.com/tinkerbell/dhcp/handler"
+	metricsdhcp "github.com/tinkerbell/dhcp/metrics"
 	oteldhcp "github.com/tinkerbell/dhcp/otel"
+	"github.com/tinkerbell/dhcp/redact"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -35,6 +42,12 @@ func (h *Handler) setDefaults() {
 // Handle responds to DHCP messages with DHCP server options.
 func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p data.Packet) {
 	h.setDefaults()
+	if h.Maintenance() {
+		h.Log.V(1).Info("maintenance mode is enabled, not responding")
+		return
+	}
+	exch := h.exchangeTracker()
+	h = h.current()
 	if p.Pkt == nil {
 		h.Log.Error(errors.New("incoming packet is nil"), "not able to respond when the incoming packet is nil")
 		return
@@ -57,30 +70,61 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p data.Pack
 	if p.Md != nil {
 		ifName = p.Md.IfName
 	}
-	log := h.Log.WithValues("mac", p.Pkt.ClientHWAddr.String(), "xid", p.Pkt.TransactionID.String(), "interface", ifName)
+	log := h.Log.WithValues("mac", h.Redact.MAC(p.Pkt.ClientHWAddr), "xid", p.Pkt.TransactionID.String(), "interface", ifName)
+	// the backends redact the identifiers in their spans and logs like the handler, and can read the message they are
+	// read for.
+	ctx = data.NewContext(redact.NewContext(ctx, h.Redact), p)
+	vendor := h.vendor(p.Pkt.ClientHWAddr)
+	if vendor != "" {
+		log = log.WithValues("vendor", vendor)
+	}
 	tracer := otel.Tracer(tracerName)
+	name := fmt.Sprintf("DHCP Packet Received: %v", p.Pkt.MessageType().String())
+	attrs := func() []attribute.KeyValue {
+		kv := append(h.encodeToAttributes(p.Pkt, oteldhcp.MessageRequest),
+			attribute.String(oteldhcp.KeyPeer, p.Peer.String()),
+			attribute.String(oteldhcp.KeyServerIfName, ifName),
+		)
+		if vendor != "" {
+			kv = append(kv, attribute.String(oteldhcp.KeyVendor, vendor))
+		}
+
+		return kv
+	}
+	now := time.Now()
 	var span trace.Span
-	ctx, span = tracer.Start(
-		ctx,
-		fmt.Sprintf("DHCP Packet Received: %v", p.Pkt.MessageType().String()),
-		trace.WithAttributes(h.encodeToAttributes(p.Pkt, "request")...),
-		trace.WithAttributes(attribute.String("DHCP.peer", p.Peer.String())),
-		trace.WithAttributes(attribute.String("DHCP.server.ifname", ifName)),
-	)
+	if h.Tracing.traced(p.Pkt.MessageType()) {
+		// the spans of the messages of one exchange, for example DISCOVER and REQUEST, are started in one trace.
+		var exchOpts []trace.SpanStartOption
+		ctx, exchOpts = exch.start(ctx, p.Pkt, now)
+		ctx, span = tracer.Start(ctx, name, append(exchOpts, trace.WithAttributes(attrs()...))...)
+		if len(exchOpts) == 0 {
+			exch.record(p.Pkt, span.SpanContext(), now)
+		}
+	} else {
+		ctx = unsampled(ctx)
+		span = trace.SpanFromContext(ctx)
+		if h.Tracing.ErrorsOnly && !h.Tracing.Disabled {
+			span = &errorSpan{Span: span, ctx: ctx, tracer: tracer, name: name, start: now, attrs: attrs}
+		}
+	}
 
 	defer span.End()
+	defer func() {
+		h.slow(log, span, metricsdhcp.StageHandle, time.Since(now), h.SlowThreshold.Handle)
+	}()
+	dec := &decision{}
+	defer h.logDecision(log, p.Pkt, dec)
 
 	var reply *dhcpv4.DHCPv4
+	var nakErr error
 	switch mt := p.Pkt.MessageType(); mt {
 	case dhcpv4.MessageTypeDiscover:
 		d, n, err := h.readBackend(ctx, p.Pkt.ClientHWAddr)
+		dec.read(d, n, err)
 		if err != nil {
-			if hardwareNotFound(err) {
-				span.SetStatus(codes.Ok, "no reservation found")
-				return
-			}
-			log.Info("error reading from backend", "error", err)
-			span.SetStatus(codes.Error, err.Error())
+			dec.outcome = outcomeReadFailed
+			h.readFailed(ctx, log, span, p.Pkt, err)
 
 			return
 		}
@@ -89,15 +133,28 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p data.Pack
 		log = log.WithValues("type", dhcpv4.MessageTypeOffer.String())
 	case dhcpv4.MessageTypeRequest:
 		d, n, err := h.readBackend(ctx, p.Pkt.ClientHWAddr)
+		dec.read(d, n, err)
 		if err != nil {
-			if hardwareNotFound(err) {
-				span.SetStatus(codes.Ok, "no reservation found")
+			if h.ErrorPolicy.action(err) != ActionNAK {
+				dec.outcome = outcomeReadFailed
+				h.readFailed(ctx, log, span, p.Pkt, err)
+
 				return
 			}
-			log.Info("error reading from backend", "error", err)
-			span.SetStatus(codes.Error, err.Error())
+			log.Info("error reading from backend, sending NAK", "error", h.Redact.Error(err))
+			nakErr = err
+			span.SetAttributes(attribute.String(oteldhcp.KeyNAKReason, h.Redact.Error(err)))
+			h.metrics().NAK(metrics.Class(err))
+			if reply, err = h.nak(p.Pkt, "no reservation available"); err != nil {
+				dec.outcome = outcomeNAKFailed
+				log.Error(err, "failed to build DHCP NAK")
+				span.SetStatus(codes.Error, h.Redact.Error(err))
 
-			return
+				return
+			}
+			log = log.WithValues("type", dhcpv4.MessageTypeNak.String())
+
+			break
 		}
 		log.Info("received DHCP packet", "type", p.Pkt.MessageType().String())
 		reply = h.updateMsg(ctx, p.Pkt, d, n, dhcpv4.MessageTypeAck)
@@ -105,19 +162,39 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p data.Pack
 	case dhcpv4.MessageTypeRelease:
 		// Since the design of this DHCP server is that all IP addresses are
 		// Host reservations, when a client releases an address, the server
-		// doesn't have anything to do. This case is included for clarity of this
-		// design decision.
+		// doesn't have anything to do other than record the release.
 		log.Info("received DHCP release packet, no response required, all IPs are host reservations", "type", p.Pkt.MessageType().String())
+		ip, _ := netip.AddrFromSlice(p.Pkt.ClientIPAddr.To4())
+		h.writeBackend(ctx, log, "release", func(ctx context.Context, w handler.BackendWriter) error {
+			return w.RecordRelease(ctx, p.Pkt.ClientHWAddr, ip)
+		})
+		h.notify(ctx, log, h.newEvent(event.TypeRelease, p.Pkt, p.Pkt.ClientIPAddr))
+		dec.outcome = outcomeNoReply
 		span.SetStatus(codes.Ok, "received release, no response required")
 
+		return
+	case dhcpv4.MessageTypeDecline:
+		// A client declines an address when it finds that the address is already in use.
+		// There is no other address to offer, so the decline is only recorded.
+		log.Info("received DHCP decline packet, no response required, all IPs are host reservations", "type", p.Pkt.MessageType().String())
+		ip, _ := netip.AddrFromSlice(p.Pkt.RequestedIPAddress().To4())
+		h.writeBackend(ctx, log, "decline", func(ctx context.Context, w handler.BackendWriter) error {
+			return w.RecordDecline(ctx, p.Pkt.ClientHWAddr, ip)
+		})
+		h.notify(ctx, log, h.newEvent(event.TypeDecline, p.Pkt, p.Pkt.RequestedIPAddress()))
+		dec.outcome = outcomeNoReply
+		span.SetStatus(codes.Ok, "received decline, no response required")
+
 		return
 	default:
+		dec.outcome = outcomeUnknownType
 		log.Info("received unknown message type", "type", p.Pkt.MessageType().String())
 		span.SetStatus(codes.Error, "received unknown message type")
 
 		return
 	}
 
+	dec.reply = reply
 	if bf := reply.BootFileName; bf != "" {
 		log = log.WithValues("bootFileName", bf)
 	}
@@ -126,6 +203,9 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p data.Pack
 	}
 
 	dst := replyDestination(p.Peer, p.Pkt.GatewayIPAddr)
+	if reply.MessageType() == dhcpv4.MessageTypeNak && (p.Md == nil || !p.Md.ReplyToPeer) {
+		dst = nakDestination(reply)
+	}
 	log = log.WithValues("ipAddress", reply.YourIPAddr.String(), "destination", dst.String())
 	cm := &ipv4.ControlMessage{}
 	if p.Md != nil {
@@ -133,17 +213,64 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p data.Pack
 	}
 
 	if _, err := conn.WriteTo(reply.ToBytes(), cm, dst); err != nil {
+		dec.outcome = outcomeSendFailed
 		log.Error(err, "failed to send DHCP")
-		span.SetStatus(codes.Error, err.Error())
+		h.metrics().Error(metricsdhcp.ReasonSend)
+		span.SetStatus(codes.Error, h.Redact.Error(err))
 
 		return
 	}
 
+	dec.outcome = outcomeSent
 	log.Info("sent DHCP response")
-	span.SetAttributes(h.encodeToAttributes(reply, "reply")...)
+	h.metrics().Replied(reply.MessageType())
+	if reply.MessageType() == dhcpv4.MessageTypeAck {
+		ip, _ := netip.AddrFromSlice(reply.YourIPAddr.To4())
+		h.writeBackend(ctx, log, "ack", func(ctx context.Context, w handler.BackendWriter) error {
+			return w.RecordAck(ctx, reply.ClientHWAddr, ip, reply.BootFileName)
+		})
+	}
+	if e, ok := h.replyEvent(reply, nakErr); ok {
+		h.notify(ctx, log, e)
+	}
+	if span.IsRecording() {
+		span.SetAttributes(h.encodeToAttributes(reply, oteldhcp.MessageReply)...)
+	}
 	span.SetStatus(codes.Ok, "sent DHCP response")
 }
 
+// vendor returns the vendor of the NIC of mac, or an empty string when Vendors is not set or does not know it.
+func (h *Handler) vendor(mac net.HardwareAddr) string {
+	if h.Vendors == nil {
+		return ""
+	}
+
+	return h.Vendors.Vendor(mac)
+}
+
+// readFailed logs, records in span, and notifies the backend read error err of the message m that is not replied to.
+func (h *Handler) readFailed(ctx context.Context, log logr.Logger, span trace.Span, m *dhcpv4.DHCPv4, err error) {
+	if !data.IsNotFound(err) {
+		h.metrics().Error(metricsdhcp.ReasonBackend)
+		e := h.newEvent(event.TypeBackendError, m, nil)
+		e.Error = h.Redact.Error(err)
+		h.notify(ctx, log, e)
+	}
+	switch {
+	case data.IsNotFound(err):
+		span.SetStatus(codes.Ok, "no reservation found")
+	case errors.Is(err, ErrReadTimeout):
+		log.Info("backend read timed out, not responding", "error", h.Redact.Error(err))
+		span.SetStatus(codes.Error, ErrReadTimeout.Error())
+	case data.IsInvalidRecord(err):
+		log.Info("backend record is invalid, not responding", "error", h.Redact.Error(err))
+		span.SetStatus(codes.Error, h.Redact.Error(err))
+	default:
+		log.Info("error reading from backend", "error", h.Redact.Error(err))
+		span.SetStatus(codes.Error, h.Redact.Error(err))
+	}
+}
+
 // replyDestination determines the destination address for the DHCP reply.
 // If the giaddr is set, then the reply should be sent to the giaddr.
 // Otherwise, the reply should be sent to the direct peer.
@@ -168,20 +295,93 @@ func (h *Handler) readBackend(ctx context.Context, mac net.HardwareAddr) (*data.
 	ctx, span := tracer.Start(ctx, "Hardware data get")
 	defer span.End()
 
-	d, n, err := h.Backend.GetByMac(ctx, mac)
+	b := h.backend()
+	if h.BackendMetrics != nil {
+		b = h.BackendMetrics.Wrap(fmt.Sprintf("%T", b), b)
+	}
+	start := time.Now()
+	d, n, err := h.getByMac(ctx, b, mac)
+	if err != nil && ctx.Err() == nil && h.ErrorPolicy.action(err) == ActionRetry {
+		span.AddEvent("retrying backend read", trace.WithAttributes(attribute.String("error", h.Redact.Error(err))))
+		d, n, err = h.getByMac(ctx, b, mac)
+	}
+	h.slow(h.Log.WithValues("mac", h.Redact.MAC(mac)), span, metricsdhcp.StageBackendRead, time.Since(start), h.SlowThreshold.Read)
+	if err == nil {
+		err = h.Validation.validate(mac, d, n)
+	}
 	if err != nil {
-		span.SetStatus(codes.Error, err.Error())
+		h.metrics().BackendError(metrics.Class(err))
+		span.SetStatus(codes.Error, h.Redact.Error(err))
 
 		return nil, nil, err
 	}
 
-	span.SetAttributes(d.EncodeToAttributes()...)
+	h.readTracker().last.Store(time.Now().UnixNano())
+	span.SetAttributes(h.Redact.Attributes(d.EncodeToAttributes())...)
 	span.SetAttributes(n.EncodeToAttributes()...)
 	span.SetStatus(codes.Ok, "done reading from backend")
 
 	return d, n, nil
 }
 
+// getByMac reads mac from b, bounded by the read timeout. The read runs in its own goroutine so that a backend
+// that does not honor ctx is abandoned at the timeout. Such a read keeps running in the background until the backend returns.
+func (h *Handler) getByMac(ctx context.Context, b handler.BackendReader, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	timeout := h.ReadTimeout
+	if timeout == 0 {
+		timeout = DefaultReadTimeout
+	}
+	if timeout < 0 {
+		return b.GetByMac(ctx, mac)
+	}
+	rctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		d   *data.DHCP
+		n   *data.Netboot
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		d, n, err := b.GetByMac(rctx, mac)
+		done <- result{d: d, n: n, err: err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil && rctx.Err() != nil && ctx.Err() == nil {
+			return nil, nil, fmt.Errorf("%w after %v: %w", ErrReadTimeout, timeout, r.err)
+		}
+		return r.d, r.n, r.err
+	case <-rctx.Done():
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("%w after %v: %w", ErrReadTimeout, timeout, rctx.Err())
+	}
+}
+
+// writeBackend encapsulates recording a lease event with the Writer and opentelemetry handling.
+// It does nothing when no Writer is configured. Errors are only logged, they never change the DHCP response.
+func (h *Handler) writeBackend(ctx context.Context, log logr.Logger, event string, record func(context.Context, handler.BackendWriter) error) {
+	if h.Writer == nil {
+		return
+	}
+
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "Hardware data record", trace.WithAttributes(attribute.String(oteldhcp.KeyEvent, event)))
+	defer span.End()
+
+	if err := record(ctx, h.Writer); err != nil {
+		log.Info("error recording to backend", "event", event, "error", h.Redact.Error(err))
+		span.SetStatus(codes.Error, h.Redact.Error(err))
+
+		return
+	}
+
+	span.SetStatus(codes.Ok, "done recording to backend")
+}
+
 // updateMsg handles updating DHCP packets with the data from the backend.
 func (h *Handler) updateMsg(ctx context.Context, pkt *dhcpv4.DHCPv4, d *data.DHCP, n *data.Netboot, msgType dhcpv4.MessageType) *dhcpv4.DHCPv4 {
 	h.setDefaults()
@@ -258,11 +458,11 @@ func (h *Handler) isNetbootClient(pkt *dhcpv4.DHCPv4) error {
 		// well accept these buggy ROMs.
 	case 17:
 		if guid[0] != 0 {
-			h.Log.Info("not a netboot client", "reason", "option 97 does not start with 0", "mac", pkt.ClientHWAddr.String(), "option 97", string(guid))
+			h.Log.Info("not a netboot client", "reason", "option 97 does not start with 0", "mac", h.Redact.MAC(pkt.ClientHWAddr), "option 97", string(guid))
 			err = fmt.Errorf("%w: option 97 does not start with 0", err)
 		}
 	default:
-		h.Log.Info("not a netboot client", "reason", "option 97 has invalid length (0 or 17)", "mac", pkt.ClientHWAddr.String(), "option 97", string(guid))
+		h.Log.Info("not a netboot client", "reason", "option 97 has invalid length (0 or 17)", "mac", h.Redact.MAC(pkt.ClientHWAddr), "option 97", string(guid))
 		err = fmt.Errorf("%w: option 97 has invalid length (0 or 17)", err)
 	}
 
@@ -274,14 +474,5 @@ func (h *Handler) encodeToAttributes(d *dhcpv4.DHCPv4, namespace string) []attri
 	h.setDefaults()
 	a := &oteldhcp.Encoder{Log: h.Log}
 
-	return a.Encode(d, namespace, oteldhcp.AllEncoders()...)
-}
-
-// hardwareNotFound returns true if the error is from a hardware record not being found.
-func hardwareNotFound(err error) bool {
-	type hardwareNotFound interface {
-		NotFound() bool
-	}
-	te, ok := err.(hardwareNotFound)
-	return ok && te.NotFound()
+	return h.Redact.Attributes(a.Encode(d, namespace, oteldhcp.AllEncoders()...))
 }
@@ -1,6 +1,7 @@
 package reservation
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"net/netip"
 	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -19,8 +21,18 @@ import (
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/iana"
 	"github.com/insomniacslk/dhcp/rfc1035label"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/dhcp/backend/metrics"
+	"github.com/tinkerbell/dhcp/backend/mock"
 	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/event"
+	"github.com/tinkerbell/dhcp/handler"
+	metricsdhcp "github.com/tinkerbell/dhcp/metrics"
 	"github.com/tinkerbell/dhcp/otel"
+	"github.com/tinkerbell/dhcp/oui"
+	"github.com/tinkerbell/dhcp/redact"
+	"github.com/tonglil/buflogr"
 	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/nettest"
@@ -28,30 +40,18 @@ import (
 
 var errBadBackend = fmt.Errorf("bad backend")
 
-type mockBackend struct {
-	err              error
-	allowNetboot     bool
-	ipxeScript       *url.URL
-	hardwareNotFound bool
-}
-
 type hwNotFoundError struct{}
 
 func (hwNotFoundError) NotFound() bool { return true }
 func (hwNotFoundError) Error() string  { return "not found" }
 
-func (m *mockBackend) GetByMac(context.Context, net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
-	if m.err != nil {
-		return nil, nil, m.err
-	}
-	if m.hardwareNotFound {
-		return nil, nil, hwNotFoundError{}
-	}
-	d := &data.DHCP{
-		MACAddress:     []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
-		IPAddress:      netip.MustParseAddr("192.168.1.100"),
-		SubnetMask:     []byte{255, 255, 255, 0},
-		DefaultGateway: netip.MustParseAddr("192.168.1.1"),
+// testDHCP returns the record that the mock backends of the tests serve.
+func testDHCP() *data.DHCP {
+	return &data.DHCP{
+		MACAddress:      []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+		IPAddress:       netip.MustParseAddr("192.168.1.100"),
+		SubnetMask:      []byte{255, 255, 255, 0},
+		DefaultGateways: []netip.Addr{netip.MustParseAddr("192.168.1.1")},
 		NameServers: []net.IP{
 			{1, 1, 1, 1},
 		},
@@ -66,19 +66,16 @@ func (m *mockBackend) GetByMac(context.Context, net.HardwareAddr) (*data.DHCP, *
 			"mydomain.com",
 		},
 	}
-	n := &data.Netboot{
-		AllowNetboot:  m.allowNetboot,
-		IPXEScriptURL: m.ipxeScript,
-	}
+}
 
-	return d, n, m.err
+// newMockBackend returns a mock backend that serves testDHCP and n for every MAC address.
+func newMockBackend(n data.Netboot) *mock.Backend {
+	return mock.New(mock.WithDefault(mock.Response{DHCP: testDHCP(), Netboot: &n}))
 }
 
-func (m *mockBackend) GetByIP(context.Context, net.IP) (*data.DHCP, *data.Netboot, error) {
-	if m.hardwareNotFound {
-		return nil, nil, hwNotFoundError{}
-	}
-	return nil, nil, errors.New("not implemented")
+// failingBackend returns a mock backend whose reads fail with err.
+func failingBackend(err error) *mock.Backend {
+	return mock.New(mock.WithDefault(mock.Response{Err: err}))
 }
 
 func TestHandle(t *testing.T) {
@@ -91,10 +88,7 @@ func TestHandle(t *testing.T) {
 	}{
 		"success discover message type with netboot options": {
 			server: Handler{
-				Backend: &mockBackend{
-					allowNetboot: true,
-					ipxeScript:   &url.URL{Scheme: "http", Host: "localhost:8181", Path: "auto.ipxe"},
-				},
+				Backend: newMockBackend(data.Netboot{AllowNetboot: true, IPXEScriptURL: &url.URL{Scheme: "http", Host: "localhost:8181", Path: "auto.ipxe"}}),
 				IPAddr:  netip.MustParseAddr("127.0.0.1"),
 				Netboot: Netboot{Enabled: true},
 			},
@@ -131,6 +125,7 @@ func TestHandle(t *testing.T) {
 					dhcpv4.OptNTPServers([]net.IP{{132, 163, 96, 2}}...),
 					dhcpv4.OptDomainSearch(&rfc1035label.Labels{Labels: []string{"mydomain.com"}}),
 					dhcpv4.OptClassIdentifier("HTTPClient"),
+					dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, []byte{0x00, 0x02, 0x03, 0x04, 0x05, 0x06, 0x00, 0x02, 0x03, 0x04, 0x05, 0x06, 0x00, 0x02, 0x03, 0x04, 0x05}),
 					dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{
 						6:  []byte{8},
 						69: otel.TraceparentFromContext(context.Background()),
@@ -140,7 +135,7 @@ func TestHandle(t *testing.T) {
 		},
 		"failure discover message type": {
 			server: Handler{
-				Backend: &mockBackend{err: errBadBackend},
+				Backend: failingBackend(errBadBackend),
 				IPAddr:  netip.MustParseAddr("127.0.0.1"),
 			},
 			req: &dhcpv4.DHCPv4{
@@ -154,10 +149,7 @@ func TestHandle(t *testing.T) {
 		},
 		"success request message type with netboot options": {
 			server: Handler{
-				Backend: &mockBackend{
-					allowNetboot: true,
-					ipxeScript:   &url.URL{Scheme: "http", Host: "localhost:8181", Path: "auto.ipxe"},
-				},
+				Backend: newMockBackend(data.Netboot{AllowNetboot: true, IPXEScriptURL: &url.URL{Scheme: "http", Host: "localhost:8181", Path: "auto.ipxe"}}),
 				Netboot: Netboot{Enabled: true},
 				IPAddr:  netip.MustParseAddr("127.0.0.1"),
 			},
@@ -208,6 +200,7 @@ func TestHandle(t *testing.T) {
 					dhcpv4.OptNTPServers([]net.IP{{132, 163, 96, 2}}...),
 					dhcpv4.OptDomainSearch(&rfc1035label.Labels{Labels: []string{"mydomain.com"}}),
 					dhcpv4.OptClassIdentifier("HTTPClient"),
+					dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, []byte{0x00, 0x02, 0x03, 0x04, 0x05, 0x06, 0x00, 0x02, 0x03, 0x04, 0x05, 0x06, 0x00, 0x02, 0x03, 0x04, 0x05}),
 					dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{
 						6:  []byte{8},
 						69: otel.TraceparentFromContext(context.Background()),
@@ -217,7 +210,7 @@ func TestHandle(t *testing.T) {
 		},
 		"failure request message type": {
 			server: Handler{
-				Backend: &mockBackend{err: errBadBackend},
+				Backend: failingBackend(errBadBackend),
 				IPAddr:  netip.MustParseAddr("127.0.0.1"),
 			},
 			req: &dhcpv4.DHCPv4{
@@ -231,7 +224,7 @@ func TestHandle(t *testing.T) {
 		},
 		"request release type": {
 			server: Handler{
-				Backend: &mockBackend{err: errBadBackend},
+				Backend: failingBackend(errBadBackend),
 				IPAddr:  netip.MustParseAddr("127.0.0.1"),
 			},
 			req: &dhcpv4.DHCPv4{
@@ -245,7 +238,7 @@ func TestHandle(t *testing.T) {
 		},
 		"unknown message type": {
 			server: Handler{
-				Backend: &mockBackend{err: errBadBackend},
+				Backend: failingBackend(errBadBackend),
 				IPAddr:  netip.MustParseAddr("127.0.0.1"),
 			},
 			req: &dhcpv4.DHCPv4{
@@ -259,7 +252,7 @@ func TestHandle(t *testing.T) {
 		},
 		"fail WriteTo": {
 			server: Handler{
-				Backend: &mockBackend{},
+				Backend: newMockBackend(data.Netboot{}),
 			},
 			req: &dhcpv4.DHCPv4{
 				OpCode:       dhcpv4.OpcodeBootRequest,
@@ -277,7 +270,7 @@ func TestHandle(t *testing.T) {
 		},
 		"failure no hardware found discover": {
 			server: Handler{
-				Backend: &mockBackend{hardwareNotFound: true},
+				Backend: failingBackend(hwNotFoundError{}),
 				IPAddr:  netip.MustParseAddr("127.0.0.1"),
 			},
 			req: &dhcpv4.DHCPv4{
@@ -292,7 +285,7 @@ func TestHandle(t *testing.T) {
 		},
 		"failure no hardware found request": {
 			server: Handler{
-				Backend: &mockBackend{hardwareNotFound: true},
+				Backend: failingBackend(hwNotFoundError{}),
 				IPAddr:  netip.MustParseAddr("127.0.0.1"),
 			},
 			req: &dhcpv4.DHCPv4{
@@ -346,6 +339,66 @@ func TestHandle(t *testing.T) {
 	}
 }
 
+// FuzzHandle feeds arbitrary messages, that parse as DHCPv4 messages, to a handler that netboots every client.
+// Handle must not panic, whatever the message type, options, and client hardware address are.
+func FuzzHandle(f *testing.F) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	netboot := []dhcpv4.Modifier{
+		dhcpv4.WithOption(dhcpv4.OptClassIdentifier("PXEClient:Arch:00007:UNDI:003016")),
+		dhcpv4.WithOption(dhcpv4.OptClientArch(iana.EFI_X86_64)),
+		dhcpv4.WithGeneric(dhcpv4.OptionClientNetworkInterfaceIdentifier, []byte{0x01, 0x03, 0x10}),
+		dhcpv4.WithGeneric(dhcpv4.OptionClientMachineIdentifier, make([]byte, 17)),
+		dhcpv4.WithGeneric(dhcpv4.OptionUserClassInformation, []byte("iPXE")),
+	}
+	for _, mt := range []dhcpv4.MessageType{
+		dhcpv4.MessageTypeDiscover, dhcpv4.MessageTypeRequest, dhcpv4.MessageTypeRelease, dhcpv4.MessageTypeDecline, dhcpv4.MessageTypeInform,
+	} {
+		m, err := dhcpv4.New(append(netboot, dhcpv4.WithHwAddr(mac), dhcpv4.WithMessageType(mt), dhcpv4.WithOption(dhcpv4.OptRequestedIPAddress(net.IP{192, 168, 1, 100})))...)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(m.ToBytes())
+	}
+	relayed, err := dhcpv4.NewDiscovery(mac, dhcpv4.WithGatewayIP(net.IP{192, 168, 1, 1}),
+		dhcpv4.WithOption(dhcpv4.OptRelayAgentInfo(dhcpv4.OptGeneric(dhcpv4.AgentCircuitIDSubOption, []byte("eth0")))))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(relayed.ToBytes())
+	// a GUID that is not 17 bytes, and an architecture option of an odd length.
+	odd, err := dhcpv4.NewDiscovery(mac, dhcpv4.WithGeneric(dhcpv4.OptionClientMachineIdentifier, []byte{0x01}),
+		dhcpv4.WithGeneric(dhcpv4.OptionClientSystemArchitectureType, []byte{0x00}))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(odd.ToBytes())
+
+	conn, err := nettest.NewLocalPacketListener("udp")
+	if err != nil {
+		f.Fatal(err)
+	}
+	defer conn.Close()
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		f.Fatal(err)
+	}
+	defer pc.Close()
+	s := &Handler{
+		Log:     logr.Discard(),
+		Backend: newMockBackend(data.Netboot{AllowNetboot: true, IPXEScriptURL: &url.URL{Scheme: "http", Host: "localhost:8181", Path: "auto.ipxe"}}),
+		IPAddr:  netip.MustParseAddr("127.0.0.1"),
+		Netboot: Netboot{Enabled: true, IPXEBinServerTFTP: netip.MustParseAddrPort("127.0.0.1:69")},
+	}
+	con := ipv4.NewPacketConn(conn)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		m, err := dhcpv4.FromBytes(b)
+		if err != nil {
+			return
+		}
+		s.Handle(context.Background(), con, data.Packet{Peer: pc.LocalAddr(), Pkt: m, Md: &data.Metadata{IfName: "lo"}})
+	})
+}
+
 func client(pc net.PacketConn) (*dhcpv4.DHCPv4, error) {
 	buf := make([]byte, 1024)
 	pc.SetReadDeadline(time.Now().Add(time.Millisecond * 100))
@@ -402,6 +455,7 @@ func TestUpdateMsg(t *testing.T) {
 					dhcpv4.OptIPAddressLeaseTime(3600),
 					dhcpv4.OptSubnetMask(net.IPMask(net.IP{255, 255, 255, 0}.To4())),
 					dhcpv4.OptClassIdentifier("HTTPClient"),
+					dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, []byte{0x00, 0x02, 0x03, 0x04, 0x05, 0x06, 0x00, 0x02, 0x03, 0x04, 0x05, 0x06, 0x00, 0x02, 0x03, 0x04, 0x05}),
 					dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{
 						6:  []byte{8},
 						69: otel.TraceparentFromContext(context.Background()),
@@ -418,10 +472,7 @@ func TestUpdateMsg(t *testing.T) {
 				Netboot: Netboot{
 					Enabled: true,
 				},
-				Backend: &mockBackend{
-					allowNetboot: true,
-					ipxeScript:   &url.URL{Scheme: "http", Host: "localhost:8181", Path: "auto.ipxe"},
-				},
+				Backend: newMockBackend(data.Netboot{AllowNetboot: true, IPXEScriptURL: &url.URL{Scheme: "http", Host: "localhost:8181", Path: "auto.ipxe"}}),
 				// Listener: netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), 67),
 			}
 			got := s.updateMsg(context.Background(), tt.args.m, tt.args.data, tt.args.netboot, tt.args.msg)
@@ -432,6 +483,112 @@ func TestUpdateMsg(t *testing.T) {
 	}
 }
 
+// mockWriter records the lease events it is called with.
+type mockWriter struct {
+	events []string
+	err    error
+}
+
+func (m *mockWriter) RecordAck(_ context.Context, mac net.HardwareAddr, ip netip.Addr, bootfile string) error {
+	m.events = append(m.events, fmt.Sprintf("ack %v %v %v", mac, ip, bootfile))
+	return m.err
+}
+
+func (m *mockWriter) RecordRelease(_ context.Context, mac net.HardwareAddr, ip netip.Addr) error {
+	m.events = append(m.events, fmt.Sprintf("release %v %v", mac, ip))
+	return m.err
+}
+
+func (m *mockWriter) RecordDecline(_ context.Context, mac net.HardwareAddr, ip netip.Addr) error {
+	m.events = append(m.events, fmt.Sprintf("decline %v %v", mac, ip))
+	return m.err
+}
+
+func TestHandleWriter(t *testing.T) {
+	tests := map[string]struct {
+		req        *dhcpv4.DHCPv4
+		writerErr  error
+		wantEvents []string
+		wantReply  bool
+	}{
+		"discover is not recorded": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover)),
+			},
+			wantReply: true,
+		},
+		"ack": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest)),
+			},
+			wantEvents: []string{"ack 01:02:03:04:05:06 192.168.1.100 "},
+			wantReply:  true,
+		},
+		"writer error does not change the reply": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest)),
+			},
+			writerErr:  errBadBackend,
+			wantEvents: []string{"ack 01:02:03:04:05:06 192.168.1.100 "},
+			wantReply:  true,
+		},
+		"release": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				ClientIPAddr: []byte{192, 168, 1, 100},
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeRelease)),
+			},
+			wantEvents: []string{"release 01:02:03:04:05:06 192.168.1.100"},
+		},
+		"decline": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptMessageType(dhcpv4.MessageTypeDecline),
+					dhcpv4.OptRequestedIPAddress(net.IP{192, 168, 1, 100}),
+				),
+			},
+			wantEvents: []string{"decline 01:02:03:04:05:06 192.168.1.100"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := &mockWriter{err: tt.writerErr}
+			s := Handler{Backend: newMockBackend(data.Netboot{}), Writer: w, IPAddr: netip.MustParseAddr("127.0.0.1")}
+			conn, err := nettest.NewLocalPacketListener("udp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			pc, err := net.ListenPacket("udp4", ":0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pc.Close()
+			peer := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: pc.LocalAddr().(*net.UDPAddr).Port}
+
+			s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: peer, Pkt: tt.req})
+
+			_, err = client(pc)
+			if gotReply := err == nil; gotReply != tt.wantReply {
+				t.Fatalf("got reply = %v, want %v", gotReply, tt.wantReply)
+			}
+			if diff := cmp.Diff(w.events, tt.wantEvents); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
 func TestOne(t *testing.T) {
 	t.Skip()
 	h := &Handler{}
@@ -463,7 +620,7 @@ func TestReadBackend(t *testing.T) {
 				MACAddress:       []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
 				IPAddress:        netip.MustParseAddr("192.168.1.100"),
 				SubnetMask:       []byte{255, 255, 255, 0},
-				DefaultGateway:   netip.MustParseAddr("192.168.1.1"),
+				DefaultGateways:  []netip.Addr{netip.MustParseAddr("192.168.1.1")},
 				NameServers:      []net.IP{{1, 1, 1, 1}},
 				Hostname:         "test-host",
 				DomainName:       "mydomain.com",
@@ -488,11 +645,7 @@ func TestReadBackend(t *testing.T) {
 				Netboot: Netboot{
 					Enabled: true,
 				},
-				Backend: &mockBackend{
-					err:          tt.wantErr,
-					allowNetboot: true,
-					ipxeScript:   &url.URL{Scheme: "http", Host: "localhost:8181", Path: "auto.ipxe"},
-				},
+				Backend: mock.New(mock.WithDefault(mock.Response{DHCP: testDHCP(), Netboot: &data.Netboot{AllowNetboot: true, IPXEScriptURL: &url.URL{Scheme: "http", Host: "localhost:8181", Path: "auto.ipxe"}}, Err: tt.wantErr})),
 				// Listener: netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), 67),
 			}
 			netaddrComparer := cmp.Comparer(func(x, y netip.Addr) bool {
@@ -513,6 +666,96 @@ func TestReadBackend(t *testing.T) {
 	}
 }
 
+func TestReadBackendMetrics(t *testing.T) {
+	r := prometheus.NewRegistry()
+	m, err := metrics.New(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Handler{Backend: newMockBackend(data.Netboot{}), BackendMetrics: m}
+	if _, _, err := s.readBackend(context.Background(), net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}); err != nil {
+		t.Fatal(err)
+	}
+	s.Backend = failingBackend(errBadBackend)
+	if _, _, err := s.readBackend(context.Background(), net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}); !errors.Is(err, errBadBackend) {
+		t.Fatalf("readBackend() error = %v, want %v", err, errBadBackend)
+	}
+
+	want := `
+# HELP dhcp_backend_errors_total Number of failed backend reads by error class.
+# TYPE dhcp_backend_errors_total counter
+dhcp_backend_errors_total{backend="*mock.Backend",class="other",method="GetByMac"} 1
+# HELP dhcp_backend_requests_total Number of backend reads.
+# TYPE dhcp_backend_requests_total counter
+dhcp_backend_requests_total{backend="*mock.Backend",method="GetByMac"} 2
+`
+	if err := testutil.GatherAndCompare(r, strings.NewReader(want), "dhcp_backend_requests_total", "dhcp_backend_errors_total"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	r := prometheus.NewRegistry()
+	m, err := metricsdhcp.New(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Handler{Backend: newMockBackend(data.Netboot{}), IPAddr: netip.MustParseAddr("127.0.0.1"), Metrics: m}
+	conn, err := nettest.NewLocalPacketListener("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	pc, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	peer := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: pc.LocalAddr().(*net.UDPAddr).Port}
+	req := &dhcpv4.DHCPv4{
+		OpCode:       dhcpv4.OpcodeBootRequest,
+		ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+		Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover)),
+	}
+
+	s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: peer, Pkt: req})
+	if _, err := client(pc); err != nil {
+		t.Fatal(err)
+	}
+	s.Backend = failingBackend(errBadBackend)
+	s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: peer, Pkt: req})
+
+	want := `
+# HELP dhcp_errors_total Number of DHCP messages that were not handled or replied to, by reason.
+# TYPE dhcp_errors_total counter
+dhcp_errors_total{reason="backend"} 1
+# HELP dhcp_replies_sent_total Number of DHCP replies sent, by message type.
+# TYPE dhcp_replies_sent_total counter
+dhcp_replies_sent_total{type="OFFER"} 1
+`
+	if err := testutil.GatherAndCompare(r, strings.NewReader(want), "dhcp_replies_sent_total", "dhcp_errors_total"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Backend = failingBackend(hwNotFoundError{})
+	s.ErrorPolicy = ErrorPolicy{NotFound: ActionNAK, Authoritative: true}
+	req.Options = dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest))
+	s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: peer, Pkt: req})
+
+	want = `
+# HELP dhcp_handler_backend_errors_total Number of backend reads that failed while handling a DHCP message, by error class.
+# TYPE dhcp_handler_backend_errors_total counter
+dhcp_handler_backend_errors_total{class="not_found"} 1
+dhcp_handler_backend_errors_total{class="other"} 1
+# HELP dhcp_naks_total Number of DHCP NAKs replied to requests, by the class of the backend error that caused them.
+# TYPE dhcp_naks_total counter
+dhcp_naks_total{class="not_found"} 1
+`
+	if err := testutil.GatherAndCompare(r, strings.NewReader(want), "dhcp_handler_backend_errors_total", "dhcp_naks_total"); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestIsNetbootClient(t *testing.T) {
 	tests := map[string]struct {
 		input *dhcpv4.DHCPv4
@@ -627,3 +870,325 @@ func TestReplyDestination(t *testing.T) {
 		})
 	}
 }
+
+// slowBackend is a backend that takes delay to read and ignores the context.
+type slowBackend struct {
+	*mock.Backend
+	delay time.Duration
+}
+
+func (s *slowBackend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	time.Sleep(s.delay)
+
+	return s.Backend.GetByMac(ctx, mac)
+}
+
+func TestReadBackendTimeout(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	// delayed returns a mock backend that takes d to read, and honors the context.
+	delayed := func(d time.Duration) *mock.Backend {
+		return mock.New(mock.WithDefault(mock.Response{DHCP: testDHCP(), Netboot: &data.Netboot{}}), mock.WithDelay(d))
+	}
+	tests := map[string]struct {
+		ctx     context.Context
+		backend handler.BackendReader
+		timeout time.Duration
+		wantErr error
+	}{
+		"fast backend":                   {ctx: context.Background(), backend: newMockBackend(data.Netboot{}), timeout: time.Second},
+		"backend honors the context":     {ctx: context.Background(), backend: delayed(time.Second), timeout: 10 * time.Millisecond, wantErr: ErrReadTimeout},
+		"backend ignores the context":    {ctx: context.Background(), backend: &slowBackend{Backend: newMockBackend(data.Netboot{}), delay: time.Second}, timeout: 10 * time.Millisecond, wantErr: ErrReadTimeout},
+		"canceled is not a timeout":      {ctx: canceled, backend: delayed(time.Second), timeout: time.Second, wantErr: context.Canceled},
+		"negative timeout disables it":   {ctx: context.Background(), backend: delayed(20 * time.Millisecond), timeout: -1},
+		"backend error is not a timeout": {ctx: context.Background(), backend: failingBackend(errBadBackend), timeout: time.Second, wantErr: errBadBackend},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{Backend: tt.backend, ReadTimeout: tt.timeout}
+			start := time.Now()
+			_, _, err := h.readBackend(tt.ctx, net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readBackend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(tt.wantErr, ErrReadTimeout) {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("readBackend() error = %v, want it to wrap %v", err, context.DeadlineExceeded)
+				}
+				if d := time.Since(start); d > 500*time.Millisecond {
+					t.Fatalf("readBackend() took %v, want it to return at the timeout", d)
+				}
+			}
+		})
+	}
+}
+
+// namedBackend is a backend that serves hostname, to tell backends apart.
+type namedBackend struct {
+	*mock.Backend
+	hostname string
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func (n *namedBackend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	if n.started != nil {
+		n.started <- struct{}{}
+		<-n.release
+	}
+	d, nb, err := n.Backend.GetByMac(ctx, mac)
+	if err != nil {
+		return nil, nil, err
+	}
+	d.Hostname = n.hostname
+
+	return d, nb, nil
+}
+
+func TestSwapBackend(t *testing.T) {
+	ctx := context.Background()
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	old := &namedBackend{Backend: newMockBackend(data.Netboot{}), hostname: "old", started: make(chan struct{}), release: make(chan struct{})}
+	h := &Handler{Backend: old}
+
+	// a read in flight when the backend is swapped finishes with the old backend.
+	type result struct {
+		hostname string
+		err      error
+	}
+	inFlight := make(chan result)
+	go func() {
+		d, _, err := h.readBackend(ctx, mac)
+		if err != nil {
+			inFlight <- result{err: err}
+			return
+		}
+		inFlight <- result{hostname: d.Hostname}
+	}()
+	<-old.started
+
+	h.SwapBackend(&namedBackend{Backend: newMockBackend(data.Netboot{}), hostname: "new"})
+	d, _, err := h.readBackend(ctx, mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("new", d.Hostname); diff != "" {
+		t.Fatal(diff)
+	}
+
+	close(old.release)
+	r := <-inFlight
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if diff := cmp.Diff("old", r.hostname); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// swapping to nil reverts to Backend.
+	h.SwapBackend(nil)
+	old.started = nil
+	d, _, err = h.readBackend(ctx, mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("old", d.Hostname); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestHandleNotifier(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	tests := map[string]struct {
+		req     *dhcpv4.DHCPv4
+		backend *mock.Backend
+		policy  ErrorPolicy
+		redact  redact.Redactor
+		want    []event.Event
+	}{
+		"offer": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: mac,
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover)),
+			},
+			backend: newMockBackend(data.Netboot{}),
+			want:    []event.Event{{Type: event.TypeOffer, MAC: mac.String(), TransactionID: "0x00000000", IP: "192.168.1.100", Hostname: "test-host"}},
+		},
+		"ack": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: mac,
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest)),
+			},
+			backend: newMockBackend(data.Netboot{}),
+			want:    []event.Event{{Type: event.TypeAck, MAC: mac.String(), TransactionID: "0x00000000", IP: "192.168.1.100", Hostname: "test-host"}},
+		},
+		"nak": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: mac,
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest)),
+			},
+			backend: failingBackend(fmt.Errorf("%w: no lease", data.ErrInvalidRecord)),
+			policy:  ErrorPolicy{Authoritative: true, InvalidRecord: ActionNAK},
+			want:    []event.Event{{Type: event.TypeNAK, MAC: mac.String(), TransactionID: "0x00000000", Error: "invalid record: no lease"}},
+		},
+		"release": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: mac,
+				ClientIPAddr: []byte{192, 168, 1, 100},
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeRelease)),
+			},
+			backend: newMockBackend(data.Netboot{}),
+			want:    []event.Event{{Type: event.TypeRelease, MAC: mac.String(), TransactionID: "0x00000000", IP: "192.168.1.100"}},
+		},
+		"decline": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: mac,
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptMessageType(dhcpv4.MessageTypeDecline),
+					dhcpv4.OptRequestedIPAddress(net.IP{192, 168, 1, 100}),
+				),
+			},
+			backend: newMockBackend(data.Netboot{}),
+			want:    []event.Event{{Type: event.TypeDecline, MAC: mac.String(), TransactionID: "0x00000000", IP: "192.168.1.100"}},
+		},
+		"backend error": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: mac,
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover)),
+			},
+			backend: failingBackend(errBadBackend),
+			want:    []event.Event{{Type: event.TypeBackendError, MAC: mac.String(), TransactionID: "0x00000000", Error: "bad backend"}},
+		},
+		"redacted": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: mac,
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover)),
+			},
+			backend: newMockBackend(data.Netboot{}),
+			redact:  redact.Redactor{Mode: redact.ModeTruncate},
+			want:    []event.Event{{Type: event.TypeOffer, MAC: "01:02:03:***", TransactionID: "0x00000000", IP: "192.168.1.100", Hostname: "te***"}},
+		},
+		"redacted backend error": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: mac,
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover)),
+			},
+			backend: failingBackend(fmt.Errorf("no hardware for %v", mac)),
+			redact:  redact.Redactor{Mode: redact.ModeTruncate},
+			want:    []event.Event{{Type: event.TypeBackendError, MAC: "01:02:03:***", TransactionID: "0x00000000", Error: "no hardware for 01:02:03:***"}},
+		},
+		"not found is not notified": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: mac,
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover)),
+			},
+			backend: failingBackend(hwNotFoundError{}),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got []event.Event
+			n := event.NotifierFunc(func(_ context.Context, e event.Event) error {
+				got = append(got, e)
+				return errors.New("notifier errors are only logged")
+			})
+			s := Handler{Backend: tt.backend, Notifier: n, ErrorPolicy: tt.policy, Redact: tt.redact, IPAddr: netip.MustParseAddr("127.0.0.1")}
+			conn, err := nettest.NewLocalPacketListener("udp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			pc, err := net.ListenPacket("udp4", ":0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pc.Close()
+			peer := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: pc.LocalAddr().(*net.UDPAddr).Port}
+
+			s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: peer, Pkt: tt.req})
+
+			if diff := cmp.Diff(tt.want, got, cmpopts.IgnoreFields(event.Event{}, "Time")); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestHandleVendor(t *testing.T) {
+	vendors, err := oui.Parse(strings.NewReader("01-02-03   (hex)\t\tTest Vendor\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		vendors oui.Lookup
+		want    bool
+	}{
+		"vendor is logged": {vendors: vendors, want: true},
+		"no vendors":       {},
+		"unknown vendor":   {vendors: &oui.DB{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			s := Handler{Backend: newMockBackend(data.Netboot{}), Vendors: tt.vendors, Log: buflogr.NewWithBuffer(&buf), IPAddr: netip.MustParseAddr("127.0.0.1")}
+			conn, err := nettest.NewLocalPacketListener("udp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			pkt := &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover)),
+			}
+
+			s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: 68}, Pkt: pkt})
+
+			if got := strings.Contains(buf.String(), "vendor Test Vendor"); got != tt.want {
+				t.Fatalf("vendor logged = %v, want %v: %s", got, tt.want, buf.String())
+			}
+		})
+	}
+}
+
+// checkedBackend is a backend with a health check.
+type checkedBackend struct {
+	*mock.Backend
+	healthErr error
+}
+
+func (c *checkedBackend) Healthy(context.Context) error { return c.healthErr }
+
+func TestHealth(t *testing.T) {
+	h := &Handler{Backend: &checkedBackend{Backend: newMockBackend(data.Netboot{}), healthErr: errBadBackend}, IPAddr: netip.MustParseAddr("127.0.0.1")}
+	if got := h.Health(context.Background()); !errors.Is(got.Backend, errBadBackend) || !got.LastRead.IsZero() {
+		t.Fatalf("Health() = %+v, want the backend error and no read", got)
+	}
+
+	// reads of a reloaded handler are reported too.
+	if err := h.Reload(h.Config()); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, _, err := h.current().readBackend(context.Background(), net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}); err != nil {
+		t.Fatal(err)
+	}
+	h.SwapBackend(newMockBackend(data.Netboot{}))
+	got := h.Health(context.Background())
+	if got.Backend != nil {
+		t.Fatalf("Health() backend = %v, want nil for a backend without a health check", got.Backend)
+	}
+	if got.LastRead.Before(start) {
+		t.Fatalf("Health() last read = %v, want after %v", got.LastRead, start)
+	}
+}
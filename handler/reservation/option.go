@@ -2,17 +2,19 @@ package reservation
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 	"net"
 	"net/netip"
 	"net/url"
+	"slices"
 	"strings"
 
 	"github.com/equinix-labs/otel-init-go/otelhelpers"
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/iana"
+	"github.com/insomniacslk/dhcp/rfc1035label"
 	"github.com/tinkerbell/dhcp/data"
-	"github.com/tinkerbell/dhcp/otel"
 )
 
 // UserClass is DHCP option 77 (https://www.rfc-editor.org/rfc/rfc3004.html).
@@ -62,6 +64,10 @@ var ArchToBootFile = map[iana.Arch]string{
 	iana.Arch(41):          "snp.efi", // arm rpiboot: https://www.iana.org/assignments/dhcpv6-parameters/dhcpv6-parameters.xhtml#processor-architecture
 }
 
+// optionProxyAutoConfig is DHCP option 252, the URL of a proxy auto-config (WPAD) file.
+// It is not a standard option, but Windows and most browsers request it.
+const optionProxyAutoConfig = dhcpv4.GenericOptionCode(252)
+
 // String function for clientType.
 func (c clientType) String() string {
 	return string(c)
@@ -83,8 +89,8 @@ func (h *Handler) setDHCPOpts(_ context.Context, _ *dhcpv4.DHCPv4, d *data.DHCP)
 	if len(d.NameServers) > 0 {
 		mods = append(mods, dhcpv4.WithDNS(d.NameServers...))
 	}
-	if len(d.DomainSearch) > 0 {
-		mods = append(mods, dhcpv4.WithDomainSearchList(d.DomainSearch...))
+	if s := d.SearchDomains(); len(s) > 0 {
+		mods = append(mods, dhcpv4.WithDomainSearchList(s...))
 	}
 	if len(d.NTPServers) > 0 {
 		mods = append(mods, dhcpv4.WithOption(dhcpv4.OptNTPServers(d.NTPServers...)))
@@ -92,8 +98,9 @@ func (h *Handler) setDHCPOpts(_ context.Context, _ *dhcpv4.DHCPv4, d *data.DHCP)
 	if d.BroadcastAddress.Compare(netip.Addr{}) != 0 {
 		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionBroadcastAddress, d.BroadcastAddress.AsSlice()))
 	}
-	if d.DomainName != "" {
-		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionDomainName, []byte(d.DomainName)))
+	// without a domain name, option 15 is the first search domain.
+	if dn := d.Domain(); dn != "" {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionDomainName, []byte(dn)))
 	}
 	if d.Hostname != "" {
 		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionHostName, []byte(d.Hostname)))
@@ -101,17 +108,122 @@ func (h *Handler) setDHCPOpts(_ context.Context, _ *dhcpv4.DHCPv4, d *data.DHCP)
 	if len(d.SubnetMask) > 0 {
 		mods = append(mods, dhcpv4.WithNetmask(d.SubnetMask))
 	}
-	if d.DefaultGateway.Compare(netip.Addr{}) != 0 {
-		mods = append(mods, dhcpv4.WithRouter(d.DefaultGateway.AsSlice()))
+	if len(d.DefaultGateways) > 0 {
+		mods = append(mods, dhcpv4.WithRouter(h.routers(d)...))
+	}
+	if d.TimeOffset != 0 {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionTimeOffset, binary.BigEndian.AppendUint32(nil, uint32(d.TimeOffset))))
+	}
+	if d.TZPOSIX != "" {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionIEEE10031TZString, []byte(d.TZPOSIX)))
+	}
+	if d.TZDatabase != "" {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionReferenceToTZDatabase, []byte(d.TZDatabase)))
+	}
+	if d.MTU != 0 {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionInterfaceMTU, binary.BigEndian.AppendUint16(nil, d.MTU)))
+	}
+	if d.TFTPServerName != "" {
+		mods = append(mods, dhcpv4.WithOption(dhcpv4.OptTFTPServerName(d.TFTPServerName)))
+	}
+	if d.BootFileName != "" {
+		mods = append(mods, dhcpv4.WithOption(dhcpv4.OptBootFileName(d.BootFileName)))
+	}
+	if len(d.VendorOptions) > 0 {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options(d.VendorOptions).ToBytes()))
+	}
+	if b := sipServers(d); b != nil {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionSIPServers, b))
+	}
+	if len(d.ClasslessStaticRoutes) > 0 {
+		var routes []*dhcpv4.Route
+		for _, r := range d.ClasslessStaticRoutes {
+			routes = append(routes, &dhcpv4.Route{
+				Dest:   &net.IPNet{IP: r.Destination.Masked().Addr().AsSlice(), Mask: net.CIDRMask(r.Destination.Bits(), 32)},
+				Router: r.Router.AsSlice(),
+			})
+		}
+		mods = append(mods, dhcpv4.WithOption(dhcpv4.OptClasslessStaticRoute(routes...)))
+	}
+	if len(d.VIVendorOptions) > 0 {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionVendorIdentifyingVendorSpecific, d.VIVendorOptions.ToBytes()))
+	}
+	// options 132 and 133 are 32-bit integers, as IP phones and NIC firmware expect them.
+	if d.VLANTag != 0 {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.Option8021PVLANID, binary.BigEndian.AppendUint32(nil, uint32(d.VLANTag))))
+		if d.VLANPriority != 0 {
+			mods = append(mods, dhcpv4.WithGeneric(dhcpv4.Option8021QL2Priority, binary.BigEndian.AppendUint32(nil, uint32(d.VLANPriority))))
+		}
+	}
+	if d.CaptivePortalURL != nil {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionURL, []byte(d.CaptivePortalURL.String())))
+	}
+	if d.ProxyAutoConfigURL != nil {
+		mods = append(mods, dhcpv4.WithGeneric(optionProxyAutoConfig, []byte(d.ProxyAutoConfigURL.String())))
 	}
 	if h.SyslogAddr.Compare(netip.Addr{}) != 0 {
 		mods = append(mods, dhcpv4.WithOption(dhcpv4.OptGeneric(dhcpv4.OptionLogServer, h.SyslogAddr.AsSlice())))
 	}
+	codes := make([]uint8, 0, len(d.OtherOptions))
+	for c := range d.OtherOptions {
+		codes = append(codes, c)
+	}
+	slices.Sort(codes)
+	for _, c := range codes {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.GenericOptionCode(c), d.OtherOptions[c]))
+	}
+	if len(h.OptionPolicy.Include) > 0 || len(h.OptionPolicy.Exclude) > 0 {
+		mods = append(mods, h.OptionPolicy.filter())
+	}
 
 	return mods
 }
 
-// setNetworkBootOpts purpose is to sets 3 or 4 values. 2 DHCP headers, option 43 and optionally option (60).
+// sipServers returns the value of option 120 for the SIP servers of d, or nil when there are none.
+// The first byte is the encoding, 0 for domain names and 1 for IPv4 addresses (https://www.rfc-editor.org/rfc/rfc3361.html#section-3).
+func sipServers(d *data.DHCP) []byte {
+	switch {
+	case len(d.SIPServerNames) > 0:
+		l := &rfc1035label.Labels{Labels: d.SIPServerNames}
+		return append([]byte{0}, l.ToBytes()...)
+	case len(d.SIPServerAddresses) > 0:
+		b := []byte{1}
+		for _, ip := range d.SIPServerAddresses {
+			b = append(b, ip.To4()...)
+		}
+		return b
+	}
+
+	return nil
+}
+
+// routers returns the default gateways of d for option 3.
+// When h.LocalGatewayFirst is set, the gateways in the subnet of d.IPAddress are moved to the front.
+func (h *Handler) routers(d *data.DHCP) []net.IP {
+	gws := slices.Clone(d.DefaultGateways)
+	if h.LocalGatewayFirst && len(d.SubnetMask) > 0 {
+		ones, _ := d.SubnetMask.Size()
+		if p, err := d.IPAddress.Prefix(ones); err == nil {
+			slices.SortStableFunc(gws, func(a, b netip.Addr) int {
+				switch ia, ib := p.Contains(a), p.Contains(b); {
+				case ia && !ib:
+					return -1
+				case !ia && ib:
+					return 1
+				}
+				return 0
+			})
+		}
+	}
+	r := make([]net.IP, 0, len(gws))
+	for _, gw := range gws {
+		r = append(r, gw.AsSlice())
+	}
+
+	return r
+}
+
+// setNetworkBootOpts purpose is to sets 3 to 5 values. 2 DHCP headers, option 43 and optionally options (60) and (97).
 // These headers and options are returned as a dhcvp4.Modifier that can be used to modify a dhcp response.
 // github.com/insomniacslk/dhcp uses this method to simplify packet manipulation.
 //
@@ -121,29 +233,39 @@ func (h *Handler) setDHCPOpts(_ context.Context, _ *dhcpv4.DHCPv4, d *data.DHCP)
 //
 // DHCP option
 // option 60: Class Identifier. https://www.rfc-editor.org/rfc/rfc2132.html#section-9.13
-// option 60 is set if the client's option 60 (Class Identifier) starts with HTTPClient.
+// option 60 is set if the client's option 60 (Class Identifier) starts with HTTPClient and it has something to boot over HTTP.
+// option 97: Client Machine Identifier. https://www.rfc-editor.org/rfc/rfc4578.html#section-2.3
+// option 97 is mirrored back to the client when it sent one, as the PXE spec expects.
 func (h *Handler) setNetworkBootOpts(ctx context.Context, m *dhcpv4.DHCPv4, n *data.Netboot) dhcpv4.Modifier {
 	// m is a received DHCPv4 packet.
 	// d is the reply packet we are building.
 	withNetboot := func(d *dhcpv4.DHCPv4) {
 		var opt60 string
-		// if the client sends opt 60 with HTTPClient then we need to respond with opt 60
+		uClass := UserClass(string(m.GetOneOption(dhcpv4.OptionUserClassInformation)))
+		// if the client sends opt 60 with HTTPClient then we need to respond with opt 60,
+		// unless there is nothing to boot over HTTP and the client is answered like a PXE client.
 		if val := m.Options.Get(dhcpv4.OptionClassIdentifier); val != nil {
-			if strings.HasPrefix(string(val), httpClient.String()) {
+			if strings.HasPrefix(string(val), httpClient.String()) && h.httpBoot(uClass, n) {
 				d.UpdateOption(dhcpv4.OptGeneric(dhcpv4.OptionClassIdentifier, []byte(httpClient)))
 				opt60 = httpClient.String()
 			}
 		}
+		if guid := m.GetOneOption(dhcpv4.OptionClientMachineIdentifier); len(guid) > 0 {
+			d.UpdateOption(dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, guid))
+		}
 		d.BootFileName = "/netboot-not-allowed"
 		d.ServerIPAddr = net.IPv4(0, 0, 0, 0)
 		if n.AllowNetboot {
 			a := arch(m)
 			bin, found := ArchToBootFile[a]
+			if n.IPXEBinary != "" {
+				// the record pins the client to a binary, whatever its architecture.
+				bin, found = n.IPXEBinary, true
+			}
 			if !found {
-				h.Log.Error(fmt.Errorf("unable to find bootfile for arch"), "network boot not allowed", "arch", a, "archInt", int(a), "mac", m.ClientHWAddr)
+				h.Log.Error(fmt.Errorf("unable to find bootfile for arch"), "network boot not allowed", "arch", a, "archInt", int(a), "mac", h.Redact.MAC(m.ClientHWAddr))
 				return
 			}
-			uClass := UserClass(string(m.GetOneOption(dhcpv4.OptionUserClassInformation)))
 			var ipxeScript *url.URL
 			if h.Netboot.IPXEScriptURL != nil {
 				ipxeScript = h.Netboot.IPXEScriptURL(m)
@@ -151,11 +273,28 @@ func (h *Handler) setNetworkBootOpts(ctx context.Context, m *dhcpv4.DHCPv4, n *d
 			if n.IPXEScriptURL != nil {
 				ipxeScript = n.IPXEScriptURL
 			}
-			d.BootFileName, d.ServerIPAddr = h.bootfileAndNextServer(ctx, uClass, opt60, bin, h.Netboot.IPXEBinServerTFTP, h.Netboot.IPXEBinServerHTTP, ipxeScript)
+			if n.HTTPBootURI != nil && clientType(opt60) == httpClient && !h.inIPXE(uClass) {
+				// UEFI HTTP Boot clients that are not running iPXE boot the HTTP boot URI directly.
+				d.BootFileName, d.ServerIPAddr = n.HTTPBootURI.String(), hostIP(n.HTTPBootURI)
+			} else {
+				d.BootFileName, d.ServerIPAddr = h.bootfileAndNextServer(ctx, uClass, opt60, bin, h.Netboot.IPXEBinServerTFTP, h.Netboot.IPXEBinServerHTTP, ipxeScript)
+			}
 			pxe := dhcpv4.Options{ // FYI, these are suboptions of option43. ref: https://datatracker.ietf.org/doc/html/rfc2132#section-8.4
 				// PXE Boot Server Discovery Control - bypass, just boot from filename.
-				6:  []byte{8},
-				69: otel.TraceparentFromContext(ctx),
+				pxeDiscoveryControl: []byte{8},
+			}
+			h.Traceparent.subOption(ctx, pxe)
+			h.Traceparent.option(ctx, d)
+			// sub-options from the backend, set earlier in d, take precedence over the PXE sub-options.
+			if b := d.Options.Get(dhcpv4.OptionVendorSpecificInformation); len(b) > 0 {
+				vendor := dhcpv4.Options{}
+				if err := vendor.FromBytes(b); err != nil {
+					h.Log.Info("option 43 from the backend is not a list of sub-options, replacing it", "mac", h.Redact.MAC(m.ClientHWAddr), "err", err)
+				} else {
+					for code, v := range vendor {
+						pxe[code] = v
+					}
+				}
 			}
 			d.UpdateOption(dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, pxe.ToBytes()))
 		}
@@ -180,14 +319,9 @@ func (h *Handler) bootfileAndNextServer(ctx context.Context, uClass UserClass, o
 		if iscript != nil {
 			bootfile = iscript.String()
 		}
-	case clientType(opt60) == httpClient: // Check the client type from option 60.
+	case clientType(opt60) == httpClient && ipxe != nil: // Check the client type from option 60.
 		bootfile = ipxe.JoinPath(bin).String()
-		nextServer = net.ParseIP("0.0.0.0")
-		if n, err := netip.ParseAddrPort(ipxe.Host); err == nil {
-			nextServer = n.Addr().AsSlice()
-		} else if n2 := net.ParseIP(ipxe.Host); n2 != nil {
-			nextServer = net.ParseIP(ipxe.Host)
-		}
+		nextServer = hostIP(ipxe)
 	case uClass == IPXE: // if the "iPXE" user class is found it means we aren't in our custom version of ipxe, but because of the option 43 we're setting we need to give a full tftp url from which to boot.
 		bootfile = fmt.Sprintf("tftp://%v/%v", tftp.String(), bin)
 		nextServer = net.IP(tftp.Addr().AsSlice())
@@ -199,6 +333,29 @@ func (h *Handler) bootfileAndNextServer(ctx context.Context, uClass UserClass, o
 	return bootfile, nextServer
 }
 
+// httpBoot returns true if a UEFI HTTP Boot client with the user class uClass has something to boot over HTTP: the HTTP
+// boot URI of n, the iPXE binary from the HTTP server, or, when it is already running iPXE, the iPXE script.
+func (h *Handler) httpBoot(uClass UserClass, n *data.Netboot) bool {
+	return n.HTTPBootURI != nil || h.Netboot.IPXEBinServerHTTP != nil || h.inIPXE(uClass)
+}
+
+// inIPXE returns true if the user class uClass shows that the client is already running iPXE.
+func (h *Handler) inIPXE(uClass UserClass) bool {
+	return uClass == IPXE || uClass == Tinkerbell || (h.Netboot.UserClass != "" && uClass == h.Netboot.UserClass)
+}
+
+// hostIP returns the IP address in the host of u, or 0.0.0.0 when the host is not an IP address.
+func hostIP(u *url.URL) net.IP {
+	if n, err := netip.ParseAddrPort(u.Host); err == nil {
+		return n.Addr().AsSlice()
+	}
+	if ip := net.ParseIP(u.Host); ip != nil {
+		return ip
+	}
+
+	return net.ParseIP("0.0.0.0")
+}
+
 // arch returns the arch of the client pulled from DHCP option 93.
 func arch(d *dhcpv4.DHCPv4) iana.Arch {
 	// get option 93 ; arch
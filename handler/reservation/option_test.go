@@ -38,10 +38,10 @@ func TestSetDHCPOpts(t *testing.T) {
 				in0: context.Background(),
 				m:   &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(dhcpv4.OptParameterRequestList(dhcpv4.OptionSubnetMask))},
 				d: &data.DHCP{
-					MACAddress:     net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
-					IPAddress:      netip.MustParseAddr("192.168.4.4"),
-					SubnetMask:     []byte{255, 255, 255, 0},
-					DefaultGateway: netip.MustParseAddr("192.168.4.1"),
+					MACAddress:      net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+					IPAddress:       netip.MustParseAddr("192.168.4.4"),
+					SubnetMask:      []byte{255, 255, 255, 0},
+					DefaultGateways: []netip.Addr{netip.MustParseAddr("192.168.4.1")},
 					NameServers: []net.IP{
 						{8, 8, 8, 8},
 						{8, 8, 4, 4},
@@ -89,6 +89,294 @@ func TestSetDHCPOpts(t *testing.T) {
 				),
 			},
 		},
+		"mtu, tftp server, bootfile, and static routes": {
+			server: Handler{Log: logr.Discard()},
+			args: args{
+				in0: context.Background(),
+				m:   &dhcpv4.DHCPv4{},
+				d: &data.DHCP{
+					IPAddress:      netip.MustParseAddr("192.168.4.4"),
+					LeaseTime:      84600,
+					MTU:            9000,
+					TFTPServerName: "192.168.4.2",
+					BootFileName:   "pxelinux.0",
+					ClasslessStaticRoutes: []data.Route{
+						{Destination: netip.MustParsePrefix("10.0.0.0/8"), Router: netip.MustParseAddr("192.168.4.254")},
+					},
+				},
+			},
+			want: &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				HWType:        iana.HWTypeEthernet,
+				ClientHWAddr:  net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				ClientIPAddr:  []byte{0, 0, 0, 0},
+				YourIPAddr:    []byte{192, 168, 4, 4},
+				ServerIPAddr:  []byte{0, 0, 0, 0},
+				GatewayIPAddr: []byte{0, 0, 0, 0},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptIPAddressLeaseTime(time.Duration(84600)*time.Second),
+					dhcpv4.OptGeneric(dhcpv4.OptionInterfaceMTU, []byte{0x23, 0x28}),
+					dhcpv4.OptTFTPServerName("192.168.4.2"),
+					dhcpv4.OptBootFileName("pxelinux.0"),
+					dhcpv4.OptClasslessStaticRoute(&dhcpv4.Route{
+						Dest:   &net.IPNet{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+						Router: net.IP{192, 168, 4, 254},
+					}),
+				),
+			},
+		},
+		"domain name from the search list": {
+			server: Handler{Log: logr.Discard()},
+			args: args{
+				in0: context.Background(),
+				m:   &dhcpv4.DHCPv4{},
+				d: &data.DHCP{
+					IPAddress:    netip.MustParseAddr("192.168.4.4"),
+					LeaseTime:    84600,
+					DomainSearch: []string{"MyNet.Local.", "example.com", "mynet.local"},
+				},
+			},
+			want: &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				HWType:        iana.HWTypeEthernet,
+				ClientHWAddr:  net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				ClientIPAddr:  []byte{0, 0, 0, 0},
+				YourIPAddr:    []byte{192, 168, 4, 4},
+				ServerIPAddr:  []byte{0, 0, 0, 0},
+				GatewayIPAddr: []byte{0, 0, 0, 0},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptIPAddressLeaseTime(time.Duration(84600)*time.Second),
+					dhcpv4.OptDomainName("mynet.local"),
+					dhcpv4.OptDomainSearch(&rfc1035label.Labels{
+						Labels: []string{"mynet.local", "example.com"},
+					}),
+				),
+			},
+		},
+		"local gateway first": {
+			server: Handler{Log: logr.Discard(), LocalGatewayFirst: true},
+			args: args{
+				in0: context.Background(),
+				m:   &dhcpv4.DHCPv4{},
+				d: &data.DHCP{
+					IPAddress:  netip.MustParseAddr("192.168.4.4"),
+					SubnetMask: []byte{255, 255, 255, 0},
+					LeaseTime:  84600,
+					DefaultGateways: []netip.Addr{
+						netip.MustParseAddr("10.0.0.1"),
+						netip.MustParseAddr("192.168.4.1"),
+						netip.MustParseAddr("10.0.0.2"),
+						netip.MustParseAddr("192.168.4.2"),
+					},
+				},
+			},
+			want: &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				HWType:        iana.HWTypeEthernet,
+				ClientHWAddr:  net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				ClientIPAddr:  []byte{0, 0, 0, 0},
+				YourIPAddr:    []byte{192, 168, 4, 4},
+				ServerIPAddr:  []byte{0, 0, 0, 0},
+				GatewayIPAddr: []byte{0, 0, 0, 0},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptSubnetMask(net.IPMask{255, 255, 255, 0}),
+					dhcpv4.OptIPAddressLeaseTime(time.Duration(84600)*time.Second),
+					dhcpv4.OptRouter(net.IP{192, 168, 4, 1}, net.IP{192, 168, 4, 2}, net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}),
+				),
+			},
+		},
+		"proxy auto-config and captive portal urls": {
+			server: Handler{Log: logr.Discard()},
+			args: args{
+				in0: context.Background(),
+				m:   &dhcpv4.DHCPv4{},
+				d: &data.DHCP{
+					IPAddress:          netip.MustParseAddr("192.168.4.4"),
+					LeaseTime:          84600,
+					ProxyAutoConfigURL: &url.URL{Scheme: "http", Host: "192.168.4.1", Path: "/wpad.dat"},
+					CaptivePortalURL:   &url.URL{Scheme: "https", Host: "portal.example.com", Path: "/api"},
+				},
+			},
+			want: &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				HWType:        iana.HWTypeEthernet,
+				ClientHWAddr:  net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				ClientIPAddr:  []byte{0, 0, 0, 0},
+				YourIPAddr:    []byte{192, 168, 4, 4},
+				ServerIPAddr:  []byte{0, 0, 0, 0},
+				GatewayIPAddr: []byte{0, 0, 0, 0},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptIPAddressLeaseTime(time.Duration(84600)*time.Second),
+					dhcpv4.OptGeneric(dhcpv4.OptionURL, []byte("https://portal.example.com/api")),
+					dhcpv4.OptGeneric(dhcpv4.GenericOptionCode(252), []byte("http://192.168.4.1/wpad.dat")),
+				),
+			},
+		},
+		"timezone": {
+			server: Handler{Log: logr.Discard()},
+			args: args{
+				in0: context.Background(),
+				m:   &dhcpv4.DHCPv4{},
+				d: &data.DHCP{
+					IPAddress:  netip.MustParseAddr("192.168.4.4"),
+					LeaseTime:  84600,
+					TimeOffset: -18000,
+					TZPOSIX:    "EST5EDT,M3.2.0,M11.1.0",
+					TZDatabase: "America/New_York",
+				},
+			},
+			want: &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				HWType:        iana.HWTypeEthernet,
+				ClientHWAddr:  net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				ClientIPAddr:  []byte{0, 0, 0, 0},
+				YourIPAddr:    []byte{192, 168, 4, 4},
+				ServerIPAddr:  []byte{0, 0, 0, 0},
+				GatewayIPAddr: []byte{0, 0, 0, 0},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptIPAddressLeaseTime(time.Duration(84600)*time.Second),
+					dhcpv4.OptGeneric(dhcpv4.OptionTimeOffset, []byte{0xff, 0xff, 0xb9, 0xb0}),
+					dhcpv4.OptGeneric(dhcpv4.OptionIEEE10031TZString, []byte("EST5EDT,M3.2.0,M11.1.0")),
+					dhcpv4.OptGeneric(dhcpv4.OptionReferenceToTZDatabase, []byte("America/New_York")),
+				),
+			},
+		},
+		"vlan tag and priority": {
+			server: Handler{Log: logr.Discard()},
+			args: args{
+				in0: context.Background(),
+				m:   &dhcpv4.DHCPv4{},
+				d: &data.DHCP{
+					IPAddress:    netip.MustParseAddr("192.168.4.4"),
+					LeaseTime:    84600,
+					VLANTag:      200,
+					VLANPriority: 5,
+				},
+			},
+			want: &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				HWType:        iana.HWTypeEthernet,
+				ClientHWAddr:  net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				ClientIPAddr:  []byte{0, 0, 0, 0},
+				YourIPAddr:    []byte{192, 168, 4, 4},
+				ServerIPAddr:  []byte{0, 0, 0, 0},
+				GatewayIPAddr: []byte{0, 0, 0, 0},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptIPAddressLeaseTime(time.Duration(84600)*time.Second),
+					dhcpv4.OptGeneric(dhcpv4.Option8021PVLANID, []byte{0x00, 0x00, 0x00, 0xc8}),
+					dhcpv4.OptGeneric(dhcpv4.Option8021QL2Priority, []byte{0x00, 0x00, 0x00, 0x05}),
+				),
+			},
+		},
+		"sip servers by name": {
+			server: Handler{Log: logr.Discard()},
+			args: args{
+				in0: context.Background(),
+				m:   &dhcpv4.DHCPv4{},
+				d: &data.DHCP{
+					IPAddress:      netip.MustParseAddr("192.168.4.4"),
+					LeaseTime:      84600,
+					SIPServerNames: []string{"sip.example.com", "sip2.example.com"},
+				},
+			},
+			want: &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				HWType:        iana.HWTypeEthernet,
+				ClientHWAddr:  net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				ClientIPAddr:  []byte{0, 0, 0, 0},
+				YourIPAddr:    []byte{192, 168, 4, 4},
+				ServerIPAddr:  []byte{0, 0, 0, 0},
+				GatewayIPAddr: []byte{0, 0, 0, 0},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptIPAddressLeaseTime(time.Duration(84600)*time.Second),
+					dhcpv4.OptGeneric(dhcpv4.OptionSIPServers, append([]byte{0}, (&rfc1035label.Labels{Labels: []string{"sip.example.com", "sip2.example.com"}}).ToBytes()...)),
+				),
+			},
+		},
+		"sip servers by address": {
+			server: Handler{Log: logr.Discard()},
+			args: args{
+				in0: context.Background(),
+				m:   &dhcpv4.DHCPv4{},
+				d: &data.DHCP{
+					IPAddress:          netip.MustParseAddr("192.168.4.4"),
+					LeaseTime:          84600,
+					SIPServerAddresses: []net.IP{{192, 168, 4, 5}, net.ParseIP("192.168.4.6")},
+				},
+			},
+			want: &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				HWType:        iana.HWTypeEthernet,
+				ClientHWAddr:  net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				ClientIPAddr:  []byte{0, 0, 0, 0},
+				YourIPAddr:    []byte{192, 168, 4, 4},
+				ServerIPAddr:  []byte{0, 0, 0, 0},
+				GatewayIPAddr: []byte{0, 0, 0, 0},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptIPAddressLeaseTime(time.Duration(84600)*time.Second),
+					dhcpv4.OptGeneric(dhcpv4.OptionSIPServers, []byte{1, 192, 168, 4, 5, 192, 168, 4, 6}),
+				),
+			},
+		},
+		"vendor options": {
+			server: Handler{Log: logr.Discard()},
+			args: args{
+				in0: context.Background(),
+				m:   &dhcpv4.DHCPv4{},
+				d: &data.DHCP{
+					IPAddress:       netip.MustParseAddr("192.168.4.4"),
+					LeaseTime:       84600,
+					VendorOptions:   map[uint8][]byte{1: {0xaa}, 116: []byte("100")},
+					VIVendorOptions: data.VIVendorOptions{3561: {1: []byte("abc")}},
+				},
+			},
+			want: &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				HWType:        iana.HWTypeEthernet,
+				ClientHWAddr:  net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				ClientIPAddr:  []byte{0, 0, 0, 0},
+				YourIPAddr:    []byte{192, 168, 4, 4},
+				ServerIPAddr:  []byte{0, 0, 0, 0},
+				GatewayIPAddr: []byte{0, 0, 0, 0},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptIPAddressLeaseTime(time.Duration(84600)*time.Second),
+					dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, []byte{1, 1, 0xaa, 116, 3, '1', '0', '0'}),
+					dhcpv4.OptGeneric(dhcpv4.OptionVendorIdentifyingVendorSpecific, []byte{0x00, 0x00, 0x0d, 0xe9, 5, 1, 3, 'a', 'b', 'c'}),
+				),
+			},
+		},
+		"other options": {
+			server: Handler{Log: logr.Discard()},
+			args: args{
+				in0: context.Background(),
+				m:   &dhcpv4.DHCPv4{},
+				d: &data.DHCP{
+					IPAddress: netip.MustParseAddr("192.168.4.4"),
+					LeaseTime: 84600,
+					Hostname:  "test-server",
+					OtherOptions: map[uint8][]byte{
+						252: []byte("http://192.168.4.1/wpad.dat"),
+						224: {0x01, 0x02},
+						12:  []byte("other-server"),
+					},
+				},
+			},
+			want: &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				HWType:        iana.HWTypeEthernet,
+				ClientHWAddr:  net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				ClientIPAddr:  []byte{0, 0, 0, 0},
+				YourIPAddr:    []byte{192, 168, 4, 4},
+				ServerIPAddr:  []byte{0, 0, 0, 0},
+				GatewayIPAddr: []byte{0, 0, 0, 0},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptIPAddressLeaseTime(time.Duration(84600)*time.Second),
+					dhcpv4.OptHostName("other-server"),
+					dhcpv4.OptGeneric(dhcpv4.GenericOptionCode(224), []byte{0x01, 0x02}),
+					dhcpv4.OptGeneric(dhcpv4.GenericOptionCode(252), []byte("http://192.168.4.1/wpad.dat")),
+				),
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -101,9 +389,10 @@ func TestSetDHCPOpts(t *testing.T) {
 					Enabled:           tt.server.Netboot.Enabled,
 					UserClass:         tt.server.Netboot.UserClass,
 				},
-				IPAddr:     tt.server.IPAddr,
-				Backend:    tt.server.Backend,
-				SyslogAddr: tt.server.SyslogAddr,
+				IPAddr:            tt.server.IPAddr,
+				Backend:           tt.server.Backend,
+				SyslogAddr:        tt.server.SyslogAddr,
+				LocalGatewayFirst: tt.server.LocalGatewayFirst,
 			}
 			mods := s.setDHCPOpts(tt.args.in0, tt.args.m, tt.args.d)
 			finalPkt, err := dhcpv4.New(mods...)
@@ -182,6 +471,17 @@ func TestBootfileAndNextServer(t *testing.T) {
 			wantBootFile: "http://localhost:8181/snp.ipxe",
 			wantNextSrv:  net.IPv4(0, 0, 0, 0),
 		},
+		"httpClient without an http server": {
+			server: &Handler{Log: logr.Discard()},
+			args: args{
+				mac:   net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				opt60: httpClient.String(),
+				bin:   "ipxe.efi",
+				tftp:  netip.MustParseAddrPort("192.168.6.5:69"),
+			},
+			wantBootFile: "ipxe.efi",
+			wantNextSrv:  net.ParseIP("192.168.6.5"),
+		},
 		"success userclass iPXE": {
 			server: &Handler{Log: logr.Discard()},
 			args: args{
@@ -259,6 +559,7 @@ func TestSetNetworkBootOpts(t *testing.T) {
 	tests := map[string]struct {
 		server *Handler
 		args   args
+		reply  *dhcpv4.DHCPv4
 		want   *dhcpv4.DHCPv4
 	}{
 		"netboot not allowed": {
@@ -270,6 +571,19 @@ func TestSetNetworkBootOpts(t *testing.T) {
 			},
 			want: &dhcpv4.DHCPv4{ServerIPAddr: net.IPv4(0, 0, 0, 0), BootFileName: "/netboot-not-allowed"},
 		},
+		"client machine identifier mirrored": {
+			server: &Handler{Log: logr.Discard()},
+			args: args{
+				in0: context.Background(),
+				m: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, []byte{0x00, 0x4c, 0x4c, 0x45, 0x44, 0x00, 0x51, 0x10, 0x80, 0x80, 0x4a, 0xb4, 0xc0, 0x4f, 0x4e, 0x38, 0x32}),
+				)},
+				n: &data.Netboot{AllowNetboot: false},
+			},
+			want: &dhcpv4.DHCPv4{ServerIPAddr: net.IPv4(0, 0, 0, 0), BootFileName: "/netboot-not-allowed", Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, []byte{0x00, 0x4c, 0x4c, 0x45, 0x44, 0x00, 0x51, 0x10, 0x80, 0x80, 0x4a, 0xb4, 0xc0, 0x4f, 0x4e, 0x38, 0x32}),
+			)},
+		},
 		"netboot allowed": {
 			server: &Handler{Log: logr.Discard(), Netboot: Netboot{IPXEScriptURL: func(*dhcpv4.DHCPv4) *url.URL {
 				return &url.URL{Scheme: "http", Host: "localhost:8181", Path: "/01:02:03:04:05:06/auto.ipxe"}
@@ -294,6 +608,139 @@ func TestSetNetworkBootOpts(t *testing.T) {
 				dhcpv4.OptClassIdentifier("HTTPClient"),
 			)},
 		},
+		"netboot allowed, ipxe binary override": {
+			server: &Handler{Log: logr.Discard(), Netboot: Netboot{IPXEBinServerTFTP: netip.MustParseAddrPort("192.168.6.5:69")}},
+			args: args{
+				in0: context.Background(),
+				m: &dhcpv4.DHCPv4{
+					ClientHWAddr: net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+					Options:      dhcpv4.OptionsFromList(dhcpv4.OptClientArch(iana.UBOOT_ARM64)),
+				},
+				n: &data.Netboot{AllowNetboot: true, IPXEBinary: "snp-debug.efi"},
+			},
+			want: &dhcpv4.DHCPv4{BootFileName: "snp-debug.efi", ServerIPAddr: net.IP{192, 168, 6, 5}, Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{
+					6:  []byte{8},
+					69: oteldhcp.TraceparentFromContext(context.Background()),
+				}.ToBytes()),
+			)},
+		},
+		"netboot allowed, http boot uri": {
+			server: &Handler{Log: logr.Discard(), Netboot: Netboot{IPXEBinServerHTTP: &url.URL{Scheme: "http", Host: "192.168.6.5:8080"}}},
+			args: args{
+				in0: context.Background(),
+				m: &dhcpv4.DHCPv4{
+					ClientHWAddr: net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+					Options: dhcpv4.OptionsFromList(
+						dhcpv4.OptClassIdentifier("HTTPClient:Arch:00016:UNDI:003001"),
+						dhcpv4.OptClientArch(iana.EFI_X86_64_HTTP),
+					),
+				},
+				n: &data.Netboot{AllowNetboot: true, HTTPBootURI: &url.URL{Scheme: "https", Host: "192.168.6.7", Path: "/uki.efi"}},
+			},
+			want: &dhcpv4.DHCPv4{BootFileName: "https://192.168.6.7/uki.efi", ServerIPAddr: net.IP{192, 168, 6, 7}, Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{
+					6:  []byte{8},
+					69: oteldhcp.TraceparentFromContext(context.Background()),
+				}.ToBytes()),
+				dhcpv4.OptClassIdentifier("HTTPClient"),
+			)},
+		},
+		"netboot allowed, http client with tftp only": {
+			server: &Handler{Log: logr.Discard(), Netboot: Netboot{IPXEBinServerTFTP: netip.MustParseAddrPort("192.168.6.5:69")}},
+			args: args{
+				in0: context.Background(),
+				m: &dhcpv4.DHCPv4{
+					ClientHWAddr: net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+					Options: dhcpv4.OptionsFromList(
+						dhcpv4.OptClassIdentifier("HTTPClient:Arch:00016:UNDI:003001"),
+						dhcpv4.OptClientArch(iana.EFI_X86_64_HTTP),
+					),
+				},
+				n: &data.Netboot{AllowNetboot: true},
+			},
+			want: &dhcpv4.DHCPv4{BootFileName: "ipxe.efi", ServerIPAddr: net.IP{192, 168, 6, 5}, Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{
+					6:  []byte{8},
+					69: oteldhcp.TraceparentFromContext(context.Background()),
+				}.ToBytes()),
+			)},
+		},
+		"netboot allowed, vendor options from the backend": {
+			server: &Handler{Log: logr.Discard()},
+			args: args{
+				in0: context.Background(),
+				m: &dhcpv4.DHCPv4{
+					ClientHWAddr: net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+					Options: dhcpv4.OptionsFromList(
+						dhcpv4.OptUserClass(Tinkerbell.String()),
+						dhcpv4.OptClientArch(iana.EFI_X86_64),
+					),
+				},
+				n: &data.Netboot{AllowNetboot: true, IPXEScriptURL: &url.URL{Scheme: "http", Host: "localhost:8181", Path: "/auto.ipxe"}},
+			},
+			reply: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{
+					1:   []byte{0xaa},
+					6:   []byte{3},
+					116: []byte("100"),
+				}.ToBytes()),
+			)},
+			want: &dhcpv4.DHCPv4{BootFileName: "http://localhost:8181/auto.ipxe", Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{
+					1:   []byte{0xaa},
+					6:   []byte{3},
+					69:  oteldhcp.TraceparentFromContext(context.Background()),
+					116: []byte("100"),
+				}.ToBytes()),
+			)},
+		},
+		"traceparent in another sub-option": {
+			server: &Handler{Log: logr.Discard(), Traceparent: Traceparent{Code: 200}, Netboot: Netboot{IPXEBinServerTFTP: netip.MustParseAddrPort("192.168.6.5:69")}},
+			args: args{
+				in0: context.Background(),
+				m: &dhcpv4.DHCPv4{
+					ClientHWAddr: net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+					Options:      dhcpv4.OptionsFromList(dhcpv4.OptClientArch(iana.UBOOT_ARM64)),
+				},
+				n: &data.Netboot{AllowNetboot: true, IPXEBinary: "snp.efi"},
+			},
+			want: &dhcpv4.DHCPv4{BootFileName: "snp.efi", ServerIPAddr: net.IP{192, 168, 6, 5}, Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{
+					6:   []byte{8},
+					200: oteldhcp.TraceparentFromContext(context.Background()),
+				}.ToBytes()),
+			)},
+		},
+		"traceparent in a site-specific option": {
+			server: &Handler{Log: logr.Discard(), Traceparent: Traceparent{Mode: TraceparentOption}, Netboot: Netboot{IPXEBinServerTFTP: netip.MustParseAddrPort("192.168.6.5:69")}},
+			args: args{
+				in0: context.Background(),
+				m: &dhcpv4.DHCPv4{
+					ClientHWAddr: net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+					Options:      dhcpv4.OptionsFromList(dhcpv4.OptClientArch(iana.UBOOT_ARM64)),
+				},
+				n: &data.Netboot{AllowNetboot: true, IPXEBinary: "snp.efi"},
+			},
+			want: &dhcpv4.DHCPv4{BootFileName: "snp.efi", ServerIPAddr: net.IP{192, 168, 6, 5}, Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.GenericOptionCode(224), oteldhcp.TraceparentFromContext(context.Background())),
+				dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{6: []byte{8}}.ToBytes()),
+			)},
+		},
+		"traceparent not sent": {
+			server: &Handler{Log: logr.Discard(), Traceparent: Traceparent{Mode: TraceparentNone}, Netboot: Netboot{IPXEBinServerTFTP: netip.MustParseAddrPort("192.168.6.5:69")}},
+			args: args{
+				in0: context.Background(),
+				m: &dhcpv4.DHCPv4{
+					ClientHWAddr: net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+					Options:      dhcpv4.OptionsFromList(dhcpv4.OptClientArch(iana.UBOOT_ARM64)),
+				},
+				n: &data.Netboot{AllowNetboot: true, IPXEBinary: "snp.efi"},
+			},
+			want: &dhcpv4.DHCPv4{BootFileName: "snp.efi", ServerIPAddr: net.IP{192, 168, 6, 5}, Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{6: []byte{8}}.ToBytes()),
+			)},
+		},
 		"netboot not allowed, arch unknown": {
 			server: &Handler{Log: logr.Discard(), Netboot: Netboot{IPXEScriptURL: func(*dhcpv4.DHCPv4) *url.URL {
 				return &url.URL{Scheme: "http", Host: "localhost:8181", Path: "/01:02:03:04:05:06/auto.ipxe"}
@@ -323,11 +770,15 @@ func TestSetNetworkBootOpts(t *testing.T) {
 					Enabled:           tt.server.Netboot.Enabled,
 					UserClass:         tt.server.Netboot.UserClass,
 				},
-				IPAddr:  tt.server.IPAddr,
-				Backend: tt.server.Backend,
+				IPAddr:      tt.server.IPAddr,
+				Backend:     tt.server.Backend,
+				Traceparent: tt.server.Traceparent,
 			}
 			gotFunc := s.setNetworkBootOpts(tt.args.in0, tt.args.m, tt.args.n)
-			got := new(dhcpv4.DHCPv4)
+			got := tt.reply
+			if got == nil {
+				got = new(dhcpv4.DHCPv4)
+			}
 			gotFunc(got)
 			if diff := cmp.Diff(tt.want, got); diff != "" {
 				t.Fatal(diff)
@@ -0,0 +1,160 @@
+package reservation
+
+import (
+	"errors"
+	"net"
+	"slices"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+// Action is what the handler does with a DHCP message when the backend read for it fails.
+type Action int
+
+const (
+	// ActionDefault is the default action of the error class, see ErrorPolicy.
+	ActionDefault Action = iota
+	// ActionDrop does not reply to the message.
+	ActionDrop
+	// ActionRetry retries the backend read once. When the retry fails too, the message is dropped.
+	ActionRetry
+	// ActionNAK replies to a DHCPREQUEST with a DHCPNAK, so the client restarts with a DHCPDISCOVER
+	// instead of retransmitting the request. A DHCPDISCOVER is dropped.
+	// It is only applied when ErrorPolicy.Authoritative is set, otherwise the message is dropped.
+	ActionNAK
+)
+
+// String returns the name of the action.
+func (a Action) String() string {
+	switch a {
+	case ActionDefault:
+		return "default"
+	case ActionDrop:
+		return "drop"
+	case ActionRetry:
+		return "retry"
+	case ActionNAK:
+		return "nak"
+	}
+
+	return "unknown"
+}
+
+// ErrorPolicy configures the action for each class of backend read error, see the errors in the data package.
+//
+// The zero value drops messages for every class, except for unavailable errors, which are retried once.
+type ErrorPolicy struct {
+	// NotFound is the action for data.ErrNotFound errors. Defaults to ActionDrop.
+	NotFound Action
+
+	// Unauthorized is the action for data.ErrUnauthorized errors. Defaults to ActionDrop.
+	Unauthorized Action
+
+	// Unavailable is the action for data.ErrUnavailable errors and read timeouts. Defaults to ActionRetry.
+	// Reads that timed out are not retried, they already took the full read timeout.
+	Unavailable Action
+
+	// InvalidRecord is the action for data.ErrInvalidRecord errors, including records that fail Validation. Defaults to ActionDrop.
+	InvalidRecord Action
+
+	// Other is the action for all other errors. Defaults to ActionDrop.
+	Other Action
+
+	// Authoritative must be set for ActionNAK to send a DHCPNAK. Only a server that is authoritative for the network,
+	// that is, no other DHCP server could have a lease for the client, should NAK a request. See RFC 2131, section 4.3.2.
+	Authoritative bool
+}
+
+// action returns the action for the backend read error err.
+func (p ErrorPolicy) action(err error) Action {
+	var a, def Action
+	switch {
+	case data.IsNotFound(err):
+		a, def = p.NotFound, ActionDrop
+	case data.IsUnauthorized(err):
+		a, def = p.Unauthorized, ActionDrop
+	case errors.Is(err, ErrReadTimeout):
+		a, def = p.Unavailable, ActionDrop
+		if a == ActionRetry {
+			a = ActionDrop
+		}
+	case data.IsUnavailable(err):
+		a, def = p.Unavailable, ActionRetry
+	case data.IsInvalidRecord(err):
+		a, def = p.InvalidRecord, ActionDrop
+	default:
+		a, def = p.Other, ActionDrop
+	}
+	if a == ActionDefault {
+		a = def
+	}
+	if a == ActionNAK && !p.Authoritative {
+		a = ActionDrop
+	}
+
+	return a
+}
+
+// OptionPolicy controls which DHCP options set from the backend record are put on the wire, for example
+// to suppress NTP servers or the domain search list on a network without editing every record.
+// Options are given by code, for example 42 for NTP servers.
+//
+// The message type (53), server identifier (54), and lease time (51) are required in a reply and always sent.
+// The options of a netboot reply are not affected either. The zero value sends every option.
+type OptionPolicy struct {
+	// Include, when set, are the only options that are sent.
+	Include []uint8
+
+	// Exclude are options that are never sent. It takes precedence over Include.
+	Exclude []uint8
+}
+
+// allowed returns true if the option code can be sent.
+func (p OptionPolicy) allowed(code uint8) bool {
+	switch code {
+	case dhcpv4.OptionDHCPMessageType.Code(), dhcpv4.OptionServerIdentifier.Code(), dhcpv4.OptionIPAddressLeaseTime.Code():
+		return true
+	}
+	if slices.Contains(p.Exclude, code) {
+		return false
+	}
+
+	return len(p.Include) == 0 || slices.Contains(p.Include, code)
+}
+
+// filter returns a modifier that removes the options that are not allowed from a reply.
+func (p OptionPolicy) filter() dhcpv4.Modifier {
+	return func(d *dhcpv4.DHCPv4) {
+		for code := range d.Options {
+			if !p.allowed(code) {
+				delete(d.Options, code)
+			}
+		}
+	}
+}
+
+// nak returns a DHCPNAK in reply to the DHCPREQUEST pkt.
+// Per RFC 2131, section 4.3.2, yiaddr and siaddr are zero and no options other than the message type,
+// server identifier, and message are set.
+func (h *Handler) nak(pkt *dhcpv4.DHCPv4, msg string) (*dhcpv4.DHCPv4, error) {
+	return dhcpv4.NewReplyFromRequest(pkt,
+		dhcpv4.WithMessageType(dhcpv4.MessageTypeNak),
+		dhcpv4.WithGeneric(dhcpv4.OptionServerIdentifier, h.IPAddr.AsSlice()),
+		dhcpv4.WithOption(dhcpv4.OptMessage(msg)),
+	)
+}
+
+// nakDestination returns the destination of a DHCPNAK.
+// From page 23 of https://www.ietf.org/rfc/rfc2131.txt: "In all cases, when 'giaddr' is zero, the server broadcasts
+// any DHCPNAK messages to 0xffffffff." When giaddr is set, the DHCPNAK is sent to the relay agent,
+// and the broadcast bit is set so that the relay agent broadcasts it to the client.
+func nakDestination(reply *dhcpv4.DHCPv4) net.Addr {
+	if giaddr := reply.GatewayIPAddr; giaddr != nil && !giaddr.IsUnspecified() {
+		reply.SetBroadcast()
+
+		return &net.UDPAddr{IP: giaddr, Port: dhcpv4.ServerPort}
+	}
+
+	return &net.UDPAddr{IP: net.IPv4bcast, Port: dhcpv4.ClientPort}
+}
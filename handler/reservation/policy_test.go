@@ -0,0 +1,197 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/backend/mock"
+	"github.com/tinkerbell/dhcp/data"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/nettest"
+)
+
+func TestErrorPolicyAction(t *testing.T) {
+	unavailable := fmt.Errorf("%w: 503", data.ErrUnavailable)
+	timeout := fmt.Errorf("%w after 5s: %w", ErrReadTimeout, context.DeadlineExceeded)
+	tests := map[string]struct {
+		policy ErrorPolicy
+		err    error
+		want   Action
+	}{
+		"not found":                     {err: fmt.Errorf("record %w", data.ErrNotFound), want: ActionDrop},
+		"legacy not found":              {err: hwNotFoundError{}, want: ActionDrop},
+		"unauthorized":                  {err: data.ErrUnauthorized, want: ActionDrop},
+		"unavailable":                   {err: unavailable, want: ActionRetry},
+		"invalid record":                {err: data.ErrInvalidRecord, want: ActionDrop},
+		"other":                         {err: errBadBackend, want: ActionDrop},
+		"timeout is not retried":        {err: timeout, want: ActionDrop},
+		"timeout uses unavailable":      {policy: ErrorPolicy{Unavailable: ActionNAK, Authoritative: true}, err: timeout, want: ActionNAK},
+		"configured":                    {policy: ErrorPolicy{Unavailable: ActionDrop}, err: unavailable, want: ActionDrop},
+		"nak when authoritative":        {policy: ErrorPolicy{NotFound: ActionNAK, Authoritative: true}, err: hwNotFoundError{}, want: ActionNAK},
+		"no nak when not authoritative": {policy: ErrorPolicy{NotFound: ActionNAK}, err: hwNotFoundError{}, want: ActionDrop},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.policy.action(tt.err); got != tt.want {
+				t.Fatalf("action() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBackendRetry(t *testing.T) {
+	tests := map[string]struct {
+		policy    ErrorPolicy
+		err       error
+		failures  int
+		wantCalls int
+		wantErr   error
+	}{
+		"unavailable is retried once": {err: data.ErrUnavailable, failures: 1, wantCalls: 2},
+		"only once":                   {err: data.ErrUnavailable, failures: 2, wantCalls: 2, wantErr: data.ErrUnavailable},
+		"retry disabled":              {policy: ErrorPolicy{Unavailable: ActionDrop}, err: data.ErrUnavailable, failures: 1, wantCalls: 1, wantErr: data.ErrUnavailable},
+		"not found is not retried":    {err: data.ErrNotFound, failures: 1, wantCalls: 1, wantErr: data.ErrNotFound},
+		"other errors can be retried": {policy: ErrorPolicy{Other: ActionRetry}, err: errBadBackend, failures: 1, wantCalls: 2},
+		"unauthorized is not retried": {err: data.ErrUnauthorized, failures: 1, wantCalls: 1, wantErr: data.ErrUnauthorized},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// the first failures reads fail with err, and the next ones succeed.
+			responses := make([]mock.Response, tt.failures, tt.failures+1)
+			for i := range responses {
+				responses[i] = mock.Response{Err: tt.err}
+			}
+			b := mock.New(mock.WithDefault(append(responses, mock.Response{DHCP: testDHCP(), Netboot: &data.Netboot{}})...))
+			h := &Handler{Backend: b, ErrorPolicy: tt.policy}
+			_, _, err := h.readBackend(context.Background(), net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readBackend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := len(b.Calls()); got != tt.wantCalls {
+				t.Fatalf("got %d calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestNAK(t *testing.T) {
+	tests := map[string]struct {
+		giaddr        net.IP
+		want          net.Addr
+		wantBroadcast bool
+	}{
+		"no relay": {giaddr: net.IPv4zero, want: &net.UDPAddr{IP: net.IPv4bcast, Port: dhcpv4.ClientPort}},
+		"relay":    {giaddr: net.IP{192, 168, 1, 1}, want: &net.UDPAddr{IP: net.IP{192, 168, 1, 1}, Port: dhcpv4.ServerPort}, wantBroadcast: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{IPAddr: netip.MustParseAddr("192.168.1.10")}
+			req, err := dhcpv4.New(
+				dhcpv4.WithMessageType(dhcpv4.MessageTypeRequest),
+				dhcpv4.WithHwAddr(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}),
+				dhcpv4.WithGatewayIP(tt.giaddr),
+				dhcpv4.WithClientIP(net.IP{192, 168, 1, 100}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reply, err := h.nak(req, "no reservation available")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(dhcpv4.MessageTypeNak, reply.MessageType()); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(net.IP{192, 168, 1, 10}, reply.ServerIdentifier().To4()); diff != "" {
+				t.Fatal(diff)
+			}
+			if !reply.YourIPAddr.IsUnspecified() || !reply.ServerIPAddr.IsUnspecified() || !reply.ClientIPAddr.IsUnspecified() {
+				t.Fatalf("got yiaddr %v, siaddr %v, and ciaddr %v, want them unspecified", reply.YourIPAddr, reply.ServerIPAddr, reply.ClientIPAddr)
+			}
+			if reply.Options.Has(dhcpv4.OptionIPAddressLeaseTime) {
+				t.Fatal("a NAK must not have a lease time")
+			}
+			if diff := cmp.Diff(tt.want.String(), nakDestination(reply).String()); diff != "" {
+				t.Fatal(diff)
+			}
+			if reply.IsBroadcast() != tt.wantBroadcast {
+				t.Fatalf("got broadcast %v, want %v", reply.IsBroadcast(), tt.wantBroadcast)
+			}
+		})
+	}
+}
+
+func TestHandleNAKReplyToPeer(t *testing.T) {
+	conn, err := nettest.NewLocalPacketListener("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	h := &Handler{
+		Backend:     failingBackend(data.ErrUnavailable),
+		IPAddr:      netip.MustParseAddr("127.0.0.1"),
+		ErrorPolicy: ErrorPolicy{Unavailable: ActionNAK, Authoritative: true},
+	}
+	req, err := dhcpv4.New(dhcpv4.WithMessageType(dhcpv4.MessageTypeRequest), dhcpv4.WithHwAddr(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a NAK is broadcast, unless the server asks for every reply to be written to the peer.
+	h.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: pc.LocalAddr(), Pkt: req, Md: &data.Metadata{ReplyToPeer: true}})
+	reply, err := client(pc)
+	if err != nil {
+		t.Fatalf("got no reply at the peer: %v", err)
+	}
+	if diff := cmp.Diff(dhcpv4.MessageTypeNak, reply.MessageType()); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestOptionPolicy(t *testing.T) {
+	d := &data.DHCP{
+		IPAddress:    netip.MustParseAddr("192.168.4.4"),
+		SubnetMask:   []byte{255, 255, 255, 0},
+		LeaseTime:    3600,
+		NTPServers:   []net.IP{{132, 163, 96, 2}},
+		DomainSearch: []string{"example.com"},
+	}
+	tests := map[string]struct {
+		policy OptionPolicy
+		want   []uint8
+	}{
+		"all":           {want: []uint8{1, 15, 42, 51, 53, 54, 119}},
+		"exclude":       {policy: OptionPolicy{Exclude: []uint8{42, 119}}, want: []uint8{1, 15, 51, 53, 54}},
+		"include":       {policy: OptionPolicy{Include: []uint8{42}}, want: []uint8{42, 51, 53, 54}},
+		"exclude first": {policy: OptionPolicy{Include: []uint8{1, 42}, Exclude: []uint8{42}}, want: []uint8{1, 51, 53, 54}},
+		"required":      {policy: OptionPolicy{Exclude: []uint8{51, 53, 54}}, want: []uint8{1, 15, 42, 51, 53, 54, 119}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{IPAddr: netip.MustParseAddr("127.0.0.1"), OptionPolicy: tt.policy}
+			req, err := dhcpv4.NewDiscovery(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+			if err != nil {
+				t.Fatal(err)
+			}
+			reply := h.updateMsg(context.Background(), req, d, nil, dhcpv4.MessageTypeOffer)
+			var got []uint8
+			for code := range reply.Options {
+				got = append(got, code)
+			}
+			if diff := cmp.Diff(tt.want, got, cmpopts.SortSlices(func(a, b uint8) bool { return a < b })); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
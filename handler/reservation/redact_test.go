@@ -0,0 +1,150 @@
+package reservation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/backend/cache"
+	"github.com/tinkerbell/dhcp/backend/mock"
+	"github.com/tinkerbell/dhcp/backend/static"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler"
+	"github.com/tinkerbell/dhcp/redact"
+	"github.com/tonglil/buflogr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/nettest"
+)
+
+// recorder is a tracer provider that records the attributes, statuses, and events of all the spans that are started.
+type recorder struct {
+	noop.TracerProvider
+	mu       sync.Mutex
+	recorded []string
+}
+
+func (r *recorder) Tracer(string, ...trace.TracerOption) trace.Tracer { return recordTracer{r: r} }
+
+func (r *recorder) record(kv ...attribute.KeyValue) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, a := range kv {
+		r.recorded = append(r.recorded, string(a.Key)+"="+a.Value.Emit())
+	}
+}
+
+type recordTracer struct {
+	noop.Tracer
+	r *recorder
+}
+
+func (t recordTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	c := trace.NewSpanStartConfig(opts...)
+	t.r.record(c.Attributes()...)
+	s := recordSpan{Span: trace.SpanFromContext(ctx), r: t.r}
+	t.r.record(attribute.String("span", name))
+
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+type recordSpan struct {
+	trace.Span
+	r *recorder
+}
+
+func (s recordSpan) SetAttributes(kv ...attribute.KeyValue) { s.r.record(kv...) }
+
+func (s recordSpan) SetStatus(_ codes.Code, description string) {
+	s.r.record(attribute.String("status", description))
+}
+
+func (s recordSpan) AddEvent(name string, opts ...trace.EventOption) {
+	c := trace.NewEventConfig(opts...)
+	s.r.record(append(c.Attributes(), attribute.String("event", name))...)
+}
+
+func (s recordSpan) End(...trace.SpanEndOption) {}
+
+func TestHandleRedact(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	found, err := static.New(static.Record{
+		DHCP:    data.DHCP{MACAddress: mac, IPAddress: netip.MustParseAddr("192.168.2.150"), Hostname: "server-01"},
+		Netboot: data.Netboot{AllowNetboot: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// backend errors usually name the MAC address that was read.
+	failing := mock.New(mock.WithDefault(mock.Response{Err: fmt.Errorf("%w: failed listing hardware for (%v)", data.ErrUnavailable, mac)}))
+	// a not found read that is cached, so that the handler gets it from the cache.
+	cached := cache.NewBackend(mock.New(mock.WithDefault(mock.Response{Err: fmt.Errorf("%w: no hardware for (%v)", data.ErrNotFound, mac)})))
+	cached.NegativeTTL = time.Minute
+	if _, _, err := cached.GetByMac(context.Background(), mac); !errors.Is(err, data.ErrNotFound) {
+		t.Fatalf("GetByMac() error = %v, want %v", err, data.ErrNotFound)
+	}
+	tests := map[string]struct {
+		backend handler.BackendReader
+		mt      dhcpv4.MessageType
+		policy  ErrorPolicy
+	}{
+		"found":      {backend: found, mt: dhcpv4.MessageTypeDiscover},
+		"read error": {backend: failing, mt: dhcpv4.MessageTypeDiscover},
+		"nak":        {backend: failing, mt: dhcpv4.MessageTypeRequest, policy: ErrorPolicy{Unavailable: ActionNAK}},
+		"cached":     {backend: cached, mt: dhcpv4.MessageTypeDiscover},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := &recorder{}
+			prev := otel.GetTracerProvider()
+			otel.SetTracerProvider(rec)
+			defer otel.SetTracerProvider(prev)
+
+			var buf bytes.Buffer
+			h := &Handler{
+				Backend:     tt.backend,
+				IPAddr:      netip.MustParseAddr("127.0.0.1"),
+				Log:         buflogr.NewWithBuffer(&buf),
+				DecisionLog: true,
+				ErrorPolicy: tt.policy,
+				Redact:      redact.Redactor{Mode: redact.ModeHash},
+			}
+			conn, err := nettest.NewLocalPacketListener("udp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			req, err := dhcpv4.New(dhcpv4.WithHwAddr(mac), dhcpv4.WithMessageType(tt.mt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: 68}, Pkt: req})
+
+			if buf.Len() == 0 || len(rec.recorded) == 0 {
+				t.Fatalf("got %d bytes of logs and %d span records, want both", buf.Len(), len(rec.recorded))
+			}
+			for _, raw := range []string{mac.String(), "server-01"} {
+				if strings.Contains(buf.String(), raw) {
+					t.Errorf("log contains %q:\n%s", raw, buf.String())
+				}
+				for _, r := range rec.recorded {
+					if strings.Contains(r, raw) {
+						t.Errorf("span record %q contains %q", r, raw)
+					}
+				}
+			}
+		})
+	}
+}
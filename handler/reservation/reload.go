@@ -0,0 +1,124 @@
+package reservation
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// Config holds the settings of a Handler that can be replaced while it is serving, see Reload.
+// The fields are documented on the Handler fields of the same name.
+type Config struct {
+	IPAddr        netip.Addr
+	Netboot       Netboot
+	OTELEnabled   bool
+	Traceparent   Traceparent
+	SyslogAddr    netip.Addr
+	ReadTimeout   time.Duration
+	SlowThreshold SlowThreshold
+	Validation    Validation
+	ErrorPolicy   ErrorPolicy
+	OptionPolicy  OptionPolicy
+	Tracing       TracingPolicy
+	DecisionLog   bool
+}
+
+// errInvalidConfig is returned by Reload when the new configuration is not valid.
+var errInvalidConfig = errors.New("invalid handler configuration")
+
+// configHolder is the value stored in Handler.reloaded.
+type configHolder struct {
+	Config
+}
+
+// Reload replaces the settings of the handler with c, for example when the configuration file is reloaded on SIGHUP.
+// It is safe to call while the handler is serving and does not affect the listener: messages in flight are finished with
+// the previous settings, and later messages use c. An invalid c is rejected with an error and the settings are not changed.
+func (h *Handler) Reload(c Config) error {
+	if !c.IPAddr.Is4() {
+		return fmt.Errorf("%w: IP address %q is not an IPv4 address", errInvalidConfig, c.IPAddr)
+	}
+	if c.SyslogAddr.IsValid() && !c.SyslogAddr.Is4() {
+		return fmt.Errorf("%w: syslog address %q is not an IPv4 address", errInvalidConfig, c.SyslogAddr)
+	}
+	for _, p := range c.Validation.Subnets {
+		if !p.IsValid() || !p.Addr().Is4() {
+			return fmt.Errorf("%w: subnet %q is not an IPv4 prefix", errInvalidConfig, p)
+		}
+	}
+	if c.Validation.MaxLeaseTime > 0 && c.Validation.MinLeaseTime > c.Validation.MaxLeaseTime {
+		return fmt.Errorf("%w: minimum lease time %d is more than the maximum of %d", errInvalidConfig, c.Validation.MinLeaseTime, c.Validation.MaxLeaseTime)
+	}
+	if err := c.Tracing.validate(); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidConfig, err)
+	}
+	if err := c.Traceparent.validate(); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidConfig, err)
+	}
+	if err := c.SlowThreshold.validate(); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidConfig, err)
+	}
+	h.reloaded.Store(configHolder{Config: c})
+
+	return nil
+}
+
+// Config returns the settings the handler is serving with: the last Config passed to Reload,
+// or the settings of the Handler fields if Reload was never called.
+func (h *Handler) Config() Config {
+	if c, ok := h.reloaded.Load().(configHolder); ok {
+		return c.Config
+	}
+
+	return Config{
+		IPAddr:        h.IPAddr,
+		Netboot:       h.Netboot,
+		OTELEnabled:   h.OTELEnabled,
+		Traceparent:   h.Traceparent,
+		SyslogAddr:    h.SyslogAddr,
+		ReadTimeout:   h.ReadTimeout,
+		SlowThreshold: h.SlowThreshold,
+		Validation:    h.Validation,
+		ErrorPolicy:   h.ErrorPolicy,
+		OptionPolicy:  h.OptionPolicy,
+		Tracing:       h.Tracing,
+		DecisionLog:   h.DecisionLog,
+	}
+}
+
+// current returns the handler to handle a message with. It is h, or when Reload was called, a copy of h with
+// the reloaded settings, so that a message is handled with one set of settings even if Reload is called meanwhile.
+func (h *Handler) current() *Handler {
+	c, ok := h.reloaded.Load().(configHolder)
+	if !ok {
+		return h
+	}
+
+	cur := &Handler{
+		Backend:           h.backend(),
+		Writer:            h.Writer,
+		Notifier:          h.Notifier,
+		IPAddr:            c.IPAddr,
+		Log:               h.Log,
+		Netboot:           c.Netboot,
+		OTELEnabled:       c.OTELEnabled,
+		Traceparent:       c.Traceparent,
+		SyslogAddr:        c.SyslogAddr,
+		LocalGatewayFirst: h.LocalGatewayFirst,
+		Redact:            h.Redact,
+		Vendors:           h.Vendors,
+		BackendMetrics:    h.BackendMetrics,
+		Metrics:           h.Metrics,
+		ReadTimeout:       c.ReadTimeout,
+		SlowThreshold:     c.SlowThreshold,
+		Validation:        c.Validation,
+		ErrorPolicy:       c.ErrorPolicy,
+		OptionPolicy:      c.OptionPolicy,
+		Tracing:           c.Tracing,
+		DecisionLog:       c.DecisionLog,
+	}
+	cur.reads.Store(h.readTracker())
+
+	return cur
+}
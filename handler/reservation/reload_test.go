@@ -0,0 +1,110 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/stdr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+func TestReload(t *testing.T) {
+	tests := map[string]struct {
+		config  Config
+		wantErr error
+	}{
+		"valid":                                {config: Config{IPAddr: netip.MustParseAddr("192.168.2.1"), Validation: Validation{Subnets: []netip.Prefix{netip.MustParsePrefix("192.168.2.0/24")}}}},
+		"no IP address":                        {config: Config{}, wantErr: errInvalidConfig},
+		"IPv6 address":                         {config: Config{IPAddr: netip.MustParseAddr("2001:db8::1")}, wantErr: errInvalidConfig},
+		"IPv6 syslog address":                  {config: Config{IPAddr: netip.MustParseAddr("192.168.2.1"), SyslogAddr: netip.MustParseAddr("2001:db8::1")}, wantErr: errInvalidConfig},
+		"IPv6 subnet":                          {config: Config{IPAddr: netip.MustParseAddr("192.168.2.1"), Validation: Validation{Subnets: []netip.Prefix{netip.MustParsePrefix("2001:db8::/64")}}}, wantErr: errInvalidConfig},
+		"lease time bounds":                    {config: Config{IPAddr: netip.MustParseAddr("192.168.2.1"), Validation: Validation{MinLeaseTime: 7200, MaxLeaseTime: 3600}}, wantErr: errInvalidConfig},
+		"sample rate over 1":                   {config: Config{IPAddr: netip.MustParseAddr("192.168.2.1"), Tracing: TracingPolicy{SampleRates: map[dhcpv4.MessageType]float64{dhcpv4.MessageTypeRequest: 2}}}, wantErr: errInvalidConfig},
+		"traceparent option not site-specific": {config: Config{IPAddr: netip.MustParseAddr("192.168.2.1"), Traceparent: Traceparent{Mode: TraceparentOption, Code: 43}}, wantErr: errInvalidConfig},
+		"negative slow threshold":              {config: Config{IPAddr: netip.MustParseAddr("192.168.2.1"), SlowThreshold: SlowThreshold{Read: -time.Second}}, wantErr: errInvalidConfig},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{IPAddr: netip.MustParseAddr("192.168.1.1")}
+			err := h.Reload(tt.config)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Reload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			want := tt.config
+			if tt.wantErr != nil {
+				// the settings are not changed.
+				want = Config{IPAddr: netip.MustParseAddr("192.168.1.1")}
+			}
+			if diff := cmp.Diff(want, h.Config(), cmpopts.EquateComparable(netip.Addr{}, netip.AddrPort{}, netip.Prefix{})); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestReloadHandle(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	h := &Handler{Backend: newMockBackend(data.Netboot{}), IPAddr: netip.MustParseAddr("192.168.1.1")}
+	pkt, err := dhcpv4.New(dhcpv4.WithHwAddr(mac), dhcpv4.WithMessageType(dhcpv4.MessageTypeDiscover))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverID := func(h *Handler) net.IP {
+		d, n, err := h.readBackend(context.Background(), mac)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h.updateMsg(context.Background(), pkt, d, n, dhcpv4.MessageTypeOffer).ServerIdentifier().To4()
+	}
+	if got := serverID(h.current()); !got.Equal(net.IP{192, 168, 1, 1}) {
+		t.Fatalf("got server identifier %v, want 192.168.1.1", got)
+	}
+
+	if err := h.Reload(Config{IPAddr: netip.MustParseAddr("192.168.1.2"), Netboot: Netboot{IPXEBinServerHTTP: &url.URL{Scheme: "http", Host: "192.168.1.2"}}}); err != nil {
+		t.Fatal(err)
+	}
+	// a swapped backend is still used after a reload.
+	h.SwapBackend(&namedBackend{Backend: newMockBackend(data.Netboot{}), hostname: "swapped"})
+	cur := h.current()
+	if got := serverID(cur); !got.Equal(net.IP{192, 168, 1, 2}) {
+		t.Fatalf("got server identifier %v, want 192.168.1.2", got)
+	}
+	d, _, err := cur.readBackend(context.Background(), mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("swapped", d.Hostname); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestReloadConcurrent(t *testing.T) {
+	h := &Handler{Backend: newMockBackend(data.Netboot{}), IPAddr: netip.MustParseAddr("192.168.1.1"), Log: stdr.New(log.New(io.Discard, "", 0))}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_ = h.Reload(Config{IPAddr: netip.AddrFrom4([4]byte{192, 168, 1, byte(i + 1)})})
+		}(i)
+		go func() {
+			defer wg.Done()
+			if c := h.current(); !c.IPAddr.Is4() {
+				t.Errorf("got IP address %v, want an IPv4 address", c.IPAddr)
+			}
+			_, _, _ = h.current().readBackend(context.Background(), net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+		}()
+	}
+	wg.Wait()
+}
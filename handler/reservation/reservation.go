@@ -2,19 +2,44 @@
 package reservation
 
 import (
+	"context"
+	"errors"
 	"net/netip"
 	"net/url"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/backend/metrics"
+	"github.com/tinkerbell/dhcp/event"
 	"github.com/tinkerbell/dhcp/handler"
+	metricsdhcp "github.com/tinkerbell/dhcp/metrics"
+	"github.com/tinkerbell/dhcp/oui"
+	"github.com/tinkerbell/dhcp/redact"
 )
 
+// DefaultReadTimeout is the default time a backend read can take before it is abandoned.
+const DefaultReadTimeout = 5 * time.Second
+
+// ErrReadTimeout is returned, wrapping context.DeadlineExceeded, when a backend read takes longer than the read timeout.
+var ErrReadTimeout = errors.New("backend read timed out")
+
 // Handler holds the configuration details for the running the DHCP server.
 type Handler struct {
 	// Backend is the backend to use for getting DHCP data.
 	Backend handler.BackendReader
 
+	// Writer, when set, records ACKs, releases, and declines in a backend.
+	// It is commonly the same backend as Backend, for example the kube backend.
+	Writer handler.BackendWriter
+
+	// Notifier, when set, is notified of the offers, acks, and naks sent, the releases and declines received, and the
+	// backend errors. Events are sent after the reply and their errors are only logged.
+	// Use an event.Queue so that a slow receiver does not delay the handling of messages.
+	// The MAC addresses, hostnames, and errors of the events are redacted with Redact.
+	Notifier event.Notifier
+
 	// IPAddr is the IP address to use in DHCP responses.
 	// Option 54 and the sname DHCP header.
 	// This could be a load balancer IP address or an ingress IP address or a local IP address.
@@ -33,8 +58,141 @@ type Handler struct {
 	// <original filename>-00-<trace id>-<span id>-<trace flags>
 	OTELEnabled bool
 
+	// Traceparent configures the option the traceparent is sent in. By default it is sub-option 69 of option 43.
+	Traceparent Traceparent
+
 	// SyslogAddr is the address to send syslog messages to. DHCP Option 7.
 	SyslogAddr netip.Addr
+
+	// LocalGatewayFirst moves the default gateways of a record that are in the subnet of its IP address
+	// ahead of the others in option 3. Otherwise the order of the record is kept.
+	// Some clients only use the first router in option 3, this makes sure it is one that they can reach.
+	LocalGatewayFirst bool
+
+	// BackendMetrics enables metrics for Backend reads when set.
+	// The backend label is the type of Backend, for example "*kube.Backend".
+	BackendMetrics *metrics.Metrics
+
+	// Metrics, when set, records the replies that are sent, and the messages that are not replied to because
+	// the backend read or the send failed.
+	Metrics metricsdhcp.Metrics
+
+	// ReadTimeout bounds each backend read. A read that takes longer is abandoned and no reply is sent,
+	// even if the backend does not honor the context cancellation, so a hung backend does not stall the handler.
+	// Defaults to DefaultReadTimeout. A negative value disables the timeout.
+	ReadTimeout time.Duration
+
+	// SlowThreshold configures when the handling of a message or a backend read is slow, and is logged and counted.
+	// By default neither is.
+	SlowThreshold SlowThreshold
+
+	// Validation configures the checks that records from the backend must pass before a reply is built from them.
+	// Records that fail are handled as configured by ErrorPolicy.InvalidRecord, by default they are not replied to.
+	Validation Validation
+
+	// ErrorPolicy configures what is done with a message when the backend read for it fails, by class of error.
+	ErrorPolicy ErrorPolicy
+
+	// OptionPolicy configures which options from the backend records are sent in replies.
+	OptionPolicy OptionPolicy
+
+	// Vendors, when set, looks up the vendor of the NIC of each client, for example with oui.Default(). The vendor is
+	// added to the logs of the message, in the vendor key, and to its span, in the DHCP.vendor attribute.
+	Vendors oui.Lookup
+
+	// Redact redacts the MAC addresses and hostnames of clients in the logs and span attributes of the handler,
+	// including the text of backend errors. It is passed to the backends in the context of their reads, see
+	// redact.FromContext, so that their spans are redacted too.
+	Redact redact.Redactor
+
+	// Tracing configures which messages are traced with a span. By default every message is.
+	Tracing TracingPolicy
+
+	// DecisionLog, when set, logs one record for each handled message with its whole decision path:
+	// the result of the backend read, whether the client is a netboot client and the check it failed,
+	// its arch and user class, the boot file and next server chosen, and the type of the reply or why none was sent.
+	// It is meant for debugging, the record repeats what the other logs of the message say.
+	DecisionLog bool
+
+	// swapped holds the backendHolder set by SwapBackend. It takes precedence over Backend.
+	swapped atomic.Value
+
+	// reloaded holds the configHolder set by Reload. It takes precedence over the fields of Config.
+	reloaded atomic.Value
+
+	// maintenance holds the bool set by SetMaintenance.
+	maintenance atomic.Value
+
+	// exchanges holds the *exchanges that traces the messages of an exchange in one trace, see exchangeTracker.
+	exchanges atomic.Value
+
+	// reads holds the *reads that tracks the backend reads, see readTracker.
+	reads atomic.Value
+}
+
+// backendHolder gives every value stored in Handler.swapped the same concrete type, as atomic.Value requires.
+type backendHolder struct {
+	handler.BackendReader
+}
+
+// SwapBackend replaces the backend used for reads with b, for example after reloading the configuration or rotating credentials.
+// It is safe to call while the handler is serving: reads in flight finish with the previous backend and
+// later reads use b, so the listener does not have to be restarted. A nil b reverts to Backend.
+func (h *Handler) SwapBackend(b handler.BackendReader) {
+	h.swapped.Store(backendHolder{BackendReader: b})
+}
+
+// backend returns the backend used for reads: the last backend passed to SwapBackend, or Backend if it was never called.
+func (h *Handler) backend() handler.BackendReader {
+	if b, ok := h.swapped.Load().(backendHolder); ok && b.BackendReader != nil {
+		return b.BackendReader
+	}
+
+	return h.Backend
+}
+
+// exchangeTracker returns the exchanges of h, creating them on first use.
+func (h *Handler) exchangeTracker() *exchanges {
+	if e, ok := h.exchanges.Load().(*exchanges); ok {
+		return e
+	}
+	h.exchanges.CompareAndSwap(nil, &exchanges{})
+
+	return h.exchanges.Load().(*exchanges)
+}
+
+// reads tracks when a backend read last succeeded, for Health.
+type reads struct {
+	last atomic.Int64 // Unix time in nanoseconds.
+}
+
+// readTracker returns the reads of h, creating them on first use.
+func (h *Handler) readTracker() *reads {
+	if r, ok := h.reads.Load().(*reads); ok {
+		return r
+	}
+	h.reads.CompareAndSwap(nil, &reads{})
+
+	return h.reads.Load().(*reads)
+}
+
+// Health implements handler.HealthReporter. It reports the health check of the backend, when it implements
+// handler.HealthChecker, and when a backend read last succeeded.
+func (h *Handler) Health(ctx context.Context) handler.Status {
+	var s handler.Status
+	if hc, ok := h.backend().(handler.HealthChecker); ok {
+		s.Backend = hc.Healthy(ctx)
+	}
+	if n := h.readTracker().last.Load(); n != 0 {
+		s.LastRead = time.Unix(0, n)
+	}
+
+	return s
+}
+
+// metrics returns Metrics, or metrics that record nothing when Metrics is not set.
+func (h *Handler) metrics() metricsdhcp.Metrics {
+	return metricsdhcp.OrNoop(h.Metrics)
 }
 
 // Netboot holds the netboot configuration details used in running a DHCP server.
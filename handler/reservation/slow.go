@@ -0,0 +1,44 @@
+package reservation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SlowThreshold configures when the handling of a message or a backend read is slow. A slow one is logged with a
+// warning, with the trace ID of the message when it is traced, and counted in the slow metric of Metrics, so that
+// latency regressions of a backend, like Tink or the Kubernetes API, are caught before boots start timing out.
+// A zero threshold disables the detection.
+type SlowThreshold struct {
+	// Handle is the time after which the handling of a message, from when it is received to when its reply is sent, is slow.
+	Handle time.Duration
+
+	// Read is the time after which a backend read, including its retry, is slow.
+	Read time.Duration
+}
+
+// validate returns an error when a threshold is negative.
+func (s SlowThreshold) validate() error {
+	if s.Handle < 0 || s.Read < 0 {
+		return fmt.Errorf("slow thresholds %v and %v must not be negative", s.Handle, s.Read)
+	}
+
+	return nil
+}
+
+// slow logs and records stage, one of the Stage constants of the metrics package, when it took d, more than threshold.
+// span is the span of the message.
+func (h *Handler) slow(log logr.Logger, span trace.Span, stage string, d, threshold time.Duration) {
+	if threshold <= 0 || d <= threshold {
+		return
+	}
+	h.metrics().Slow(stage)
+	kv := []any{"stage", stage, "duration", d.String(), "threshold", threshold.String()}
+	if sc := span.SpanContext(); sc.IsSampled() {
+		kv = append(kv, "traceID", sc.TraceID().String())
+	}
+	log.Info("warning: slow DHCP message handling", kv...)
+}
@@ -0,0 +1,111 @@
+package reservation
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/dhcp/backend/mock"
+	"github.com/tinkerbell/dhcp/data"
+	metricsdhcp "github.com/tinkerbell/dhcp/metrics"
+	"github.com/tonglil/buflogr"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/nettest"
+)
+
+func TestSlow(t *testing.T) {
+	sampled := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x01},
+		TraceFlags: trace.FlagsSampled,
+	})
+	tests := map[string]struct {
+		sc        trace.SpanContext
+		d         time.Duration
+		threshold time.Duration
+		want      []string
+	}{
+		"disabled":   {d: time.Hour},
+		"fast":       {d: time.Millisecond, threshold: time.Second},
+		"slow":       {d: 2 * time.Second, threshold: time.Second, want: []string{"stage handle duration 2s threshold 1s"}},
+		"traced":     {sc: sampled, d: 2 * time.Second, threshold: time.Second, want: []string{"traceID 01000000000000000000000000000000"}},
+		"not traced": {sc: sampled.WithTraceFlags(0), d: 2 * time.Second, threshold: time.Second, want: []string{"threshold 1s"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := prometheus.NewRegistry()
+			m, err := metricsdhcp.New(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			h := &Handler{Metrics: m}
+			span := trace.SpanFromContext(trace.ContextWithSpanContext(context.Background(), tt.sc))
+			h.slow(buflogr.NewWithBuffer(&buf), span, metricsdhcp.StageHandle, tt.d, tt.threshold)
+
+			if got, want := buf.Len() != 0, len(tt.want) != 0; got != want {
+				t.Fatalf("logged = %v, want %v: %s", got, want, buf.String())
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(buf.String(), w) {
+					t.Errorf("log %q does not contain %q", buf.String(), w)
+				}
+			}
+			if strings.Contains(buf.String(), "traceID") != tt.sc.IsSampled() {
+				t.Errorf("log %q has a trace ID for a span that is not sampled, or none for a sampled one", buf.String())
+			}
+			if got, _ := testutil.GatherAndCount(r, "dhcp_handler_slow_total"); got != len(tt.want) {
+				t.Errorf("got %d slow series, want %d", got, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestHandleSlow(t *testing.T) {
+	r := prometheus.NewRegistry()
+	m, err := metricsdhcp.New(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	s := &Handler{
+		Backend:       mock.New(mock.WithDefault(mock.Response{DHCP: testDHCP(), Netboot: &data.Netboot{}}), mock.WithDelay(20*time.Millisecond)),
+		IPAddr:        netip.MustParseAddr("127.0.0.1"),
+		Log:           buflogr.NewWithBuffer(&buf),
+		Metrics:       m,
+		SlowThreshold: SlowThreshold{Handle: 10 * time.Millisecond, Read: 10 * time.Millisecond},
+	}
+	conn, err := nettest.NewLocalPacketListener("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	req := &dhcpv4.DHCPv4{
+		OpCode:       dhcpv4.OpcodeBootRequest,
+		ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+		Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover)),
+	}
+
+	s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: 68}, Pkt: req})
+
+	want := `
+# HELP dhcp_handler_slow_total Number of DHCP message handlings and backend reads that took longer than their threshold, by stage.
+# TYPE dhcp_handler_slow_total counter
+dhcp_handler_slow_total{stage="backend_read"} 1
+dhcp_handler_slow_total{stage="handle"} 1
+`
+	if err := testutil.GatherAndCompare(r, strings.NewReader(want), "dhcp_handler_slow_total"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(buf.String(), "slow DHCP message handling"); got != 2 {
+		t.Fatalf("got %d slow logs, want 2: %s", got, buf.String())
+	}
+}
@@ -0,0 +1,107 @@
+package reservation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/otel"
+)
+
+// TraceparentMode is where the binary traceparent of a message is sent in the netboot options of its reply.
+type TraceparentMode int
+
+const (
+	// TraceparentSubOption sends the traceparent in a sub-option of option 43, by default DefaultTraceparentSubOption.
+	TraceparentSubOption TraceparentMode = iota
+	// TraceparentOption sends the traceparent in a site-specific option, by default DefaultTraceparentOption.
+	TraceparentOption
+	// TraceparentNone does not send the binary traceparent.
+	TraceparentNone
+)
+
+const (
+	// DefaultTraceparentSubOption is the default sub-option of option 43 of TraceparentSubOption.
+	DefaultTraceparentSubOption = 69
+	// DefaultTraceparentOption is the default option of TraceparentOption.
+	DefaultTraceparentOption = 224
+)
+
+// String returns the name of the mode.
+func (m TraceparentMode) String() string {
+	switch m {
+	case TraceparentSubOption:
+		return "suboption"
+	case TraceparentOption:
+		return "option"
+	case TraceparentNone:
+		return "none"
+	}
+
+	return "unknown"
+}
+
+// Traceparent configures how the traceparent of a message is propagated to netboot clients, so that the spans of
+// iPXE and of the services it boots from are in the trace of the DHCP exchange.
+//
+// The traceparent is sent in binary, 26 bytes, in the option set by Mode. When OTELEnabled is set, it is also
+// appended to the boot file name. The zero value sends it in sub-option 69 of option 43, which some vendors use
+// for their own sub-options: move it to another sub-option or to a site-specific option, from 224 to 254, to avoid
+// the collision, or set Mode to TraceparentNone and OTELEnabled to false to not propagate the traceparent at all.
+type Traceparent struct {
+	// Mode is where the traceparent is sent.
+	Mode TraceparentMode
+
+	// Code is the number of the sub-option of option 43 or of the option the traceparent is sent in.
+	// Defaults to DefaultTraceparentSubOption or DefaultTraceparentOption.
+	Code uint8
+}
+
+// validate returns an error when Code is not a sub-option that can be used with the PXE sub-options of option 43,
+// or not a site-specific option.
+func (t Traceparent) validate() error {
+	switch t.Mode {
+	case TraceparentSubOption:
+		if t.Code == pxeDiscoveryControl || t.Code == 255 {
+			return fmt.Errorf("traceparent sub-option %d is reserved", t.Code)
+		}
+	case TraceparentOption:
+		if t.Code != 0 && (t.Code < 224 || t.Code == 255) {
+			return fmt.Errorf("traceparent option %d is not a site-specific option, from 224 to 254", t.Code)
+		}
+	case TraceparentNone:
+	default:
+		return fmt.Errorf("unknown traceparent mode %d", t.Mode)
+	}
+
+	return nil
+}
+
+// pxeDiscoveryControl is the PXE sub-option of option 43 that the handler always sets.
+const pxeDiscoveryControl = 6
+
+// code returns the number of the sub-option or option, with its default when Code is not set.
+func (t Traceparent) code() uint8 {
+	switch {
+	case t.Code != 0:
+		return t.Code
+	case t.Mode == TraceparentOption:
+		return DefaultTraceparentOption
+	}
+
+	return DefaultTraceparentSubOption
+}
+
+// subOption adds the traceparent of ctx to the sub-options of option 43 pxe, when Mode is TraceparentSubOption.
+func (t Traceparent) subOption(ctx context.Context, pxe dhcpv4.Options) {
+	if t.Mode == TraceparentSubOption {
+		pxe[t.code()] = otel.TraceparentFromContext(ctx)
+	}
+}
+
+// option sets the traceparent of ctx in its option of d, when Mode is TraceparentOption.
+func (t Traceparent) option(ctx context.Context, d *dhcpv4.DHCPv4) {
+	if t.Mode == TraceparentOption {
+		d.UpdateOption(dhcpv4.OptGeneric(dhcpv4.GenericOptionCode(t.code()), otel.TraceparentFromContext(ctx)))
+	}
+}
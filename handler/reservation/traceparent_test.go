@@ -0,0 +1,31 @@
+package reservation
+
+import "testing"
+
+func TestTraceparent(t *testing.T) {
+	tests := map[string]struct {
+		t        Traceparent
+		wantCode uint8
+		wantErr  bool
+	}{
+		"default":                  {wantCode: 69},
+		"sub-option":               {t: Traceparent{Code: 200}, wantCode: 200},
+		"PXE sub-option":           {t: Traceparent{Code: 6}, wantCode: 6, wantErr: true},
+		"default option":           {t: Traceparent{Mode: TraceparentOption}, wantCode: 224},
+		"option":                   {t: Traceparent{Mode: TraceparentOption, Code: 250}, wantCode: 250},
+		"option not site-specific": {t: Traceparent{Mode: TraceparentOption, Code: 67}, wantCode: 67, wantErr: true},
+		"option end":               {t: Traceparent{Mode: TraceparentOption, Code: 255}, wantCode: 255, wantErr: true},
+		"none":                     {t: Traceparent{Mode: TraceparentNone}, wantCode: 69},
+		"unknown mode":             {t: Traceparent{Mode: 7}, wantCode: 69, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := tt.t.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tt.t.code(); got != tt.wantCode {
+				t.Fatalf("code() = %d, want %d", got, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package reservation
+
+import (
+	"context"
+	crand "crypto/rand"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingPolicy configures which DHCP messages are traced with a span. At thousands of messages a second, a span with
+// all the attributes of every message can overwhelm a collector. The zero value traces every message.
+//
+// A message that is not traced is handled in a context with a span context that is not sampled, so that the spans
+// started for it, for example by backends, are not sampled either with a parent based sampler, the default of the
+// OpenTelemetry SDK. The traceparent sent in the netboot options of its reply is not sampled either.
+type TracingPolicy struct {
+	// Disabled, when set, traces no messages.
+	Disabled bool
+
+	// ErrorsOnly, when set, traces only the messages whose handling failed, for example because the backend read
+	// failed or the reply could not be sent. Their span is started as a new root when the handling is done, with the time
+	// the message was received, and the spans of the backend reads for them are not kept. SampleRates is not used.
+	ErrorsOnly bool
+
+	// SampleRates are the fractions, from 0 to 1, of the messages of each type that are traced.
+	// Messages of the types that are not in SampleRates are always traced. For example,
+	// {dhcpv4.MessageTypeRequest: 0.1} traces every DISCOVER, and one in ten REQUESTs, most of which are renewals.
+	SampleRates map[dhcpv4.MessageType]float64
+}
+
+// validate returns an error when a sample rate is not between 0 and 1.
+func (p TracingPolicy) validate() error {
+	for mt, r := range p.SampleRates {
+		if r < 0 || r > 1 {
+			return fmt.Errorf("sample rate %v of %v messages is not between 0 and 1", r, mt)
+		}
+	}
+
+	return nil
+}
+
+// traced returns true if a message of type mt is traced from when it is received.
+func (p TracingPolicy) traced(mt dhcpv4.MessageType) bool {
+	if p.Disabled || p.ErrorsOnly {
+		return false
+	}
+	r, ok := p.SampleRates[mt]
+
+	return !ok || rand.Float64() < r
+}
+
+// unsampled returns ctx with a new span context that is not sampled, the parent of the spans of a message that is not traced.
+func unsampled(ctx context.Context) context.Context {
+	var tid trace.TraceID
+	var sid trace.SpanID
+	_, _ = crand.Read(tid[:])
+	_, _ = crand.Read(sid[:])
+
+	return trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid, SpanID: sid}))
+}
+
+// errorSpan is the span of a message when TracingPolicy.ErrorsOnly is set. It collects the attributes and the status
+// set on it, and only when it ends with an error status, starts and ends a span with them.
+type errorSpan struct {
+	trace.Span // not recording, the span of the unsampled context of the message.
+
+	ctx    context.Context
+	tracer trace.Tracer
+	name   string
+	start  time.Time
+	attrs  func() []attribute.KeyValue // the attributes to start the span with, only encoded for failed messages.
+
+	set  []attribute.KeyValue
+	code codes.Code
+	desc string
+}
+
+// IsRecording implements trace.Span. It returns true, as the attributes and status are collected.
+func (s *errorSpan) IsRecording() bool {
+	return true
+}
+
+// SetAttributes implements trace.Span.
+func (s *errorSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.set = append(s.set, kv...)
+}
+
+// SetStatus implements trace.Span.
+func (s *errorSpan) SetStatus(code codes.Code, description string) {
+	s.code, s.desc = code, description
+}
+
+// End implements trace.Span. It starts and ends the span of the message when its status is an error.
+func (s *errorSpan) End(options ...trace.SpanEndOption) {
+	s.Span.End(options...)
+	if s.code != codes.Error {
+		return
+	}
+	_, span := s.tracer.Start(s.ctx, s.name, trace.WithNewRoot(), trace.WithTimestamp(s.start), trace.WithAttributes(s.attrs()...), trace.WithAttributes(s.set...))
+	span.SetStatus(s.code, s.desc)
+	span.End(options...)
+}
@@ -0,0 +1,82 @@
+package reservation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTracingPolicyTraced(t *testing.T) {
+	tests := map[string]struct {
+		policy TracingPolicy
+		mt     dhcpv4.MessageType
+		want   bool
+	}{
+		"default":           {mt: dhcpv4.MessageTypeDiscover, want: true},
+		"disabled":          {policy: TracingPolicy{Disabled: true}, mt: dhcpv4.MessageTypeDiscover},
+		"errors only":       {policy: TracingPolicy{ErrorsOnly: true}, mt: dhcpv4.MessageTypeDiscover},
+		"type not sampled":  {policy: TracingPolicy{SampleRates: map[dhcpv4.MessageType]float64{dhcpv4.MessageTypeRequest: 0}}, mt: dhcpv4.MessageTypeRequest},
+		"type always":       {policy: TracingPolicy{SampleRates: map[dhcpv4.MessageType]float64{dhcpv4.MessageTypeRequest: 1}}, mt: dhcpv4.MessageTypeRequest, want: true},
+		"type without rate": {policy: TracingPolicy{SampleRates: map[dhcpv4.MessageType]float64{dhcpv4.MessageTypeRequest: 0}}, mt: dhcpv4.MessageTypeDiscover, want: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.policy.traced(tt.mt); got != tt.want {
+				t.Fatalf("traced() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnsampled(t *testing.T) {
+	sc := trace.SpanContextFromContext(unsampled(context.Background()))
+	if !sc.IsValid() || sc.IsSampled() {
+		t.Fatalf("span context %v is not a valid, unsampled span context", sc)
+	}
+}
+
+// startTracer records the names of the spans it starts.
+type startTracer struct {
+	trace.Tracer
+	started []string
+}
+
+func (s *startTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	s.started = append(s.started, name)
+	return s.Tracer.Start(ctx, name, opts...)
+}
+
+func TestErrorSpan(t *testing.T) {
+	tests := map[string]struct {
+		code        codes.Code
+		wantStarted bool
+	}{
+		"error": {code: codes.Error, wantStarted: true},
+		"ok":    {code: codes.Ok},
+		"unset": {code: codes.Unset},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tracer := &startTracer{Tracer: noop.NewTracerProvider().Tracer("")}
+			var encoded bool
+			attrs := func() []attribute.KeyValue {
+				encoded = true
+				return nil
+			}
+			ctx := unsampled(context.Background())
+			s := &errorSpan{Span: trace.SpanFromContext(ctx), ctx: ctx, tracer: tracer, name: "DHCP Packet Received: DISCOVER", start: time.Now(), attrs: attrs}
+			s.SetAttributes(attribute.String("DHCP.reply", "none"))
+			s.SetStatus(tt.code, "")
+			s.End()
+			if started := len(tracer.started) > 0; started != tt.wantStarted || encoded != tt.wantStarted {
+				t.Fatalf("span started: %v, attributes encoded: %v, want %v", started, encoded, tt.wantStarted)
+			}
+		})
+	}
+}
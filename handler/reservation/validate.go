@@ -0,0 +1,141 @@
+package reservation
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/data"
+)
+
+// DefaultURLSchemes are the URL schemes allowed in netboot URLs when Validation.URLSchemes is not set.
+var DefaultURLSchemes = []string{"http", "https", "tftp"}
+
+// Validation configures the checks that a record from the backend must pass before a reply is built from it.
+//
+// The following are always checked: the MAC address of the record, when set, is the MAC address that was looked up,
+// the IP address is an IPv4 address, the subnet mask, when set, is a valid, non-zero IPv4 mask, the client identifier,
+// when set, is at least 2 bytes and the client GUID 16 bytes, the VLAN tag and priority are in range,
+// SIP servers are set either by name or by IPv4 address, the captive portal URL, when set, is an https URL,
+// the option 125 data of each enterprise fits in 255 bytes, and other options do not set a code that the handler owns,
+// like the message type.
+type Validation struct {
+	// Subnets, when set, are the networks that the IP address of a record must be in.
+	Subnets []netip.Prefix
+
+	// MinLeaseTime and MaxLeaseTime bound the lease time of a record, in seconds. A zero value is no bound.
+	MinLeaseTime uint32
+	MaxLeaseTime uint32
+
+	// URLSchemes are the schemes allowed in the iPXE script URL, OSIE base URL, and HTTP boot URI of a record.
+	// Defaults to DefaultURLSchemes.
+	URLSchemes []string
+}
+
+// validate returns a data.ErrInvalidRecord error describing the first check that the record d and n, read for mac, fails.
+func (v Validation) validate(mac net.HardwareAddr, d *data.DHCP, n *data.Netboot) error {
+	if d == nil {
+		return fmt.Errorf("%w: no DHCP data", data.ErrInvalidRecord)
+	}
+	if len(d.MACAddress) > 0 && !bytes.Equal(d.MACAddress, mac) {
+		return fmt.Errorf("%w: MAC address %v does not match the requested MAC address %v", data.ErrInvalidRecord, d.MACAddress, mac)
+	}
+	ip := d.IPAddress.Unmap()
+	if !ip.Is4() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: IP address %q is not a valid IPv4 address", data.ErrInvalidRecord, d.IPAddress)
+	}
+	if len(v.Subnets) > 0 && !inSubnets(ip, v.Subnets) {
+		return fmt.Errorf("%w: IP address %v is not in an expected subnet", data.ErrInvalidRecord, ip)
+	}
+	if len(d.SubnetMask) > 0 {
+		ones, bits := d.SubnetMask.Size()
+		if bits != 32 || ones == 0 {
+			return fmt.Errorf("%w: subnet mask %v is not a valid IPv4 mask", data.ErrInvalidRecord, net.IP(d.SubnetMask))
+		}
+	}
+	if v.MinLeaseTime > 0 && d.LeaseTime < v.MinLeaseTime {
+		return fmt.Errorf("%w: lease time %d is less than the minimum of %d", data.ErrInvalidRecord, d.LeaseTime, v.MinLeaseTime)
+	}
+	if v.MaxLeaseTime > 0 && d.LeaseTime > v.MaxLeaseTime {
+		return fmt.Errorf("%w: lease time %d is more than the maximum of %d", data.ErrInvalidRecord, d.LeaseTime, v.MaxLeaseTime)
+	}
+	// RFC 2132 requires a client identifier to be a type and at least one byte of identifier.
+	if len(d.ClientIdentifier) == 1 {
+		return fmt.Errorf("%w: client identifier %x is shorter than 2 bytes", data.ErrInvalidRecord, d.ClientIdentifier)
+	}
+	if len(d.ClientGUID) > 0 && len(d.ClientGUID) != 16 {
+		return fmt.Errorf("%w: client GUID %x is not 16 bytes", data.ErrInvalidRecord, d.ClientGUID)
+	}
+	// VLAN IDs 0 and 4095 are reserved by 802.1Q, 0 is not sent.
+	if d.VLANTag > 4094 {
+		return fmt.Errorf("%w: VLAN tag %d is more than the maximum of 4094", data.ErrInvalidRecord, d.VLANTag)
+	}
+	if d.VLANPriority > 7 {
+		return fmt.Errorf("%w: VLAN priority %d is more than the maximum of 7", data.ErrInvalidRecord, d.VLANPriority)
+	}
+	if len(d.SIPServerNames) > 0 && len(d.SIPServerAddresses) > 0 {
+		return fmt.Errorf("%w: SIP servers can be set by name or by address, not both", data.ErrInvalidRecord)
+	}
+	for _, ip := range d.SIPServerAddresses {
+		if ip.To4() == nil {
+			return fmt.Errorf("%w: SIP server %q is not an IPv4 address", data.ErrInvalidRecord, ip)
+		}
+	}
+	// RFC 8910 requires the captive portal API to be served over https.
+	if u := d.CaptivePortalURL; u != nil && !strings.EqualFold(u.Scheme, "https") {
+		return fmt.Errorf("%w: captive portal URL %q is not an https URL", data.ErrInvalidRecord, u.Redacted())
+	}
+	for e, opts := range d.VIVendorOptions {
+		if n := len(dhcpv4.Options(opts).ToBytes()); n > 255 {
+			return fmt.Errorf("%w: vendor-identifying options of enterprise %d are %d bytes, more than the maximum of 255", data.ErrInvalidRecord, e, n)
+		}
+	}
+	for c := range d.OtherOptions {
+		switch c {
+		case dhcpv4.OptionPad.Code(), dhcpv4.OptionDHCPMessageType.Code(), dhcpv4.OptionServerIdentifier.Code(), dhcpv4.OptionEnd.Code():
+			return fmt.Errorf("%w: option %d can not be set in other options", data.ErrInvalidRecord, c)
+		}
+	}
+	if n != nil {
+		schemes := v.URLSchemes
+		if len(schemes) == 0 {
+			schemes = DefaultURLSchemes
+		}
+		for _, u := range []struct {
+			name string
+			u    *url.URL
+		}{{"iPXE script URL", n.IPXEScriptURL}, {"OSIE base URL", n.OSIE.BaseURL}, {"HTTP boot URI", n.HTTPBootURI}} {
+			if u.u != nil && !allowedScheme(u.u, schemes) {
+				return fmt.Errorf("%w: %v %q has a scheme that is not allowed, allowed schemes: %v", data.ErrInvalidRecord, u.name, u.u.Redacted(), strings.Join(schemes, ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
+// inSubnets returns true if ip is in one of subnets.
+func inSubnets(ip netip.Addr, subnets []netip.Prefix) bool {
+	for _, p := range subnets {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowedScheme returns true if the scheme of u is one of schemes.
+func allowedScheme(u *url.URL, schemes []string) bool {
+	for _, s := range schemes {
+		if strings.EqualFold(u.Scheme, s) {
+			return true
+		}
+	}
+
+	return false
+}
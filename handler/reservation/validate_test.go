@@ -0,0 +1,157 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/tinkerbell/dhcp/data"
+)
+
+func TestValidate(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	valid := func() *data.DHCP {
+		return &data.DHCP{
+			MACAddress: mac,
+			IPAddress:  netip.MustParseAddr("192.168.1.100"),
+			SubnetMask: net.CIDRMask(24, 32),
+			LeaseTime:  3600,
+		}
+	}
+	tests := map[string]struct {
+		v       Validation
+		d       func(*data.DHCP) *data.DHCP
+		n       *data.Netboot
+		wantErr error
+	}{
+		"valid":                  {},
+		"no MAC address":         {d: func(d *data.DHCP) *data.DHCP { d.MACAddress = nil; return d }},
+		"no subnet mask":         {d: func(d *data.DHCP) *data.DHCP { d.SubnetMask = nil; return d }},
+		"IPv4 mapped IP address": {d: func(d *data.DHCP) *data.DHCP { d.IPAddress = netip.MustParseAddr("::ffff:192.168.1.100"); return d }},
+		"no DHCP data":           {d: func(*data.DHCP) *data.DHCP { return nil }, wantErr: data.ErrInvalidRecord},
+		"other MAC address": {
+			d: func(d *data.DHCP) *data.DHCP {
+				d.MACAddress = net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x07}
+				return d
+			},
+			wantErr: data.ErrInvalidRecord,
+		},
+		"no IP address":      {d: func(d *data.DHCP) *data.DHCP { d.IPAddress = netip.Addr{}; return d }, wantErr: data.ErrInvalidRecord},
+		"IPv6 address":       {d: func(d *data.DHCP) *data.DHCP { d.IPAddress = netip.MustParseAddr("2001:db8::1"); return d }, wantErr: data.ErrInvalidRecord},
+		"unspecified IP":     {d: func(d *data.DHCP) *data.DHCP { d.IPAddress = netip.IPv4Unspecified(); return d }, wantErr: data.ErrInvalidRecord},
+		"zero subnet mask":   {d: func(d *data.DHCP) *data.DHCP { d.SubnetMask = net.IPMask{0, 0, 0, 0}; return d }, wantErr: data.ErrInvalidRecord},
+		"non contiguous":     {d: func(d *data.DHCP) *data.DHCP { d.SubnetMask = net.IPMask{255, 0, 255, 0}; return d }, wantErr: data.ErrInvalidRecord},
+		"IPv6 subnet mask":   {d: func(d *data.DHCP) *data.DHCP { d.SubnetMask = net.CIDRMask(64, 128); return d }, wantErr: data.ErrInvalidRecord},
+		"in subnet":          {v: Validation{Subnets: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.0/24")}}},
+		"not in subnet":      {v: Validation{Subnets: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}, wantErr: data.ErrInvalidRecord},
+		"lease time bounds":  {v: Validation{MinLeaseTime: 60, MaxLeaseTime: 86400}},
+		"lease time too low": {v: Validation{MinLeaseTime: 7200}, wantErr: data.ErrInvalidRecord},
+		"lease time too high": {
+			v:       Validation{MaxLeaseTime: 60},
+			wantErr: data.ErrInvalidRecord,
+		},
+		"SIP servers by name and address": {
+			d: func(d *data.DHCP) *data.DHCP {
+				d.SIPServerNames = []string{"sip.example.com"}
+				d.SIPServerAddresses = []net.IP{{192, 168, 1, 5}}
+				return d
+			},
+			wantErr: data.ErrInvalidRecord,
+		},
+		"IPv6 SIP server": {
+			d:       func(d *data.DHCP) *data.DHCP { d.SIPServerAddresses = []net.IP{net.ParseIP("2001:db8::5")}; return d },
+			wantErr: data.ErrInvalidRecord,
+		},
+		"captive portal URL": {d: func(d *data.DHCP) *data.DHCP {
+			d.CaptivePortalURL = &url.URL{Scheme: "https", Host: "portal.example.com", Path: "/api"}
+			return d
+		}},
+		"captive portal URL not https": {
+			d: func(d *data.DHCP) *data.DHCP {
+				d.CaptivePortalURL = &url.URL{Scheme: "http", Host: "portal.example.com", Path: "/api"}
+				return d
+			},
+			wantErr: data.ErrInvalidRecord,
+		},
+		"client identifiers": {d: func(d *data.DHCP) *data.DHCP {
+			d.ClientIdentifier = []byte{0x01, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+			d.ClientGUID = make([]byte, 16)
+			return d
+		}},
+		"client identifier too short": {
+			d:       func(d *data.DHCP) *data.DHCP { d.ClientIdentifier = []byte{0x01}; return d },
+			wantErr: data.ErrInvalidRecord,
+		},
+		"client GUID not 16 bytes": {
+			d:       func(d *data.DHCP) *data.DHCP { d.ClientGUID = make([]byte, 17); return d },
+			wantErr: data.ErrInvalidRecord,
+		},
+		"VLAN tag": {d: func(d *data.DHCP) *data.DHCP { d.VLANTag, d.VLANPriority = 200, 5; return d }},
+		"VLAN tag too large": {
+			d:       func(d *data.DHCP) *data.DHCP { d.VLANTag = 4095; return d },
+			wantErr: data.ErrInvalidRecord,
+		},
+		"VLAN priority too large": {
+			d:       func(d *data.DHCP) *data.DHCP { d.VLANTag, d.VLANPriority = 200, 8; return d },
+			wantErr: data.ErrInvalidRecord,
+		},
+		"vendor-identifying options too long": {
+			d: func(d *data.DHCP) *data.DHCP {
+				d.VIVendorOptions = data.VIVendorOptions{3561: {1: make([]byte, 200), 2: make([]byte, 100)}}
+				return d
+			},
+			wantErr: data.ErrInvalidRecord,
+		},
+		"other options": {d: func(d *data.DHCP) *data.DHCP { d.OtherOptions = map[uint8][]byte{224: {1}}; return d }},
+		"other options message type": {
+			d:       func(d *data.DHCP) *data.DHCP { d.OtherOptions = map[uint8][]byte{53: {5}}; return d },
+			wantErr: data.ErrInvalidRecord,
+		},
+		"allowed URL schemes": {
+			n: &data.Netboot{
+				IPXEScriptURL: &url.URL{Scheme: "https", Host: "example.com", Path: "/auto.ipxe"},
+				OSIE:          data.OSIE{BaseURL: &url.URL{Scheme: "http", Host: "example.com"}},
+			},
+		},
+		"iPXE script URL scheme not allowed": {
+			n:       &data.Netboot{IPXEScriptURL: &url.URL{Scheme: "file", Path: "/etc/passwd"}},
+			wantErr: data.ErrInvalidRecord,
+		},
+		"HTTP boot URI scheme not allowed": {
+			n:       &data.Netboot{HTTPBootURI: &url.URL{Scheme: "ftp", Host: "example.com", Path: "/uki.efi"}},
+			wantErr: data.ErrInvalidRecord,
+		},
+		"OSIE base URL scheme not allowed": {
+			n:       &data.Netboot{OSIE: data.OSIE{BaseURL: &url.URL{Scheme: "http", Host: "example.com"}}},
+			v:       Validation{URLSchemes: []string{"https"}},
+			wantErr: data.ErrInvalidRecord,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			d := valid()
+			if tt.d != nil {
+				d = tt.d(d)
+			}
+			if err := tt.v.validate(mac, d, tt.n); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadBackendValidation(t *testing.T) {
+	h := &Handler{
+		Backend:    newMockBackend(data.Netboot{}),
+		Validation: Validation{Subnets: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+	}
+	if _, _, err := h.readBackend(context.Background(), net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}); !errors.Is(err, data.ErrInvalidRecord) {
+		t.Fatalf("readBackend() error = %v, wantErr %v", err, data.ErrInvalidRecord)
+	}
+	if _, _, err := h.readBackend(context.Background(), net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x07}); !errors.Is(err, data.ErrInvalidRecord) {
+		t.Fatalf("readBackend() error = %v, wantErr %v", err, data.ErrInvalidRecord)
+	}
+}
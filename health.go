@@ -0,0 +1,144 @@
+package dhcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tinkerbell/dhcp/handler"
+)
+
+// healthCheckTimeout bounds a health check made through HealthHandler.
+const healthCheckTimeout = 5 * time.Second
+
+// Health is the aggregated health of a Server, its HealthCheckers, and its Handlers.
+type Health struct {
+	// Healthy is true if all checks passed and the backends of all Handlers are healthy.
+	Healthy bool
+	// Listening is true while Serve is reading messages from its connections, that is while its socket is bound.
+	// It does not change Healthy, add the Server to its HealthCheckers for that.
+	Listening bool
+	// Checks holds the result of each check, keyed by the name of the HealthChecker. A nil error is a passed check.
+	Checks map[string]error
+	// Handlers holds the status of the Handlers that implement handler.HealthReporter, keyed by their type,
+	// as in the handler label of the metrics, for example "*reservation.Handler".
+	Handlers map[string]handler.Status
+}
+
+// errNotListening is returned by Healthy when the Server is not serving any connection.
+var errNotListening = errors.New("server is not listening")
+
+// Healthy implements the handler.HealthChecker interface. It returns nil while Serve is reading messages from
+// its connections, so the Server can be added to its own HealthCheckers to report whether its socket is bound:
+//
+//	s.HealthCheckers["listener"] = s
+func (s *Server) Healthy(context.Context) error {
+	if s.listening.Load() == 0 {
+		return errNotListening
+	}
+
+	return nil
+}
+
+// Health runs all HealthCheckers, and gets the status of the Handlers, concurrently and returns the aggregated result.
+// A Server without HealthCheckers and Handlers that report their health is healthy.
+func (s *Server) Health(ctx context.Context) Health {
+	h := Health{Healthy: true, Listening: s.listening.Load() > 0, Checks: make(map[string]error, len(s.HealthCheckers))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, hd := range s.Handlers {
+		hr, ok := hd.(handler.HealthReporter)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(name string, hr handler.HealthReporter) {
+			defer wg.Done()
+			st := hr.Health(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if h.Handlers == nil {
+				h.Handlers = make(map[string]handler.Status)
+			}
+			h.Handlers[name] = st
+			if st.Backend != nil {
+				h.Healthy = false
+			}
+		}(fmt.Sprintf("%T", hd), hr)
+	}
+	for name, hc := range s.HealthCheckers {
+		wg.Add(1)
+		go func(name string, check func(context.Context) error) {
+			defer wg.Done()
+			e := check(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			h.Checks[name] = e
+			if e != nil {
+				h.Healthy = false
+			}
+		}(name, hc.Healthy)
+	}
+	wg.Wait()
+
+	return h
+}
+
+// handlerStatus is the JSON form of a handler.Status.
+type handlerStatus struct {
+	Backend  string     `json:"backend"`
+	LastRead *time.Time `json:"lastRead,omitempty"`
+}
+
+// HealthHandler returns an http.Handler that reports the Health of the Server as JSON.
+// It responds with a 200 status code when healthy and a 503 status code otherwise. For example:
+//
+//	{"healthy":false,"listening":true,"checks":{"kube":"hardware cache not synced"},
+//	 "handlers":{"*reservation.Handler":{"backend":"hardware cache not synced","lastRead":"2024-01-02T03:04:05Z"}}}
+func (s *Server) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+		h := s.Health(ctx)
+
+		resp := struct {
+			Healthy   bool                     `json:"healthy"`
+			Listening bool                     `json:"listening"`
+			Checks    map[string]string        `json:"checks"`
+			Handlers  map[string]handlerStatus `json:"handlers,omitempty"`
+		}{Healthy: h.Healthy, Listening: h.Listening, Checks: make(map[string]string, len(h.Checks))}
+		for name, err := range h.Checks {
+			resp.Checks[name] = status(err)
+		}
+		for name, st := range h.Handlers {
+			if resp.Handlers == nil {
+				resp.Handlers = make(map[string]handlerStatus, len(h.Handlers))
+			}
+			hs := handlerStatus{Backend: status(st.Backend)}
+			if !st.LastRead.IsZero() {
+				t := st.LastRead.UTC()
+				hs.LastRead = &t
+			}
+			resp.Handlers[name] = hs
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !h.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// status returns the JSON form of the result err of a check.
+func status(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+
+	return "ok"
+}
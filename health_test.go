@@ -0,0 +1,120 @@
+package dhcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/handler"
+	"golang.org/x/net/ipv4"
+)
+
+type healthFunc func(context.Context) error
+
+func (f healthFunc) Healthy(ctx context.Context) error { return f(ctx) }
+
+// reportingHandler is a Handler that reports status as its health.
+type reportingHandler struct {
+	status handler.Status
+}
+
+func (reportingHandler) Handle(context.Context, *ipv4.PacketConn, data.Packet) {}
+
+func (r reportingHandler) Health(context.Context) handler.Status { return r.status }
+
+func TestHealth(t *testing.T) {
+	errNotSynced := errors.New("not synced")
+	lastRead := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := map[string]struct {
+		checkers   map[string]handler.HealthChecker
+		handlers   []Handler
+		wantHealth bool
+		wantCode   int
+		wantBody   string
+	}{
+		"no checkers": {
+			wantHealth: true,
+			wantCode:   http.StatusOK,
+			wantBody:   `{"healthy":true,"listening":false,"checks":{}}` + "\n",
+		},
+		"all healthy": {
+			checkers: map[string]handler.HealthChecker{
+				"file": healthFunc(func(context.Context) error { return nil }),
+				"kube": healthFunc(func(context.Context) error { return nil }),
+			},
+			wantHealth: true,
+			wantCode:   http.StatusOK,
+			wantBody:   `{"healthy":true,"listening":false,"checks":{"file":"ok","kube":"ok"}}` + "\n",
+		},
+		"one unhealthy": {
+			checkers: map[string]handler.HealthChecker{
+				"file": healthFunc(func(context.Context) error { return nil }),
+				"kube": healthFunc(func(context.Context) error { return errNotSynced }),
+			},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"healthy":false,"listening":false,"checks":{"file":"ok","kube":"not synced"}}` + "\n",
+		},
+		"handler healthy": {
+			handlers:   []Handler{reportingHandler{status: handler.Status{LastRead: lastRead}}, panicking{}},
+			wantHealth: true,
+			wantCode:   http.StatusOK,
+			wantBody:   `{"healthy":true,"listening":false,"checks":{},"handlers":{"dhcp.reportingHandler":{"backend":"ok","lastRead":"2024-01-02T03:04:05Z"}}}` + "\n",
+		},
+		"handler backend unhealthy": {
+			handlers: []Handler{reportingHandler{status: handler.Status{Backend: errNotSynced}}},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"healthy":false,"listening":false,"checks":{},"handlers":{"dhcp.reportingHandler":{"backend":"not synced"}}}` + "\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := &Server{HealthCheckers: tt.checkers, Handlers: tt.handlers}
+			if got := s.Health(context.Background()); got.Healthy != tt.wantHealth {
+				t.Errorf("Health() = %+v, want healthy %v", got, tt.wantHealth)
+			}
+
+			rec := httptest.NewRecorder()
+			s.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			if rec.Code != tt.wantCode {
+				t.Errorf("got status code %d, want %d", rec.Code, tt.wantCode)
+			}
+			if diff := cmp.Diff(rec.Body.String(), tt.wantBody); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Conn: conn, Logger: logr.Discard()}
+	if err := s.Healthy(context.Background()); !errors.Is(err, errNotListening) {
+		t.Fatalf("Healthy() error = %v before Serve, want %v", err, errNotListening)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx) }()
+	for start := time.Now(); s.Healthy(ctx) != nil && time.Since(start) < 2*time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.Healthy(ctx); err != nil {
+		t.Fatalf("Healthy() error = %v while serving, want nil", err)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Healthy(context.Background()); !errors.Is(err, errNotListening) {
+		t.Fatalf("Healthy() error = %v after Serve, want %v", err, errNotListening)
+	}
+}
@@ -0,0 +1,122 @@
+// Package sigv4 signs HTTP requests with AWS Signature Version 4, for the backends that talk to AWS APIs
+// and to the S3 compatible APIs of other object stores.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Credentials are AWS credentials used to sign requests.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is only set for temporary credentials.
+	SessionToken string
+}
+
+// ErrCredentials is returned by CredentialsFromEnv when the credentials are not set.
+var ErrCredentials = errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+
+// CredentialsFromEnv returns the credentials in the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables.
+func CredentialsFromEnv() (Credentials, error) {
+	c := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return Credentials{}, ErrCredentials
+	}
+
+	return c, nil
+}
+
+// Sign adds an AWS Signature Version 4 Authorization header to req, as described in
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html.
+// The X-Amz-Date header is set to t. Every header set on req, and the host, is signed.
+func Sign(req *http.Request, body []byte, c Credentials, region, service string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", k, headers[k])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hashHex(body),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", c.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns the query parameters sorted by name and value, and URI encoded.
+func canonicalQuery(q url.Values) string {
+	var params []string
+	for k, vs := range q {
+		for _, v := range vs {
+			params = append(params, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+// uriEncode encodes s as required by Signature Version 4: every byte except unreserved characters is percent encoded.
+func uriEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hashHex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
@@ -0,0 +1,148 @@
+package lease
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileStore is a Store that persists leases to a JSON file.
+//
+// Every change rewrites the whole file: the leases are written to a temporary file in the same directory,
+// synced to disk, and then renamed over the lease file. A crash leaves either the old or the new file, never a partial one.
+// Expired leases are removed from the file on every change.
+type FileStore struct {
+	path string
+	mem  *MemoryStore
+}
+
+// fileLease is the on-disk format of a Lease.
+type fileLease struct {
+	MAC     string     `json:"mac"`
+	IP      netip.Addr `json:"ip"`
+	Expires time.Time  `json:"expires"`
+}
+
+// NewFileStore returns a FileStore backed by the file at path. Leases in an existing file are loaded.
+// The file is created on the first change if it does not exist.
+func NewFileStore(path string) (*FileStore, error) {
+	f := &FileStore{path: path, mem: NewMemoryStore()}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lease file: %w", err)
+	}
+	var leases []fileLease
+	if err := json.Unmarshal(b, &leases); err != nil {
+		return nil, fmt.Errorf("failed to parse lease file %v: %w", path, err)
+	}
+	for _, l := range leases {
+		mac, err := net.ParseMAC(l.MAC)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse lease file %v: %w", path, err)
+		}
+		f.mem.leases[mac.String()] = Lease{MAC: mac, IP: l.IP, Expires: l.Expires}
+	}
+
+	return f, nil
+}
+
+// Get implements the Store interface.
+func (f *FileStore) Get(ctx context.Context, mac net.HardwareAddr) (Lease, error) {
+	return f.mem.Get(ctx, mac)
+}
+
+// GetByIP implements the Store interface.
+func (f *FileStore) GetByIP(ctx context.Context, ip netip.Addr) (Lease, error) {
+	return f.mem.GetByIP(ctx, ip)
+}
+
+// List implements the Store interface.
+func (f *FileStore) List(ctx context.Context) ([]Lease, error) {
+	return f.mem.List(ctx)
+}
+
+// Put implements the Store interface. The lease is not stored if writing the file fails.
+func (f *FileStore) Put(_ context.Context, l Lease) error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	key := l.MAC.String()
+	old, existed := f.mem.leases[key]
+	if err := f.mem.put(l); err != nil {
+		return err
+	}
+	if err := f.save(); err != nil {
+		if existed {
+			f.mem.leases[key] = old
+		} else {
+			delete(f.mem.leases, key)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Revoke implements the Store interface. The lease is not revoked if writing the file fails.
+func (f *FileStore) Revoke(_ context.Context, mac net.HardwareAddr) error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	key := mac.String()
+	old, existed := f.mem.leases[key]
+	if !existed {
+		return nil
+	}
+	delete(f.mem.leases, key)
+	if err := f.save(); err != nil {
+		f.mem.leases[key] = old
+
+		return err
+	}
+
+	return nil
+}
+
+// save prunes expired leases and atomically replaces the lease file. The caller must hold the write lock.
+func (f *FileStore) save() error {
+	f.mem.prune()
+	leases := make([]fileLease, 0, len(f.mem.leases))
+	for _, l := range f.mem.list() {
+		leases = append(leases, fileLease{MAC: l.MAC.String(), IP: l.IP, Expires: l.Expires})
+	}
+	b, err := json.MarshalIndent(leases, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write lease file: %w", err)
+	}
+	// the temporary file no longer exists after a successful rename.
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write lease file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write lease file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write lease file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to write lease file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+// Package lease tracks the binding of IP addresses to clients.
+//
+// A Store holds leases until they expire or are revoked. Implementations are provided that keep leases
+// in memory, in a file, and in a SQL database. The file and SQL stores persist leases across restarts.
+package lease
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when there is no unexpired lease for a MAC address or IP address.
+	ErrNotFound = errors.New("lease not found")
+	// ErrConflict is returned by Put when the IP address is leased to a different MAC address.
+	ErrConflict = errors.New("ip address is leased to a different mac address")
+)
+
+// Lease is the binding of an IP address to a client until Expires.
+type Lease struct {
+	MAC     net.HardwareAddr
+	IP      netip.Addr
+	Expires time.Time
+}
+
+// Expired reports whether the lease has expired at now.
+func (l Lease) Expired(now time.Time) bool {
+	return !now.Before(l.Expires)
+}
+
+// Store is the interface for lease storage.
+//
+// A MAC address has at most one lease and an IP address is leased to at most one MAC address.
+// Expired leases are never returned.
+type Store interface {
+	// Get returns the lease of mac.
+	Get(ctx context.Context, mac net.HardwareAddr) (Lease, error)
+	// GetByIP returns the lease of ip.
+	GetByIP(ctx context.Context, ip netip.Addr) (Lease, error)
+	// Put creates or replaces the lease of l.MAC. It returns ErrConflict if l.IP is leased to a different MAC address.
+	Put(ctx context.Context, l Lease) error
+	// Revoke removes the lease of mac. Revoking a MAC address without a lease is not an error.
+	Revoke(ctx context.Context, mac net.HardwareAddr) error
+	// List returns all leases, sorted by IP address.
+	List(ctx context.Context) ([]Lease, error)
+}
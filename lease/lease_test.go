@@ -0,0 +1,180 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+var (
+	now  = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	mac1 = net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	mac2 = net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x06}
+	ip1  = netip.MustParseAddr("192.168.2.10")
+	ip2  = netip.MustParseAddr("192.168.2.9")
+)
+
+// stores returns a new instance of each Store implementation that keeps its state locally, with a fixed clock.
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+	m := NewMemoryStore()
+	m.now = func() time.Time { return now }
+	f, err := NewFileStore(filepath.Join(t.TempDir(), "leases.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.mem.now = func() time.Time { return now }
+
+	return map[string]Store{"memory": m, "file": f}
+}
+
+func TestStore(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if _, err := s.Get(ctx, mac1); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get() error = %v, want %v", err, ErrNotFound)
+			}
+			l1 := Lease{MAC: mac1, IP: ip1, Expires: now.Add(time.Hour)}
+			l2 := Lease{MAC: mac2, IP: ip2, Expires: now.Add(time.Hour)}
+			for _, l := range []Lease{l1, l2} {
+				if err := s.Put(ctx, l); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := s.Get(ctx, mac1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(got, l1, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" || got.IP != ip1 {
+				t.Fatal(diff)
+			}
+			if got, err := s.GetByIP(ctx, ip2); err != nil || got.MAC.String() != mac2.String() {
+				t.Fatalf("GetByIP() = %v, %v, want %v", got, err, mac2)
+			}
+			if err := s.Put(ctx, Lease{MAC: mac2, IP: ip1, Expires: now.Add(time.Hour)}); !errors.Is(err, ErrConflict) {
+				t.Fatalf("Put() error = %v, want %v", err, ErrConflict)
+			}
+
+			list, err := s.List(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != 2 || list[0].IP != ip2 || list[1].IP != ip1 {
+				t.Fatalf("List() = %v, want leases sorted by IP", list)
+			}
+
+			if err := s.Revoke(ctx, mac1); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Revoke(ctx, mac1); err != nil {
+				t.Fatalf("Revoke() of a revoked lease error = %v, want nil", err)
+			}
+			if _, err := s.GetByIP(ctx, ip1); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("GetByIP() error = %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestStoreExpired(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if err := s.Put(ctx, Lease{MAC: mac1, IP: ip1, Expires: now}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Get(ctx, mac1); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get() error = %v, want %v", err, ErrNotFound)
+			}
+			if _, err := s.GetByIP(ctx, ip1); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("GetByIP() error = %v, want %v", err, ErrNotFound)
+			}
+			if list, _ := s.List(ctx); len(list) != 0 {
+				t.Fatalf("List() = %v, want no leases", list)
+			}
+			// an expired lease does not conflict.
+			if err := s.Put(ctx, Lease{MAC: mac2, IP: ip1, Expires: now.Add(time.Hour)}); err != nil {
+				t.Fatalf("Put() error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestFileStorePersistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "leases.json")
+	f, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Put(ctx, Lease{MAC: mac1, IP: ip1, Expires: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Put(ctx, Lease{MAC: mac2, IP: ip2, Expires: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Revoke(ctx, mac2); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new store reads the leases written by the first one, as it would after a restart.
+	f, err = NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := f.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].MAC.String() != mac1.String() || list[0].IP != ip1 {
+		t.Fatalf("List() = %v, want only the lease of %v", list, mac1)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d files, want only the lease file, temporary files must be removed", len(entries))
+	}
+}
+
+func TestFileStoreWriteFailure(t *testing.T) {
+	ctx := context.Background()
+	f, err := NewFileStore(filepath.Join(t.TempDir(), "missing", "leases.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Put(ctx, Lease{MAC: mac1, IP: ip1, Expires: time.Now().Add(time.Hour)}); err == nil {
+		t.Fatal("Put() expected error writing to a missing directory")
+	}
+	if _, err := f.Get(ctx, mac1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() error = %v, want %v, a lease that was not written must not be stored", err, ErrNotFound)
+	}
+}
+
+func TestNewFileStoreBadFile(t *testing.T) {
+	tests := map[string]string{
+		"not json": "leases",
+		"bad mac":  `[{"mac": "bad", "ip": "192.168.2.10", "expires": "2023-06-01T12:00:00Z"}]`,
+	}
+	for name, contents := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "leases.json")
+			if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewFileStore(path); err == nil {
+				t.Fatal("NewFileStore() expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package lease
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps leases in memory. Leases are lost when the process exits.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	leases map[string]Lease // keyed by MAC address string
+	now    func() time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{leases: make(map[string]Lease), now: time.Now}
+}
+
+// Get implements the Store interface.
+func (m *MemoryStore) Get(_ context.Context, mac net.HardwareAddr) (Lease, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	l, ok := m.leases[mac.String()]
+	if !ok || l.Expired(m.now()) {
+		return Lease{}, ErrNotFound
+	}
+
+	return l, nil
+}
+
+// GetByIP implements the Store interface.
+func (m *MemoryStore) GetByIP(_ context.Context, ip netip.Addr) (Lease, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := m.now()
+	for _, l := range m.leases {
+		if l.IP == ip && !l.Expired(now) {
+			return l, nil
+		}
+	}
+
+	return Lease{}, ErrNotFound
+}
+
+// Put implements the Store interface.
+func (m *MemoryStore) Put(_ context.Context, l Lease) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.put(l)
+}
+
+// put adds l to the store. The caller must hold the write lock.
+func (m *MemoryStore) put(l Lease) error {
+	now := m.now()
+	key := l.MAC.String()
+	for k, e := range m.leases {
+		if k != key && e.IP == l.IP && !e.Expired(now) {
+			return ErrConflict
+		}
+	}
+	m.leases[key] = l
+
+	return nil
+}
+
+// Revoke implements the Store interface.
+func (m *MemoryStore) Revoke(_ context.Context, mac net.HardwareAddr) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.leases, mac.String())
+
+	return nil
+}
+
+// List implements the Store interface.
+func (m *MemoryStore) List(_ context.Context) ([]Lease, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.list(), nil
+}
+
+// list returns the unexpired leases sorted by IP address. The caller must hold the read lock.
+func (m *MemoryStore) list() []Lease {
+	now := m.now()
+	r := make([]Lease, 0, len(m.leases))
+	for _, l := range m.leases {
+		if !l.Expired(now) {
+			r = append(r, l)
+		}
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].IP.Less(r[j].IP) })
+
+	return r
+}
+
+// prune removes expired leases. The caller must hold the write lock.
+func (m *MemoryStore) prune() {
+	now := m.now()
+	for k, l := range m.leases {
+		if l.Expired(now) {
+			delete(m.leases, k)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package lease
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTable is the table that NewSQLStore stores leases in.
+const DefaultTable = "dhcp_leases"
+
+// Dialect is the bind parameter style of a database.
+type Dialect int
+
+const (
+	// DialectQuestion uses "?" bind parameters, as used by SQLite and MySQL.
+	DialectQuestion Dialect = iota
+	// DialectDollar uses "$1" style bind parameters, as used by PostgreSQL.
+	DialectDollar
+)
+
+// tableName is the allowed format of a table name. The table name is part of the query, it can't be a bind parameter.
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLStore is a Store that keeps leases in a table of a SQL database, with the columns mac, ip, and expires.
+// It works with any database/sql driver, for example SQLite. The driver must be registered, by importing it, by the caller.
+//
+// Changes are made in transactions, so the durability of leases is that of the database.
+type SQLStore struct {
+	db      *sql.DB
+	table   string
+	dialect Dialect
+	now     func() time.Time
+}
+
+// NewSQLStore returns a SQLStore that keeps leases in table of db.
+// An empty table uses DefaultTable.
+func NewSQLStore(db *sql.DB, table string, d Dialect) (*SQLStore, error) {
+	if table == "" {
+		table = DefaultTable
+	}
+	if !tableName.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+
+	return &SQLStore{db: db, table: table, dialect: d, now: time.Now}, nil
+}
+
+// CreateTable creates the lease table if it does not exist.
+func (s *SQLStore) CreateTable(ctx context.Context) error {
+	q := "CREATE TABLE IF NOT EXISTS " + s.table + " (mac VARCHAR(64) PRIMARY KEY, ip VARCHAR(64) NOT NULL, expires TIMESTAMP NOT NULL)"
+	if _, err := s.db.ExecContext(ctx, q); err != nil {
+		return fmt.Errorf("failed to create table %v: %w", s.table, err)
+	}
+
+	return nil
+}
+
+// Get implements the Store interface.
+func (s *SQLStore) Get(ctx context.Context, mac net.HardwareAddr) (Lease, error) {
+	row := s.db.QueryRowContext(ctx, s.query("SELECT mac, ip, expires FROM %s WHERE mac = ? AND expires > ?"), mac.String(), s.now().UTC())
+
+	return scan(row)
+}
+
+// GetByIP implements the Store interface.
+func (s *SQLStore) GetByIP(ctx context.Context, ip netip.Addr) (Lease, error) {
+	row := s.db.QueryRowContext(ctx, s.query("SELECT mac, ip, expires FROM %s WHERE ip = ? AND expires > ?"), ip.String(), s.now().UTC())
+
+	return scan(row)
+}
+
+// Put implements the Store interface.
+func (s *SQLStore) Put(ctx context.Context, l Lease) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var other string
+	err = tx.QueryRowContext(ctx, s.query("SELECT mac FROM %s WHERE ip = ? AND mac <> ? AND expires > ?"), l.IP.String(), l.MAC.String(), s.now().UTC()).Scan(&other)
+	switch {
+	case err == nil:
+		return ErrConflict
+	case !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("failed to check for a conflicting lease: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, s.query("DELETE FROM %s WHERE mac = ?"), l.MAC.String()); err != nil {
+		return fmt.Errorf("failed to replace lease: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, s.query("INSERT INTO %s (mac, ip, expires) VALUES (?, ?, ?)"), l.MAC.String(), l.IP.String(), l.Expires.UTC()); err != nil {
+		return fmt.Errorf("failed to insert lease: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit lease: %w", err)
+	}
+
+	return nil
+}
+
+// Revoke implements the Store interface.
+func (s *SQLStore) Revoke(ctx context.Context, mac net.HardwareAddr) error {
+	if _, err := s.db.ExecContext(ctx, s.query("DELETE FROM %s WHERE mac = ?"), mac.String()); err != nil {
+		return fmt.Errorf("failed to revoke lease: %w", err)
+	}
+
+	return nil
+}
+
+// List implements the Store interface.
+func (s *SQLStore) List(ctx context.Context) ([]Lease, error) {
+	rows, err := s.db.QueryContext(ctx, s.query("SELECT mac, ip, expires FROM %s WHERE expires > ?"), s.now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list leases: %w", err)
+	}
+	defer rows.Close()
+
+	var r []Lease
+	for rows.Next() {
+		l, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list leases: %w", err)
+	}
+	// IP addresses are stored as text, which does not sort numerically.
+	sort.Slice(r, func(i, j int) bool { return r[i].IP.Less(r[j].IP) })
+
+	return r, nil
+}
+
+// Prune deletes expired leases from the table.
+func (s *SQLStore) Prune(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, s.query("DELETE FROM %s WHERE expires <= ?"), s.now().UTC()); err != nil {
+		return fmt.Errorf("failed to prune leases: %w", err)
+	}
+
+	return nil
+}
+
+// query formats q with the table name and rewrites its "?" bind parameters in the style of the Dialect.
+func (s *SQLStore) query(q string) string {
+	q = fmt.Sprintf(q, s.table)
+	if s.dialect != DialectDollar {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range q {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// scan reads a lease from a row of mac, ip, and expires columns.
+func scan(row interface{ Scan(...any) error }) (Lease, error) {
+	var mac, ip string
+	var expires time.Time
+	if err := row.Scan(&mac, &ip, &expires); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Lease{}, ErrNotFound
+		}
+		return Lease{}, fmt.Errorf("failed to read lease: %w", err)
+	}
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return Lease{}, fmt.Errorf("failed to read lease: %w", err)
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Lease{}, fmt.Errorf("failed to read lease: %w", err)
+	}
+
+	return Lease{MAC: hw, IP: addr, Expires: expires}, nil
+}
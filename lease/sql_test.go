@@ -0,0 +1,209 @@
+package lease
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// script is a database/sql driver that records the statements it executes
+// and answers queries with the rows set for the query.
+type script struct {
+	mu        sync.Mutex
+	execs     []string
+	rows      map[string][][]driver.Value
+	committed bool
+}
+
+func (s *script) Open(string) (driver.Conn, error) { return &scriptConn{s: s}, nil }
+
+func (s *script) Connect(context.Context) (driver.Conn, error) { return &scriptConn{s: s}, nil }
+
+func (s *script) Driver() driver.Driver { return s }
+
+type scriptConn struct{ s *script }
+
+func (c *scriptConn) Prepare(query string) (driver.Stmt, error) {
+	return &scriptStmt{s: c.s, query: query}, nil
+}
+func (c *scriptConn) Close() error              { return nil }
+func (c *scriptConn) Begin() (driver.Tx, error) { return scriptTx{s: c.s}, nil }
+
+type scriptTx struct{ s *script }
+
+func (t scriptTx) Commit() error   { t.s.committed = true; return nil }
+func (t scriptTx) Rollback() error { return nil }
+
+type scriptStmt struct {
+	s     *script
+	query string
+}
+
+func (st *scriptStmt) Close() error  { return nil }
+func (st *scriptStmt) NumInput() int { return -1 }
+
+func (st *scriptStmt) Exec([]driver.Value) (driver.Result, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+	st.s.execs = append(st.s.execs, st.query)
+
+	return driver.RowsAffected(1), nil
+}
+
+func (st *scriptStmt) Query([]driver.Value) (driver.Rows, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+	for prefix, rows := range st.s.rows {
+		if strings.HasPrefix(st.query, prefix) {
+			return &scriptRows{rows: rows}, nil
+		}
+	}
+
+	return &scriptRows{}, nil
+}
+
+type scriptRows struct {
+	rows [][]driver.Value
+}
+
+func (r *scriptRows) Columns() []string {
+	if len(r.rows) == 1 && len(r.rows[0]) == 1 {
+		return []string{"mac"}
+	}
+
+	return []string{"mac", "ip", "expires"}
+}
+func (r *scriptRows) Close() error { return nil }
+
+func (r *scriptRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
+
+func newSQLStore(t *testing.T, s *script, d Dialect) *SQLStore {
+	t.Helper()
+	db := sql.OpenDB(s)
+	t.Cleanup(func() { db.Close() })
+	st, err := NewSQLStore(db, "", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.now = func() time.Time { return now }
+
+	return st
+}
+
+func TestNewSQLStoreInvalidTable(t *testing.T) {
+	if _, err := NewSQLStore(nil, "leases; DROP TABLE hardware", DialectQuestion); err == nil {
+		t.Fatal("NewSQLStore() expected error for invalid table name")
+	}
+}
+
+func TestSQLStoreGet(t *testing.T) {
+	s := &script{rows: map[string][][]driver.Value{
+		"SELECT mac, ip, expires FROM dhcp_leases WHERE mac": {{"00:01:02:03:04:05", "192.168.2.10", now.Add(time.Hour)}},
+	}}
+	st := newSQLStore(t, s, DialectQuestion)
+	got, err := st.Get(context.Background(), mac1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MAC.String() != mac1.String() || got.IP != ip1 || !got.Expires.Equal(now.Add(time.Hour)) {
+		t.Fatalf("Get() = %v, want the lease of %v", got, mac1)
+	}
+	if _, err := st.GetByIP(context.Background(), ip1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetByIP() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestSQLStorePut(t *testing.T) {
+	tests := map[string]struct {
+		rows      map[string][][]driver.Value
+		dialect   Dialect
+		wantExecs []string
+		wantErr   error
+	}{
+		"new lease": {
+			wantExecs: []string{
+				"DELETE FROM dhcp_leases WHERE mac = ?",
+				"INSERT INTO dhcp_leases (mac, ip, expires) VALUES (?, ?, ?)",
+			},
+		},
+		"dollar dialect": {
+			dialect: DialectDollar,
+			wantExecs: []string{
+				"DELETE FROM dhcp_leases WHERE mac = $1",
+				"INSERT INTO dhcp_leases (mac, ip, expires) VALUES ($1, $2, $3)",
+			},
+		},
+		"conflict": {
+			rows:    map[string][][]driver.Value{"SELECT mac FROM dhcp_leases WHERE ip": {{"00:01:02:03:04:06"}}},
+			wantErr: ErrConflict,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := &script{rows: tt.rows}
+			st := newSQLStore(t, s, tt.dialect)
+			err := st.Put(context.Background(), Lease{MAC: mac1, IP: ip1, Expires: now.Add(time.Hour)})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Put() error = %v, want %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(s.execs, tt.wantExecs); diff != "" {
+				t.Fatal(diff)
+			}
+			if s.committed != (tt.wantErr == nil) {
+				t.Fatalf("got committed = %v, want %v", s.committed, tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestSQLStoreList(t *testing.T) {
+	s := &script{rows: map[string][][]driver.Value{
+		"SELECT mac, ip, expires FROM dhcp_leases": {
+			{"00:01:02:03:04:05", "192.168.2.10", now.Add(time.Hour)},
+			{"00:01:02:03:04:06", "192.168.2.9", now.Add(time.Hour)},
+		},
+	}}
+	list, err := newSQLStore(t, s, DialectQuestion).List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []netip.Addr
+	for _, l := range list {
+		got = append(got, l.IP)
+	}
+	if len(got) != 2 || got[0] != ip2 || got[1] != ip1 {
+		t.Fatalf("List() = %v, want leases sorted by IP", got)
+	}
+}
+
+func TestSQLStoreRevokePrune(t *testing.T) {
+	s := &script{}
+	st := newSQLStore(t, s, DialectQuestion)
+	if err := st.Revoke(context.Background(), mac1); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Prune(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"DELETE FROM dhcp_leases WHERE mac = ?", "DELETE FROM dhcp_leases WHERE expires <= ?"}
+	if diff := cmp.Diff(s.execs, want); diff != "" {
+		t.Fatal(diff)
+	}
+}
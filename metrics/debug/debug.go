@@ -0,0 +1,33 @@
+// Package debug serves the net/http/pprof profiles and the expvar variables of the process, so that goroutine and heap
+// profiles can be grabbed when the server misbehaves, for example during a large provisioning wave.
+//
+// It is a separate package from metrics because importing net/http/pprof and expvar registers their handlers on
+// http.DefaultServeMux: only the programs that import this package, to serve the endpoints, get them.
+//
+// The profiles expose the internals of the process, and profiling costs CPU. Only serve them when they are enabled
+// in the configuration, and on an address that is not reachable from the provisioning network, like the metrics:
+//
+//	mux := http.NewServeMux()
+//	metrics.Register(mux, reg, srv.HealthHandler())
+//	if debugEnabled {
+//		debug.Register(mux)
+//	}
+//	go metrics.ListenAndServe(ctx, "127.0.0.1:9090", mux)
+package debug
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+)
+
+// Register mounts the pprof index and profiles at /debug/pprof/, for example /debug/pprof/goroutine and
+// /debug/pprof/heap, and the expvar variables at /debug/vars, on mux.
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+}
@@ -0,0 +1,38 @@
+package debug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux)
+
+	tests := map[string]struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		"index":      {path: "/debug/pprof/", wantCode: http.StatusOK, wantBody: "goroutine"},
+		"goroutines": {path: "/debug/pprof/goroutine?debug=1", wantCode: http.StatusOK, wantBody: "goroutine profile"},
+		"heap":       {path: "/debug/pprof/heap?debug=1", wantCode: http.StatusOK, wantBody: "heap profile"},
+		"cmdline":    {path: "/debug/pprof/cmdline", wantCode: http.StatusOK},
+		"vars":       {path: "/debug/vars", wantCode: http.StatusOK, wantBody: `"memstats"`},
+		"unknown":    {path: "/debug/unknown", wantCode: http.StatusNotFound},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("got status code %d, want %d", rec.Code, tt.wantCode)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Fatalf("body %q does not contain %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Package metrics holds the metrics of the DHCP server and its handlers, and an HTTP server that exposes them
+// together with the health of the server, so that DHCP can be alerted on, not only traced and logged.
+//
+// The server and handlers record metrics through the Metrics interface. Prometheus records them with
+// Prometheus collectors, OTel with OpenTelemetry instruments, and Noop does not record them.
+// The Prometheus metrics are:
+//
+//	dhcp_packets_received_total{type}          messages received, by DHCP message type
+//	dhcp_replies_sent_total{type}              replies sent by handlers, by DHCP message type
+//	dhcp_errors_total{reason}                  messages that were not handled or replied to, by reason
+//	dhcp_naks_total{class}                     NAKs replied to requests, by the class of the backend error that caused them
+//	dhcp_handler_backend_errors_total{class}   backend reads that failed while handling a message, by error class
+//	dhcp_handler_slow_total{stage}             message handlings and backend reads that took longer than their threshold, by stage
+//	dhcp_handler_duration_seconds{handler}     time handlers took to handle a message
+//
+// Backend read latency and errors by backend are recorded by the backend/metrics package, and can be registered
+// with the same registry. The error classes are the Class constants of that package.
+//
+// Embedders that already serve HTTP can mount the metrics on their own mux with Register instead of running
+// the server of ListenAndServe.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Reasons used in the reason label of the errors metric.
+const (
+	ReasonMalformed   = "malformed"
+	ReasonOversized   = "oversized"
+	ReasonQueueFull   = "queue_full"
+	ReasonRateLimited = "rate_limited"
+	ReasonDuplicate   = "duplicate"
+	ReasonPanic       = "panic"
+	ReasonExpired     = "expired"
+	ReasonBackend     = "backend"
+	ReasonSend        = "send"
+)
+
+// Stages used in the stage label of the slow metric.
+const (
+	StageHandle      = "handle"
+	StageBackendRead = "backend_read"
+)
+
+// shutdownTimeout bounds the graceful shutdown of the server started by ListenAndServe.
+const shutdownTimeout = 5 * time.Second
+
+// Metrics records the metrics of the server and its handlers. Implementations must be safe for concurrent use.
+// Prometheus and OTel are the implementations of this package. Embedders can implement Metrics to bridge
+// the metrics to their own telemetry stack.
+type Metrics interface {
+	// Received records a received message of type mt.
+	Received(mt dhcpv4.MessageType)
+	// Replied records a sent reply of type mt.
+	Replied(mt dhcpv4.MessageType)
+	// Error records a message that was not handled or replied to, for reason, one of the Reason constants.
+	Error(reason string)
+	// NAK records a NAK replied to a request because of a backend error of class.
+	NAK(class string)
+	// BackendError records a backend read of class that failed while handling a message.
+	BackendError(class string)
+	// Handled records that handler took d to handle a message.
+	Handled(handler string, d time.Duration)
+	// Slow records a stage, one of the Stage constants, that took longer than its threshold.
+	Slow(stage string)
+}
+
+// Noop is a Metrics that records nothing.
+type Noop struct{}
+
+// Received implements Metrics.
+func (Noop) Received(dhcpv4.MessageType) {}
+
+// Replied implements Metrics.
+func (Noop) Replied(dhcpv4.MessageType) {}
+
+// Error implements Metrics.
+func (Noop) Error(string) {}
+
+// NAK implements Metrics.
+func (Noop) NAK(string) {}
+
+// BackendError implements Metrics.
+func (Noop) BackendError(string) {}
+
+// Handled implements Metrics.
+func (Noop) Handled(string, time.Duration) {}
+
+// Slow implements Metrics.
+func (Noop) Slow(string) {}
+
+// OrNoop returns m, or Noop when m is nil, so that metrics can be recorded whether or not they are enabled.
+func OrNoop(m Metrics) Metrics {
+	if m == nil {
+		return Noop{}
+	}
+
+	return m
+}
+
+// Handler returns an http.Handler that serves the metrics gathered from g at /metrics, and health at /healthz,
+// for example dhcp.Server.HealthHandler. Other paths respond with a 404 status code.
+func Handler(g prometheus.Gatherer, health http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	Register(mux, g, health)
+
+	return mux
+}
+
+// Register mounts the metrics gathered from g at /metrics, and health, when not nil, at /healthz, on mux.
+// It is how an embedder serves the metrics next to its own handlers.
+func Register(mux *http.ServeMux, g prometheus.Gatherer, health http.Handler) {
+	mux.Handle("/metrics", promhttp.HandlerFor(g, promhttp.HandlerOpts{}))
+	if health != nil {
+		mux.Handle("/healthz", health)
+	}
+}
+
+// ListenAndServe serves h on addr until ctx is done. It returns nil once the server is shut down.
+func ListenAndServe(ctx context.Context, addr string, h http.Handler) error {
+	srv := &http.Server{Addr: addr, Handler: h, ReadHeaderTimeout: 5 * time.Second}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	r := prometheus.NewRegistry()
+	m, err := New(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Received(dhcpv4.MessageTypeDiscover)
+	m.Received(dhcpv4.MessageTypeDiscover)
+	m.Replied(dhcpv4.MessageTypeOffer)
+	m.Error(ReasonMalformed)
+	m.NAK("not_found")
+	m.BackendError("not_found")
+	m.BackendError("deadline_exceeded")
+	m.Handled("*reservation.Handler", 10*time.Millisecond)
+	m.Slow(StageBackendRead)
+
+	if got := testutil.ToFloat64(m.received.WithLabelValues("DISCOVER")); got != 2 {
+		t.Errorf("got %v received, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.replies.WithLabelValues("OFFER")); got != 1 {
+		t.Errorf("got %v replies, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.errors.WithLabelValues(ReasonMalformed)); got != 1 {
+		t.Errorf("got %v errors, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.naks.WithLabelValues("not_found")); got != 1 {
+		t.Errorf("got %v NAKs, want 1", got)
+	}
+	if got := testutil.CollectAndCount(m.backend); got != 2 {
+		t.Errorf("got %v backend error series, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.slow.WithLabelValues(StageBackendRead)); got != 1 {
+		t.Errorf("got %v slow backend reads, want 1", got)
+	}
+	if got := testutil.CollectAndCount(m.duration); got != 1 {
+		t.Errorf("got %v handler duration series, want 1", got)
+	}
+	if _, err := New(r); err == nil {
+		t.Error("New() error = nil, want an error registering the collectors twice")
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Prometheus
+	m.Received(dhcpv4.MessageTypeDiscover)
+	m.Replied(dhcpv4.MessageTypeOffer)
+	m.Error(ReasonPanic)
+	m.NAK("other")
+	m.BackendError("other")
+	m.Handled("handler", time.Second)
+	m.Slow(StageHandle)
+}
+
+func TestOrNoop(t *testing.T) {
+	if _, ok := OrNoop(nil).(Noop); !ok {
+		t.Errorf("OrNoop(nil) = %T, want Noop", OrNoop(nil))
+	}
+	p := &Prometheus{}
+	if got := OrNoop(p); got != p {
+		t.Errorf("OrNoop(%p) = %v, want it unchanged", p, got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := prometheus.NewRegistry()
+	m, err := New(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Received(dhcpv4.MessageTypeRequest)
+	health := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) })
+	h := Handler(r, health)
+
+	tests := map[string]struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		"metrics": {path: "/metrics", wantCode: http.StatusOK, wantBody: `dhcp_packets_received_total{type="REQUEST"} 1`},
+		"healthz": {path: "/healthz", wantCode: http.StatusServiceUnavailable},
+		"unknown": {path: "/unknown", wantCode: http.StatusNotFound},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("got status code %d, want %d", rec.Code, tt.wantCode)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Fatalf("body %q does not contain %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	r := prometheus.NewRegistry()
+	m, err := New(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Error(ReasonDuplicate)
+	mux := http.NewServeMux()
+	mux.Handle("/", http.NotFoundHandler())
+	Register(mux, r, nil)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := `dhcp_errors_total{reason="duplicate"} 1`; !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("body %q does not contain %q", rec.Body.String(), want)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("got status code %d for /healthz without a health handler, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestListenAndServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- ListenAndServe(ctx, "127.0.0.1:0", http.NotFoundHandler()) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ListenAndServe() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenAndServe() did not return after the context was done")
+	}
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// instrumentationName is the name of the meter of OTel.
+const instrumentationName = "github.com/tinkerbell/dhcp/metrics"
+
+// OTel is a Metrics that records the metrics with OpenTelemetry instruments, so that they are exported with
+// the other OpenTelemetry metrics of an embedder. The instruments are named like the Prometheus metrics,
+// with dots, for example dhcp.packets.received, and have the same attributes. A nil *OTel records nothing.
+type OTel struct {
+	received metric.Int64Counter
+	replies  metric.Int64Counter
+	errors   metric.Int64Counter
+	naks     metric.Int64Counter
+	backend  metric.Int64Counter
+	slow     metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+// NewOTel returns OTel metrics with their instruments created by a meter of mp.
+func NewOTel(mp metric.MeterProvider) (*OTel, error) {
+	meter := mp.Meter(instrumentationName)
+	var errs []error
+	counter := func(name, desc string) metric.Int64Counter {
+		c, err := meter.Int64Counter(name, metric.WithDescription(desc), metric.WithUnit("{message}"))
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return c
+	}
+	o := &OTel{
+		received: counter("dhcp.packets.received", "Number of DHCP messages received, by message type."),
+		replies:  counter("dhcp.replies.sent", "Number of DHCP replies sent, by message type."),
+		errors:   counter("dhcp.errors", "Number of DHCP messages that were not handled or replied to, by reason."),
+		naks:     counter("dhcp.naks", "Number of DHCP NAKs replied to requests, by the class of the backend error that caused them."),
+		backend:  counter("dhcp.handler.backend.errors", "Number of backend reads that failed while handling a DHCP message, by error class."),
+		slow:     counter("dhcp.handler.slow", "Number of DHCP message handlings and backend reads that took longer than their threshold, by stage."),
+	}
+	d, err := meter.Float64Histogram("dhcp.handler.duration", metric.WithDescription("Time handlers took to handle a DHCP message."), metric.WithUnit("s"))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	o.duration = d
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// Received implements Metrics.
+func (o *OTel) Received(mt dhcpv4.MessageType) {
+	if o == nil {
+		return
+	}
+	o.received.Add(context.Background(), 1, metric.WithAttributes(attribute.String("type", mt.String())))
+}
+
+// Replied implements Metrics.
+func (o *OTel) Replied(mt dhcpv4.MessageType) {
+	if o == nil {
+		return
+	}
+	o.replies.Add(context.Background(), 1, metric.WithAttributes(attribute.String("type", mt.String())))
+}
+
+// Error implements Metrics.
+func (o *OTel) Error(reason string) {
+	if o == nil {
+		return
+	}
+	o.errors.Add(context.Background(), 1, metric.WithAttributes(attribute.String("reason", reason)))
+}
+
+// NAK implements Metrics.
+func (o *OTel) NAK(class string) {
+	if o == nil {
+		return
+	}
+	o.naks.Add(context.Background(), 1, metric.WithAttributes(attribute.String("class", class)))
+}
+
+// BackendError implements Metrics.
+func (o *OTel) BackendError(class string) {
+	if o == nil {
+		return
+	}
+	o.backend.Add(context.Background(), 1, metric.WithAttributes(attribute.String("class", class)))
+}
+
+// Handled implements Metrics.
+func (o *OTel) Handled(handler string, d time.Duration) {
+	if o == nil {
+		return
+	}
+	o.duration.Record(context.Background(), d.Seconds(), metric.WithAttributes(attribute.String("handler", handler)))
+}
+
+// Slow implements Metrics.
+func (o *OTel) Slow(stage string) {
+	if o == nil {
+		return
+	}
+	o.slow.Add(context.Background(), 1, metric.WithAttributes(attribute.String("stage", stage)))
+}
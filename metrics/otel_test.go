@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestOTel(t *testing.T) {
+	o, err := NewOTel(noop.NewMeterProvider())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nilOTel *OTel
+	for _, m := range []Metrics{o, nilOTel} {
+		m.Received(dhcpv4.MessageTypeDiscover)
+		m.Replied(dhcpv4.MessageTypeOffer)
+		m.Error(ReasonMalformed)
+		m.NAK("not_found")
+		m.BackendError("not_found")
+		m.Handled("*reservation.Handler", time.Millisecond)
+		m.Slow(StageHandle)
+	}
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus is a Metrics that records the metrics with Prometheus collectors. A nil *Prometheus records nothing.
+type Prometheus struct {
+	received *prometheus.CounterVec
+	replies  *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	naks     *prometheus.CounterVec
+	backend  *prometheus.CounterVec
+	slow     *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// New returns Prometheus metrics with their collectors registered with r.
+func New(r prometheus.Registerer) (*Prometheus, error) {
+	m := &Prometheus{
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dhcp_packets_received_total",
+			Help: "Number of DHCP messages received, by message type.",
+		}, []string{"type"}),
+		replies: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dhcp_replies_sent_total",
+			Help: "Number of DHCP replies sent, by message type.",
+		}, []string{"type"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dhcp_errors_total",
+			Help: "Number of DHCP messages that were not handled or replied to, by reason.",
+		}, []string{"reason"}),
+		naks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dhcp_naks_total",
+			Help: "Number of DHCP NAKs replied to requests, by the class of the backend error that caused them.",
+		}, []string{"class"}),
+		backend: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dhcp_handler_backend_errors_total",
+			Help: "Number of backend reads that failed while handling a DHCP message, by error class.",
+		}, []string{"class"}),
+		slow: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dhcp_handler_slow_total",
+			Help: "Number of DHCP message handlings and backend reads that took longer than their threshold, by stage.",
+		}, []string{"stage"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "dhcp_handler_duration_seconds",
+			Help:    "Time handlers took to handle a DHCP message.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14), // 0.5ms to ~4s
+		}, []string{"handler"}),
+	}
+	for _, c := range []prometheus.Collector{m.received, m.replies, m.errors, m.naks, m.backend, m.slow, m.duration} {
+		if err := r.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// Received implements Metrics.
+func (m *Prometheus) Received(mt dhcpv4.MessageType) {
+	if m == nil {
+		return
+	}
+	m.received.WithLabelValues(mt.String()).Inc()
+}
+
+// Replied implements Metrics.
+func (m *Prometheus) Replied(mt dhcpv4.MessageType) {
+	if m == nil {
+		return
+	}
+	m.replies.WithLabelValues(mt.String()).Inc()
+}
+
+// Error implements Metrics.
+func (m *Prometheus) Error(reason string) {
+	if m == nil {
+		return
+	}
+	m.errors.WithLabelValues(reason).Inc()
+}
+
+// NAK implements Metrics.
+func (m *Prometheus) NAK(class string) {
+	if m == nil {
+		return
+	}
+	m.naks.WithLabelValues(class).Inc()
+}
+
+// BackendError implements Metrics.
+func (m *Prometheus) BackendError(class string) {
+	if m == nil {
+		return
+	}
+	m.backend.WithLabelValues(class).Inc()
+}
+
+// Handled implements Metrics.
+func (m *Prometheus) Handled(handler string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.duration.WithLabelValues(handler).Observe(d.Seconds())
+}
+
+// Slow implements Metrics.
+func (m *Prometheus) Slow(stage string) {
+	if m == nil {
+		return
+	}
+	m.slow.WithLabelValues(stage).Inc()
+}
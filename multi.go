@@ -0,0 +1,115 @@
+package dhcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"golang.org/x/sync/errgroup"
+)
+
+// errClosed stops the other bindings of a MultiServer when one of them is closed.
+var errClosed = errors.New("server closed")
+
+// Binding is an interface and address that a MultiServer listens on, and the handlers for the messages received there.
+type Binding struct {
+	// Interface is the name of the interface to listen on. Only messages received on it are handled.
+	// An empty name listens on all interfaces.
+	Interface string
+
+	// Addr is the address to listen on. Defaults to 0.0.0.0:67.
+	Addr netip.AddrPort
+
+	// Handlers handle the messages received on the binding. Each binding usually has its own reservation.Handler,
+	// configured with the server IP and netboot endpoints of its network.
+	Handlers []Handler
+
+	// Conn, when set, is used instead of opening a connection for Interface and Addr. When Interface is set too, Conn is bound to it.
+	Conn net.PacketConn
+}
+
+// MultiServer serves DHCP on several bindings at once, for example one per provisioning VLAN, from a single process.
+// The bindings share one lifecycle: Serve stops all of them when its context is done, when Close is called,
+// or when one of them fails.
+type MultiServer struct {
+	// Servers are the servers of the bindings, in the order of the bindings.
+	Servers []*Server
+}
+
+// NewMultiServer opens a connection for each of bindings and returns a MultiServer that serves them.
+// If a connection can not be opened, the connections already opened are closed.
+func NewMultiServer(l logr.Logger, bindings ...Binding) (*MultiServer, error) {
+	if l.GetSink() == nil {
+		l = logr.Discard()
+	}
+	m := &MultiServer{}
+	for _, b := range bindings {
+		conn := b.Conn
+		if conn == nil {
+			addr := &net.UDPAddr{Port: dhcpv4.ServerPort}
+			if b.Addr.IsValid() {
+				addr = net.UDPAddrFromAddrPort(b.Addr)
+			}
+			if err := CheckPrivileges(addr.Port, false); err != nil {
+				_ = m.Close()
+				return nil, fmt.Errorf("binding %v on interface %q: %w", addr, b.Interface, err)
+			}
+			c, err := NewConn(b.Interface, addr)
+			if err != nil {
+				_ = m.Close()
+				return nil, fmt.Errorf("binding %v on interface %q: %w", addr, b.Interface, err)
+			}
+			conn = c
+		}
+		s := &Server{
+			Conn:     conn,
+			Handlers: b.Handlers,
+			Logger:   l.WithValues("interface", b.Interface),
+		}
+		if b.Conn != nil {
+			s.Interface = b.Interface
+		}
+		m.Servers = append(m.Servers, s)
+	}
+
+	return m, nil
+}
+
+// Serve serves all bindings until ctx is done, Close is called, or one of them fails.
+// It returns the error of the first binding that failed, after all bindings are stopped.
+func (m *MultiServer) Serve(ctx context.Context) error {
+	g, ctx := errgroup.WithContext(ctx)
+	for _, s := range m.Servers {
+		s := s
+		g.Go(func() error {
+			err := s.Serve(ctx)
+			if err == nil || errors.Is(err, net.ErrClosed) {
+				// a binding that stopped without failing, because ctx is done or it was closed, stops the others too.
+				return errClosed
+			}
+
+			return fmt.Errorf("serving %v: %w", s.Conn.LocalAddr(), err)
+		})
+	}
+	if err := g.Wait(); !errors.Is(err, errClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Close closes the connections of all bindings.
+func (m *MultiServer) Close() error {
+	var errs []error
+	for _, s := range m.Servers {
+		if err := s.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
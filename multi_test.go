@@ -0,0 +1,103 @@
+package dhcp
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// exchange sends a DHCPDISCOVER to addr and returns the reply.
+func exchange(t *testing.T, addr net.Addr) *dhcpv4.DHCPv4 {
+	t.Helper()
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	req, err := dhcpv4.NewDiscovery(net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pc.WriteTo(req.ToBytes(), addr); err != nil {
+		t.Fatal(err)
+	}
+	_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1500)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := dhcpv4.FromBytes(buf[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return reply
+}
+
+func TestMultiServer(t *testing.T) {
+	m, err := NewMultiServer(logr.Discard(),
+		Binding{Addr: netip.MustParseAddrPort("127.0.0.1:0"), Handlers: []Handler{&mock{ServerIP: net.IP{192, 168, 1, 1}}}},
+		Binding{Addr: netip.MustParseAddrPort("127.0.0.1:0"), Handlers: []Handler{&mock{ServerIP: net.IP{192, 168, 2, 1}}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- m.Serve(ctx) }()
+
+	// each binding replies with the configuration of its own handler.
+	for i, want := range []net.IP{{192, 168, 1, 1}, {192, 168, 2, 1}} {
+		reply := exchange(t, m.Servers[i].Conn.LocalAddr())
+		if diff := cmp.Diff(want, reply.ServerIdentifier().To4()); diff != "" {
+			t.Fatalf("binding %d: %v", i, diff)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Serve() error = %v, want nil", err)
+	}
+}
+
+func TestMultiServerClose(t *testing.T) {
+	m, err := NewMultiServer(logr.Discard(),
+		Binding{Addr: netip.MustParseAddrPort("127.0.0.1:0"), Handlers: []Handler{&mock{}}},
+		Binding{Addr: netip.MustParseAddrPort("127.0.0.1:0"), Handlers: []Handler{&mock{}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- m.Serve(context.Background()) }()
+
+	// closing one binding stops all of them.
+	time.Sleep(50 * time.Millisecond)
+	_ = m.Servers[0].Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve() error = %v, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve() did not return after a binding was closed")
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() error = %v, want nil", err)
+	}
+}
+
+func TestNewMultiServerError(t *testing.T) {
+	_, err := NewMultiServer(logr.Discard(), Binding{Interface: "does-not-exist0", Addr: netip.MustParseAddrPort("127.0.0.1:0")})
+	if err == nil {
+		t.Fatal("NewMultiServer() error = nil, want an error for an interface that does not exist")
+	}
+}
@@ -5,16 +5,17 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/data"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
-const keyNamespace = "DHCP"
-
 // Encoder holds the otel key/value attributes.
 type Encoder struct {
 	Log logr.Logger
@@ -69,15 +70,17 @@ func AllEncoders() []func(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValu
 		EncodeOpt1, EncodeOpt3, EncodeOpt6,
 		EncodeOpt12, EncodeOpt15, EncodeOpt28,
 		EncodeOpt42, EncodeOpt51, EncodeOpt53,
-		EncodeOpt54, EncodeOpt60, EncodeOpt93,
-		EncodeOpt94, EncodeOpt97, EncodeOpt119,
+		EncodeOpt54, EncodeOpt60, EncodeOpt61,
+		EncodeOpt66, EncodeOpt67, EncodeOpt77,
+		EncodeOpt82, EncodeOpt93, EncodeOpt94,
+		EncodeOpt97, EncodeOpt119, EncodeOpt125,
 	}
 }
 
 // EncodeFlags takes DHCP flags from a DHCP packet and returns an OTEL key/value pair.
 // key/value pair. See https://datatracker.ietf.org/doc/html/rfc2131#page-9
 func EncodeFlags(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Header.flags", keyNamespace, namespace)
+	key := Key(namespace, "Header.flags")
 	if d != nil {
 		return attribute.String(key, d.FlagsToString()), nil
 	}
@@ -88,7 +91,7 @@ func EncodeFlags(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 // EncodeTransactionID takes the Transaction ID header from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeTransactionID(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Header.transactionID", keyNamespace, namespace)
+	key := Key(namespace, "Header.transactionID")
 	if d != nil {
 		return attribute.String(key, d.TransactionID.String()), nil
 	}
@@ -99,20 +102,19 @@ func EncodeTransactionID(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue
 // EncodeOpt1 takes DHCP Opt 1 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt1(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	opt := "Opt1.SubnetMask"
-	key := fmt.Sprintf("%v.%v.%v", keyNamespace, namespace, opt)
+	key := Key(namespace, "Opt1.SubnetMask")
 	if d != nil && d.SubnetMask() != nil {
 		sm := net.IP(d.SubnetMask()).String()
 		return attribute.String(key, sm), nil
 	}
 
-	return attribute.KeyValue{}, &notFoundError{optName: opt}
+	return attribute.KeyValue{}, &notFoundError{optName: key}
 }
 
 // EncodeOpt3 takes DHCP Opt 3 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt3(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt3.DefaultGateway", keyNamespace, namespace)
+	key := Key(namespace, "Opt3.DefaultGateway")
 	if d != nil {
 		var routers []string
 		for _, e := range d.Router() {
@@ -129,7 +131,7 @@ func EncodeOpt3(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 // EncodeOpt6 takes DHCP Opt 6 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt6(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt6.NameServers", keyNamespace, namespace)
+	key := Key(namespace, "Opt6.NameServers")
 	if d != nil {
 		var ns []string
 		for _, e := range d.DNS() {
@@ -146,7 +148,7 @@ func EncodeOpt6(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 // EncodeOpt12 takes DHCP Opt 12 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt12(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt12.Hostname", keyNamespace, namespace)
+	key := Key(namespace, "Opt12.Hostname")
 	if d != nil && d.HostName() != "" {
 		return attribute.String(key, d.HostName()), nil
 	}
@@ -157,7 +159,7 @@ func EncodeOpt12(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 // EncodeOpt15 takes DHCP Opt 15 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt15(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt15.DomainName", keyNamespace, namespace)
+	key := Key(namespace, "Opt15.DomainName")
 	if d != nil && d.DomainName() != "" {
 		return attribute.String(key, d.DomainName()), nil
 	}
@@ -168,7 +170,7 @@ func EncodeOpt15(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 // EncodeOpt28 takes DHCP Opt 28 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt28(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt28.BroadcastAddress", keyNamespace, namespace)
+	key := Key(namespace, "Opt28.BroadcastAddress")
 	if d != nil && d.BroadcastAddress() != nil {
 		return attribute.String(key, d.BroadcastAddress().String()), nil
 	}
@@ -179,7 +181,7 @@ func EncodeOpt28(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 // EncodeOpt42 takes DHCP Opt 42 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt42(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt42.NTPServers", keyNamespace, namespace)
+	key := Key(namespace, "Opt42.NTPServers")
 	if d != nil {
 		var ntp []string
 		for _, e := range d.NTPServers() {
@@ -196,7 +198,7 @@ func EncodeOpt42(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 // EncodeOpt51 takes DHCP Opt 51 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt51(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt51.LeaseTime", keyNamespace, namespace)
+	key := Key(namespace, "Opt51.LeaseTime")
 	if d != nil && d.IPAddressLeaseTime(0) != 0 {
 		return attribute.Float64(key, d.IPAddressLeaseTime(0).Seconds()), nil
 	}
@@ -207,7 +209,7 @@ func EncodeOpt51(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 // EncodeOpt53 takes DHCP Opt 53 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt53(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt53.MessageType", keyNamespace, namespace)
+	key := Key(namespace, "Opt53.MessageType")
 	if d != nil && d.MessageType() != dhcpv4.MessageTypeNone {
 		return attribute.String(key, d.MessageType().String()), nil
 	}
@@ -218,7 +220,7 @@ func EncodeOpt53(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 // EncodeOpt54 takes DHCP Opt 54 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt54(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt54.ServerIdentifier", keyNamespace, namespace)
+	key := Key(namespace, "Opt54.ServerIdentifier")
 	if d != nil && d.ServerIdentifier() != nil {
 		return attribute.String(key, d.ServerIdentifier().String()), nil
 	}
@@ -229,7 +231,7 @@ func EncodeOpt54(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 // EncodeOpt60 takes DHCP Opt 60 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt60(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt60.ClassIdentifier", keyNamespace, namespace)
+	key := Key(namespace, "Opt60.ClassIdentifier")
 	if d != nil && d.ClassIdentifier() != "" {
 		return attribute.String(key, d.ClassIdentifier()), nil
 	}
@@ -237,10 +239,86 @@ func EncodeOpt60(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 	return attribute.KeyValue{}, &notFoundError{optName: key}
 }
 
+// EncodeOpt61 takes DHCP Opt 61 from a DHCP packet and returns an OTEL key/value pair.
+// See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
+func EncodeOpt61(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
+	key := Key(namespace, "Opt61.ClientIdentifier")
+	if d != nil && len(d.GetOneOption(dhcpv4.OptionClientIdentifier)) > 0 {
+		var r []string
+		for _, i := range d.GetOneOption(dhcpv4.OptionClientIdentifier) {
+			r = append(r, fmt.Sprintf("%v", i))
+		}
+
+		// "." delimited follows the same format from tcpdump
+		return attribute.String(key, strings.Join(r, ".")), nil
+	}
+
+	return attribute.KeyValue{}, &notFoundError{optName: key}
+}
+
+// EncodeOpt66 takes DHCP Opt 66 from a DHCP packet and returns an OTEL key/value pair.
+// See https://www.rfc-editor.org/rfc/rfc2132.html#section-9.4
+func EncodeOpt66(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
+	key := Key(namespace, "Opt66.TFTPServerName")
+	if d != nil && d.TFTPServerName() != "" {
+		return attribute.String(key, d.TFTPServerName()), nil
+	}
+
+	return attribute.KeyValue{}, &notFoundError{optName: key}
+}
+
+// EncodeOpt67 takes DHCP Opt 67 from a DHCP packet and returns an OTEL key/value pair.
+// See https://www.rfc-editor.org/rfc/rfc2132.html#section-9.5
+func EncodeOpt67(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
+	key := Key(namespace, "Opt67.BootFileName")
+	if d != nil && d.BootFileNameOption() != "" {
+		return attribute.String(key, d.BootFileNameOption()), nil
+	}
+
+	return attribute.KeyValue{}, &notFoundError{optName: key}
+}
+
+// EncodeOpt77 takes DHCP Opt 77 from a DHCP packet and returns an OTEL key/value pair.
+// A user class in the format of RFC 3004 is split in its classes, other values, like "iPXE", are kept whole.
+// See https://www.rfc-editor.org/rfc/rfc3004.html
+func EncodeOpt77(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
+	key := Key(namespace, "Opt77.UserClass")
+	if d != nil && len(d.UserClass()) > 0 {
+		return attribute.StringSlice(key, d.UserClass()), nil
+	}
+
+	return attribute.KeyValue{}, &notFoundError{optName: key}
+}
+
+// EncodeOpt82 takes the sub-options of DHCP Opt 82 from a DHCP packet and returns an OTEL key/value pair.
+// The sub-options are encoded as "code=value", in the order of their codes, with their values in hex,
+// for example "1=65:74:68:30,2=00:01:02:03:04:05" for a circuit ID and a remote ID.
+// See https://www.rfc-editor.org/rfc/rfc3046.html
+func EncodeOpt82(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
+	key := Key(namespace, "Opt82.RelayAgentInformation")
+	if d != nil {
+		if ri := d.RelayAgentInfo(); ri != nil && len(ri.Options) > 0 {
+			codes := make([]uint8, 0, len(ri.Options))
+			for c := range ri.Options {
+				codes = append(codes, c)
+			}
+			slices.Sort(codes)
+			r := make([]string, 0, len(codes))
+			for _, c := range codes {
+				r = append(r, fmt.Sprintf("%d=%s", c, hexString(ri.Options[c])))
+			}
+
+			return attribute.String(key, strings.Join(r, ",")), nil
+		}
+	}
+
+	return attribute.KeyValue{}, &notFoundError{optName: key}
+}
+
 // EncodeOpt93 takes DHCP Opt 93 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt93(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt93.ClientIdentifier", keyNamespace, namespace)
+	key := Key(namespace, "Opt93.ClientIdentifier")
 	if d != nil && len(d.ClientArch()) > 0 {
 		var r []string
 		for _, i := range d.ClientArch() {
@@ -256,7 +334,7 @@ func EncodeOpt93(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 // EncodeOpt94 takes DHCP Opt 94 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt94(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt94.ClientNetworkInterfaceIdentifier", keyNamespace, namespace)
+	key := Key(namespace, "Opt94.ClientNetworkInterfaceIdentifier")
 	if d != nil && len(d.GetOneOption(dhcpv4.OptionClientNetworkInterfaceIdentifier)) > 0 {
 		var r []string
 		for _, i := range d.GetOneOption(dhcpv4.OptionClientNetworkInterfaceIdentifier) {
@@ -271,17 +349,19 @@ func EncodeOpt94(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 }
 
 // EncodeOpt97 takes DHCP Opt 97 from a DHCP packet and returns an OTEL key/value pair.
-// See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
+// A GUID of type 0, as PXE clients send it, is encoded in its 8-4-4-4-12 hex form, like a UUID,
+// for example "00010203-0405-0607-0809-0a0b0c0d0e0f", other values in hex.
+// See https://www.rfc-editor.org/rfc/rfc4578.html#section-2.3
 func EncodeOpt97(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt97.ClientMachineIdentifier", keyNamespace, namespace)
+	key := Key(namespace, "Opt97.ClientMachineIdentifier")
 	if d != nil && len(d.GetOneOption(dhcpv4.OptionClientMachineIdentifier)) > 0 {
-		var r []string
-		for _, i := range d.GetOneOption(dhcpv4.OptionClientMachineIdentifier) {
-			r = append(r, fmt.Sprintf("%v", i))
+		guid := d.GetOneOption(dhcpv4.OptionClientMachineIdentifier)
+		if len(guid) == 17 && guid[0] == 0 {
+			g := guid[1:]
+			return attribute.String(key, fmt.Sprintf("%x-%x-%x-%x-%x", g[0:4], g[4:6], g[6:8], g[8:10], g[10:16])), nil
 		}
 
-		// "." delimited follows the same format from tcpdump
-		return attribute.String(key, strings.Join(r, ".")), nil
+		return attribute.String(key, hexString(guid)), nil
 	}
 
 	return attribute.KeyValue{}, &notFoundError{optName: key}
@@ -290,7 +370,7 @@ func EncodeOpt97(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 // EncodeOpt119 takes DHCP Opt 119 from a DHCP packet and returns an OTEL key/value pair.
 // See https://www.iana.org/assignments/bootp-dhcp-parameters/bootp-dhcp-parameters.xhtml
 func EncodeOpt119(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Opt119.DomainSearch", keyNamespace, namespace)
+	key := Key(namespace, "Opt119.DomainSearch")
 	if d != nil {
 		if l := d.DomainSearch(); l != nil {
 			return attribute.String(key, strings.Join(l.Labels, ",")), nil
@@ -300,10 +380,33 @@ func EncodeOpt119(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error
 	return attribute.KeyValue{}, &notFoundError{optName: key}
 }
 
+// EncodeOpt125 takes the enterprise numbers of DHCP Opt 125 from a DHCP packet and returns an OTEL key/value pair.
+// See https://www.rfc-editor.org/rfc/rfc3925.html
+func EncodeOpt125(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
+	key := Key(namespace, "Opt125.Enterprises")
+	if d != nil {
+		if v, err := data.ParseVIVendorOptions(d.GetOneOption(dhcpv4.OptionVendorIdentifyingVendorSpecific)); err == nil && len(v) > 0 {
+			ents := make([]uint32, 0, len(v))
+			for e := range v {
+				ents = append(ents, e)
+			}
+			slices.Sort(ents)
+			r := make([]string, 0, len(ents))
+			for _, e := range ents {
+				r = append(r, strconv.FormatUint(uint64(e), 10))
+			}
+
+			return attribute.String(key, strings.Join(r, ",")), nil
+		}
+	}
+
+	return attribute.KeyValue{}, &notFoundError{optName: key}
+}
+
 // EncodeYIADDR takes the yiaddr header from a DHCP packet and returns an OTEL
 // key/value pair. See https://datatracker.ietf.org/doc/html/rfc2131#page-9
 func EncodeYIADDR(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Header.yiaddr", keyNamespace, namespace)
+	key := Key(namespace, "Header.yiaddr")
 	if d != nil && d.YourIPAddr != nil {
 		return attribute.String(key, d.YourIPAddr.String()), nil
 	}
@@ -314,7 +417,7 @@ func EncodeYIADDR(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error
 // EncodeSIADDR takes the siaddr header from a DHCP packet and returns an OTEL
 // key/value pair. See https://datatracker.ietf.org/doc/html/rfc2131#page-9
 func EncodeSIADDR(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Header.siaddr", keyNamespace, namespace)
+	key := Key(namespace, "Header.siaddr")
 	if d != nil && d.ServerIPAddr != nil {
 		return attribute.String(key, d.ServerIPAddr.String()), nil
 	}
@@ -325,7 +428,7 @@ func EncodeSIADDR(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error
 // EncodeCHADDR takes the CHADDR header from a DHCP packet and returns an OTEL
 // key/value pair. See https://datatracker.ietf.org/doc/html/rfc2131#page-9
 func EncodeCHADDR(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Header.chaddr", keyNamespace, namespace)
+	key := Key(namespace, "Header.chaddr")
 	if d != nil && d.ClientHWAddr != nil {
 		return attribute.String(key, d.ClientHWAddr.String()), nil
 	}
@@ -336,7 +439,7 @@ func EncodeCHADDR(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error
 // EncodeFILE takes the file header from a DHCP packet and returns an OTEL
 // key/value pair. See https://datatracker.ietf.org/doc/html/rfc2131#page-9
 func EncodeFILE(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error) {
-	key := fmt.Sprintf("%v.%v.Header.file", keyNamespace, namespace)
+	key := Key(namespace, "Header.file")
 	if d != nil && d.BootFileName != "" {
 		return attribute.String(key, d.BootFileName), nil
 	}
@@ -344,6 +447,16 @@ func EncodeFILE(d *dhcpv4.DHCPv4, namespace string) (attribute.KeyValue, error)
 	return attribute.KeyValue{}, &notFoundError{optName: key}
 }
 
+// hexString returns b in hex, with the bytes separated by colons.
+func hexString(b []byte) string {
+	r := make([]string, 0, len(b))
+	for _, c := range b {
+		r = append(r, fmt.Sprintf("%02x", c))
+	}
+
+	return strings.Join(r, ":")
+}
+
 // TraceparentFromContext extracts the binary trace id, span id, and trace flags
 // from the running span in ctx and returns a 26 byte []byte with the traceparent
 // encoded and ready to pass into a suboption (most likely 69) of opt43.
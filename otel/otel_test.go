@@ -359,6 +359,123 @@ func TestSetOpt60(t *testing.T) {
 	}
 }
 
+func TestSetOpt66(t *testing.T) {
+	tests := map[string]struct {
+		input   *dhcpv4.DHCPv4
+		want    attribute.KeyValue
+		wantErr error
+	}{
+		"success": {
+			input: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptTFTPServerName("192.168.2.5"),
+			)},
+			want: attribute.String("DHCP.testing.Opt66.TFTPServerName", "192.168.2.5"),
+		},
+		"error": {wantErr: &notFoundError{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := EncodeOpt66(tt.input, "testing")
+			if tt.wantErr != nil && !OptNotFound(err) {
+				t.Fatalf("setOpt66() error (type: %T) = %[1]v, wantErr (type: %T) %[2]v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(got, tt.want, cmpopts.IgnoreUnexported(attribute.Value{})); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestSetOpt67(t *testing.T) {
+	tests := map[string]struct {
+		input   *dhcpv4.DHCPv4
+		want    attribute.KeyValue
+		wantErr error
+	}{
+		"success": {
+			input: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptBootFileName("snp.efi"),
+			)},
+			want: attribute.String("DHCP.testing.Opt67.BootFileName", "snp.efi"),
+		},
+		"error": {wantErr: &notFoundError{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := EncodeOpt67(tt.input, "testing")
+			if tt.wantErr != nil && !OptNotFound(err) {
+				t.Fatalf("setOpt67() error (type: %T) = %[1]v, wantErr (type: %T) %[2]v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(got, tt.want, cmpopts.IgnoreUnexported(attribute.Value{})); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestSetOpt77(t *testing.T) {
+	tests := map[string]struct {
+		input   *dhcpv4.DHCPv4
+		want    attribute.KeyValue
+		wantErr error
+	}{
+		"success": {
+			input: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptUserClass("Tinkerbell"),
+			)},
+			want: attribute.StringSlice("DHCP.testing.Opt77.UserClass", []string{"Tinkerbell"}),
+		},
+		"RFC 3004": {
+			input: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptRFC3004UserClass([]string{"iPXE", "Tinkerbell"}),
+			)},
+			want: attribute.StringSlice("DHCP.testing.Opt77.UserClass", []string{"iPXE", "Tinkerbell"}),
+		},
+		"error": {wantErr: &notFoundError{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := EncodeOpt77(tt.input, "testing")
+			if tt.wantErr != nil && !OptNotFound(err) {
+				t.Fatalf("setOpt77() error (type: %T) = %[1]v, wantErr (type: %T) %[2]v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(got, tt.want, cmpopts.IgnoreUnexported(attribute.Value{})); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestSetOpt82(t *testing.T) {
+	tests := map[string]struct {
+		input   *dhcpv4.DHCPv4
+		want    attribute.KeyValue
+		wantErr error
+	}{
+		"success": {
+			input: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptRelayAgentInfo(
+					dhcpv4.OptGeneric(dhcpv4.GenericOptionCode(2), []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}),
+					dhcpv4.OptGeneric(dhcpv4.GenericOptionCode(1), []byte("eth0")),
+				),
+			)},
+			want: attribute.String("DHCP.testing.Opt82.RelayAgentInformation", "1=65:74:68:30,2=00:01:02:03:04:05"),
+		},
+		"error": {wantErr: &notFoundError{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := EncodeOpt82(tt.input, "testing")
+			if tt.wantErr != nil && !OptNotFound(err) {
+				t.Fatalf("setOpt82() error (type: %T) = %[1]v, wantErr (type: %T) %[2]v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(got, tt.want, cmpopts.IgnoreUnexported(attribute.Value{})); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
 func TestSetOpt93(t *testing.T) {
 	tests := map[string]struct {
 		input   *dhcpv4.DHCPv4
@@ -415,6 +532,33 @@ func TestSetOpt94(t *testing.T) {
 	}
 }
 
+func TestSetOpt61(t *testing.T) {
+	tests := map[string]struct {
+		input   *dhcpv4.DHCPv4
+		want    attribute.KeyValue
+		wantErr error
+	}{
+		"success": {
+			input: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionClientIdentifier, []byte{0x01, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05}),
+			)},
+			want: attribute.String("DHCP.testing.Opt61.ClientIdentifier", "1.0.1.2.3.4.5"),
+		},
+		"error": {wantErr: &notFoundError{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := EncodeOpt61(tt.input, "testing")
+			if tt.wantErr != nil && !OptNotFound(err) {
+				t.Fatalf("setOpt61() error (type: %T) = %[1]v, wantErr (type: %T) %[2]v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(got, tt.want, cmpopts.IgnoreUnexported(attribute.Value{})); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
 func TestSetOpt97(t *testing.T) {
 	tests := map[string]struct {
 		input   *dhcpv4.DHCPv4
@@ -425,7 +569,13 @@ func TestSetOpt97(t *testing.T) {
 			input: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(
 				dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}),
 			)},
-			want: attribute.String("DHCP.testing.Opt97.ClientMachineIdentifier", "0.1.2.3.4.5.6.7.8.9.10.11.12.13.14.15.16"),
+			want: attribute.String("DHCP.testing.Opt97.ClientMachineIdentifier", "00010203-0405-0607-0809-0a0b0c0d0e0f"),
+		},
+		"not a GUID": {
+			input: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, []byte{0x01, 0x02, 0x03}),
+			)},
+			want: attribute.String("DHCP.testing.Opt97.ClientMachineIdentifier", "01:02:03"),
 		},
 		"error": {wantErr: &notFoundError{}},
 	}
@@ -443,6 +593,39 @@ func TestSetOpt97(t *testing.T) {
 	}
 }
 
+func TestSetOpt125(t *testing.T) {
+	tests := map[string]struct {
+		input   *dhcpv4.DHCPv4
+		want    attribute.KeyValue
+		wantErr error
+	}{
+		"success": {
+			input: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionVendorIdentifyingVendorSpecific, []byte{0x00, 0x00, 0x11, 0x8b, 3, 2, 1, 0x01, 0x00, 0x00, 0x0d, 0xe9, 0}),
+			)},
+			want: attribute.String("DHCP.testing.Opt125.Enterprises", "3561,4491"),
+		},
+		"malformed": {
+			input: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionVendorIdentifyingVendorSpecific, []byte{0x00, 0x00, 0x11}),
+			)},
+			wantErr: &notFoundError{},
+		},
+		"error": {wantErr: &notFoundError{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := EncodeOpt125(tt.input, "testing")
+			if tt.wantErr != nil && !OptNotFound(err) {
+				t.Fatalf("setOpt125() error (type: %T) = %[1]v, wantErr (type: %T) %[2]v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(got, tt.want, cmpopts.IgnoreUnexported(attribute.Value{})); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
 func TestSetOpt119(t *testing.T) {
 	tests := map[string]struct {
 		input   *dhcpv4.DHCPv4
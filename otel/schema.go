@@ -0,0 +1,47 @@
+package otel
+
+// The span attributes of the DHCP server, its handlers, and backends follow one naming scheme, so that dashboards
+// and queries built on them keep working as the code that sets them changes. The keys below, and the keys of the
+// encoders of this package, are stable: a key is not renamed and the type of its value is not changed, new keys are
+// added instead. Their values can be redacted, see the redact package.
+//
+// All the keys are in the Namespace namespace. The attributes of a DHCP message, set by the encoders of this package,
+// are keyed by Key, "DHCP.<message>.<field>", where message is MessageRequest or MessageReply, and field is either:
+//
+//	Header.<name>    a header field, named as in RFC 2131, for example DHCP.request.Header.chaddr
+//	Opt<code>.<Name> an option, by its code and its name in CamelCase, for example DHCP.reply.Opt12.Hostname
+//
+// The attributes of a record read from a backend, set by data.DHCP.EncodeToAttributes and
+// data.Netboot.EncodeToAttributes, are keyed "DHCP.<Field>" by the name of the field of the record, for example
+// DHCP.IPAddress. The attributes of the handling of a message are the Key constants.
+const (
+	// Namespace is the namespace of all the keys.
+	Namespace = "DHCP"
+
+	// MessageRequest is the message of the attributes of a received message.
+	MessageRequest = "request"
+	// MessageReply is the message of the attributes of a reply.
+	MessageReply = "reply"
+)
+
+// Keys of the attributes of the handling of a message.
+const (
+	// KeyPeer is the address the message was received from.
+	KeyPeer = Namespace + ".peer"
+	// KeyServerIfName is the name of the interface the message was received on.
+	KeyServerIfName = Namespace + ".server.ifname"
+	// KeyVendor is the vendor of the NIC of the client, from the OUI of its MAC address.
+	KeyVendor = Namespace + ".vendor"
+	// KeyNAKReason is the backend error a NAK was replied for.
+	KeyNAKReason = Namespace + ".nak.reason"
+	// KeyEvent is the lease event recorded in a backend: ack, release, or decline.
+	KeyEvent = Namespace + ".event"
+	// KeyMACAddress is the MAC address of the client, the same key as the MACAddress of a record.
+	KeyMACAddress = Namespace + ".MACAddress"
+)
+
+// Key returns the key of field of message, see the naming scheme above.
+// For example, Key(MessageRequest, "Opt12.Hostname") is "DHCP.request.Opt12.Hostname".
+func Key(message, field string) string {
+	return Namespace + "." + message + "." + field
+}
@@ -0,0 +1,53 @@
+package otel
+
+import (
+	"net"
+	"regexp"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+func TestKey(t *testing.T) {
+	if got, want := Key(MessageRequest, "Opt12.Hostname"), "DHCP.request.Opt12.Hostname"; got != want {
+		t.Fatalf("Key() = %q, want %q", got, want)
+	}
+}
+
+// TestSchema checks that the keys of the encoders follow the naming scheme, so that a new encoder does not break it.
+func TestSchema(t *testing.T) {
+	scheme := regexp.MustCompile(`^DHCP\.(request|reply)\.(Header\.[a-z][A-Za-z]*|Opt[0-9]+\.[A-Z][A-Za-z]*)$`)
+	pkt := &dhcpv4.DHCPv4{
+		ClientHWAddr: net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+		YourIPAddr:   net.IP{192, 168, 2, 150},
+		ServerIPAddr: net.IP{192, 168, 2, 1},
+		BootFileName: "snp.efi",
+		Options: dhcpv4.OptionsFromList(
+			dhcpv4.OptMessageType(dhcpv4.MessageTypeOffer),
+			dhcpv4.OptSubnetMask(net.IPMask{255, 255, 255, 0}),
+			dhcpv4.OptRouter(net.IP{192, 168, 2, 1}),
+			dhcpv4.OptDNS(net.IP{1, 1, 1, 1}),
+			dhcpv4.OptHostName("server1"),
+			dhcpv4.OptDomainName("example.com"),
+			dhcpv4.OptBroadcastAddress(net.IP{192, 168, 2, 255}),
+			dhcpv4.OptNTPServers(net.IP{132, 163, 96, 2}),
+			dhcpv4.OptIPAddressLeaseTime(3600e9),
+			dhcpv4.OptServerIdentifier(net.IP{192, 168, 2, 1}),
+			dhcpv4.OptClassIdentifier("PXEClient"),
+			dhcpv4.OptClientArch(iana.EFI_X86_64),
+			dhcpv4.OptUserClass("iPXE"),
+		),
+	}
+	for _, mt := range []string{MessageRequest, MessageReply} {
+		kv := (&Encoder{}).Encode(pkt, mt, AllEncoders()...)
+		if len(kv) == 0 {
+			t.Fatal("Encode() returned no attributes")
+		}
+		for _, a := range kv {
+			if !scheme.MatchString(string(a.Key)) {
+				t.Errorf("key %q does not follow the naming scheme", a.Key)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package oui looks up the vendor of the NIC of a MAC address by its OUI, the Organizationally Unique Identifier
+// of its first three bytes, so that logs and spans name the kind of device an unknown MAC address belongs to.
+package oui
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Lookup returns the vendor of the NIC of a MAC address, or an empty string when it is not known.
+// Implementations must be safe for concurrent use.
+type Lookup interface {
+	Vendor(mac net.HardwareAddr) string
+}
+
+// hexMarker separates the OUI from the vendor in the lines of the IEEE registry.
+const hexMarker = "(hex)"
+
+//go:embed oui.txt
+var embedded string
+
+// DB is a Lookup of the OUIs of a registry. The zero value knows no vendors.
+type DB struct {
+	vendors map[[3]byte]string
+}
+
+// embeddedDB parses the embedded registry once.
+var embeddedDB = sync.OnceValue(func() *DB {
+	db, err := Parse(strings.NewReader(embedded))
+	if err != nil {
+		panic(fmt.Sprintf("parsing embedded OUI registry: %v", err))
+	}
+
+	return db
+})
+
+// Default returns the DB of the embedded registry, a selection of the vendors of the servers, NICs, switches,
+// and hypervisors commonly found on provisioning networks. Use Load with the full IEEE registry for every vendor.
+func Default() *DB {
+	return embeddedDB()
+}
+
+// Load returns the DB of the registry in the file at path, see Parse.
+func Load(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse returns the DB of the registry in r, in the format of the IEEE MA-L registry, oui.txt, available from
+// https://standards-oui.ieee.org/oui/oui.txt. Only the "(hex)" lines are read, for example:
+//
+//	00-00-0C   (hex)		Cisco Systems, Inc
+//
+// The other lines, like the addresses of the vendors, are ignored.
+func Parse(r io.Reader) (*DB, error) {
+	db := &DB{vendors: make(map[[3]byte]string)}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		prefix, vendor, ok := strings.Cut(s.Text(), hexMarker)
+		if !ok {
+			continue
+		}
+		b, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(prefix), "-", ""))
+		if err != nil || len(b) != 3 {
+			return nil, fmt.Errorf("line %d: invalid OUI %q", n, strings.TrimSpace(prefix))
+		}
+		db.vendors[[3]byte(b)] = strings.TrimSpace(vendor)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Vendor implements Lookup. Locally administered MAC addresses, like the random ones of virtual machines
+// and containers, have no vendor.
+func (db *DB) Vendor(mac net.HardwareAddr) string {
+	if db == nil || len(mac) < 3 || mac[0]&0x02 != 0 {
+		return ""
+	}
+
+	return db.vendors[[3]byte(mac[:3])]
+}
+
+// Len returns the number of OUIs in db.
+func (db *DB) Len() int {
+	if db == nil {
+		return 0
+	}
+
+	return len(db.vendors)
+}
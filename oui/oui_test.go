@@ -0,0 +1,81 @@
+package oui
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const registry = `OUI/MA-L                                                    Organization
+company_id                                                  Organization
+                                                            Address
+
+00-00-0C   (hex)		Cisco Systems, Inc
+00000C     (base 16)		Cisco Systems, Inc
+				170 WEST TASMAN DRIVE
+				SAN JOSE CA 95134-1706
+				US
+
+00-50-56   (hex)		VMware, Inc.
+005056     (base 16)		VMware, Inc.
+`
+
+func TestParse(t *testing.T) {
+	db, err := Parse(strings.NewReader(registry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", db.Len())
+	}
+
+	if _, err := Parse(strings.NewReader("00-00-XY   (hex)\t\tBad Vendor\n")); err == nil {
+		t.Fatal("Parse() of an invalid OUI did not fail")
+	}
+}
+
+func TestVendor(t *testing.T) {
+	db, err := Parse(strings.NewReader(registry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		db   *DB
+		mac  net.HardwareAddr
+		want string
+	}{
+		"known":                  {db: db, mac: net.HardwareAddr{0x00, 0x50, 0x56, 0x01, 0x02, 0x03}, want: "VMware, Inc."},
+		"unknown":                {db: db, mac: net.HardwareAddr{0x00, 0x50, 0x57, 0x01, 0x02, 0x03}},
+		"locally administered":   {db: db, mac: net.HardwareAddr{0x02, 0x00, 0x0c, 0x01, 0x02, 0x03}},
+		"short":                  {db: db, mac: net.HardwareAddr{0x00, 0x00}},
+		"nil db":                 {mac: net.HardwareAddr{0x00, 0x50, 0x56, 0x01, 0x02, 0x03}},
+		"default":                {db: Default(), mac: net.HardwareAddr{0xb8, 0x27, 0xeb, 0x01, 0x02, 0x03}, want: "Raspberry Pi Foundation"},
+		"default is a selection": {db: Default(), mac: net.HardwareAddr{0x00, 0x00, 0x01, 0x01, 0x02, 0x03}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.db.Vendor(tt.mac); got != tt.want {
+				t.Fatalf("Vendor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oui.txt")
+	if err := os.WriteFile(path, []byte(registry), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Vendor(net.HardwareAddr{0x00, 0x00, 0x0c, 0x01, 0x02, 0x03}); got != "Cisco Systems, Inc" {
+		t.Fatalf("Vendor() = %q, want %q", got, "Cisco Systems, Inc")
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Fatal("Load() of a missing file did not fail")
+	}
+}
@@ -0,0 +1,61 @@
+package dhcp
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// pool runs the handling of received messages on a bounded number of goroutines.
+// A nil pool runs every job in its own goroutine.
+type pool struct {
+	jobs    chan func()
+	wg      sync.WaitGroup
+	dropped *atomic.Uint64
+}
+
+// newPool starts workers goroutines that run the jobs submitted to the pool. Up to queue jobs wait for a worker,
+// jobs submitted while the queue is full are dropped and counted in dropped. It returns nil when workers is not positive.
+func newPool(workers, queue int, dropped *atomic.Uint64) *pool {
+	if workers <= 0 {
+		return nil
+	}
+	if queue <= 0 {
+		queue = workers
+	}
+	p := &pool{jobs: make(chan func(), queue), dropped: dropped}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+
+	return p
+}
+
+// submit queues job to be run by a worker. It returns false if the queue was full and job was dropped.
+func (p *pool) submit(job func()) bool {
+	if p == nil {
+		go job()
+		return true
+	}
+	select {
+	case p.jobs <- job:
+		return true
+	default:
+		p.dropped.Add(1)
+		return false
+	}
+}
+
+// stop stops the workers after the queued jobs are run, and waits for them to return.
+func (p *pool) stop() {
+	if p == nil {
+		return
+	}
+	close(p.jobs)
+	p.wg.Wait()
+}
@@ -0,0 +1,122 @@
+package dhcp
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/data"
+	"golang.org/x/net/ipv4"
+)
+
+func TestPool(t *testing.T) {
+	var dropped atomic.Uint64
+	p := newPool(1, 1, &dropped)
+	started, release := make(chan struct{}), make(chan struct{})
+	var ran atomic.Int32
+	job := func() {
+		started <- struct{}{}
+		<-release
+		ran.Add(1)
+	}
+
+	if !p.submit(job) {
+		t.Fatal("submit() = false, want the first job to run")
+	}
+	<-started
+	if !p.submit(job) {
+		t.Fatal("submit() = false, want the second job to be queued")
+	}
+	if p.submit(job) {
+		t.Fatal("submit() = true, want the third job to be dropped")
+	}
+	if got := dropped.Load(); got != 1 {
+		t.Fatalf("got %d dropped, want 1", got)
+	}
+
+	close(release)
+	<-started
+	p.stop()
+	if got := ran.Load(); got != 2 {
+		t.Fatalf("got %d jobs run, want 2", got)
+	}
+}
+
+func TestNilPool(t *testing.T) {
+	p := newPool(0, 0, nil)
+	if p != nil {
+		t.Fatal("newPool() != nil, want nil without workers")
+	}
+	done := make(chan struct{})
+	p.submit(func() { close(done) })
+	<-done
+	p.stop()
+}
+
+// blocking is a handler that blocks until release is closed.
+type blocking struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (b *blocking) Handle(context.Context, *ipv4.PacketConn, data.Packet) {
+	b.calls.Add(1)
+	<-b.release
+}
+
+func TestServeWorkers(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &blocking{release: make(chan struct{})}
+	s := &Server{Conn: conn, Handlers: []Handler{h}, Logger: logr.Discard(), Workers: 2, QueueSize: 2}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx) }()
+
+	c, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	req, err := dhcpv4.NewDiscovery(net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(n int) {
+		for i := 0; i < n; i++ {
+			if _, err := c.WriteTo(req.ToBytes(), conn.LocalAddr()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// 2 messages keep the workers busy, then 2 wait in the queue, and the rest are dropped.
+	send(2)
+	for start := time.Now(); h.calls.Load() < 2 && time.Since(start) < 2*time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	send(8)
+	for start := time.Now(); s.Dropped() < 6 && time.Since(start) < 2*time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := s.Dropped(); got != 6 {
+		t.Fatalf("got %d dropped, want 6", got)
+	}
+	if got := h.calls.Load(); got != 2 {
+		t.Fatalf("got %d handler calls, want 2 with 2 workers", got)
+	}
+
+	close(h.release)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := h.calls.Load(); got != 4 {
+		t.Fatalf("got %d handler calls, want the 4 accepted messages handled", got)
+	}
+}
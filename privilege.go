@@ -0,0 +1,31 @@
+package dhcp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrPrivilege is returned, with advice on how to grant it, when the process misses a privilege it needs to open a socket.
+var ErrPrivilege = errors.New("insufficient privileges")
+
+// CheckPrivileges returns an error wrapping ErrPrivilege if the process can not listen on the UDP port, or, when raw
+// is set, can not open a raw socket, as a RawServer does. Servers check it before they open their sockets, so an
+// unprivileged process fails with an error that says which capability is missing instead of a bare permission error.
+//
+// On Linux, ports below net.ipv4.ip_unprivileged_port_start, 1024 by default, need the CAP_NET_BIND_SERVICE capability
+// and raw sockets need CAP_NET_RAW. To run without them, listen on a higher port and redirect the DHCP server port
+// to it, see docs/Non-Root.md. On other platforms it always returns nil.
+func CheckPrivileges(port int, raw bool) error {
+	if port > 0 && port < unprivilegedPortStart() && !hasCapability(capNetBindService) {
+		return fmt.Errorf("%w: listening on port %d needs the CAP_NET_BIND_SERVICE capability, run as root, "+
+			"grant it to the binary (setcap cap_net_bind_service=+ep) or container (securityContext.capabilities.add), "+
+			"or listen on a port of %d or more and redirect port %d to it", ErrPrivilege, port, unprivilegedPortStart(), port)
+	}
+	if raw && !hasCapability(capNetRaw) {
+		return fmt.Errorf("%w: raw sockets need the CAP_NET_RAW capability, run as root, "+
+			"grant it to the binary (setcap cap_net_raw=+ep) or container (securityContext.capabilities.add), "+
+			"or use a Server instead of a RawServer", ErrPrivilege)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package dhcp
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Capabilities that CheckPrivileges checks for.
+const (
+	capNetBindService = unix.CAP_NET_BIND_SERVICE
+	capNetRaw         = unix.CAP_NET_RAW
+)
+
+// hasCapability returns true if capability c is in the effective set of the process.
+// If the set can not be read, it returns true, so that opening the socket reports the error.
+func hasCapability(c int) bool {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var d [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &d[0]); err != nil {
+		return true
+	}
+
+	return d[c/32].Effective&(1<<(uint(c)%32)) != 0
+}
+
+// unprivilegedPortStart returns the lowest port that needs no capability to listen on.
+func unprivilegedPortStart() int {
+	b, err := os.ReadFile("/proc/sys/net/ipv4/ip_unprivileged_port_start")
+	if err != nil {
+		return 1024
+	}
+	p, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 1024
+	}
+
+	return p
+}
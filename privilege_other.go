@@ -0,0 +1,19 @@
+//go:build !linux
+
+package dhcp
+
+// Capabilities that CheckPrivileges checks for. They only exist on Linux.
+const (
+	capNetBindService = iota
+	capNetRaw
+)
+
+// hasCapability returns true, privileges are not checked on other platforms.
+func hasCapability(int) bool {
+	return true
+}
+
+// unprivilegedPortStart returns 0, privileges are not checked on other platforms.
+func unprivilegedPortStart() int {
+	return 0
+}
@@ -0,0 +1,35 @@
+package dhcp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckPrivileges(t *testing.T) {
+	if err := CheckPrivileges(0, false); err != nil {
+		t.Fatalf("CheckPrivileges() error = %v for no port, want nil", err)
+	}
+	if p := unprivilegedPortStart(); p > 0 {
+		if err := CheckPrivileges(p, false); err != nil {
+			t.Fatalf("CheckPrivileges() error = %v for unprivileged port %d, want nil", err, p)
+		}
+	}
+
+	err := CheckPrivileges(67, false)
+	if hasCapability(capNetBindService) || unprivilegedPortStart() <= 67 {
+		if err != nil {
+			t.Fatalf("CheckPrivileges() error = %v with CAP_NET_BIND_SERVICE, want nil", err)
+		}
+	} else if !errors.Is(err, ErrPrivilege) {
+		t.Fatalf("CheckPrivileges() error = %v without CAP_NET_BIND_SERVICE, want %v", err, ErrPrivilege)
+	}
+
+	err = CheckPrivileges(0, true)
+	if hasCapability(capNetRaw) {
+		if err != nil {
+			t.Fatalf("CheckPrivileges() error = %v with CAP_NET_RAW, want nil", err)
+		}
+	} else if !errors.Is(err, ErrPrivilege) {
+		t.Fatalf("CheckPrivileges() error = %v without CAP_NET_RAW, want %v", err, ErrPrivilege)
+	}
+}
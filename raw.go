@@ -0,0 +1,224 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/metrics"
+	"github.com/tinkerbell/dhcp/redact"
+	"golang.org/x/time/rate"
+)
+
+// Header lengths and values of the frames a RawServer receives and transmits.
+const (
+	ethHeaderLen  = 14
+	ipv4HeaderLen = 20
+	udpHeaderLen  = 8
+	etherTypeIPv4 = 0x0800
+	protocolUDP   = 17
+	defaultTTL    = 64
+)
+
+// errNotDHCP is returned when a frame is not a UDP datagram to the DHCP server port.
+var errNotDHCP = errors.New("not a DHCP server datagram")
+
+// RawServer is a DHCP server that receives and transmits full Ethernet frames on a raw (AF_PACKET) socket bound to Interface.
+// It is only supported on Linux, and requires the CAP_NET_RAW capability.
+//
+// Replies to clients on the link are sent to the MAC address of the client. An OFFER to a client without an IP address
+// is unicast to its MAC address, unless the client set the broadcast flag, which a UDP socket can not do. A DHCPNAK is
+// broadcast. Handlers write these replies to the peer of the message, as data.Metadata.ReplyToPeer asks them to.
+//
+// Relayed messages are replied to with a UDP socket bound to Addr on Interface. The socket also stops the kernel from
+// answering unicast messages to the DHCP server port with ICMP port unreachable errors.
+type RawServer struct {
+	// Interface is the name of the interface to listen on. It is required.
+	Interface string
+
+	// Addr is the address of the UDP socket for relayed messages. Defaults to 0.0.0.0:67.
+	Addr netip.AddrPort
+
+	// Handlers handle the messages received on Interface.
+	Handlers []Handler
+
+	// Logger is used to log messages.
+	Logger logr.Logger
+
+	// Workers bounds the number of messages that are handled at once. A zero value handles every message in its own goroutine.
+	Workers int
+
+	// QueueSize is the number of messages that wait for a worker when all Workers are busy. Messages received while
+	// the queue is full are dropped, see Dropped. Defaults to Workers.
+	QueueSize int
+
+	// DedupWindow, when set, suppresses the retransmissions of a message that are received within DedupWindow of it,
+	// see Server.DedupWindow.
+	DedupWindow time.Duration
+
+	// SerializeClients, when set, handles the messages of each client one at a time, see Server.SerializeClients.
+	SerializeClients bool
+
+	// HandlerTimeout, when set, bounds the handling of each message, see Server.HandlerTimeout.
+	HandlerTimeout time.Duration
+
+	// Limiter, when set, caps the rate of messages that are handled, see Server.Limiter.
+	Limiter *rate.Limiter
+
+	// Redact redacts the MAC addresses in the logs of the server, see Server.Redact.
+	Redact redact.Redactor
+
+	// Metrics, when set, records the received messages, see Server.Metrics.
+	Metrics metrics.Metrics
+
+	dropped    atomic.Uint64
+	suppressed atomic.Uint64
+	panics     atomic.Uint64
+	expired    atomic.Uint64
+	limited    atomic.Uint64
+}
+
+// RateLimited returns the number of messages that were dropped because they were over the rate of Limiter.
+func (r *RawServer) RateLimited() uint64 {
+	return r.limited.Load()
+}
+
+// Expired returns the number of handler calls that did not finish within HandlerTimeout.
+func (r *RawServer) Expired() uint64 {
+	return r.expired.Load()
+}
+
+// Panics returns the number of handler calls that panicked. The panics are recovered and logged.
+func (r *RawServer) Panics() uint64 {
+	return r.panics.Load()
+}
+
+// Suppressed returns the number of messages that were suppressed as retransmissions, see DedupWindow.
+func (r *RawServer) Suppressed() uint64 {
+	return r.suppressed.Load()
+}
+
+// Dropped returns the number of handler calls that were dropped because all Workers were busy and the queue was full.
+func (r *RawServer) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+// parseFrame returns the source, the destination IP, and the UDP payload of the Ethernet frame b,
+// or errNotDHCP if b is not an unfragmented IPv4 UDP datagram to the DHCP server port.
+func parseFrame(b []byte) (netip.AddrPort, netip.Addr, []byte, error) {
+	if len(b) < ethHeaderLen+ipv4HeaderLen+udpHeaderLen || binary.BigEndian.Uint16(b[12:14]) != etherTypeIPv4 {
+		return netip.AddrPort{}, netip.Addr{}, nil, errNotDHCP
+	}
+	ip := b[ethHeaderLen:]
+	ihl := int(ip[0]&0x0f) * 4
+	total := int(binary.BigEndian.Uint16(ip[2:4]))
+	switch {
+	case ip[0]>>4 != 4, ihl < ipv4HeaderLen, total < ihl+udpHeaderLen, total > len(ip):
+		return netip.AddrPort{}, netip.Addr{}, nil, fmt.Errorf("%w: invalid IPv4 header", errNotDHCP)
+	case ip[9] != protocolUDP:
+		return netip.AddrPort{}, netip.Addr{}, nil, errNotDHCP
+	case binary.BigEndian.Uint16(ip[6:8])&0x3fff != 0:
+		// the more fragments flag or a fragment offset is set. DHCP messages are never fragmented.
+		return netip.AddrPort{}, netip.Addr{}, nil, fmt.Errorf("%w: fragmented", errNotDHCP)
+	}
+	src, dst := netip.AddrFrom4([4]byte(ip[12:16])), netip.AddrFrom4([4]byte(ip[16:20]))
+	udp := ip[ihl:total]
+	if binary.BigEndian.Uint16(udp[2:4]) != dhcpv4.ServerPort {
+		return netip.AddrPort{}, netip.Addr{}, nil, errNotDHCP
+	}
+	l := int(binary.BigEndian.Uint16(udp[4:6]))
+	if l < udpHeaderLen || l > len(udp) {
+		return netip.AddrPort{}, netip.Addr{}, nil, fmt.Errorf("%w: invalid UDP length", errNotDHCP)
+	}
+
+	return netip.AddrPortFrom(src, binary.BigEndian.Uint16(udp[0:2])), dst, udp[udpHeaderLen:l], nil
+}
+
+// frameDestination returns the MAC and IP addresses that the reply to a client on the link is sent to, following
+// page 23 of https://www.ietf.org/rfc/rfc2131.txt: a client with an IP address (ciaddr) is unicast to,
+// a client that set the broadcast flag, and any DHCPNAK, is broadcast to, and otherwise the reply is unicast
+// to the client hardware address and yiaddr.
+func frameDestination(reply *dhcpv4.DHCPv4) (net.HardwareAddr, net.IP) {
+	bcast := net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	switch {
+	case reply.MessageType() == dhcpv4.MessageTypeNak:
+		return bcast, net.IPv4bcast
+	case reply.ClientIPAddr != nil && !reply.ClientIPAddr.IsUnspecified():
+		return reply.ClientHWAddr, reply.ClientIPAddr
+	case reply.IsBroadcast(), reply.YourIPAddr == nil, reply.YourIPAddr.IsUnspecified():
+		return bcast, net.IPv4bcast
+	}
+
+	return reply.ClientHWAddr, reply.YourIPAddr
+}
+
+// frame returns the Ethernet frame that carries reply from srcMAC and srcIP to the client.
+func frame(srcMAC net.HardwareAddr, srcIP net.IP, reply *dhcpv4.DHCPv4) ([]byte, error) {
+	dstMAC, dstIP := frameDestination(reply)
+	src, srcOK := netip.AddrFromSlice(srcIP.To4())
+	dst, dstOK := netip.AddrFromSlice(dstIP.To4())
+	if len(srcMAC) != 6 || len(dstMAC) != 6 || !srcOK || !dstOK {
+		return nil, fmt.Errorf("can not address a frame from %v (%v) to %v (%v)", srcIP, srcMAC, dstIP, dstMAC)
+	}
+
+	return udpFrame(srcMAC, dstMAC, netip.AddrPortFrom(src, dhcpv4.ServerPort), netip.AddrPortFrom(dst, dhcpv4.ClientPort), reply.ToBytes()), nil
+}
+
+// udpFrame returns the Ethernet frame of an IPv4 UDP datagram with payload, from src to dst.
+func udpFrame(srcMAC, dstMAC net.HardwareAddr, src, dst netip.AddrPort, payload []byte) []byte {
+	b := make([]byte, ethHeaderLen+ipv4HeaderLen+udpHeaderLen+len(payload))
+
+	copy(b[0:6], dstMAC)
+	copy(b[6:12], srcMAC)
+	binary.BigEndian.PutUint16(b[12:14], etherTypeIPv4)
+
+	srcIP, dstIP := src.Addr().As4(), dst.Addr().As4()
+	ip := b[ethHeaderLen:]
+	ip[0] = 0x45 // version 4, 5 word header.
+	binary.BigEndian.PutUint16(ip[2:4], uint16(len(ip)))
+	ip[8] = defaultTTL
+	ip[9] = protocolUDP
+	copy(ip[12:16], srcIP[:])
+	copy(ip[16:20], dstIP[:])
+	binary.BigEndian.PutUint16(ip[10:12], checksum(0, ip[:ipv4HeaderLen]))
+
+	udp := ip[ipv4HeaderLen:]
+	binary.BigEndian.PutUint16(udp[0:2], src.Port())
+	binary.BigEndian.PutUint16(udp[2:4], dst.Port())
+	binary.BigEndian.PutUint16(udp[4:6], uint16(len(udp)))
+	copy(udp[udpHeaderLen:], payload)
+	// the checksum covers a pseudo header of the addresses, protocol, and UDP length.
+	pseudo := uint32(binary.BigEndian.Uint16(srcIP[0:2])) + uint32(binary.BigEndian.Uint16(srcIP[2:4])) +
+		uint32(binary.BigEndian.Uint16(dstIP[0:2])) + uint32(binary.BigEndian.Uint16(dstIP[2:4])) +
+		protocolUDP + uint32(len(udp))
+	sum := checksum(pseudo, udp)
+	if sum == 0 {
+		// a zero checksum means no checksum, it is sent as all ones.
+		sum = 0xffff
+	}
+	binary.BigEndian.PutUint16(udp[6:8], sum)
+
+	return b
+}
+
+// checksum returns the internet checksum of b, starting from the partial sum initial. See RFC 1071.
+func checksum(initial uint32, b []byte) uint16 {
+	sum := initial
+	for ; len(b) > 1; b = b[2:] {
+		sum += uint32(binary.BigEndian.Uint16(b))
+	}
+	if len(b) == 1 {
+		sum += uint32(b[0]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+
+	return ^uint16(sum)
+}
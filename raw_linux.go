@@ -0,0 +1,303 @@
+//go:build linux
+
+package dhcp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/metrics"
+	"golang.org/x/net/bpf"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/sys/unix"
+)
+
+// filter only passes unfragmented IPv4 UDP datagrams to the DHCP server port that carry a BOOTREQUEST to the raw socket.
+// Everything else on a busy broadcast domain, including the replies of other DHCP servers, is dropped in the kernel
+// instead of waking the server up to parse it.
+var filter = []bpf.Instruction{
+	bpf.LoadAbsolute{Off: 12, Size: 2},
+	bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: etherTypeIPv4, SkipTrue: 10},
+	bpf.LoadAbsolute{Off: ethHeaderLen + 9, Size: 1},
+	bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: protocolUDP, SkipTrue: 8},
+	bpf.LoadAbsolute{Off: ethHeaderLen + 6, Size: 2},
+	bpf.JumpIf{Cond: bpf.JumpBitsSet, Val: 0x3fff, SkipTrue: 6},
+	bpf.LoadMemShift{Off: ethHeaderLen},
+	bpf.LoadIndirect{Off: ethHeaderLen + 2, Size: 2},
+	bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: dhcpv4.ServerPort, SkipTrue: 3},
+	bpf.LoadIndirect{Off: ethHeaderLen + udpHeaderLen, Size: 1},
+	bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: uint32(dhcpv4.OpcodeBootRequest), SkipTrue: 1},
+	bpf.RetConstant{Val: 0xffff},
+	bpf.RetConstant{Val: 0},
+}
+
+// Serve serves requests on Interface until ctx is done.
+func (r *RawServer) Serve(ctx context.Context) error {
+	if r.Logger.GetSink() == nil {
+		r.Logger = logr.Discard()
+	}
+	iface, err := net.InterfaceByName(r.Interface)
+	if err != nil {
+		return err
+	}
+	ifIP, err := interfaceIPv4(iface)
+	if err != nil {
+		return err
+	}
+	addr := &net.UDPAddr{Port: dhcpv4.ServerPort}
+	if r.Addr.IsValid() {
+		addr = net.UDPAddrFromAddrPort(r.Addr)
+	}
+	if err := CheckPrivileges(addr.Port, true); err != nil {
+		return err
+	}
+	raw, err := packetSocket(iface)
+	if err != nil {
+		return err
+	}
+	defer raw.Close()
+
+	relay, err := NewConn(iface.Name, addr)
+	if err != nil {
+		return err
+	}
+	defer relay.Close()
+
+	// Handlers write replies to clients on the link to sink, through out. They are then sent from raw as frames.
+	out, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	sink, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+
+	go func() {
+		<-ctx.Done()
+		_ = raw.Close()
+	}()
+	go r.transmit(raw, sink, iface.HardwareAddr, ifIP)
+	r.Logger.Info("Server listening on", "interface", iface.Name, "mode", "raw")
+
+	relayConn, outConn := ipv4.NewPacketConn(relay), ipv4.NewPacketConn(out)
+	workers := newPool(r.Workers, r.QueueSize, &r.dropped)
+	defer workers.stop()
+	rec := metrics.OrNoop(r.Metrics)
+	dd := newDedup(r.DedupWindow, &r.suppressed)
+	sz := newSerializer(r.SerializeClients)
+	d := dispatch{log: r.Logger, timeout: r.HandlerTimeout, panics: &r.panics, expired: &r.expired, metrics: rec, redact: r.Redact}
+	buf, oob := make([]byte, 65536), make([]byte, unix.CmsgSpace(auxdataLen))
+	for {
+		n, vlan, err := readFrame(raw, buf, oob)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			r.Logger.Info("error reading from raw socket", "err", err)
+			return err
+		}
+		received := time.Now()
+		src, dst, payload, err := parseFrame(buf[:n])
+		if err != nil {
+			continue
+		}
+		m, err := dhcpv4.FromBytes(payload)
+		if err != nil {
+			rec.Error(metrics.ReasonMalformed)
+			r.Logger.Info("error parsing DHCPv4 request", "err", err)
+			continue
+		}
+		if err := checkRequest(m); err != nil {
+			if errors.Is(err, errMalformed) {
+				rec.Error(metrics.ReasonMalformed)
+				r.Logger.Info("error parsing DHCPv4 request", "err", err)
+			}
+			continue
+		}
+		if r.Limiter != nil && !r.Limiter.Allow() {
+			r.limited.Add(1)
+			rec.Error(metrics.ReasonRateLimited)
+			continue
+		}
+		rec.Received(m.MessageType())
+		if dd.duplicate(m, received) {
+			rec.Error(metrics.ReasonDuplicate)
+			continue
+		}
+
+		// every reply to a client on the link, a DHCPNAK too, is written to sink and sent as a frame by transmit.
+		md := &data.Metadata{IfName: iface.Name, IfIndex: iface.Index, LocalIP: dst, VLANID: vlan, SrcPort: src.Port(), Received: received, ReplyToPeer: true}
+		p := data.Packet{Peer: sink.LocalAddr(), Pkt: m, Md: md}
+		conn := outConn
+		if m.GatewayIPAddr != nil && !m.GatewayIPAddr.IsUnspecified() {
+			md.ReplyToPeer = false
+			p.Peer, conn = net.UDPAddrFromAddrPort(src), relayConn
+		}
+		for i, h := range r.Handlers {
+			h := h
+			run, skip := sz.wrap(i, m.ClientHWAddr, func() { d.handle(ctx, h, conn, p) })
+			if !workers.submit(run) {
+				skip()
+				rec.Error(metrics.ReasonQueueFull)
+			}
+		}
+	}
+}
+
+// transmit sends the replies that handlers write to sink as frames from mac and ip on raw, until sink is closed.
+// The source IP of a frame is the server identifier of the reply, when it has one.
+func (r *RawServer) transmit(raw *os.File, sink net.PacketConn, mac net.HardwareAddr, ip net.IP) {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := sink.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		reply, err := dhcpv4.FromBytes(buf[:n])
+		if err != nil {
+			r.Logger.Info("error parsing DHCPv4 reply", "err", err)
+			continue
+		}
+		src := ip
+		if sid := reply.ServerIdentifier(); sid.To4() != nil {
+			src = sid
+		}
+		f, err := frame(mac, src, reply)
+		if err != nil {
+			r.Logger.Info("error building frame", "err", err, "mac", r.Redact.MAC(reply.ClientHWAddr))
+			continue
+		}
+		if _, err := raw.Write(f); err != nil {
+			r.Logger.Info("error writing to raw socket", "err", err, "mac", r.Redact.MAC(reply.ClientHWAddr))
+		}
+	}
+}
+
+// packetSocket returns a non-blocking raw socket bound to iface that only receives DHCP server datagrams.
+// The socket is pollable, so closing it unblocks a Read.
+func packetSocket(iface *net.Interface) (*os.File, error) {
+	proto := htons(unix.ETH_P_IP)
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, int(proto))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get a raw socket: %w", err)
+	}
+	prog, err := bpf.Assemble(filter)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+	sf := make([]unix.SockFilter, len(prog))
+	for i, ins := range prog {
+		sf[i] = unix.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+	}
+	if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &unix.SockFprog{Len: uint16(len(sf)), Filter: &sf[0]}); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("cannot attach filter to raw socket: %w", err)
+	}
+	// the kernel strips the 802.1Q tag of a frame before it is read, its VLAN ID is passed in a control message instead.
+	if err := unix.SetsockoptInt(fd, unix.SOL_PACKET, unix.PACKET_AUXDATA, 1); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("cannot enable auxiliary data on raw socket: %w", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: proto, Ifindex: iface.Index}); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("cannot bind raw socket to interface %v: %w", iface.Name, err)
+	}
+	drain(fd)
+
+	return os.NewFile(uintptr(fd), "packet:"+iface.Name), nil
+}
+
+// auxdataLen is the size of struct tpacket_auxdata, and auxdataVLANTCI the offset of its tp_vlan_tci field.
+const (
+	auxdataLen     = 20
+	auxdataVLANTCI = 16
+)
+
+// readFrame reads a frame from raw into buf and returns its length and VLAN ID, which is 0 for an untagged frame.
+// oob holds the control messages of the frame.
+func readFrame(raw *os.File, buf, oob []byte) (int, uint16, error) {
+	rc, err := raw.SyscallConn()
+	if err != nil {
+		return 0, 0, err
+	}
+	var n, oobn int
+	var rerr error
+	err = rc.Read(func(fd uintptr) bool {
+		n, oobn, _, _, rerr = unix.Recvmsg(int(fd), buf, oob, 0)
+		return !errors.Is(rerr, unix.EAGAIN)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if rerr != nil {
+		return 0, 0, rerr
+	}
+
+	return n, auxdataVLAN(oob[:oobn]), nil
+}
+
+// auxdataVLAN returns the VLAN ID from the PACKET_AUXDATA control message in oob, or 0 if there is none.
+func auxdataVLAN(oob []byte) uint16 {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0
+	}
+	for _, m := range msgs {
+		if m.Header.Level != unix.SOL_PACKET || m.Header.Type != unix.PACKET_AUXDATA || len(m.Data) < auxdataLen {
+			continue
+		}
+		if binary.NativeEndian.Uint32(m.Data)&unix.TP_STATUS_VLAN_VALID == 0 {
+			return 0
+		}
+		// the low 12 bits of the tag control information are the VLAN ID, the rest are the priority and DEI bits.
+		return binary.NativeEndian.Uint16(m.Data[auxdataVLANTCI:]) & 0x0fff
+	}
+
+	return 0
+}
+
+// drain discards the frames that were queued on the non-blocking socket fd before its filter was attached.
+// The socket receives the frames of every interface from when it is opened, so they may be anything.
+func drain(fd int) {
+	buf := make([]byte, 1)
+	for {
+		if _, _, err := unix.Recvfrom(fd, buf, unix.MSG_TRUNC); err != nil {
+			return
+		}
+	}
+}
+
+// interfaceIPv4 returns the first IPv4 address of iface.
+func interfaceIPv4(iface *net.Interface) (net.IP, error) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.To4() != nil {
+			return n.IP.To4(), nil
+		}
+	}
+
+	return nil, errors.New("interface " + iface.Name + " has no IPv4 address")
+}
+
+// htons converts v from host to network byte order.
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+
+	return binary.NativeEndian.Uint16(b[:])
+}
@@ -0,0 +1,79 @@
+//go:build linux
+
+package dhcp
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"unsafe"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+func TestFilter(t *testing.T) {
+	vm, err := bpf.NewVM(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := netip.MustParseAddrPort("0.0.0.0:68")
+	request := []byte{byte(dhcpv4.OpcodeBootRequest), 0x01}
+	tests := map[string]struct {
+		frame []byte
+		want  bool
+	}{
+		"DHCP server port": {frame: udpFrame(clientMAC, bcastMAC, src, netip.MustParseAddrPort("255.255.255.255:67"), request), want: true},
+		"other port":       {frame: udpFrame(clientMAC, bcastMAC, src, netip.MustParseAddrPort("255.255.255.255:68"), request)},
+		"reply":            {frame: udpFrame(clientMAC, bcastMAC, src, netip.MustParseAddrPort("255.255.255.255:67"), []byte{byte(dhcpv4.OpcodeBootReply), 0x01})},
+		"fragment": {frame: func() []byte {
+			b := udpFrame(clientMAC, bcastMAC, src, netip.MustParseAddrPort("255.255.255.255:67"), request)
+			b[ethHeaderLen+7] = 1
+			return b
+		}()},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			n, err := vm.Run(tt.frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := n > 0; got != tt.want {
+				t.Fatalf("filter passed the frame = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuxdataVLAN(t *testing.T) {
+	tests := map[string]struct {
+		oob  []byte
+		want uint16
+	}{
+		"none":     {},
+		"untagged": {oob: auxdata(0, 0)},
+		"tagged":   {oob: auxdata(unix.TP_STATUS_VLAN_VALID, 100), want: 100},
+		"priority": {oob: auxdata(unix.TP_STATUS_VLAN_VALID, 5<<13|100), want: 100},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := auxdataVLAN(tt.oob); got != tt.want {
+				t.Fatalf("auxdataVLAN() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// auxdata returns a PACKET_AUXDATA control message with status and the tag control information tci.
+func auxdata(status uint32, tci uint16) []byte {
+	b := make([]byte, unix.CmsgSpace(auxdataLen))
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&b[0]))
+	h.Level, h.Type = unix.SOL_PACKET, unix.PACKET_AUXDATA
+	h.SetLen(unix.CmsgLen(auxdataLen))
+	d := b[unix.CmsgLen(0):]
+	binary.NativeEndian.PutUint32(d, status)
+	binary.NativeEndian.PutUint16(d[auxdataVLANTCI:], tci)
+
+	return b
+}
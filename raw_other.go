@@ -0,0 +1,13 @@
+//go:build !linux
+
+package dhcp
+
+import (
+	"context"
+	"errors"
+)
+
+// Serve returns an error, raw sockets are only supported on Linux.
+func (r *RawServer) Serve(context.Context) error {
+	return errors.New("raw socket listener is only supported on Linux")
+}
@@ -0,0 +1,181 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+var (
+	clientMAC = net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	serverMAC = net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+	bcastMAC  = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+func TestParseFrame(t *testing.T) {
+	src := netip.MustParseAddrPort("0.0.0.0:68")
+	dst := netip.MustParseAddrPort("255.255.255.255:67")
+	payload := []byte("a DHCP message")
+	tests := map[string]struct {
+		frame   func() []byte
+		wantErr error
+	}{
+		"DHCP": {frame: func() []byte { return udpFrame(clientMAC, bcastMAC, src, dst, payload) }},
+		"other port": {frame: func() []byte {
+			return udpFrame(clientMAC, bcastMAC, src, netip.MustParseAddrPort("255.255.255.255:68"), payload)
+		}, wantErr: errNotDHCP},
+		"not IPv4": {frame: func() []byte {
+			b := udpFrame(clientMAC, bcastMAC, src, dst, payload)
+			binary.BigEndian.PutUint16(b[12:14], 0x86dd)
+			return b
+		}, wantErr: errNotDHCP},
+		"not UDP": {frame: func() []byte {
+			b := udpFrame(clientMAC, bcastMAC, src, dst, payload)
+			b[ethHeaderLen+9] = 6
+			return b
+		}, wantErr: errNotDHCP},
+		"fragment": {frame: func() []byte {
+			b := udpFrame(clientMAC, bcastMAC, src, dst, payload)
+			b[ethHeaderLen+6] = 0x20
+			return b
+		}, wantErr: errNotDHCP},
+		"truncated": {frame: func() []byte { return udpFrame(clientMAC, bcastMAC, src, dst, payload)[:40] }, wantErr: errNotDHCP},
+		"bad UDP length": {frame: func() []byte {
+			b := udpFrame(clientMAC, bcastMAC, src, dst, payload)
+			binary.BigEndian.PutUint16(b[ethHeaderLen+ipv4HeaderLen+4:], 1000)
+			return b
+		}, wantErr: errNotDHCP},
+		"ethernet padding": {frame: func() []byte { return append(udpFrame(clientMAC, bcastMAC, src, dst, payload), 0, 0, 0, 0) }},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotSrc, gotDst, got, err := parseFrame(tt.frame())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseFrame() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if diff := cmp.Diff(src, gotSrc, cmp.Comparer(func(a, b netip.AddrPort) bool { return a == b })); diff != "" {
+				t.Fatal(diff)
+			}
+			if gotDst != dst.Addr() {
+				t.Fatalf("parseFrame() dst = %v, want %v", gotDst, dst.Addr())
+			}
+			if diff := cmp.Diff(payload, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+// FuzzParseFrame feeds arbitrary frames to the raw server's frame parser, and the messages they carry to the frame
+// builder of the replies. Neither must panic.
+func FuzzParseFrame(f *testing.F) {
+	src := netip.MustParseAddrPort("0.0.0.0:68")
+	dst := netip.MustParseAddrPort("255.255.255.255:67")
+	req, err := dhcpv4.NewDiscovery(clientMAC)
+	if err != nil {
+		f.Fatal(err)
+	}
+	b := udpFrame(clientMAC, bcastMAC, src, dst, req.ToBytes())
+	f.Add(b)
+	f.Add(b[:ethHeaderLen+ipv4HeaderLen+udpHeaderLen])
+	f.Add(b[:len(b)-1])
+	f.Add(append(b[:len(b):len(b)], 0, 0, 0, 0))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _, payload, err := parseFrame(b)
+		if err != nil {
+			return
+		}
+		m, err := dhcpv4.FromBytes(payload)
+		if err != nil || checkRequest(m) != nil {
+			return
+		}
+		reply, err := dhcpv4.NewReplyFromRequest(m, dhcpv4.WithYourIP(net.IP{192, 168, 2, 150}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = frame(serverMAC, net.IP{192, 168, 2, 225}, reply)
+	})
+}
+
+func TestFrameDestination(t *testing.T) {
+	yiaddr := net.IP{192, 168, 1, 100}
+	tests := map[string]struct {
+		mods    []dhcpv4.Modifier
+		wantMAC net.HardwareAddr
+		wantIP  net.IP
+	}{
+		"unicast to yiaddr": {mods: []dhcpv4.Modifier{dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer), dhcpv4.WithYourIP(yiaddr)}, wantMAC: clientMAC, wantIP: yiaddr},
+		"broadcast flag":    {mods: []dhcpv4.Modifier{dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer), dhcpv4.WithYourIP(yiaddr), dhcpv4.WithBroadcast(true)}, wantMAC: bcastMAC, wantIP: net.IPv4bcast},
+		"ciaddr":            {mods: []dhcpv4.Modifier{dhcpv4.WithMessageType(dhcpv4.MessageTypeAck), dhcpv4.WithYourIP(yiaddr), dhcpv4.WithClientIP(net.IP{192, 168, 1, 50})}, wantMAC: clientMAC, wantIP: net.IP{192, 168, 1, 50}},
+		"NAK":               {mods: []dhcpv4.Modifier{dhcpv4.WithMessageType(dhcpv4.MessageTypeNak), dhcpv4.WithClientIP(net.IP{192, 168, 1, 50})}, wantMAC: bcastMAC, wantIP: net.IPv4bcast},
+		"no yiaddr":         {mods: []dhcpv4.Modifier{dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer)}, wantMAC: bcastMAC, wantIP: net.IPv4bcast},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			reply, err := dhcpv4.New(append([]dhcpv4.Modifier{dhcpv4.WithHwAddr(clientMAC)}, tt.mods...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mac, ip := frameDestination(reply)
+			if diff := cmp.Diff(tt.wantMAC, mac); diff != "" {
+				t.Fatal(diff)
+			}
+			if !ip.Equal(tt.wantIP) {
+				t.Fatalf("got IP %v, want %v", ip, tt.wantIP)
+			}
+		})
+	}
+}
+
+func TestFrame(t *testing.T) {
+	reply, err := dhcpv4.New(
+		dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer),
+		dhcpv4.WithHwAddr(clientMAC),
+		dhcpv4.WithYourIP(net.IP{192, 168, 1, 100}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := frame(serverMAC, net.IP{192, 168, 1, 1}, reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]byte(clientMAC), b[0:6]); diff != "" {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff([]byte(serverMAC), b[6:12]); diff != "" {
+		t.Fatal(diff)
+	}
+	ip := b[ethHeaderLen:]
+	// a header with a valid checksum sums to zero.
+	if got := checksum(0, ip[:ipv4HeaderLen]); got != 0 {
+		t.Fatalf("IPv4 header checksum does not verify, got %#x", got)
+	}
+	udp := ip[ipv4HeaderLen:]
+	pseudo := uint32(192<<8|168)*2 + uint32(1<<8|1) + uint32(1<<8|100) + protocolUDP + uint32(len(udp))
+	if got := checksum(pseudo, udp); got != 0 {
+		t.Fatalf("UDP checksum does not verify, got %#x", got)
+	}
+	if got := binary.BigEndian.Uint16(udp[2:4]); got != dhcpv4.ClientPort {
+		t.Fatalf("got destination port %d, want %d", got, dhcpv4.ClientPort)
+	}
+	got, err := dhcpv4.FromBytes(udp[udpHeaderLen:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(reply.ToBytes(), got.ToBytes()); diff != "" {
+		t.Fatal(diff)
+	}
+
+	if _, err := frame(serverMAC, nil, reply); err == nil {
+		t.Fatal("frame() error = nil, want an error without a source IP")
+	}
+}
@@ -0,0 +1,185 @@
+// Package redact redacts the identifiers of clients, MAC addresses and hostnames, in logs and span attributes,
+// for deployments where full identifiers are not allowed to leave the provisioning network.
+package redact
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Mode is how identifiers are redacted.
+type Mode int
+
+const (
+	// ModeNone does not redact identifiers.
+	ModeNone Mode = iota
+	// ModeHash replaces identifiers with a short hash, so that the logs and spans of a client can still be correlated.
+	ModeHash
+	// ModeTruncate keeps the first bytes of identifiers: the OUI, the vendor part, of MAC addresses and
+	// the first characters of hostnames.
+	ModeTruncate
+)
+
+// String returns the name of the mode.
+func (m Mode) String() string {
+	switch m {
+	case ModeNone:
+		return "none"
+	case ModeHash:
+		return "hash"
+	case ModeTruncate:
+		return "truncate"
+	}
+
+	return "unknown"
+}
+
+// hashLen is the number of bytes of the hash that are kept, in hex they are twice as many characters.
+const hashLen = 8
+
+// truncatedLen is the number of characters of a hostname that ModeTruncate keeps.
+const truncatedLen = 2
+
+// masked replaces the truncated part of an identifier.
+const masked = "***"
+
+// The suffixes of the keys of the span attributes, from the otel and data packages, that Attributes redacts.
+var (
+	macKeys      = []string{".Header.chaddr", ".Opt61.ClientIdentifier", "DHCP.MACAddress", "DHCP.ClientIdentifier"}
+	hostnameKeys = []string{".Opt12.Hostname", "DHCP.Hostname"}
+)
+
+// macPattern matches the MAC addresses in a text, in the colon or hyphen separated form of net.HardwareAddr.String.
+var macPattern = regexp.MustCompile(`\b[0-9a-fA-F]{2}(?:(?::[0-9a-fA-F]{2}){5,19}|(?:-[0-9a-fA-F]{2}){5,19})\b`)
+
+// Redactor redacts identifiers. The zero value does not redact anything.
+type Redactor struct {
+	// Mode is how identifiers are redacted.
+	Mode Mode
+
+	// Key, when set, is the key of the HMAC-SHA256 hash of ModeHash. Without a key, anyone can hash all the MAC
+	// addresses of a vendor and find the one that matches a hash, so a key should be set, and kept secret.
+	Key []byte
+}
+
+// MAC returns mac redacted.
+func (r Redactor) MAC(mac net.HardwareAddr) string {
+	switch r.Mode {
+	case ModeHash:
+		return r.hash(mac)
+	case ModeTruncate:
+		if len(mac) <= 3 {
+			return masked
+		}
+		return mac[:3].String() + ":" + masked
+	}
+
+	return mac.String()
+}
+
+// Hostname returns the hostname host redacted.
+func (r Redactor) Hostname(host string) string {
+	if host == "" {
+		return ""
+	}
+	switch r.Mode {
+	case ModeHash:
+		return r.hash([]byte(host))
+	case ModeTruncate:
+		if len(host) <= truncatedLen {
+			return masked
+		}
+		return host[:truncatedLen] + masked
+	}
+
+	return host
+}
+
+// Error returns the text of err with the MAC addresses in it redacted, for the errors of backends, which usually
+// name the MAC address that was read. It returns "" when err is nil.
+func (r Redactor) Error(err error) string {
+	if err == nil {
+		return ""
+	}
+	if r.Mode == ModeNone {
+		return err.Error()
+	}
+
+	return macPattern.ReplaceAllStringFunc(err.Error(), func(s string) string {
+		mac, err := net.ParseMAC(s)
+		if err != nil {
+			return masked
+		}
+		return r.MAC(mac)
+	})
+}
+
+// Attributes returns kv with the MAC addresses, client identifiers, and hostnames that the otel and data packages
+// encode redacted. kv is modified in place.
+func (r Redactor) Attributes(kv []attribute.KeyValue) []attribute.KeyValue {
+	if r.Mode == ModeNone {
+		return kv
+	}
+	for i, a := range kv {
+		v := a.Value.AsString()
+		if a.Value.Type() != attribute.STRING || v == "" {
+			continue
+		}
+		switch {
+		case hasSuffix(string(a.Key), macKeys):
+			mac, err := net.ParseMAC(v)
+			if err != nil {
+				// client identifiers usually hold a MAC address, but not in a form that ParseMAC accepts.
+				kv[i].Value = attribute.StringValue(r.Hostname(v))
+				continue
+			}
+			kv[i].Value = attribute.StringValue(r.MAC(mac))
+		case hasSuffix(string(a.Key), hostnameKeys):
+			kv[i].Value = attribute.StringValue(r.Hostname(v))
+		}
+	}
+
+	return kv
+}
+
+// hash returns the first hashLen bytes of the HMAC-SHA256 of b with Key, in hex.
+func (r Redactor) hash(b []byte) string {
+	h := hmac.New(sha256.New, r.Key)
+	_, _ = h.Write(b)
+
+	return hex.EncodeToString(h.Sum(nil)[:hashLen])
+}
+
+// hasSuffix returns true if s ends with one of suffixes.
+func hasSuffix(s string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// contextKey is the key of the Redactor of a context.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries r, so that the backends that are read with ctx redact their spans and
+// logs like the handler does.
+func NewContext(ctx context.Context, r Redactor) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the Redactor of ctx, or the zero Redactor, which does not redact anything, if ctx has none.
+func FromContext(ctx context.Context) Redactor {
+	r, _ := ctx.Value(contextKey{}).(Redactor)
+
+	return r
+}
@@ -0,0 +1,120 @@
+package redact
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestMAC(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	tests := map[string]struct {
+		r    Redactor
+		mac  net.HardwareAddr
+		want string
+	}{
+		"none":           {mac: mac, want: "00:01:02:03:04:05"},
+		"truncate":       {r: Redactor{Mode: ModeTruncate}, mac: mac, want: "00:01:02:***"},
+		"truncate short": {r: Redactor{Mode: ModeTruncate}, mac: mac[:2], want: "***"},
+		"hash":           {r: Redactor{Mode: ModeHash}, mac: mac, want: "43fb7274fc096583"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.r.MAC(tt.mac); got != tt.want {
+				t.Fatalf("MAC() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	r := Redactor{Mode: ModeHash, Key: []byte("secret")}
+	if got := r.MAC(mac); got != r.MAC(mac) || len(got) != 2*hashLen {
+		t.Fatalf("MAC() = %q, want the same hash of %d characters for the same MAC", got, 2*hashLen)
+	}
+	if r.MAC(mac) == (Redactor{Mode: ModeHash}).MAC(mac) {
+		t.Fatal("MAC() hashes are the same with and without a key")
+	}
+}
+
+func TestHostname(t *testing.T) {
+	tests := map[string]struct {
+		r    Redactor
+		host string
+		want string
+	}{
+		"none":           {host: "server1", want: "server1"},
+		"truncate":       {r: Redactor{Mode: ModeTruncate}, host: "server1", want: "se***"},
+		"truncate short": {r: Redactor{Mode: ModeTruncate}, host: "s1", want: "***"},
+		"empty":          {r: Redactor{Mode: ModeHash}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.r.Hostname(tt.host); got != tt.want {
+				t.Fatalf("Hostname() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	kv := []attribute.KeyValue{
+		attribute.String("DHCP.request.Header.chaddr", "00:01:02:03:04:05"),
+		attribute.String("DHCP.request.Opt12.Hostname", "server1"),
+		attribute.String("DHCP.request.Opt61.ClientIdentifier", "1.0.1.2.3.4.5"),
+		attribute.String("DHCP.MACAddress", "00:01:02:03:04:05"),
+		attribute.String("DHCP.Hostname", ""),
+		attribute.String("DHCP.request.Header.yiaddr", "192.168.2.150"),
+		attribute.Int64("DHCP.LeaseTime", 3600),
+	}
+	want := []attribute.KeyValue{
+		attribute.String("DHCP.request.Header.chaddr", "00:01:02:***"),
+		attribute.String("DHCP.request.Opt12.Hostname", "se***"),
+		attribute.String("DHCP.request.Opt61.ClientIdentifier", "1.***"),
+		attribute.String("DHCP.MACAddress", "00:01:02:***"),
+		attribute.String("DHCP.Hostname", ""),
+		attribute.String("DHCP.request.Header.yiaddr", "192.168.2.150"),
+		attribute.Int64("DHCP.LeaseTime", 3600),
+	}
+	got := Redactor{Mode: ModeTruncate}.Attributes(kv)
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(attribute.Value{})); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestError(t *testing.T) {
+	err := errors.New("failed listing hardware for (00:01:02:03:04:05): dial tcp 10.0.0.1:6443: connection refused")
+	tests := map[string]struct {
+		r    Redactor
+		err  error
+		want string
+	}{
+		"nil":      {r: Redactor{Mode: ModeHash}, want: ""},
+		"none":     {err: err, want: err.Error()},
+		"truncate": {r: Redactor{Mode: ModeTruncate}, err: err, want: "failed listing hardware for (00:01:02:***): dial tcp 10.0.0.1:6443: connection refused"},
+		"hash":     {r: Redactor{Mode: ModeHash}, err: err, want: "failed listing hardware for (43fb7274fc096583): dial tcp 10.0.0.1:6443: connection refused"},
+		"hyphens":  {r: Redactor{Mode: ModeTruncate}, err: errors.New("not found: 00-01-02-03-04-05"), want: "not found: 00:01:02:***"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.r.Error(tt.err); got != tt.want {
+				t.Fatalf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	if got := FromContext(context.Background()); got.Mode != ModeNone {
+		t.Fatalf("FromContext() mode = %v, want %v", got.Mode, ModeNone)
+	}
+	r := Redactor{Mode: ModeHash, Key: []byte("key")}
+	if got := FromContext(NewContext(context.Background(), r)); got.Mode != ModeHash || string(got.Key) != "key" {
+		t.Fatalf("FromContext() = %+v, want %+v", got, r)
+	}
+}
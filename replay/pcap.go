@@ -0,0 +1,309 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp"
+)
+
+// Magic numbers of the capture file formats, see https://www.ietf.org/archive/id/draft-ietf-opsawg-pcap-03.html and
+// https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng-01.html.
+const (
+	pcapMicro       = 0xa1b2c3d4 // pcap with microsecond timestamps.
+	pcapNano        = 0xa1b23c4d // pcap with nanosecond timestamps.
+	pcapngSHB       = 0x0a0d0d0a // pcapng section header block, the first block of a file.
+	pcapngByteOrder = 0x1a2b3c4d // pcapng byte order magic, in the section header block.
+	pcapngIDB       = 0x00000001 // pcapng interface description block.
+	pcapngSPB       = 0x00000003 // pcapng simple packet block.
+	pcapngEPB       = 0x00000006 // pcapng enhanced packet block.
+)
+
+// Link types of the captured frames, see https://www.tcpdump.org/linktypes.html.
+const (
+	linkNull     = 0   // BSD loopback, lo0 on macOS.
+	linkEthernet = 1   // Ethernet.
+	linkRaw      = 101 // raw IP.
+	linkSLL      = 113 // Linux cooked capture v1, "tcpdump -i any".
+	linkIPv4     = 228 // raw IPv4.
+	linkSLL2     = 276 // Linux cooked capture v2.
+)
+
+// maxRecordSize bounds the size of a record or block of a capture file, so a corrupt length does not allocate
+// gigabytes. It is larger than any frame a DHCP message is captured in.
+const maxRecordSize = 1 << 18
+
+// ErrFormat is returned by Read for a file that is not a pcap or pcapng capture file, or is corrupt.
+var ErrFormat = errors.New("not a pcap or pcapng capture file")
+
+// Packet is a DHCP message of a capture.
+type Packet struct {
+	// Time is when the message was captured.
+	Time time.Time
+	// Src and Dst are the addresses of the UDP datagram that carried the message.
+	Src, Dst netip.AddrPort
+	// Msg is the message.
+	Msg *dhcpv4.DHCPv4
+}
+
+// Read returns the DHCPv4 messages of a pcap or pcapng capture, as written by tcpdump or Wireshark, in the order they
+// were captured. Messages are the UDP datagrams from or to the DHCP server, client, and PXE ports, 67, 68, and 4011,
+// that parse as DHCPv4 messages, other frames are skipped. The frames can be captured on Ethernet, with or without
+// VLAN tags, on "any" interface of Linux, on the loopback interface, or as raw IP.
+func Read(r io.Reader) ([]Packet, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFormat, err)
+	}
+	switch {
+	case binary.BigEndian.Uint32(magic) == pcapngSHB:
+		return readPcapng(br)
+	case binary.LittleEndian.Uint32(magic) == pcapMicro, binary.LittleEndian.Uint32(magic) == pcapNano:
+		return readPcap(br, binary.LittleEndian)
+	case binary.BigEndian.Uint32(magic) == pcapMicro, binary.BigEndian.Uint32(magic) == pcapNano:
+		return readPcap(br, binary.BigEndian)
+	}
+
+	return nil, ErrFormat
+}
+
+// readPcap reads a pcap file written in byte order bo.
+func readPcap(r io.Reader, bo binary.ByteOrder) ([]Packet, error) {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("%w: file header: %w", ErrFormat, err)
+	}
+	nano := bo.Uint32(hdr[0:4]) == pcapNano
+	link := bo.Uint32(hdr[20:24]) & 0xffff // the upper bits are the FCS length and flags.
+
+	var packets []Packet
+	for i := 1; ; i++ {
+		var rec [16]byte
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return packets, nil
+			}
+			return nil, fmt.Errorf("%w: record %d: %w", ErrFormat, i, err)
+		}
+		n := bo.Uint32(rec[8:12])
+		if n > maxRecordSize {
+			return nil, fmt.Errorf("%w: record %d of %d bytes", ErrFormat, i, n)
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, fmt.Errorf("%w: record %d: %w", ErrFormat, i, err)
+		}
+		frac := time.Duration(bo.Uint32(rec[4:8]))
+		if !nano {
+			frac *= time.Microsecond
+		}
+		t := time.Unix(int64(bo.Uint32(rec[0:4])), int64(frac))
+		if p, ok := packet(link, frame, t); ok {
+			packets = append(packets, p)
+		}
+	}
+}
+
+// pcapngInterface is an interface of a pcapng section, which the packets captured on it refer to.
+type pcapngInterface struct {
+	link uint32
+	// unit is the duration of a unit of the timestamps of the interface.
+	unit time.Duration
+}
+
+// readPcapng reads a pcapng file. Each section of the file can have its own byte order and interfaces.
+func readPcapng(r io.Reader) ([]Packet, error) {
+	var (
+		packets []Packet
+		bo      binary.ByteOrder = binary.LittleEndian
+		ifaces  []pcapngInterface
+	)
+	for i := 1; ; i++ {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			if errors.Is(err, io.EOF) && i > 1 {
+				return packets, nil
+			}
+			return nil, fmt.Errorf("%w: block %d: %w", ErrFormat, i, err)
+		}
+		typ := binary.BigEndian.Uint32(hdr[0:4])
+		if typ == pcapngSHB {
+			// the byte order of a section is that of the magic that follows the length of its header block.
+			var m [4]byte
+			if _, err := io.ReadFull(r, m[:]); err != nil {
+				return nil, fmt.Errorf("%w: block %d: %w", ErrFormat, i, err)
+			}
+			switch {
+			case binary.LittleEndian.Uint32(m[:]) == pcapngByteOrder:
+				bo = binary.LittleEndian
+			case binary.BigEndian.Uint32(m[:]) == pcapngByteOrder:
+				bo = binary.BigEndian
+			default:
+				return nil, fmt.Errorf("%w: block %d: invalid byte order magic", ErrFormat, i)
+			}
+			ifaces = nil
+		} else {
+			typ = bo.Uint32(hdr[0:4])
+		}
+		l := bo.Uint32(hdr[4:8])
+		if l < 12 || l%4 != 0 || l > maxRecordSize {
+			return nil, fmt.Errorf("%w: block %d of %d bytes", ErrFormat, i, l)
+		}
+		// the body is the block without its type, length, and trailing length, and without the byte order magic that
+		// was already read for a section header block.
+		bodyLen := l - 12
+		if typ == pcapngSHB {
+			if bodyLen < 4 {
+				return nil, fmt.Errorf("%w: block %d of %d bytes", ErrFormat, i, l)
+			}
+			bodyLen -= 4
+		}
+		body := make([]byte, bodyLen+4)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, fmt.Errorf("%w: block %d: %w", ErrFormat, i, err)
+		}
+		body = body[:bodyLen]
+
+		switch typ {
+		case pcapngIDB:
+			if len(body) < 8 {
+				return nil, fmt.Errorf("%w: block %d: interface description block of %d bytes", ErrFormat, i, l)
+			}
+			ifaces = append(ifaces, pcapngInterface{link: uint32(bo.Uint16(body[0:2])), unit: tsUnit(bo, body[8:])})
+		case pcapngEPB:
+			if len(body) < 20 {
+				return nil, fmt.Errorf("%w: block %d: enhanced packet block of %d bytes", ErrFormat, i, l)
+			}
+			id, n := bo.Uint32(body[0:4]), bo.Uint32(body[12:16])
+			if int(id) >= len(ifaces) || n > uint32(len(body)-20) {
+				return nil, fmt.Errorf("%w: block %d: invalid enhanced packet block", ErrFormat, i)
+			}
+			ts := uint64(bo.Uint32(body[4:8]))<<32 | uint64(bo.Uint32(body[8:12]))
+			t := time.Unix(0, 0).Add(time.Duration(ts) * ifaces[id].unit)
+			if p, ok := packet(ifaces[id].link, body[20:20+n], t); ok {
+				packets = append(packets, p)
+			}
+		case pcapngSPB:
+			// a simple packet block has no timestamp, and is captured on the first interface.
+			if len(body) < 4 || len(ifaces) == 0 {
+				return nil, fmt.Errorf("%w: block %d: invalid simple packet block", ErrFormat, i)
+			}
+			n := min(bo.Uint32(body[0:4]), uint32(len(body)-4))
+			if p, ok := packet(ifaces[0].link, body[4:4+n], time.Time{}); ok {
+				packets = append(packets, p)
+			}
+		}
+	}
+}
+
+// tsUnit returns the unit of the timestamps of an interface, from the if_tsresol option in opts, the options of its
+// interface description block. The default unit is a microsecond.
+func tsUnit(bo binary.ByteOrder, opts []byte) time.Duration {
+	for len(opts) >= 4 {
+		code, l := bo.Uint16(opts[0:2]), int(bo.Uint16(opts[2:4]))
+		if code == 0 || 4+l > len(opts) {
+			break
+		}
+		if code == 9 && l == 1 {
+			res := opts[4]
+			if res&0x80 != 0 {
+				// a negative power of two, which no capture tool writes. Nanoseconds are close enough.
+				return time.Nanosecond
+			}
+			unit := time.Second
+			for i := byte(0); i < res && unit > 1; i++ {
+				unit /= 10
+			}
+			return unit
+		}
+		opts = opts[4+(l+3)&^3:]
+	}
+
+	return time.Microsecond
+}
+
+// packet returns the DHCP message of frame, a frame of link type link captured at t. It returns false if the frame is
+// not an IPv4 UDP datagram of a DHCP port, or its payload is not a DHCPv4 message.
+func packet(link uint32, frame []byte, t time.Time) (Packet, bool) {
+	ip, ok := ipv4Packet(link, frame)
+	if !ok || len(ip) < 20 {
+		return Packet{}, false
+	}
+	ihl := int(ip[0]&0x0f) * 4
+	total := int(binary.BigEndian.Uint16(ip[2:4]))
+	if ip[0]>>4 != 4 || ihl < 20 || total < ihl+8 || total > len(ip) || ip[9] != 17 {
+		return Packet{}, false
+	}
+	if binary.BigEndian.Uint16(ip[6:8])&0x3fff != 0 {
+		// a fragment, DHCP messages are never fragmented.
+		return Packet{}, false
+	}
+	udp := ip[ihl:total]
+	sport, dport := binary.BigEndian.Uint16(udp[0:2]), binary.BigEndian.Uint16(udp[2:4])
+	if !dhcpPort(sport) && !dhcpPort(dport) {
+		return Packet{}, false
+	}
+	l := int(binary.BigEndian.Uint16(udp[4:6]))
+	if l < 8 || l > len(udp) {
+		return Packet{}, false
+	}
+	m, err := dhcpv4.FromBytes(udp[8:l])
+	if err != nil {
+		return Packet{}, false
+	}
+
+	return Packet{
+		Time: t,
+		Src:  netip.AddrPortFrom(netip.AddrFrom4([4]byte(ip[12:16])), sport),
+		Dst:  netip.AddrPortFrom(netip.AddrFrom4([4]byte(ip[16:20])), dport),
+		Msg:  m,
+	}, true
+}
+
+// ipv4Packet returns the IPv4 packet that frame, a frame of link type link, carries.
+func ipv4Packet(link uint32, frame []byte) ([]byte, bool) {
+	const etherTypeIPv4, etherTypeVLAN, etherTypeQinQ = 0x0800, 0x8100, 0x88a8
+	switch link {
+	case linkEthernet:
+		if len(frame) < 14 {
+			return nil, false
+		}
+		et, b := binary.BigEndian.Uint16(frame[12:14]), frame[14:]
+		for (et == etherTypeVLAN || et == etherTypeQinQ) && len(b) >= 4 {
+			et, b = binary.BigEndian.Uint16(b[2:4]), b[4:]
+		}
+		return b, et == etherTypeIPv4
+	case linkSLL:
+		if len(frame) < 16 {
+			return nil, false
+		}
+		return frame[16:], binary.BigEndian.Uint16(frame[14:16]) == etherTypeIPv4
+	case linkSLL2:
+		if len(frame) < 20 {
+			return nil, false
+		}
+		return frame[20:], binary.BigEndian.Uint16(frame[0:2]) == etherTypeIPv4
+	case linkNull:
+		// the address family, AF_INET, is in the byte order of the host that captured the frame.
+		if len(frame) < 4 {
+			return nil, false
+		}
+		return frame[4:], binary.LittleEndian.Uint32(frame[0:4]) == 2 || binary.BigEndian.Uint32(frame[0:4]) == 2
+	case linkRaw, linkIPv4:
+		return frame, true
+	}
+
+	return nil, false
+}
+
+// dhcpPort returns true if port is the DHCP server, client, or PXE port.
+func dhcpPort(port uint16) bool {
+	return port == dhcpv4.ServerPort || port == dhcpv4.ClientPort || port == dhcp.PXEPort
+}
@@ -0,0 +1,221 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+var (
+	clientMAC = net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	client    = netip.MustParseAddrPort("0.0.0.0:68")
+	bcast     = netip.MustParseAddrPort("255.255.255.255:67")
+	server    = netip.MustParseAddrPort("192.168.2.225:67")
+	captured  = time.Date(2024, 5, 1, 10, 30, 0, 123456000, time.UTC)
+)
+
+// datagram returns an IPv4 packet of a UDP datagram with payload from src to dst, without checksums.
+func datagram(src, dst netip.AddrPort, payload []byte) []byte {
+	b := make([]byte, 28+len(payload))
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	b[8], b[9] = 64, 17
+	s, d := src.Addr().As4(), dst.Addr().As4()
+	copy(b[12:16], s[:])
+	copy(b[16:20], d[:])
+	binary.BigEndian.PutUint16(b[20:22], src.Port())
+	binary.BigEndian.PutUint16(b[22:24], dst.Port())
+	binary.BigEndian.PutUint16(b[24:26], uint16(8+len(payload)))
+	copy(b[28:], payload)
+
+	return b
+}
+
+// ethernet returns an Ethernet frame of the IPv4 packet ip, tagged with vlans.
+func ethernet(ip []byte, vlans ...uint16) []byte {
+	b := append(make([]byte, 12), 0, 0)
+	copy(b[6:12], clientMAC)
+	for _, v := range vlans {
+		binary.BigEndian.PutUint16(b[len(b)-2:], 0x8100)
+		b = binary.BigEndian.AppendUint16(b, v)
+		b = append(b, 0, 0)
+	}
+	binary.BigEndian.PutUint16(b[len(b)-2:], 0x0800)
+
+	return append(b, ip...)
+}
+
+// pcapFile returns a pcap file of frames of link type link, in byte order bo, captured one second apart from captured.
+func pcapFile(bo binary.AppendByteOrder, link uint32, frames ...[]byte) []byte {
+	var b []byte
+	b = bo.AppendUint32(b, pcapMicro)
+	b = bo.AppendUint16(b, 2)
+	b = bo.AppendUint16(b, 4)
+	b = append(b, make([]byte, 8)...)
+	b = bo.AppendUint32(b, 65535)
+	b = bo.AppendUint32(b, link)
+	for i, f := range frames {
+		t := captured.Add(time.Duration(i) * time.Second)
+		b = bo.AppendUint32(b, uint32(t.Unix()))
+		b = bo.AppendUint32(b, uint32(t.Nanosecond()/1000))
+		b = bo.AppendUint32(b, uint32(len(f)))
+		b = bo.AppendUint32(b, uint32(len(f)))
+		b = append(b, f...)
+	}
+
+	return b
+}
+
+// pcapngFile returns a pcapng file of frames of link type link, captured on one interface one second apart from captured.
+func pcapngFile(link uint16, frames ...[]byte) []byte {
+	bo := binary.LittleEndian
+	block := func(b []byte, typ uint32, body []byte) []byte {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		b = bo.AppendUint32(b, typ)
+		b = bo.AppendUint32(b, uint32(12+len(body)))
+		b = append(b, body...)
+		return bo.AppendUint32(b, uint32(12+len(body)))
+	}
+	shb := bo.AppendUint32(nil, pcapngByteOrder)
+	shb = bo.AppendUint16(shb, 1)
+	shb = bo.AppendUint16(shb, 0)
+	shb = bo.AppendUint64(shb, ^uint64(0))
+	b := block(nil, pcapngSHB, shb)
+	// an interface with nanosecond timestamps, if_tsresol 9.
+	idb := bo.AppendUint16(nil, link)
+	idb = append(idb, 0, 0)
+	idb = bo.AppendUint32(idb, 0)
+	idb = bo.AppendUint16(idb, 9)
+	idb = bo.AppendUint16(idb, 1)
+	idb = append(idb, 9, 0, 0, 0)
+	idb = append(idb, 0, 0, 0, 0)
+	b = block(b, pcapngIDB, idb)
+	for i, f := range frames {
+		ts := uint64(captured.Add(time.Duration(i) * time.Second).UnixNano())
+		epb := bo.AppendUint32(nil, 0)
+		epb = bo.AppendUint32(epb, uint32(ts>>32))
+		epb = bo.AppendUint32(epb, uint32(ts))
+		epb = bo.AppendUint32(epb, uint32(len(f)))
+		epb = bo.AppendUint32(epb, uint32(len(f)))
+		b = block(b, pcapngEPB, append(epb, f...))
+	}
+
+	return b
+}
+
+func TestRead(t *testing.T) {
+	discover, err := dhcpv4.NewDiscovery(clientMAC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offer, err := dhcpv4.NewReplyFromRequest(discover, dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer), dhcpv4.WithYourIP(net.IP{192, 168, 2, 150}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := datagram(client, bcast, discover.ToBytes())
+	reply := datagram(server, netip.MustParseAddrPort("192.168.2.150:68"), offer.ToBytes())
+	dns := datagram(netip.MustParseAddrPort("192.168.2.150:5353"), netip.MustParseAddrPort("192.168.2.1:53"), []byte("not DHCP"))
+	sll := func(ip []byte) []byte {
+		b := make([]byte, 16)
+		binary.BigEndian.PutUint16(b[14:16], 0x0800)
+		return append(b, ip...)
+	}
+	null := func(ip []byte) []byte { return append(binary.LittleEndian.AppendUint32(nil, 2), ip...) }
+	want := []Packet{
+		{Time: captured, Src: client, Dst: bcast, Msg: discover},
+		{Time: captured.Add(time.Second), Src: server, Dst: netip.MustParseAddrPort("192.168.2.150:68"), Msg: offer},
+	}
+	tests := map[string]struct {
+		file    []byte
+		want    []Packet
+		wantErr error
+	}{
+		"ethernet":          {file: pcapFile(binary.LittleEndian, linkEthernet, ethernet(req), ethernet(reply), ethernet(dns)), want: want},
+		"big endian":        {file: pcapFile(binary.BigEndian, linkEthernet, ethernet(req), ethernet(reply)), want: want},
+		"vlan":              {file: pcapFile(binary.LittleEndian, linkEthernet, ethernet(req, 100), ethernet(reply, 100, 200)), want: want},
+		"linux cooked":      {file: pcapFile(binary.LittleEndian, linkSLL, sll(req), sll(reply)), want: want},
+		"loopback":          {file: pcapFile(binary.LittleEndian, linkNull, null(req), null(reply)), want: want},
+		"raw":               {file: pcapFile(binary.LittleEndian, linkRaw, req, reply), want: want},
+		"pcapng":            {file: pcapngFile(linkEthernet, ethernet(req), ethernet(dns), ethernet(reply)), want: []Packet{want[0], {Time: captured.Add(2 * time.Second), Src: want[1].Src, Dst: want[1].Dst, Msg: offer}}},
+		"no DHCP":           {file: pcapFile(binary.LittleEndian, linkEthernet, ethernet(dns))},
+		"truncated frame":   {file: pcapFile(binary.LittleEndian, linkEthernet, ethernet(req)[:100])},
+		"unknown link type": {file: pcapFile(binary.LittleEndian, 999, req)},
+		"not a capture":     {file: []byte("hardware:\n  mac: 00:01:02:03:04:05\n"), wantErr: ErrFormat},
+		"empty":             {file: nil, wantErr: ErrFormat},
+		"truncated record":  {file: pcapFile(binary.LittleEndian, linkEthernet, ethernet(req))[:50], wantErr: ErrFormat},
+		"truncated pcapng":  {file: pcapngFile(linkEthernet, ethernet(req))[:70], wantErr: ErrFormat},
+		"no records":        {file: pcapFile(binary.LittleEndian, linkEthernet)},
+		"huge record length": {file: func() []byte {
+			b := pcapFile(binary.LittleEndian, linkEthernet, ethernet(req))
+			binary.LittleEndian.PutUint32(b[24+8:], 1<<30)
+			return b
+		}(), wantErr: ErrFormat},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Read(bytes.NewReader(tt.file))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.Comparer(func(a, b *dhcpv4.DHCPv4) bool {
+				return bytes.Equal(a.ToBytes(), b.ToBytes())
+			}), cmp.Comparer(func(a, b netip.AddrPort) bool { return a == b })); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestTSUnit(t *testing.T) {
+	bo := binary.LittleEndian
+	opt := func(code uint16, v ...byte) []byte {
+		b := bo.AppendUint16(nil, code)
+		b = bo.AppendUint16(b, uint16(len(v)))
+		b = append(b, v...)
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		return b
+	}
+	tests := map[string]struct {
+		opts []byte
+		want time.Duration
+	}{
+		"default":      {want: time.Microsecond},
+		"milliseconds": {opts: opt(9, 3), want: time.Millisecond},
+		"nanoseconds":  {opts: append(opt(2, []byte("eth0")...), opt(9, 9)...), want: time.Nanosecond},
+		"power of two": {opts: opt(9, 0x80|20), want: time.Nanosecond},
+		"truncated":    {opts: opt(9, 9)[:4], want: time.Microsecond},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tsUnit(bo, tt.opts); got != tt.want {
+				t.Fatalf("tsUnit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// FuzzRead feeds arbitrary files to Read, which must not panic or allocate more than a record at a time.
+func FuzzRead(f *testing.F) {
+	discover, err := dhcpv4.NewDiscovery(clientMAC)
+	if err != nil {
+		f.Fatal(err)
+	}
+	req := datagram(client, bcast, discover.ToBytes())
+	f.Add(pcapFile(binary.LittleEndian, linkEthernet, ethernet(req, 100)))
+	f.Add(pcapFile(binary.BigEndian, linkSLL, append(make([]byte, 16), req...)))
+	f.Add(pcapngFile(linkEthernet, ethernet(req)))
+	f.Fuzz(func(_ *testing.T, b []byte) {
+		_, _ = Read(bytes.NewReader(b))
+	})
+}
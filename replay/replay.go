@@ -0,0 +1,188 @@
+// Package replay reproduces the DHCP exchanges of a capture, for example a tcpdump of a client that fails to boot in
+// the field, against a handler in-process or a server over the network, and prints how the replies computed now differ
+// from the replies in the capture.
+//
+// For example, to replay a capture to a reservation handler and its backend:
+//
+//	packets, err := replay.Read(f)
+//	...
+//	c, err := dhcptest.New(handler)
+//	...
+//	defer c.Close()
+//	err = replay.Print(os.Stdout, replay.Replay(ctx, c, packets))
+package replay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/dhcptest"
+)
+
+// Result is a request of a capture, replayed.
+type Result struct {
+	// Request is the request in the capture.
+	Request Packet
+	// Captured is the reply to Request in the capture, or nil if the capture has none.
+	Captured *dhcpv4.DHCPv4
+	// Reply is the reply computed by the handler or server that Request was replayed to, or nil if there is none.
+	Reply *dhcpv4.DHCPv4
+	// Err is the error of the exchange of Request, for example dhcptest.ErrNoReply.
+	Err error
+}
+
+// Replay replays the requests of packets, the BOOTREQUEST messages, with c, one at a time in the order they were
+// captured, and returns their results. The replies in packets are only used as the captured replies of the results.
+//
+// A relayed request is replayed as a request from a client on the link, with giaddr cleared, so the reply is sent
+// back to c instead of to the relay agent in the capture. DHCPRELEASE and DHCPDECLINE messages, which have no reply,
+// are sent without waiting for one.
+func Replay(ctx context.Context, c *dhcptest.Client, packets []Packet) []Result {
+	var results []Result
+	claimed := map[int]bool{}
+	for i, p := range packets {
+		if p.Msg.OpCode != dhcpv4.OpcodeBootRequest {
+			continue
+		}
+		r := Result{Request: p}
+		if j := capturedReply(packets, i, claimed); j >= 0 {
+			claimed[j] = true
+			r.Captured = packets[j].Msg
+		}
+		m, err := dhcpv4.FromBytes(p.Msg.ToBytes())
+		if err != nil {
+			r.Err = err
+			results = append(results, r)
+			continue
+		}
+		m.GatewayIPAddr = net.IPv4zero
+		switch m.MessageType() {
+		case dhcpv4.MessageTypeRelease, dhcpv4.MessageTypeDecline:
+			r.Err = c.Send(ctx, m)
+		default:
+			r.Reply, r.Err = c.Exchange(ctx, m)
+		}
+		results = append(results, r)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return results
+}
+
+// capturedReply returns the index of the first reply after the request packets[i] with its transaction ID and client
+// hardware address that is not claimed by an earlier request, or -1 if there is none.
+func capturedReply(packets []Packet, i int, claimed map[int]bool) int {
+	req := packets[i].Msg
+	for j := i + 1; j < len(packets); j++ {
+		m := packets[j].Msg
+		if m.OpCode == dhcpv4.OpcodeBootReply && !claimed[j] && m.TransactionID == req.TransactionID && bytes.Equal(m.ClientHWAddr, req.ClientHWAddr) {
+			return j
+		}
+	}
+
+	return -1
+}
+
+// Print writes results to w: for each request, the replies in the capture and of the replay, the fields and options
+// in which they differ, "-" for the value captured and "+" for the value replayed, and the reply of the replay in full.
+func Print(w io.Writer, results []Result) error {
+	var errs []error
+	printf := func(format string, a ...any) {
+		if _, err := fmt.Fprintf(w, format, a...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for i, r := range results {
+		req := r.Request.Msg
+		printf("#%d %v %v from %v, xid %v", i+1, r.Request.Time.Format("15:04:05.000000"), req.MessageType(), req.ClientHWAddr, req.TransactionID)
+		if req.GatewayIPAddr != nil && !req.GatewayIPAddr.IsUnspecified() {
+			printf(", relayed by %v", req.GatewayIPAddr)
+		}
+		printf("\n")
+		printf("  captured: %v\n", brief(r.Captured))
+		switch {
+		case errors.Is(r.Err, dhcptest.ErrNoReply):
+			printf("  replayed: no reply\n")
+		case r.Err != nil:
+			printf("  replayed: error: %v\n", r.Err)
+		case r.Reply == nil:
+			// a DHCPRELEASE or DHCPDECLINE, which has no reply.
+			printf("  replayed: sent\n")
+		default:
+			printf("  replayed: %v\n", brief(r.Reply))
+		}
+		if r.Captured != nil && r.Reply != nil {
+			for _, d := range diff(r.Captured, r.Reply) {
+				printf("    %v\n", d)
+			}
+		}
+		if r.Reply != nil {
+			printf("%v\n", r.Reply.Summary())
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// brief returns a one line description of the reply m.
+func brief(m *dhcpv4.DHCPv4) string {
+	if m == nil {
+		return "no reply"
+	}
+
+	return fmt.Sprintf("%v, yiaddr %v, siaddr %v, file %q", m.MessageType(), m.YourIPAddr, m.ServerIPAddr, m.BootFileName)
+}
+
+// diff returns the fields and options in which the replies captured and replayed differ, in the order of the fields
+// and option codes, as the lines of a diff: the value captured prefixed with "-" and the value replayed with "+".
+func diff(captured, replayed *dhcpv4.DHCPv4) []string {
+	var d []string
+	field := func(name string, c, r any, differ bool) {
+		if differ {
+			d = append(d, fmt.Sprintf("- %v: %v", name, c), fmt.Sprintf("+ %v: %v", name, r))
+		}
+	}
+	field("yiaddr", captured.YourIPAddr, replayed.YourIPAddr, !captured.YourIPAddr.Equal(replayed.YourIPAddr))
+	field("siaddr", captured.ServerIPAddr, replayed.ServerIPAddr, !captured.ServerIPAddr.Equal(replayed.ServerIPAddr))
+	field("file", captured.BootFileName, replayed.BootFileName, captured.BootFileName != replayed.BootFileName)
+
+	var codes []uint8
+	for c := range captured.Options {
+		codes = append(codes, c)
+	}
+	for c := range replayed.Options {
+		if _, ok := captured.Options[c]; !ok {
+			codes = append(codes, c)
+		}
+	}
+	slices.Sort(codes)
+	for _, c := range codes {
+		cv, cok := captured.Options[c]
+		rv, rok := replayed.Options[c]
+		if cok && rok && bytes.Equal(cv, rv) {
+			continue
+		}
+		if cok {
+			d = append(d, "- "+option(c, cv))
+		}
+		if rok {
+			d = append(d, "+ "+option(c, rv))
+		}
+	}
+
+	return d
+}
+
+// option returns the name and the value of the option code c, as dhcpv4 formats them.
+func option(c uint8, v []byte) string {
+	return strings.TrimSpace(dhcpv4.Options{c: v}.String())
+}
@@ -0,0 +1,99 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/dhcp/backend/static"
+	"github.com/tinkerbell/dhcp/data"
+	"github.com/tinkerbell/dhcp/dhcptest"
+	"github.com/tinkerbell/dhcp/handler/reservation"
+)
+
+func TestReplay(t *testing.T) {
+	b, err := static.New(static.Record{
+		DHCP: data.DHCP{MACAddress: clientMAC, IPAddress: netip.MustParseAddr("192.168.2.150"), SubnetMask: net.IPv4Mask(255, 255, 255, 0), LeaseTime: 3600},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &reservation.Handler{Log: logr.Discard(), IPAddr: netip.MustParseAddr("192.168.2.225"), Backend: b}
+	c, err := dhcptest.New(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Timeout = 100 * time.Millisecond
+
+	// the capture of a client that was offered another IP address by the server in the field, through a relay agent.
+	discover, err := dhcpv4.NewDiscovery(clientMAC, dhcpv4.WithGatewayIP(net.IP{192, 168, 2, 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offer, err := dhcpv4.NewReplyFromRequest(discover,
+		dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer),
+		dhcpv4.WithYourIP(net.IP{192, 168, 2, 99}),
+		dhcpv4.WithServerIP(net.IP{192, 168, 2, 225}),
+		dhcpv4.WithOption(dhcpv4.OptServerIdentifier(net.IP{192, 168, 2, 225})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, err := dhcpv4.New(dhcpv4.WithHwAddr(clientMAC), dhcpv4.WithMessageType(dhcpv4.MessageTypeRelease))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown, err := dhcpv4.NewDiscovery(net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x06})
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets := []Packet{
+		{Time: captured, Msg: discover},
+		{Time: captured, Msg: offer},
+		{Time: captured, Msg: release},
+		{Time: captured, Msg: unknown},
+	}
+
+	results := Replay(context.Background(), c, packets)
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if r := results[0]; r.Captured != offer || r.Reply == nil || r.Err != nil {
+		t.Fatalf("got captured reply %v, reply %v, error %v, want the captured offer and a reply", r.Captured, r.Reply, r.Err)
+	}
+	dhcptest.Assert(t, results[0].Reply, dhcptest.MessageType(dhcpv4.MessageTypeOffer), dhcptest.YourIP("192.168.2.150"))
+	if r := results[1]; r.Captured != nil || r.Reply != nil || r.Err != nil {
+		t.Fatalf("got captured reply %v, reply %v, error %v, want none for a DHCPRELEASE", r.Captured, r.Reply, r.Err)
+	}
+	if r := results[2]; !errors.Is(r.Err, dhcptest.ErrNoReply) {
+		t.Fatalf("got error %v, want %v for a client that is not in the backend", r.Err, dhcptest.ErrNoReply)
+	}
+	if !discover.GatewayIPAddr.Equal(net.IP{192, 168, 2, 1}) {
+		t.Fatalf("Replay() changed the giaddr of the captured request to %v", discover.GatewayIPAddr)
+	}
+
+	var out strings.Builder
+	if err := Print(&out, results); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"#1 10:30:00.123456 DISCOVER from 00:01:02:03:04:05, xid " + discover.TransactionID.String() + ", relayed by 192.168.2.1\n",
+		"  captured: OFFER, yiaddr 192.168.2.99, siaddr 192.168.2.225, file \"\"\n",
+		"  replayed: OFFER, yiaddr 192.168.2.150, siaddr 192.168.2.225, file \"\"\n",
+		"    - yiaddr: 192.168.2.99\n    + yiaddr: 192.168.2.150\n",
+		"    + Subnet Mask: ffffff00\n",
+		"#2 10:30:00.123456 RELEASE from 00:01:02:03:04:05",
+		"  captured: no reply\n  replayed: sent\n#3",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Print() output does not contain %q:\n%v", want, out.String())
+		}
+	}
+}
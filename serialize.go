@@ -0,0 +1,60 @@
+package dhcp
+
+import (
+	"net"
+	"sync"
+)
+
+// serialKey identifies the jobs that a serializer runs one at a time: those of one handler for one client.
+type serialKey struct {
+	handler int
+	mac     string
+}
+
+// serializer runs the jobs of each key one at a time, in the order they are submitted.
+// A nil serializer does not serialize.
+type serializer struct {
+	mu   sync.Mutex
+	last map[serialKey]chan struct{} // closed when the last job submitted for the key is done
+}
+
+// newSerializer returns a serializer, or nil when enabled is false.
+func newSerializer(enabled bool) *serializer {
+	if !enabled {
+		return nil
+	}
+
+	return &serializer{last: make(map[serialKey]chan struct{})}
+}
+
+// wrap returns run, which runs job once the jobs of handler and mac that were wrapped before it are done.
+// If run is not going to be called, for example because the job was dropped, skip must be called instead,
+// so the jobs wrapped after it do not wait forever.
+func (s *serializer) wrap(handler int, mac net.HardwareAddr, job func()) (run, skip func()) {
+	if s == nil {
+		return job, func() {}
+	}
+	k := serialKey{handler: handler, mac: mac.String()}
+	done := make(chan struct{})
+	s.mu.Lock()
+	prev := s.last[k]
+	s.last[k] = done
+	s.mu.Unlock()
+
+	chained := func(job func()) {
+		if prev != nil {
+			<-prev
+		}
+		defer func() {
+			close(done)
+			s.mu.Lock()
+			if s.last[k] == done {
+				delete(s.last, k)
+			}
+			s.mu.Unlock()
+		}()
+		job()
+	}
+
+	return func() { chained(job) }, func() { go chained(func() {}) }
+}
@@ -0,0 +1,88 @@
+package dhcp
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSerializer(t *testing.T) {
+	s := newSerializer(true)
+	mac := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	var mu sync.Mutex
+	var got []int
+	record := func(i int) func() {
+		return func() {
+			// the first job is the slowest, so jobs run in any order unless they are serialized.
+			time.Sleep(time.Duration(3-i) * 10 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, i)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		run, skip := s.wrap(0, mac, record(i))
+		if i == 1 {
+			// a dropped job does not block the jobs after it.
+			skip()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run()
+		}()
+	}
+	wg.Wait()
+	if diff := cmp.Diff(got, []int{0, 2}); diff != "" {
+		t.Fatal(diff)
+	}
+	if len(s.last) != 0 {
+		t.Fatalf("got %d keys left, want none once all jobs are done", len(s.last))
+	}
+}
+
+func TestSerializerKeys(t *testing.T) {
+	s := newSerializer(true)
+	release := make(chan struct{})
+	run, _ := s.wrap(0, net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, func() { <-release })
+	go run()
+	defer close(release)
+
+	// jobs of another client, or of another handler, do not wait for the blocked job.
+	for _, k := range []struct {
+		handler int
+		mac     net.HardwareAddr
+	}{
+		{handler: 0, mac: net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}},
+		{handler: 1, mac: net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}},
+	} {
+		done := make(chan struct{})
+		run, _ := s.wrap(k.handler, k.mac, func() { close(done) })
+		go run()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("job of handler %d and %v waited for another key", k.handler, k.mac)
+		}
+	}
+}
+
+func TestNilSerializer(t *testing.T) {
+	s := newSerializer(false)
+	if s != nil {
+		t.Fatal("newSerializer() != nil, want nil when disabled")
+	}
+	ran := false
+	run, skip := s.wrap(0, nil, func() { ran = true })
+	run()
+	skip()
+	if !ran {
+		t.Fatal("run() did not run the job")
+	}
+}